@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+func token(args []string) error {
+	return subcommand("token", map[string]func(args []string) error{
+		"create": createToken,
+		"list":   listTokens,
+	}, args)
+}
+
+func createToken(args []string) error {
+	flags, c := newFlagSet("token create")
+	name := flags.String("name", "", "token name")
+	quota := flags.Int64("quota", 0, "remain quota of the token")
+	unlimited := flags.Bool("unlimited", false, "unlimited quota")
+	expires := flags.Duration("expires", 0, "the token expires after this duration, e.g. 720h, never expires if 0")
+	models := flags.String("models", "", "comma separated models the token is allowed to use, all if empty")
+	subnet := flags.String("subnet", "", "allowed subnets, e.g. 192.168.0.0/24")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("--name is required")
+	}
+	expiredTime := int64(-1)
+	if *expires > 0 {
+		expiredTime = time.Now().Add(*expires).Unix()
+	}
+	data, err := c.do(http.MethodPost, "/api/token/", nil, map[string]any{
+		"name":            *name,
+		"remain_quota":    *quota,
+		"unlimited_quota": *unlimited,
+		"expired_time":    expiredTime,
+		"models":          *models,
+		"subnet":          *subnet,
+	})
+	if err != nil {
+		return err
+	}
+	return printJSON(data)
+}
+
+func listTokens(args []string) error {
+	flags, c := newFlagSet("token list")
+	page := flags.Int("page", 0, "page number, starts from 0")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	data, err := c.do(http.MethodGet, "/api/token/", url.Values{"p": {strconv.Itoa(*page)}}, nil)
+	if err != nil {
+		return err
+	}
+	return printJSON(data)
+}
+
+func channel(args []string) error {
+	return subcommand("channel", map[string]func(args []string) error{
+		"add":  addChannel,
+		"list": listChannels,
+		"test": testChannel,
+	}, args)
+}
+
+func addChannel(args []string) error {
+	flags, c := newFlagSet("channel add")
+	name := flags.String("name", "", "channel name")
+	channelType := flags.Int("type", 1, "channel type, see relay/channeltype, 1 is OpenAI")
+	key := flags.String("key", "", "API key, one channel is created for each line")
+	models := flags.String("models", "", "comma separated models")
+	group := flags.String("group", "default", "comma separated groups")
+	baseURL := flags.String("base-url", "", "base URL of the upstream API")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" || *key == "" || *models == "" {
+		return errors.New("--name, --key and --models are required")
+	}
+	data, err := c.do(http.MethodPost, "/api/channel/", nil, map[string]any{
+		"name":     *name,
+		"type":     *channelType,
+		"key":      *key,
+		"models":   *models,
+		"group":    *group,
+		"base_url": *baseURL,
+	})
+	if err != nil {
+		return err
+	}
+	return printJSON(data)
+}
+
+func listChannels(args []string) error {
+	flags, c := newFlagSet("channel list")
+	page := flags.Int("page", 0, "page number, starts from 0")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	data, err := c.do(http.MethodGet, "/api/channel/", url.Values{"p": {strconv.Itoa(*page)}}, nil)
+	if err != nil {
+		return err
+	}
+	return printJSON(data)
+}
+
+// testChannel exits with code 1 if the channel test fails, so that it can be used in CI
+func testChannel(args []string) error {
+	flags, c := newFlagSet("channel test")
+	id := flags.Int("id", 0, "channel id, test all channels in the background if 0")
+	model := flags.String("model", "", "model to test with, the first model of the channel if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	path := "/api/channel/test"
+	query := url.Values{}
+	if *id != 0 {
+		path += "/" + strconv.Itoa(*id)
+		if *model != "" {
+			query.Set("model", *model)
+		}
+	}
+	response, err := c.request(http.MethodGet, path, query, nil)
+	if err != nil {
+		return err
+	}
+	// the result of a single channel test is not wrapped in data
+	return printJSON(response.raw)
+}
+
+// usage prints the quota consumed in the given time range
+func usage(args []string) error {
+	flags, c := newFlagSet("usage")
+	since := flags.Duration("since", 24*time.Hour, "time range, e.g. 1h, 720h")
+	username := flags.String("username", "", "filter by username")
+	tokenName := flags.String("token-name", "", "filter by token name")
+	modelName := flags.String("model", "", "filter by model")
+	channelId := flags.Int("channel", 0, "filter by channel id")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	now := time.Now()
+	query := url.Values{
+		"type":            {"2"}, // consume logs
+		"start_timestamp": {strconv.FormatInt(now.Add(-*since).Unix(), 10)},
+		"end_timestamp":   {strconv.FormatInt(now.Unix(), 10)},
+		"username":        {*username},
+		"token_name":      {*tokenName},
+		"model_name":      {*modelName},
+		"channel":         {strconv.Itoa(*channelId)},
+	}
+	data, err := c.do(http.MethodGet, "/api/log/stat", query, nil)
+	if err != nil {
+		return err
+	}
+	return printJSON(data)
+}
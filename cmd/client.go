@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// client talks to a running One API server with the access token of an admin user,
+// so that it can be used from scripts and CI without a session cookie.
+type client struct {
+	server      string
+	accessToken string
+	http        *http.Client
+}
+
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	raw     []byte
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *client) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	c := &client{http: &http.Client{Timeout: 5 * time.Minute}}
+	flags.StringVar(&c.server, "server", envOr("ONE_API_SERVER", "http://localhost:3000"), "address of the One API server, or set ONE_API_SERVER")
+	flags.StringVar(&c.accessToken, "access-token", os.Getenv("ONE_API_ACCESS_TOKEN"), "access token of an admin user, or set ONE_API_ACCESS_TOKEN")
+	return flags, c
+}
+
+func envOr(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func (c *client) do(method string, path string, query url.Values, body any) (json.RawMessage, error) {
+	response, err := c.request(method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	return response.Data, nil
+}
+
+func (c *client) request(method string, path string, query url.Values, body any) (*apiResponse, error) {
+	if c.accessToken == "" {
+		return nil, errors.New("access token is required, use --access-token or ONE_API_ACCESS_TOKEN")
+	}
+	u := strings.TrimSuffix(c.server, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	response := apiResponse{raw: data}
+	if err = json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("bad response with status code %d: %w", resp.StatusCode, err)
+	}
+	if !response.Success {
+		return nil, errors.New(response.Message)
+	}
+	return &response, nil
+}
+
+// printJSON writes data as indented JSON to stdout, so that the output can be piped into jq
+func printJSON(data json.RawMessage) error {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err := out.WriteTo(os.Stdout)
+	return err
+}
+
+// subcommand dispatches args to one of actions, e.g. "token create --name ci"
+func subcommand(name string, actions map[string]func(args []string) error, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: one-api %s <%s>", name, strings.Join(keys(actions), "|"))
+	}
+	action, ok := actions[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown action %s, available: %s", args[0], strings.Join(keys(actions), ", "))
+	}
+	return action(args[1:])
+}
+
+func keys[T any](m map[string]T) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// the words the prompts are drawn from, a seed always gives the same prompts so that the runs can be compared
+var loadgenWords = strings.Fields("the gateway relays requests to many upstream models and bills each token " +
+	"used by the users of every group while the channels are balanced by their weights and priorities " +
+	"streams are forwarded event by event as soon as they arrive from the provider")
+
+type loadgenScenario struct {
+	path   string
+	stream bool
+	// body returns the payload of the i-th request, the same for a given seed
+	body func(cfg *loadgenConfig, random *rand.Rand) map[string]any
+}
+
+var loadgenScenarios = map[string]loadgenScenario{
+	"chat":       {path: "/v1/chat/completions", body: chatLoadgenBody},
+	"stream":     {path: "/v1/chat/completions", stream: true, body: chatLoadgenBody},
+	"vision":     {path: "/v1/chat/completions", stream: true, body: visionLoadgenBody},
+	"embeddings": {path: "/v1/embeddings", body: embeddingsLoadgenBody},
+}
+
+type loadgenConfig struct {
+	server      string
+	key         string
+	scenario    string
+	model       string
+	concurrency int
+	requests    int
+	duration    time.Duration
+	promptWords int
+	maxTokens   int
+	imageSize   int
+	seed        int64
+	timeout     time.Duration
+
+	imageOnce sync.Once
+	imageURL  string
+}
+
+type loadgenResult struct {
+	status    int
+	err       string
+	latency   time.Duration
+	firstByte time.Duration
+	usage     struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	}
+}
+
+type loadgenPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+type loadgenReport struct {
+	Scenario          string              `json:"scenario"`
+	Model             string              `json:"model"`
+	Seed              int64               `json:"seed"`
+	Concurrency       int                 `json:"concurrency"`
+	Requests          int                 `json:"requests"`
+	Succeeded         int                 `json:"succeeded"`
+	Failed            int                 `json:"failed"`
+	StatusCodes       map[string]int      `json:"status_codes"`
+	Errors            map[string]int      `json:"errors,omitempty"`
+	DurationSeconds   float64             `json:"duration_seconds"`
+	RequestsPerSecond float64             `json:"requests_per_second"`
+	LatencyMs         loadgenPercentiles  `json:"latency_ms"`
+	FirstByteMs       *loadgenPercentiles `json:"first_byte_ms,omitempty"`
+	PromptTokens      int                 `json:"prompt_tokens"`
+	CompletionTokens  int                 `json:"completion_tokens"`
+}
+
+func loadgenPrompt(cfg *loadgenConfig, random *rand.Rand) string {
+	words := make([]string, cfg.promptWords)
+	for i := range words {
+		words[i] = loadgenWords[random.Intn(len(loadgenWords))]
+	}
+	return strings.Join(words, " ")
+}
+
+func chatLoadgenBody(cfg *loadgenConfig, random *rand.Rand) map[string]any {
+	return map[string]any{
+		"model":      cfg.model,
+		"max_tokens": cfg.maxTokens,
+		"messages": []map[string]any{
+			{"role": "user", "content": loadgenPrompt(cfg, random)},
+		},
+	}
+}
+
+// loadgenImageURL is a noisy PNG, which does not compress, to measure the cost of the large bodies,
+// it is encoded once so that the load generator does not slow down the run
+func (cfg *loadgenConfig) loadgenImageURL() string {
+	cfg.imageOnce.Do(func() {
+		random := rand.New(rand.NewSource(cfg.seed))
+		img := image.NewRGBA(image.Rect(0, 0, cfg.imageSize, cfg.imageSize))
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = uint8(random.Intn(256)), uint8(random.Intn(256)), uint8(random.Intn(256)), 255
+		}
+		var buf bytes.Buffer
+		_ = png.Encode(&buf, img)
+		cfg.imageURL = "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+	})
+	return cfg.imageURL
+}
+
+func visionLoadgenBody(cfg *loadgenConfig, random *rand.Rand) map[string]any {
+	body := chatLoadgenBody(cfg, random)
+	body["messages"] = []map[string]any{
+		{"role": "user", "content": []map[string]any{
+			{"type": "text", "text": loadgenPrompt(cfg, random)},
+			{"type": "image_url", "image_url": map[string]any{"url": cfg.loadgenImageURL()}},
+		}},
+	}
+	return body
+}
+
+func embeddingsLoadgenBody(cfg *loadgenConfig, random *rand.Rand) map[string]any {
+	return map[string]any{
+		"model": cfg.model,
+		"input": loadgenPrompt(cfg, random),
+	}
+}
+
+func (cfg *loadgenConfig) send(client *http.Client, scenario loadgenScenario, i int) *loadgenResult {
+	result := &loadgenResult{}
+	body := scenario.body(cfg, rand.New(rand.NewSource(cfg.seed+int64(i))))
+	if scenario.stream {
+		body["stream"] = true
+		body["stream_options"] = map[string]any{"include_usage": true}
+	}
+	data, _ := json.Marshal(body)
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(cfg.server, "/")+scenario.path, bytes.NewReader(data))
+	if err != nil {
+		result.err = err.Error()
+		return result
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.key)
+	req.Header.Set("Content-Type", "application/json")
+	start := time.Now()
+	defer func() {
+		result.latency = time.Since(start)
+	}()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.err = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	result.status = resp.StatusCode
+	if !scenario.stream || resp.StatusCode != http.StatusOK {
+		data, err = io.ReadAll(resp.Body)
+		result.firstByte = time.Since(start)
+		if err != nil {
+			result.err = err.Error()
+			return result
+		}
+		var response struct {
+			Usage *json.RawMessage `json:"usage"`
+		}
+		if resp.StatusCode == http.StatusOK && json.Unmarshal(data, &response) == nil && response.Usage != nil {
+			_ = json.Unmarshal(*response.Usage, &result.usage)
+		}
+		return result
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	done := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		if result.firstByte == 0 {
+			result.firstByte = time.Since(start)
+		}
+		line = strings.TrimPrefix(line, "data: ")
+		if line == "[DONE]" {
+			done = true
+			continue
+		}
+		var chunk struct {
+			Usage *json.RawMessage `json:"usage"`
+		}
+		if err = json.Unmarshal([]byte(line), &chunk); err != nil {
+			result.err = "malformed event"
+			continue
+		}
+		if chunk.Usage != nil {
+			_ = json.Unmarshal(*chunk.Usage, &result.usage)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		result.err = err.Error()
+	} else if !done && result.err == "" {
+		result.err = "stream ended without [DONE]"
+	}
+	return result
+}
+
+func percentiles(values []time.Duration) loadgenPercentiles {
+	if len(values) == 0 {
+		return loadgenPercentiles{}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	at := func(p float64) float64 {
+		return float64(values[int(p*float64(len(values)-1))].Microseconds()) / 1000
+	}
+	return loadgenPercentiles{P50: at(0.5), P90: at(0.9), P99: at(0.99), Max: at(1)}
+}
+
+func (cfg *loadgenConfig) run() *loadgenReport {
+	scenario := loadgenScenarios[cfg.scenario]
+	client := &http.Client{Timeout: cfg.timeout, Transport: &http.Transport{MaxIdleConnsPerHost: cfg.concurrency}}
+	var next int64 = -1
+	var deadline time.Time
+	if cfg.duration > 0 {
+		deadline = time.Now().Add(cfg.duration)
+	}
+	results := make(chan *loadgenResult, cfg.concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < cfg.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if deadline.IsZero() {
+					if i >= cfg.requests {
+						return
+					}
+				} else if time.Now().After(deadline) {
+					return
+				}
+				results <- cfg.send(client, scenario, i)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	report := &loadgenReport{
+		Scenario:    cfg.scenario,
+		Model:       cfg.model,
+		Seed:        cfg.seed,
+		Concurrency: cfg.concurrency,
+		StatusCodes: map[string]int{},
+		Errors:      map[string]int{},
+	}
+	var latencies, firstBytes []time.Duration
+	for result := range results {
+		report.Requests++
+		report.StatusCodes[fmt.Sprint(result.status)]++
+		if result.err != "" || result.status != http.StatusOK {
+			report.Failed++
+			if result.err != "" {
+				report.Errors[result.err]++
+			}
+			continue
+		}
+		report.Succeeded++
+		report.PromptTokens += result.usage.PromptTokens
+		report.CompletionTokens += result.usage.CompletionTokens
+		latencies = append(latencies, result.latency)
+		firstBytes = append(firstBytes, result.firstByte)
+	}
+	elapsed := time.Since(start)
+	report.DurationSeconds = elapsed.Seconds()
+	report.RequestsPerSecond = float64(report.Requests) / elapsed.Seconds()
+	report.LatencyMs = percentiles(latencies)
+	if scenario.stream {
+		p := percentiles(firstBytes)
+		report.FirstByteMs = &p
+	}
+	return report
+}
+
+// loadgen sends the requests of a scenario to the relay and reports the throughput and the latencies,
+// with a sandbox token it measures the gateway alone
+func loadgen(args []string) error {
+	flags := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	cfg := &loadgenConfig{}
+	flags.StringVar(&cfg.server, "server", envOr("ONE_API_SERVER", "http://localhost:3000"), "address of the One API server, or set ONE_API_SERVER")
+	flags.StringVar(&cfg.key, "key", os.Getenv("ONE_API_KEY"), "token the requests are sent with, or set ONE_API_KEY")
+	flags.StringVar(&cfg.scenario, "scenario", "stream", "one of "+strings.Join(keys(loadgenScenarios), ", "))
+	flags.StringVar(&cfg.model, "model", "gpt-4o-mini", "model of the requests")
+	flags.IntVar(&cfg.concurrency, "concurrency", 8, "requests in flight")
+	flags.IntVar(&cfg.requests, "requests", 100, "requests to send, ignored if --duration is set")
+	flags.DurationVar(&cfg.duration, "duration", 0, "send requests for this duration, e.g. 1m")
+	flags.IntVar(&cfg.promptWords, "prompt-words", 50, "words of the prompts")
+	flags.IntVar(&cfg.maxTokens, "max-tokens", 64, "max_tokens of the chat requests")
+	flags.IntVar(&cfg.imageSize, "image-size", 512, "width and height in pixels of the images of the vision scenario")
+	flags.Int64Var(&cfg.seed, "seed", 1, "seed of the generated prompts, the same seed sends the same requests")
+	flags.DurationVar(&cfg.timeout, "timeout", time.Minute, "timeout of each request")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if cfg.key == "" {
+		return errors.New("token is required, use --key or ONE_API_KEY")
+	}
+	if _, ok := loadgenScenarios[cfg.scenario]; !ok {
+		return fmt.Errorf("unknown scenario %s, available: %s", cfg.scenario, strings.Join(keys(loadgenScenarios), ", "))
+	}
+	if cfg.concurrency <= 0 || cfg.promptWords <= 0 || cfg.imageSize <= 0 || cfg.duration == 0 && cfg.requests <= 0 {
+		return errors.New("--concurrency, --requests, --prompt-words and --image-size must be positive")
+	}
+	data, err := json.Marshal(cfg.run())
+	if err != nil {
+		return err
+	}
+	return printJSON(data)
+}
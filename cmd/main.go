@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+)
+
+var commands = map[string]func(args []string) error{
+	"migrate": migrate,
+	"restore": restore,
+	"token":   token,
+	"channel": channel,
+	"usage":   usage,
+	"loadgen": loadgen,
+}
+
+// Run executes the subcommand given in args and reports whether there was one,
+// in which case the caller should exit instead of starting the server.
+func Run(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", args[0])
+		os.Exit(2)
+	}
+	if err := command(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %s\n", args[0], err.Error())
+		os.Exit(1)
+	}
+	return true
+}
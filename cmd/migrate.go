@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// migrate without arguments applies the pending migrations to SQL_DSN (and LOG_SQL_DSN), with --down
+// it rolls SQL_DSN back to the version, with --status it lists the applied migrations;
+// with --to it copies all data from --from (SQLite at SQLITE_PATH if empty) to the target database.
+func migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	from := flags.String("from", "", "source database DSN, leave empty to use SQLite")
+	to := flags.String("to", "", "target database DSN, must be an empty database")
+	down := flags.Int("down", -1, "roll the schema back to this version")
+	status := flags.Bool("status", false, "list the applied migrations")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *status {
+		return migrationStatus()
+	}
+	if *down >= 0 {
+		return rollbackSchema(*down)
+	}
+	if *to == "" {
+		return migrateSchema()
+	}
+	if *from == *to {
+		return errors.New("source and target database are the same")
+	}
+	src, err := model.OpenDB(*from)
+	if err != nil {
+		return err
+	}
+	dst, err := model.OpenDB(*to)
+	if err != nil {
+		return err
+	}
+	dstIsPostgreSQL := strings.HasPrefix(*to, "postgres://") || strings.HasPrefix(*to, "postgresql://")
+	logger.SysLog("data migration started")
+	if err = model.MigrateData(src, dst, dstIsPostgreSQL); err != nil {
+		return err
+	}
+	logger.SysLog("data migration finished")
+	return nil
+}
+
+// openDB opens SQL_DSN (and LOG_SQL_DSN) as the databases of the model
+func openDB() error {
+	db, err := model.OpenDB(os.Getenv("SQL_DSN"))
+	if err != nil {
+		return err
+	}
+	model.DB = db
+	model.LOG_DB = db
+	if os.Getenv("LOG_SQL_DSN") != "" {
+		model.LOG_DB, err = model.OpenDB(os.Getenv("LOG_SQL_DSN"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateSchema() error {
+	if err := openDB(); err != nil {
+		return err
+	}
+	defer model.CloseDB()
+	logger.SysLog("database migration started")
+	if err := model.MigrateDB(); err != nil {
+		return err
+	}
+	logger.SysLog("database migrated")
+	return nil
+}
+
+func rollbackSchema(version int) error {
+	if err := openDB(); err != nil {
+		return err
+	}
+	defer model.CloseDB()
+	logger.SysLogf("rolling the database back to schema version %d", version)
+	return model.RollbackDB(version)
+}
+
+func migrationStatus() error {
+	if err := openDB(); err != nil {
+		return err
+	}
+	defer model.CloseDB()
+	applied, err := model.GetSchemaMigrations()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(applied)
+	if err != nil {
+		return err
+	}
+	return printJSON(data)
+}
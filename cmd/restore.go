@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+
+	httpclient "github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// restore replaces the options, channels, tokens and users of SQL_DSN with the ones of a backup,
+// read from a file on disk or by its name from BACKUP_STORAGE
+func restore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	file := flags.String("file", "", "path of the backup file")
+	name := flags.String("name", "", "name of the backup in BACKUP_STORAGE")
+	password := flags.String("password", config.BackupPassword, "password of the backup, or set BACKUP_PASSWORD")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if (*file == "") == (*name == "") {
+		return errors.New("either --file or --name is required")
+	}
+	var data []byte
+	var err error
+	if *file != "" {
+		data, err = os.ReadFile(*file)
+	} else {
+		httpclient.Init()
+		data, err = model.ReadBackup(context.Background(), config.BackupStorage, *name)
+	}
+	if err != nil {
+		return err
+	}
+	if err = openDB(); err != nil {
+		return err
+	}
+	defer model.CloseDB()
+	if err = model.MigrateDB(); err != nil {
+		return err
+	}
+	logger.SysLog("restore started")
+	if err = model.RestoreBackup(data, *password); err != nil {
+		return err
+	}
+	logger.SysLog("restore finished")
+	return nil
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	NetworkDual = "dual" // both IPv4 and IPv6, the addresses of the other family are tried after the fallback delay
+	NetworkIPv4 = "ipv4"
+	NetworkIPv6 = "ipv6"
+)
+
+// DialOptions choose how the connections to an upstream are dialed
+type DialOptions struct {
+	// Network is dual, ipv4 or ipv6, dual if empty
+	Network string
+	// Hosts resolve the host names to the addresses given instead of through DNS, like /etc/hosts, the addresses
+	// are tried in their order
+	Hosts map[string][]string
+	// FallbackDelay is how long the first family is tried before the other one is tried too, which is the happy
+	// eyeballs of RFC 6555, the default of net.Dialer if 0, negative to try them one after the other
+	FallbackDelay time.Duration
+}
+
+func (o DialOptions) IsDefault() bool {
+	return (o.Network == "" || o.Network == NetworkDual) && len(o.Hosts) == 0 && o.FallbackDelay == 0
+}
+
+func (o DialOptions) key() string {
+	hosts := make([]string, 0, len(o.Hosts))
+	for host, addresses := range o.Hosts {
+		hosts = append(hosts, strings.ToLower(host)+"="+strings.Join(addresses, ","))
+	}
+	sort.Strings(hosts)
+	return fmt.Sprintf("%s|%s|%d", o.Network, strings.Join(hosts, ";"), o.FallbackDelay)
+}
+
+func (o DialOptions) tcpNetwork() string {
+	switch o.Network {
+	case NetworkIPv4:
+		return "tcp4"
+	case NetworkIPv6:
+		return "tcp6"
+	}
+	return "tcp"
+}
+
+// addresses returns the addresses set for the host of the family dialed, nil if the host is resolved through DNS
+func (o DialOptions) addresses(host string) ([]string, error) {
+	var entries []string
+	for name, addresses := range o.Hosts {
+		if strings.EqualFold(name, host) {
+			entries = addresses
+			break
+		}
+	}
+	if entries == nil {
+		return nil, nil
+	}
+	var addresses []string
+	for _, entry := range entries {
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			continue
+		}
+		isIPv4 := ip.To4() != nil
+		if (o.Network == NetworkIPv4 && !isIPv4) || (o.Network == NetworkIPv6 && isIPv4) {
+			continue
+		}
+		addresses = append(addresses, entry)
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no %s address is set for %s", o.tcpNetwork(), host)
+	}
+	return addresses, nil
+}
+
+func (o DialOptions) dialContext(dialer *net.Dialer) func(ctx context.Context, network string, address string) (net.Conn, error) {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		if network == "tcp" {
+			network = o.tcpNetwork()
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return dialer.DialContext(ctx, network, address)
+		}
+		addresses, err := o.addresses(host)
+		if err != nil {
+			return nil, err
+		}
+		if addresses == nil {
+			return dialer.DialContext(ctx, network, address)
+		}
+		var errs []error
+		for _, ip := range addresses {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+}
+
+// ValidateDialOptions checks the network and the addresses of the hosts
+func ValidateDialOptions(o DialOptions) error {
+	switch o.Network {
+	case "", NetworkDual, NetworkIPv4, NetworkIPv6:
+	default:
+		return fmt.Errorf("network must be one of dual, ipv4 and ipv6, got %q", o.Network)
+	}
+	for host, addresses := range o.Hosts {
+		if host == "" || len(addresses) == 0 {
+			return errors.New("hosts must map a host name to its addresses")
+		}
+		for _, address := range addresses {
+			if net.ParseIP(address) == nil {
+				return fmt.Errorf("%q of %s is not an IP address", address, host)
+			}
+		}
+	}
+	return nil
+}
+
+var dialClients = struct {
+	sync.Mutex
+	clients map[*http.Client]map[string]*http.Client
+}{clients: make(map[*http.Client]map[string]*http.Client)}
+
+// WithDial returns the client dialing its connections by the options, along with its proxy and its timeout, the
+// clients are kept for the options, so that their connections are reused
+func WithDial(base *http.Client, options DialOptions) *http.Client {
+	if options.IsDefault() {
+		return base
+	}
+	key := options.key()
+	dialClients.Lock()
+	defer dialClients.Unlock()
+	clients, ok := dialClients.clients[base]
+	if !ok {
+		clients = make(map[string]*http.Client)
+		dialClients.clients[base] = clients
+	}
+	if httpClient, ok := clients[key]; ok {
+		return httpClient
+	}
+	var transport *http.Transport
+	if baseTransport, ok := base.Transport.(*http.Transport); ok {
+		transport = baseTransport.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	dialer := &net.Dialer{
+		Timeout:       30 * time.Second,
+		KeepAlive:     30 * time.Second,
+		FallbackDelay: options.FallbackDelay,
+	}
+	transport.DialContext = options.dialContext(dialer)
+	httpClient := &http.Client{
+		Transport: transport,
+		Timeout:   base.Timeout,
+	}
+	clients[key] = httpClient
+	return httpClient
+}
+
+// resetDialClients drops the clients kept for the clients replaced by Init
+func resetDialClients() {
+	dialClients.Lock()
+	defer dialClients.Unlock()
+	for _, clients := range dialClients.clients {
+		for _, httpClient := range clients {
+			httpClient.CloseIdleConnections()
+		}
+	}
+	dialClients.clients = make(map[*http.Client]map[string]*http.Client)
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWithDial(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	upstream := "http://upstream.invalid:" + serverURL.Port()
+	base := &http.Client{}
+
+	Convey("WithDial", t, func() {
+		So(WithDial(base, DialOptions{Network: NetworkDual}), ShouldEqual, base)
+		options := DialOptions{Hosts: map[string][]string{"Upstream.invalid": {"127.0.0.1"}}}
+		So(WithDial(base, options), ShouldEqual, WithDial(base, options))
+
+		resp, err := WithDial(base, options).Get(upstream)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+		options.Network = NetworkIPv6
+		_, err = WithDial(base, options).Get(upstream)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "no tcp6 address is set for upstream.invalid")
+
+		options.Network = NetworkIPv4
+		resp, err = WithDial(base, options).Get(server.URL)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+	})
+
+	Convey("ValidateDialOptions", t, func() {
+		So(ValidateDialOptions(DialOptions{Network: NetworkIPv4, Hosts: map[string][]string{"a.example": {"::1", "10.0.0.1"}}}), ShouldBeNil)
+		So(ValidateDialOptions(DialOptions{Network: "tcp4"}), ShouldNotBeNil)
+		So(ValidateDialOptions(DialOptions{Hosts: map[string][]string{"a.example": {"a.example"}}}), ShouldNotBeNil)
+		So(ValidateDialOptions(DialOptions{Hosts: map[string][]string{"a.example": nil}}), ShouldNotBeNil)
+	})
+}
@@ -10,10 +10,14 @@ import (
 )
 
 var HTTPClient *http.Client
+
+// UntimedHTTPClient is HTTPClient without RELAY_TIMEOUT, for the requests given a deadline of their own
+var UntimedHTTPClient *http.Client
 var ImpatientHTTPClient *http.Client
 var UserContentRequestHTTPClient *http.Client
 
 func Init() {
+	resetDialClients()
 	if config.UserContentRequestProxy != "" {
 		logger.SysLog(fmt.Sprintf("using %s as proxy to fetch user content", config.UserContentRequestProxy))
 		proxyURL, err := url.Parse(config.UserContentRequestProxy)
@@ -53,6 +57,10 @@ func Init() {
 		}
 	}
 
+	UntimedHTTPClient = &http.Client{
+		Transport: transport,
+	}
+
 	ImpatientHTTPClient = &http.Client{
 		Timeout:   5 * time.Second,
 		Transport: transport,
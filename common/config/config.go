@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,10 +17,15 @@ var SystemName = "One API"
 var ServerAddress = "http://localhost:3000"
 var Footer = ""
 var Logo = ""
+var Favicon = "" // the icon of the web console, the one of the theme if empty
 var TopUpLink = ""
 var ChatLink = ""
 var QuotaPerUnit = 500 * 1000.0 // $0.002 / 1K tokens
 var DisplayInCurrencyEnabled = true
+
+// BaseCurrency is the currency of QuotaPerUnit, the quota is displayed in it or converted by the exchange rates
+// to the currency chosen by the user
+var BaseCurrency = "USD"
 var DisplayTokenStatEnabled = true
 
 // Any options with "Secret", "Token" in its key won't be return by GetOptions
@@ -37,10 +43,19 @@ var PasswordRegisterEnabled = true
 var EmailVerificationEnabled = false
 var GitHubOAuthEnabled = false
 var OidcEnabled = false
+var SAMLEnabled = false
 var WeChatAuthEnabled = false
 var TurnstileCheckEnabled = false
 var RegisterEnabled = true
 
+// TwoFactorEnforcedRoles are the roles separated by commas whose users must enable the two-factor authentication
+var TwoFactorEnforcedRoles = ""
+
+// the origins allowed to call the relay API and the management API from a browser, separated by commas,
+// see common/network/origin.go; the management API allows none but the console itself by default
+var RelayCORSAllowedOrigins = "*"
+var AdminCORSAllowedOrigins = ""
+
 var EmailDomainRestrictionEnabled = false
 var EmailDomainWhitelist = []string{
 	"gmail.com",
@@ -59,6 +74,31 @@ var DebugSQLEnabled = strings.ToLower(os.Getenv("DEBUG_SQL")) == "true"
 var MemoryCacheEnabled = strings.ToLower(os.Getenv("MEMORY_CACHE_ENABLED")) == "true"
 
 var LogConsumeEnabled = true
+var LogRetentionDays = env.Int("LOG_RETENTION_DAYS", 0)      // 0 means logs are kept forever
+var TrashRetentionDays = env.Int("TRASH_RETENTION_DAYS", 30) // 0 means the trash is never purged
+var LogRequestBodyEnabled = env.Bool("LOG_REQUEST_BODY_ENABLED", false)
+var LogRequestBodyMaxSize = env.Int("LOG_REQUEST_BODY_MAX_SIZE", 64) // KB, larger bodies are not recorded
+
+var ReconcileDir = env.String("RECONCILE_DIR", "")
+var ReconcileFrequency = env.Int("RECONCILE_FREQUENCY", 30) // seconds
+var ReconcilePrune = env.Bool("RECONCILE_PRUNE", false)
+
+// DryRunEnabled allows common users to preview the upstream request with the X-OneAPI-Dry-Run header
+var DryRunEnabled = env.Bool("DRY_RUN_ENABLED", false)
+
+// QuotaFallbackModel is used instead of failing when a token is exhausted, usually a free model
+var QuotaFallbackModel = env.String("QUOTA_FALLBACK_MODEL", "")
+
+// IdempotencyKeyTTL is how long the responses of the requests with an Idempotency-Key header are kept, in seconds
+var IdempotencyKeyTTL = env.Int("IDEMPOTENCY_KEY_TTL", 86400)
+
+// RequestDedupWindow is how long the byte-identical requests of a token are coalesced onto the first of them, in
+// seconds, 0 disables it
+var RequestDedupWindow = 0
+
+// ConversationMaxMessages is the most messages of a conversation replayed into a chat completion,
+// the conversations may set a lower limit
+var ConversationMaxMessages = env.Int("CONVERSATION_MAX_MESSAGES", 100)
 
 var SMTPServer = ""
 var SMTPPort = 587
@@ -79,6 +119,17 @@ var OidcAuthorizationEndpoint = ""
 var OidcTokenEndpoint = ""
 var OidcUserinfoEndpoint = ""
 
+// the gateway is the SAML service provider of the IdP, the attributes of the assertions are mapped to the users,
+// SAMLGroupMapping is a JSON object mapping the values of the group attribute to the groups
+var SAMLIdPEntityId = ""
+var SAMLIdPSSOURL = ""
+var SAMLIdPCertificate = ""
+var SAMLUsernameAttribute = ""
+var SAMLEmailAttribute = ""
+var SAMLDisplayNameAttribute = ""
+var SAMLGroupAttribute = ""
+var SAMLGroupMapping = ""
+
 var WeChatServerAddress = ""
 var WeChatServerToken = ""
 var WeChatAccountQRCodeImageURL = ""
@@ -86,16 +137,142 @@ var WeChatAccountQRCodeImageURL = ""
 var MessagePusherAddress = ""
 var MessagePusherToken = ""
 
+// the bots push the alerts to the bound chats and answer the commands of the admins
+var TelegramBotToken = ""
+var TelegramWebhookSecretToken = ""
+var LarkBotAppId = ""
+var LarkBotAppSecret = ""
+var LarkBotVerificationToken = ""
+var DingTalkBotAppKey = ""
+var DingTalkBotAppSecret = ""
+
+// BotChatEnabled lets all the users bind a chat and talk to BotChatModel in it, billed to their quota
+var BotChatEnabled = false
+var BotChatModel = "gpt-4o-mini"
+
 var TurnstileSiteKey = ""
 var TurnstileSecretKey = ""
 
 var QuotaForNewUser int64 = 0
+
+// NewUserQuotaEmailRequiredEnabled holds the quota for new user until the email is bound
+var NewUserQuotaEmailRequiredEnabled = false
 var QuotaForInviter int64 = 0
 var QuotaForInvitee int64 = 0
+
+// InviterTopUpRewardRate is the share of the invitee top-ups given to the inviter, e.g. 0.1 for 10%
+var InviterTopUpRewardRate = 0.0
+
+// InviteRewardDedupeEnabled gives no invite reward to the users registered from a used ip or device
+var InviteRewardDedupeEnabled = false
+
+// QuotaTransferEnabled lets the users transfer their quota to each other, the sender pays
+// QuotaTransferFeeRate of the transferred quota as the fee, the limits are ignored when 0
+var QuotaTransferEnabled = false
+var QuotaTransferMinQuota int64 = 0
+var QuotaTransferMaxQuota int64 = 0
+var QuotaTransferDailyQuota int64 = 0
+var QuotaTransferFeeRate = 0.0
+
+// FineTuningModel decides the channel of the uploaded files which have no model given,
+// the fine-tuning jobs are sent to the channel of their training file
+var FineTuningModel = "gpt-4o-mini-2024-07-18"
+
+// FileMaxSize and FileUserStorageLimit are in MB, the limit is of the total size of the stored files of a user,
+// the stored files are deleted FileRetentionDays days after they are uploaded unless the upload asks otherwise
+var FileMaxSize int64 = 512
+var FileUserStorageLimit int64 = 0
+var FileRetentionDays = 0
+
+// the files added to the vector stores are split into chunks of VectorStoreChunkSize characters, each one repeating
+// the last VectorStoreChunkOverlap characters of the previous one, and embedded with VectorStoreEmbeddingModel
+var VectorStoreEmbeddingModel = "text-embedding-3-small"
+var VectorStoreChunkSize = 2000
+var VectorStoreChunkOverlap = 400
+
+// RagPromptTemplate is the prompt of /v1/rag/query, {{context}} is replaced by the numbered chunks retrieved
+// and {{question}} by the question
+var RagPromptTemplate = "Answer the question with the sources below, cite the sources you use by their numbers like [1]. " +
+	"If the sources do not contain the answer, say that you do not know.\n\nSources:\n{{context}}\n\nQuestion: {{question}}"
+
+// JudgeModel scores the answers sent to /v1/evaluations with JudgePrompt, in which {{question}}, {{answer}}
+// and {{reference}} are replaced, the judge replies a JSON object of the score from 1 to 10 and the reason
+var JudgeModel = ""
+var JudgePrompt = "You are an impartial judge of the quality of answers. Rate the answer to the question below " +
+	"for correctness, helpfulness and clarity on a scale from 1 to 10, compare it with the reference answer if there is one.\n\n" +
+	"Question:\n{{question}}\n\nReference answer:\n{{reference}}\n\nAnswer:\n{{answer}}\n\n" +
+	"Reply only with a JSON object like {\"score\": 7, \"reason\": \"...\"}."
+
+// CouncilModels are the models /v1/council asks when the request does not list its own, separated by commas, the
+// judge model picks the best answer with CouncilJudgePrompt, in which {{question}} and {{answers}} are replaced
+var CouncilModels = ""
+var CouncilJudgePrompt = "You are an impartial judge of the quality of answers. Several assistants answered the conversation below, " +
+	"choose the best answer for correctness, helpfulness and clarity.\n\nConversation:\n{{question}}\n\nAnswers:\n{{answers}}\n\n" +
+	"Reply only with a JSON object like {\"best\": 2, \"reason\": \"...\"}, where best is the number of the best answer."
+
+// LongContextSummaryModel condenses the chat completions longer than the context window of their model, when the
+// request asks for it, with LongContextSummaryPrompt, in which {{content}} is replaced by a part of the conversation
+var LongContextSummaryModel = ""
+var LongContextSummaryPrompt = "Summarize the following part of a conversation as concisely as possible. Keep every fact, " +
+	"name, number, date, decision, instruction and piece of code that may matter later, and keep any question or task " +
+	"of the user word for word. Reply only with the summary, in the language of the conversation.\n\n{{content}}"
+
+// WebSearchProvider serves the web_search tool for the channels which do not support it, bing, searxng or tavily,
+// WebSearchURL replaces the default endpoint of the provider and is required by searxng
+var WebSearchProvider = ""
+var WebSearchURL = ""
+var WebSearchToken = ""
+var WebSearchMaxResults = 5
+
+// SentryDSN enables the reporting of the relay panics and the upstream error spikes to Sentry or GlitchTip,
+// an upstream error spike is SentryErrorSpikeThreshold errors of a channel in a minute, 0 not to report them
+var SentryDSN = env.String("SENTRY_DSN", "")
+var SentryEnvironment = env.String("SENTRY_ENVIRONMENT", "production")
+var SentryErrorSpikeThreshold = 20
+
+// ExchangeRateURL is fetched every ExchangeRateSyncFrequency minutes by the leader to update the exchange rates,
+// {base} is replaced by the base currency
+var ExchangeRateURL = env.String("EXCHANGE_RATE_URL", "")
+var ExchangeRateSyncFrequency = env.Int("EXCHANGE_RATE_SYNC_FREQUENCY", 360)
 var ChannelDisableThreshold = 5.0
 var AutomaticDisableChannelEnabled = false
 var AutomaticEnableChannelEnabled = false
+
+// ResponseValidationEnabled checks the chat completions the channels answer with, a channel is disabled when
+// MalformedResponseThreshold of its last MalformedResponseWindow checked responses are malformed, 0 not to disable it
+var ResponseValidationEnabled = false
+var MalformedResponseThreshold = 0.2
+var MalformedResponseWindow = 20
+
+// StatusPageEnabled shows the model status page to everyone, otherwise only the admins can see it
+var StatusPageEnabled = false
+
+// StatusPageModels are the models on the status page, comma separated, empty means all the enabled models
+var StatusPageModels = ""
+
+// StatusPageWindowHours is how many hours of the channel health checks the status page covers
+var StatusPageWindowHours = 24
 var QuotaRemindThreshold int64 = 1000
+
+// TokenExpiryRemindDays is how many days before the expiry the token owner is reminded, 0 disables it
+var TokenExpiryRemindDays = 3
+var MonthlyStatementEnabled = false
+
+// TokenAnomalyDetectionEnabled checks the usage of the tokens every hour, the usage of the last day or of a
+// night hour more than TokenAnomalySpikeFactor times the usual one and the models never used before are reported
+var TokenAnomalyDetectionEnabled = false
+var TokenAnomalySpikeFactor = 10
+
+// TokenAnomalyNightHours are the hours of the night of the server, such as 0-6, empty disables the check
+var TokenAnomalyNightHours = "0-6"
+
+// MonthlyStatementSentMonth is the last month whose statements have been sent, e.g. 2024-01
+var MonthlyStatementSentMonth = ""
+
+// DailyDigestSentDay and WeeklyDigestSentWeek are the last day and the first day of the last week whose usage
+// digests have been sent, e.g. 2024-01-01
+var DailyDigestSentDay = ""
+var WeeklyDigestSentWeek = ""
 var PreConsumedQuota int64 = 500
 var ApproximateTokenEnabled = false
 var RetryTimes = 0
@@ -104,25 +281,98 @@ var RootUserEmail = ""
 
 var IsMasterNode = os.Getenv("NODE_TYPE") != "slave"
 
+// AutoMigrateEnabled lets the master node apply the pending migrations on start, otherwise it
+// refuses to start like the slave nodes until they are applied with the migrate command
+var AutoMigrateEnabled = env.Bool("AUTO_MIGRATE_ENABLED", true)
+
+// NodeId identifies this replica among all the nodes sharing the same database and Redis
+var NodeId = env.String("NODE_ID", uuid.New().String())
+
+// LeaderElectionEnabled lets the nodes elect the one running scheduled jobs instead of relying on NODE_TYPE
+var LeaderElectionEnabled = env.Bool("LEADER_ELECTION_ENABLED", false)
+
 var requestInterval, _ = strconv.Atoi(os.Getenv("POLLING_INTERVAL"))
 var RequestInterval = time.Duration(requestInterval) * time.Second
 
 var SyncFrequency = env.Int("SYNC_FREQUENCY", 10*60) // unit is second
 
+var SQLMaxIdleConns = env.Int("SQL_MAX_IDLE_CONNS", 100)
+var SQLMaxOpenConns = env.Int("SQL_MAX_OPEN_CONNS", 1000)
+
+// SQL_CONN_MAX_LIFETIME is in minutes, SQL_MAX_LIFETIME (in seconds) is kept for backward compatibility
+var SQLConnMaxLifetime = time.Duration(env.Int("SQL_MAX_LIFETIME", env.Int("SQL_CONN_MAX_LIFETIME", 1)*60)) * time.Second
+var SQLConnMaxIdleTime = time.Duration(env.Int("SQL_CONN_MAX_IDLE_TIME", 0)) * time.Minute // 0 means no limit
+
 var BatchUpdateEnabled = false
 var BatchUpdateInterval = env.Int("BATCH_UPDATE_INTERVAL", 5)
-
-var RelayTimeout = env.Int("RELAY_TIMEOUT", 0) // unit is second
+var BatchUpdateJournal = env.String("BATCH_UPDATE_JOURNAL", "") // file path, pending updates are lost on crash if not set
+
+var RelayTimeout = env.Int("RELAY_TIMEOUT", 0)                           // unit is second
+var ShutdownTimeout = env.Int("SHUTDOWN_TIMEOUT", 30)                    // unit is second, how long to wait for in-flight requests on exit
+var StreamKeepAliveInterval = env.Int("STREAM_KEEP_ALIVE_INTERVAL", 15)  // unit is second, 0 to disable the keep-alive comments of idle streams
+var ChannelRateLimitMaxWait = env.Int("CHANNEL_RATE_LIMIT_MAX_WAIT", 30) // unit is second, how long a request may be queued for the rpm and tpm of a channel
+
+// RelayCompressionMinSize is the size in bytes from which the non-streaming JSON responses of the relay API are
+// gzipped for the clients accepting it, 0 to disable
+var RelayCompressionMinSize = env.Int("RELAY_COMPRESSION_MIN_SIZE", 1024)
+
+var GRPCPort = env.String("GRPC_PORT", "") // gRPC management API is disabled if empty
+
+// ListenAddress is the address of the public listener, such as 0.0.0.0:3000, unix:/run/one-api.sock or systemd
+// for socket activation, it is ":" + PORT if empty. ADMIN_LISTEN and ACME_HTTP_LISTEN accept the same forms
+var ListenAddress = env.String("LISTEN_ADDRESS", "")
+var TLSCertFile = env.String("TLS_CERT_FILE", "") // the public listener serves https if set, along with TLS_KEY_FILE
+var TLSKeyFile = env.String("TLS_KEY_FILE", "")
+var UnixSocketMode = env.String("UNIX_SOCKET_MODE", "0660") // the octal permission of the unix sockets created
+
+// ACMEDomains are the comma separated domains whose certificates are obtained and renewed automatically through
+// ACME (such as Let's Encrypt) for the public listener, instead of TLS_CERT_FILE
+var ACMEDomains = env.String("ACME_DOMAINS", "")
+var ACMEEmail = env.String("ACME_EMAIL", "")                // the contact of the ACME account, optional
+var ACMECachePath = env.String("ACME_CACHE_PATH", "acme")   // the directory of the account key and certificates
+var ACMEDirectoryURL = env.String("ACME_DIRECTORY_URL", "") // Let's Encrypt if empty
+var ACMEHTTPListen = env.String("ACME_HTTP_LISTEN", "")     // such as :80, answers HTTP-01 challenges and redirects to https
+
+// AdminListen is the address of the internal listener, such as 127.0.0.1:3001, which serves the management API
+// even if it is not served on PORT, disabled if empty
+var AdminListen = env.String("ADMIN_LISTEN", "")
+var PublicAdminAPIEnabled = env.Bool("PUBLIC_ADMIN_API_ENABLED", true) // false to serve the relay API only on PORT
+var AdminTLSCertFile = env.String("ADMIN_TLS_CERT_FILE", "")
+var AdminTLSKeyFile = env.String("ADMIN_TLS_KEY_FILE", "")
+
+// AdminTLSClientCAFile requires the clients of ADMIN_LISTEN to present a certificate signed by the CAs in the file
+var AdminTLSClientCAFile = env.String("ADMIN_TLS_CLIENT_CA_FILE", "")
+
+// BasePath is the path prefix such as /one-api under which the relay API and the console are served, for the
+// deployments sharing a domain behind a reverse proxy, served at the root if empty
+var BasePath = strings.TrimSuffix(env.String("BASE_PATH", ""), "/")
+
+var BillingWorkerNum = env.Int("BILLING_WORKER_NUM", 8)
+var BillingQueueSize = env.Int("BILLING_QUEUE_SIZE", 1024)
+var BillingQueueOverflowPolicy = env.String("BILLING_QUEUE_OVERFLOW_POLICY", "spawn")
 
 var GeminiSafetySetting = env.String("GEMINI_SAFETY_SETTING", "BLOCK_NONE")
 
 var Theme = env.String("THEME", "default")
+var ThemePath = env.String("THEME_PATH", "themes") // the directory of the theme packages
 var ValidThemes = map[string]bool{
 	"default": true,
 	"berry":   true,
 	"air":     true,
 }
 
+// ThemeExists reports whether the theme is an embedded one or a theme package in ThemePath
+func ThemeExists(name string) bool {
+	if ValidThemes[name] {
+		return true
+	}
+	if name == "" || strings.ContainsAny(name, `/\.`) {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(ThemePath, name, "index.html"))
+	return err == nil && !info.IsDir()
+}
+
 // All duration's unit is seconds
 // Shouldn't larger then RateLimitKeyExpirationDuration
 var (
@@ -162,5 +412,65 @@ var RelayProxy = env.String("RELAY_PROXY", "")
 var UserContentRequestProxy = env.String("USER_CONTENT_REQUEST_PROXY", "")
 var UserContentRequestTimeout = env.Int("USER_CONTENT_REQUEST_TIMEOUT", 30)
 
+// S3 compatible object storage for generated images
+var ObjectStorageEndpoint = env.String("OBJECT_STORAGE_ENDPOINT", "")
+var ObjectStorageRegion = env.String("OBJECT_STORAGE_REGION", "us-east-1")
+var ObjectStorageBucket = env.String("OBJECT_STORAGE_BUCKET", "")
+var ObjectStorageAccessKey = env.String("OBJECT_STORAGE_ACCESS_KEY", "")
+var ObjectStorageSecretKey = env.String("OBJECT_STORAGE_SECRET_KEY", "")
+var ObjectStoragePrefix = env.String("OBJECT_STORAGE_PREFIX", "one-api/")
+var ObjectStoragePublicURL = env.String("OBJECT_STORAGE_PUBLIC_URL", "")
+var ObjectStorageURLExpiration = env.Int("OBJECT_STORAGE_URL_EXPIRATION", 24*60*60) // unit is second
+
+// FileStorage is where the files uploaded through /v1/files are kept, local or s3 (the object storage above),
+// when it is empty the files are relayed to the upstream of a channel instead
+var FileStorage = env.String("FILE_STORAGE", "")
+var FileStoragePath = env.String("FILE_STORAGE_PATH", "files")
+
+// the backups are encrypted with BackupPassword and kept in BackupStorage, local (at BackupPath) or s3
+var BackupPassword = env.String("BACKUP_PASSWORD", "")
+var BackupStorage = env.String("BACKUP_STORAGE", "local")
+var BackupPath = env.String("BACKUP_PATH", "backups")
+var BackupFrequency = env.Int("BACKUP_FREQUENCY", 0) // minutes, 0 means no scheduled backups
+var BackupRetention = env.Int("BACKUP_RETENTION", 7) // the number of backups kept, 0 means all
+
+// VectorStore is where the embeddings of the vector stores are searched, database, pgvector or qdrant,
+// when it is empty the vector stores and the file_search tool are disabled
+var VectorStore = env.String("VECTOR_STORE", "")
+var QdrantURL = env.String("QDRANT_URL", "http://localhost:6333")
+var QdrantAPIKey = env.String("QDRANT_API_KEY", "")
+var QdrantCollection = env.String("QDRANT_COLLECTION", "one-api")
+
+var PluginHookURLs = env.String("PLUGIN_HOOK_URLS", "")   // comma separated
+var PluginHookTimeout = env.Int("PLUGIN_HOOK_TIMEOUT", 5) // unit is second
+
+var WasmFilterEnabled = env.Bool("WASM_FILTER_ENABLED", false)
+var WasmFilterTimeout = env.Int("WASM_FILTER_TIMEOUT", 100)         // unit is millisecond
+var WasmFilterMemoryLimit = env.Int("WASM_FILTER_MEMORY_LIMIT", 16) // unit is MB
+var WasmFilterMaxSize = env.Int("WASM_FILTER_MAX_SIZE", 4)          // unit is MB
+
 var EnforceIncludeUsage = env.Bool("ENFORCE_INCLUDE_USAGE", false)
+
+// AsyncTaskConcurrency is how many async tasks the leader executes at the same time, 0 disables the async mode
+var AsyncTaskConcurrency = env.Int("ASYNC_TASK_CONCURRENCY", 2)
+
+// DeferredCompletionTTL is how long the results of the deferred chat completions are kept to be polled, unit is second
+var DeferredCompletionTTL = env.Int("DEFERRED_COMPLETION_TTL", 24*3600)
+
+// StartupCheckPolicy is what the startup check does when it finds a problem: off skips the check, degraded serves with
+// a banner on the console, and fail refuses to serve
+var StartupCheckPolicy = env.String("STARTUP_CHECK_POLICY", "degraded")
+
+// StartupCheckModels are the critical models which must have an enabled channel in each of StartupCheckGroups,
+// separated by commas, the groups only need a model if empty
+var StartupCheckModels = env.String("STARTUP_CHECK_MODELS", "")
+var StartupCheckGroups = env.String("STARTUP_CHECK_GROUPS", "default") // comma separated
+
+// StreamSalvageEnabled continues the streams dropped by the upstream on another channel, with the generated part as the prefix
+var StreamSalvageEnabled = env.Bool("STREAM_SALVAGE_ENABLED", false)
+var StreamSalvagePrompt = env.String("STREAM_SALVAGE_PROMPT", "Continue exactly from where you stopped, without repeating what you have already said.")
+
+// ResponseMetadataChannelVisible shows the names of the channels in the response metadata instead of masking them
+var ResponseMetadataChannelVisible = env.Bool("RESPONSE_METADATA_CHANNEL_VISIBLE", false)
+
 var TestPrompt = env.String("TEST_PROMPT", "Output only your specific model name with no additional text.")
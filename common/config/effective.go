@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/env"
+)
+
+// keys which are read with os.Getenv directly instead of the env helpers
+var directKeys = []string{
+	"BATCH_UPDATE_ENABLED", "CHANNEL_SCAN_FREQUENCY", "CHANNEL_TEST_FREQUENCY", "CHANNEL_UPDATE_FREQUENCY", "DEBUG", "DEBUG_SQL",
+	"FRONTEND_BASE_URL", "GIN_MODE", "INITIAL_ROOT_ACCESS_TOKEN", "INITIAL_ROOT_TOKEN", "LOG_SQL_DSN",
+	"LOG_SQL_REPLICA_DSN", "MEMORY_CACHE_ENABLED", "NODE_TYPE", "ONEAPI_CONSTRAINED_MODELS", "POLLING_INTERVAL",
+	"PORT", "REDIS_CONN_STRING", "REDIS_MASTER_NAME", "REDIS_PASSWORD", "SESSION_SECRET", "SQLITE_PATH",
+	"SQL_DSN", "SQL_REPLICA_DSN", "TIKTOKEN_CACHE_DIR", "CONFIG_FILE",
+}
+
+var secretKeyParts = []string{"SECRET", "PASSWORD", "TOKEN", "KEY", "DSN", "CONN_STRING"}
+
+type Entry struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Default string `json:"default"`
+	Source  string `json:"source"`
+}
+
+func isSecretKey(key string) bool {
+	for _, part := range secretKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func redact(key string, value string) string {
+	if value == "" || !isSecretKey(key) {
+		return value
+	}
+	return "******"
+}
+
+// Effective lists the effective configuration, secrets are redacted
+func Effective() []Entry {
+	defaults := env.Known()
+	for _, key := range directKeys {
+		if _, ok := defaults[key]; !ok {
+			defaults[key] = ""
+		}
+	}
+	for _, key := range env.FileKeys() {
+		if _, ok := defaults[key]; !ok {
+			defaults[key] = ""
+		}
+	}
+	entries := make([]Entry, 0, len(defaults))
+	for key, defaultValue := range defaults {
+		source := env.Source(key)
+		value := defaultValue
+		if source != "default" {
+			value = os.Getenv(key)
+		}
+		entries = append(entries, Entry{
+			Key:     key,
+			Value:   redact(key, value),
+			Default: redact(key, defaultValue),
+			Source:  source,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
+
+// UnknownFileKeys returns the keys in the config file which are not used by one api, usually typos
+func UnknownFileKeys() []string {
+	defaults := env.Known()
+	for _, key := range directKeys {
+		defaults[key] = ""
+	}
+	var unknown []string
+	for _, key := range env.FileKeys() {
+		if _, ok := defaults[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	return unknown
+}
+
+// Validate checks the configuration on startup and after reloading
+func Validate() error {
+	var errs []error
+	if !ThemeExists(Theme) {
+		errs = append(errs, fmt.Errorf("THEME: unknown theme %q", Theme))
+	}
+	switch BillingQueueOverflowPolicy {
+	case "spawn", "block", "drop":
+	default:
+		errs = append(errs, fmt.Errorf("BILLING_QUEUE_OVERFLOW_POLICY: must be one of spawn, block and drop, got %q", BillingQueueOverflowPolicy))
+	}
+	if BillingWorkerNum <= 0 {
+		errs = append(errs, errors.New("BILLING_WORKER_NUM: must be positive"))
+	}
+	if BatchUpdateInterval <= 0 {
+		errs = append(errs, errors.New("BATCH_UPDATE_INTERVAL: must be positive"))
+	}
+	if SyncFrequency <= 0 {
+		errs = append(errs, errors.New("SYNC_FREQUENCY: must be positive"))
+	}
+	if ShutdownTimeout < 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT: must not be negative"))
+	}
+	if !PublicAdminAPIEnabled && AdminListen == "" {
+		errs = append(errs, errors.New("PUBLIC_ADMIN_API_ENABLED: ADMIN_LISTEN must be set to serve the management API"))
+	}
+	if (TLSCertFile == "") != (TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE: must be set along with TLS_KEY_FILE"))
+	}
+	if _, err := strconv.ParseUint(UnixSocketMode, 8, 32); err != nil {
+		errs = append(errs, errors.New("UNIX_SOCKET_MODE: must be an octal permission such as 0660"))
+	}
+	if ACMEDomains != "" && TLSCertFile != "" {
+		errs = append(errs, errors.New("ACME_DOMAINS: cannot be set along with TLS_CERT_FILE"))
+	}
+	if ACMEHTTPListen != "" && ACMEDomains == "" {
+		errs = append(errs, errors.New("ACME_HTTP_LISTEN: ACME_DOMAINS must be set"))
+	}
+	if (AdminTLSCertFile == "") != (AdminTLSKeyFile == "") {
+		errs = append(errs, errors.New("ADMIN_TLS_CERT_FILE: must be set along with ADMIN_TLS_KEY_FILE"))
+	}
+	if AdminTLSCertFile != "" && AdminListen == "" {
+		errs = append(errs, errors.New("ADMIN_TLS_CERT_FILE: ADMIN_LISTEN must be set"))
+	}
+	if AdminTLSClientCAFile != "" && AdminTLSCertFile == "" {
+		errs = append(errs, errors.New("ADMIN_TLS_CLIENT_CA_FILE: ADMIN_TLS_CERT_FILE must be set"))
+	}
+	if BasePath != "" && (!strings.HasPrefix(BasePath, "/") || strings.ContainsAny(BasePath, "?#")) {
+		errs = append(errs, fmt.Errorf("BASE_PATH: must be a path starting with /, got %q", BasePath))
+	}
+	switch StartupCheckPolicy {
+	case "off", "degraded", "fail":
+	default:
+		errs = append(errs, fmt.Errorf("STARTUP_CHECK_POLICY: must be one of off, degraded and fail, got %q", StartupCheckPolicy))
+	}
+	if DeferredCompletionTTL <= 0 {
+		errs = append(errs, errors.New("DEFERRED_COMPLETION_TTL: must be positive"))
+	}
+	if ConversationMaxMessages <= 0 {
+		errs = append(errs, errors.New("CONVERSATION_MAX_MESSAGES: must be positive"))
+	}
+	if MetricSuccessRateThreshold < 0 || MetricSuccessRateThreshold > 1 {
+		errs = append(errs, errors.New("METRIC_SUCCESS_RATE_THRESHOLD: must be between 0 and 1"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+
+	"github.com/songquanpeng/one-api/common/env"
+)
+
+// Reload re-reads the .env file and refreshes the options which are safe to
+// change while the server is running, it is triggered by SIGHUP.
+// Options like the database DSN, the port or the queue sizes still need a restart.
+func Reload() {
+	if _, err := os.Stat(".env"); err == nil {
+		_ = godotenv.Overload()
+	}
+	DebugEnabled = strings.ToLower(os.Getenv("DEBUG")) == "true"
+	DebugSQLEnabled = strings.ToLower(os.Getenv("DEBUG_SQL")) == "true"
+	RelayTimeout = env.Int("RELAY_TIMEOUT", 0)
+	StreamKeepAliveInterval = env.Int("STREAM_KEEP_ALIVE_INTERVAL", 15)
+	RelayProxy = env.String("RELAY_PROXY", "")
+	UserContentRequestProxy = env.String("USER_CONTENT_REQUEST_PROXY", "")
+	UserContentRequestTimeout = env.Int("USER_CONTENT_REQUEST_TIMEOUT", 30)
+	BillingQueueOverflowPolicy = env.String("BILLING_QUEUE_OVERFLOW_POLICY", "spawn")
+	GeminiSafetySetting = env.String("GEMINI_SAFETY_SETTING", "BLOCK_NONE")
+	GeminiVersion = env.String("GEMINI_VERSION", "v1")
+	MetricSuccessRateThreshold = env.Float64("METRIC_SUCCESS_RATE_THRESHOLD", 0.8)
+	EnforceIncludeUsage = env.Bool("ENFORCE_INCLUDE_USAGE", false)
+	TestPrompt = env.String("TEST_PROMPT", "Output only your specific model name with no additional text.")
+	ObjectStoragePublicURL = env.String("OBJECT_STORAGE_PUBLIC_URL", "")
+	ObjectStorageURLExpiration = env.Int("OBJECT_STORAGE_URL_EXPIRATION", 24*60*60)
+}
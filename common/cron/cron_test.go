@@ -0,0 +1,40 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSchedule(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, _ := time.Parse("2006-01-02 15:04", value)
+		return parsed
+	}
+	Convey("Parse", t, func() {
+		_, err := Parse("0 2 * * *")
+		So(err, ShouldBeNil)
+		_, err = Parse("0 2 * *")
+		So(err, ShouldNotBeNil)
+		_, err = Parse("60 2 * * *")
+		So(err, ShouldNotBeNil)
+		_, err = Parse("*/0 * * * *")
+		So(err, ShouldNotBeNil)
+	})
+	Convey("Match", t, func() {
+		s, _ := Parse("30 2 * * *")
+		So(s.Match(at("2024-06-03 02:30")), ShouldBeTrue)
+		So(s.Match(at("2024-06-03 02:31")), ShouldBeFalse)
+		s, _ = Parse("*/15 0-6 * * 1-5")
+		So(s.Match(at("2024-06-03 06:45")), ShouldBeTrue) // Monday
+		So(s.Match(at("2024-06-03 06:50")), ShouldBeFalse)
+		So(s.Match(at("2024-06-02 06:45")), ShouldBeFalse) // Sunday
+		s, _ = Parse("0 0 * * 7")
+		So(s.Match(at("2024-06-02 00:00")), ShouldBeTrue)
+		s, _ = Parse("0 0 1 * 1")
+		So(s.Match(at("2024-06-01 00:00")), ShouldBeTrue) // the first day of month
+		So(s.Match(at("2024-06-03 00:00")), ShouldBeTrue) // a Monday
+		So(s.Match(at("2024-06-04 00:00")), ShouldBeFalse)
+	})
+}
@@ -1,6 +1,17 @@
 package common
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
 
 func Password2Hash(password string) (string, error) {
 	passwordBytes := []byte(password)
@@ -12,3 +23,65 @@ func ValidatePasswordAndHash(password string, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+const passwordSaltSize = 16
+
+var ErrWrongPassword = errors.New("wrong password or corrupted data")
+
+func passwordCipher(password string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(password), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptWithPassword encrypts data with AES-GCM under a key derived from password,
+// the result is the salt, the nonce and the sealed data
+func EncryptWithPassword(data []byte, password string) ([]byte, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := passwordCipher(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	result := append(salt, nonce...)
+	return aead.Seal(result, nonce, data, nil), nil
+}
+
+func DecryptWithPassword(data []byte, password string) ([]byte, error) {
+	if len(data) < passwordSaltSize {
+		return nil, ErrWrongPassword
+	}
+	aead, err := passwordCipher(password, data[:passwordSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	data = data[passwordSaltSize:]
+	if len(data) < aead.NonceSize() {
+		return nil, ErrWrongPassword
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	return plaintext, nil
+}
+
+// SignWithTokenKey is the hex HMAC-SHA256 of the body with the key of the token, including sk-, so that the
+// receivers of the callbacks posted for the token know they come from the gateway
+func SignWithTokenKey(key string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte("sk-"+key))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -1,24 +1,53 @@
 package ctxkey
 
 const (
-	Config            = "config"
-	Id                = "id"
-	Username          = "username"
-	Role              = "role"
-	Status            = "status"
-	Channel           = "channel"
-	ChannelId         = "channel_id"
-	SpecificChannelId = "specific_channel_id"
-	RequestModel      = "request_model"
-	ConvertedRequest  = "converted_request"
-	OriginalModel     = "original_model"
-	Group             = "group"
-	ModelMapping      = "model_mapping"
-	ChannelName       = "channel_name"
-	TokenId           = "token_id"
-	TokenName         = "token_name"
-	BaseURL           = "base_url"
-	AvailableModels   = "available_models"
-	KeyRequestBody    = "key_request_body"
-	SystemPrompt      = "system_prompt"
+	Config              = "config"
+	Id                  = "id"
+	Username            = "username"
+	Role                = "role"
+	Status              = "status"
+	SessionKey          = "session_key"
+	Channel             = "channel"
+	ChannelId           = "channel_id"
+	SpecificChannelId   = "specific_channel_id"
+	RequestModel        = "request_model"
+	ConvertedRequest    = "converted_request"
+	OriginalModel       = "original_model"
+	Group               = "group"
+	ChannelGroup        = "channel_group"
+	ModelMapping        = "model_mapping"
+	ChannelName         = "channel_name"
+	TokenId             = "token_id"
+	TokenName           = "token_name"
+	BaseURL             = "base_url"
+	AvailableModels     = "available_models"
+	KeyRequestBody      = "key_request_body"
+	KeyRequestObject    = "key_request_object"
+	SystemPrompt        = "system_prompt"
+	DryRun              = "dry_run"
+	Sandbox             = "sandbox"
+	TokenDefaults       = "token_defaults"
+	TokenMaxConcurrency = "token_max_concurrency"
+	TokenQuota          = "token_quota"
+	TokenOpenAIOrg      = "token_openai_organization"
+	TokenOpenAIProject  = "token_openai_project"
+	TranscriptWebhook   = "transcript_webhook"
+	RemainingRequests   = "remaining_requests"
+	PlanGroups          = "plan_groups"
+	QuotaFallback       = "quota_fallback"
+	HoldStreamDone      = "hold_stream_done"
+	StreamDoneHeld      = "stream_done_held"
+	StreamUsageSent     = "stream_usage_sent"
+	StreamIncludeUsage  = "stream_include_usage"
+	StreamInterrupted   = "stream_interrupted"
+	StreamPartialText   = "stream_partial_text"
+	StreamContentSent   = "stream_content_sent"
+	MetadataRequested   = "metadata_requested"
+	ResponseMetadata    = "response_metadata"
+	RelayUsage          = "relay_usage"
+	StreamFilter        = "stream_filter"
+	StreamFault         = "stream_fault"
+	UpstreamTimeout     = "upstream_timeout"
+	ImageOriginalBody   = "image_original_body"
+	ImageDescriptions   = "image_descriptions"
 )
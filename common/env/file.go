@@ -0,0 +1,176 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// The config file is loaded before any other package reads the environment,
+// every key in it is exported as an environment variable unless the variable is already set,
+// so the environment always overrides the file.
+// Nested keys are joined with "_", e.g. batch_update.interval => BATCH_UPDATE_INTERVAL.
+
+var fileLock sync.Mutex
+var filePath = os.Getenv("CONFIG_FILE")
+var fileModTime time.Time
+var fileKeys = make(map[string]string)
+
+func init() {
+	if filePath == "" {
+		return
+	}
+	if err := LoadFile(filePath); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load config file %s: %s\n", filePath, err.Error())
+		os.Exit(1)
+	}
+}
+
+func ConfigFile() string {
+	return filePath
+}
+
+func parseFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q, only yaml and toml are supported", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	if err = flatten("", raw, values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func flatten(prefix string, raw map[string]any, values map[string]string) error {
+	for k, v := range raw {
+		key := strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			if err := flatten(key, v, values); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
+
+// LoadFile applies the config file to the environment,
+// keys removed from the file since the last load are removed from the environment as well
+func LoadFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	values, err := parseFile(path)
+	if err != nil {
+		return err
+	}
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	for key := range fileKeys {
+		if _, ok := values[key]; !ok {
+			_ = os.Unsetenv(key)
+			delete(fileKeys, key)
+		}
+	}
+	for key, value := range values {
+		if _, ok := fileKeys[key]; !ok {
+			if _, exists := os.LookupEnv(key); exists {
+				continue
+			}
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+		fileKeys[key] = value
+	}
+	fileModTime = info.ModTime()
+	return nil
+}
+
+// WatchFile reloads the config file once it is modified and then calls onChange
+func WatchFile(frequency time.Duration, onChange func(err error)) {
+	if filePath == "" {
+		return
+	}
+	for {
+		time.Sleep(frequency)
+		info, err := os.Stat(filePath)
+		if err != nil {
+			continue
+		}
+		fileLock.Lock()
+		changed := !info.ModTime().Equal(fileModTime)
+		fileLock.Unlock()
+		if !changed {
+			continue
+		}
+		err = LoadFile(filePath)
+		if err != nil {
+			// do not try again until the file is modified
+			fileLock.Lock()
+			fileModTime = info.ModTime()
+			fileLock.Unlock()
+		}
+		onChange(err)
+	}
+}
+
+// Source tells where the value of the given key comes from, "file", "env" or "default"
+func Source(key string) string {
+	fileLock.Lock()
+	_, fromFile := fileKeys[key]
+	fileLock.Unlock()
+	if fromFile {
+		return "file"
+	}
+	if os.Getenv(key) != "" {
+		return "env"
+	}
+	return "default"
+}
+
+// FileKeys returns the keys which are set by the config file
+func FileKeys() []string {
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	keys := make([]string, 0, len(fileKeys))
+	for key := range fileKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
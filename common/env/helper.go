@@ -1,11 +1,44 @@
 package env
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"sync"
 )
 
+var knownLock sync.Mutex
+var known = make(map[string]string)
+
+// register remembers every key read through the helpers together with its default value,
+// so that the effective configuration can be listed
+func register(env string, defaultValue any) {
+	if env == "" {
+		return
+	}
+	knownLock.Lock()
+	known[env] = fmt.Sprint(defaultValue)
+	knownLock.Unlock()
+}
+
+// Register is used for keys which are read with os.Getenv directly
+func Register(env string, defaultValue string) {
+	register(env, defaultValue)
+}
+
+// Known returns all registered keys with their default values
+func Known() map[string]string {
+	knownLock.Lock()
+	defer knownLock.Unlock()
+	result := make(map[string]string, len(known))
+	for key, value := range known {
+		result[key] = value
+	}
+	return result
+}
+
 func Bool(env string, defaultValue bool) bool {
+	register(env, defaultValue)
 	if env == "" || os.Getenv(env) == "" {
 		return defaultValue
 	}
@@ -13,6 +46,7 @@ func Bool(env string, defaultValue bool) bool {
 }
 
 func Int(env string, defaultValue int) int {
+	register(env, defaultValue)
 	if env == "" || os.Getenv(env) == "" {
 		return defaultValue
 	}
@@ -24,6 +58,7 @@ func Int(env string, defaultValue int) int {
 }
 
 func Float64(env string, defaultValue float64) float64 {
+	register(env, defaultValue)
 	if env == "" || os.Getenv(env) == "" {
 		return defaultValue
 	}
@@ -35,6 +70,7 @@ func Float64(env string, defaultValue float64) float64 {
 }
 
 func String(env string, defaultValue string) string {
+	register(env, defaultValue)
 	if env == "" || os.Getenv(env) == "" {
 		return defaultValue
 	}
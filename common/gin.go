@@ -4,18 +4,38 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/jsonedit"
 )
 
+// maxPreallocatedBody bounds the buffer allocated from the Content-Length header, larger bodies grow as they are read
+const maxPreallocatedBody = 32 << 20
+
+// ReadRequestBody reads the body into a buffer of its size, so that the large bodies are not copied as they grow
+func ReadRequestBody(r *http.Request) ([]byte, error) {
+	if r.ContentLength <= 0 || r.ContentLength > maxPreallocatedBody {
+		return io.ReadAll(r.Body)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, r.ContentLength+bytes.MinRead))
+	_, err := buf.ReadFrom(r.Body)
+	return buf.Bytes(), err
+}
+
 func GetRequestBody(c *gin.Context) ([]byte, error) {
+	if object := getRequestObject(c); object != nil && object.Changed() {
+		// the edits of the middlewares are serialized once, when the body is read again
+		setRequestBody(c, object.Bytes())
+	}
 	requestBody, _ := c.Get(ctxkey.KeyRequestBody)
 	if requestBody != nil {
 		return requestBody.([]byte), nil
 	}
-	requestBody, err := io.ReadAll(c.Request.Body)
+	requestBody, err := ReadRequestBody(c.Request)
 	if err != nil {
 		return nil, err
 	}
@@ -24,6 +44,43 @@ func GetRequestBody(c *gin.Context) ([]byte, error) {
 	return requestBody.([]byte), nil
 }
 
+// GetRequestObject returns the JSON object of the request body, parsed once and shared by the middlewares,
+// which edit it in place instead of rewriting the body each
+func GetRequestObject(c *gin.Context) (*jsonedit.Object, error) {
+	if object := getRequestObject(c); object != nil {
+		return object, nil
+	}
+	requestBody, err := GetRequestBody(c)
+	if err != nil {
+		return nil, err
+	}
+	object, err := jsonedit.Parse(requestBody)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(ctxkey.KeyRequestObject, object)
+	return object, nil
+}
+
+func getRequestObject(c *gin.Context) *jsonedit.Object {
+	object, _ := c.Get(ctxkey.KeyRequestObject)
+	requestObject, _ := object.(*jsonedit.Object)
+	return requestObject
+}
+
+// SetRequestBody replaces the body of the request, the object parsed from the previous body is dropped
+func SetRequestBody(c *gin.Context, body []byte) {
+	c.Set(ctxkey.KeyRequestObject, nil)
+	setRequestBody(c, body)
+}
+
+func setRequestBody(c *gin.Context, body []byte) {
+	c.Set(ctxkey.KeyRequestBody, body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.ContentLength = int64(len(body))
+	c.Request.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
+
 func UnmarshalBodyReusable(c *gin.Context, v any) error {
 	requestBody, err := GetRequestBody(c)
 	if err != nil {
@@ -121,6 +121,87 @@ func GetRequestID(ctx context.Context) string {
 	return rawRequestId.(string)
 }
 
+// SetReplayOf marks the request as a replay of the given request id
+func SetReplayOf(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ReplayOfKey, id)
+}
+
+func GetReplayOf(ctx context.Context) string {
+	replayOf, _ := ctx.Value(ReplayOfKey).(string)
+	return replayOf
+}
+
+// SetPromptTemplate records the prompt template and its version used by the request
+func SetPromptTemplate(ctx context.Context, template string) context.Context {
+	return context.WithValue(ctx, TemplateKey, template)
+}
+
+func GetPromptTemplate(ctx context.Context) string {
+	template, _ := ctx.Value(TemplateKey).(string)
+	return template
+}
+
+// SetExperiment records the experiment variant assigned to the request, such as "name/A"
+func SetExperiment(ctx context.Context, variant string) context.Context {
+	return context.WithValue(ctx, ExperimentKey, variant)
+}
+
+func GetExperiment(ctx context.Context) string {
+	variant, _ := ctx.Value(ExperimentKey).(string)
+	return variant
+}
+
+// SetSummaryOf marks the request as one summarizing the long context of the given request id
+func SetSummaryOf(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, SummaryOfKey, id)
+}
+
+func GetSummaryOf(ctx context.Context) string {
+	summaryOf, _ := ctx.Value(SummaryOfKey).(string)
+	return summaryOf
+}
+
+// SetScreeningOf marks the request as one scoring the prompt of the given request id for injections
+func SetScreeningOf(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ScreeningOfKey, id)
+}
+
+func GetScreeningOf(ctx context.Context) string {
+	screeningOf, _ := ctx.Value(ScreeningOfKey).(string)
+	return screeningOf
+}
+
+// SetPromptGuard records the verdict of the prompt injection screening of the request
+func SetPromptGuard(ctx context.Context, verdict string) context.Context {
+	return context.WithValue(ctx, PromptGuardKey, verdict)
+}
+
+func GetPromptGuard(ctx context.Context) string {
+	verdict, _ := ctx.Value(PromptGuardKey).(string)
+	return verdict
+}
+
+// SetPipelineStage marks the request as a stage of the pipeline served as the given request id, such as the
+// transcription of a speech-to-speech request
+func SetPipelineStage(ctx context.Context, id string, stage string) context.Context {
+	return context.WithValue(ctx, PipelineKey, [2]string{id, stage})
+}
+
+func GetPipelineStage(ctx context.Context) (id string, stage string) {
+	pipeline, _ := ctx.Value(PipelineKey).([2]string)
+	return pipeline[0], pipeline[1]
+}
+
+// SetProject records the project the usage of the request is attributed to, the requests it sends keep it
+func SetProject(ctx context.Context, project string) context.Context {
+	return context.WithValue(ctx, ProjectKey, project)
+}
+
+func GetProject(ctx context.Context) string {
+	project, _ := ctx.Value(ProjectKey).(string)
+	return project
+}
+
 func GetResponseID(c *gin.Context) string {
 	logID := c.GetString(RequestIdKey)
 	return fmt.Sprintf("chatcmpl-%s", logID)
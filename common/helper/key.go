@@ -1,5 +1,13 @@
 package helper
 
 const (
-	RequestIdKey = "X-Oneapi-Request-Id"
+	RequestIdKey   = "X-Oneapi-Request-Id"
+	ReplayOfKey    = "X-Oneapi-Replay-Of"
+	TemplateKey    = "X-Oneapi-Prompt-Template"
+	ExperimentKey  = "X-Oneapi-Experiment"
+	SummaryOfKey   = "X-Oneapi-Summary-Of"
+	ScreeningOfKey = "X-Oneapi-Screening-Of"
+	PromptGuardKey = "X-Oneapi-Prompt-Guard"
+	PipelineKey    = "X-Oneapi-Pipeline"
+	ProjectKey     = "X-Oneapi-Project"
 )
@@ -22,6 +22,9 @@ func printHelp() {
 	fmt.Println("Copyright (C) 2023 JustSong. All rights reserved.")
 	fmt.Println("GitHub: https://github.com/songquanpeng/one-api")
 	fmt.Println("Usage: one-api [--port <port>] [--log-dir <log directory>] [--version] [--help]")
+	fmt.Println("       one-api migrate [--from <source dsn>] [--to <target dsn>]")
+	fmt.Println("       one-api restore --file <backup file> | --name <backup name> [--password <password>]")
+	fmt.Println("       one-api token|channel|usage <action> [--server <address>] [--access-token <token>] [flags]")
 }
 
 func Init() {
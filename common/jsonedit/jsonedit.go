@@ -0,0 +1,320 @@
+// Package jsonedit edits the top level fields of a JSON object without decoding the others, so that the request
+// bodies are rewritten with their fields in order and the large values, such as images, copied as they are
+package jsonedit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+var errNotObject = errors.New("not a JSON object")
+
+type member struct {
+	key   string
+	raw   []byte // the key, colon and value as in the body, or as set
+	value []byte
+}
+
+// Object is a JSON object whose fields are kept as raw JSON, in order
+type Object struct {
+	body    []byte
+	members []member
+	changed bool
+}
+
+// Parse splits the object into its fields, the values are only scanned for their end, not validated.
+// A value that is not valid JSON is left to the decoder of the edited body to report
+func Parse(body []byte) (*Object, error) {
+	o := &Object{body: body}
+	i := skipSpace(body, 0)
+	if i >= len(body) || body[i] != '{' {
+		return nil, errNotObject
+	}
+	i = skipSpace(body, i+1)
+	if i < len(body) && body[i] == '}' {
+		return o, o.end(i + 1)
+	}
+	for {
+		if i >= len(body) || body[i] != '"' {
+			return nil, errNotObject
+		}
+		start := i
+		keyEnd, err := skipString(body, i)
+		if err != nil {
+			return nil, err
+		}
+		key, err := decodeKey(body[i:keyEnd])
+		if err != nil {
+			return nil, err
+		}
+		i = skipSpace(body, keyEnd)
+		if i >= len(body) || body[i] != ':' {
+			return nil, errNotObject
+		}
+		valueStart := skipSpace(body, i+1)
+		valueEnd, err := skipValue(body, valueStart)
+		if err != nil {
+			return nil, err
+		}
+		o.members = append(o.members, member{key: key, raw: body[start:valueEnd], value: body[valueStart:valueEnd]})
+		i = skipSpace(body, valueEnd)
+		if i >= len(body) {
+			return nil, errNotObject
+		}
+		if body[i] == '}' {
+			return o, o.end(i + 1)
+		}
+		if body[i] != ',' {
+			return nil, errNotObject
+		}
+		i = skipSpace(body, i+1)
+	}
+}
+
+func (o *Object) end(i int) error {
+	if skipSpace(o.body, i) != len(o.body) {
+		return errNotObject
+	}
+	return nil
+}
+
+func (o *Object) index(key string) int {
+	for i := len(o.members) - 1; i >= 0; i-- {
+		if o.members[i].key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns the raw value of the field, the last one if the key is repeated as encoding/json does
+func (o *Object) Get(key string) (json.RawMessage, bool) {
+	i := o.index(key)
+	if i < 0 {
+		return nil, false
+	}
+	return o.members[i].value, true
+}
+
+func (o *Object) Has(key string) bool {
+	return o.index(key) >= 0
+}
+
+// Unmarshal decodes the value of the field into v, nothing is done if the field is absent
+func (o *Object) Unmarshal(key string, v any) error {
+	value, ok := o.Get(key)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(value, v)
+}
+
+// Set replaces the value of the field in place, or appends the field if it is absent
+func (o *Object) Set(key string, v any) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	o.SetRaw(key, value)
+	return nil
+}
+
+func newMember(key string, value json.RawMessage) member {
+	encodedKey, _ := json.Marshal(key)
+	raw := make([]byte, 0, len(encodedKey)+1+len(value))
+	raw = append(append(append(raw, encodedKey...), ':'), value...)
+	return member{key: key, raw: raw, value: raw[len(encodedKey)+1:]}
+}
+
+func (o *Object) SetRaw(key string, value json.RawMessage) {
+	m := newMember(key, value)
+	o.changed = true
+	if i := o.index(key); i >= 0 {
+		o.members[i] = m
+		return
+	}
+	o.members = append(o.members, m)
+}
+
+func (o *Object) Delete(keys ...string) {
+	members := o.members[:0]
+	for _, m := range o.members {
+		deleted := false
+		for _, key := range keys {
+			if m.key == key {
+				deleted = true
+				break
+			}
+		}
+		if deleted {
+			o.changed = true
+			continue
+		}
+		members = append(members, m)
+	}
+	o.members = members
+}
+
+// Prepend inserts the values at the start of the array of the field, it fails if the field is not an array
+func (o *Object) Prepend(key string, values ...any) error {
+	array, ok := o.Get(key)
+	if !ok || len(array) == 0 || array[0] != '[' {
+		return errors.New(key + " is not an array")
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(array) + 64*len(values))
+	buf.WriteByte('[')
+	for i, v := range values {
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(value)
+	}
+	rest := array[skipSpace(array, 1):]
+	if rest[0] != ']' {
+		buf.WriteByte(',')
+	}
+	buf.Write(rest)
+	o.SetRaw(key, buf.Bytes())
+	return nil
+}
+
+// Edit replaces the value of every field, the repeated ones included, by the one returned for it, the fields
+// whose value is returned unchanged are kept as they are
+func (o *Object) Edit(edit func(key string, value json.RawMessage) json.RawMessage) {
+	for i, m := range o.members {
+		value := edit(m.key, m.value)
+		if bytes.Equal(value, m.value) {
+			continue
+		}
+		o.members[i] = newMember(m.key, value)
+		o.changed = true
+	}
+}
+
+// Changed tells whether a field has been set or deleted since the object was parsed or last serialized
+func (o *Object) Changed() bool {
+	return o.changed
+}
+
+// Bytes returns the edited object, the body as parsed if it has not changed. The fields then refer to the
+// returned body, so that the previous values are not kept alive and the object can be edited again
+func (o *Object) Bytes() []byte {
+	if !o.changed {
+		return o.body
+	}
+	size := 2
+	for _, m := range o.members {
+		size += len(m.raw) + 1
+	}
+	out := make([]byte, 0, size)
+	out = append(out, '{')
+	for i := range o.members {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		m := &o.members[i]
+		start := len(out)
+		out = append(out, m.raw...)
+		m.raw = out[start:len(out):len(out)]
+		m.value = m.raw[len(m.raw)-len(m.value):]
+	}
+	o.body = append(out, '}')
+	o.changed = false
+	return o.body
+}
+
+func skipSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch data[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipString returns the index after the string starting at i, searched quote by quote
+// so that the long strings are skipped quickly
+func skipString(data []byte, i int) (int, error) {
+	i++
+	for {
+		j := bytes.IndexByte(data[i:], '"')
+		if j < 0 {
+			return 0, errors.New("unterminated string")
+		}
+		i += j
+		backslashes := 0
+		for k := i - 1; k >= 0 && data[k] == '\\'; k-- {
+			backslashes++
+		}
+		i++
+		if backslashes%2 == 0 {
+			return i, nil
+		}
+	}
+}
+
+func skipValue(data []byte, i int) (int, error) {
+	if i >= len(data) {
+		return 0, errors.New("unexpected end of JSON")
+	}
+	switch data[i] {
+	case '"':
+		return skipString(data, i)
+	case '{', '[':
+		depth := 0
+		for i < len(data) {
+			switch data[i] {
+			case '"':
+				end, err := skipString(data, i)
+				if err != nil {
+					return 0, err
+				}
+				i = end
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1, nil
+				}
+			}
+			i++
+		}
+		return 0, errors.New("unexpected end of JSON")
+	default:
+		start := i
+		for i < len(data) {
+			switch data[i] {
+			case ',', '}', ']', ' ', '\t', '\n', '\r':
+				if i == start {
+					return 0, errors.New("empty value")
+				}
+				return i, nil
+			}
+			i++
+		}
+		return i, nil
+	}
+}
+
+func decodeKey(raw []byte) (string, error) {
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return string(raw[1 : len(raw)-1]), nil
+	}
+	var key string
+	err := json.Unmarshal(raw, &key)
+	return key, err
+}
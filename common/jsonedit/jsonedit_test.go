@@ -0,0 +1,70 @@
+package jsonedit
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJSONEdit(t *testing.T) {
+	Convey("Parse", t, func() {
+		for _, body := range []string{``, `[]`, `{`, `{"a"}`, `{"a":}`, `{"a":1,}`, `{"a":"b}`, `{"a":[1,2}`, `{"a":1} x`} {
+			_, err := Parse([]byte(body))
+			So(err, ShouldNotBeNil)
+		}
+		o, err := Parse([]byte(` { } `))
+		So(err, ShouldBeNil)
+		So(o.Has("a"), ShouldBeFalse)
+
+		o, err = Parse([]byte(`{"model": "gpt-4o", "n": 1, "s": "a \"quoted\" \\", "m": [{"x": "]}"}], "b": true, "z": null, "key": 2}`))
+		So(err, ShouldBeNil)
+		value, ok := o.Get("m")
+		So(ok, ShouldBeTrue)
+		So(string(value), ShouldEqual, `[{"x": "]}"}]`)
+		value, _ = o.Get("s")
+		So(string(value), ShouldEqual, `"a \"quoted\" \\"`)
+		var model string
+		So(o.Unmarshal("model", &model), ShouldBeNil)
+		So(model, ShouldEqual, "gpt-4o")
+		So(o.Has("key"), ShouldBeTrue)
+		So(o.Unmarshal("absent", &model), ShouldBeNil)
+	})
+
+	Convey("Edit", t, func() {
+		body := []byte(`{"model": "o3", "temperature": 0.7, "messages": [{"role": "user", "content": "hi"}], "max_tokens": 10}`)
+		o, err := Parse(body)
+		So(err, ShouldBeNil)
+		So(string(o.Bytes()), ShouldEqual, string(body))
+		So(o.Changed(), ShouldBeFalse)
+
+		o.Delete("temperature", "top_p")
+		maxTokens, _ := o.Get("max_tokens")
+		o.SetRaw("max_completion_tokens", maxTokens)
+		o.Delete("max_tokens")
+		So(o.Set("model", "o3-mini"), ShouldBeNil)
+		So(o.Prepend("messages", map[string]any{"role": "system", "content": "be brief"}), ShouldBeNil)
+		So(o.Changed(), ShouldBeTrue)
+		So(string(o.Bytes()), ShouldEqual, `{"model":"o3-mini","messages":[{"content":"be brief","role":"system"},{"role": "user", "content": "hi"}],"max_completion_tokens":10}`)
+		So(json.Valid(o.Bytes()), ShouldBeTrue)
+		So(o.Changed(), ShouldBeFalse)
+		value, _ := o.Get("messages")
+		So(string(value), ShouldEqual, `[{"content":"be brief","role":"system"},{"role": "user", "content": "hi"}]`)
+		So(o.Set("n", 2), ShouldBeNil)
+		So(string(o.Bytes()), ShouldEqual, `{"model":"o3-mini","messages":[{"content":"be brief","role":"system"},{"role": "user", "content": "hi"}],"max_completion_tokens":10,"n":2}`)
+
+		o, _ = Parse([]byte(`{"messages": [ ], "a": 1}`))
+		So(o.Prepend("messages", "x", "y"), ShouldBeNil)
+		So(o.Prepend("a", "x"), ShouldNotBeNil)
+		So(string(o.Bytes()), ShouldEqual, `{"messages":["x","y"],"a": 1}`)
+
+		o, _ = Parse([]byte(`{"key": "a", "n": 1, "key": "b"}`))
+		o.Edit(func(key string, value json.RawMessage) json.RawMessage {
+			if key == "key" {
+				return json.RawMessage(`"***"`)
+			}
+			return value
+		})
+		So(string(o.Bytes()), ShouldEqual, `{"key":"***","n": 1,"key":"***"}`)
+	})
+}
@@ -0,0 +1,198 @@
+package message
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+const (
+	BotTelegram = "telegram"
+	BotLark     = "lark"
+	BotDingTalk = "dingtalk"
+)
+
+var botClient = &http.Client{Timeout: 10 * time.Second}
+
+func postJSON(url string, header map[string]string, body any, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := botClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bot api returns status code %d", resp.StatusCode)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// SendBotMessage sends the text to the chat of the bot platform
+func SendBotMessage(platform string, chatId string, text string) error {
+	switch platform {
+	case BotTelegram:
+		return sendTelegram(chatId, text)
+	case BotLark:
+		return sendLark(chatId, text)
+	case BotDingTalk:
+		return sendDingTalk(chatId, text)
+	}
+	return fmt.Errorf("unknown bot platform: %s", platform)
+}
+
+func sendTelegram(chatId string, text string) error {
+	if config.TelegramBotToken == "" {
+		return errors.New("telegram bot token is not set")
+	}
+	var res struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	err := postJSON(fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", config.TelegramBotToken), nil, map[string]any{
+		"chat_id": chatId,
+		"text":    text,
+	}, &res)
+	if err != nil {
+		return err
+	}
+	if !res.Ok {
+		return errors.New(res.Description)
+	}
+	return nil
+}
+
+// botAccessToken caches the access token of the bot apps, they are valid for about two hours
+type botAccessToken struct {
+	sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func (t *botAccessToken) get(fetch func() (string, int, error)) (string, error) {
+	t.Lock()
+	defer t.Unlock()
+	if t.token != "" && time.Now().Before(t.expiresAt) {
+		return t.token, nil
+	}
+	token, expiresIn, err := fetch()
+	if err != nil {
+		return "", err
+	}
+	t.token = token
+	t.expiresAt = time.Now().Add(time.Duration(expiresIn)*time.Second - time.Minute)
+	return token, nil
+}
+
+var larkAccessToken botAccessToken
+
+func sendLark(chatId string, text string) error {
+	if config.LarkBotAppId == "" || config.LarkBotAppSecret == "" {
+		return errors.New("lark bot app is not set")
+	}
+	token, err := larkAccessToken.get(func() (string, int, error) {
+		var res struct {
+			Code              int    `json:"code"`
+			Msg               string `json:"msg"`
+			TenantAccessToken string `json:"tenant_access_token"`
+			Expire            int    `json:"expire"`
+		}
+		err := postJSON("https://open.feishu.cn/open-apis/auth/v3/tenant_access_token/internal", nil, map[string]any{
+			"app_id":     config.LarkBotAppId,
+			"app_secret": config.LarkBotAppSecret,
+		}, &res)
+		if err == nil && res.Code != 0 {
+			err = errors.New(res.Msg)
+		}
+		return res.TenantAccessToken, res.Expire, err
+	})
+	if err != nil {
+		return err
+	}
+	content, _ := json.Marshal(map[string]string{"text": text})
+	var res struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	err = postJSON("https://open.feishu.cn/open-apis/im/v1/messages?receive_id_type=chat_id", map[string]string{
+		"Authorization": "Bearer " + token,
+	}, map[string]any{
+		"receive_id": chatId,
+		"msg_type":   "text",
+		"content":    string(content),
+	}, &res)
+	if err != nil {
+		return err
+	}
+	if res.Code != 0 {
+		return errors.New(res.Msg)
+	}
+	return nil
+}
+
+var dingTalkAccessToken botAccessToken
+
+func sendDingTalk(conversationId string, text string) error {
+	if config.DingTalkBotAppKey == "" || config.DingTalkBotAppSecret == "" {
+		return errors.New("dingtalk bot app is not set")
+	}
+	token, err := dingTalkAccessToken.get(func() (string, int, error) {
+		var res struct {
+			AccessToken string `json:"accessToken"`
+			ExpireIn    int    `json:"expireIn"`
+		}
+		err := postJSON("https://api.dingtalk.com/v1.0/oauth2/accessToken", nil, map[string]any{
+			"appKey":    config.DingTalkBotAppKey,
+			"appSecret": config.DingTalkBotAppSecret,
+		}, &res)
+		if err == nil && res.AccessToken == "" {
+			err = errors.New("failed to get dingtalk access token")
+		}
+		return res.AccessToken, res.ExpireIn, err
+	})
+	if err != nil {
+		return err
+	}
+	msgParam, _ := json.Marshal(map[string]string{"content": text})
+	return postJSON("https://api.dingtalk.com/v1.0/robot/groupMessages/send", map[string]string{
+		"x-acs-dingtalk-access-token": token,
+	}, map[string]any{
+		"msgParam":           string(msgParam),
+		"msgKey":             "sampleText",
+		"openConversationId": conversationId,
+		"robotCode":          config.DingTalkBotAppKey,
+	}, nil)
+}
+
+// SendDingTalkSessionWebhook replies to the session of the message received by the dingtalk robot
+func SendDingTalkSessionWebhook(sessionWebhook string, text string) error {
+	if !strings.HasPrefix(sessionWebhook, "https://oapi.dingtalk.com/") {
+		return errors.New("invalid dingtalk session webhook")
+	}
+	return postJSON(sessionWebhook, nil, map[string]any{
+		"msgtype": "text",
+		"text": map[string]string{
+			"content": text,
+		},
+	}, nil)
+}
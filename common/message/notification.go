@@ -0,0 +1,198 @@
+package message
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"sync"
+	"text/template"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	NotificationQuotaWarning     = "quota_warning"
+	NotificationTokenExpiry      = "token_expiry"
+	NotificationChannelFailure   = "channel_failure"
+	NotificationMonthlyStatement = "monthly_statement"
+	NotificationTokenAnomaly     = "token_anomaly"
+	NotificationKeyScanReport    = "key_scan_report"
+	NotificationChannelSpendCap  = "channel_spend_cap"
+	NotificationDailyDigest      = "daily_digest"
+	NotificationWeeklyDigest     = "weekly_digest"
+)
+
+// NotificationTemplate is rendered with the data of the notification,
+// the subject is a text template and the body is a html template
+type NotificationTemplate struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+var defaultNotificationTemplates = map[string]NotificationTemplate{
+	NotificationQuotaWarning: {
+		Subject: "额度提醒",
+		Body: `<p>您好！</p>
+<p>{{if .Exhausted}}您的额度已用尽{{else}}您的额度即将用尽{{end}}，当前剩余额度为 <strong>{{.Quota}}</strong>。</p>
+<p>为了不影响您的使用，请及时充值。</p>
+<p style="text-align: center; margin: 30px 0;">
+	<a href="{{.TopUpLink}}" style="background-color: #007bff; color: white; padding: 12px 24px; text-decoration: none; border-radius: 4px; display: inline-block;">立即充值</a>
+</p>
+<p style="color: #666;">如果按钮无法点击，请复制以下链接到浏览器中打开：</p>
+<p style="background-color: #f8f8f8; padding: 10px; border-radius: 4px; word-break: break-all;">{{.TopUpLink}}</p>`,
+	},
+	NotificationTokenExpiry: {
+		Subject: "令牌即将过期",
+		Body: `<p>您好！</p>
+<p>您的令牌「<strong>{{.TokenName}}</strong>」将于 <strong>{{.ExpiredAt}}</strong> 过期。</p>
+<p>过期后使用该令牌的请求将会失败，如需继续使用，请及时延长令牌的过期时间。</p>`,
+	},
+	NotificationChannelFailure: {
+		Subject: "渠道状态变更提醒",
+		Body: `<p>您好！</p>
+<p>渠道{{if .ChannelName}}「<strong>{{.ChannelName}}</strong>」{{end}}（#{{.ChannelId}}）已被禁用。</p>
+<p>禁用原因：</p>
+<p style="background-color: #f8f8f8; padding: 10px; border-radius: 4px;">{{.Reason}}</p>`,
+	},
+	NotificationMonthlyStatement: {
+		Subject: "{{.Month}} 月度账单",
+		Body: `<p>您好！</p>
+<p>以下是您在 {{.Month}} 的使用情况：</p>
+<p>请求次数：<strong>{{.RequestCount}}</strong></p>
+<p>提示 token 数：<strong>{{.PromptTokens}}</strong>，补全 token 数：<strong>{{.CompletionTokens}}</strong></p>
+<p>消耗额度：<strong>{{.Quota}}</strong></p>
+<p>当前剩余额度：<strong>{{.RemainQuota}}</strong></p>`,
+	},
+	NotificationTokenAnomaly: {
+		Subject: "令牌用量异常",
+		Body: `<p>您好！</p>
+<p>您的令牌「<strong>{{.TokenName}}</strong>」用量异常：{{.Description}}。</p>
+<p>如果这不是您本人的使用，令牌可能已经泄露，请尽快禁用或删除该令牌。</p>`,
+	},
+	NotificationKeyScanReport: {
+		Subject: "渠道密钥检查报告",
+		Body: `<p>您好！</p>
+<p>本日共检查 <strong>{{.Total}}</strong> 个渠道的密钥，有效 <strong>{{.Valid}}</strong> 个，失效 <strong>{{.Invalid}}</strong> 个，请求失败 {{.Errors}} 个，不支持检查 {{.Unsupported}} 个。</p>
+{{if .Failures}}<p>以下渠道的密钥未通过检查，失效的渠道已被禁用：</p>
+<ul>{{range .Failures}}<li>{{.}}</li>{{end}}</ul>{{end}}`,
+	},
+	NotificationChannelSpendCap: {
+		Subject: "渠道消费上限提醒",
+		Body: `<p>您好！</p>
+<p>渠道「<strong>{{.ChannelName}}</strong>」（#{{.ChannelId}}）{{.Period}}已消耗额度 <strong>{{.Used}}</strong>，达到上限 <strong>{{.Limit}}</strong>。</p>
+<p>该渠道已暂停使用，将在{{.Period}}结束后自动恢复；如需提前恢复，请调高该渠道的消费上限。</p>`,
+	},
+	NotificationDailyDigest: {
+		Subject: "{{.Period}} 使用日报",
+		Body: `<p>您好！</p>
+<p>以下是您在 {{.Period}} 的使用情况：</p>
+<p>请求次数：<strong>{{.RequestCount}}</strong>，提示 token 数：<strong>{{.PromptTokens}}</strong>，补全 token 数：<strong>{{.CompletionTokens}}</strong></p>
+<p>消耗额度：<strong>{{.Quota}}</strong>，当前剩余额度：<strong>{{.RemainQuota}}</strong></p>
+<table style="border-collapse: collapse;">
+<tr><th style="text-align: left; padding: 4px 12px 4px 0;">模型</th><th style="text-align: right; padding: 4px 12px;">请求次数</th><th style="text-align: right; padding: 4px 12px;">token 数</th><th style="text-align: right; padding: 4px 0 4px 12px;">消耗额度</th></tr>
+{{range .Models}}<tr><td style="padding: 4px 12px 4px 0;">{{.Model}}</td><td style="text-align: right; padding: 4px 12px;">{{.RequestCount}}</td><td style="text-align: right; padding: 4px 12px;">{{.Tokens}}</td><td style="text-align: right; padding: 4px 0 4px 12px;">{{.Quota}}</td></tr>
+{{end}}</table>`,
+	},
+	NotificationWeeklyDigest: {
+		Subject: "{{.Period}} 使用周报",
+		Body: `<p>您好！</p>
+<p>以下是您在 {{.Period}} 的使用情况：</p>
+<p>请求次数：<strong>{{.RequestCount}}</strong>，提示 token 数：<strong>{{.PromptTokens}}</strong>，补全 token 数：<strong>{{.CompletionTokens}}</strong></p>
+<p>消耗额度：<strong>{{.Quota}}</strong>，当前剩余额度：<strong>{{.RemainQuota}}</strong></p>
+<table style="border-collapse: collapse;">
+<tr><th style="text-align: left; padding: 4px 12px 4px 0;">模型</th><th style="text-align: right; padding: 4px 12px;">请求次数</th><th style="text-align: right; padding: 4px 12px;">token 数</th><th style="text-align: right; padding: 4px 0 4px 12px;">消耗额度</th></tr>
+{{range .Models}}<tr><td style="padding: 4px 12px 4px 0;">{{.Model}}</td><td style="text-align: right; padding: 4px 12px;">{{.RequestCount}}</td><td style="text-align: right; padding: 4px 12px;">{{.Tokens}}</td><td style="text-align: right; padding: 4px 0 4px 12px;">{{.Quota}}</td></tr>
+{{end}}</table>`,
+	},
+}
+
+var notificationTemplatesLock sync.RWMutex
+
+// NotificationTemplates overrides the default templates by the kind of the notification
+var NotificationTemplates = map[string]NotificationTemplate{}
+
+func NotificationTemplates2JSONString() string {
+	notificationTemplatesLock.RLock()
+	defer notificationTemplatesLock.RUnlock()
+	jsonBytes, err := json.Marshal(NotificationTemplates)
+	if err != nil {
+		logger.SysError("error marshalling notification templates: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateNotificationTemplatesByJSONString(jsonStr string) error {
+	notificationTemplates := make(map[string]NotificationTemplate)
+	if err := json.Unmarshal([]byte(jsonStr), &notificationTemplates); err != nil {
+		return err
+	}
+	for kind, notificationTemplate := range notificationTemplates {
+		if _, ok := defaultNotificationTemplates[kind]; !ok {
+			return fmt.Errorf("unknown notification: %s", kind)
+		}
+		if _, err := template.New(kind).Parse(notificationTemplate.Subject); err != nil {
+			return err
+		}
+		if _, err := htmltemplate.New(kind).Parse(notificationTemplate.Body); err != nil {
+			return err
+		}
+	}
+	notificationTemplatesLock.Lock()
+	defer notificationTemplatesLock.Unlock()
+	NotificationTemplates = notificationTemplates
+	return nil
+}
+
+func getNotificationTemplate(kind string) (NotificationTemplate, bool) {
+	notificationTemplatesLock.RLock()
+	defer notificationTemplatesLock.RUnlock()
+	notificationTemplate, ok := defaultNotificationTemplates[kind]
+	if !ok {
+		return notificationTemplate, false
+	}
+	if override, ok := NotificationTemplates[kind]; ok {
+		if override.Subject != "" {
+			notificationTemplate.Subject = override.Subject
+		}
+		if override.Body != "" {
+			notificationTemplate.Body = override.Body
+		}
+	}
+	return notificationTemplate, true
+}
+
+// RenderNotification returns the subject and the html email content of the notification,
+// SystemName and ServerAddress are always available to the templates
+func RenderNotification(kind string, data map[string]any) (subject string, content string, err error) {
+	notificationTemplate, ok := getNotificationTemplate(kind)
+	if !ok {
+		return "", "", fmt.Errorf("unknown notification: %s", kind)
+	}
+	values := map[string]any{
+		"SystemName":    config.SystemName,
+		"ServerAddress": config.ServerAddress,
+	}
+	for k, v := range data {
+		values[k] = v
+	}
+	subjectTemplate, err := template.New(kind).Parse(notificationTemplate.Subject)
+	if err != nil {
+		return "", "", err
+	}
+	var buf bytes.Buffer
+	if err = subjectTemplate.Execute(&buf, values); err != nil {
+		return "", "", err
+	}
+	subject = buf.String()
+	bodyTemplate, err := htmltemplate.New(kind).Parse(notificationTemplate.Body)
+	if err != nil {
+		return "", "", err
+	}
+	buf.Reset()
+	if err = bodyTemplate.Execute(&buf, values); err != nil {
+		return "", "", err
+	}
+	return subject, EmailTemplate(subject, buf.String()), nil
+}
@@ -0,0 +1,29 @@
+package network
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ACMEManager obtains and renews the certificates of the comma separated domains, it refuses the handshakes of
+// any other host so that nobody can make it request certificates for arbitrary names
+func ACMEManager(domains string, email string, cachePath string, directoryURL string) *autocert.Manager {
+	var hosts []string
+	for _, domain := range strings.Split(domains, ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			hosts = append(hosts, domain)
+		}
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cachePath),
+		HostPolicy: autocert.HostWhitelist(hosts...),
+		Email:      email,
+	}
+	if directoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: directoryURL}
+	}
+	return manager
+}
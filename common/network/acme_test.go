@@ -0,0 +1,21 @@
+package network
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestACMEManager(t *testing.T) {
+	Convey("TestACMEManager", t, func() {
+		manager := ACMEManager("api.example.com, Example.com,", "", t.TempDir(), "")
+		So(manager.HostPolicy(context.Background(), "api.example.com"), ShouldBeNil)
+		So(manager.HostPolicy(context.Background(), "example.com"), ShouldBeNil)
+		So(manager.HostPolicy(context.Background(), "other.example.com"), ShouldNotBeNil)
+		So(manager.Client, ShouldBeNil)
+
+		manager = ACMEManager("example.com", "", t.TempDir(), "https://acme-staging-v02.api.letsencrypt.org/directory")
+		So(manager.Client.DirectoryURL, ShouldEqual, "https://acme-staging-v02.api.letsencrypt.org/directory")
+	})
+}
@@ -0,0 +1,122 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	unixPrefix    = "unix:"
+	systemdPrefix = "systemd"
+	// listenFdsStart is the first file descriptor passed by systemd
+	listenFdsStart = 3
+)
+
+var (
+	activatedOnce  sync.Once
+	activatedFiles []*os.File
+	activatedNames []string
+)
+
+// Listen listens on the address, which is one of
+//   - host:port, such as 127.0.0.1:3000 or :3000
+//   - unix:/path/to/socket, a unix domain socket created with the mode
+//   - systemd or systemd:name, the first socket passed by systemd socket activation, or the one of the name
+//     (FileDescriptorName= in the socket unit)
+func Listen(address string, mode os.FileMode) (net.Listener, error) {
+	if strings.HasPrefix(address, unixPrefix) {
+		return listenUnix(strings.TrimPrefix(address, unixPrefix), mode)
+	}
+	if address == systemdPrefix || strings.HasPrefix(address, systemdPrefix+":") {
+		return listenActivated(strings.TrimPrefix(strings.TrimPrefix(address, systemdPrefix), ":"))
+	}
+	return net.Listen("tcp", address)
+}
+
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("the path of the unix socket is empty")
+	}
+	// a socket left by a previous process which was killed blocks the listen
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return localListener{listener}, nil
+}
+
+func listenActivated(name string) (net.Listener, error) {
+	activatedOnce.Do(func() {
+		pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID"))
+		n, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if pid != os.Getpid() || n <= 0 {
+			return
+		}
+		names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+		for i := 0; i < n; i++ {
+			fdName := ""
+			if i < len(names) {
+				fdName = names[i]
+			}
+			activatedFiles = append(activatedFiles, os.NewFile(uintptr(listenFdsStart+i), fdName))
+			activatedNames = append(activatedNames, fdName)
+		}
+		// the sockets are not passed on to the child processes
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	})
+	if len(activatedFiles) == 0 {
+		return nil, errors.New("no socket is passed by systemd socket activation")
+	}
+	for i, file := range activatedFiles {
+		if name != "" && activatedNames[i] != name {
+			continue
+		}
+		listener, err := net.FileListener(file)
+		if err != nil {
+			return nil, err
+		}
+		if listener.Addr().Network() == "unix" {
+			return localListener{listener}, nil
+		}
+		return listener, nil
+	}
+	return nil, fmt.Errorf("no socket named %s is passed by systemd socket activation", name)
+}
+
+// localListener reports the peers of a unix socket as 127.0.0.1, which have no address otherwise, so that the
+// client ip is taken from the X-Forwarded-For header set by the local reverse proxy
+type localListener struct {
+	net.Listener
+}
+
+func (l localListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return localConn{conn}, nil
+}
+
+type localConn struct {
+	net.Conn
+}
+
+func (c localConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
@@ -0,0 +1,49 @@
+package network
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestListen(t *testing.T) {
+	Convey("TestListen", t, func() {
+		path := filepath.Join(t.TempDir(), "one-api.sock")
+		listener, err := Listen("unix:"+path, 0o660)
+		So(err, ShouldBeNil)
+		info, err := os.Stat(path)
+		So(err, ShouldBeNil)
+		So(info.Mode().Perm(), ShouldEqual, os.FileMode(0o660))
+
+		go func() {
+			conn, err := net.Dial("unix", path)
+			if err == nil {
+				_ = conn.Close()
+			}
+		}()
+		conn, err := listener.Accept()
+		So(err, ShouldBeNil)
+		So(conn.RemoteAddr().String(), ShouldEqual, "127.0.0.1:0")
+		_ = conn.Close()
+
+		// the socket left by a killed process is replaced
+		listener.(localListener).Listener.(*net.UnixListener).SetUnlinkOnClose(false)
+		So(listener.Close(), ShouldBeNil)
+		listener, err = Listen("unix:"+path, 0o600)
+		So(err, ShouldBeNil)
+		So(listener.Close(), ShouldBeNil)
+
+		_, err = Listen("unix:", 0o660)
+		So(err, ShouldNotBeNil)
+		_, err = Listen("systemd", 0o660)
+		So(err, ShouldNotBeNil)
+
+		listener, err = Listen("127.0.0.1:0", 0o660)
+		So(err, ShouldBeNil)
+		So(listener.Addr().Network(), ShouldEqual, "tcp")
+		So(listener.Close(), ShouldBeNil)
+	})
+}
@@ -0,0 +1,65 @@
+package network
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// the origins are separated by commas, "*" allows any origin, and "https://*.example.com" the subdomains
+// of example.com over https
+
+func splitOrigins(origins string) []string {
+	var res []string
+	for _, origin := range strings.Split(origins, ",") {
+		if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); origin != "" {
+			res = append(res, origin)
+		}
+	}
+	return res
+}
+
+func IsValidOrigins(origins string) error {
+	for _, origin := range splitOrigins(origins) {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			return fmt.Errorf("invalid origin: %s", origin)
+		}
+	}
+	return nil
+}
+
+// NormalizeOrigin returns the origin of the address, such as the server address
+func NormalizeOrigin(address string) string {
+	u, err := url.Parse(address)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+func isOriginMatched(origin string, pattern string) bool {
+	if pattern == "*" {
+		return true
+	}
+	pattern = strings.ToLower(pattern)
+	if scheme, domain, ok := strings.Cut(pattern, "://*."); ok {
+		prefix := scheme + "://"
+		return strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, "."+domain)
+	}
+	return origin == pattern
+}
+
+// IsOriginAllowed tells whether the origin sent by a browser is in the origins
+func IsOriginAllowed(origin string, origins string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range splitOrigins(origins) {
+		if isOriginMatched(origin, pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package network
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIsOriginAllowed(t *testing.T) {
+	Convey("TestIsOriginAllowed", t, func() {
+		So(IsOriginAllowed("https://app.example.com", "*"), ShouldBeTrue)
+		So(IsOriginAllowed("https://app.example.com", "https://app.example.com/"), ShouldBeTrue)
+		So(IsOriginAllowed("https://App.Example.com", "https://a.com, https://app.example.com"), ShouldBeTrue)
+		So(IsOriginAllowed("http://app.example.com", "https://app.example.com"), ShouldBeFalse)
+		So(IsOriginAllowed("https://app.example.com:8443", "https://app.example.com"), ShouldBeFalse)
+		So(IsOriginAllowed("https://a.b.example.com", "https://*.example.com"), ShouldBeTrue)
+		So(IsOriginAllowed("https://example.com", "https://*.example.com"), ShouldBeFalse)
+		So(IsOriginAllowed("https://evilexample.com", "https://*.example.com"), ShouldBeFalse)
+		So(IsOriginAllowed("null", "https://app.example.com"), ShouldBeFalse)
+		So(IsOriginAllowed("https://app.example.com", ""), ShouldBeFalse)
+	})
+}
+
+func TestIsValidOrigins(t *testing.T) {
+	Convey("TestIsValidOrigins", t, func() {
+		So(IsValidOrigins(""), ShouldBeNil)
+		So(IsValidOrigins("*"), ShouldBeNil)
+		So(IsValidOrigins("https://a.com, http://localhost:3000, https://*.example.com"), ShouldBeNil)
+		So(IsValidOrigins("a.com"), ShouldNotBeNil)
+		So(IsValidOrigins("https://a.com/path"), ShouldNotBeNil)
+		So(IsValidOrigins("ftp://a.com"), ShouldNotBeNil)
+	})
+}
+
+func TestNormalizeOrigin(t *testing.T) {
+	Convey("TestNormalizeOrigin", t, func() {
+		So(NormalizeOrigin("https://One.Example.com/console/"), ShouldEqual, "https://one.example.com")
+		So(NormalizeOrigin("http://localhost:3000"), ShouldEqual, "http://localhost:3000")
+		So(NormalizeOrigin(""), ShouldEqual, "")
+	})
+}
@@ -0,0 +1,50 @@
+package network
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClientCertTLSConfig(t *testing.T) {
+	Convey("TestClientCertTLSConfig", t, func() {
+		dir := t.TempDir()
+		_, err := ClientCertTLSConfig(filepath.Join(dir, "missing.pem"))
+		So(err, ShouldNotBeNil)
+
+		empty := filepath.Join(dir, "empty.pem")
+		So(os.WriteFile(empty, []byte("not a certificate"), 0o600), ShouldBeNil)
+		_, err = ClientCertTLSConfig(empty)
+		So(err, ShouldNotBeNil)
+
+		ca := filepath.Join(dir, "ca.pem")
+		So(os.WriteFile(ca, []byte(testCA), 0o600), ShouldBeNil)
+		tlsConfig, err := ClientCertTLSConfig(ca)
+		So(err, ShouldBeNil)
+		So(tlsConfig.ClientAuth, ShouldEqual, tls.RequireAndVerifyClientCert)
+		So(tlsConfig.ClientCAs, ShouldNotBeNil)
+	})
+}
+
+const testCA = `-----BEGIN CERTIFICATE-----
+MIIC/zCCAeegAwIBAgIUDnDVuvmZJI8Fg31OL9kDWyPKM64wDQYJKoZIhvcNAQEL
+BQAwDzENMAsGA1UEAwwEenpjYTAeFw0yNjEwMTQwNzQ2MDJaFw0yNjEwMTUwNzQ2
+MDJaMA8xDTALBgNVBAMMBHp6Y2EwggEiMA0GCSqGSIb3DQEBAQUAA4IBDwAwggEK
+AoIBAQDMx2OTFzxNA54L/uwT8VgnBowPo7+TDH2CiMJHz8k1HOBlqie/WrIiY56Z
+M+kkDQaEQAhKU2HMTQhSEmHohXc+Ad+vQtmff8U2/1ZGqDJx4Q/Inz1IFJ+kS1D7
+ZSM0ls7IEKQXpknozsMqS0+lqrFghTSr5m7d23e7qJaM7JSxIcNt9WdaZSl2zP6J
+pOlYlgVUZReq+u7RzDFTVzVlkC1/yk7csIvQhjQvQ2oqTbzX6NSnrU3QrYxmbnHD
+l2O6+yMhofpncG3eUfUfkUL/tNGcUXkr4rRO7LfV//cT5u0WroCzMOCMnYgj5Ncb
+GiL86C0Lqt8waC0rpqsnLXUL9DfRAgMBAAGjUzBRMB0GA1UdDgQWBBTKODVU049u
+BVQIPpt60oSpp9RMqzAfBgNVHSMEGDAWgBTKODVU049uBVQIPpt60oSpp9RMqzAP
+BgNVHRMBAf8EBTADAQH/MA0GCSqGSIb3DQEBCwUAA4IBAQAbkhLv8DyAZotH1bXH
+Dzg/xbJcSbyUxOe4IJfm3Skwwo9Q2AzCHIHZAfvdhoHbFdGorJ9i1UaasSV8VitT
+UYgJ+s5UCB2iuTlXiK/NTJsN1gdOEPJFgU51DQrIZGC/TbT85cCAyjXgebhlN0HD
+Ml9rCiXAtEYs1SxW0kz+78t35CX5kKkQDcgJWyJV86fBXfF00GyfYboyaopn540B
+6GDOH3akGFYp9rFjqxqaq5smoHhlmDc0vrHGVjNrNc+ulhB2LCA8FWXreoYkaErB
+QkhLHcinu00u19fD7TJj59kESF0EveP8L//Tsywg0ltAPBmfNQkpMnSDC9895yLO
+AGOP
+-----END CERTIFICATE-----`
@@ -68,3 +68,24 @@ func (l *InMemoryRateLimiter) Request(key string, maxRequestNum int, duration in
 	}
 	return true
 }
+
+// Remaining returns how many more requests the key may make in the duration, the unit of duration is seconds
+func (l *InMemoryRateLimiter) Remaining(key string, maxRequestNum int, duration int64) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	queue, ok := l.store[key]
+	if !ok {
+		return maxRequestNum
+	}
+	now := time.Now().Unix()
+	used := 0
+	for _, t := range *queue {
+		if now-t < duration {
+			used++
+		}
+	}
+	if used >= maxRequestNum {
+		return 0
+	}
+	return maxRequestNum - used
+}
@@ -0,0 +1,290 @@
+// Package redact removes the sensitive parts of the logs before they are recorded, the fields of the request bodies
+// chosen by a JSONPath and the text matching a regular expression, by the rules set by the admin
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/jsonedit"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// DefaultReplacement replaces what is redacted when the rule sets no replacement
+const DefaultReplacement = "[REDACTED]"
+
+// Rule redacts the values at Path in the JSON request bodies, or the text matching Pattern in the request bodies and
+// in the contents of the logs
+type Rule struct {
+	// Path is a JSONPath such as $.user, $.messages[*].content or $..api_key, with the names, the indexes, the
+	// wildcards and the recursive descent
+	Path string `json:"path,omitempty"`
+	// Pattern is a regular expression, the replacement may refer to its groups such as $1
+	Pattern     string `json:"pattern,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+
+	path    []segment
+	pattern *regexp.Regexp
+}
+
+const (
+	segmentName = iota
+	segmentIndex
+	segmentWildcard
+	segmentDescendant // a name at any depth
+)
+
+type segment struct {
+	kind  int
+	name  string
+	index int
+}
+
+// parsePath parses the JSONPath into its segments
+func parsePath(path string) ([]segment, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("path %q must start with $", path)
+	}
+	var segments []segment
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			name, tail := cutName(rest[2:])
+			if name == "" || name == "*" {
+				return nil, fmt.Errorf("path %q must have a name after ..", path)
+			}
+			segments = append(segments, segment{kind: segmentDescendant, name: name})
+			rest = tail
+		case rest[0] == '.':
+			name, tail := cutName(rest[1:])
+			if name == "" {
+				return nil, fmt.Errorf("path %q must have a name after .", path)
+			}
+			if name == "*" {
+				segments = append(segments, segment{kind: segmentWildcard})
+			} else {
+				segments = append(segments, segment{kind: segmentName, name: name})
+			}
+			rest = tail
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %q has an unclosed [", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if inner == "*" {
+				segments = append(segments, segment{kind: segmentWildcard})
+			} else if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				segments = append(segments, segment{kind: segmentName, name: inner[1 : len(inner)-1]})
+			} else if index, err := strconv.Atoi(inner); err == nil && index >= 0 {
+				segments = append(segments, segment{kind: segmentIndex, index: index})
+			} else {
+				return nil, fmt.Errorf("path %q has an invalid [%s]", path, inner)
+			}
+		default:
+			return nil, fmt.Errorf("path %q has an unexpected %q", path, rest[0])
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("path %q selects the whole body", path)
+	}
+	return segments, nil
+}
+
+func cutName(s string) (string, string) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
+
+// compile checks the rule and compiles its path or its pattern
+func (r *Rule) compile() error {
+	if (r.Path == "") == (r.Pattern == "") {
+		return fmt.Errorf("either path or pattern must be set")
+	}
+	var err error
+	if r.Path != "" {
+		r.path, err = parsePath(r.Path)
+		return err
+	}
+	r.pattern, err = regexp.Compile(r.Pattern)
+	return err
+}
+
+func (r *Rule) replacement() string {
+	if r.Replacement == "" {
+		return DefaultReplacement
+	}
+	return r.Replacement
+}
+
+var logRedactionRulesLock sync.RWMutex
+var LogRedactionRules []*Rule
+
+func LogRedactionRules2JSONString() string {
+	logRedactionRulesLock.RLock()
+	defer logRedactionRulesLock.RUnlock()
+	if LogRedactionRules == nil {
+		return "[]"
+	}
+	jsonBytes, err := json.Marshal(LogRedactionRules)
+	if err != nil {
+		logger.SysError("error marshalling log redaction rules: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateLogRedactionRulesByJSONString(jsonStr string) error {
+	var rules []*Rule
+	if err := json.Unmarshal([]byte(jsonStr), &rules); err != nil {
+		return err
+	}
+	for i, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	logRedactionRulesLock.Lock()
+	defer logRedactionRulesLock.Unlock()
+	LogRedactionRules = rules
+	return nil
+}
+
+func getRules() []*Rule {
+	logRedactionRulesLock.RLock()
+	defer logRedactionRulesLock.RUnlock()
+	return LogRedactionRules
+}
+
+// Text redacts the text matching the patterns of the rules, such as the content of a log
+func Text(text string) string {
+	for _, rule := range getRules() {
+		if rule.pattern != nil {
+			text = rule.pattern.ReplaceAllString(text, rule.replacement())
+		}
+	}
+	return text
+}
+
+// Body redacts the values at the paths of the rules and the strings matching their patterns in a JSON body, the
+// other bodies, such as the multipart forms, only have the text matching the patterns redacted
+func Body(body []byte) []byte {
+	rules := getRules()
+	if len(rules) == 0 {
+		return body
+	}
+	if !json.Valid(body) {
+		return []byte(Text(string(body)))
+	}
+	for _, rule := range rules {
+		if rule.path != nil {
+			replacement, _ := json.Marshal(rule.replacement())
+			body = redactPath(body, rule.path, replacement)
+		} else {
+			body = redactStrings(body, rule)
+		}
+	}
+	return body
+}
+
+// eachChild replaces every child of the object or the array by the one returned for it, other values are returned
+// as they are
+func eachChild(raw []byte, edit func(key string, index int, value []byte) []byte) []byte {
+	switch firstByte(raw) {
+	case '{':
+		object, err := jsonedit.Parse(raw)
+		if err != nil {
+			return raw
+		}
+		object.Edit(func(key string, value json.RawMessage) json.RawMessage {
+			return edit(key, -1, value)
+		})
+		return object.Bytes()
+	case '[':
+		var elements []json.RawMessage
+		if json.Unmarshal(raw, &elements) != nil {
+			return raw
+		}
+		changed := false
+		for i, element := range elements {
+			if edited := edit("", i, element); string(edited) != string(element) {
+				elements[i] = edited
+				changed = true
+			}
+		}
+		if !changed {
+			return raw
+		}
+		edited, err := json.Marshal(elements)
+		if err != nil {
+			return raw
+		}
+		return edited
+	}
+	return raw
+}
+
+func firstByte(raw []byte) byte {
+	for _, b := range raw {
+		if b != ' ' && b != '\t' && b != '\n' && b != '\r' {
+			return b
+		}
+	}
+	return 0
+}
+
+func redactPath(raw []byte, path []segment, replacement []byte) []byte {
+	if len(path) == 0 {
+		return replacement
+	}
+	current, rest := path[0], path[1:]
+	isObject := firstByte(raw) == '{'
+	return eachChild(raw, func(key string, index int, value []byte) []byte {
+		switch current.kind {
+		case segmentName:
+			if isObject && key == current.name {
+				return redactPath(value, rest, replacement)
+			}
+		case segmentIndex:
+			if !isObject && index == current.index {
+				return redactPath(value, rest, replacement)
+			}
+		case segmentWildcard:
+			return redactPath(value, rest, replacement)
+		case segmentDescendant:
+			value = redactPath(value, path, replacement)
+			if isObject && key == current.name {
+				return redactPath(value, rest, replacement)
+			}
+			return value
+		}
+		return value
+	})
+}
+
+// redactStrings redacts the text matching the pattern of the rule in the strings of the JSON value
+func redactStrings(raw []byte, rule *Rule) []byte {
+	if firstByte(raw) == '"' {
+		var text string
+		if json.Unmarshal(raw, &text) != nil {
+			return raw
+		}
+		redacted := rule.pattern.ReplaceAllString(text, rule.replacement())
+		if redacted == text {
+			return raw
+		}
+		encoded, _ := json.Marshal(redacted)
+		return encoded
+	}
+	return eachChild(raw, func(key string, index int, value []byte) []byte {
+		return redactStrings(value, rule)
+	})
+}
@@ -0,0 +1,43 @@
+package redact
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRedact(t *testing.T) {
+	Convey("UpdateLogRedactionRulesByJSONString", t, func() {
+		for _, rules := range []string{
+			`[{}]`,
+			`[{"path":"$.a","pattern":"b"}]`,
+			`[{"path":"a"}]`,
+			`[{"path":"$"}]`,
+			`[{"path":"$.a[x]"}]`,
+			`[{"path":"$..*"}]`,
+			`[{"pattern":"("}]`,
+		} {
+			So(UpdateLogRedactionRulesByJSONString(rules), ShouldNotBeNil)
+		}
+		So(LogRedactionRules2JSONString(), ShouldEqual, "[]")
+	})
+
+	Convey("Body and Text", t, func() {
+		So(UpdateLogRedactionRulesByJSONString(`[
+			{"path": "$.user"},
+			{"path": "$.messages[*].content", "replacement": "***"},
+			{"path": "$..api_key"},
+			{"path": "$['metadata'].tags[1]"},
+			{"pattern": "sk-[A-Za-z0-9]{8,}", "replacement": "sk-***"}
+		]`), ShouldBeNil)
+		defer func() { _ = UpdateLogRedactionRulesByJSONString(`[]`) }()
+
+		body := `{"model": "gpt-4o", "user": "alice@example.com", "messages": [{"role": "user", "content": [{"type": "text", "text": "hi"}]}, {"role": "assistant", "content": "hello"}], ` +
+			`"tools": [{"config": {"api_key": "secret", "nested": {"api_key": 1}}}], "metadata": {"tags": ["a", "b"], "note": "key sk-abcdefgh1234"}}`
+		So(string(Body([]byte(body))), ShouldEqual, `{"model": "gpt-4o","user":"[REDACTED]","messages":[{"role":"user","content":"***"},{"role":"assistant","content":"***"}],`+
+			`"tools":[{"config":{"api_key":"[REDACTED]","nested":{"api_key":"[REDACTED]"}}}],"metadata":{"tags":["a","[REDACTED]"],"note":"key sk-***"}}`)
+		So(string(Body([]byte(`{"model": "gpt-4o"}`))), ShouldEqual, `{"model": "gpt-4o"}`)
+		So(string(Body([]byte("--boundary\r\nuser sk-abcdefgh1234\r\n"))), ShouldEqual, "--boundary\r\nuser sk-***\r\n")
+		So(Text("调用失败：sk-abcdefgh1234 无效"), ShouldEqual, "调用失败：sk-*** 无效")
+	})
+}
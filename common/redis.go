@@ -13,6 +13,9 @@ import (
 var RDB redis.Cmdable
 var RedisEnabled = true
 
+// rdbClient is the same client as RDB, kept for pub/sub which is not part of redis.Cmdable
+var rdbClient redis.UniversalClient
+
 // InitRedisClient This function is called after init()
 func InitRedisClient() (err error) {
 	if os.Getenv("REDIS_CONN_STRING") == "" {
@@ -32,16 +35,17 @@ func InitRedisClient() (err error) {
 		if err != nil {
 			logger.FatalLog("failed to parse Redis connection string: " + err.Error())
 		}
-		RDB = redis.NewClient(opt)
+		rdbClient = redis.NewClient(opt)
 	} else {
 		// cluster mode
 		logger.SysLog("Redis cluster mode enabled")
-		RDB = redis.NewUniversalClient(&redis.UniversalOptions{
+		rdbClient = redis.NewUniversalClient(&redis.UniversalOptions{
 			Addrs:      strings.Split(redisConnString, ","),
 			Password:   os.Getenv("REDIS_PASSWORD"),
 			MasterName: os.Getenv("REDIS_MASTER_NAME"),
 		})
 	}
+	RDB = rdbClient
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -65,6 +69,12 @@ func RedisSet(key string, value string, expiration time.Duration) error {
 	return RDB.Set(ctx, key, value, expiration).Err()
 }
 
+// RedisSetNX sets the key only if it does not exist, and reports whether it is set
+func RedisSetNX(key string, value string, expiration time.Duration) (bool, error) {
+	ctx := context.Background()
+	return RDB.SetNX(ctx, key, value, expiration).Result()
+}
+
 func RedisGet(key string) (string, error) {
 	ctx := context.Background()
 	return RDB.Get(ctx, key).Result()
@@ -79,3 +89,13 @@ func RedisDecrease(key string, value int64) error {
 	ctx := context.Background()
 	return RDB.DecrBy(ctx, key, value).Err()
 }
+
+func RedisPublish(channel string, message string) error {
+	ctx := context.Background()
+	return RDB.Publish(ctx, channel, message).Err()
+}
+
+func RedisSubscribe(channel string) *redis.PubSub {
+	ctx := context.Background()
+	return rdbClient.Subscribe(ctx, channel)
+}
@@ -7,11 +7,46 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
 )
 
+// Filter rewrites the data of the events before they are written, it can hold back or add events
+type Filter interface {
+	Filter(data string) []string
+}
+
 func StringData(c *gin.Context, str string) {
 	str = strings.TrimPrefix(str, "data: ")
 	str = strings.TrimSuffix(str, "\r")
+	data := []string{str}
+	// the injected faults break the events as filtered, as the network would
+	for _, key := range []string{ctxkey.StreamFilter, ctxkey.StreamFault} {
+		f, ok := c.Get(key)
+		if !ok {
+			continue
+		}
+		var filtered []string
+		for _, d := range data {
+			filtered = append(filtered, f.(Filter).Filter(d)...)
+		}
+		data = filtered
+	}
+	for _, d := range data {
+		writeData(c, d)
+	}
+}
+
+func writeData(c *gin.Context, str string) {
+	if c.GetBool(ctxkey.HoldStreamDone) {
+		// the relay ends the stream itself, after the usage is known
+		if str == "[DONE]" {
+			c.Set(ctxkey.StreamDoneHeld, true)
+			return
+		}
+		if strings.Contains(str, `"usage":{`) {
+			c.Set(ctxkey.StreamUsageSent, true)
+		}
+	}
 	c.Render(-1, common.CustomEvent{Data: "data: " + str})
 	c.Writer.Flush()
 }
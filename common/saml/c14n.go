@@ -0,0 +1,108 @@
+package saml
+
+import (
+	"sort"
+	"strings"
+)
+
+// canonicalize serializes the element with the exclusive XML canonicalization without comments, the namespaces
+// are declared where they are visibly used, and also where those of inclusivePrefixes are in scope, "#default"
+// being the default namespace. The element exclude and its descendants are left out, it is the signature of an enveloped one
+func canonicalize(element *Element, inclusivePrefixes []string, exclude *Element) []byte {
+	var builder strings.Builder
+	inclusive := make(map[string]bool, len(inclusivePrefixes))
+	for _, prefix := range inclusivePrefixes {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		inclusive[prefix] = true
+	}
+	writeCanonical(&builder, element, map[string]string{"": ""}, inclusive, exclude)
+	return []byte(builder.String())
+}
+
+func writeCanonical(builder *strings.Builder, element *Element, rendered map[string]string, inclusive map[string]bool, exclude *Element) {
+	used := map[string]bool{element.Prefix: true}
+	for _, attr := range element.Attrs {
+		if attr.Prefix != "" {
+			used[attr.Prefix] = true
+		}
+	}
+	for prefix := range inclusive {
+		if _, ok := element.namespaces[prefix]; ok {
+			used[prefix] = true
+		}
+	}
+	var prefixes []string
+	for prefix := range used {
+		if prefix == "xml" {
+			continue
+		}
+		value, ok := rendered[prefix]
+		if !ok || value != element.namespaces[prefix] {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Strings(prefixes)
+	if len(prefixes) > 0 {
+		inherited := rendered
+		rendered = make(map[string]string, len(inherited)+len(prefixes))
+		for k, v := range inherited {
+			rendered[k] = v
+		}
+	}
+
+	builder.WriteByte('<')
+	builder.WriteString(qualifiedName(element.Prefix, element.Local))
+	for _, prefix := range prefixes {
+		value := element.namespaces[prefix]
+		rendered[prefix] = value
+		if prefix == "" {
+			builder.WriteString(" xmlns=\"")
+		} else {
+			builder.WriteString(" xmlns:" + prefix + "=\"")
+		}
+		builder.WriteString(escapeAttr(value))
+		builder.WriteByte('"')
+	}
+	attrs := append([]Attr(nil), element.Attrs...)
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].Space != attrs[j].Space {
+			return attrs[i].Space < attrs[j].Space
+		}
+		return attrs[i].Local < attrs[j].Local
+	})
+	for _, attr := range attrs {
+		builder.WriteString(" " + qualifiedName(attr.Prefix, attr.Local) + "=\"")
+		builder.WriteString(escapeAttr(attr.Value))
+		builder.WriteByte('"')
+	}
+	builder.WriteByte('>')
+	for _, child := range element.Children {
+		if child.Element == nil {
+			builder.WriteString(escapeText(child.Text))
+		} else if child.Element != exclude {
+			writeCanonical(builder, child.Element, rendered, inclusive, exclude)
+		}
+	}
+	builder.WriteString("</" + qualifiedName(element.Prefix, element.Local) + ">")
+}
+
+func qualifiedName(prefix string, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+var textReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+
+var attrReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", "\"", "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+
+func escapeText(s string) string {
+	return textReplacer.Replace(s)
+}
+
+func escapeAttr(s string) string {
+	return attrReplacer.Replace(s)
+}
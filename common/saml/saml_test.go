@@ -0,0 +1,223 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCanonicalize(t *testing.T) {
+	Convey("Canonicalize", t, func() {
+		// the example of the exclusive canonicalization specification
+		root, err := parseXML([]byte(`<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org"><n1:elem2 xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"/></n1:elem2></n0:local>`))
+		So(err, ShouldBeNil)
+		So(string(canonicalize(root.Children[0].Element, nil, nil)), ShouldEqual,
+			`<n1:elem2 xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"></n3:stuff></n1:elem2>`)
+		So(string(canonicalize(root.Children[0].Element, []string{"n0"}, nil)), ShouldEqual,
+			`<n1:elem2 xmlns:n0="foo:bar" xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"></n3:stuff></n1:elem2>`)
+
+		root, err = parseXML([]byte("<a xmlns=\"urn:a\" xmlns:b=\"urn:b\" b:x=\"1\" y='&quot;\t' a=\"3\"><c xmlns=\"\">1 &lt; 2 &amp;&gt;</c><!-- comment --><b:d/></a>"))
+		So(err, ShouldBeNil)
+		So(string(canonicalize(root, nil, nil)), ShouldEqual,
+			`<a xmlns="urn:a" xmlns:b="urn:b" a="3" y="&quot;&#x9;" b:x="1"><c xmlns="">1 &lt; 2 &amp;&gt;</c><b:d></b:d></a>`)
+	})
+
+	Convey("Parse", t, func() {
+		_, err := parseXML([]byte(`<!DOCTYPE a [<!ENTITY e "x">]><a>&e;</a>`))
+		So(err, ShouldNotBeNil)
+		_, err = parseXML([]byte(`<a><b></a></b>`))
+		So(err, ShouldNotBeNil)
+		_, err = parseXML([]byte(`<p:a/>`))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+const (
+	testEntityId    = "https://gateway.example.com/api/saml/metadata"
+	testACSURL      = "https://gateway.example.com/api/saml/acs"
+	testIdPEntityId = "https://idp.example.com"
+)
+
+func newTestCertificate() (*rsa.PrivateKey, *x509.Certificate) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	certificate, _ := x509.ParseCertificate(der)
+	return key, certificate
+}
+
+// sign fills the digest and the signature value of the signature of the element with the given ID
+func sign(document string, id string, key *rsa.PrivateKey) string {
+	find := func(document string) *Element {
+		root, _ := parseXML([]byte(document))
+		var found *Element
+		root.walk(func(element *Element) {
+			if element.Attr("ID") == id {
+				found = element
+			}
+		})
+		return found
+	}
+	element := find(document)
+	signature := element.ChildElement(dsigNamespace, "Signature")
+	digest := sha256.Sum256(canonicalize(element, nil, signature))
+	document = strings.Replace(document, "DIGEST-"+id, base64.StdEncoding.EncodeToString(digest[:]), 1)
+	signedInfo := find(document).ChildElement(dsigNamespace, "Signature").ChildElement(dsigNamespace, "SignedInfo")
+	hashed := sha256.Sum256(canonicalize(signedInfo, nil, nil))
+	value, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	return strings.Replace(document, "SIGNATURE-"+id, base64.StdEncoding.EncodeToString(value), 1)
+}
+
+func signatureOf(id string) string {
+	return `<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo>` +
+		`<ds:CanonicalizationMethod Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"/>` +
+		`<ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"/>` +
+		`<ds:Reference URI="#` + id + `"><ds:Transforms>` +
+		`<ds:Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"/>` +
+		`<ds:Transform Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"/></ds:Transforms>` +
+		`<ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"/>` +
+		`<ds:DigestValue>DIGEST-` + id + `</ds:DigestValue></ds:Reference></ds:SignedInfo>` +
+		`<ds:SignatureValue>SIGNATURE-` + id + `</ds:SignatureValue></ds:Signature>`
+}
+
+func assertionOf(id string, nameId string, audience string, now time.Time) string {
+	notOnOrAfter := now.Add(5 * time.Minute).UTC().Format(time.RFC3339)
+	return `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="` + id + `" Version="2.0">
+  <saml:Issuer>` + testIdPEntityId + `</saml:Issuer>` + signatureOf(id) + `
+  <saml:Subject>
+    <saml:NameID>` + nameId + `</saml:NameID>
+    <saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">
+      <saml:SubjectConfirmationData InResponseTo="req1" NotOnOrAfter="` + notOnOrAfter + `" Recipient="` + testACSURL + `"/>
+    </saml:SubjectConfirmation>
+  </saml:Subject>
+  <saml:Conditions NotBefore="` + now.Add(-time.Minute).UTC().Format(time.RFC3339) + `" NotOnOrAfter="` + notOnOrAfter + `">
+    <saml:AudienceRestriction><saml:Audience>` + audience + `</saml:Audience></saml:AudienceRestriction>
+  </saml:Conditions>
+  <saml:AttributeStatement>
+    <saml:Attribute Name="urn:oid:0.9.2342.19200300.100.1.3" FriendlyName="mail"><saml:AttributeValue>alice@example.com</saml:AttributeValue></saml:Attribute>
+    <saml:Attribute Name="groups"><saml:AttributeValue>staff</saml:AttributeValue><saml:AttributeValue>admins</saml:AttributeValue></saml:Attribute>
+  </saml:AttributeStatement>
+</saml:Assertion>`
+}
+
+func responseOf(assertions ...string) string {
+	return `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="resp1" Version="2.0" InResponseTo="req1" Destination="` + testACSURL + `">
+  <saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">` + testIdPEntityId + `</saml:Issuer>
+  <samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>
+  ` + strings.Join(assertions, "\n") + `
+</samlp:Response>`
+}
+
+func TestParseResponse(t *testing.T) {
+	key, certificate := newTestCertificate()
+	_, otherCertificate := newTestCertificate()
+	sp := &ServiceProvider{
+		EntityId:       testEntityId,
+		ACSURL:         testACSURL,
+		IdPEntityId:    testIdPEntityId,
+		IdPSSOURL:      "https://idp.example.com/sso?tenant=1",
+		IdPCertificate: certificate,
+		ClockSkew:      time.Minute,
+	}
+	now := time.Now()
+	pending := map[string]bool{}
+	consume := func(id string) bool {
+		ok := pending[id]
+		delete(pending, id)
+		return ok
+	}
+	parse := func(document string) (*Identity, error) {
+		pending["req1"] = true
+		return sp.ParseResponse(base64.StdEncoding.EncodeToString([]byte(document)), consume, now)
+	}
+	signed := sign(responseOf(assertionOf("a1", "alice", testEntityId, now)), "a1", key)
+
+	Convey("Signed assertion", t, func() {
+		identity, err := parse(signed)
+		So(err, ShouldBeNil)
+		So(identity.NameID, ShouldEqual, "alice")
+		So(identity.Attribute("mail"), ShouldEqual, "alice@example.com")
+		So(identity.Attribute("urn:oid:0.9.2342.19200300.100.1.3"), ShouldEqual, "alice@example.com")
+		So(identity.Attributes["groups"], ShouldResemble, []string{"staff", "admins"})
+
+		// the request is answered once
+		_, err = sp.ParseResponse(base64.StdEncoding.EncodeToString([]byte(signed)), consume, now)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Signed response", t, func() {
+		document := strings.Replace(responseOf(assertionOf("a1", "alice", testEntityId, now)),
+			`<samlp:Status>`, signatureOf("resp1")+`<samlp:Status>`, 1)
+		document = strings.Replace(document, signatureOf("a1"), "", 1)
+		_, err := parse(sign(document, "resp1", key))
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Rejected responses", t, func() {
+		unsigned := strings.Replace(responseOf(assertionOf("a1", "alice", testEntityId, now)), signatureOf("a1"), "", 1)
+		_, err := parse(unsigned)
+		So(err, ShouldNotBeNil)
+
+		_, err = parse(strings.Replace(signed, "<saml:NameID>alice", "<saml:NameID>admin", 1))
+		So(err, ShouldNotBeNil)
+
+		sp.IdPCertificate = otherCertificate
+		_, err = parse(signed)
+		So(err, ShouldNotBeNil)
+		sp.IdPCertificate = nil
+		_, err = parse(signed)
+		So(err, ShouldNotBeNil)
+		sp.IdPCertificate = certificate
+
+		_, err = parse(sign(responseOf(assertionOf("a1", "alice", "https://other.example.com", now)), "a1", key))
+		So(err, ShouldNotBeNil)
+
+		pending["req1"] = true
+		_, err = sp.ParseResponse(base64.StdEncoding.EncodeToString([]byte(signed)), consume, now.Add(time.Hour))
+		So(err, ShouldNotBeNil)
+
+		// wrapping the signed assertion along with an unsigned one
+		evil := strings.Replace(assertionOf("a2", "admin", testEntityId, now), signatureOf("a2"), "", 1)
+		signedAssertion := sign(assertionOf("a1", "alice", testEntityId, now), "a1", key)
+		_, err = parse(responseOf(signedAssertion, evil))
+		So(err, ShouldNotBeNil)
+		_, err = parse(responseOf(strings.Replace(evil, "</saml:Assertion>", `<saml:Advice>`+signedAssertion+`</saml:Advice></saml:Assertion>`, 1)))
+		So(err, ShouldNotBeNil)
+		_, err = parse(responseOf(strings.Replace(evil, `ID="a2"`, `ID="a1"`, 1), signedAssertion))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("AuthnRequestURL", t, func() {
+		location, err := sp.AuthnRequestURL("req1", "state", now)
+		So(err, ShouldBeNil)
+		So(strings.HasPrefix(location, "https://idp.example.com/sso?tenant=1&"), ShouldBeTrue)
+		u, _ := url.Parse(location)
+		So(u.Query().Get("RelayState"), ShouldEqual, "state")
+		compressed, _ := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+		request, _ := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+		root, err := parseXML(request)
+		So(err, ShouldBeNil)
+		So(root.Local, ShouldEqual, "AuthnRequest")
+		So(root.Attr("ID"), ShouldEqual, "req1")
+		So(root.Attr("AssertionConsumerServiceURL"), ShouldEqual, testACSURL)
+	})
+}
@@ -0,0 +1,176 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+const (
+	dsigNamespace       = "http://www.w3.org/2000/09/xmldsig#"
+	excC14NAlgorithm    = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	envelopedAlgorithm  = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	rsaSHA256Algorithm  = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	rsaSHA512Algorithm  = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	sha256Algorithm     = "http://www.w3.org/2001/04/xmlenc#sha256"
+	sha512Algorithm     = "http://www.w3.org/2001/04/xmlenc#sha512"
+	inclusiveNamespaces = "InclusiveNamespaces"
+)
+
+var signatureHashes = map[string]crypto.Hash{
+	rsaSHA256Algorithm: crypto.SHA256,
+	rsaSHA512Algorithm: crypto.SHA512,
+}
+
+var digestHashes = map[string]crypto.Hash{
+	sha256Algorithm: crypto.SHA256,
+	sha512Algorithm: crypto.SHA512,
+}
+
+// ParseCertificate parses the certificate of the IdP, either PEM encoded or as the base64 of its DER
+// like in the metadata of the IdPs
+func ParseCertificate(data string) (*x509.Certificate, error) {
+	data = strings.TrimSpace(data)
+	var der []byte
+	if block, _ := pem.Decode([]byte(data)); block != nil {
+		der = block.Bytes
+	} else {
+		var err error
+		if der, err = base64.StdEncoding.DecodeString(removeSpaces(data)); err != nil {
+			return nil, errors.New("invalid certificate")
+		}
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := certificate.PublicKey.(*rsa.PublicKey); !ok {
+		return nil, errors.New("only RSA certificates are supported")
+	}
+	return certificate, nil
+}
+
+func removeSpaces(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// inclusivePrefixes returns the prefixes of the InclusiveNamespaces child of the transform or of the canonicalization method
+func inclusivePrefixes(method *Element) []string {
+	for _, child := range method.Children {
+		if child.Element != nil && child.Element.Local == inclusiveNamespaces && child.Element.Space == excC14NAlgorithm {
+			return strings.Fields(child.Element.Attr("PrefixList"))
+		}
+	}
+	return nil
+}
+
+// verifySignature verifies the enveloped signature of the element with the certificate of the IdP, the certificates
+// in the signature are ignored. It returns false without error if the element is not signed. The signature must
+// reference the element itself by its ID, so that what is signed is what is read
+func verifySignature(element *Element, certificate *x509.Certificate) (bool, error) {
+	signatures := element.ChildElements(dsigNamespace, "Signature")
+	if len(signatures) == 0 {
+		return false, nil
+	}
+	if len(signatures) > 1 {
+		return false, errors.New("more than one signature")
+	}
+	signature := signatures[0]
+	signedInfo := signature.ChildElement(dsigNamespace, "SignedInfo")
+	if signedInfo == nil {
+		return false, errors.New("missing SignedInfo")
+	}
+	c14nMethod := signedInfo.ChildElement(dsigNamespace, "CanonicalizationMethod")
+	if c14nMethod == nil || c14nMethod.Attr("Algorithm") != excC14NAlgorithm {
+		return false, errors.New("unsupported canonicalization method")
+	}
+	signatureMethod := signedInfo.ChildElement(dsigNamespace, "SignatureMethod")
+	if signatureMethod == nil {
+		return false, errors.New("missing SignatureMethod")
+	}
+	signatureHash, ok := signatureHashes[signatureMethod.Attr("Algorithm")]
+	if !ok {
+		return false, fmt.Errorf("unsupported signature method %s", signatureMethod.Attr("Algorithm"))
+	}
+	references := signedInfo.ChildElements(dsigNamespace, "Reference")
+	if len(references) != 1 {
+		return false, errors.New("the signature must have exactly one reference")
+	}
+	reference := references[0]
+	id := element.Attr("ID")
+	if id == "" || reference.Attr("URI") != "#"+id {
+		return false, errors.New("the signature does not reference the signed element")
+	}
+
+	var prefixes []string
+	enveloped := false
+	if transforms := reference.ChildElement(dsigNamespace, "Transforms"); transforms != nil {
+		for _, transform := range transforms.ChildElements(dsigNamespace, "Transform") {
+			switch transform.Attr("Algorithm") {
+			case envelopedAlgorithm:
+				enveloped = true
+			case excC14NAlgorithm:
+				prefixes = inclusivePrefixes(transform)
+			default:
+				return false, fmt.Errorf("unsupported transform %s", transform.Attr("Algorithm"))
+			}
+		}
+	}
+	if !enveloped {
+		return false, errors.New("the signature is not enveloped")
+	}
+	digestMethod := reference.ChildElement(dsigNamespace, "DigestMethod")
+	if digestMethod == nil {
+		return false, errors.New("missing DigestMethod")
+	}
+	digestHash, ok := digestHashes[digestMethod.Attr("Algorithm")]
+	if !ok {
+		return false, fmt.Errorf("unsupported digest method %s", digestMethod.Attr("Algorithm"))
+	}
+	digestValue := reference.ChildElement(dsigNamespace, "DigestValue")
+	if digestValue == nil {
+		return false, errors.New("missing DigestValue")
+	}
+	expectedDigest, err := base64.StdEncoding.DecodeString(removeSpaces(digestValue.Text()))
+	if err != nil {
+		return false, errors.New("invalid DigestValue")
+	}
+	hash := digestHash.New()
+	hash.Write(canonicalize(element, prefixes, signature))
+	if subtle.ConstantTimeCompare(hash.Sum(nil), expectedDigest) != 1 {
+		return false, errors.New("the digest of the signed element does not match")
+	}
+
+	signatureValue := signature.ChildElement(dsigNamespace, "SignatureValue")
+	if signatureValue == nil {
+		return false, errors.New("missing SignatureValue")
+	}
+	value, err := base64.StdEncoding.DecodeString(removeSpaces(signatureValue.Text()))
+	if err != nil {
+		return false, errors.New("invalid SignatureValue")
+	}
+	hash = signatureHash.New()
+	hash.Write(canonicalize(signedInfo, inclusivePrefixes(c14nMethod), nil))
+	publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return false, errors.New("the certificate of the IdP has no RSA public key")
+	}
+	if err = rsa.VerifyPKCS1v15(publicKey, signatureHash, hash.Sum(nil), value); err != nil {
+		return false, errors.New("invalid signature")
+	}
+	return true, nil
+}
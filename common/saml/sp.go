@@ -0,0 +1,288 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	assertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+	protocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	metadataNamespace  = "urn:oasis:names:tc:SAML:2.0:metadata"
+	postBinding        = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	successStatus      = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	bearerMethod       = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	unspecifiedFormat  = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+)
+
+// MaxResponseSize is the largest SAMLResponse accepted, base64 encoded
+const MaxResponseSize = 1 << 20
+
+// ServiceProvider is the gateway as a SAML service provider, it sends the authentication requests to the IdP
+// with the HTTP-Redirect binding and receives the responses with the HTTP-POST binding
+type ServiceProvider struct {
+	EntityId       string
+	ACSURL         string
+	IdPEntityId    string
+	IdPSSOURL      string
+	IdPCertificate *x509.Certificate
+	// ClockSkew is the difference tolerated between the clocks of the IdP and of the gateway
+	ClockSkew time.Duration
+}
+
+// Identity is what the IdP asserts about the authenticated user
+type Identity struct {
+	NameID string
+	// Attributes are the values of the attributes by name, and also by friendly name if any
+	Attributes map[string][]string
+}
+
+// Attribute returns the first value of the attribute, "" if it is missing
+func (identity *Identity) Attribute(name string) string {
+	if values := identity.Attributes[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func escape(s string) string {
+	var buffer bytes.Buffer
+	_ = xml.EscapeText(&buffer, []byte(s))
+	return buffer.String()
+}
+
+// Metadata returns the metadata of the service provider to be imported by the IdP
+func (sp *ServiceProvider) Metadata() []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<md:EntityDescriptor xmlns:md="` + metadataNamespace + `" entityID="` + escape(sp.EntityId) + `">
+  <md:SPSSODescriptor AuthnRequestsSigned="false" WantAssertionsSigned="true" protocolSupportEnumeration="` + protocolNamespace + `">
+    <md:NameIDFormat>` + unspecifiedFormat + `</md:NameIDFormat>
+    <md:AssertionConsumerService Binding="` + postBinding + `" Location="` + escape(sp.ACSURL) + `" index="0" isDefault="true"/>
+  </md:SPSSODescriptor>
+</md:EntityDescriptor>
+`)
+}
+
+// AuthnRequestURL returns the url of the IdP the user is redirected to for signing in, id is the ID of the
+// request which the response must be in response to, relayState is returned as is with the response
+func (sp *ServiceProvider) AuthnRequestURL(id string, relayState string, now time.Time) (string, error) {
+	request := `<samlp:AuthnRequest xmlns:samlp="` + protocolNamespace + `" xmlns:saml="` + assertionNamespace + `"` +
+		` ID="` + escape(id) + `" Version="2.0" IssueInstant="` + now.UTC().Format(time.RFC3339) + `"` +
+		` Destination="` + escape(sp.IdPSSOURL) + `" AssertionConsumerServiceURL="` + escape(sp.ACSURL) + `"` +
+		` ProtocolBinding="` + postBinding + `">` +
+		`<saml:Issuer>` + escape(sp.EntityId) + `</saml:Issuer>` +
+		`<samlp:NameIDPolicy Format="` + unspecifiedFormat + `" AllowCreate="true"/>` +
+		`</samlp:AuthnRequest>`
+	var buffer bytes.Buffer
+	writer, err := flate.NewWriter(&buffer, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err = writer.Write([]byte(request)); err != nil {
+		return "", err
+	}
+	if err = writer.Close(); err != nil {
+		return "", err
+	}
+	query := url.Values{}
+	query.Set("SAMLRequest", base64.StdEncoding.EncodeToString(buffer.Bytes()))
+	if relayState != "" {
+		query.Set("RelayState", relayState)
+	}
+	separator := "?"
+	if strings.Contains(sp.IdPSSOURL, "?") {
+		separator = "&"
+	}
+	return sp.IdPSSOURL + separator + query.Encode(), nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("invalid time %s", value)
+	}
+	return t, nil
+}
+
+// ParseResponse validates the base64 encoded response posted by the IdP and returns the identity it asserts. consumeRequestId
+// tells whether the id is one of a request sent and not answered yet, and marks it as answered, so that
+// a response is accepted once and only in response to the requests of the gateway
+func (sp *ServiceProvider) ParseResponse(samlResponse string, consumeRequestId func(id string) bool, now time.Time) (*Identity, error) {
+	if sp.IdPCertificate == nil {
+		return nil, errors.New("the certificate of the IdP is not set")
+	}
+	if len(samlResponse) > MaxResponseSize {
+		return nil, errors.New("the response is too large")
+	}
+	data, err := base64.StdEncoding.DecodeString(removeSpaces(samlResponse))
+	if err != nil {
+		return nil, errors.New("the response is not base64 encoded")
+	}
+	response, err := parseXML(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	if response.Space != protocolNamespace || response.Local != "Response" {
+		return nil, errors.New("the document is not a response")
+	}
+	// the signatures reference the elements by ID, an ID used twice could make another element be read than the one signed
+	ids := make(map[string]bool)
+	duplicated := false
+	response.walk(func(element *Element) {
+		if id := element.Attr("ID"); id != "" {
+			duplicated = duplicated || ids[id]
+			ids[id] = true
+		}
+	})
+	if duplicated {
+		return nil, errors.New("duplicate ID in the response")
+	}
+
+	status := response.ChildElement(protocolNamespace, "Status")
+	if status == nil {
+		return nil, errors.New("missing status")
+	}
+	statusCode := status.ChildElement(protocolNamespace, "StatusCode")
+	if statusCode == nil || statusCode.Attr("Value") != successStatus {
+		message := ""
+		if statusMessage := status.ChildElement(protocolNamespace, "StatusMessage"); statusMessage != nil {
+			message = statusMessage.Text()
+		}
+		if statusCode != nil {
+			return nil, fmt.Errorf("the authentication failed: %s %s", statusCode.Attr("Value"), message)
+		}
+		return nil, errors.New("the authentication failed")
+	}
+	respSigned, err := verifySignature(response, sp.IdPCertificate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature of the response: %w", err)
+	}
+	if len(response.ChildElements(assertionNamespace, "EncryptedAssertion")) > 0 {
+		return nil, errors.New("encrypted assertions are not supported")
+	}
+	assertions := response.ChildElements(assertionNamespace, "Assertion")
+	if len(assertions) != 1 {
+		return nil, errors.New("the response must have exactly one assertion")
+	}
+	assertion := assertions[0]
+	assertionSigned, err := verifySignature(assertion, sp.IdPCertificate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature of the assertion: %w", err)
+	}
+	if !respSigned && !assertionSigned {
+		return nil, errors.New("neither the response nor the assertion is signed")
+	}
+
+	if destination := response.Attr("Destination"); destination != "" && destination != sp.ACSURL {
+		return nil, fmt.Errorf("unexpected destination %s", destination)
+	}
+	if issuer := response.ChildElement(assertionNamespace, "Issuer"); issuer != nil && issuer.Text() != sp.IdPEntityId {
+		return nil, fmt.Errorf("unexpected issuer %s", issuer.Text())
+	}
+	inResponseTo := response.Attr("InResponseTo")
+	if inResponseTo == "" || !consumeRequestId(inResponseTo) {
+		return nil, errors.New("the response is not in response to a pending request")
+	}
+
+	if issuer := assertion.ChildElement(assertionNamespace, "Issuer"); issuer == nil || issuer.Text() != sp.IdPEntityId {
+		return nil, errors.New("unexpected issuer of the assertion")
+	}
+	if err = sp.checkSubject(assertion, inResponseTo, now); err != nil {
+		return nil, err
+	}
+	if err = sp.checkConditions(assertion, now); err != nil {
+		return nil, err
+	}
+
+	result := &Identity{
+		NameID:     assertion.ChildElement(assertionNamespace, "Subject").ChildElement(assertionNamespace, "NameID").Text(),
+		Attributes: make(map[string][]string),
+	}
+	for _, statement := range assertion.ChildElements(assertionNamespace, "AttributeStatement") {
+		for _, attribute := range statement.ChildElements(assertionNamespace, "Attribute") {
+			var values []string
+			for _, value := range attribute.ChildElements(assertionNamespace, "AttributeValue") {
+				values = append(values, value.Text())
+			}
+			for _, name := range []string{attribute.Attr("Name"), attribute.Attr("FriendlyName")} {
+				if name != "" {
+					result.Attributes[name] = append(result.Attributes[name], values...)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// checkSubject checks the assertion identifies the user and can be presented by the user to the gateway
+func (sp *ServiceProvider) checkSubject(assertion *Element, inResponseTo string, now time.Time) error {
+	subject := assertion.ChildElement(assertionNamespace, "Subject")
+	if subject == nil {
+		return errors.New("missing subject")
+	}
+	if nameId := subject.ChildElement(assertionNamespace, "NameID"); nameId == nil || nameId.Text() == "" {
+		return errors.New("missing NameID")
+	}
+	for _, confirmation := range subject.ChildElements(assertionNamespace, "SubjectConfirmation") {
+		if confirmation.Attr("Method") != bearerMethod {
+			continue
+		}
+		data := confirmation.ChildElement(assertionNamespace, "SubjectConfirmationData")
+		if data == nil || data.Attr("Recipient") != sp.ACSURL {
+			continue
+		}
+		if id := data.Attr("InResponseTo"); id != "" && id != inResponseTo {
+			continue
+		}
+		notOnOrAfter, err := parseTime(data.Attr("NotOnOrAfter"))
+		if err != nil || !now.Add(-sp.ClockSkew).Before(notOnOrAfter) {
+			continue
+		}
+		return nil
+	}
+	return errors.New("no valid bearer subject confirmation")
+}
+
+// checkConditions checks the assertion is valid now and is intended for the gateway
+func (sp *ServiceProvider) checkConditions(assertion *Element, now time.Time) error {
+	conditions := assertion.ChildElement(assertionNamespace, "Conditions")
+	if conditions == nil {
+		return nil
+	}
+	if value := conditions.Attr("NotBefore"); value != "" {
+		notBefore, err := parseTime(value)
+		if err != nil {
+			return err
+		}
+		if now.Add(sp.ClockSkew).Before(notBefore) {
+			return errors.New("the assertion is not valid yet")
+		}
+	}
+	if value := conditions.Attr("NotOnOrAfter"); value != "" {
+		notOnOrAfter, err := parseTime(value)
+		if err != nil {
+			return err
+		}
+		if !now.Add(-sp.ClockSkew).Before(notOnOrAfter) {
+			return errors.New("the assertion has expired")
+		}
+	}
+	for _, restriction := range conditions.ChildElements(assertionNamespace, "AudienceRestriction") {
+		allowed := false
+		for _, audience := range restriction.ChildElements(assertionNamespace, "Audience") {
+			allowed = allowed || audience.Text() == sp.EntityId
+		}
+		if !allowed {
+			return errors.New("the assertion is not intended for the gateway")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// Element is an element of a parsed document, which keeps the prefixes and the namespace declarations
+// as written, as they are needed to canonicalize the signed elements
+type Element struct {
+	Prefix string
+	Local  string
+	// Space is the namespace of the element, resolved from its prefix
+	Space  string
+	Attrs  []Attr
+	Parent *Element
+	// Children are the elements and the texts of the element in order, a text is a child whose Element is nil
+	Children []Child
+	// namespaces are the namespaces in scope of the element by prefix, "" being the default namespace
+	namespaces map[string]string
+}
+
+type Attr struct {
+	Prefix string
+	Local  string
+	Space  string
+	Value  string
+}
+
+type Child struct {
+	Element *Element
+	Text    string
+}
+
+// parseXML parses the document into a tree of elements, the documents declaring a DTD are rejected as
+// they may define entities, the comments and the processing instructions are dropped
+func parseXML(data []byte) (*Element, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = true
+	var root, current *Element
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			if current == nil && root != nil {
+				return nil, errors.New("more than one root element")
+			}
+			element, err := newElement(token, current)
+			if err != nil {
+				return nil, err
+			}
+			if current == nil {
+				root = element
+			} else {
+				current.Children = append(current.Children, Child{Element: element})
+			}
+			current = element
+		case xml.EndElement:
+			if current == nil || token.Name.Space != current.Prefix || token.Name.Local != current.Local {
+				return nil, fmt.Errorf("unexpected end element %s", token.Name.Local)
+			}
+			current = current.Parent
+		case xml.CharData:
+			if current != nil {
+				current.Children = append(current.Children, Child{Text: string(token)})
+			} else if len(bytes.TrimSpace(token)) > 0 {
+				return nil, errors.New("text outside of the root element")
+			}
+		case xml.Directive:
+			return nil, errors.New("documents with a DTD are not supported")
+		}
+	}
+	if root == nil || current != nil {
+		return nil, errors.New("incomplete document")
+	}
+	return root, nil
+}
+
+func newElement(token xml.StartElement, parent *Element) (*Element, error) {
+	element := &Element{
+		Prefix:     token.Name.Space,
+		Local:      token.Name.Local,
+		Parent:     parent,
+		namespaces: map[string]string{"xml": xmlNamespace},
+	}
+	if parent != nil {
+		element.namespaces = parent.namespaces
+	}
+	copied := false
+	for _, attr := range token.Attr {
+		prefix := ""
+		switch {
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+		case attr.Name.Space == "xmlns":
+			prefix = attr.Name.Local
+			if prefix == "xml" || prefix == "xmlns" {
+				return nil, fmt.Errorf("invalid namespace prefix %s", prefix)
+			}
+		default:
+			continue
+		}
+		if !copied {
+			namespaces := make(map[string]string, len(element.namespaces)+1)
+			for k, v := range element.namespaces {
+				namespaces[k] = v
+			}
+			element.namespaces = namespaces
+			copied = true
+		}
+		element.namespaces[prefix] = attr.Value
+	}
+	space, ok := element.namespaces[element.Prefix]
+	if !ok && element.Prefix != "" {
+		return nil, fmt.Errorf("undeclared namespace prefix %s", element.Prefix)
+	}
+	element.Space = space
+	for _, attr := range token.Attr {
+		if (attr.Name.Space == "" && attr.Name.Local == "xmlns") || attr.Name.Space == "xmlns" {
+			continue
+		}
+		a := Attr{Prefix: attr.Name.Space, Local: attr.Name.Local, Value: attr.Value}
+		if a.Prefix != "" {
+			if a.Space, ok = element.namespaces[a.Prefix]; !ok {
+				return nil, fmt.Errorf("undeclared namespace prefix %s", a.Prefix)
+			}
+		}
+		for _, other := range element.Attrs {
+			if other.Space == a.Space && other.Local == a.Local {
+				return nil, fmt.Errorf("duplicate attribute %s", a.Local)
+			}
+		}
+		element.Attrs = append(element.Attrs, a)
+	}
+	return element, nil
+}
+
+// Attr returns the value of the attribute without namespace named local, "" if it is missing
+func (element *Element) Attr(local string) string {
+	for _, attr := range element.Attrs {
+		if attr.Space == "" && attr.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// ChildElements returns the child elements in the namespace space named local
+func (element *Element) ChildElements(space string, local string) []*Element {
+	var elements []*Element
+	for _, child := range element.Children {
+		if child.Element != nil && child.Element.Space == space && child.Element.Local == local {
+			elements = append(elements, child.Element)
+		}
+	}
+	return elements
+}
+
+// ChildElement returns the first child element in the namespace space named local, nil if there is none
+func (element *Element) ChildElement(space string, local string) *Element {
+	if elements := element.ChildElements(space, local); len(elements) > 0 {
+		return elements[0]
+	}
+	return nil
+}
+
+// Text returns the text of the element and of its descendants, without the surrounding spaces
+func (element *Element) Text() string {
+	var builder strings.Builder
+	var walk func(*Element)
+	walk = func(element *Element) {
+		for _, child := range element.Children {
+			if child.Element != nil {
+				walk(child.Element)
+			} else {
+				builder.WriteString(child.Text)
+			}
+		}
+	}
+	walk(element)
+	return strings.TrimSpace(builder.String())
+}
+
+// walk calls fn on the element and on all its descendants in document order
+func (element *Element) walk(fn func(*Element)) {
+	fn(element)
+	for _, child := range element.Children {
+		if child.Element != nil {
+			child.Element.walk(fn)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Package sentry reports events to Sentry, or to GlitchTip which speaks the same protocol, through the envelope
+// endpoint of the project of SentryDSN
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/random"
+)
+
+const (
+	LevelFatal   = "fatal"
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+type Frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+type Stacktrace struct {
+	Frames []Frame `json:"frames"`
+}
+
+type Exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Stacktrace *Stacktrace `json:"stacktrace,omitempty"`
+}
+
+type Exceptions struct {
+	Values []Exception `json:"values"`
+}
+
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+// TraceContext links the event to the distributed trace of the request
+type TraceContext struct {
+	TraceId string `json:"trace_id"`
+	SpanId  string `json:"span_id"`
+}
+
+type Event struct {
+	EventId     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Release     string            `json:"release"`
+	Environment string            `json:"environment,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	Fingerprint []string          `json:"fingerprint,omitempty"`
+	Exception   *Exceptions       `json:"exception,omitempty"`
+	Request     *Request          `json:"request,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]any    `json:"extra,omitempty"`
+	Contexts    map[string]any    `json:"contexts,omitempty"`
+}
+
+// NewEvent returns an event of the level tagged with the release and the request id of ctx
+func NewEvent(ctx context.Context, level string, message string) *Event {
+	event := &Event{
+		EventId:     random.GetUUID(),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339Nano),
+		Platform:    "go",
+		Level:       level,
+		Logger:      "one-api",
+		ServerName:  hostname(),
+		Release:     "one-api@" + common.Version,
+		Environment: config.SentryEnvironment,
+		Message:     message,
+		Tags:        map[string]string{},
+		Extra:       map[string]any{},
+	}
+	if requestId := helper.GetRequestID(ctx); requestId != "" {
+		event.Tags["request_id"] = requestId
+	}
+	return event
+}
+
+// SetException sets the exception of the event, with the stack of the caller after skipping skip frames
+func (e *Event) SetException(typ string, value string, skip int) {
+	e.Exception = &Exceptions{Values: []Exception{{Type: typ, Value: value, Stacktrace: stacktrace(skip + 1)}}}
+}
+
+// SetTrace links the event to the trace of a W3C traceparent header, the other values are ignored
+func (e *Event) SetTrace(traceparent string) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return
+	}
+	if _, err := hex.DecodeString(parts[1] + parts[2]); err != nil {
+		return
+	}
+	if e.Contexts == nil {
+		e.Contexts = map[string]any{}
+	}
+	e.Contexts["trace"] = TraceContext{TraceId: parts[1], SpanId: parts[2]}
+}
+
+func Enabled() bool {
+	return config.SentryDSN != ""
+}
+
+// Capture sends the event in the background, it does nothing if SentryDSN is not set
+func Capture(event *Event) {
+	dsn := config.SentryDSN
+	if dsn == "" {
+		return
+	}
+	go func() {
+		if err := send(dsn, event); err != nil {
+			logger.SysError("failed to report to sentry: " + err.Error())
+		}
+	}()
+}
+
+type parsedDSN struct {
+	endpoint  string
+	publicKey string
+}
+
+func parseDSN(dsn string) (*parsedDSN, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, errors.New("the DSN has no public key")
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if i < 0 || path[i+1:] == "" {
+		return nil, errors.New("the DSN has no project id")
+	}
+	endpoint := fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, path[:i], path[i+1:])
+	return &parsedDSN{endpoint: endpoint, publicKey: u.User.Username()}, nil
+}
+
+func send(dsn string, event *Event) error {
+	target, err := parseDSN(dsn)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	header, _ := json.Marshal(map[string]string{
+		"event_id": event.EventId,
+		"sent_at":  time.Now().UTC().Format(time.RFC3339Nano),
+		"dsn":      dsn,
+	})
+	item, _ := json.Marshal(map[string]any{"type": "event", "length": len(payload)})
+	var envelope bytes.Buffer
+	for _, line := range [][]byte{header, item, payload} {
+		envelope.Write(line)
+		envelope.WriteByte('\n')
+	}
+	req, err := http.NewRequest(http.MethodPost, target.endpoint, &envelope)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=one-api/%s, sentry_key=%s", common.Version, target.publicKey))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// stacktrace returns the frames of the caller, the oldest first as Sentry expects, without those of the runtime
+// which raised the panic
+func stacktrace(skip int) *Stacktrace {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var result []Frame
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			module, function := splitFunction(frame.Function)
+			result = append(result, Frame{
+				Function: function,
+				Module:   module,
+				Filename: relativePath(frame.File),
+				AbsPath:  frame.File,
+				Lineno:   frame.Line,
+				InApp:    strings.HasPrefix(module, "github.com/songquanpeng/one-api"),
+			})
+		}
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return &Stacktrace{Frames: result}
+}
+
+// splitFunction splits github.com/a/b/pkg.(*T).Method into its package and function
+func splitFunction(name string) (string, string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:slash+1+dot], name[slash+1+dot+1:]
+}
+
+// sourceRoot is the directory of the module when it was built, the files of the frames are given relative to it
+var sourceRoot = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return strings.TrimSuffix(file, "common/sentry/sentry.go")
+}()
+
+func relativePath(file string) string {
+	return strings.TrimPrefix(file, sourceRoot)
+}
+
+var hostOnce sync.Once
+var host string
+
+func hostname() string {
+	hostOnce.Do(func() {
+		host, _ = os.Hostname()
+	})
+	return host
+}
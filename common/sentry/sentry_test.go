@@ -0,0 +1,91 @@
+package sentry
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+func TestParseDSN(t *testing.T) {
+	Convey("parseDSN", t, func() {
+		dsn, err := parseDSN("https://abc@o1.ingest.sentry.io/42")
+		So(err, ShouldBeNil)
+		So(dsn.endpoint, ShouldEqual, "https://o1.ingest.sentry.io/api/42/envelope/")
+		So(dsn.publicKey, ShouldEqual, "abc")
+
+		dsn, err = parseDSN("http://key@glitchtip.example.com/sub/7/")
+		So(err, ShouldBeNil)
+		So(dsn.endpoint, ShouldEqual, "http://glitchtip.example.com/sub/api/7/envelope/")
+
+		for _, invalid := range []string{"https://sentry.io/42", "https://abc@sentry.io/", "://"} {
+			_, err = parseDSN(invalid)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestEvent(t *testing.T) {
+	Convey("SetTrace", t, func() {
+		event := NewEvent(context.Background(), LevelError, "")
+		event.SetTrace("00-invalid-00f067aa0ba902b7-01")
+		So(event.Contexts, ShouldBeNil)
+		event.SetTrace("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		So(event.Contexts["trace"], ShouldResemble, TraceContext{TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", SpanId: "00f067aa0ba902b7"})
+	})
+
+	Convey("SetException", t, func() {
+		event := NewEvent(context.Background(), LevelError, "")
+		event.SetException("panic", "boom", 0)
+		frames := event.Exception.Values[0].Stacktrace.Frames
+		last := frames[len(frames)-1]
+		So(last.Module, ShouldEqual, "github.com/songquanpeng/one-api/common/sentry")
+		So(last.Function, ShouldStartWith, "TestEvent.func")
+		So(last.Filename, ShouldEqual, "common/sentry/sentry_test.go")
+		So(last.InApp, ShouldBeTrue)
+	})
+
+	Convey("send", t, func() {
+		var path, auth string
+		var lines []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			auth = r.Header.Get("X-Sentry-Auth")
+			body, _ := io.ReadAll(r.Body)
+			scanner := bufio.NewScanner(strings.NewReader(string(body)))
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+		}))
+		defer server.Close()
+		dsn := strings.Replace(server.URL, "://", "://abc@", 1) + "/42"
+
+		ctx := context.WithValue(context.Background(), helper.RequestIdKey, "req-1")
+		event := NewEvent(ctx, LevelWarning, "spike")
+		So(send(dsn, event), ShouldBeNil)
+		So(path, ShouldEqual, "/api/42/envelope/")
+		So(auth, ShouldContainSubstring, "sentry_key=abc")
+		So(lines, ShouldHaveLength, 3)
+		var item struct {
+			Type   string `json:"type"`
+			Length int    `json:"length"`
+		}
+		So(json.Unmarshal([]byte(lines[1]), &item), ShouldBeNil)
+		So(item.Type, ShouldEqual, "event")
+		So(item.Length, ShouldEqual, len(lines[2]))
+		var sent Event
+		So(json.Unmarshal([]byte(lines[2]), &sent), ShouldBeNil)
+		So(sent.EventId, ShouldHaveLength, 32)
+		So(sent.Message, ShouldEqual, "spike")
+		So(sent.Release, ShouldStartWith, "one-api@")
+		So(sent.Tags["request_id"], ShouldEqual, "req-1")
+	})
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+const (
+	FileBackendLocal = "local"
+	FileBackendS3    = "s3"
+)
+
+var ErrFileNotFound = errors.New("file not found")
+
+// FileBackend keeps the content of the files uploaded through /v1/files, the keys are generated by us
+type FileBackend interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// GetFileBackend returns the backend named name, or nil when the files are relayed to the upstream instead
+func GetFileBackend(name string) FileBackend {
+	switch name {
+	case FileBackendLocal:
+		return localFileBackend{dir: config.FileStoragePath}
+	case FileBackendS3:
+		return s3FileBackend{prefix: "files/"}
+	}
+	return nil
+}
+
+// GetBackupBackend returns the backend named name for the backups, they are kept apart from the files
+func GetBackupBackend(name string) FileBackend {
+	switch name {
+	case FileBackendLocal:
+		return localFileBackend{dir: config.BackupPath}
+	case FileBackendS3:
+		return s3FileBackend{prefix: "backups/"}
+	}
+	return nil
+}
+
+type localFileBackend struct {
+	dir string
+}
+
+func (b localFileBackend) path(key string) string {
+	return filepath.Join(b.dir, filepath.Base(key))
+}
+
+func (b localFileBackend) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	if err := os.MkdirAll(b.dir, 0750); err != nil {
+		return err
+	}
+	file, err := os.Create(b.path(key))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(b.path(key))
+	}
+	return err
+}
+
+func (b localFileBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := os.Open(b.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrFileNotFound
+	}
+	return file, err
+}
+
+func (b localFileBackend) Delete(ctx context.Context, key string) error {
+	err := os.Remove(b.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// s3FileBackend shares the object storage of the generated images, the objects are kept under prefix
+type s3FileBackend struct {
+	prefix string
+}
+
+func (b s3FileBackend) do(ctx context.Context, method string, key string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, objectURL(config.ObjectStoragePrefix+b.prefix+key), body)
+	if err != nil {
+		return nil, err
+	}
+	// the content is streamed, so it is not part of the signature
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	if body != nil {
+		req.ContentLength = size
+	}
+	err = signer().SignHTTP(ctx, credentials(), req, "UNSIGNED-PAYLOAD", "s3", config.ObjectStorageRegion, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return client.HTTPClient.Do(req)
+}
+
+func (b s3FileBackend) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	resp, err := b.do(ctx, http.MethodPut, key, body, size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("put object failed with status code %d: %s", resp.StatusCode, string(data))
+	}
+	return nil
+}
+
+func (b s3FileBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := b.do(ctx, http.MethodGet, key, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrFileNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("get object failed with status code %d: %s", resp.StatusCode, string(data))
+	}
+	return resp.Body, nil
+}
+
+func (b s3FileBackend) Delete(ctx context.Context, key string) error {
+	resp, err := b.do(ctx, http.MethodDelete, key, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("delete object failed with status code %d: %s", resp.StatusCode, string(data))
+	}
+	return nil
+}
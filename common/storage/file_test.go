@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+func TestFileBackend(t *testing.T) {
+	ctx := context.Background()
+	Convey("Local", t, func() {
+		config.FileStoragePath = t.TempDir()
+		backend := GetFileBackend(FileBackendLocal)
+		So(backend.Put(ctx, "file-abc", strings.NewReader("content"), 7), ShouldBeNil)
+		content, err := backend.Get(ctx, "file-abc")
+		So(err, ShouldBeNil)
+		data, _ := io.ReadAll(content)
+		content.Close()
+		So(string(data), ShouldEqual, "content")
+		So(backend.Delete(ctx, "file-abc"), ShouldBeNil)
+		_, err = backend.Get(ctx, "file-abc")
+		So(err, ShouldEqual, ErrFileNotFound)
+		So(backend.Delete(ctx, "file-abc"), ShouldBeNil)
+	})
+
+	Convey("S3", t, func() {
+		objects := map[string]string{}
+		var authorization string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization = r.Header.Get("Authorization")
+			switch r.Method {
+			case http.MethodPut:
+				data, _ := io.ReadAll(r.Body)
+				objects[r.URL.Path] = string(data)
+			case http.MethodGet:
+				data, ok := objects[r.URL.Path]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write([]byte(data))
+			case http.MethodDelete:
+				delete(objects, r.URL.Path)
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}))
+		defer server.Close()
+		client.Init()
+		config.ObjectStorageEndpoint = server.URL
+		config.ObjectStorageBucket = "bucket"
+		config.ObjectStorageAccessKey = "ak"
+		config.ObjectStorageSecretKey = "sk"
+
+		backend := GetFileBackend(FileBackendS3)
+		So(backend.Put(ctx, "file-abc", strings.NewReader("content"), 7), ShouldBeNil)
+		So(objects["/bucket/one-api/files/file-abc"], ShouldEqual, "content")
+		So(authorization, ShouldStartWith, "AWS4-HMAC-SHA256 Credential=ak/")
+		content, err := backend.Get(ctx, "file-abc")
+		So(err, ShouldBeNil)
+		data, _ := io.ReadAll(content)
+		content.Close()
+		So(string(data), ShouldEqual, "content")
+		So(backend.Delete(ctx, "file-abc"), ShouldBeNil)
+		_, err = backend.Get(ctx, "file-abc")
+		So(err, ShouldEqual, ErrFileNotFound)
+
+		backend = GetBackupBackend(FileBackendS3)
+		So(backend.Put(ctx, "one-api.backup", strings.NewReader("backup"), 6), ShouldBeNil)
+		So(objects["/bucket/one-api/backups/one-api.backup"], ShouldEqual, "backup")
+	})
+
+	Convey("Disabled", t, func() {
+		So(GetFileBackend(""), ShouldBeNil)
+	})
+}
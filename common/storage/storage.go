@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/random"
+)
+
+// Enabled reports whether generated content should be uploaded to the object storage, only the base64 images
+// are uploaded for now, the audio is left to a follow-up.
+// Any S3 compatible service works, including AWS S3, MinIO, Cloudflare R2 and Aliyun OSS.
+func Enabled() bool {
+	return config.ObjectStorageEndpoint != "" && config.ObjectStorageBucket != ""
+}
+
+func signer() *v4.Signer {
+	return v4.NewSigner(func(options *v4.SignerOptions) {
+		// S3 expects the object key to be escaped only once
+		options.DisableURIPathEscaping = true
+	})
+}
+
+func credentials() aws.Credentials {
+	return aws.Credentials{
+		AccessKeyID:     config.ObjectStorageAccessKey,
+		SecretAccessKey: config.ObjectStorageSecretKey,
+	}
+}
+
+func objectURL(key string) string {
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(config.ObjectStorageEndpoint, "/"), config.ObjectStorageBucket, key)
+}
+
+// NewObjectKey returns a key grouped by day, so that a lifecycle rule on the prefix can expire old objects
+func NewObjectKey(ext string) string {
+	return fmt.Sprintf("%s%s/%s%s", config.ObjectStoragePrefix, time.Now().Format("20060102"), random.GetUUID(), ext)
+}
+
+// Upload puts data at key and returns a URL the client can download it from
+func Upload(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	payloadHash := hex.EncodeToString(hash[:])
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.ContentLength = int64(len(data))
+	err = signer().SignHTTP(ctx, credentials(), req, payloadHash, "s3", config.ObjectStorageRegion, time.Now())
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("upload object failed with status code %d: %s", resp.StatusCode, string(body))
+	}
+	return downloadURL(ctx, key)
+}
+
+func downloadURL(ctx context.Context, key string) (string, error) {
+	if config.ObjectStoragePublicURL != "" {
+		return fmt.Sprintf("%s/%s", strings.TrimSuffix(config.ObjectStoragePublicURL, "/"), key), nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, objectURL(key), nil)
+	if err != nil {
+		return "", err
+	}
+	query := req.URL.Query()
+	query.Set("X-Amz-Expires", strconv.Itoa(config.ObjectStorageURLExpiration))
+	req.URL.RawQuery = query.Encode()
+	signedURL, _, err := signer().PresignHTTP(ctx, credentials(), req, "UNSIGNED-PAYLOAD", "s3", config.ObjectStorageRegion, time.Now())
+	return signedURL, err
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+func TestUpload(t *testing.T) {
+	var method, path, body, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.Path
+		authorization = r.Header.Get("Authorization")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+	client.Init()
+	config.ObjectStorageEndpoint = server.URL
+	config.ObjectStorageBucket = "bucket"
+	config.ObjectStorageAccessKey = "ak"
+	config.ObjectStorageSecretKey = "sk"
+
+	Convey("Upload", t, func() {
+		url, err := Upload(context.Background(), "one-api/image.png", []byte("image"), "image/png")
+		So(err, ShouldBeNil)
+		So(method, ShouldEqual, http.MethodPut)
+		So(path, ShouldEqual, "/bucket/one-api/image.png")
+		So(body, ShouldEqual, "image")
+		So(authorization, ShouldStartWith, "AWS4-HMAC-SHA256 Credential=ak/")
+		So(url, ShouldStartWith, server.URL+"/bucket/one-api/image.png?")
+		So(strings.Contains(url, "X-Amz-Signature="), ShouldBeTrue)
+		So(strings.Contains(url, "X-Amz-Expires=86400"), ShouldBeTrue)
+	})
+
+	Convey("Upload with public url", t, func() {
+		config.ObjectStoragePublicURL = "https://cdn.example.com/"
+		defer func() { config.ObjectStoragePublicURL = "" }()
+		url, err := Upload(context.Background(), "one-api/image.png", []byte("image"), "image/png")
+		So(err, ShouldBeNil)
+		So(url, ShouldEqual, "https://cdn.example.com/one-api/image.png")
+	})
+}
@@ -0,0 +1,179 @@
+package theme
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+// the themes of the web console are the builds embedded under web/build and the theme packages in THEME_PATH,
+// a package is a directory with the index.html of the build and an optional theme.json describing it,
+// it replaces the embedded theme of the same name
+
+type Info struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+	Author      string `json:"author"`
+	External    bool   `json:"external"`
+}
+
+var embedded fs.FS
+
+func Init(buildFS embed.FS) {
+	embedded, _ = fs.Sub(buildFS, "web/build")
+}
+
+func validName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, `/\.`)
+}
+
+func packageFS(name string) (fs.FS, bool) {
+	if !validName(name) {
+		return nil, false
+	}
+	dir := filepath.Join(config.ThemePath, name)
+	if info, err := os.Stat(filepath.Join(dir, "index.html")); err != nil || info.IsDir() {
+		return nil, false
+	}
+	return os.DirFS(dir), true
+}
+
+// Open returns the files of the theme
+func Open(name string) (fs.FS, bool) {
+	if files, ok := packageFS(name); ok {
+		return files, true
+	}
+	if embedded == nil || !validName(name) {
+		return nil, false
+	}
+	files, err := fs.Sub(embedded, name)
+	if err != nil {
+		return nil, false
+	}
+	if _, err = fs.Stat(files, "index.html"); err != nil {
+		return nil, false
+	}
+	return files, true
+}
+
+func List() []Info {
+	themes := map[string]Info{}
+	for name := range config.ValidThemes {
+		themes[name] = Info{Name: name}
+	}
+	entries, _ := os.ReadDir(config.ThemePath)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, ok := packageFS(entry.Name()); !ok {
+			continue
+		}
+		info := Info{}
+		if data, err := os.ReadFile(filepath.Join(config.ThemePath, entry.Name(), "theme.json")); err == nil {
+			_ = json.Unmarshal(data, &info)
+		}
+		info.Name = entry.Name()
+		info.External = true
+		themes[info.Name] = info
+	}
+	list := make([]Info, 0, len(themes))
+	for _, info := range themes {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// FileSystem serves the files of the current theme, so that the theme can be switched without a restart
+type FileSystem struct{}
+
+func (FileSystem) Open(name string) (http.File, error) {
+	files, ok := Open(config.Theme)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return http.FS(files).Open(name)
+}
+
+func (s FileSystem) Exists(prefix string, path string) bool {
+	file, err := s.Open(path)
+	if err != nil {
+		return false
+	}
+	_ = file.Close()
+	return true
+}
+
+// IndexPage returns the index.html of the current theme, with the title and the icon of the branding, and with its
+// paths under BASE_PATH
+func IndexPage() ([]byte, error) {
+	files, ok := Open(config.Theme)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	data, err := fs.ReadFile(files, "index.html")
+	if err != nil {
+		return nil, err
+	}
+	page := string(data)
+	if start := strings.Index(page, "<title>"); start >= 0 && config.SystemName != "" {
+		if end := strings.Index(page[start:], "</title>"); end >= 0 {
+			page = page[:start] + "<title>" + htmlEscape(config.SystemName) + page[start+end:]
+		}
+	}
+	if config.Favicon != "" {
+		page = replaceIcon(page, config.Favicon)
+	}
+	if config.BasePath != "" {
+		page = withBasePath(page, config.BasePath)
+	}
+	return []byte(page), nil
+}
+
+// withBasePath prefixes the root-relative links of the page with base, and tells the scripts of the theme where
+// they are served through window.__BASE_PATH__
+func withBasePath(page string, base string) string {
+	for _, attr := range []string{`src="/`, `href="/`} {
+		parts := strings.Split(page, attr)
+		for i := 1; i < len(parts); i++ {
+			if !strings.HasPrefix(parts[i], "/") {
+				parts[i] = base[1:] + "/" + parts[i]
+			}
+		}
+		page = strings.Join(parts, attr)
+	}
+	escaped := htmlEscape(base)
+	head := `<base href="` + escaped + `/" /><script>window.__BASE_PATH__="` + escaped + `"</script>`
+	if start := strings.Index(page, "<head>"); start >= 0 {
+		return page[:start+len("<head>")] + head + page[start+len("<head>"):]
+	}
+	return head + page
+}
+
+func htmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
+
+// replaceIcon points the first icon link of the page to href
+func replaceIcon(page string, href string) string {
+	start := strings.Index(page, `<link rel="icon"`)
+	if start < 0 {
+		return strings.Replace(page, "</head>", `<link rel="icon" href="`+htmlEscape(href)+`" /></head>`, 1)
+	}
+	end := strings.Index(page[start:], ">")
+	if end < 0 {
+		return page
+	}
+	return page[:start] + `<link rel="icon" href="` + htmlEscape(href) + `" />` + page[start+end+1:]
+}
@@ -0,0 +1,56 @@
+package theme
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+func TestTheme(t *testing.T) {
+	config.ThemePath = t.TempDir()
+	dir := filepath.Join(config.ThemePath, "acme")
+	_ = os.MkdirAll(dir, 0750)
+	_ = os.WriteFile(filepath.Join(dir, "index.html"), []byte(`<html><head><link rel="icon" href="/logo.png"/><title>One API</title></head></html>`), 0640)
+	_ = os.WriteFile(filepath.Join(dir, "app.css"), []byte("body{}"), 0640)
+	_ = os.WriteFile(filepath.Join(dir, "theme.json"), []byte(`{"description":"Acme","version":"1.0"}`), 0640)
+	config.Theme = "acme"
+
+	Convey("Package", t, func() {
+		So(config.ThemeExists("acme"), ShouldBeTrue)
+		So(config.ThemeExists("default"), ShouldBeTrue)
+		So(config.ThemeExists("missing"), ShouldBeFalse)
+		So(config.ThemeExists("../acme"), ShouldBeFalse)
+		file, err := FileSystem{}.Open("/app.css")
+		So(err, ShouldBeNil)
+		data, _ := io.ReadAll(file)
+		file.Close()
+		So(string(data), ShouldEqual, "body{}")
+		So(FileSystem{}.Exists("/", "/missing.js"), ShouldBeFalse)
+		So(List(), ShouldContain, Info{Name: "acme", Description: "Acme", Version: "1.0", External: true})
+		So(List(), ShouldContain, Info{Name: "default"})
+	})
+
+	Convey("Branding", t, func() {
+		config.SystemName = "Acme <AI>"
+		config.Favicon = "https://acme.example/icon.png"
+		page, err := IndexPage()
+		So(err, ShouldBeNil)
+		So(string(page), ShouldEqual, `<html><head><link rel="icon" href="https://acme.example/icon.png" /><title>Acme &lt;AI&gt;</title></head></html>`)
+	})
+
+	Convey("BasePath", t, func() {
+		config.Favicon = "/logo.png"
+		config.BasePath = "/one-api"
+		defer func() { config.BasePath = "" }()
+		page, err := IndexPage()
+		So(err, ShouldBeNil)
+		So(string(page), ShouldEqual, `<html><head><base href="/one-api/" /><script>window.__BASE_PATH__="/one-api"</script>`+
+			`<link rel="icon" href="/one-api/logo.png" /><title>Acme &lt;AI&gt;</title></head></html>`)
+		So(withBasePath(`<script src="//cdn.example/a.js"></script>`, "/x"), ShouldContainSubstring, `src="//cdn.example/a.js"`)
+	})
+}
@@ -0,0 +1,35 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCode(t *testing.T) {
+	// the SHA1 test vectors of RFC 6238, truncated to 6 digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	Convey("Code", t, func() {
+		for at, code := range map[int64]string{
+			59:          "287082",
+			1111111109:  "081804",
+			1111111111:  "050471",
+			1234567890:  "005924",
+			2000000000:  "279037",
+			20000000000: "353130",
+		} {
+			got, err := Code(secret, at/Period)
+			So(err, ShouldBeNil)
+			So(got, ShouldEqual, code)
+		}
+	})
+	Convey("Validate", t, func() {
+		now := time.Unix(1111111111, 0)
+		So(Validate(secret, "050471", now), ShouldEqual, 1111111111/Period)
+		So(Validate(secret, "081804", now), ShouldEqual, 1111111109/Period)
+		So(Validate(secret, "287082", now), ShouldEqual, 0)
+		So(Validate(secret, "05047", now), ShouldEqual, 0)
+	})
+}
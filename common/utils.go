@@ -5,9 +5,25 @@ import (
 	"github.com/songquanpeng/one-api/common/config"
 )
 
+// currencySymbols are the symbols of the common currencies, the others are written with their code
+var currencySymbols = map[string]string{
+	"USD": "＄",
+	"CNY": "￥",
+	"EUR": "€",
+}
+
 func LogQuota(quota int64) string {
+	return LogQuotaInCurrency(quota, config.BaseCurrency, 1)
+}
+
+// LogQuotaInCurrency writes the quota in the currency, rate being its units for one unit of the base currency
+func LogQuotaInCurrency(quota int64, currency string, rate float64) string {
 	if config.DisplayInCurrencyEnabled {
-		return fmt.Sprintf("＄%.6f 额度", float64(quota)/config.QuotaPerUnit)
+		amount := float64(quota) / config.QuotaPerUnit * rate
+		if symbol, ok := currencySymbols[currency]; ok {
+			return fmt.Sprintf("%s%.6f 额度", symbol, amount)
+		}
+		return fmt.Sprintf("%.6f %s 额度", amount, currency)
 	} else {
 		return fmt.Sprintf("%d 点额度", quota)
 	}
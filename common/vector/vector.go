@@ -0,0 +1,138 @@
+package vector
+
+import (
+	"math"
+	"strings"
+)
+
+// Chunk splits text into pieces of at most size runes, each one repeating the last overlap runes
+// of the previous piece, a piece ends at a line or word boundary when there is one in its second half
+func Chunk(text string, size int, overlap int) []string {
+	runes := []rune(strings.TrimSpace(text))
+	if size <= 0 || len(runes) == 0 {
+		return nil
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+	var chunks []string
+	for start := 0; start < len(runes); {
+		end := start + size
+		if end >= len(runes) {
+			end = len(runes)
+		} else if cut := boundary(runes[start:end], size/2); cut > 0 {
+			end = start + cut
+		}
+		chunk := strings.TrimSpace(string(runes[start:end]))
+		if chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return chunks
+}
+
+// boundary returns the position after the last line break, or else the last space, not before from
+func boundary(runes []rune, from int) int {
+	for _, separator := range []rune{'\n', ' '} {
+		for i := len(runes) - 1; i >= from; i-- {
+			if runes[i] == separator {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// Cosine returns the cosine similarity of a and b, 0 when they are of different dimensions
+// Normalize scales the vector to a length of 1 in place
+func Normalize(v []float64) []float64 {
+	var norm float64
+	for _, x := range v {
+		norm += x * x
+	}
+	if norm == 0 {
+		return v
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+func Cosine(a []float64, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// Float16 returns the IEEE 754 half precision bits of x, rounded to the nearest even, the values out of
+// the range of half precision become infinities
+func Float16(x float32) uint16 {
+	bits := math.Float32bits(x)
+	sign := uint16(bits>>16) & 0x8000
+	exponent := int(bits>>23) & 0xff
+	mantissa := bits & 0x7fffff
+	if exponent == 0xff {
+		if mantissa != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	}
+	exponent = exponent - 127 + 15
+	if exponent >= 0x1f {
+		return sign | 0x7c00
+	}
+	shift := uint(13)
+	if exponent <= 0 {
+		// subnormal, the implicit leading bit is shifted into the mantissa
+		if exponent < -10 {
+			return sign
+		}
+		mantissa |= 0x800000
+		shift = uint(14 - exponent)
+		exponent = 0
+	}
+	half := uint32(exponent)<<10 | mantissa>>shift
+	rest := mantissa & (1<<shift - 1)
+	halfway := uint32(1) << (shift - 1)
+	// a carry into the exponent is still the right rounding
+	if rest > halfway || (rest == halfway && half&1 == 1) {
+		half++
+	}
+	return sign | uint16(half)
+}
+
+// QuantizeInt8 scales the vector into integers between -127 and 127, v[i] is about q[i] * scale
+func QuantizeInt8(v []float64) (q []int8, scale float64) {
+	for _, x := range v {
+		scale = math.Max(scale, math.Abs(x))
+	}
+	q = make([]int8, len(v))
+	if scale == 0 {
+		return q, 0
+	}
+	scale /= 127
+	for i, x := range v {
+		q[i] = int8(math.Round(x / scale))
+	}
+	return q, scale
+}
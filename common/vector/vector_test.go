@@ -0,0 +1,53 @@
+package vector
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestChunk(t *testing.T) {
+	Convey("Chunk", t, func() {
+		So(Chunk("", 10, 2), ShouldBeEmpty)
+		So(Chunk("short text", 100, 20), ShouldResemble, []string{"short text"})
+		chunks := Chunk(strings.Repeat("word ", 100), 50, 10)
+		So(len(chunks), ShouldBeGreaterThan, 1)
+		for _, chunk := range chunks {
+			So(len([]rune(chunk)), ShouldBeLessThanOrEqualTo, 50)
+			So(strings.HasPrefix(chunk, "word"), ShouldBeTrue)
+		}
+		chunks = Chunk("第一行内容\n第二行内容\n第三行内容", 8, 0)
+		So(chunks, ShouldResemble, []string{"第一行内容", "第二行内容", "第三行内容"})
+		So(len(Chunk(strings.Repeat("x", 100), 30, 40)), ShouldEqual, 4)
+	})
+	Convey("Cosine", t, func() {
+		So(Cosine([]float64{1, 0}, []float64{1, 0}), ShouldAlmostEqual, 1)
+		So(Cosine([]float64{1, 0}, []float64{0, 1}), ShouldAlmostEqual, 0)
+		So(Cosine([]float64{1, 0}, []float64{1, 0, 0}), ShouldEqual, 0)
+	})
+	Convey("Normalize", t, func() {
+		So(Normalize([]float64{3, 4}), ShouldResemble, []float64{0.6, 0.8})
+		So(Normalize([]float64{0, 0}), ShouldResemble, []float64{0, 0})
+	})
+	Convey("Float16", t, func() {
+		So(Float16(1), ShouldEqual, 0x3c00)
+		So(Float16(-2), ShouldEqual, 0xc000)
+		So(Float16(0.1), ShouldEqual, 0x2e66)
+		So(Float16(65504), ShouldEqual, 0x7bff)
+		So(Float16(65520), ShouldEqual, 0x7c00)
+		So(Float16(float32(math.Inf(-1))), ShouldEqual, 0xfc00)
+		So(Float16(float32(math.Pow(2, -24))), ShouldEqual, 0x0001)
+		So(Float16(float32(math.Pow(2, -14))), ShouldEqual, 0x0400)
+		So(Float16(1e-10), ShouldEqual, 0)
+	})
+	Convey("QuantizeInt8", t, func() {
+		q, scale := QuantizeInt8([]float64{0.5, -0.25, 0})
+		So(q, ShouldResemble, []int8{127, -64, 0})
+		So(scale, ShouldAlmostEqual, 0.5/127)
+		q, scale = QuantizeInt8([]float64{0, 0})
+		So(q, ShouldResemble, []int8{0, 0})
+		So(scale, ShouldEqual, 0)
+	})
+}
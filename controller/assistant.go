@@ -0,0 +1,1077 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// https://platform.openai.com/docs/api-reference/assistants
+// the assistants api is emulated rather than relayed, so that it works with the models of any channel:
+// the objects are kept in the database and a run is executed as chat completions of the thread
+
+type assistantRequest struct {
+	Model         *string         `json:"model"`
+	Name          *string         `json:"name"`
+	Description   *string         `json:"description"`
+	Instructions  *string         `json:"instructions"`
+	Tools         json.RawMessage `json:"tools"`
+	ToolResources json.RawMessage `json:"tool_resources"`
+	Metadata      json.RawMessage `json:"metadata"`
+}
+
+type threadMessageRequest struct {
+	Role     string          `json:"role"`
+	Content  any             `json:"content"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+type threadRequest struct {
+	Messages      []threadMessageRequest `json:"messages"`
+	ToolResources json.RawMessage        `json:"tool_resources"`
+	Metadata      json.RawMessage        `json:"metadata"`
+}
+
+type runRequest struct {
+	AssistantId            string                 `json:"assistant_id"`
+	Model                  string                 `json:"model"`
+	Instructions           *string                `json:"instructions"`
+	AdditionalInstructions string                 `json:"additional_instructions"`
+	AdditionalMessages     []threadMessageRequest `json:"additional_messages"`
+	Tools                  json.RawMessage        `json:"tools"`
+	Metadata               json.RawMessage        `json:"metadata"`
+	Stream                 bool                   `json:"stream"`
+	// Thread and ToolResources are only used when the thread is created with the run
+	Thread        *threadRequest  `json:"thread"`
+	ToolResources json.RawMessage `json:"tool_resources"`
+}
+
+type toolResources struct {
+	FileSearch *struct {
+		VectorStoreIds []string `json:"vector_store_ids"`
+	} `json:"file_search,omitempty"`
+}
+
+type toolOutputsRequest struct {
+	ToolOutputs []struct {
+		ToolCallId string `json:"tool_call_id"`
+		Output     string `json:"output"`
+	} `json:"tool_outputs"`
+	Stream bool `json:"stream"`
+}
+
+type runRequiredAction struct {
+	Type              string `json:"type"`
+	SubmitToolOutputs struct {
+		ToolCalls []relaymodel.Tool `json:"tool_calls"`
+	} `json:"submit_tool_outputs"`
+}
+
+func bindAssistantsRequest(c *gin.Context, v any) bool {
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if len(body) == 0 {
+		return true
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, "无效的请求："+err.Error())
+		return false
+	}
+	return true
+}
+
+func getListParams(c *gin.Context) model.ListParams {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return model.ListParams{
+		Limit:  limit,
+		Order:  c.DefaultQuery("order", "desc"),
+		After:  c.Query("after"),
+		Before: c.Query("before"),
+	}
+}
+
+func listResponse(c *gin.Context, data []gin.H, limit int) {
+	hasMore := len(data) > limit
+	if hasMore {
+		data = data[:limit]
+	}
+	var firstId, lastId any
+	if len(data) > 0 {
+		firstId = data[0]["id"]
+		lastId = data[len(data)-1]["id"]
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":   "list",
+		"data":     data,
+		"first_id": firstId,
+		"last_id":  lastId,
+		"has_more": hasMore,
+	})
+}
+
+func rawJSON(value string, empty string) json.RawMessage {
+	if value == "" {
+		value = empty
+	}
+	return json.RawMessage(value)
+}
+
+func nullable[T comparable](value T) any {
+	var zero T
+	if value == zero {
+		return nil
+	}
+	return value
+}
+
+// normalizeMetadata returns the metadata to save, the old one is kept if the request has none
+func normalizeMetadata(metadata json.RawMessage, old string) (string, error) {
+	if len(metadata) == 0 || string(metadata) == "null" {
+		return old, nil
+	}
+	var values map[string]string
+	if err := json.Unmarshal(metadata, &values); err != nil {
+		return "", fmt.Errorf("metadata 必须是字符串键值对：%w", err)
+	}
+	return string(metadata), nil
+}
+
+// parseAssistantTools checks the tools, only the function tools can be served by chat completions,
+// and file_search by the vector stores
+func parseAssistantTools(tools string) ([]relaymodel.Tool, error) {
+	if tools == "" {
+		return nil, nil
+	}
+	var parsed []relaymodel.Tool
+	if err := json.Unmarshal([]byte(tools), &parsed); err != nil {
+		return nil, fmt.Errorf("无效的 tools：%w", err)
+	}
+	for _, tool := range parsed {
+		if tool.Type == "file_search" && config.VectorStore != "" {
+			continue
+		}
+		if tool.Type != "function" {
+			return nil, fmt.Errorf("不支持 %s 类型的工具，仅支持 function 和 file_search（需启用向量存储）", tool.Type)
+		}
+	}
+	return parsed, nil
+}
+
+func normalizeTools(tools json.RawMessage, old string) (string, error) {
+	if len(tools) == 0 || string(tools) == "null" {
+		return old, nil
+	}
+	if _, err := parseAssistantTools(string(tools)); err != nil {
+		return "", err
+	}
+	return string(tools), nil
+}
+
+// normalizeToolResources returns the tool resources to save, the vector stores must be of the user
+func normalizeToolResources(userId int, resources json.RawMessage, old string) (string, error) {
+	if len(resources) == 0 || string(resources) == "null" {
+		return old, nil
+	}
+	var parsed toolResources
+	if err := json.Unmarshal(resources, &parsed); err != nil {
+		return "", fmt.Errorf("无效的 tool_resources：%w", err)
+	}
+	if parsed.FileSearch == nil {
+		return string(resources), nil
+	}
+	for _, vectorStoreId := range parsed.FileSearch.VectorStoreIds {
+		if _, err := model.GetUserVectorStore(userId, vectorStoreId); err != nil {
+			return "", fmt.Errorf("vector store %s 不存在", vectorStoreId)
+		}
+	}
+	return string(resources), nil
+}
+
+func getVectorStoreIds(resources string) []string {
+	var parsed toolResources
+	if json.Unmarshal([]byte(resources), &parsed) != nil || parsed.FileSearch == nil {
+		return nil
+	}
+	return parsed.FileSearch.VectorStoreIds
+}
+
+func assistantObject(assistant *model.Assistant) gin.H {
+	return gin.H{
+		"id":             assistant.AssistantId,
+		"object":         "assistant",
+		"created_at":     assistant.CreatedAt,
+		"name":           nullable(assistant.Name),
+		"description":    nullable(assistant.Description),
+		"model":          assistant.Model,
+		"instructions":   nullable(assistant.Instructions),
+		"tools":          rawJSON(assistant.Tools, "[]"),
+		"tool_resources": rawJSON(assistant.ToolResources, "{}"),
+		"metadata":       rawJSON(assistant.Metadata, "{}"),
+	}
+}
+
+func threadObject(thread *model.Thread) gin.H {
+	return gin.H{
+		"id":             thread.ThreadId,
+		"object":         "thread",
+		"created_at":     thread.CreatedAt,
+		"tool_resources": rawJSON(thread.ToolResources, "{}"),
+		"metadata":       rawJSON(thread.Metadata, "{}"),
+	}
+}
+
+func messageObject(message *model.ThreadMessage) gin.H {
+	return gin.H{
+		"id":         message.MessageId,
+		"object":     "thread.message",
+		"created_at": message.CreatedAt,
+		"thread_id":  message.ThreadId,
+		"status":     "completed",
+		"role":       message.Role,
+		"content": []gin.H{{
+			"type": "text",
+			"text": gin.H{
+				"value":       message.Content,
+				"annotations": []any{},
+			},
+		}},
+		"assistant_id": nullable(message.AssistantId),
+		"run_id":       nullable(message.RunId),
+		"attachments":  []any{},
+		"metadata":     rawJSON(message.Metadata, "{}"),
+	}
+}
+
+func runObject(run *model.Run) gin.H {
+	var usage any
+	if model.IsRunFinished(run.Status) {
+		usage = relaymodel.Usage{
+			PromptTokens:     run.PromptTokens,
+			CompletionTokens: run.CompletionTokens,
+			TotalTokens:      run.PromptTokens + run.CompletionTokens,
+		}
+	}
+	var expiresAt any
+	if !model.IsRunFinished(run.Status) {
+		expiresAt = run.CreatedAt + model.RunTimeout
+	}
+	var requiredAction, lastError any
+	if run.RequiredAction != "" {
+		requiredAction = json.RawMessage(run.RequiredAction)
+	}
+	if run.LastError != "" {
+		lastError = json.RawMessage(run.LastError)
+	}
+	return gin.H{
+		"id":              run.RunId,
+		"object":          "thread.run",
+		"created_at":      run.CreatedAt,
+		"thread_id":       run.ThreadId,
+		"assistant_id":    run.AssistantId,
+		"status":          run.Status,
+		"required_action": requiredAction,
+		"last_error":      lastError,
+		"expires_at":      expiresAt,
+		"started_at":      nullable(run.StartedAt),
+		"cancelled_at":    nullable(run.CancelledAt),
+		"failed_at":       nullable(run.FailedAt),
+		"completed_at":    nullable(run.CompletedAt),
+		"model":           run.Model,
+		"instructions":    run.Instructions,
+		"tools":           rawJSON(run.Tools, "[]"),
+		"metadata":        rawJSON(run.Metadata, "{}"),
+		"usage":           usage,
+	}
+}
+
+func CreateAssistant(c *gin.Context) {
+	var request assistantRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	if request.Model == nil || *request.Model == "" {
+		abortWithOpenAIError(c, http.StatusBadRequest, "model 不能为空")
+		return
+	}
+	assistant := &model.Assistant{UserId: c.GetInt(ctxkey.Id)}
+	if !applyAssistantRequest(c, assistant, &request) {
+		return
+	}
+	if err := assistant.Insert(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, assistantObject(assistant))
+}
+
+func applyAssistantRequest(c *gin.Context, assistant *model.Assistant, request *assistantRequest) bool {
+	var err error
+	if request.Model != nil && *request.Model != "" {
+		assistant.Model = *request.Model
+	}
+	if request.Name != nil {
+		assistant.Name = *request.Name
+	}
+	if request.Description != nil {
+		assistant.Description = *request.Description
+	}
+	if request.Instructions != nil {
+		assistant.Instructions = *request.Instructions
+	}
+	if assistant.Tools, err = normalizeTools(request.Tools, assistant.Tools); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if assistant.ToolResources, err = normalizeToolResources(assistant.UserId, request.ToolResources, assistant.ToolResources); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if assistant.Metadata, err = normalizeMetadata(request.Metadata, assistant.Metadata); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+func getUserAssistant(c *gin.Context, assistantId string) (*model.Assistant, bool) {
+	assistant, err := model.GetUserAssistant(c.GetInt(ctxkey.Id), assistantId)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusNotFound, fmt.Sprintf("assistant %s 不存在", assistantId))
+		return nil, false
+	}
+	return assistant, true
+}
+
+func ListAssistants(c *gin.Context) {
+	params := getListParams(c)
+	assistants, err := model.GetUserAssistants(c.GetInt(ctxkey.Id), params)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	data := make([]gin.H, 0, len(assistants))
+	for _, assistant := range assistants {
+		data = append(data, assistantObject(assistant))
+	}
+	listResponse(c, data, params.Limit)
+}
+
+func RetrieveAssistant(c *gin.Context) {
+	assistant, ok := getUserAssistant(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, assistantObject(assistant))
+}
+
+func ModifyAssistant(c *gin.Context) {
+	assistant, ok := getUserAssistant(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	var request assistantRequest
+	if !bindAssistantsRequest(c, &request) || !applyAssistantRequest(c, assistant, &request) {
+		return
+	}
+	if err := assistant.Update(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, assistantObject(assistant))
+}
+
+func DeleteAssistant(c *gin.Context) {
+	assistant, ok := getUserAssistant(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if err := assistant.Delete(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      assistant.AssistantId,
+		"object":  "assistant.deleted",
+		"deleted": true,
+	})
+}
+
+func toThreadMessage(request *threadMessageRequest) (*model.ThreadMessage, error) {
+	if request.Role != "user" && request.Role != "assistant" {
+		return nil, fmt.Errorf("role 必须是 user 或 assistant")
+	}
+	content := relaymodel.Message{Content: request.Content}.StringContent()
+	if content == "" {
+		return nil, fmt.Errorf("消息内容不能为空，且仅支持文本内容")
+	}
+	metadata, err := normalizeMetadata(request.Metadata, "")
+	if err != nil {
+		return nil, err
+	}
+	return &model.ThreadMessage{
+		Role:     request.Role,
+		Content:  content,
+		Metadata: metadata,
+	}, nil
+}
+
+// createThread creates the thread of the request, a nil request creates an empty thread
+func createThread(c *gin.Context, request *threadRequest) (*model.Thread, bool) {
+	thread := &model.Thread{UserId: c.GetInt(ctxkey.Id)}
+	var messages []*model.ThreadMessage
+	if request != nil {
+		var err error
+		if thread.Metadata, err = normalizeMetadata(request.Metadata, ""); err != nil {
+			abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+			return nil, false
+		}
+		if thread.ToolResources, err = normalizeToolResources(thread.UserId, request.ToolResources, ""); err != nil {
+			abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+			return nil, false
+		}
+		for i := range request.Messages {
+			message, err := toThreadMessage(&request.Messages[i])
+			if err != nil {
+				abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+				return nil, false
+			}
+			messages = append(messages, message)
+		}
+	}
+	if err := model.InsertThread(thread, messages); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return thread, true
+}
+
+func getUserThread(c *gin.Context) (*model.Thread, bool) {
+	thread, err := model.GetUserThread(c.GetInt(ctxkey.Id), c.Param("id"))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusNotFound, fmt.Sprintf("thread %s 不存在", c.Param("id")))
+		return nil, false
+	}
+	return thread, true
+}
+
+func CreateThread(c *gin.Context) {
+	var request threadRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	thread, ok := createThread(c, &request)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, threadObject(thread))
+}
+
+func RetrieveThread(c *gin.Context) {
+	thread, ok := getUserThread(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, threadObject(thread))
+}
+
+func ModifyThread(c *gin.Context) {
+	thread, ok := getUserThread(c)
+	if !ok {
+		return
+	}
+	var request threadRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	var err error
+	if thread.Metadata, err = normalizeMetadata(request.Metadata, thread.Metadata); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if thread.ToolResources, err = normalizeToolResources(thread.UserId, request.ToolResources, thread.ToolResources); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err = thread.Update(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, threadObject(thread))
+}
+
+func DeleteThread(c *gin.Context) {
+	thread, ok := getUserThread(c)
+	if !ok {
+		return
+	}
+	if err := thread.Delete(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      thread.ThreadId,
+		"object":  "thread.deleted",
+		"deleted": true,
+	})
+}
+
+func CreateMessage(c *gin.Context) {
+	thread, ok := getUserThread(c)
+	if !ok {
+		return
+	}
+	var request threadMessageRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	message, err := toThreadMessage(&request)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if model.HasActiveRun(thread.ThreadId) {
+		abortWithOpenAIError(c, http.StatusBadRequest, fmt.Sprintf("thread %s 有正在进行的 run，无法添加消息", thread.ThreadId))
+		return
+	}
+	if err = model.InsertThreadMessage(thread.ThreadId, message); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, messageObject(message))
+}
+
+func ListMessages(c *gin.Context) {
+	thread, ok := getUserThread(c)
+	if !ok {
+		return
+	}
+	params := getListParams(c)
+	messages, err := model.GetThreadMessages(thread.ThreadId, c.Query("run_id"), params)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	data := make([]gin.H, 0, len(messages))
+	for _, message := range messages {
+		data = append(data, messageObject(message))
+	}
+	listResponse(c, data, params.Limit)
+}
+
+func getThreadMessage(c *gin.Context) (*model.ThreadMessage, bool) {
+	thread, ok := getUserThread(c)
+	if !ok {
+		return nil, false
+	}
+	message, err := model.GetThreadMessage(thread.ThreadId, c.Param("messageId"))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusNotFound, fmt.Sprintf("message %s 不存在", c.Param("messageId")))
+		return nil, false
+	}
+	return message, true
+}
+
+func RetrieveMessage(c *gin.Context) {
+	message, ok := getThreadMessage(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, messageObject(message))
+}
+
+func ModifyMessage(c *gin.Context) {
+	message, ok := getThreadMessage(c)
+	if !ok {
+		return
+	}
+	var request threadMessageRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	var err error
+	if message.Metadata, err = normalizeMetadata(request.Metadata, message.Metadata); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err = message.Update(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, messageObject(message))
+}
+
+// prepareRun resolves the run of the request from the assistant, the fields given in the request take precedence
+func prepareRun(c *gin.Context, request *runRequest) (*model.Run, []*model.ThreadMessage, bool) {
+	if request.Stream {
+		abortWithOpenAIError(c, http.StatusBadRequest, "暂不支持流式 run，请轮询 run 的状态")
+		return nil, nil, false
+	}
+	assistant, ok := getUserAssistant(c, request.AssistantId)
+	if !ok {
+		return nil, nil, false
+	}
+	run := &model.Run{
+		UserId:         c.GetInt(ctxkey.Id),
+		TokenId:        c.GetInt(ctxkey.TokenId),
+		AssistantId:    assistant.AssistantId,
+		Model:          assistant.Model,
+		Instructions:   assistant.Instructions,
+		VectorStoreIds: strings.Join(getVectorStoreIds(assistant.ToolResources), ","),
+	}
+	if request.Model != "" {
+		run.Model = request.Model
+	}
+	if request.Instructions != nil {
+		run.Instructions = *request.Instructions
+	}
+	if request.AdditionalInstructions != "" {
+		run.Instructions += "\n\n" + request.AdditionalInstructions
+	}
+	var err error
+	if run.Tools, err = normalizeTools(request.Tools, assistant.Tools); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return nil, nil, false
+	}
+	if run.Metadata, err = normalizeMetadata(request.Metadata, ""); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return nil, nil, false
+	}
+	var messages []*model.ThreadMessage
+	for i := range request.AdditionalMessages {
+		message, err := toThreadMessage(&request.AdditionalMessages[i])
+		if err != nil {
+			abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+			return nil, nil, false
+		}
+		messages = append(messages, message)
+	}
+	return run, messages, true
+}
+
+func startRun(c *gin.Context, thread *model.Thread, run *model.Run, messages []*model.ThreadMessage) {
+	for _, message := range messages {
+		if err := model.InsertThreadMessage(thread.ThreadId, message); err != nil {
+			abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	run.ThreadId = thread.ThreadId
+	vectorStoreIds := getVectorStoreIds(thread.ToolResources)
+	if run.VectorStoreIds != "" {
+		vectorStoreIds = append(vectorStoreIds, strings.Split(run.VectorStoreIds, ",")...)
+	}
+	run.VectorStoreIds = strings.Join(vectorStoreIds, ",")
+	if err := run.Insert(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	go executeRun(*run)
+	c.JSON(http.StatusOK, runObject(run))
+}
+
+func CreateRun(c *gin.Context) {
+	thread, ok := getUserThread(c)
+	if !ok {
+		return
+	}
+	var request runRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	run, messages, ok := prepareRun(c, &request)
+	if !ok {
+		return
+	}
+	if model.HasActiveRun(thread.ThreadId) {
+		abortWithOpenAIError(c, http.StatusBadRequest, fmt.Sprintf("thread %s 已有正在进行的 run", thread.ThreadId))
+		return
+	}
+	startRun(c, thread, run, messages)
+}
+
+func CreateThreadAndRun(c *gin.Context) {
+	var request runRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	run, messages, ok := prepareRun(c, &request)
+	if !ok {
+		return
+	}
+	if len(request.ToolResources) != 0 {
+		if request.Thread == nil {
+			request.Thread = &threadRequest{}
+		}
+		if len(request.Thread.ToolResources) == 0 {
+			request.Thread.ToolResources = request.ToolResources
+		}
+	}
+	thread, ok := createThread(c, request.Thread)
+	if !ok {
+		return
+	}
+	startRun(c, thread, run, messages)
+}
+
+func getThreadRun(c *gin.Context) (*model.Run, bool) {
+	thread, ok := getUserThread(c)
+	if !ok {
+		return nil, false
+	}
+	run, err := model.GetThreadRun(thread.ThreadId, c.Param("runsId"))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusNotFound, fmt.Sprintf("run %s 不存在", c.Param("runsId")))
+		return nil, false
+	}
+	return run, true
+}
+
+func ListRuns(c *gin.Context) {
+	thread, ok := getUserThread(c)
+	if !ok {
+		return
+	}
+	params := getListParams(c)
+	runs, err := model.GetThreadRuns(thread.ThreadId, params)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	data := make([]gin.H, 0, len(runs))
+	for _, run := range runs {
+		data = append(data, runObject(run))
+	}
+	listResponse(c, data, params.Limit)
+}
+
+func RetrieveRun(c *gin.Context) {
+	run, ok := getThreadRun(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, runObject(run))
+}
+
+func ModifyRun(c *gin.Context) {
+	run, ok := getThreadRun(c)
+	if !ok {
+		return
+	}
+	var request runRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	var err error
+	if run.Metadata, err = normalizeMetadata(request.Metadata, run.Metadata); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err = run.UpdateMetadata(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, runObject(run))
+}
+
+func SubmitToolOutputs(c *gin.Context) {
+	run, ok := getThreadRun(c)
+	if !ok {
+		return
+	}
+	if run.Status != model.RunStatusRequiresAction {
+		abortWithOpenAIError(c, http.StatusBadRequest, fmt.Sprintf("run %s 的状态为 %s，无需提交工具输出", run.RunId, run.Status))
+		return
+	}
+	var request toolOutputsRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	if request.Stream {
+		abortWithOpenAIError(c, http.StatusBadRequest, "暂不支持流式 run，请轮询 run 的状态")
+		return
+	}
+	var requiredAction runRequiredAction
+	var toolMessages []relaymodel.Message
+	_ = json.Unmarshal([]byte(run.RequiredAction), &requiredAction)
+	_ = json.Unmarshal([]byte(run.ToolMessages), &toolMessages)
+	outputs := make(map[string]string)
+	for _, output := range request.ToolOutputs {
+		outputs[output.ToolCallId] = output.Output
+	}
+	for _, toolCall := range requiredAction.SubmitToolOutputs.ToolCalls {
+		output, ok := outputs[toolCall.Id]
+		if !ok {
+			abortWithOpenAIError(c, http.StatusBadRequest, fmt.Sprintf("缺少工具调用 %s 的输出", toolCall.Id))
+			return
+		}
+		toolMessages = append(toolMessages, relaymodel.Message{
+			Role:       "tool",
+			Content:    output,
+			ToolCallId: toolCall.Id,
+		})
+	}
+	data, _ := json.Marshal(toolMessages)
+	run.ToolMessages = string(data)
+	run.RequiredAction = ""
+	run.Status = model.RunStatusInProgress
+	if err := run.Transit(model.RunStatusRequiresAction, "tool_messages", "required_action"); err != nil {
+		abortWithOpenAIError(c, http.StatusConflict, err.Error())
+		return
+	}
+	go executeRun(*run)
+	c.JSON(http.StatusOK, runObject(run))
+}
+
+func CancelRun(c *gin.Context) {
+	run, ok := getThreadRun(c)
+	if !ok {
+		return
+	}
+	if model.IsRunFinished(run.Status) {
+		abortWithOpenAIError(c, http.StatusBadRequest, fmt.Sprintf("无法取消状态为 %s 的 run", run.Status))
+		return
+	}
+	status := run.Status
+	run.Status = model.RunStatusCancelled
+	run.CancelledAt = helper.GetTimestamp()
+	if err := run.Transit(status, "cancelled_at"); err != nil {
+		abortWithOpenAIError(c, http.StatusConflict, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, runObject(run))
+}
+
+func buildRunMessages(run *model.Run) ([]relaymodel.Message, error) {
+	var messages []relaymodel.Message
+	if run.Instructions != "" {
+		messages = append(messages, relaymodel.Message{Role: "system", Content: run.Instructions})
+	}
+	threadMessages, err := model.GetAllThreadMessages(run.ThreadId)
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range threadMessages {
+		messages = append(messages, relaymodel.Message{Role: message.Role, Content: message.Content})
+	}
+	if run.ToolMessages != "" {
+		var toolMessages []relaymodel.Message
+		if err = json.Unmarshal([]byte(run.ToolMessages), &toolMessages); err != nil {
+			return nil, err
+		}
+		messages = append(messages, toolMessages...)
+	}
+	return messages, nil
+}
+
+func failRun(run *model.Run, from string, message string) {
+	lastError, _ := json.Marshal(gin.H{
+		"code":    "server_error",
+		"message": message,
+	})
+	run.Status = model.RunStatusFailed
+	run.FailedAt = helper.GetTimestamp()
+	run.LastError = string(lastError)
+	_ = run.Transit(from, "failed_at", "last_error", "prompt_tokens", "completion_tokens")
+}
+
+// fileSearchRounds is how many times a run may search the files before the model has to answer
+const fileSearchRounds = 5
+
+const fileSearchToolName = "file_search"
+
+var fileSearchTool = relaymodel.Tool{
+	Type: "function",
+	Function: relaymodel.Function{
+		Name:        fileSearchToolName,
+		Description: "Search the files attached to the assistant and the thread, returns the most relevant passages.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]any{
+					"type":        "string",
+					"description": "The query to search the files for.",
+				},
+			},
+			"required": []string{"query"},
+		},
+	},
+}
+
+// runTools returns the tools sent to the model, file_search is served as a function searching the vector stores
+// of the run, the limit of its results is 0 if the run does not search the files
+func runTools(run *model.Run) ([]relaymodel.Tool, int, error) {
+	parsed, err := parseAssistantTools(run.Tools)
+	if err != nil {
+		return nil, 0, err
+	}
+	var raw []struct {
+		FileSearch struct {
+			MaxNumResults int `json:"max_num_results"`
+		} `json:"file_search"`
+	}
+	_ = json.Unmarshal([]byte(run.Tools), &raw)
+	var tools []relaymodel.Tool
+	limit := 0
+	for i, tool := range parsed {
+		if tool.Type != "file_search" {
+			tools = append(tools, tool)
+			continue
+		}
+		if run.VectorStoreIds != "" {
+			limit = raw[i].FileSearch.MaxNumResults
+			if limit <= 0 {
+				limit = fileSearchMaxResults
+			}
+		}
+	}
+	// it is the last one, so that it can be dropped when the searches are exhausted
+	if limit > 0 {
+		tools = append(tools, fileSearchTool)
+	}
+	return tools, limit, nil
+}
+
+// searchRunFiles serves a file_search call of the model, the failure is told to the model rather than failing the run
+func searchRunFiles(key string, run *model.Run, toolCall relaymodel.Tool, limit int) string {
+	var arguments struct {
+		Query string `json:"query"`
+	}
+	switch value := toolCall.Function.Arguments.(type) {
+	case string:
+		_ = json.Unmarshal([]byte(value), &arguments)
+	default:
+		data, _ := json.Marshal(value)
+		_ = json.Unmarshal(data, &arguments)
+	}
+	if strings.TrimSpace(arguments.Query) == "" {
+		return "file_search failed: query is empty"
+	}
+	results, err := searchVectorStores(key, run.UserId, strings.Split(run.VectorStoreIds, ","), arguments.Query, limit)
+	if err != nil {
+		return "file_search failed: " + err.Error()
+	}
+	data, _ := json.Marshal(results)
+	return string(data)
+}
+
+// executeRun makes the chat completions of the run, the file_search calls are served in place and
+// it stops when the model calls the functions, it is executed again once their outputs are submitted
+func executeRun(run model.Run) {
+	ctx := context.Background()
+	if run.Status == model.RunStatusQueued {
+		run.Status = model.RunStatusInProgress
+		run.StartedAt = helper.GetTimestamp()
+		if run.Transit(model.RunStatusQueued, "started_at") != nil {
+			return
+		}
+	}
+	token, err := model.GetTokenById(run.TokenId)
+	if err != nil {
+		failRun(&run, model.RunStatusInProgress, "令牌不存在")
+		return
+	}
+	tools, fileSearchLimit, err := runTools(&run)
+	if err != nil {
+		failRun(&run, model.RunStatusInProgress, err.Error())
+		return
+	}
+	messages, err := buildRunMessages(&run)
+	if err != nil {
+		failRun(&run, model.RunStatusInProgress, err.Error())
+		return
+	}
+	toolMessages := []relaymodel.Message{}
+	_ = json.Unmarshal([]byte(run.ToolMessages), &toolMessages)
+	var reply relaymodel.Message
+	for round := 0; ; round++ {
+		if round == fileSearchRounds && fileSearchLimit > 0 {
+			// the model has to answer with what it has found so far
+			tools = tools[:len(tools)-1]
+			fileSearchLimit = 0
+		}
+		response, err := relayChatCompletion(token.Key, &relaymodel.GeneralOpenAIRequest{
+			Model:    run.Model,
+			Messages: messages,
+			Tools:    tools,
+		})
+		if err != nil {
+			logger.Warnf(ctx, "run %s failed: %s", run.RunId, err.Error())
+			failRun(&run, model.RunStatusInProgress, err.Error())
+			return
+		}
+		run.PromptTokens += response.PromptTokens
+		run.CompletionTokens += response.CompletionTokens
+		if len(response.Choices) == 0 {
+			failRun(&run, model.RunStatusInProgress, "模型没有返回内容")
+			return
+		}
+		reply = response.Choices[0].Message
+		if len(reply.ToolCalls) == 0 {
+			break
+		}
+		var functionCalls []relaymodel.Tool
+		var outputs []relaymodel.Message
+		for _, toolCall := range reply.ToolCalls {
+			if fileSearchLimit == 0 || toolCall.Function.Name != fileSearchToolName {
+				functionCalls = append(functionCalls, toolCall)
+				continue
+			}
+			outputs = append(outputs, relaymodel.Message{
+				Role:       "tool",
+				Content:    searchRunFiles(token.Key, &run, toolCall, fileSearchLimit),
+				ToolCallId: toolCall.Id,
+			})
+		}
+		reply.Role = "assistant"
+		toolMessages = append(toolMessages, reply)
+		toolMessages = append(toolMessages, outputs...)
+		if len(functionCalls) > 0 {
+			requiredAction := runRequiredAction{Type: "submit_tool_outputs"}
+			requiredAction.SubmitToolOutputs.ToolCalls = functionCalls
+			data, _ := json.Marshal(toolMessages)
+			run.ToolMessages = string(data)
+			data, _ = json.Marshal(requiredAction)
+			run.RequiredAction = string(data)
+			run.Status = model.RunStatusRequiresAction
+			_ = run.Transit(model.RunStatusInProgress, "tool_messages", "required_action", "prompt_tokens", "completion_tokens")
+			return
+		}
+		messages = append(messages, reply)
+		messages = append(messages, outputs...)
+	}
+	// the reply is added before the run completes, so that it is there once the client sees the run completed
+	message := &model.ThreadMessage{
+		Role:        "assistant",
+		Content:     reply.StringContent(),
+		AssistantId: run.AssistantId,
+		RunId:       run.RunId,
+	}
+	if err = model.InsertThreadMessage(run.ThreadId, message); err != nil {
+		failRun(&run, model.RunStatusInProgress, err.Error())
+		return
+	}
+	run.Status = model.RunStatusCompleted
+	run.CompletedAt = helper.GetTimestamp()
+	if run.Transit(model.RunStatusInProgress, "completed_at", "prompt_tokens", "completion_tokens") != nil {
+		// the run has been cancelled in the meantime
+		_ = model.DeleteThreadMessage(message.Id)
+	}
+}
@@ -0,0 +1,304 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+const asyncCallbackURLHeader = "X-OneAPI-Callback-URL"
+const asyncSignatureHeader = "X-OneAPI-Signature"
+
+// asyncTaskPaths are the relay routes accepting async tasks, their requests and responses are plain JSON
+var asyncTaskPaths = map[string]bool{
+	"/chat/completions":   true,
+	"/completions":        true,
+	"/embeddings":         true,
+	"/moderations":        true,
+	"/images/generations": true,
+}
+
+// asyncRetryDelays are the seconds waited before the retries of a task limited by the upstream
+// and before the retries of a callback not delivered, there is one attempt more than delays
+var asyncRetryDelays = []int64{60, 5 * 60, 30 * 60, 2 * 60 * 60, 6 * 60 * 60}
+
+const asyncPollInterval = 5 * time.Second
+const asyncCallbackTimeout = 30 * time.Second
+
+func asyncTaskObject(task *model.AsyncTask) gin.H {
+	objectType := "async.task"
+	if task.TTL > 0 {
+		objectType = "chat.completion.deferred"
+	}
+	object := gin.H{
+		"id":              task.TaskId,
+		"object":          objectType,
+		"path":            task.Path,
+		"model":           task.Model,
+		"status":          task.Status,
+		"attempts":        task.Attempts,
+		"callback_url":    task.CallbackURL,
+		"callback_status": task.CallbackStatus,
+		"created_at":      task.CreatedAt,
+		"started_at":      task.StartedAt,
+		"finished_at":     task.FinishedAt,
+	}
+	if model.IsAsyncTaskFinished(task.Status) {
+		object["status_code"] = task.StatusCode
+		if json.Valid([]byte(task.Response)) {
+			object["response"] = json.RawMessage(task.Response)
+		} else {
+			object["response"] = task.Response
+		}
+	}
+	return object
+}
+
+// newAsyncTask reads the task of the request to the relay route, with the callback url of the X-OneAPI-Callback-URL
+// header, it aborts with the error and returns nil if the request is invalid
+func newAsyncTask(c *gin.Context, path string) *model.AsyncTask {
+	callbackURL := c.GetHeader(asyncCallbackURLHeader)
+	if callbackURL != "" {
+		u, err := url.Parse(callbackURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			abortWithOpenAIError(c, http.StatusBadRequest, "无效的回调地址")
+			return nil
+		}
+	}
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return nil
+	}
+	var request struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}
+	if err = json.Unmarshal(body, &request); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, "无效的请求："+err.Error())
+		return nil
+	}
+	if request.Stream {
+		abortWithOpenAIError(c, http.StatusBadRequest, "异步任务不支持流式请求")
+		return nil
+	}
+	return &model.AsyncTask{
+		UserId:      c.GetInt(ctxkey.Id),
+		TokenId:     c.GetInt(ctxkey.TokenId),
+		Path:        path,
+		Model:       request.Model,
+		Body:        string(body),
+		CallbackURL: callbackURL,
+	}
+}
+
+// SubmitAsyncTask queues the request to the relay route after /v1/async, it is executed in the background
+// and its response is posted to the url of the X-OneAPI-Callback-URL header, if any
+func SubmitAsyncTask(c *gin.Context) {
+	if config.AsyncTaskConcurrency <= 0 {
+		abortWithOpenAIError(c, http.StatusServiceUnavailable, "异步任务未启用")
+		return
+	}
+	path := c.Param("path")
+	if !asyncTaskPaths[path] {
+		abortWithOpenAIError(c, http.StatusBadRequest, fmt.Sprintf("不支持异步执行的接口：%s", path))
+		return
+	}
+	task := newAsyncTask(c, path)
+	if task == nil {
+		return
+	}
+	if err := task.Insert(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusAccepted, asyncTaskObject(task))
+}
+
+// RetrieveAsyncTask returns the task of the user, with the response once it is finished
+func RetrieveAsyncTask(c *gin.Context) {
+	task, err := model.GetUserAsyncTask(c.GetInt(ctxkey.Id), c.Param("id"))
+	if err != nil || task.IsExpired() {
+		abortWithOpenAIError(c, http.StatusNotFound, "任务不存在")
+		return
+	}
+	c.JSON(http.StatusOK, asyncTaskObject(task))
+}
+
+func asyncErrorResponse(message string) string {
+	response, _ := json.Marshal(gin.H{
+		"error": relaymodel.Error{
+			Message: message,
+			Type:    "one_api_error",
+		},
+	})
+	return string(response)
+}
+
+// isAsyncTaskRetryable tells whether the response shows the request may succeed later,
+// like when the upstream or the token is limited
+func isAsyncTaskRetryable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// executeAsyncTask relays the request of the task with the key of its token, so it is billed and logged as usual
+func executeAsyncTask(task *model.AsyncTask) {
+	statusCode, response := http.StatusInternalServerError, ""
+	token, err := model.GetTokenById(task.TokenId)
+	if err != nil {
+		statusCode, response = http.StatusUnauthorized, asyncErrorResponse("令牌不存在")
+	} else if BotRelayHandler == nil {
+		response = asyncErrorResponse("relay handler is not set")
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), model.AsyncTaskTimeout*time.Second)
+		req := httptest.NewRequest(http.MethodPost, "/v1"+task.Path, bytes.NewReader([]byte(task.Body))).WithContext(ctx)
+		req.RemoteAddr = "127.0.0.1:0"
+		req.Header.Set("Authorization", "Bearer sk-"+token.Key)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		BotRelayHandler.ServeHTTP(w, req)
+		cancel()
+		statusCode, response = w.Code, w.Body.String()
+	}
+	now := helper.GetTimestamp()
+	if isAsyncTaskRetryable(statusCode) && task.Attempts <= len(asyncRetryDelays) {
+		task.Status = model.AsyncTaskStatusQueued
+		task.NextAttemptAt = now + asyncRetryDelays[task.Attempts-1]
+		if err = task.Transit(model.AsyncTaskStatusInProgress, "next_attempt_at"); err != nil {
+			logger.SysError(fmt.Sprintf("failed to queue async task %s again: %s", task.TaskId, err.Error()))
+		}
+		return
+	}
+	task.Status = model.AsyncTaskStatusFailed
+	if statusCode == http.StatusOK {
+		task.Status = model.AsyncTaskStatusCompleted
+	}
+	task.StatusCode = statusCode
+	task.Response = response
+	task.FinishedAt = now
+	if task.CallbackURL != "" {
+		task.CallbackStatus = model.AsyncCallbackStatusPending
+		task.NextAttemptAt = now
+	}
+	err = task.Transit(model.AsyncTaskStatusInProgress, "status_code", "response", "finished_at", "callback_status", "next_attempt_at")
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to finish async task %s: %s", task.TaskId, err.Error()))
+	}
+}
+
+// startAsyncTask takes the queued task to execute it, it fails if another node has taken it meanwhile
+func startAsyncTask(task *model.AsyncTask) bool {
+	task.Status = model.AsyncTaskStatusInProgress
+	task.StartedAt = helper.GetTimestamp()
+	task.Attempts++
+	return task.Transit(model.AsyncTaskStatusQueued, "started_at", "attempts") == nil
+}
+
+// AutomaticallyRunAsyncTasks executes the queued tasks on the leader, AsyncTaskConcurrency at most at the same time
+func AutomaticallyRunAsyncTasks() {
+	slots := make(chan struct{}, config.AsyncTaskConcurrency)
+	for {
+		time.Sleep(asyncPollInterval)
+		if !model.IsLeader() {
+			continue
+		}
+		if err := model.RequeueStaleAsyncTasks(); err != nil {
+			logger.SysError("failed to queue stale async tasks again: " + err.Error())
+		}
+		free := cap(slots) - len(slots)
+		if free == 0 {
+			continue
+		}
+		tasks, err := model.GetDueAsyncTasks(free)
+		if err != nil {
+			logger.SysError("failed to get queued async tasks: " + err.Error())
+			continue
+		}
+		for _, task := range tasks {
+			if !startAsyncTask(task) {
+				continue
+			}
+			slots <- struct{}{}
+			go func(task *model.AsyncTask) {
+				defer func() { <-slots }()
+				executeAsyncTask(task)
+			}(task)
+		}
+	}
+}
+
+func postAsyncCallback(task *model.AsyncTask) error {
+	token, err := model.GetTokenById(task.TokenId)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(asyncTaskObject(task))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), asyncCallbackTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, task.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(asyncSignatureHeader, common.SignWithTokenKey(token.Key, body))
+	resp, err := client.UserContentRequestHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// AutomaticallyDeliverAsyncCallbacks posts the results of the finished tasks to their callback urls on the leader,
+// a callback not answered with 2xx is retried later
+func AutomaticallyDeliverAsyncCallbacks() {
+	for {
+		time.Sleep(asyncPollInterval)
+		if !model.IsLeader() {
+			continue
+		}
+		tasks, err := model.GetDueAsyncCallbacks(100)
+		if err != nil {
+			logger.SysError("failed to get async callbacks: " + err.Error())
+			continue
+		}
+		for _, task := range tasks {
+			task.CallbackAttempts++
+			if err = postAsyncCallback(task); err == nil {
+				task.CallbackStatus = model.AsyncCallbackStatusDelivered
+			} else if task.CallbackAttempts <= len(asyncRetryDelays) {
+				task.NextAttemptAt = helper.GetTimestamp() + asyncRetryDelays[task.CallbackAttempts-1]
+			} else {
+				task.CallbackStatus = model.AsyncCallbackStatusFailed
+			}
+			if err != nil {
+				logger.SysLog(fmt.Sprintf("failed to post the callback of async task %s: %s", task.TaskId, err.Error()))
+			}
+			if err = task.UpdateCallback(); err != nil {
+				logger.SysError(fmt.Sprintf("failed to update the callback of async task %s: %s", task.TaskId, err.Error()))
+			}
+		}
+	}
+}
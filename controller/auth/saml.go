@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/common/saml"
+	"github.com/songquanpeng/one-api/controller"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// samlRequestTTL is how long the user has to sign in at the IdP
+const samlRequestTTL = 10 * time.Minute
+
+const samlClockSkew = 3 * time.Minute
+
+// the ids of the authentication requests sent are kept on the server, the session cookie is not sent along
+// with the response the IdP posts from another site
+var samlRequestsLock sync.Mutex
+var samlRequests = make(map[string]time.Time)
+
+func saveSAMLRequest(id string) error {
+	if common.RedisEnabled {
+		return common.RedisSet("saml_request:"+id, "1", samlRequestTTL)
+	}
+	samlRequestsLock.Lock()
+	defer samlRequestsLock.Unlock()
+	now := time.Now()
+	for requestId, expiresAt := range samlRequests {
+		if now.After(expiresAt) {
+			delete(samlRequests, requestId)
+		}
+	}
+	samlRequests[id] = now.Add(samlRequestTTL)
+	return nil
+}
+
+// consumeSAMLRequest tells whether the request is pending, a request is answered once
+func consumeSAMLRequest(id string) bool {
+	if common.RedisEnabled {
+		deleted, err := common.RDB.Del(context.Background(), "saml_request:"+id).Result()
+		if err != nil {
+			logger.SysError("failed to consume saml request: " + err.Error())
+		}
+		return deleted == 1
+	}
+	samlRequestsLock.Lock()
+	defer samlRequestsLock.Unlock()
+	expiresAt, ok := samlRequests[id]
+	delete(samlRequests, id)
+	return ok && time.Now().Before(expiresAt)
+}
+
+func getServiceProvider() (*saml.ServiceProvider, error) {
+	if config.ServerAddress == "" {
+		return nil, errors.New("请先设置服务器地址")
+	}
+	sp := &saml.ServiceProvider{
+		EntityId:    config.ServerAddress + "/api/saml/metadata",
+		ACSURL:      config.ServerAddress + "/api/saml/acs",
+		IdPEntityId: config.SAMLIdPEntityId,
+		IdPSSOURL:   config.SAMLIdPSSOURL,
+		ClockSkew:   samlClockSkew,
+	}
+	if config.SAMLIdPCertificate != "" {
+		certificate, err := saml.ParseCertificate(config.SAMLIdPCertificate)
+		if err != nil {
+			return nil, err
+		}
+		sp.IdPCertificate = certificate
+	}
+	return sp, nil
+}
+
+// SAMLMetadata returns the metadata of the gateway as a service provider, for the IdP to be configured before SAML is enabled
+func SAMLMetadata(c *gin.Context) {
+	sp, err := getServiceProvider()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", sp.Metadata())
+}
+
+// SAMLLogin redirects the user to the IdP for signing in
+func SAMLLogin(c *gin.Context) {
+	if !config.SAMLEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员未开启通过 SAML 登录以及注册",
+		})
+		return
+	}
+	sp, err := getServiceProvider()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	// the IDs of SAML must not start with a digit
+	id := "_" + random.GetUUID()
+	if err = saveSAMLRequest(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	location, err := sp.AuthnRequestURL(id, "", time.Now())
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.Redirect(http.StatusFound, location)
+}
+
+// samlGroup returns the group mapped from the first value of the group attribute having one, "" if none
+func samlGroup(identity *saml.Identity) string {
+	if config.SAMLGroupAttribute == "" || config.SAMLGroupMapping == "" {
+		return ""
+	}
+	var mapping map[string]string
+	if err := json.Unmarshal([]byte(config.SAMLGroupMapping), &mapping); err != nil {
+		logger.SysError("invalid saml group mapping: " + err.Error())
+		return ""
+	}
+	for _, value := range identity.Attributes[config.SAMLGroupAttribute] {
+		if group, ok := mapping[value]; ok && group != "" {
+			return group
+		}
+	}
+	return ""
+}
+
+func samlUser(ctx context.Context, identity *saml.Identity) (*model.User, error) {
+	group := samlGroup(identity)
+	user := model.User{
+		SamlId: identity.NameID,
+	}
+	if model.IsSamlIdAlreadyTaken(user.SamlId) {
+		if err := user.FillUserBySamlId(); err != nil {
+			return nil, err
+		}
+		// the group follows the IdP on every login
+		if group != "" && group != user.Group && user.Status == model.UserStatusEnabled {
+			if err := model.UpdateUserGroup(user.Id, group); err != nil {
+				return nil, err
+			}
+			user.Group = group
+		}
+		return &user, nil
+	}
+	if !config.RegisterEnabled {
+		return nil, errors.New("管理员关闭了新用户注册")
+	}
+	user.Username = identity.Attribute(config.SAMLUsernameAttribute)
+	if user.Username == "" || len(user.Username) > 12 || model.IsUsernameAlreadyTaken(user.Username) {
+		user.Username = "saml_" + strconv.Itoa(model.GetMaxUserId()+1)
+	}
+	if email := identity.Attribute(config.SAMLEmailAttribute); email != "" && !model.IsEmailAlreadyTaken(email) {
+		user.Email = email
+	}
+	user.DisplayName = identity.Attribute(config.SAMLDisplayNameAttribute)
+	if user.DisplayName == "" {
+		user.DisplayName = "SAML User"
+	}
+	user.Group = group
+	if err := user.Insert(ctx, 0); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func redirectSAMLError(c *gin.Context, message string) {
+	c.Redirect(http.StatusFound, config.BasePath+"/oauth/saml?error="+url.QueryEscape(message))
+}
+
+// SAMLACS receives the response posted by the IdP, signs the user in and redirects to the web page finishing the login
+func SAMLACS(c *gin.Context) {
+	if !config.SAMLEnabled {
+		redirectSAMLError(c, "管理员未开启通过 SAML 登录以及注册")
+		return
+	}
+	sp, err := getServiceProvider()
+	if err != nil {
+		redirectSAMLError(c, err.Error())
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, saml.MaxResponseSize+4096)
+	identity, err := sp.ParseResponse(c.PostForm("SAMLResponse"), consumeSAMLRequest, time.Now())
+	if err != nil {
+		logger.SysLog("invalid saml response: " + err.Error())
+		redirectSAMLError(c, "SAML 认证失败："+err.Error())
+		return
+	}
+	user, err := samlUser(c.Request.Context(), identity)
+	if err != nil {
+		redirectSAMLError(c, err.Error())
+		return
+	}
+	if user.Status != model.UserStatusEnabled {
+		redirectSAMLError(c, "用户已被封禁")
+		return
+	}
+	twoFactorRequired, err := controller.SetupSession(user, c)
+	if err != nil {
+		redirectSAMLError(c, "无法保存会话信息，请重试")
+		return
+	}
+	if twoFactorRequired {
+		c.Redirect(http.StatusFound, config.BasePath+"/login/2fa")
+		return
+	}
+	if model.IsTwoFactorEnforced(user.Role) && !user.TwoFactorEnabled {
+		c.Redirect(http.StatusFound, config.BasePath+"/oauth/saml?two_factor_setup=1")
+		return
+	}
+	c.Redirect(http.StatusFound, config.BasePath+"/oauth/saml")
+}
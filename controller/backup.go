@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/model"
+)
+
+func GetBackups(c *gin.Context) {
+	backups, err := model.GetBackups()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    backups,
+	})
+	return
+}
+
+func CreateBackup(c *gin.Context) {
+	backup, err := model.CreateBackup(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    backup,
+	})
+	return
+}
+
+// DownloadBackup sends the backup as it is stored, it can only be read with BACKUP_PASSWORD
+func DownloadBackup(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	backup, err := model.GetBackupById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	data, err := model.ReadBackup(c.Request.Context(), backup.Storage, backup.Name)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "读取备份失败：" + err.Error(),
+		})
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename="+backup.Name)
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
+
+func DeleteBackup(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	backup, err := model.GetBackupById(id)
+	if err == nil {
+		err = model.DeleteBackup(c.Request.Context(), backup)
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+	"github.com/songquanpeng/one-api/relay/defaults"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+type billingEstimate struct {
+	Model           string  `json:"model"`
+	ChannelId       int     `json:"channel_id"`
+	ChannelName     string  `json:"channel_name,omitempty"`
+	ChannelType     int     `json:"channel_type"`
+	Priority        int64   `json:"priority"`
+	ActualModel     string  `json:"actual_model"`
+	ModelRatio      float64 `json:"model_ratio"`
+	CompletionRatio float64 `json:"completion_ratio"`
+	GroupRatio      float64 `json:"group_ratio"`
+	PromptTokens    int     `json:"prompt_tokens"`
+	// CompletionTokens is max_tokens of the request capped by the channel and the model, 0 if none is set
+	CompletionTokens int     `json:"completion_tokens"`
+	Quota            int64   `json:"quota"`
+	Cost             float64 `json:"cost"`
+	// QuotaPer1KCompletionTokens is the quota of 1000 more tokens of the answer
+	QuotaPer1KCompletionTokens int64 `json:"quota_per_1k_completion_tokens"`
+}
+
+func countEstimatePromptTokens(request *relaymodel.GeneralOpenAIRequest, modelName string) int {
+	if len(request.Messages) > 0 {
+		return openai.CountTokenMessages(request.Messages, modelName)
+	}
+	if request.Prompt != nil {
+		return openai.CountTokenInput(request.Prompt, modelName)
+	}
+	return openai.CountTokenInput(request.Input, modelName)
+}
+
+// EstimateBilling prices the chat, completion or embedding request on each channel which could serve it without
+// sending it; the models of the query, separated by commas, are compared along the model of the request
+func EstimateBilling(c *gin.Context) {
+	var request relaymodel.GeneralOpenAIRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, "无效的请求体："+err.Error())
+		return
+	}
+	modelNames := make([]string, 0)
+	if request.Model != "" {
+		modelNames = append(modelNames, request.Model)
+	}
+	for _, name := range strings.Split(c.Query("models"), ",") {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(modelNames, name) {
+			modelNames = append(modelNames, name)
+		}
+	}
+	if len(modelNames) == 0 {
+		abortWithOpenAIError(c, http.StatusBadRequest, "model 不能为空")
+		return
+	}
+	completionTokens := request.MaxTokens
+	if request.MaxCompletionTokens != nil {
+		completionTokens = *request.MaxCompletionTokens
+	}
+	group, _ := model.CacheGetUserGroup(c.GetInt(ctxkey.Id))
+	availableModels := getAvailableModels(c)
+	isAdmin := model.IsAdmin(c.GetInt(ctxkey.Id))
+	estimates := make([]billingEstimate, 0)
+	for _, modelName := range modelNames {
+		if !slices.Contains(availableModels, modelName) {
+			continue
+		}
+		channels, err := model.GetGroupModelChannels(group, modelName)
+		if err != nil {
+			abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, channel := range channels {
+			actualModel := modelName
+			if mapped := channel.GetModelMapping()[modelName]; mapped != "" {
+				actualModel = mapped
+			}
+			estimate := billingEstimate{
+				Model:            modelName,
+				ChannelId:        channel.Id,
+				ChannelType:      channel.Type,
+				Priority:         channel.GetPriority(),
+				ActualModel:      actualModel,
+				ModelRatio:       billingratio.GetModelRatio(actualModel, channel.Type),
+				CompletionRatio:  billingratio.GetCompletionRatio(actualModel, channel.Type),
+				GroupRatio:       billingratio.GetGroupModelRatio(group, actualModel),
+				PromptTokens:     countEstimatePromptTokens(&request, actualModel),
+				CompletionTokens: completionTokens,
+			}
+			cfg, _ := channel.LoadConfig()
+			if limit := defaults.GetMaxTokens(cfg.MaxTokens, actualModel, modelName); limit > 0 && (completionTokens == 0 || completionTokens > limit) {
+				// the relay caps the answer to the limit, or sets it when the request does not
+				estimate.CompletionTokens = limit
+			}
+			if isAdmin {
+				estimate.ChannelName = channel.Name
+			}
+			ratio := estimate.ModelRatio * estimate.GroupRatio
+			estimate.Quota = int64(math.Ceil((float64(estimate.PromptTokens) + float64(estimate.CompletionTokens)*estimate.CompletionRatio) * ratio))
+			if ratio != 0 && estimate.Quota <= 0 {
+				estimate.Quota = 1
+			}
+			estimate.Cost = float64(estimate.Quota) / config.QuotaPerUnit
+			estimate.QuotaPer1KCompletionTokens = int64(math.Ceil(1000 * estimate.CompletionRatio * ratio))
+			estimates = append(estimates, estimate)
+		}
+	}
+	sort.SliceStable(estimates, func(i, j int) bool {
+		return estimates[i].Quota < estimates[j].Quota
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"object": "billing.estimate",
+		"data":   estimates,
+	})
+}
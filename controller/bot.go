@@ -0,0 +1,305 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/message"
+	"github.com/songquanpeng/one-api/model"
+)
+
+const botHelp = `可用命令：
+/bind <绑定码>：将当前会话绑定到你的账户
+/unbind：解除当前会话的绑定
+/model <模型>：切换对话使用的模型，不带参数时显示当前模型
+/reset：清空对话上下文
+/usage：查看今日用量（管理员）
+/disable_channel <渠道 ID>：禁用渠道（管理员）
+/enable_channel <渠道 ID>：启用渠道（管理员）
+开启机器人对话后，直接发送消息即可与模型对话，消耗计入你的账户额度`
+
+// handleBotCommand runs the command sent in the chat and returns the reply, the other messages
+// are sent to the model of the chat if BotChatEnabled, the admin commands are only allowed in the chats bound to an admin
+func handleBotCommand(platform string, chatId string, text string) string {
+	fields := strings.Fields(text)
+	// skip the mentions of the bot in group chats
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return botHelp
+	}
+	isCommand := strings.HasPrefix(fields[0], "/")
+	command := strings.TrimPrefix(fields[0], "/")
+	// telegram appends the bot name to the commands in group chats, e.g. /usage@one_api_bot
+	command, _, _ = strings.Cut(command, "@")
+	args := fields[1:]
+	switch command {
+	case "bind":
+		if len(args) != 1 {
+			return "用法：/bind <绑定码>，绑定码可在个人设置中获取"
+		}
+		user, err := model.BindBotChat(platform, chatId, args[0])
+		if err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("已绑定到用户 %s，渠道与额度告警将推送到此会话", user.Username)
+	case "help", "start":
+		if isCommand || !config.BotChatEnabled {
+			return botHelp
+		}
+	}
+	chat, err := model.GetBotChat(platform, chatId)
+	if err != nil {
+		return "当前会话未绑定，请先发送 /bind <绑定码>"
+	}
+	if command == "unbind" {
+		if err = model.UnbindBotChat(platform, chatId); err != nil {
+			return err.Error()
+		}
+		return "已解除绑定"
+	}
+	user, err := model.GetUserById(chat.UserId, false)
+	if err != nil || user.Status != model.UserStatusEnabled {
+		return "无权进行此操作"
+	}
+	if !isCommand && config.BotChatEnabled {
+		return chatWithModel(chat, strings.Join(fields, " "))
+	}
+	switch command {
+	case "model":
+		if len(args) == 0 {
+			modelName := chat.Model
+			if modelName == "" {
+				modelName = config.BotChatModel
+			}
+			return "当前模型：" + modelName
+		}
+		if err = model.UpdateBotChatModel(chat.Id, args[0]); err != nil {
+			return err.Error()
+		}
+		return "已切换到模型 " + args[0]
+	case "reset":
+		resetBotChatHistory(chat.Id)
+		return "已清空对话上下文"
+	}
+	if user.Role < model.RoleAdminUser {
+		return "无权进行此操作"
+	}
+	switch command {
+	case "usage":
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		stat, err := model.GetUsageStat(today.Unix(), now.Unix()+1)
+		if err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("今日用量：\n请求次数：%d\n提示 token 数：%d\n补全 token 数：%d\n消耗额度：%s",
+			stat.RequestCount, stat.PromptTokens, stat.CompletionTokens, common.LogQuota(stat.Quota))
+	case "disable_channel", "enable_channel":
+		if len(args) != 1 {
+			return fmt.Sprintf("用法：/%s <渠道 ID>", command)
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return "渠道 ID 无效"
+		}
+		channel, err := model.GetChannelById(id, false)
+		if err != nil {
+			return "渠道不存在"
+		}
+		if command == "disable_channel" {
+			model.UpdateChannelStatusById(id, model.ChannelStatusManuallyDisabled)
+			logger.SysLogf("channel #%d is disabled by user %d via %s bot", id, user.Id, platform)
+			return fmt.Sprintf("渠道「%s」（#%d）已禁用", channel.Name, id)
+		}
+		model.UpdateChannelStatusById(id, model.ChannelStatusEnabled)
+		logger.SysLogf("channel #%d is enabled by user %d via %s bot", id, user.Id, platform)
+		return fmt.Sprintf("渠道「%s」（#%d）已启用", channel.Name, id)
+	}
+	return botHelp
+}
+
+func replyBotMessage(platform string, chatId string, text string) {
+	reply := handleBotCommand(platform, chatId, text)
+	if err := message.SendBotMessage(platform, chatId, reply); err != nil {
+		logger.SysError(fmt.Sprintf("failed to reply %s bot message: %s", platform, err.Error()))
+	}
+}
+
+func TelegramBotWebhook(c *gin.Context) {
+	secretToken := c.GetHeader("X-Telegram-Bot-Api-Secret-Token")
+	if config.TelegramBotToken == "" || config.TelegramWebhookSecretToken == "" ||
+		!hmac.Equal([]byte(secretToken), []byte(config.TelegramWebhookSecretToken)) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+	var update struct {
+		Message *struct {
+			Chat struct {
+				Id int64 `json:"id"`
+			} `json:"chat"`
+			Text string `json:"text"`
+		} `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	if update.Message != nil && update.Message.Text != "" {
+		go replyBotMessage(message.BotTelegram, strconv.FormatInt(update.Message.Chat.Id, 10), update.Message.Text)
+	}
+	c.Status(http.StatusOK)
+}
+
+// LarkBotWebhook receives the events of the lark bot app, the encryption of the events must be disabled
+func LarkBotWebhook(c *gin.Context) {
+	var event struct {
+		// url verification
+		Type      string `json:"type"`
+		Token     string `json:"token"`
+		Challenge string `json:"challenge"`
+		// events of schema 2.0
+		Header struct {
+			EventType string `json:"event_type"`
+			Token     string `json:"token"`
+		} `json:"header"`
+		Event struct {
+			Message struct {
+				ChatId      string `json:"chat_id"`
+				MessageType string `json:"message_type"`
+				Content     string `json:"content"`
+			} `json:"message"`
+		} `json:"event"`
+	}
+	if err := c.ShouldBindJSON(&event); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	token := event.Token
+	if event.Type != "url_verification" {
+		token = event.Header.Token
+	}
+	if config.LarkBotVerificationToken == "" || !hmac.Equal([]byte(token), []byte(config.LarkBotVerificationToken)) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+	if event.Type == "url_verification" {
+		c.JSON(http.StatusOK, gin.H{"challenge": event.Challenge})
+		return
+	}
+	if event.Header.EventType == "im.message.receive_v1" && event.Event.Message.MessageType == "text" {
+		var content struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal([]byte(event.Event.Message.Content), &content); err == nil {
+			go replyBotMessage(message.BotLark, event.Event.Message.ChatId, content.Text)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func verifyDingTalkSign(timestamp string, sign string) bool {
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.UnixMilli(ms)).Abs() > time.Hour {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(config.DingTalkBotAppSecret))
+	mac.Write([]byte(timestamp + "\n" + config.DingTalkBotAppSecret))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(sign), []byte(expected))
+}
+
+// DingTalkBotWebhook receives the messages of the dingtalk robot, the reply is sent to the session webhook
+// since the chats with the models may take longer than the robot waits for the response
+func DingTalkBotWebhook(c *gin.Context) {
+	if config.DingTalkBotAppSecret == "" || !verifyDingTalkSign(c.GetHeader("timestamp"), c.GetHeader("sign")) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+	var msg struct {
+		ConversationId string `json:"conversationId"`
+		SessionWebhook string `json:"sessionWebhook"`
+		Text           struct {
+			Content string `json:"content"`
+		} `json:"text"`
+	}
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	go func() {
+		reply := handleBotCommand(message.BotDingTalk, msg.ConversationId, msg.Text.Content)
+		if err := message.SendDingTalkSessionWebhook(msg.SessionWebhook, reply); err != nil {
+			logger.SysError("failed to reply dingtalk bot message: " + err.Error())
+		}
+	}()
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func GetBotBindCode(c *gin.Context) {
+	if !config.BotChatEnabled && c.GetInt(ctxkey.Role) < model.RoleAdminUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员未开启机器人对话",
+		})
+		return
+	}
+	code, err := model.GenerateBotBindCode(c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    code,
+	})
+}
+
+func GetBotChats(c *gin.Context) {
+	chats, err := model.GetUserBotChats(c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    chats,
+	})
+}
+
+func DeleteBotChat(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteUserBotChat(id, c.GetInt(ctxkey.Id)); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// BotRelayHandler serves the chats with the models of the bots and the assistant runs through the relay routes,
+// so that they are authorized and billed like any other request, it is set to the server in main
+var BotRelayHandler http.Handler
+
+// botChatHistorySize is how many messages of a chat are kept as the context of the next one
+const botChatHistorySize = 20
+
+var botChatHistoriesLock sync.Mutex
+var botChatHistories = map[int][]relaymodel.Message{}
+
+func resetBotChatHistory(chatId int) {
+	botChatHistoriesLock.Lock()
+	defer botChatHistoriesLock.Unlock()
+	delete(botChatHistories, chatId)
+}
+
+// relayInternal posts the request with the token key to the relay route path and decodes the response
+func relayInternal(key string, path string, request any, response any) error {
+	return relayInternalWithContext(context.Background(), key, path, request, response)
+}
+
+// relayInternalWithContext is relayInternal with the context of the request, which carries its values to the logs
+func relayInternalWithContext(ctx context.Context, key string, path string, request any, response any) error {
+	if BotRelayHandler == nil {
+		return errors.New("relay handler is not set")
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)).WithContext(ctx)
+	req.RemoteAddr = "127.0.0.1:0"
+	req.Header.Set("Authorization", "Bearer sk-"+key)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	BotRelayHandler.ServeHTTP(w, req)
+	var errorResponse struct {
+		Error *relaymodel.Error `json:"error"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &errorResponse); err != nil {
+		return errors.New("解析响应失败：" + err.Error())
+	}
+	if errorResponse.Error != nil && errorResponse.Error.Message != "" {
+		return errors.New("请求失败：" + errorResponse.Error.Message)
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), response); err != nil {
+		return errors.New("解析响应失败：" + err.Error())
+	}
+	return nil
+}
+
+// relayInternalRaw posts the body with the token key to the relay route path, the response is written to w as it
+// is, e.g. for the multipart requests or the audio responses
+func relayInternalRaw(ctx context.Context, key string, path string, contentType string, body []byte, w http.ResponseWriter) error {
+	if BotRelayHandler == nil {
+		return errors.New("relay handler is not set")
+	}
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)).WithContext(ctx)
+	req.RemoteAddr = "127.0.0.1:0"
+	req.Header.Set("Authorization", "Bearer sk-"+key)
+	req.Header.Set("Content-Type", contentType)
+	BotRelayHandler.ServeHTTP(w, req)
+	return nil
+}
+
+// relayChatCompletion sends the request with the token key to the relay routes
+func relayChatCompletion(key string, request *relaymodel.GeneralOpenAIRequest) (*openai.TextResponse, error) {
+	response := &openai.TextResponse{}
+	if err := relayInternal(key, "/v1/chat/completions", request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// relayEmbeddings sends the request with the token key to the relay routes
+func relayEmbeddings(key string, request *relaymodel.GeneralOpenAIRequest) (*openai.EmbeddingResponse, error) {
+	response := &openai.EmbeddingResponse{}
+	if err := relayInternal(key, "/v1/embeddings", request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func chatWithModel(chat *model.BotChat, text string) string {
+	if BotRelayHandler == nil {
+		return "机器人对话不可用"
+	}
+	token, err := model.GetBotToken(chat.UserId)
+	if err != nil {
+		return "获取令牌失败：" + err.Error()
+	}
+	modelName := chat.Model
+	if modelName == "" {
+		modelName = config.BotChatModel
+	}
+	botChatHistoriesLock.Lock()
+	messages := append([]relaymodel.Message{}, botChatHistories[chat.Id]...)
+	botChatHistoriesLock.Unlock()
+	messages = append(messages, relaymodel.Message{Role: "user", Content: text})
+	response, err := relayChatCompletion(token.Key, &relaymodel.GeneralOpenAIRequest{
+		Model:    modelName,
+		Messages: messages,
+	})
+	if err != nil {
+		return err.Error()
+	}
+	if len(response.Choices) == 0 {
+		return "模型没有返回内容"
+	}
+	reply := response.Choices[0].StringContent()
+	messages = append(messages, relaymodel.Message{Role: "assistant", Content: reply})
+	if len(messages) > botChatHistorySize {
+		messages = messages[len(messages)-botChatHistorySize:]
+	}
+	botChatHistoriesLock.Lock()
+	botChatHistories[chat.Id] = messages
+	botChatHistoriesLock.Unlock()
+	return reply
+}
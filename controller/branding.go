@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/theme"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// the branding of a white-label deployment, each field is an option of its own
+type brandingRequest struct {
+	SystemName *string `json:"system_name"`
+	Logo       *string `json:"logo"`
+	Favicon    *string `json:"favicon"`
+	Footer     *string `json:"footer_html"`
+	Theme      *string `json:"theme"`
+}
+
+func GetBranding(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"system_name": config.SystemName,
+			"logo":        config.Logo,
+			"favicon":     config.Favicon,
+			"footer_html": config.Footer,
+			"theme":       config.Theme,
+		},
+	})
+}
+
+// UpdateBranding updates the fields given in the request, the others are kept
+func UpdateBranding(c *gin.Context) {
+	var request brandingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if request.Theme != nil && !config.ThemeExists(*request.Theme) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的主题",
+		})
+		return
+	}
+	options := []struct {
+		key   string
+		value *string
+	}{
+		{"SystemName", request.SystemName},
+		{"Logo", request.Logo},
+		{"Favicon", request.Favicon},
+		{"Footer", request.Footer},
+		{"Theme", request.Theme},
+	}
+	for _, option := range options {
+		if option.value == nil {
+			continue
+		}
+		if err := model.UpdateOption(option.key, *option.value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	GetBranding(c)
+}
+
+func GetThemes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    theme.List(),
+	})
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/capability"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// checkCapabilities rejects the chat completions the model cannot serve and adapts those it can serve otherwise,
+// after the long context is condensed and the images are described for the channel
+func checkCapabilities(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatusCode {
+	if relayMode != relaymode.ChatCompletions {
+		return nil
+	}
+	originalModel := c.GetString(ctxkey.OriginalModel)
+	actualModel := c.GetStringMapString(ctxkey.ModelMapping)[originalModel]
+	capabilities := capability.GetFirst(actualModel, originalModel)
+	if capabilities.IsEmpty() {
+		return nil
+	}
+	request, err := common.GetRequestObject(c)
+	if err != nil {
+		// the relay reports the invalid request
+		return nil
+	}
+	err = capability.Adapt(request, capabilities, originalModel, func(messages []relaymodel.Message) int {
+		return openai.CountTokenMessages(messages, originalModel)
+	})
+	var capabilityErr *capability.Error
+	if errors.As(err, &capabilityErr) {
+		return openai.ErrorWrapper(err, capabilityErr.Code, http.StatusBadRequest)
+	}
+	if err != nil {
+		return openai.ErrorWrapper(err, "adapt_request_failed", http.StatusInternalServerError)
+	}
+	return nil
+}
@@ -134,7 +134,7 @@ func GetResponseBody(method, url string, channel *model.Channel, headers http.He
 	for k := range headers {
 		req.Header.Add(k, headers.Get(k))
 	}
-	res, err := client.HTTPClient.Do(req)
+	res, err := channel.HTTPClient(client.HTTPClient).Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -452,6 +452,9 @@ func UpdateAllChannelsBalance(c *gin.Context) {
 func AutomaticallyUpdateChannels(frequency int) {
 	for {
 		time.Sleep(time.Duration(frequency) * time.Minute)
+		if !model.IsLeader() {
+			continue
+		}
 		logger.SysLog("updating all channels")
 		_ = updateAllChannelsBalance()
 		logger.SysLog("channels update done")
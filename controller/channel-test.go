@@ -65,8 +65,8 @@ func parseTestResponse(resp string) (*openai.TextResponse, string, error) {
 	return &response, stringContent, nil
 }
 
-func testChannel(ctx context.Context, channel *model.Channel, request *relaymodel.GeneralOpenAIRequest) (responseMessage string, err error, openaiErr *relaymodel.Error) {
-	startTime := time.Now()
+// newChannelTestContext builds the context of a chat completion sent to the channel, as the relay would
+func newChannelTestContext(channel *model.Channel) (*gin.Context, *httptest.ResponseRecorder) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	c.Request = &http.Request{
@@ -82,24 +82,37 @@ func testChannel(ctx context.Context, channel *model.Channel, request *relaymode
 	cfg, _ := channel.LoadConfig()
 	c.Set(ctxkey.Config, cfg)
 	middleware.SetupContextForSelectedChannel(c, channel, "")
-	meta := meta.GetByContext(c)
-	apiType := channeltype.ToAPIType(channel.Type)
-	adaptor := relay.GetAdaptor(apiType)
-	if adaptor == nil {
-		return "", fmt.Errorf("invalid api type: %d, adaptor is nil", apiType), nil
-	}
-	adaptor.Init(meta)
-	modelName := request.Model
-	modelMap := channel.GetModelMapping()
+	return c, w
+}
+
+// getTestModelName returns the model of the channel to test, which is the first one of the channel unless the
+// requested one is served by it, and the model sent to the upstream after the model mapping of the channel
+func getTestModelName(channel *model.Channel, requestModel string) (checkedModelName string, modelName string) {
+	modelName = requestModel
 	if modelName == "" || !strings.Contains(channel.Models, modelName) {
 		modelNames := strings.Split(channel.Models, ",")
 		if len(modelNames) > 0 {
 			modelName = modelNames[0]
 		}
 	}
-	if modelMap != nil && modelMap[modelName] != "" {
+	checkedModelName = modelName
+	if modelMap := channel.GetModelMapping(); modelMap != nil && modelMap[modelName] != "" {
 		modelName = modelMap[modelName]
 	}
+	return checkedModelName, modelName
+}
+
+func testChannel(ctx context.Context, channel *model.Channel, request *relaymodel.GeneralOpenAIRequest) (responseMessage string, err error, openaiErr *relaymodel.Error) {
+	startTime := time.Now()
+	c, w := newChannelTestContext(channel)
+	meta := meta.GetByContext(c)
+	apiType := channeltype.ToAPIType(channel.Type)
+	adaptor := relay.GetAdaptor(apiType)
+	if adaptor == nil {
+		return "", fmt.Errorf("invalid api type: %d, adaptor is nil", apiType), nil
+	}
+	adaptor.Init(meta)
+	checkedModelName, modelName := getTestModelName(channel, request.Model)
 	meta.OriginModelName, meta.ActualModelName = request.Model, modelName
 	request.Model = modelName
 	convertedRequest, err := adaptor.ConvertRequest(c, relaymode.ChatCompletions, request)
@@ -121,12 +134,14 @@ func testChannel(ctx context.Context, channel *model.Channel, request *relaymode
 			}
 			logContent = fmt.Sprintf("渠道 %s 测试失败，错误：%s", channel.Name, errorMessage)
 		}
+		elapsedTime := helper.CalcElapsedTime(startTime)
 		go model.RecordTestLog(ctx, &model.Log{
 			ChannelId:   channel.Id,
 			ModelName:   modelName,
 			Content:     logContent,
-			ElapsedTime: helper.CalcElapsedTime(startTime),
+			ElapsedTime: elapsedTime,
 		})
+		go model.RecordChannelCheck(channel.Id, checkedModelName, err == nil && openaiErr == nil, elapsedTime)
 	}()
 	logger.SysLog(string(jsonData))
 	requestBody := bytes.NewBuffer(jsonData)
@@ -237,6 +252,9 @@ func testChannels(ctx context.Context, notify bool, scope string) error {
 	}
 	go func() {
 		for _, channel := range channels {
+			if model.IsChannelInMaintenance(channel.Id) {
+				continue
+			}
 			isChannelEnabled := channel.Status == model.ChannelStatusEnabled
 			tik := time.Now()
 			testRequest := buildTestRequest("")
@@ -298,6 +316,9 @@ func AutomaticallyTestChannels(frequency int) {
 	ctx := context.Background()
 	for {
 		time.Sleep(time.Duration(frequency) * time.Minute)
+		if !model.IsLeader() {
+			continue
+		}
 		logger.SysLog("testing all channels")
 		_ = testChannels(ctx, false, "all")
 		logger.SysLog("channel test finished")
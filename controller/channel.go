@@ -5,6 +5,7 @@ import (
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/apiversion"
 	"net/http"
 	"strconv"
 	"strings"
@@ -104,8 +105,9 @@ func AddChannel(c *gin.Context) {
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "",
+		"success":  true,
+		"message":  "",
+		"warnings": checkChannelAPIVersion(&channel),
 	})
 	return
 }
@@ -163,10 +165,13 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	// the versions rejected before are tried again with the new config
+	apiversion.ResetUnsupported(channel.Id)
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "",
-		"data":    channel,
+		"success":  true,
+		"message":  "",
+		"data":     channel,
+		"warnings": checkChannelAPIVersion(&channel),
 	})
 	return
 }
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/apiversion"
+)
+
+// checkChannelAPIVersion returns the warnings about the API version configured for the channel, the version of
+// the old channels is in other
+func checkChannelAPIVersion(channel *model.Channel) []string {
+	cfg, _ := channel.LoadConfig()
+	version := cfg.APIVersion
+	if version == "" && channel.Other != nil {
+		version = *channel.Other
+	}
+	warnings := apiversion.Check(channel.Type, version)
+	if len(warnings) > 0 && cfg.AutoUpgradeAPIVersion {
+		warnings = append(warnings, "已开启自动升级 API 版本，请求将使用 "+
+			apiversion.Resolve(channel.Id, channel.Type, version, true))
+	}
+	return warnings
+}
+
+// GetChannelAPIVersions returns the API versions known for each channel type and the versions rejected by the
+// upstream of each channel
+func GetChannelAPIVersions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"catalogs": apiversion.GetCatalogs(),
+			"rejected": apiversion.GetRejected(),
+		},
+	})
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/defaults"
+)
+
+// ExplainChannelSelection tells the channels a request for the model would be sent to right now, from the groups
+// of the user of token_id, or from the given groups
+func ExplainChannelSelection(c *gin.Context) {
+	requestModel := c.Query("model")
+	if requestModel == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "未指定模型",
+		})
+		return
+	}
+	data := gin.H{"model": requestModel}
+	// the deprecated models are replaced before the channel is chosen
+	if replacement, ok := defaults.GetModelReplacement(requestModel); ok {
+		data["replaced_by"] = replacement
+		requestModel = replacement
+	}
+	var memberGroups []string
+	if tokenId, _ := strconv.Atoi(c.Query("token_id")); tokenId != 0 {
+		token, err := model.GetTokenById(tokenId)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "令牌不存在",
+			})
+			return
+		}
+		memberGroups, err = model.CacheGetUserGroups(token.UserId)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		if plan, _ := model.CacheGetUserPlan(token.UserId); plan != nil {
+			memberGroups = append(memberGroups, plan.GetGroups()...)
+		}
+		if token.Models != nil && *token.Models != "" && !slices.Contains(strings.Split(*token.Models, ","), requestModel) {
+			data["message"] = fmt.Sprintf("该令牌无权使用模型：%s", requestModel)
+		}
+	} else {
+		for _, group := range strings.Split(c.Query("group"), ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				memberGroups = append(memberGroups, group)
+			}
+		}
+	}
+	if len(memberGroups) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "未指定令牌或分组",
+		})
+		return
+	}
+	selections, err := model.ExplainChannelSelection(memberGroups, requestModel)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	data["groups"] = memberGroups
+	data["selections"] = selections
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
@@ -0,0 +1,385 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+)
+
+// the channels are imported from the management APIs of the providers, the addresses are variables for the tests
+var (
+	azureLoginURL      = "https://login.microsoftonline.com"
+	azureManagementURL = "https://management.azure.com"
+	openAIAPIURL       = "https://api.openai.com"
+)
+
+const azureManagementAPIVersion = "2023-05-01"
+
+const (
+	ChannelImportCreated = "created"
+	ChannelImportExists  = "exists"
+	ChannelImportPreview = "preview"
+	ChannelImportFailed  = "failed"
+)
+
+type channelImportRequest struct {
+	Provider string `json:"provider"` // azure or openai
+	Group    string `json:"group"`
+	// DryRun lists the channels which would be imported, without creating them or any key
+	DryRun bool `json:"dry_run"`
+	// the subscription of Azure, with an access token of the management API or a service principal
+	SubscriptionId string `json:"subscription_id"`
+	AccessToken    string `json:"access_token"`
+	TenantId       string `json:"tenant_id"`
+	ClientId       string `json:"client_id"`
+	ClientSecret   string `json:"client_secret"`
+	APIVersion     string `json:"api_version"`
+	// the admin key of the organization of OpenAI
+	AdminKey string `json:"admin_key"`
+}
+
+type channelImportResult struct {
+	Name       string   `json:"name"`
+	ExternalId string   `json:"external_id"`
+	Models     []string `json:"models"`
+	Status     string   `json:"status"`
+	Message    string   `json:"message,omitempty"`
+	ChannelId  int      `json:"channel_id,omitempty"`
+}
+
+// callProviderAPI sends the request to the management API of the provider and decodes the JSON response
+func callProviderAPI(ctx context.Context, method string, url string, header http.Header, body io.Reader, result any) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	for key := range header {
+		req.Header.Set(key, header.Get(key))
+	}
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status code %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// channelImportExternalId identifies the account or the project the channel is imported from,
+// so that importing again skips it
+func channelImportExternalId(provider string, id string) string {
+	externalId := provider + ":" + id
+	if len(externalId) > 64 {
+		externalId = externalId[:64]
+	}
+	return externalId
+}
+
+func isChannelImported(externalId string) bool {
+	_, err := model.GetChannelByExternalId(externalId, false)
+	return err == nil
+}
+
+func createImportedChannel(result *channelImportResult, channel *model.Channel) {
+	channel.Name = result.Name
+	channel.ExternalId = result.ExternalId
+	channel.Models = strings.Join(result.Models, ",")
+	channel.Status = model.ChannelStatusEnabled
+	channel.CreatedTime = helper.GetTimestamp()
+	channels := []model.Channel{*channel}
+	if err := model.BatchInsertChannels(channels); err != nil {
+		result.Status = ChannelImportFailed
+		result.Message = err.Error()
+		return
+	}
+	result.Status = ChannelImportCreated
+	result.ChannelId = channels[0].Id
+}
+
+func getAzureAccessToken(ctx context.Context, request *channelImportRequest) (string, error) {
+	if request.AccessToken != "" {
+		return request.AccessToken, nil
+	}
+	if request.TenantId == "" || request.ClientId == "" || request.ClientSecret == "" {
+		return "", errors.New("需要提供 access_token，或 tenant_id、client_id 与 client_secret")
+	}
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", request.ClientId)
+	form.Set("client_secret", request.ClientSecret)
+	form.Set("scope", azureManagementURL+"/.default")
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", azureLoginURL, url.PathEscape(request.TenantId))
+	if err := callProviderAPI(ctx, http.MethodPost, tokenURL, header, strings.NewReader(form.Encode()), &token); err != nil {
+		return "", fmt.Errorf("获取 Azure 访问令牌失败：%w", err)
+	}
+	return token.AccessToken, nil
+}
+
+type azureAccount struct {
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	Properties struct {
+		Endpoint string `json:"endpoint"`
+	} `json:"properties"`
+}
+
+type azureDeployment struct {
+	Name       string `json:"name"`
+	Properties struct {
+		Model struct {
+			Name string `json:"name"`
+		} `json:"model"`
+		ProvisioningState string `json:"provisioningState"`
+	} `json:"properties"`
+}
+
+// listAzurePages follows the nextLink of the lists of the management API
+func listAzurePages[T any](ctx context.Context, url string, header http.Header) ([]T, error) {
+	var items []T
+	for url != "" {
+		var page struct {
+			Value    []T    `json:"value"`
+			NextLink string `json:"nextLink"`
+		}
+		if err := callProviderAPI(ctx, http.MethodGet, url, header, nil, &page); err != nil {
+			return nil, err
+		}
+		items = append(items, page.Value...)
+		url = page.NextLink
+	}
+	return items, nil
+}
+
+// importAzureChannels creates a channel for each Azure OpenAI resource of the subscription, the models are those
+// of the deployments, which are mapped to the deployments of other names
+func importAzureChannels(ctx context.Context, request *channelImportRequest) ([]*channelImportResult, error) {
+	if request.SubscriptionId == "" {
+		return nil, errors.New("subscription_id 不能为空")
+	}
+	accessToken, err := getAzureAccessToken(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+accessToken)
+	accountsURL := fmt.Sprintf("%s/subscriptions/%s/providers/Microsoft.CognitiveServices/accounts?api-version=%s",
+		azureManagementURL, url.PathEscape(request.SubscriptionId), azureManagementAPIVersion)
+	accounts, err := listAzurePages[azureAccount](ctx, accountsURL, header)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Azure 资源列表失败：%w", err)
+	}
+	apiVersion := request.APIVersion
+	if apiVersion == "" {
+		apiVersion = defaultAzureAPIVersion
+	}
+	results := make([]*channelImportResult, 0, len(accounts))
+	for _, account := range accounts {
+		if account.Kind != "OpenAI" && account.Kind != "AIServices" {
+			continue
+		}
+		result := &channelImportResult{Name: "azure/" + account.Name, ExternalId: channelImportExternalId("azure", account.Name)}
+		results = append(results, result)
+		deploymentsURL := fmt.Sprintf("%s%s/deployments?api-version=%s", azureManagementURL, account.Id, azureManagementAPIVersion)
+		deployments, err := listAzurePages[azureDeployment](ctx, deploymentsURL, header)
+		if err != nil {
+			result.Status = ChannelImportFailed
+			result.Message = "获取部署列表失败：" + err.Error()
+			continue
+		}
+		modelMapping := make(map[string]string)
+		for _, deployment := range deployments {
+			modelName := deployment.Properties.Model.Name
+			if modelName == "" || deployment.Properties.ProvisioningState != "Succeeded" {
+				continue
+			}
+			if _, ok := modelMapping[modelName]; ok {
+				continue
+			}
+			modelMapping[modelName] = deployment.Name
+			result.Models = append(result.Models, modelName)
+		}
+		sort.Strings(result.Models)
+		switch {
+		case len(result.Models) == 0:
+			result.Status = ChannelImportFailed
+			result.Message = "没有可用的部署"
+			continue
+		case isChannelImported(result.ExternalId):
+			result.Status = ChannelImportExists
+			continue
+		case request.DryRun:
+			result.Status = ChannelImportPreview
+			continue
+		}
+		var keys struct {
+			Key1 string `json:"key1"`
+		}
+		keysURL := fmt.Sprintf("%s%s/listKeys?api-version=%s", azureManagementURL, account.Id, azureManagementAPIVersion)
+		if err = callProviderAPI(ctx, http.MethodPost, keysURL, header, nil, &keys); err != nil {
+			result.Status = ChannelImportFailed
+			result.Message = "获取密钥失败：" + err.Error()
+			continue
+		}
+		// the adaptor sends the requests to the deployment named as the model without the dots
+		for modelName, deployment := range modelMapping {
+			if deployment == strings.ReplaceAll(modelName, ".", "") {
+				delete(modelMapping, modelName)
+			}
+		}
+		channel := &model.Channel{Type: channeltype.Azure, Key: keys.Key1, Group: request.Group}
+		baseURL := strings.TrimSuffix(account.Properties.Endpoint, "/")
+		channel.BaseURL = &baseURL
+		if len(modelMapping) > 0 {
+			data, _ := json.Marshal(modelMapping)
+			mapping := string(data)
+			channel.ModelMapping = &mapping
+		}
+		data, _ := json.Marshal(model.ChannelConfig{APIVersion: apiVersion})
+		channel.Config = string(data)
+		createImportedChannel(result, channel)
+	}
+	return results, nil
+}
+
+type openAIProject struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// importOpenAIChannels creates a channel for each active project of the organization, with the key of a service
+// account created in the project, the models are those listed with the key
+func importOpenAIChannels(ctx context.Context, request *channelImportRequest) ([]*channelImportResult, error) {
+	if request.AdminKey == "" {
+		return nil, errors.New("admin_key 不能为空")
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+request.AdminKey)
+	header.Set("Content-Type", "application/json")
+	var projects []openAIProject
+	after := ""
+	for {
+		projectsURL := openAIAPIURL + "/v1/organization/projects?limit=100"
+		if after != "" {
+			projectsURL += "&after=" + url.QueryEscape(after)
+		}
+		var page struct {
+			Data    []openAIProject `json:"data"`
+			HasMore bool            `json:"has_more"`
+			LastId  string          `json:"last_id"`
+		}
+		if err := callProviderAPI(ctx, http.MethodGet, projectsURL, header, nil, &page); err != nil {
+			return nil, fmt.Errorf("获取 OpenAI 项目列表失败：%w", err)
+		}
+		projects = append(projects, page.Data...)
+		if !page.HasMore || page.LastId == "" {
+			break
+		}
+		after = page.LastId
+	}
+	results := make([]*channelImportResult, 0, len(projects))
+	for _, project := range projects {
+		if project.Status != "" && project.Status != "active" {
+			continue
+		}
+		result := &channelImportResult{Name: "openai/" + project.Name, ExternalId: channelImportExternalId("openai", project.Id)}
+		results = append(results, result)
+		if isChannelImported(result.ExternalId) {
+			result.Status = ChannelImportExists
+			continue
+		}
+		if request.DryRun {
+			// the models are known with the key of the project only
+			result.Status = ChannelImportPreview
+			continue
+		}
+		var serviceAccount struct {
+			APIKey struct {
+				Value string `json:"value"`
+			} `json:"api_key"`
+		}
+		serviceAccountsURL := fmt.Sprintf("%s/v1/organization/projects/%s/service_accounts", openAIAPIURL, url.PathEscape(project.Id))
+		if err := callProviderAPI(ctx, http.MethodPost, serviceAccountsURL, header, bytes.NewReader([]byte(`{"name":"one-api"}`)), &serviceAccount); err != nil {
+			result.Status = ChannelImportFailed
+			result.Message = "创建服务账号失败：" + err.Error()
+			continue
+		}
+		var models struct {
+			Data []struct {
+				Id string `json:"id"`
+			} `json:"data"`
+		}
+		if err := callProviderAPI(ctx, http.MethodGet, openAIAPIURL+"/v1/models", GetAuthHeader(serviceAccount.APIKey.Value), nil, &models); err != nil {
+			result.Status = ChannelImportFailed
+			result.Message = "获取模型列表失败：" + err.Error()
+			continue
+		}
+		for _, m := range models.Data {
+			result.Models = append(result.Models, m.Id)
+		}
+		sort.Strings(result.Models)
+		createImportedChannel(result, &model.Channel{Type: channeltype.OpenAI, Key: serviceAccount.APIKey.Value, Group: request.Group})
+	}
+	return results, nil
+}
+
+// ImportChannels creates the channels of the resources of an Azure subscription or the projects of an OpenAI
+// organization, those imported before are skipped by their external id
+func ImportChannels(c *gin.Context) {
+	var request channelImportRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if request.Group == "" {
+		request.Group = "default"
+	}
+	var results []*channelImportResult
+	var err error
+	switch request.Provider {
+	case "azure":
+		results, err = importAzureChannels(c.Request.Context(), &request)
+	case "openai":
+		results, err = importOpenAIChannels(c.Request.Context(), &request)
+	default:
+		err = errors.New("provider 只能为 azure 或 openai")
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    results,
+	})
+}
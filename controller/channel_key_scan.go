@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/monitor"
+	"github.com/songquanpeng/one-api/relay/apitype"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+)
+
+const defaultAzureAPIVersion = "2024-02-01"
+
+// newKeyScanRequest builds the request listing the models of the upstream, which checks the key without cost,
+// it returns nil for the channel types whose keys cannot be checked this way
+func newKeyScanRequest(channel *model.Channel) (*http.Request, error) {
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" && channel.Type >= 0 && channel.Type < len(channeltype.ChannelBaseURLs) {
+		baseURL = channeltype.ChannelBaseURLs[channel.Type]
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if baseURL == "" {
+		return nil, nil
+	}
+	var url string
+	header := http.Header{}
+	switch apiType := channeltype.ToAPIType(channel.Type); {
+	case channel.Type == channeltype.Azure:
+		cfg, _ := channel.LoadConfig()
+		version := cfg.APIVersion
+		if version == "" {
+			version = defaultAzureAPIVersion
+		}
+		url = fmt.Sprintf("%s/openai/models?api-version=%s", baseURL, version)
+		header.Set("api-key", channel.Key)
+	case apiType == apitype.Anthropic:
+		url = baseURL + "/v1/models"
+		header.Set("x-api-key", channel.Key)
+		header.Set("anthropic-version", "2023-06-01")
+	case apiType == apitype.Gemini:
+		url = baseURL + "/v1beta/models"
+		header.Set("x-goog-api-key", channel.Key)
+	case apiType == apitype.OpenAI:
+		url = baseURL + "/v1/models"
+		header.Set("Authorization", "Bearer "+channel.Key)
+	default:
+		return nil, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	return req, nil
+}
+
+func scanChannelKey(channel *model.Channel) *model.ChannelKeyScan {
+	scan := &model.ChannelKeyScan{ChannelId: channel.Id, ChannelName: channel.Name}
+	req, err := newKeyScanRequest(channel)
+	if err != nil {
+		scan.Result = monitor.KeyError
+		scan.Message = err.Error()
+		return scan
+	}
+	if req == nil {
+		scan.Result = monitor.KeyUnsupported
+		return scan
+	}
+	resp, err := channel.HTTPClient(client.HTTPClient).Do(req)
+	if err != nil {
+		scan.Result = monitor.KeyError
+		scan.Message = err.Error()
+		return scan
+	}
+	defer resp.Body.Close()
+	scan.StatusCode = resp.StatusCode
+	if resp.StatusCode == http.StatusOK {
+		scan.Result = monitor.KeyValid
+		return scan
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	scan.Result = monitor.ClassifyKeyFailure(resp.StatusCode, string(body))
+	scan.Message = fmt.Sprintf("status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	return scan
+}
+
+var scanChannelKeysLock sync.Mutex
+var scanChannelKeysRunning bool
+
+// scanChannelKeys checks the keys of all channels, the channels with an invalid key are disabled and the
+// channels disabled automatically with a valid key are enabled again, as the channel tests do
+func scanChannelKeys() ([]*model.ChannelKeyScan, error) {
+	scanChannelKeysLock.Lock()
+	if scanChannelKeysRunning {
+		scanChannelKeysLock.Unlock()
+		return nil, errors.New("密钥检查已在运行中")
+	}
+	scanChannelKeysRunning = true
+	scanChannelKeysLock.Unlock()
+	defer func() {
+		scanChannelKeysLock.Lock()
+		scanChannelKeysRunning = false
+		scanChannelKeysLock.Unlock()
+	}()
+	channels, err := model.GetAllChannels(0, 0, "all")
+	if err != nil {
+		return nil, err
+	}
+	scans := make([]*model.ChannelKeyScan, 0, len(channels))
+	for _, channel := range channels {
+		if model.IsChannelInMaintenance(channel.Id) {
+			continue
+		}
+		scan := scanChannelKey(channel)
+		scans = append(scans, scan)
+		switch {
+		case monitor.IsKeyInvalid(scan.Result) && channel.Status == model.ChannelStatusEnabled && config.AutomaticDisableChannelEnabled:
+			monitor.DisableChannel(channel.Id, channel.Name, fmt.Sprintf("密钥%s：%s", monitor.KeyResultName(scan.Result), scan.Message))
+		case scan.Result == monitor.KeyValid && channel.Status == model.ChannelStatusAutoDisabled && config.AutomaticEnableChannelEnabled:
+			monitor.EnableChannel(channel.Id, channel.Name)
+		}
+		time.Sleep(config.RequestInterval)
+	}
+	if err = model.RecordChannelKeyScans(scans); err != nil {
+		logger.SysError("failed to record channel key scans: " + err.Error())
+	}
+	return scans, nil
+}
+
+// ScanChannelKeys starts checking the keys of all channels, the results are listed by GetChannelKeyScans
+func ScanChannelKeys(c *gin.Context) {
+	scanChannelKeysLock.Lock()
+	running := scanChannelKeysRunning
+	scanChannelKeysLock.Unlock()
+	if running {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "密钥检查已在运行中",
+		})
+		return
+	}
+	go func() {
+		if _, err := scanChannelKeys(); err != nil {
+			logger.SysError("failed to scan channel keys: " + err.Error())
+		}
+	}()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// GetChannelKeyScans lists the last key check of each channel
+func GetChannelKeyScans(c *gin.Context) {
+	scans, err := model.GetLatestChannelKeyScans()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    scans,
+	})
+}
+
+// AutomaticallyScanChannelKeys checks the keys of the channels every frequency minutes on the leader node,
+// and sends the report of the first scan of each day
+func AutomaticallyScanChannelKeys(frequency int) {
+	lastReportedDay := ""
+	for {
+		time.Sleep(time.Duration(frequency) * time.Minute)
+		if !model.IsLeader() {
+			continue
+		}
+		logger.SysLog("scanning channel keys")
+		scans, err := scanChannelKeys()
+		if err != nil {
+			logger.SysError("failed to scan channel keys: " + err.Error())
+			continue
+		}
+		logger.SysLog("channel key scan finished")
+		if day := time.Now().Format("2006-01-02"); day != lastReportedDay {
+			monitor.ReportChannelKeyScans(scans)
+			lastReportedDay = day
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/model"
+)
+
+func formatUsageTime(timestamp int64) string {
+	if timestamp == 0 {
+		return ""
+	}
+	return time.Unix(timestamp, 0).UTC().Format(time.RFC3339)
+}
+
+// GetChannelKeyUsages returns the usage of every key of the channels, for the keys still used to be known before
+// the credentials of the providers are rotated, format=csv downloads it as a spreadsheet
+func GetChannelKeyUsages(c *gin.Context) {
+	// the usage recorded in memory is written first, for the export to be up to date
+	model.FlushChannelKeyUsages()
+	records, err := model.GetChannelKeyUsages()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "",
+			"data":    records,
+		})
+		return
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=channel-key-usage-%s.csv", time.Now().Format("20060102150405")))
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"channel_id", "channel_name", "channel_type", "channel_status", "deleted", "current",
+		"key_fingerprint", "key_hint", "first_used_time", "last_used_time", "request_count", "used_quota"})
+	for _, record := range records {
+		_ = w.Write([]string{
+			strconv.Itoa(record.ChannelId),
+			record.ChannelName,
+			strconv.Itoa(record.ChannelType),
+			strconv.Itoa(record.ChannelStatus),
+			strconv.FormatBool(record.Deleted),
+			strconv.FormatBool(record.Current),
+			record.KeyFingerprint,
+			record.KeyHint,
+			formatUsageTime(record.FirstUsedTime),
+			formatUsageTime(record.LastUsedTime),
+			strconv.FormatInt(record.RequestCount, 10),
+			strconv.FormatInt(record.UsedQuota, 10),
+		})
+	}
+	w.Flush()
+}
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// the conversations keep the history of chat completions on the server, a chat completion carrying the
+// X-OneAPI-Conversation-Id header only sends the new messages, see middleware/conversation.go
+
+type conversationRequest struct {
+	MaxMessages *int            `json:"max_messages"`
+	MaxTokens   *int            `json:"max_tokens"`
+	Metadata    json.RawMessage `json:"metadata"`
+	// Messages are only used when the conversation is created, e.g. to continue a chat started elsewhere
+	Messages []json.RawMessage `json:"messages"`
+}
+
+func conversationObject(conversation *model.Conversation) gin.H {
+	return gin.H{
+		"id":           conversation.ConversationId,
+		"object":       "conversation",
+		"created_at":   conversation.CreatedAt,
+		"updated_at":   conversation.UpdatedAt,
+		"max_messages": nullable(conversation.MaxMessages),
+		"max_tokens":   nullable(conversation.MaxTokens),
+		"metadata":     rawJSON(conversation.Metadata, "{}"),
+	}
+}
+
+func conversationMessageObject(message *model.ConversationMessage) gin.H {
+	return gin.H{
+		"id":              message.MessageId,
+		"object":          "conversation.message",
+		"created_at":      message.CreatedAt,
+		"conversation_id": message.ConversationId,
+		"role":            message.Role,
+		"message":         rawJSON(message.Message, "{}"),
+	}
+}
+
+func toConversationMessage(raw json.RawMessage) (*model.ConversationMessage, error) {
+	var message relaymodel.Message
+	if err := json.Unmarshal(raw, &message); err != nil {
+		return nil, fmt.Errorf("无效的消息：%w", err)
+	}
+	if message.Role != "user" && message.Role != "assistant" && message.Role != "tool" {
+		return nil, fmt.Errorf("role 必须是 user、assistant 或 tool")
+	}
+	return &model.ConversationMessage{Role: message.Role, Message: string(raw)}, nil
+}
+
+// applyConversationRequest checks the limits and the metadata of the request and applies them to the conversation
+func applyConversationRequest(c *gin.Context, conversation *model.Conversation, request *conversationRequest) bool {
+	if request.MaxMessages != nil {
+		if *request.MaxMessages < 0 || *request.MaxMessages > config.ConversationMaxMessages {
+			abortWithOpenAIError(c, http.StatusBadRequest, fmt.Sprintf("max_messages 必须在 0 到 %d 之间", config.ConversationMaxMessages))
+			return false
+		}
+		conversation.MaxMessages = *request.MaxMessages
+	}
+	if request.MaxTokens != nil {
+		if *request.MaxTokens < 0 {
+			abortWithOpenAIError(c, http.StatusBadRequest, "max_tokens 不能为负数")
+			return false
+		}
+		conversation.MaxTokens = *request.MaxTokens
+	}
+	var err error
+	if conversation.Metadata, err = normalizeMetadata(request.Metadata, conversation.Metadata); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+func getUserConversation(c *gin.Context) (*model.Conversation, bool) {
+	conversation, err := model.GetUserConversation(c.GetInt(ctxkey.Id), c.Param("id"))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusNotFound, fmt.Sprintf("conversation %s 不存在", c.Param("id")))
+		return nil, false
+	}
+	return conversation, true
+}
+
+func CreateConversation(c *gin.Context) {
+	var request conversationRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	conversation := &model.Conversation{UserId: c.GetInt(ctxkey.Id)}
+	if !applyConversationRequest(c, conversation, &request) {
+		return
+	}
+	var messages []*model.ConversationMessage
+	for _, raw := range request.Messages {
+		message, err := toConversationMessage(raw)
+		if err != nil {
+			abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		messages = append(messages, message)
+	}
+	if err := model.InsertConversation(conversation, messages); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, conversationObject(conversation))
+}
+
+func ListConversations(c *gin.Context) {
+	params := getListParams(c)
+	conversations, err := model.GetUserConversations(c.GetInt(ctxkey.Id), params)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	data := make([]gin.H, 0, len(conversations))
+	for _, conversation := range conversations {
+		data = append(data, conversationObject(conversation))
+	}
+	listResponse(c, data, params.Limit)
+}
+
+func RetrieveConversation(c *gin.Context) {
+	conversation, ok := getUserConversation(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, conversationObject(conversation))
+}
+
+func ModifyConversation(c *gin.Context) {
+	conversation, ok := getUserConversation(c)
+	if !ok {
+		return
+	}
+	var request conversationRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	if !applyConversationRequest(c, conversation, &request) {
+		return
+	}
+	if err := conversation.Update(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, conversationObject(conversation))
+}
+
+func DeleteConversation(c *gin.Context) {
+	conversation, ok := getUserConversation(c)
+	if !ok {
+		return
+	}
+	if err := conversation.Delete(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      conversation.ConversationId,
+		"object":  "conversation.deleted",
+		"deleted": true,
+	})
+}
+
+func ListConversationMessages(c *gin.Context) {
+	conversation, ok := getUserConversation(c)
+	if !ok {
+		return
+	}
+	params := getListParams(c)
+	messages, err := model.GetConversationMessages(conversation.ConversationId, params)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	data := make([]gin.H, 0, len(messages))
+	for _, message := range messages {
+		data = append(data, conversationMessageObject(message))
+	}
+	listResponse(c, data, params.Limit)
+}
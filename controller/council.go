@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// councilMaxModels is how many models a council asks at most
+const councilMaxModels = 8
+const councilStage = "模型会审"
+
+// councilRequest is a chat completion asked to each of the models, the judge model picks the best answer if judge is set
+type councilRequest struct {
+	relaymodel.GeneralOpenAIRequest
+	Models []string `json:"models"`
+	Judge  bool     `json:"judge"`
+}
+
+type councilAnswer struct {
+	Index        int                 `json:"index"`
+	Model        string              `json:"model"`
+	Message      *relaymodel.Message `json:"message,omitempty"`
+	FinishReason string              `json:"finish_reason,omitempty"`
+	Usage        *relaymodel.Usage   `json:"usage,omitempty"`
+	Error        string              `json:"error,omitempty"`
+}
+
+type councilVerdict struct {
+	Index  int    `json:"index"`
+	Model  string `json:"model"`
+	Reason string `json:"reason"`
+}
+
+// councilModels returns the models of the request, or the ones of the deployment, without the duplicates
+func councilModels(models []string) []string {
+	if len(models) == 0 {
+		models = strings.Split(config.CouncilModels, ",")
+	}
+	var result []string
+	seen := make(map[string]bool)
+	for _, name := range models {
+		name = strings.TrimSpace(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// buildCouncilJudgePrompt numbers the answers returned from 1, the failed ones are left out
+func buildCouncilJudgePrompt(messages []relaymodel.Message, answers []councilAnswer) (string, []int) {
+	var question strings.Builder
+	for _, message := range messages {
+		question.WriteString(message.Role + ": " + message.StringContent() + "\n")
+	}
+	var numbered strings.Builder
+	var indexes []int
+	for i, answer := range answers {
+		if answer.Message == nil {
+			continue
+		}
+		indexes = append(indexes, i)
+		numbered.WriteString(fmt.Sprintf("[%d]\n%s\n\n", len(indexes), answer.Message.StringContent()))
+	}
+	prompt := strings.NewReplacer(
+		"{{question}}", strings.TrimSpace(question.String()),
+		"{{answers}}", strings.TrimSpace(numbered.String()),
+	).Replace(config.CouncilJudgePrompt)
+	return prompt, indexes
+}
+
+// parseCouncilVerdict reads the JSON object of the judge, best is the number of the answer in the prompt
+func parseCouncilVerdict(content string, count int) (best int, reason string, err error) {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return 0, "", errors.New("the judge model did not reply a JSON object")
+	}
+	var verdict struct {
+		Best   int    `json:"best"`
+		Reason string `json:"reason"`
+	}
+	if err = json.Unmarshal([]byte(content[start:end+1]), &verdict); err != nil {
+		return 0, "", errors.New("invalid reply of the judge model: " + err.Error())
+	}
+	if verdict.Best < 1 || verdict.Best > count {
+		return 0, "", fmt.Errorf("the best answer of the judge model is not from 1 to %d", count)
+	}
+	return verdict.Best, verdict.Reason, nil
+}
+
+func addUsage(total *relaymodel.Usage, usage relaymodel.Usage) {
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+}
+
+// Council asks the models the same chat completion at the same time and returns all the answers, with the best one
+// picked by the judge model of the deployment if asked; each completion is relayed with the key of the token, so it
+// is billed and logged as its own request
+func Council(c *gin.Context) {
+	var request councilRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	models := councilModels(request.Models)
+	if len(models) == 0 {
+		abortWithOpenAIError(c, http.StatusBadRequest, "models 不能为空，且未配置默认的会审模型")
+		return
+	}
+	if len(models) > councilMaxModels {
+		abortWithOpenAIError(c, http.StatusBadRequest, fmt.Sprintf("会审最多支持 %d 个模型", councilMaxModels))
+		return
+	}
+	if len(request.Messages) == 0 {
+		abortWithOpenAIError(c, http.StatusBadRequest, "messages 不能为空")
+		return
+	}
+	if request.Judge && config.JudgeModel == "" {
+		abortWithOpenAIError(c, http.StatusNotImplemented, "未配置评估模型")
+		return
+	}
+	token, err := model.GetTokenById(c.GetInt(ctxkey.TokenId))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusUnauthorized, "令牌不存在")
+		return
+	}
+	ctx := helper.SetPipelineStage(c.Request.Context(), c.GetString(helper.RequestIdKey), councilStage)
+	answers := make([]councilAnswer, len(models))
+	var wg sync.WaitGroup
+	for i, name := range models {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			answers[i] = councilAnswer{Index: i, Model: name}
+			completion := request.GeneralOpenAIRequest
+			completion.Model = name
+			completion.Stream = false
+			completion.StreamOptions = nil
+			completion.N = 0
+			response := &openai.TextResponse{}
+			err := relayInternalWithContext(ctx, token.Key, "/v1/chat/completions", &completion, response)
+			if err == nil && len(response.Choices) == 0 {
+				err = errors.New("模型没有返回内容")
+			}
+			if err != nil {
+				answers[i].Error = err.Error()
+				return
+			}
+			answers[i].Message = &response.Choices[0].Message
+			answers[i].FinishReason = response.Choices[0].FinishReason
+			answers[i].Usage = &response.Usage
+		}(i, name)
+	}
+	wg.Wait()
+
+	usage := relaymodel.Usage{}
+	answered := 0
+	for _, answer := range answers {
+		if answer.Usage != nil {
+			addUsage(&usage, *answer.Usage)
+			answered++
+		}
+	}
+	if answered == 0 {
+		abortWithOpenAIError(c, http.StatusBadGateway, answers[0].Error)
+		return
+	}
+	result := gin.H{
+		"id":      "council-" + random.GetUUID(),
+		"object":  "council",
+		"created": helper.GetTimestamp(),
+		"answers": answers,
+	}
+	if request.Judge {
+		prompt, indexes := buildCouncilJudgePrompt(request.Messages, answers)
+		temperature := 0.0
+		judgement := &openai.TextResponse{}
+		err = relayInternalWithContext(ctx, token.Key, "/v1/chat/completions", &relaymodel.GeneralOpenAIRequest{
+			Model:       config.JudgeModel,
+			Temperature: &temperature,
+			Messages:    []relaymodel.Message{{Role: "user", Content: prompt}},
+		}, judgement)
+		if err == nil && len(judgement.Choices) == 0 {
+			err = errors.New("the judge model returned no answer")
+		}
+		var best int
+		var reason string
+		if err == nil {
+			addUsage(&usage, judgement.Usage)
+			best, reason, err = parseCouncilVerdict(judgement.Choices[0].StringContent(), len(indexes))
+		}
+		if err != nil {
+			// the answers are returned anyway, they have been billed
+			result["judge_error"] = err.Error()
+		} else {
+			index := indexes[best-1]
+			result["best"] = councilVerdict{Index: index, Model: answers[index].Model, Reason: reason}
+		}
+		result["judge_model"] = config.JudgeModel
+	}
+	result["usage"] = usage
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/model"
+)
+
+func checkCoupon(coupon *model.Coupon) error {
+	coupon.Name = strings.TrimSpace(coupon.Name)
+	if coupon.Name == "" || len(coupon.Name) > 64 {
+		return errors.New("优惠券名称不能为空且不能超过 64 个字符")
+	}
+	if coupon.Scope != model.CouponScopeTopUp && coupon.Scope != model.CouponScopeUsage {
+		return errors.New("优惠券的范围应为 top_up 或 usage")
+	}
+	if coupon.DiscountType != model.CouponDiscountPercentage && coupon.DiscountType != model.CouponDiscountFixed {
+		return errors.New("优惠券的折扣类型应为 percentage 或 fixed")
+	}
+	if coupon.Value <= 0 || coupon.DiscountType == model.CouponDiscountPercentage && coupon.Value > 100 {
+		return errors.New("百分比折扣应在 0 到 100 之间，固定折扣应大于 0")
+	}
+	if coupon.EndTime != 0 && coupon.EndTime <= coupon.StartTime {
+		return errors.New("结束时间应晚于开始时间")
+	}
+	if coupon.MaxRedemptions < 0 || coupon.PerUserLimit < 0 {
+		return errors.New("使用次数上限不能为负数")
+	}
+	if coupon.Status != model.CouponStatusDisabled {
+		coupon.Status = model.CouponStatusEnabled
+	}
+	coupon.Models = strings.Join(coupon.GetModels(), ",")
+	coupon.Groups = strings.Join(coupon.GetGroups(), ",")
+	return nil
+}
+
+func GetAllCoupons(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	coupons, err := model.GetAllCoupons(p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    coupons,
+	})
+	return
+}
+
+func AddCoupon(c *gin.Context) {
+	coupon := model.Coupon{}
+	err := c.ShouldBindJSON(&coupon)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	coupon.Id = 0
+	coupon.RedeemedCount = 0
+	coupon.Code = strings.TrimSpace(coupon.Code)
+	if coupon.Code == "" {
+		coupon.Code = strings.ToUpper(random.GetRandomString(12))
+	}
+	if len(coupon.Code) > 32 {
+		err = errors.New("优惠码不能超过 32 个字符")
+	}
+	if err == nil {
+		err = checkCoupon(&coupon)
+	}
+	if err == nil {
+		err = coupon.Insert()
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    coupon,
+	})
+	return
+}
+
+func UpdateCoupon(c *gin.Context) {
+	coupon := model.Coupon{}
+	err := c.ShouldBindJSON(&coupon)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = checkCoupon(&coupon); err == nil {
+		_, err = model.GetCouponById(coupon.Id)
+	}
+	if err == nil {
+		err = coupon.Update()
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	updated, err := model.GetCouponById(coupon.Id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    updated,
+	})
+	return
+}
+
+func DeleteCoupon(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteCouponById(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// GetCouponRedemptions returns the uses of the coupon, the latest first, with their totals
+func GetCouponRedemptions(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	coupon, err := model.GetCouponById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	report, err := model.GetCouponReport(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	redemptions, err := model.GetCouponRedemptions(id, p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"coupon":      coupon,
+			"report":      report,
+			"redemptions": redemptions,
+		},
+	})
+	return
+}
+
+type claimCouponRequest struct {
+	Code string `json:"code"`
+}
+
+// ClaimCoupon gives the user the discount of a usage coupon, the top-up coupons are given with a redemption code
+func ClaimCoupon(c *gin.Context) {
+	var req claimCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	coupon, err := model.ClaimCoupon(c.Request.Context(), req.Code, c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"name":          coupon.Name,
+			"discount_type": coupon.DiscountType,
+			"value":         coupon.Value,
+			"models":        coupon.Models,
+			"end_time":      coupon.EndTime,
+		},
+	})
+	return
+}
+
+func GetSelfCoupons(c *gin.Context) {
+	coupons, err := model.GetUserCoupons(c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    coupons,
+	})
+	return
+}
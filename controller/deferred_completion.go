@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+)
+
+const deferredHeader = "X-OneAPI-Deferred"
+
+// deferredMaxWait is the longest a poll waits for the completion to finish, unit is second
+const deferredMaxWait = 60
+const deferredPollInterval = time.Second
+
+// DeferredCompletion answers the chat completions sent with the X-OneAPI-Deferred header with the id of the completion
+// right away, the completion is executed in the background as an async task started on this node, and its result
+// is polled at /v1/chat/completions/{id} or posted to the url of the X-OneAPI-Callback-URL header.
+// It goes after the token authentication, the limits of the token apply when the completion is executed
+func DeferredCompletion() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if c.GetHeader(deferredHeader) != "true" || c.FullPath() != "/v1/chat/completions" {
+			c.Next()
+			return
+		}
+		submitDeferredCompletion(c)
+		c.Abort()
+	}
+}
+
+func submitDeferredCompletion(c *gin.Context) {
+	if config.AsyncTaskConcurrency <= 0 {
+		abortWithOpenAIError(c, http.StatusServiceUnavailable, "异步任务未启用")
+		return
+	}
+	group, err := model.CacheGetUserGroup(c.GetInt(ctxkey.Id))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !model.IsFeatureEnabled(model.FeatureAsync, group) {
+		abortWithOpenAIError(c, http.StatusForbidden, fmt.Sprintf("功能 %s 尚未对分组 %s 开放", model.FeatureAsync, group))
+		return
+	}
+	task := newAsyncTask(c, "/chat/completions")
+	if task == nil {
+		return
+	}
+	task.TaskId = model.NewObjectId("deferred_")
+	task.TTL = int64(config.DeferredCompletionTTL)
+	if err = task.Insert(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if startAsyncTask(task) {
+		go executeAsyncTask(task)
+	}
+	c.JSON(http.StatusAccepted, asyncTaskObject(task))
+}
+
+// RetrieveDeferredCompletion returns the response of the finished completion with its status code, the completion
+// not finished yet is waited for the seconds of the wait query, then returned with 202
+func RetrieveDeferredCompletion(c *gin.Context) {
+	userId := c.GetInt(ctxkey.Id)
+	task, err := model.GetUserAsyncTask(userId, c.Param("id"))
+	if err != nil || task.TTL == 0 || task.IsExpired() {
+		abortWithOpenAIError(c, http.StatusNotFound, "补全不存在或已过期")
+		return
+	}
+	wait, _ := strconv.Atoi(c.Query("wait"))
+	if wait > deferredMaxWait {
+		wait = deferredMaxWait
+	}
+	deadline := time.Now().Add(time.Duration(wait) * time.Second)
+	for !model.IsAsyncTaskFinished(task.Status) && time.Now().Before(deadline) {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-time.After(deferredPollInterval):
+		}
+		if task, err = model.GetUserAsyncTask(userId, c.Param("id")); err != nil {
+			abortWithOpenAIError(c, http.StatusNotFound, "补全不存在或已过期")
+			return
+		}
+	}
+	if !model.IsAsyncTaskFinished(task.Status) {
+		c.JSON(http.StatusAccepted, asyncTaskObject(task))
+		return
+	}
+	c.Data(task.StatusCode, "application/json", []byte(task.Response))
+}
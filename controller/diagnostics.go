@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	runtimepprof "runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+// GetPprof serves the profiles of net/http/pprof under /api/debug/pprof/ on ADMIN_LISTEN, such as heap,
+// goroutine?debug=2, profile?seconds=30 and trace?seconds=5
+func GetPprof(c *gin.Context) {
+	switch name := strings.Trim(c.Param("name"), "/"); name {
+	case "":
+		// the links of the index are relative to the directory
+		if !strings.HasSuffix(c.Request.URL.Path, "/") {
+			c.Redirect(http.StatusMovedPermanently, config.BasePath+c.Request.URL.Path+"/")
+			return
+		}
+		pprof.Index(c.Writer, c.Request)
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		if runtimepprof.Lookup(name) == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "未知的 profile：" + name,
+			})
+			return
+		}
+		pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// runtimeStats is the memory and the scheduler of the process, for the memory growth to be watched without
+// taking a profile
+func runtimeStats() gin.H {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	// the pauses of the last GC cycles, the most recent first
+	var pauses []uint64
+	for i := 0; i < 10 && uint32(i) < mem.NumGC; i++ {
+		pauses = append(pauses, mem.PauseNs[(mem.NumGC+255-uint32(i))%256])
+	}
+	var lastGC int64
+	if mem.LastGC != 0 {
+		lastGC = time.Unix(0, int64(mem.LastGC)).Unix()
+	}
+	inFlightLock.Lock()
+	inFlight := len(inFlightRequests)
+	inFlightLock.Unlock()
+	return gin.H{
+		"go_version":         runtime.Version(),
+		"num_cpu":            runtime.NumCPU(),
+		"gomaxprocs":         runtime.GOMAXPROCS(0),
+		"uptime":             time.Now().Unix() - common.StartTime,
+		"goroutines":         runtime.NumGoroutine(),
+		"in_flight_requests": inFlight,
+		"heap_alloc":         mem.HeapAlloc,
+		"heap_inuse":         mem.HeapInuse,
+		"heap_idle":          mem.HeapIdle,
+		"heap_released":      mem.HeapReleased,
+		"heap_objects":       mem.HeapObjects,
+		"stack_inuse":        mem.StackInuse,
+		"sys":                mem.Sys,
+		"total_alloc":        mem.TotalAlloc,
+		"mallocs":            mem.Mallocs,
+		"frees":              mem.Frees,
+		"num_gc":             mem.NumGC,
+		"next_gc":            mem.NextGC,
+		"last_gc":            lastGC,
+		"pause_total_ns":     mem.PauseTotalNs,
+		"recent_pauses_ns":   pauses,
+		"gc_cpu_fraction":    mem.GCCPUFraction,
+	}
+}
+
+func GetRuntimeStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    runtimeStats(),
+	})
+}
+
+// ForceGC runs a garbage collection and returns the memory to the OS, to tell a leak from memory not yet collected
+func ForceGC(c *gin.Context) {
+	debug.FreeOSMemory()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    runtimeStats(),
+	})
+}
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+type evaluationRequest struct {
+	Question  string `json:"question"`
+	Answer    string `json:"answer"`
+	Reference string `json:"reference"`
+	// RequestId is the X-Oneapi-Request-Id of the relayed request which returned the answer
+	RequestId string `json:"request_id"`
+}
+
+type judgement struct {
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+// parseJudgement reads the JSON object of the judge, which may be wrapped in a code block or some words
+func parseJudgement(content string) (*judgement, error) {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("the judge model did not reply a JSON object")
+	}
+	var result judgement
+	if err := json.Unmarshal([]byte(content[start:end+1]), &result); err != nil {
+		return nil, errors.New("invalid reply of the judge model: " + err.Error())
+	}
+	if result.Score < 1 || result.Score > 10 {
+		return nil, errors.New("the score of the judge model is not from 1 to 10")
+	}
+	return &result, nil
+}
+
+// CreateEvaluation asks the judge model of the deployment to score the answer, the answers of the requests
+// relayed by the gateway are saved with their channel and experiment variant, to compare them later
+func CreateEvaluation(c *gin.Context) {
+	if config.JudgeModel == "" {
+		abortWithOpenAIError(c, http.StatusNotImplemented, "未配置评估模型")
+		return
+	}
+	var request evaluationRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	if strings.TrimSpace(request.Question) == "" || strings.TrimSpace(request.Answer) == "" {
+		abortWithOpenAIError(c, http.StatusBadRequest, "question 与 answer 不能为空")
+		return
+	}
+	userId := c.GetInt(ctxkey.Id)
+	var log *model.Log
+	if request.RequestId != "" {
+		var err error
+		if log, err = model.GetUserConsumeLogByRequestId(userId, request.RequestId); err != nil {
+			abortWithOpenAIError(c, http.StatusNotFound, "请求 "+request.RequestId+" 的日志不存在")
+			return
+		}
+	}
+	token, err := model.GetTokenById(c.GetInt(ctxkey.TokenId))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusUnauthorized, "令牌不存在")
+		return
+	}
+	reference := request.Reference
+	if reference == "" {
+		reference = "(none)"
+	}
+	prompt := strings.NewReplacer(
+		"{{question}}", request.Question,
+		"{{answer}}", request.Answer,
+		"{{reference}}", reference,
+	).Replace(config.JudgePrompt)
+	temperature := 0.0
+	response, err := relayChatCompletion(token.Key, &relaymodel.GeneralOpenAIRequest{
+		Model:       config.JudgeModel,
+		Temperature: &temperature,
+		Messages:    []relaymodel.Message{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	if len(response.Choices) == 0 {
+		abortWithOpenAIError(c, http.StatusBadGateway, "the judge model returned no answer")
+		return
+	}
+	result, err := parseJudgement(response.Choices[0].StringContent())
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	evaluation := &model.Evaluation{
+		UserId:     userId,
+		RequestId:  request.RequestId,
+		JudgeModel: config.JudgeModel,
+		Score:      result.Score,
+		Reason:     result.Reason,
+	}
+	if log != nil {
+		evaluation.ChannelId = log.ChannelId
+		evaluation.ModelName = log.ModelName
+		evaluation.Experiment = log.Experiment
+		if err = evaluation.Insert(); err != nil {
+			logger.Errorf(c.Request.Context(), "failed to save evaluation: %s", err.Error())
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":      "evaluation",
+		"created":     helper.GetTimestamp(),
+		"judge_model": config.JudgeModel,
+		"score":       result.Score,
+		"reason":      result.Reason,
+		"request_id":  request.RequestId,
+		"channel_id":  evaluation.ChannelId,
+		"experiment":  evaluation.Experiment,
+		"usage":       response.Usage,
+	})
+}
+
+// GetChannelEvaluations compares the scores of the answers of the channels, to spot the channels
+// returning degraded answers; days is the number of days looked back, 7 by default
+func GetChannelEvaluations(c *gin.Context) {
+	days, _ := strconv.Atoi(c.Query("days"))
+	if days <= 0 {
+		days = 7
+	}
+	reports, err := model.GetChannelEvaluationReports(helper.GetTimestamp() - int64(days)*24*3600)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    reports,
+	})
+	return
+}
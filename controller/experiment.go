@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/model"
+)
+
+func checkExperiment(experiment *model.Experiment) error {
+	if experiment.Name == "" || len(experiment.Name) > 32 {
+		return errors.New("名称不能为空且不能超过 32 个字符")
+	}
+	if experiment.Model == "" || experiment.ModelA == "" || experiment.ModelB == "" {
+		return errors.New("实验模型及两个变体的模型均不能为空")
+	}
+	if experiment.Percentage < 0 || experiment.Percentage > 100 {
+		return errors.New("流量比例须在 0 到 100 之间")
+	}
+	if experiment.Status == 0 {
+		experiment.Status = model.ExperimentStatusRunning
+	}
+	return nil
+}
+
+func GetAllExperiments(c *gin.Context) {
+	experiments, err := model.GetAllExperiments()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    experiments,
+	})
+	return
+}
+
+func AddExperiment(c *gin.Context) {
+	experiment := model.Experiment{}
+	err := c.ShouldBindJSON(&experiment)
+	if err == nil {
+		err = checkExperiment(&experiment)
+	}
+	if err == nil {
+		err = experiment.Insert()
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    experiment,
+	})
+	return
+}
+
+func UpdateExperiment(c *gin.Context) {
+	experiment := model.Experiment{}
+	err := c.ShouldBindJSON(&experiment)
+	if err == nil {
+		err = checkExperiment(&experiment)
+	}
+	if err == nil {
+		_, err = model.GetExperimentById(experiment.Id)
+	}
+	if err == nil {
+		err = experiment.Update()
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    experiment,
+	})
+	return
+}
+
+func DeleteExperiment(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteExperimentById(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// GetExperimentReport compares the requests, latency, cost and length of the variants
+func GetExperimentReport(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	experiment, err := model.GetExperimentById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	reports, err := model.GetExperimentReport(experiment.Name)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    reports,
+	})
+	return
+}
@@ -0,0 +1,461 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/apiversion"
+)
+
+// The endpoints in this file are keyed by an external id chosen by the caller,
+// PUT creates the resource if it doesn't exist and updates it otherwise, so it is safe to retry.
+// GET and PUT return an ETag, PUT honors If-Match (update only if unchanged)
+// and If-None-Match: * (create only).
+
+func etag(v any) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// checkPrecondition writes 412 and returns false if the If-Match or If-None-Match header is not satisfied
+func checkPrecondition(c *gin.Context, exists bool, currentETag string) bool {
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" {
+		if !exists || (ifMatch != "*" && ifMatch != currentETag) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{
+				"success": false,
+				"message": "资源已被修改，请重新获取后再试",
+			})
+			return false
+		}
+	}
+	if c.GetHeader("If-None-Match") == "*" && exists {
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"success": false,
+			"message": "资源已存在",
+		})
+		return false
+	}
+	return true
+}
+
+func findByExternalId[T any](find func() (*T, error)) (*T, bool, error) {
+	v, err := find()
+	if err == nil {
+		return v, true, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	return nil, false, err
+}
+
+func respondExternal(c *gin.Context, statusCode int, data any) {
+	c.Header("ETag", etag(data))
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
+
+func respondExternalError(c *gin.Context, statusCode int, err error) {
+	c.JSON(statusCode, gin.H{
+		"success": false,
+		"message": err.Error(),
+	})
+}
+
+func GetChannelByExternalId(c *gin.Context) {
+	channel, exists, err := findByExternalId(func() (*model.Channel, error) {
+		return model.GetChannelByExternalId(c.Param("external_id"), false)
+	})
+	if err != nil {
+		respondExternalError(c, http.StatusOK, err)
+		return
+	}
+	if !exists {
+		respondExternalError(c, http.StatusNotFound, errors.New("渠道不存在"))
+		return
+	}
+	respondExternal(c, http.StatusOK, channel)
+}
+
+// PutChannelByExternalId creates or updates the channel of the external id, which is one channel with a single key,
+// the key is kept when updated without one
+func PutChannelByExternalId(c *gin.Context) {
+	externalId := c.Param("external_id")
+	channel := model.Channel{}
+	if err := c.ShouldBindJSON(&channel); err != nil {
+		respondExternalError(c, http.StatusBadRequest, err)
+		return
+	}
+	channel.Key = strings.TrimSpace(channel.Key)
+	if strings.Contains(channel.Key, "\n") {
+		respondExternalError(c, http.StatusBadRequest, errors.New("一个 external id 对应一个渠道，key 只能包含一个密钥"))
+		return
+	}
+	origin, exists, err := findByExternalId(func() (*model.Channel, error) {
+		return model.GetChannelByExternalId(externalId, false)
+	})
+	if err != nil {
+		respondExternalError(c, http.StatusOK, err)
+		return
+	}
+	currentETag := ""
+	if exists {
+		currentETag = etag(origin)
+	}
+	if !checkPrecondition(c, exists, currentETag) {
+		return
+	}
+	if !exists && channel.Key == "" {
+		respondExternalError(c, http.StatusBadRequest, errors.New("创建渠道时 key 不能为空"))
+		return
+	}
+	channel.ExternalId = externalId
+	if !exists {
+		channel.Id = 0
+		channel.CreatedTime = helper.GetTimestamp()
+		if err = channel.Insert(); err != nil {
+			// a concurrent PUT of the same external id created it first, the unique index rejected this one
+			origin, exists, _ = findByExternalId(func() (*model.Channel, error) {
+				return model.GetChannelByExternalId(externalId, false)
+			})
+			if !exists {
+				respondExternalError(c, http.StatusOK, err)
+				return
+			}
+			if !checkPrecondition(c, true, etag(origin)) {
+				return
+			}
+		}
+	}
+	if exists {
+		channel.Id = origin.Id
+		if err = channel.Update(); err != nil {
+			respondExternalError(c, http.StatusOK, err)
+			return
+		}
+		// the versions rejected before are tried again with the new config
+		apiversion.ResetUnsupported(channel.Id)
+	}
+	updated, err := model.GetChannelById(channel.Id, false)
+	if err != nil {
+		respondExternalError(c, http.StatusOK, err)
+		return
+	}
+	if exists {
+		respondExternal(c, http.StatusOK, updated)
+	} else {
+		respondExternal(c, http.StatusCreated, updated)
+	}
+}
+
+func DeleteChannelByExternalId(c *gin.Context) {
+	channel, exists, err := findByExternalId(func() (*model.Channel, error) {
+		return model.GetChannelByExternalId(c.Param("external_id"), false)
+	})
+	if err != nil {
+		respondExternalError(c, http.StatusOK, err)
+		return
+	}
+	// deleting twice is not an error
+	if exists {
+		if !checkPrecondition(c, true, etag(channel)) {
+			return
+		}
+		if err = channel.Delete(); err != nil {
+			respondExternalError(c, http.StatusOK, err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetTokenByExternalId(c *gin.Context) {
+	token, exists, err := findByExternalId(func() (*model.Token, error) {
+		return model.GetTokenByExternalId(c.GetInt(ctxkey.Id), c.Param("external_id"))
+	})
+	if err != nil {
+		respondExternalError(c, http.StatusOK, err)
+		return
+	}
+	if !exists {
+		respondExternalError(c, http.StatusNotFound, errors.New("令牌不存在"))
+		return
+	}
+	respondExternal(c, http.StatusOK, token)
+}
+
+func PutTokenByExternalId(c *gin.Context) {
+	userId := c.GetInt(ctxkey.Id)
+	externalId := c.Param("external_id")
+	token := model.Token{}
+	if err := c.ShouldBindJSON(&token); err != nil {
+		respondExternalError(c, http.StatusBadRequest, err)
+		return
+	}
+	if err := validateToken(c, token); err != nil {
+		respondExternalError(c, http.StatusBadRequest, fmt.Errorf("参数错误：%s", err.Error()))
+		return
+	}
+	origin, exists, err := findByExternalId(func() (*model.Token, error) {
+		return model.GetTokenByExternalId(userId, externalId)
+	})
+	if err != nil {
+		respondExternalError(c, http.StatusOK, err)
+		return
+	}
+	currentETag := ""
+	if exists {
+		currentETag = etag(origin)
+	}
+	if !checkPrecondition(c, exists, currentETag) {
+		return
+	}
+	if !exists {
+		if token.ExpiredTime == 0 {
+			token.ExpiredTime = -1
+		}
+		cleanToken := model.Token{
+			UserId:         userId,
+			Name:           token.Name,
+			Key:            random.GenerateKey(),
+			CreatedTime:    helper.GetTimestamp(),
+			AccessedTime:   helper.GetTimestamp(),
+			ExpiredTime:    token.ExpiredTime,
+			RemainQuota:    token.RemainQuota,
+			UnlimitedQuota: token.UnlimitedQuota,
+			Models:         token.Models,
+			Subnet:         token.Subnet,
+			Defaults:       token.Defaults,
+			MaxConcurrency: token.MaxConcurrency,
+			AllowedOrigins: token.AllowedOrigins,
+			Sandbox:        token.Sandbox,
+			ExternalId:     externalId,
+		}
+		if err = cleanToken.Insert(); err == nil {
+			respondExternal(c, http.StatusCreated, &cleanToken)
+			return
+		}
+		// a concurrent PUT of the same external id created it first, the unique index rejected this one
+		origin, exists, _ = findByExternalId(func() (*model.Token, error) {
+			return model.GetTokenByExternalId(userId, externalId)
+		})
+		if !exists {
+			respondExternalError(c, http.StatusOK, err)
+			return
+		}
+		if !checkPrecondition(c, true, etag(origin)) {
+			return
+		}
+	}
+	if token.Status != 0 {
+		origin.Status = token.Status
+	}
+	origin.Name = token.Name
+	origin.ExpiredTime = token.ExpiredTime
+	origin.RemainQuota = token.RemainQuota
+	origin.UnlimitedQuota = token.UnlimitedQuota
+	origin.Models = token.Models
+	origin.Subnet = token.Subnet
+	origin.Defaults = token.Defaults
+	origin.MaxConcurrency = token.MaxConcurrency
+	origin.AllowedOrigins = token.AllowedOrigins
+	origin.Sandbox = token.Sandbox
+	if err = origin.Update(); err != nil {
+		respondExternalError(c, http.StatusOK, err)
+		return
+	}
+	respondExternal(c, http.StatusOK, origin)
+}
+
+func DeleteTokenByExternalId(c *gin.Context) {
+	token, exists, err := findByExternalId(func() (*model.Token, error) {
+		return model.GetTokenByExternalId(c.GetInt(ctxkey.Id), c.Param("external_id"))
+	})
+	if err != nil {
+		respondExternalError(c, http.StatusOK, err)
+		return
+	}
+	if exists {
+		if !checkPrecondition(c, true, etag(token)) {
+			return
+		}
+		if err = token.Delete(); err != nil {
+			respondExternalError(c, http.StatusOK, err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetUserByExternalId(c *gin.Context) {
+	user, exists, err := findByExternalId(func() (*model.User, error) {
+		return model.GetUserByExternalId(c.Param("external_id"))
+	})
+	if err != nil {
+		respondExternalError(c, http.StatusOK, err)
+		return
+	}
+	if !exists {
+		respondExternalError(c, http.StatusNotFound, errors.New("用户不存在"))
+		return
+	}
+	respondExternal(c, http.StatusOK, user)
+}
+
+func PutUserByExternalId(c *gin.Context) {
+	ctx := c.Request.Context()
+	externalId := c.Param("external_id")
+	user := model.User{}
+	if err := c.ShouldBindJSON(&user); err != nil {
+		respondExternalError(c, http.StatusBadRequest, err)
+		return
+	}
+	origin, exists, err := findByExternalId(func() (*model.User, error) {
+		return model.GetUserByExternalId(externalId)
+	})
+	if err != nil {
+		respondExternalError(c, http.StatusOK, err)
+		return
+	}
+	currentETag := ""
+	if exists {
+		currentETag = etag(origin)
+	}
+	if !checkPrecondition(c, exists, currentETag) {
+		return
+	}
+	myRole := c.GetInt(ctxkey.Role)
+	if myRole <= user.Role && myRole != model.RoleRootUser {
+		respondExternalError(c, http.StatusForbidden, errors.New("无权将用户权限等级设置为大于等于自己的权限等级"))
+		return
+	}
+	if user.DisplayName == "" {
+		user.DisplayName = user.Username
+	}
+	created := false
+	if !exists {
+		if user.Username == "" || user.Password == "" {
+			respondExternalError(c, http.StatusBadRequest, errors.New("创建用户时用户名和密码不能为空"))
+			return
+		}
+		if err = common.Validate.Struct(&user); err != nil {
+			respondExternalError(c, http.StatusBadRequest, err)
+			return
+		}
+		cleanUser := model.User{
+			Username:    user.Username,
+			Password:    user.Password,
+			DisplayName: user.DisplayName,
+			ExternalId:  externalId,
+		}
+		if err = cleanUser.Insert(ctx, 0); err == nil {
+			// role, quota and group are not set by Insert
+			created = true
+			user.Id = cleanUser.Id
+			user.Password = ""
+			user.ExternalId = externalId
+			if err = user.Update(false); err != nil {
+				respondExternalError(c, http.StatusOK, err)
+				return
+			}
+		} else {
+			// a concurrent PUT of the same external id created it first, the unique index rejected this one
+			origin, exists, _ = findByExternalId(func() (*model.User, error) {
+				return model.GetUserByExternalId(externalId)
+			})
+			if !exists {
+				respondExternalError(c, http.StatusOK, err)
+				return
+			}
+			if !checkPrecondition(c, true, etag(origin)) {
+				return
+			}
+		}
+	}
+	if !created {
+		if myRole <= origin.Role && myRole != model.RoleRootUser {
+			respondExternalError(c, http.StatusForbidden, errors.New("无权更新同权限等级或更高权限等级的用户信息"))
+			return
+		}
+		updatePassword := user.Password != ""
+		if !updatePassword {
+			user.Password = "$I_LOVE_U" // make Validator happy :)
+		}
+		if err = common.Validate.Struct(&user); err != nil {
+			respondExternalError(c, http.StatusBadRequest, err)
+			return
+		}
+		if !updatePassword {
+			user.Password = ""
+		}
+		user.Id = origin.Id
+		user.ExternalId = externalId
+		if err = user.Update(updatePassword); err != nil {
+			respondExternalError(c, http.StatusOK, err)
+			return
+		}
+		if origin.Quota != user.Quota {
+			model.RecordLog(ctx, origin.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", common.LogQuota(origin.Quota), common.LogQuota(user.Quota)))
+		}
+	}
+	updated, err := model.GetUserById(user.Id, false)
+	if err != nil {
+		respondExternalError(c, http.StatusOK, err)
+		return
+	}
+	if created {
+		respondExternal(c, http.StatusCreated, updated)
+	} else {
+		respondExternal(c, http.StatusOK, updated)
+	}
+}
+
+func DeleteUserByExternalId(c *gin.Context) {
+	user, exists, err := findByExternalId(func() (*model.User, error) {
+		return model.GetUserByExternalId(c.Param("external_id"))
+	})
+	if err != nil {
+		respondExternalError(c, http.StatusOK, err)
+		return
+	}
+	if exists {
+		if c.GetInt(ctxkey.Role) <= user.Role {
+			respondExternalError(c, http.StatusForbidden, errors.New("无权删除同权限等级或更高权限等级的用户"))
+			return
+		}
+		if !checkPrecondition(c, true, etag(user)) {
+			return
+		}
+		if err = user.Delete(); err != nil {
+			respondExternalError(c, http.StatusOK, err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
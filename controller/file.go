@@ -0,0 +1,376 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/storage"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// https://platform.openai.com/docs/api-reference/files
+
+var filePurposes = map[string]bool{
+	"assistants": true,
+	"batch":      true,
+	"fine-tune":  true,
+	"vision":     true,
+	"user_data":  true,
+	"evals":      true,
+}
+
+type upstreamFile struct {
+	Id       string `json:"id"`
+	Filename string `json:"filename"`
+	Purpose  string `json:"purpose"`
+	Bytes    int64  `json:"bytes"`
+}
+
+func getFileChannel(c *gin.Context, channelId int) (*model.Channel, bool) {
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil || channel.Status != model.ChannelStatusEnabled {
+		abortWithOpenAIError(c, http.StatusServiceUnavailable, fmt.Sprintf("文件所在的渠道 #%d 不可用", channelId))
+		return nil, false
+	}
+	return channel, true
+}
+
+func getUserFile(c *gin.Context) (*model.File, bool) {
+	file, err := model.GetUserFile(c.GetInt(ctxkey.Id), c.Param("id"))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusNotFound, "文件不存在")
+		return nil, false
+	}
+	return file, true
+}
+
+func fileObject(file *model.File) string {
+	data, _ := json.Marshal(gin.H{
+		"id":         file.FileId,
+		"object":     "file",
+		"bytes":      file.Bytes,
+		"created_at": file.CreatedAt,
+		"expires_at": nullable(file.ExpiresAt),
+		"filename":   file.Filename,
+		"purpose":    file.Purpose,
+		"status":     "processed",
+	})
+	return string(data)
+}
+
+func UploadFile(c *gin.Context) {
+	backend := storage.GetFileBackend(config.FileStorage)
+	if backend == nil {
+		relayFileUpload(c)
+		return
+	}
+	ctx := c.Request.Context()
+	userId := c.GetInt(ctxkey.Id)
+	header, err := c.FormFile("file")
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, "缺少文件："+err.Error())
+		return
+	}
+	purpose := c.PostForm("purpose")
+	if !filePurposes[purpose] {
+		abortWithOpenAIError(c, http.StatusBadRequest, fmt.Sprintf("无效的 purpose：%s", purpose))
+		return
+	}
+	if header.Size > config.FileMaxSize*1024*1024 {
+		abortWithOpenAIError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("文件大小不能超过 %d MB", config.FileMaxSize))
+		return
+	}
+	if config.FileUserStorageLimit > 0 {
+		used := model.GetUserStoredBytes(userId)
+		if used+header.Size > config.FileUserStorageLimit*1024*1024 {
+			abortWithOpenAIError(c, http.StatusForbidden, fmt.Sprintf("文件存储空间不足，已使用 %.2f MB，上限为 %d MB", float64(used)/1024/1024, config.FileUserStorageLimit))
+			return
+		}
+	}
+	file := &model.File{
+		FileId:    model.NewObjectId("file-"),
+		UserId:    userId,
+		Filename:  header.Filename,
+		Purpose:   purpose,
+		Bytes:     header.Size,
+		Storage:   config.FileStorage,
+		CreatedAt: helper.GetTimestamp(),
+	}
+	// the expiration is anchored at the creation like OpenAI, expires_after[anchor] has no other value
+	if seconds, err := strconv.ParseInt(c.PostForm("expires_after[seconds]"), 10, 64); err == nil && seconds > 0 {
+		file.ExpiresAt = file.CreatedAt + seconds
+	} else if config.FileRetentionDays > 0 {
+		file.ExpiresAt = file.CreatedAt + int64(config.FileRetentionDays)*24*60*60
+	}
+	content, err := header.Open()
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer content.Close()
+	if err = backend.Put(ctx, file.FileId, content, header.Size); err != nil {
+		logger.Errorf(ctx, "failed to store file %s: %s", file.FileId, err.Error())
+		abortWithOpenAIError(c, http.StatusInternalServerError, "保存文件失败")
+		return
+	}
+	file.Data = fileObject(file)
+	if err = file.Insert(); err != nil {
+		_ = backend.Delete(ctx, file.FileId)
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "application/json", []byte(file.Data))
+}
+
+// relayFileUpload uploads the file to a channel at once, it is used when no storage is configured
+func relayFileUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetInt(ctxkey.Id)
+	group, err := model.CacheGetUserGroup(userId)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// the file goes to a channel of the model it is going to fine-tune, if the client tells it
+	modelName := c.GetString(ctxkey.RequestModel)
+	if modelName == "" {
+		modelName = config.FineTuningModel
+	}
+	channel, err := model.CacheGetRandomSatisfiedChannel(group, modelName, false)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusServiceUnavailable, fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", group, modelName))
+		return
+	}
+	if !isFineTuningChannel(channel) {
+		abortWithOpenAIError(c, http.StatusServiceUnavailable, fmt.Sprintf("渠道 #%d 不支持文件上传", channel.Id))
+		return
+	}
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	statusCode, responseBody, ok := relayFineTuningRequest(c, channel, http.MethodPost, "/v1/files", requestBody)
+	if !ok || statusCode != http.StatusOK {
+		return
+	}
+	var uploaded upstreamFile
+	if err = json.Unmarshal(responseBody, &uploaded); err != nil || uploaded.Id == "" {
+		logger.Errorf(ctx, "failed to parse the file uploaded to channel #%d: %s", channel.Id, string(responseBody))
+		return
+	}
+	file := &model.File{
+		FileId:         uploaded.Id,
+		UserId:         userId,
+		ChannelId:      channel.Id,
+		Filename:       uploaded.Filename,
+		Purpose:        uploaded.Purpose,
+		Bytes:          uploaded.Bytes,
+		UpstreamFileId: uploaded.Id,
+		Data:           string(responseBody),
+	}
+	if err = file.Insert(); err != nil {
+		logger.Errorf(ctx, "failed to save file %s: %s", uploaded.Id, err.Error())
+	}
+}
+
+// uploadStoredFile copies the stored file to the channel, unless it is already there
+func uploadStoredFile(ctx context.Context, channel *model.Channel, file *model.File) error {
+	if file.Storage == "" {
+		if file.ChannelId != channel.Id {
+			return fmt.Errorf("文件 %s 与训练文件不在同一渠道", file.FileId)
+		}
+		if file.UpstreamFileId == "" {
+			file.UpstreamFileId = file.FileId
+		}
+		return nil
+	}
+	if file.ChannelId == channel.Id && file.UpstreamFileId != "" {
+		return nil
+	}
+	content, err := storage.GetFileBackend(file.Storage).Get(ctx, file.FileId)
+	if err != nil {
+		return fmt.Errorf("读取文件 %s 失败：%w", file.FileId, err)
+	}
+	defer content.Close()
+	reader, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		err := form.WriteField("purpose", file.Purpose)
+		if err == nil {
+			var part io.Writer
+			if part, err = form.CreateFormFile("file", file.Filename); err == nil {
+				if _, err = io.Copy(part, content); err == nil {
+					err = form.Close()
+				}
+			}
+		}
+		_ = writer.CloseWithError(err)
+	}()
+	resp, err := doFineTuningRequest(ctx, channel, http.MethodPost, "/v1/files", reader, form.FormDataContentType())
+	// unblocks the copy when the request fails before the body is read through
+	_ = reader.Close()
+	if err != nil {
+		return fmt.Errorf("上传文件 %s 到渠道 #%d 失败：%w", file.FileId, channel.Id, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var uploaded upstreamFile
+	if resp.StatusCode != http.StatusOK || json.Unmarshal(body, &uploaded) != nil || uploaded.Id == "" {
+		return fmt.Errorf("上传文件 %s 到渠道 #%d 失败：%s", file.FileId, channel.Id, string(body))
+	}
+	return file.UpdateUpstream(channel.Id, uploaded.Id)
+}
+
+func ListFiles(c *gin.Context) {
+	files, err := model.GetUserFiles(c.GetInt(ctxkey.Id), c.Query("purpose"))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	data := make([]json.RawMessage, 0, len(files))
+	for _, file := range files {
+		data = append(data, json.RawMessage(file.Data))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   data,
+	})
+}
+
+func RetrieveFile(c *gin.Context) {
+	file, ok := getUserFile(c)
+	if !ok {
+		return
+	}
+	if file.Storage != "" {
+		c.Data(http.StatusOK, "application/json", []byte(file.Data))
+		return
+	}
+	channel, ok := getFileChannel(c, file.ChannelId)
+	if !ok {
+		return
+	}
+	relayFineTuningRequest(c, channel, http.MethodGet, "/v1/files/"+file.FileId, nil)
+}
+
+// deleteStoredFile removes the stored file with its copy on the upstream, if any
+func deleteStoredFile(ctx context.Context, file *model.File) error {
+	if err := storage.GetFileBackend(file.Storage).Delete(ctx, file.FileId); err != nil {
+		return err
+	}
+	if file.ChannelId != 0 && file.UpstreamFileId != "" {
+		if channel, err := model.GetChannelById(file.ChannelId, true); err == nil {
+			resp, err := doFineTuningRequest(ctx, channel, http.MethodDelete, "/v1/files/"+file.UpstreamFileId, nil, "")
+			if err != nil {
+				logger.Warnf(ctx, "failed to delete the copy of file %s on channel #%d: %s", file.FileId, channel.Id, err.Error())
+			} else {
+				resp.Body.Close()
+			}
+		}
+	}
+	return file.Delete()
+}
+
+func DeleteFile(c *gin.Context) {
+	ctx := c.Request.Context()
+	file, ok := getUserFile(c)
+	if !ok {
+		return
+	}
+	if file.Storage != "" {
+		if err := deleteStoredFile(ctx, file); err != nil {
+			abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"id":      file.FileId,
+			"object":  "file",
+			"deleted": true,
+		})
+		return
+	}
+	channel, ok := getFileChannel(c, file.ChannelId)
+	if !ok {
+		return
+	}
+	statusCode, _, ok := relayFineTuningRequest(c, channel, http.MethodDelete, "/v1/files/"+file.FileId, nil)
+	if !ok || statusCode != http.StatusOK {
+		return
+	}
+	if err := file.Delete(); err != nil {
+		logger.Errorf(ctx, "failed to delete file %s: %s", file.FileId, err.Error())
+	}
+}
+
+func RetrieveFileContent(c *gin.Context) {
+	ctx := c.Request.Context()
+	file, ok := getUserFile(c)
+	if !ok {
+		return
+	}
+	var content io.ReadCloser
+	statusCode, contentLength, contentType := http.StatusOK, file.Bytes, "application/octet-stream"
+	if file.Storage != "" {
+		var err error
+		content, err = storage.GetFileBackend(file.Storage).Get(ctx, file.FileId)
+		if err != nil {
+			abortWithOpenAIError(c, http.StatusInternalServerError, "读取文件失败："+err.Error())
+			return
+		}
+	} else {
+		channel, ok := getFileChannel(c, file.ChannelId)
+		if !ok {
+			return
+		}
+		resp, err := doFineTuningRequest(ctx, channel, http.MethodGet, "/v1/files/"+file.FileId+"/content", nil, "")
+		if err != nil {
+			abortWithOpenAIError(c, http.StatusBadGateway, "请求上游失败："+err.Error())
+			return
+		}
+		content = resp.Body
+		statusCode, contentLength, contentType = resp.StatusCode, resp.ContentLength, resp.Header.Get("Content-Type")
+	}
+	defer content.Close()
+	// the content may be large, it is streamed rather than buffered
+	c.DataFromReader(statusCode, contentLength, contentType, content, nil)
+}
+
+// AutomaticallyDeleteExpiredFiles removes the stored files past their expiration
+func AutomaticallyDeleteExpiredFiles() {
+	ctx := context.Background()
+	for {
+		if model.IsLeader() {
+			files, err := model.GetExpiredFiles()
+			if err != nil {
+				logger.SysError("failed to get expired files: " + err.Error())
+			}
+			for _, file := range files {
+				if file.Storage == "" {
+					// the upstream expires it by itself
+					_ = file.Delete()
+					continue
+				}
+				if err = deleteStoredFile(ctx, file); err != nil {
+					logger.SysError(fmt.Sprintf("failed to delete expired file %s: %s", file.FileId, err.Error()))
+				}
+			}
+		}
+		time.Sleep(time.Hour)
+	}
+}
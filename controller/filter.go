@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/plugin"
+)
+
+func checkFilter(filter *model.Filter) error {
+	if plugin.Wasm == nil {
+		return errors.New("WASM 过滤器未启用，请设置 WASM_FILTER_ENABLED")
+	}
+	if filter.Name == "" {
+		return errors.New("名称不能为空")
+	}
+	if filter.Scope != model.FilterScopeChannel && filter.Scope != model.FilterScopeGroup {
+		return errors.New("作用范围只能为 channel 或 group")
+	}
+	if filter.Target == "" {
+		return errors.New("作用对象不能为空")
+	}
+	return nil
+}
+
+func reloadFilters(c *gin.Context) {
+	if err := plugin.Wasm.Reload(c.Request.Context()); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetAllFilters(c *gin.Context) {
+	filters, err := model.GetAllFilters()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    filters,
+	})
+	return
+}
+
+// AddFilter accepts a multipart form with name, scope, target, priority and the module file
+func AddFilter(c *gin.Context) {
+	priority, _ := strconv.Atoi(c.PostForm("priority"))
+	filter := model.Filter{
+		Name:     c.PostForm("name"),
+		Scope:    c.PostForm("scope"),
+		Target:   c.PostForm("target"),
+		Priority: priority,
+		Status:   model.FilterStatusEnabled,
+	}
+	if err := checkFilter(&filter); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	file, err := c.FormFile("module")
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "请上传 WASM 模块：" + err.Error(),
+		})
+		return
+	}
+	if file.Size > int64(config.WasmFilterMaxSize)<<20 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "WASM 模块过大",
+		})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	defer f.Close()
+	filter.Module, err = io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = plugin.Wasm.Validate(c.Request.Context(), filter.Module); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的 WASM 模块：" + err.Error(),
+		})
+		return
+	}
+	if err = filter.Insert(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	reloadFilters(c)
+	return
+}
+
+func UpdateFilter(c *gin.Context) {
+	filter := model.Filter{}
+	err := c.ShouldBindJSON(&filter)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = checkFilter(&filter); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if _, err = model.GetFilterById(filter.Id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = filter.Update(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	reloadFilters(c)
+	return
+}
+
+func DeleteFilter(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if plugin.Wasm == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "WASM 过滤器未启用，请设置 WASM_FILTER_ENABLED",
+		})
+		return
+	}
+	if err := model.DeleteFilterById(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	reloadFilters(c)
+	return
+}
@@ -0,0 +1,320 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/apitype"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// https://platform.openai.com/docs/api-reference/fine-tuning
+
+// defaultFineTuningEpochs is the number of epochs assumed when estimating the cost of a job with n_epochs auto
+const defaultFineTuningEpochs = 3
+
+type fineTuningJobRequest struct {
+	Model           string `json:"model"`
+	TrainingFile    string `json:"training_file"`
+	ValidationFile  string `json:"validation_file"`
+	Hyperparameters struct {
+		NEpochs any `json:"n_epochs"`
+	} `json:"hyperparameters"`
+}
+
+func abortWithOpenAIError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": relaymodel.Error{
+			Message: message,
+			Type:    "one_api_error",
+		},
+	})
+}
+
+// isFineTuningChannel reports whether the upstream of the channel serves the files and fine-tuning api of OpenAI
+func isFineTuningChannel(channel *model.Channel) bool {
+	return channeltype.ToAPIType(channel.Type) == apitype.OpenAI && channel.Type != channeltype.Azure
+}
+
+func doFineTuningRequest(ctx context.Context, channel *model.Channel, method string, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, channel.GetBaseURL()+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+channel.Key)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return client.HTTPClient.Do(req)
+}
+
+// relayFineTuningRequest sends the request to the channel and writes the response back, the body is returned
+// so that the caller can track the objects created by a successful request
+func relayFineTuningRequest(c *gin.Context, channel *model.Channel, method string, path string, body []byte) (int, []byte, bool) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	resp, err := doFineTuningRequest(c.Request.Context(), channel, method, path, reader, c.Request.Header.Get("Content-Type"))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadGateway, "请求上游失败："+err.Error())
+		return 0, nil, false
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadGateway, "读取上游响应失败："+err.Error())
+		return 0, nil, false
+	}
+	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), responseBody)
+	return resp.StatusCode, responseBody, true
+}
+
+func getFineTuningEpochs(nEpochs any) int64 {
+	if epochs, ok := nEpochs.(float64); ok && epochs > 0 {
+		return int64(epochs)
+	}
+	return defaultFineTuningEpochs
+}
+
+// getFineTuningJobChannel returns the channel the training file is on, the stored files which are on
+// no available channel yet are uploaded to a channel of the model
+func getFineTuningJobChannel(c *gin.Context, group string, modelName string, trainingFile *model.File) (*model.Channel, bool) {
+	if trainingFile.Storage == "" {
+		return getFileChannel(c, trainingFile.ChannelId)
+	}
+	if trainingFile.ChannelId != 0 {
+		channel, err := model.GetChannelById(trainingFile.ChannelId, true)
+		if err == nil && channel.Status == model.ChannelStatusEnabled {
+			return channel, true
+		}
+	}
+	channel, err := model.CacheGetRandomSatisfiedChannel(group, modelName, false)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusServiceUnavailable, fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", group, modelName))
+		return nil, false
+	}
+	if !isFineTuningChannel(channel) {
+		abortWithOpenAIError(c, http.StatusServiceUnavailable, fmt.Sprintf("渠道 #%d 不支持微调", channel.Id))
+		return nil, false
+	}
+	return channel, true
+}
+
+// useUpstreamFiles makes sure the files are on the channel and refers to them by their upstream ids in the request
+func useUpstreamFiles(ctx context.Context, channel *model.Channel, requestBody []byte, trainingFile *model.File, validationFile *model.File) ([]byte, error) {
+	var request map[string]any
+	if err := json.Unmarshal(requestBody, &request); err != nil {
+		return nil, err
+	}
+	for field, file := range map[string]*model.File{"training_file": trainingFile, "validation_file": validationFile} {
+		if file == nil {
+			continue
+		}
+		if err := uploadStoredFile(ctx, channel, file); err != nil {
+			return nil, err
+		}
+		request[field] = file.UpstreamFileId
+	}
+	return json.Marshal(request)
+}
+
+func CreateFineTuningJob(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetInt(ctxkey.Id)
+	tokenId := c.GetInt(ctxkey.TokenId)
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	var request fineTuningJobRequest
+	if err = json.Unmarshal(requestBody, &request); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, "无效的请求："+err.Error())
+		return
+	}
+	trainingFile, err := model.GetUserFile(userId, request.TrainingFile)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, fmt.Sprintf("训练文件 %s 不存在，请先通过本站上传", request.TrainingFile))
+		return
+	}
+	var validationFile *model.File
+	if request.ValidationFile != "" {
+		validationFile, err = model.GetUserFile(userId, request.ValidationFile)
+		if err != nil {
+			abortWithOpenAIError(c, http.StatusBadRequest, fmt.Sprintf("验证文件 %s 不存在，请先通过本站上传", request.ValidationFile))
+			return
+		}
+	}
+	group, err := model.CacheGetUserGroup(userId)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// a token is about four bytes of text, the estimation only rejects the jobs which are obviously unaffordable
+	estimatedTokens := trainingFile.Bytes / 4 * getFineTuningEpochs(request.Hyperparameters.NEpochs)
+	estimatedQuota, ok := model.GetFineTuningQuota(request.Model, group, estimatedTokens)
+	if !ok {
+		abortWithOpenAIError(c, http.StatusBadRequest, fmt.Sprintf("模型 %s 不支持微调", request.Model))
+		return
+	}
+	userQuota, err := model.CacheGetUserQuota(ctx, userId)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if userQuota < estimatedQuota {
+		abortWithOpenAIError(c, http.StatusForbidden, fmt.Sprintf("用户额度不足，预计需要 %s", common.LogQuota(estimatedQuota)))
+		return
+	}
+	token, err := model.GetTokenById(tokenId)
+	if err == nil && !token.UnlimitedQuota && token.RemainQuota < estimatedQuota {
+		abortWithOpenAIError(c, http.StatusForbidden, fmt.Sprintf("令牌额度不足，预计需要 %s", common.LogQuota(estimatedQuota)))
+		return
+	}
+	channel, ok := getFineTuningJobChannel(c, group, request.Model, trainingFile)
+	if !ok {
+		return
+	}
+	requestBody, err = useUpstreamFiles(ctx, channel, requestBody, trainingFile, validationFile)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	statusCode, responseBody, ok := relayFineTuningRequest(c, channel, http.MethodPost, "/v1/fine_tuning/jobs", requestBody)
+	if !ok || statusCode != http.StatusOK {
+		return
+	}
+	if _, err = model.NewFineTuningJob(userId, tokenId, c.GetString(ctxkey.TokenName), channel.Id, group, responseBody); err != nil {
+		logger.Errorf(ctx, "failed to save fine-tuning job: %s", err.Error())
+	}
+}
+
+func ListFineTuningJobs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	jobs, err := model.GetUserFineTuningJobs(c.GetInt(ctxkey.Id), c.Query("after"), limit+1)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	hasMore := len(jobs) > limit
+	if hasMore {
+		jobs = jobs[:limit]
+	}
+	data := make([]json.RawMessage, 0, len(jobs))
+	for _, job := range jobs {
+		data = append(data, json.RawMessage(job.Data))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":   "list",
+		"data":     data,
+		"has_more": hasMore,
+	})
+}
+
+func getUserFineTuningJob(c *gin.Context) (*model.FineTuningJob, *model.Channel, bool) {
+	job, err := model.GetUserFineTuningJob(c.GetInt(ctxkey.Id), c.Param("id"))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusNotFound, "微调任务不存在")
+		return nil, nil, false
+	}
+	channel, ok := getFileChannel(c, job.ChannelId)
+	return job, channel, ok
+}
+
+func RetrieveFineTuningJob(c *gin.Context) {
+	job, channel, ok := getUserFineTuningJob(c)
+	if !ok {
+		return
+	}
+	statusCode, responseBody, ok := relayFineTuningRequest(c, channel, http.MethodGet, "/v1/fine_tuning/jobs/"+job.JobId, nil)
+	if !ok || statusCode != http.StatusOK {
+		return
+	}
+	if err := job.Update(c.Request.Context(), responseBody); err != nil {
+		logger.Errorf(c.Request.Context(), "failed to update fine-tuning job %s: %s", job.JobId, err.Error())
+	}
+}
+
+func CancelFineTuningJob(c *gin.Context) {
+	job, channel, ok := getUserFineTuningJob(c)
+	if !ok {
+		return
+	}
+	statusCode, responseBody, ok := relayFineTuningRequest(c, channel, http.MethodPost, "/v1/fine_tuning/jobs/"+job.JobId+"/cancel", nil)
+	if !ok || statusCode != http.StatusOK {
+		return
+	}
+	if err := job.Update(c.Request.Context(), responseBody); err != nil {
+		logger.Errorf(c.Request.Context(), "failed to update fine-tuning job %s: %s", job.JobId, err.Error())
+	}
+}
+
+func ListFineTuningEvents(c *gin.Context) {
+	job, channel, ok := getUserFineTuningJob(c)
+	if !ok {
+		return
+	}
+	path := "/v1/fine_tuning/jobs/" + job.JobId + "/events"
+	if c.Request.URL.RawQuery != "" {
+		path += "?" + c.Request.URL.RawQuery
+	}
+	relayFineTuningRequest(c, channel, http.MethodGet, path, nil)
+}
+
+func updateFineTuningJob(ctx context.Context, job *model.FineTuningJob) error {
+	channel, err := model.GetChannelById(job.ChannelId, true)
+	if err != nil {
+		return err
+	}
+	resp, err := doFineTuningRequest(ctx, channel, http.MethodGet, "/v1/fine_tuning/jobs/"+job.JobId, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(body))
+	}
+	return job.Update(ctx, body)
+}
+
+// AutomaticallyUpdateFineTuningJobs polls the unfinished jobs, so that they are billed even if nobody retrieves them
+func AutomaticallyUpdateFineTuningJobs() {
+	ctx := context.Background()
+	for {
+		time.Sleep(10 * time.Minute)
+		if !model.IsLeader() {
+			continue
+		}
+		jobs, err := model.GetUnfinishedFineTuningJobs()
+		if err != nil {
+			logger.SysError("failed to get unfinished fine-tuning jobs: " + err.Error())
+			continue
+		}
+		for _, job := range jobs {
+			if err = updateFineTuningJob(ctx, job); err != nil {
+				logger.SysError(fmt.Sprintf("failed to update fine-tuning job %s: %s", job.JobId, err.Error()))
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/model"
+)
+
+func checkGlossaryTerm(term *model.GlossaryTerm) error {
+	term.Term = strings.TrimSpace(term.Term)
+	if term.Term == "" || len(term.Term) > 255 {
+		return errors.New("术语不能为空且不能超过 255 个字符")
+	}
+	if len(term.Language) > 16 {
+		return errors.New("语言不能超过 16 个字符")
+	}
+	return nil
+}
+
+func GetAllGlossaryTerms(c *gin.Context) {
+	terms, err := model.GetAllGlossaryTerms()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    terms,
+	})
+	return
+}
+
+func AddGlossaryTerm(c *gin.Context) {
+	term := model.GlossaryTerm{}
+	err := c.ShouldBindJSON(&term)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	term.Id = 0
+	if err = checkGlossaryTerm(&term); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = term.Insert(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    term,
+	})
+	return
+}
+
+func UpdateGlossaryTerm(c *gin.Context) {
+	term := model.GlossaryTerm{}
+	err := c.ShouldBindJSON(&term)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = checkGlossaryTerm(&term); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if _, err = model.GetGlossaryTermById(term.Id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = term.Update(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    term,
+	})
+	return
+}
+
+func DeleteGlossaryTerm(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteGlossaryTermById(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
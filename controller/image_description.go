@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+const (
+	// imageDescribedHeader returns how many images were replaced by their description
+	imageDescribedHeader = "X-OneAPI-Images-Described"
+	// imageDescriptionConcurrency is how many images are described at the same time
+	imageDescriptionConcurrency = 4
+	imageDescriptionStage       = "图片描述"
+)
+
+// describeImages replaces the images of a chat completion by their description when the channel selected describes
+// the images for its model, the original request is kept for the retries on the channels with vision, and the
+// descriptions for the retries on the other channels describing them the same way
+func describeImages(c *gin.Context, relayMode int) {
+	// the images sent to be described are not described again
+	if _, stage := helper.GetPipelineStage(c.Request.Context()); relayMode != relaymode.ChatCompletions || stage == imageDescriptionStage {
+		return
+	}
+	value, _ := c.Get(ctxkey.Config)
+	cfg, _ := value.(model.ChannelConfig)
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return
+	}
+	if original, ok := c.Get(ctxkey.ImageOriginalBody); ok {
+		body = original.([]byte)
+		common.SetRequestBody(c, body)
+	}
+	description := cfg.ImageDescription
+	if description == nil || !description.Applies(c.GetString(ctxkey.OriginalModel)) {
+		return
+	}
+	var fields map[string]json.RawMessage
+	var messages []map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil || json.Unmarshal(fields["messages"], &messages) != nil {
+		// the relay reports the invalid request
+		return
+	}
+	type imagePart struct {
+		message int
+		part    int
+		url     string
+	}
+	var images []imagePart
+	contents := make([][]map[string]any, len(messages))
+	for i, message := range messages {
+		if json.Unmarshal(message["content"], &contents[i]) != nil {
+			continue
+		}
+		for j, part := range contents[i] {
+			if part["type"] != relaymodel.ContentTypeImageURL {
+				continue
+			}
+			url, _ := part["image_url"].(string)
+			if imageURL, ok := part["image_url"].(map[string]any); ok {
+				url, _ = imageURL["url"].(string)
+			}
+			images = append(images, imagePart{message: i, part: j, url: url})
+		}
+	}
+	if len(images) == 0 {
+		return
+	}
+
+	value, _ = c.Get(ctxkey.ImageDescriptions)
+	descriptions, _ := value.(map[string]string)
+	if descriptions == nil {
+		descriptions = map[string]string{}
+	}
+	prompt := description.GetPrompt()
+	cacheKey := func(url string) string {
+		return description.Model + "\x00" + prompt + "\x00" + url
+	}
+	token, err := model.GetTokenById(c.GetInt(ctxkey.TokenId))
+	if err != nil {
+		logger.Warnf(c.Request.Context(), "failed to describe the images: %s", err.Error())
+		return
+	}
+	ctx := helper.SetPipelineStage(c.Request.Context(), c.GetString(helper.RequestIdKey), imageDescriptionStage)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, imageDescriptionConcurrency)
+	for _, image := range images {
+		if _, ok := descriptions[cacheKey(image.url)]; ok || image.url == "" {
+			continue
+		}
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(url string) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			response := &openai.TextResponse{}
+			err := relayInternalWithContext(ctx, token.Key, "/v1/chat/completions", &relaymodel.GeneralOpenAIRequest{
+				Model: description.Model,
+				Messages: []relaymodel.Message{{
+					Role: "user",
+					Content: []relaymodel.MessageContent{
+						{Type: relaymodel.ContentTypeText, Text: prompt},
+						{Type: relaymodel.ContentTypeImageURL, ImageURL: &relaymodel.ImageURL{Url: url}},
+					},
+				}},
+			}, response)
+			if err == nil && len(response.Choices) == 0 {
+				err = errors.New("模型没有返回内容")
+			}
+			if err != nil {
+				// the image is left out rather than failing the request
+				logger.Warnf(c.Request.Context(), "failed to describe an image with %s: %s", description.Model, err.Error())
+				return
+			}
+			lock.Lock()
+			descriptions[cacheKey(url)] = strings.TrimSpace(response.Choices[0].StringContent())
+			lock.Unlock()
+		}(image.url)
+	}
+	wg.Wait()
+	c.Set(ctxkey.ImageDescriptions, descriptions)
+
+	label := "Image description"
+	if description.Mode == model.ImageDescriptionOCR {
+		label = "Image text"
+	}
+	count := 0
+	for _, image := range images {
+		text := "[Image: not available]"
+		if described, ok := descriptions[cacheKey(image.url)]; ok {
+			text = "[" + label + ": " + described + "]"
+			count++
+		}
+		contents[image.message][image.part] = map[string]any{"type": relaymodel.ContentTypeText, "text": text}
+	}
+	for i, content := range contents {
+		if content == nil {
+			continue
+		}
+		if messages[i]["content"], err = json.Marshal(content); err != nil {
+			return
+		}
+	}
+	if fields["messages"], err = json.Marshal(messages); err != nil {
+		return
+	}
+	described, err := json.Marshal(fields)
+	if err != nil {
+		return
+	}
+	if _, ok := c.Get(ctxkey.ImageOriginalBody); !ok {
+		c.Set(ctxkey.ImageOriginalBody, body)
+	}
+	common.SetRequestBody(c, described)
+	c.Header(imageDescribedHeader, strconv.Itoa(count))
+	logger.Infof(c.Request.Context(), "described %d of %d images with %s", count, len(images), description.Model)
+}
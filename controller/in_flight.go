@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	inFlightSnapshotsKey   = "one-api:in_flight"
+	inFlightCancelChannel  = "one-api:in_flight_cancel"
+	inFlightSnapshotPeriod = 5 * time.Second
+)
+
+// errRequestKilled is the cause of the context of a request cancelled by an admin
+var errRequestKilled = errors.New("request cancelled by an admin")
+
+// InFlightRequest is a relay request being executed
+type InFlightRequest struct {
+	RequestId     string  `json:"request_id"`
+	NodeId        string  `json:"node_id"`
+	UserId        int     `json:"user_id"`
+	TokenId       int     `json:"token_id"`
+	TokenName     string  `json:"token_name"`
+	Path          string  `json:"path"`
+	Model         string  `json:"model"`
+	ChannelId     int     `json:"channel_id"`
+	StartedAt     int64   `json:"started_at"`
+	Elapsed       float64 `json:"elapsed"`
+	BytesStreamed int64   `json:"bytes_streamed"`
+}
+
+type inFlightEntry struct {
+	c       *gin.Context
+	start   time.Time
+	written *atomic.Int64
+	cancel  context.CancelCauseFunc
+}
+
+var inFlightLock sync.Mutex
+var inFlightRequests = make(map[string]*inFlightEntry)
+
+// countingWriter counts the bytes of the response sent to the client
+type countingWriter struct {
+	gin.ResponseWriter
+	written *atomic.Int64
+}
+
+func (w *countingWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.written.Add(int64(n))
+	return n, err
+}
+
+func (w *countingWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.written.Add(int64(n))
+	return n, err
+}
+
+// trackInFlight registers the request until the returned function is called, the context of the request is
+// replaced with one an admin can cancel
+func trackInFlight(c *gin.Context) func() {
+	requestId := c.GetString(helper.RequestIdKey)
+	ctx, cancel := context.WithCancelCause(c.Request.Context())
+	c.Request = c.Request.WithContext(ctx)
+	entry := &inFlightEntry{c: c, start: time.Now(), written: &atomic.Int64{}, cancel: cancel}
+	c.Writer = &countingWriter{ResponseWriter: c.Writer, written: entry.written}
+	inFlightLock.Lock()
+	inFlightRequests[requestId] = entry
+	inFlightLock.Unlock()
+	return func() {
+		inFlightLock.Lock()
+		delete(inFlightRequests, requestId)
+		inFlightLock.Unlock()
+		cancel(nil)
+	}
+}
+
+// isRequestKilled tells whether an admin cancelled the request
+func isRequestKilled(c *gin.Context) bool {
+	return errors.Is(context.Cause(c.Request.Context()), errRequestKilled)
+}
+
+// getLocalInFlightRequests returns the requests executed by this node, the longest running first
+func getLocalInFlightRequests() []*InFlightRequest {
+	inFlightLock.Lock()
+	defer inFlightLock.Unlock()
+	now := time.Now()
+	requests := make([]*InFlightRequest, 0, len(inFlightRequests))
+	for requestId, entry := range inFlightRequests {
+		c := entry.c
+		requests = append(requests, &InFlightRequest{
+			RequestId:     requestId,
+			NodeId:        config.NodeId,
+			UserId:        c.GetInt(ctxkey.Id),
+			TokenId:       c.GetInt(ctxkey.TokenId),
+			TokenName:     c.GetString(ctxkey.TokenName),
+			Path:          c.Request.URL.Path,
+			Model:         c.GetString(ctxkey.OriginalModel),
+			ChannelId:     c.GetInt(ctxkey.ChannelId),
+			StartedAt:     entry.start.Unix(),
+			Elapsed:       now.Sub(entry.start).Seconds(),
+			BytesStreamed: entry.written.Load(),
+		})
+	}
+	return requests
+}
+
+// cancelLocalInFlightRequests cancels the request with the id, or all the requests on the channel
+func cancelLocalInFlightRequests(requestId string, channelId int) int {
+	inFlightLock.Lock()
+	defer inFlightLock.Unlock()
+	cancelled := 0
+	for id, entry := range inFlightRequests {
+		if (requestId != "" && id == requestId) || (channelId != 0 && entry.c.GetInt(ctxkey.ChannelId) == channelId) {
+			entry.cancel(errRequestKilled)
+			logger.Warnf(entry.c.Request.Context(), "request %s cancelled by an admin", id)
+			cancelled++
+		}
+	}
+	return cancelled
+}
+
+type inFlightSnapshot struct {
+	UpdatedAt int64              `json:"updated_at"`
+	Requests  []*InFlightRequest `json:"requests"`
+}
+
+type inFlightCancel struct {
+	NodeId    string `json:"node_id"`
+	RequestId string `json:"request_id,omitempty"`
+	ChannelId int    `json:"channel_id,omitempty"`
+}
+
+// getInFlightRequests returns the requests of all the nodes, the other nodes report theirs through Redis
+func getInFlightRequests(ctx context.Context) []*InFlightRequest {
+	requests := getLocalInFlightRequests()
+	if common.RedisEnabled {
+		snapshots, err := common.RDB.HGetAll(ctx, inFlightSnapshotsKey).Result()
+		if err != nil {
+			logger.Errorf(ctx, "failed to load in-flight requests of the other nodes: %s", err.Error())
+		}
+		for nodeId, data := range snapshots {
+			var snapshot inFlightSnapshot
+			if nodeId == config.NodeId || json.Unmarshal([]byte(data), &snapshot) != nil {
+				continue
+			}
+			if time.Since(time.Unix(snapshot.UpdatedAt, 0)) > 3*inFlightSnapshotPeriod {
+				continue
+			}
+			requests = append(requests, snapshot.Requests...)
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].Elapsed > requests[j].Elapsed
+	})
+	return requests
+}
+
+// SyncInFlightRequests reports the requests of this node to the others every few seconds
+func SyncInFlightRequests() {
+	ctx := context.Background()
+	for {
+		data, err := json.Marshal(inFlightSnapshot{UpdatedAt: time.Now().Unix(), Requests: getLocalInFlightRequests()})
+		if err == nil {
+			err = common.RDB.HSet(ctx, inFlightSnapshotsKey, config.NodeId, string(data)).Err()
+		}
+		if err != nil {
+			logger.SysError("failed to report in-flight requests: " + err.Error())
+		}
+		time.Sleep(inFlightSnapshotPeriod)
+	}
+}
+
+// SubscribeInFlightCancels cancels the requests of this node cancelled through another node
+func SubscribeInFlightCancels() {
+	pubsub := common.RedisSubscribe(inFlightCancelChannel)
+	defer pubsub.Close()
+	for msg := range pubsub.Channel() {
+		var cancel inFlightCancel
+		if json.Unmarshal([]byte(msg.Payload), &cancel) != nil || cancel.NodeId == config.NodeId {
+			continue
+		}
+		cancelLocalInFlightRequests(cancel.RequestId, cancel.ChannelId)
+	}
+}
+
+func cancelInFlightRequests(requestId string, channelId int) int {
+	if common.RedisEnabled {
+		data, _ := json.Marshal(inFlightCancel{NodeId: config.NodeId, RequestId: requestId, ChannelId: channelId})
+		if err := common.RedisPublish(inFlightCancelChannel, string(data)); err != nil {
+			logger.SysError("failed to publish in-flight cancel: " + err.Error())
+		}
+	}
+	return cancelLocalInFlightRequests(requestId, channelId)
+}
+
+func GetInFlightRequests(c *gin.Context) {
+	requests := getInFlightRequests(c.Request.Context())
+	if channelId, _ := strconv.Atoi(c.Query("channel_id")); channelId != 0 {
+		filtered := make([]*InFlightRequest, 0, len(requests))
+		for _, request := range requests {
+			if request.ChannelId == channelId {
+				filtered = append(filtered, request)
+			}
+		}
+		requests = filtered
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    requests,
+	})
+}
+
+// CancelInFlightRequest cancels a request, the client receives an error or the end of the stream
+func CancelInFlightRequest(c *gin.Context) {
+	requestId := c.Param("request_id")
+	cancelled := cancelInFlightRequests(requestId, 0)
+	if cancelled == 0 && !common.RedisEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("请求 %s 不存在或已结束", requestId),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// CancelChannelInFlightRequests cancels all the requests on a channel, those of the other nodes are not counted
+func CancelChannelInFlightRequests(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	if channelId == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的渠道 ID",
+		})
+		return
+	}
+	cancelled := cancelInFlightRequests("", channelId)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cancelled,
+	})
+}
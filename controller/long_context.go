@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/vector"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/defaults"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+const (
+	// longContextHeader set to summarize opts the request in to condensing a prompt too long for the model
+	longContextHeader = "X-OneAPI-Long-Context"
+	// longContextSummarizedHeader returns how many summaries were requested to condense the prompt
+	longContextSummarizedHeader = "X-OneAPI-Long-Context-Summarized"
+	// longContextMaxRounds is how many times the summaries are summarized again at most
+	longContextMaxRounds = 3
+	// longContextChunkTokens is the size of the parts summarized when the window of the summary model is unknown
+	longContextChunkTokens = 4000
+	// longContextConcurrency is how many parts are summarized at the same time
+	longContextConcurrency      = 4
+	longContextMinSummaryTokens = 128
+)
+
+var errLongContextTooLong = errors.New("摘要后的上下文仍超出模型的上下文长度")
+
+// longContextSummarizer summarizes the parts of a conversation with LongContextSummaryModel, the summaries are
+// relayed with the key of the token and marked in the logs as the ones of the request
+type longContextSummarizer struct {
+	ctx      context.Context
+	key      string
+	model    string
+	prompt   string
+	requests int
+}
+
+// condenseLongContext replaces the earlier messages of a chat completion too long for the context window of its model
+// with their summary, map-reduce style, the system messages and the last question are kept as they are unless the
+// question alone takes more than half of the window
+func condenseLongContext(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatusCode {
+	if relayMode != relaymode.ChatCompletions || c.GetHeader(longContextHeader) != "summarize" || config.LongContextSummaryModel == "" {
+		return nil
+	}
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return openai.ErrorWrapper(err, "read_request_body_failed", http.StatusBadRequest)
+	}
+	var fields map[string]json.RawMessage
+	var request relaymodel.GeneralOpenAIRequest
+	if json.Unmarshal(body, &fields) != nil || json.Unmarshal(body, &request) != nil {
+		// the relay reports the invalid request
+		return nil
+	}
+	window := defaults.GetContextWindow(request.Model)
+	if window <= 0 {
+		return nil
+	}
+	reserved := request.MaxTokens
+	if request.MaxCompletionTokens != nil {
+		reserved = *request.MaxCompletionTokens
+	}
+	if reserved <= 0 {
+		reserved = window / 4
+	}
+	budget := window - reserved
+	if openai.CountTokenMessages(request.Messages, request.Model) <= budget {
+		return nil
+	}
+
+	messages := request.Messages
+	start := 0
+	for start < len(messages) && (messages[start].Role == "system" || messages[start].Role == "developer") {
+		start++
+	}
+	end := len(messages)
+	for end > start && messages[end-1].Role != "user" {
+		end--
+	}
+	if end > start {
+		end--
+	}
+	head, tail := messages[:start], messages[end:]
+	if openai.CountTokenMessages(tail, request.Model) > budget/2 {
+		end = len(messages)
+		tail = nil
+	}
+	if end == start {
+		return nil
+	}
+	var transcript strings.Builder
+	for _, message := range messages[start:end] {
+		transcript.WriteString(message.Role + ": " + message.StringContent() + "\n\n")
+	}
+	target := budget - openai.CountTokenMessages(head, request.Model) - openai.CountTokenMessages(tail, request.Model) - 100
+	if target < longContextMinSummaryTokens {
+		return openai.ErrorWrapper(errLongContextTooLong, "context_length_exceeded", http.StatusBadRequest)
+	}
+	token, err := model.GetTokenById(c.GetInt(ctxkey.TokenId))
+	if err != nil {
+		return openai.ErrorWrapper(err, "get_token_failed", http.StatusInternalServerError)
+	}
+	summarizer := &longContextSummarizer{
+		ctx:    helper.SetSummaryOf(c.Request.Context(), c.GetString(helper.RequestIdKey)),
+		key:    token.Key,
+		model:  config.LongContextSummaryModel,
+		prompt: config.LongContextSummaryPrompt,
+	}
+	summary, err := summarizer.summarize(strings.TrimSpace(transcript.String()), target)
+	c.Header(longContextSummarizedHeader, strconv.Itoa(summarizer.requests))
+	if err != nil {
+		logger.Warnf(c.Request.Context(), "failed to condense the long context: %s", err.Error())
+		return openai.ErrorWrapper(fmt.Errorf("长上下文摘要失败：%s", err.Error()), "long_context_summary_failed", http.StatusBadRequest)
+	}
+	condensed := append([]relaymodel.Message{}, head...)
+	condensed = append(condensed, relaymodel.Message{
+		Role:    "system",
+		Content: "Summary of the earlier conversation:\n\n" + summary,
+	})
+	condensed = append(condensed, tail...)
+	if fields["messages"], err = json.Marshal(condensed); err != nil {
+		return openai.ErrorWrapper(err, "marshal_request_failed", http.StatusInternalServerError)
+	}
+	if body, err = json.Marshal(fields); err != nil {
+		return openai.ErrorWrapper(err, "marshal_request_failed", http.StatusInternalServerError)
+	}
+	c.Set(ctxkey.KeyRequestBody, body)
+	logger.Infof(c.Request.Context(), "condensed %d messages with %d summaries", end-start, summarizer.requests)
+	return nil
+}
+
+// summarize splits the text into parts fitting the summary model and summarizes them, again and again until the
+// summaries take no more than target tokens
+func (s *longContextSummarizer) summarize(text string, target int) (string, error) {
+	chunkTokens := defaults.GetContextWindow(s.model) / 2
+	if chunkTokens <= 0 {
+		chunkTokens = longContextChunkTokens
+	}
+	for round := 0; round < longContextMaxRounds; round++ {
+		tokens := openai.CountTokenText(text, s.model)
+		if round > 0 && tokens <= target {
+			return text, nil
+		}
+		// the tokens are counted on the whole text, the parts are cut by runes at the same ratio
+		size := len([]rune(text)) * chunkTokens / helper.Max(tokens, 1)
+		chunks := vector.Chunk(text, helper.Max(size, 1), 0)
+		maxTokens := helper.Max(target/helper.Max(len(chunks), 1), longContextMinSummaryTokens)
+		summaries, err := s.summarizeChunks(chunks, maxTokens)
+		if err != nil {
+			return "", err
+		}
+		text = strings.Join(summaries, "\n\n")
+	}
+	if openai.CountTokenText(text, s.model) > target {
+		return "", errLongContextTooLong
+	}
+	return text, nil
+}
+
+func (s *longContextSummarizer) summarizeChunks(chunks []string, maxTokens int) ([]string, error) {
+	summaries := make([]string, len(chunks))
+	errs := make([]error, len(chunks))
+	semaphore := make(chan struct{}, longContextConcurrency)
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, chunk string) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			response := &openai.TextResponse{}
+			errs[i] = relayInternalWithContext(s.ctx, s.key, "/v1/chat/completions", &relaymodel.GeneralOpenAIRequest{
+				Model:     s.model,
+				MaxTokens: maxTokens,
+				Messages: []relaymodel.Message{{
+					Role:    "user",
+					Content: strings.ReplaceAll(s.prompt, "{{content}}", chunk),
+				}},
+			}, response)
+			if errs[i] == nil {
+				if len(response.Choices) == 0 {
+					errs[i] = errors.New("模型没有返回内容")
+				} else {
+					summaries[i] = strings.TrimSpace(response.Choices[0].StringContent())
+				}
+			}
+		}(i, chunk)
+	}
+	wg.Wait()
+	s.requests += len(chunks)
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return summaries, nil
+}
@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/mcp"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// the MCP endpoint offers the models of the gateway as tools, along with the tools of the MCP servers
+// configured by the admin, which are named as server__tool; it is stateless, so a session is only an id
+
+const mcpToolSeparator = "__"
+
+const mcpServerTimeout = 60 * time.Second
+
+var mcpProtocolVersions = []string{"2024-11-05", "2025-03-26", "2025-06-18"}
+
+const (
+	mcpToolChat       = "chat"
+	mcpToolListModels = "list_models"
+)
+
+var mcpBuiltinTools = []mcp.Tool{
+	{
+		Name:        mcpToolChat,
+		Description: "Ask a model of the gateway, returns its answer.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"model":      map[string]any{"type": "string", "description": "The model to ask, see list_models."},
+				"prompt":     map[string]any{"type": "string", "description": "The message to the model."},
+				"system":     map[string]any{"type": "string", "description": "The system prompt."},
+				"max_tokens": map[string]any{"type": "integer", "description": "The maximum number of tokens of the answer."},
+			},
+			"required": []string{"model", "prompt"},
+		},
+	},
+	{
+		Name:        mcpToolListModels,
+		Description: "List the models available to the chat tool.",
+		InputSchema: map[string]any{"type": "object", "properties": map[string]any{}},
+	},
+}
+
+// McpMethodNotAllowed answers the GET requests, the gateway does not send messages of its own
+func McpMethodNotAllowed(c *gin.Context) {
+	c.Status(http.StatusMethodNotAllowed)
+}
+
+func Mcp(c *gin.Context) {
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, mcp.NewErrorResponse(nil, mcp.CodeParseError, err.Error()))
+		return
+	}
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '[' {
+		var requests []mcp.Request
+		if err = json.Unmarshal(body, &requests); err != nil {
+			c.JSON(http.StatusBadRequest, mcp.NewErrorResponse(nil, mcp.CodeParseError, err.Error()))
+			return
+		}
+		responses := make([]*mcp.Response, 0, len(requests))
+		for i := range requests {
+			if response := handleMcpRequest(c, &requests[i]); response != nil {
+				responses = append(responses, response)
+			}
+		}
+		if len(responses) == 0 {
+			c.Status(http.StatusAccepted)
+			return
+		}
+		c.JSON(http.StatusOK, responses)
+		return
+	}
+	var request mcp.Request
+	if err = json.Unmarshal(body, &request); err != nil {
+		c.JSON(http.StatusBadRequest, mcp.NewErrorResponse(nil, mcp.CodeParseError, err.Error()))
+		return
+	}
+	response := handleMcpRequest(c, &request)
+	if response == nil {
+		c.Status(http.StatusAccepted)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// handleMcpRequest returns nil for the notifications and the responses of the client
+func handleMcpRequest(c *gin.Context, request *mcp.Request) *mcp.Response {
+	if request.Method == "" || request.IsNotification() {
+		return nil
+	}
+	if request.JSONRPC != "2.0" {
+		return mcp.NewErrorResponse(request.Id, mcp.CodeInvalidRequest, "jsonrpc must be 2.0")
+	}
+	switch request.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(request.Params, &params)
+		version := mcp.ProtocolVersion
+		for _, supported := range mcpProtocolVersions {
+			if params.ProtocolVersion == supported {
+				version = supported
+			}
+		}
+		c.Header(mcp.SessionIdHeader, random.GetUUID())
+		return mcp.NewResponse(request.Id, mcp.InitializeResult{
+			ProtocolVersion: version,
+			Capabilities:    map[string]any{"tools": map[string]any{"listChanged": false}},
+			ServerInfo:      mcp.Implementation{Name: config.SystemName, Version: common.Version},
+		})
+	case "ping":
+		return mcp.NewResponse(request.Id, map[string]any{})
+	case "tools/list":
+		return mcp.NewResponse(request.Id, mcp.ListToolsResult{Tools: listMcpTools(c)})
+	case "tools/call":
+		var params mcp.CallToolParams
+		if err := json.Unmarshal(request.Params, &params); err != nil || params.Name == "" {
+			return mcp.NewErrorResponse(request.Id, mcp.CodeInvalidParams, "name of the tool is required")
+		}
+		result, err := callMcpTool(c, &params)
+		if err != nil {
+			return mcp.NewErrorResponse(request.Id, err.Code, err.Message)
+		}
+		return mcp.NewResponse(request.Id, result)
+	}
+	return mcp.NewErrorResponse(request.Id, mcp.CodeMethodNotFound, "method not found: "+request.Method)
+}
+
+func getUserMcpServers(c *gin.Context) []*model.McpServer {
+	group, _ := model.CacheGetUserGroup(c.GetInt(ctxkey.Id))
+	servers, err := model.GetEnabledMcpServers(group)
+	if err != nil {
+		logger.Errorf(c.Request.Context(), "failed to get MCP servers: %s", err.Error())
+	}
+	return servers
+}
+
+func newMcpClient(server *model.McpServer) *mcp.Client {
+	return &mcp.Client{URL: server.URL, Headers: server.GetHeaders()}
+}
+
+// listMcpTools asks the servers at the same time, the servers failing are left out
+func listMcpTools(c *gin.Context) []mcp.Tool {
+	servers := getUserMcpServers(c)
+	serverTools := make([][]mcp.Tool, len(servers))
+	ctx, cancel := context.WithTimeout(c.Request.Context(), mcpServerTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server *model.McpServer) {
+			defer wg.Done()
+			tools, err := newMcpClient(server).ListTools(ctx)
+			if err != nil {
+				logger.Warnf(ctx, "failed to list the tools of MCP server %s: %s", server.Name, err.Error())
+				return
+			}
+			for j := range tools {
+				tools[j].Name = server.Name + mcpToolSeparator + tools[j].Name
+			}
+			serverTools[i] = tools
+		}(i, server)
+	}
+	wg.Wait()
+	tools := append([]mcp.Tool{}, mcpBuiltinTools...)
+	for _, t := range serverTools {
+		tools = append(tools, t...)
+	}
+	return tools
+}
+
+func callMcpTool(c *gin.Context, params *mcp.CallToolParams) (*mcp.CallToolResult, *mcp.Error) {
+	switch params.Name {
+	case mcpToolChat:
+		return callMcpChat(c, params.Arguments), nil
+	case mcpToolListModels:
+		data, _ := json.Marshal(getAvailableModels(c))
+		return mcp.TextResult(string(data)), nil
+	}
+	serverName, toolName, ok := strings.Cut(params.Name, mcpToolSeparator)
+	if ok {
+		for _, server := range getUserMcpServers(c) {
+			if server.Name == serverName {
+				return callMcpServerTool(c, server, toolName, params.Arguments), nil
+			}
+		}
+	}
+	return nil, &mcp.Error{Code: mcp.CodeInvalidParams, Message: "unknown tool: " + params.Name}
+}
+
+// callMcpChat sends the chat completion through the relay with the key of the token, so it is billed as usual
+func callMcpChat(c *gin.Context, arguments map[string]any) *mcp.CallToolResult {
+	modelName, _ := arguments["model"].(string)
+	prompt, _ := arguments["prompt"].(string)
+	if modelName == "" || prompt == "" {
+		return mcp.ErrorResult("model and prompt are required")
+	}
+	request := &relaymodel.GeneralOpenAIRequest{Model: modelName}
+	if system, _ := arguments["system"].(string); system != "" {
+		request.Messages = append(request.Messages, relaymodel.Message{Role: "system", Content: system})
+	}
+	request.Messages = append(request.Messages, relaymodel.Message{Role: "user", Content: prompt})
+	if maxTokens, ok := arguments["max_tokens"].(float64); ok {
+		request.MaxTokens = int(maxTokens)
+	}
+	token, err := model.GetTokenById(c.GetInt(ctxkey.TokenId))
+	if err != nil {
+		return mcp.ErrorResult(err.Error())
+	}
+	response, err := relayChatCompletion(token.Key, request)
+	if err != nil {
+		return mcp.ErrorResult(err.Error())
+	}
+	if len(response.Choices) == 0 {
+		return mcp.ErrorResult("the model returned no answer")
+	}
+	return mcp.TextResult(response.Choices[0].StringContent())
+}
+
+// callMcpServerTool bills the quota of the server for the call, it is returned if the server cannot be reached
+func callMcpServerTool(c *gin.Context, server *model.McpServer, toolName string, arguments map[string]any) *mcp.CallToolResult {
+	ctx := c.Request.Context()
+	tokenId := c.GetInt(ctxkey.TokenId)
+	userId := c.GetInt(ctxkey.Id)
+	if server.Quota > 0 {
+		if err := model.PreConsumeTokenQuota(tokenId, server.Quota); err != nil {
+			return mcp.ErrorResult(err.Error())
+		}
+	}
+	callCtx, cancel := context.WithTimeout(ctx, mcpServerTimeout)
+	defer cancel()
+	result, err := newMcpClient(server).CallTool(callCtx, toolName, arguments)
+	if err != nil {
+		logger.Warnf(ctx, "failed to call tool %s of MCP server %s: %s", toolName, server.Name, err.Error())
+		if server.Quota > 0 {
+			_ = model.RefundTokenQuota(tokenId, server.Quota, "MCP 服务 "+server.Name+" 调用失败")
+		}
+		return mcp.ErrorResult(fmt.Sprintf("MCP server %s failed: %s", server.Name, err.Error()))
+	}
+	if server.Quota > 0 {
+		_ = model.CacheUpdateUserQuota(ctx, userId)
+		model.RecordConsumeLog(ctx, &model.Log{
+			UserId:    userId,
+			ModelName: server.Name + mcpToolSeparator + toolName,
+			TokenName: c.GetString(ctxkey.TokenName),
+			Quota:     int(server.Quota),
+			Content:   fmt.Sprintf("MCP 工具调用，服务器 %s", server.Name),
+		})
+		model.UpdateUserUsedQuotaAndRequestCount(userId, server.Quota)
+	}
+	return result
+}
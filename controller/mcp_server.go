@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/model"
+)
+
+var mcpServerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func checkMcpServer(server *model.McpServer) error {
+	if !mcpServerNamePattern.MatchString(server.Name) || strings.Contains(server.Name, mcpToolSeparator) {
+		return errors.New("名称只能包含字母、数字、- 与 _，且不能包含 __")
+	}
+	if u, err := url.Parse(server.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("地址无效")
+	}
+	if server.Headers != "" {
+		var headers map[string]string
+		if err := json.Unmarshal([]byte(server.Headers), &headers); err != nil {
+			return errors.New("请求头需为 JSON 对象：" + err.Error())
+		}
+	}
+	if server.Quota < 0 {
+		return errors.New("额度不能为负数")
+	}
+	if server.Status == 0 {
+		server.Status = model.McpServerStatusEnabled
+	}
+	return nil
+}
+
+func GetAllMcpServers(c *gin.Context) {
+	servers, err := model.GetAllMcpServers()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    servers,
+	})
+	return
+}
+
+func AddMcpServer(c *gin.Context) {
+	server := model.McpServer{}
+	err := c.ShouldBindJSON(&server)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	server.Id = 0
+	if err = checkMcpServer(&server); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = server.Insert(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    server,
+	})
+	return
+}
+
+func UpdateMcpServer(c *gin.Context) {
+	server := model.McpServer{}
+	err := c.ShouldBindJSON(&server)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = checkMcpServer(&server); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if _, err = model.GetMcpServerById(server.Id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = server.Update(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    server,
+	})
+	return
+}
+
+func DeleteMcpServer(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteMcpServerById(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// GetMcpServerTools lists the tools of the server as it offers them, to check the server is reachable
+func GetMcpServerTools(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	server, err := model.GetMcpServerById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), mcpServerTimeout)
+	defer cancel()
+	tools, err := newMcpClient(server).ListTools(ctx)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "获取工具列表失败：" + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    tools,
+	})
+	return
+}
@@ -0,0 +1,21 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/relay/billing"
+)
+
+func GetMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"billing_queue_depth":    billing.QueueDepth(),
+			"billing_queue_capacity": billing.QueueCapacity(),
+			"billing_dropped_tasks":  billing.DroppedTasks(),
+		},
+	})
+}
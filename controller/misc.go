@@ -8,9 +8,11 @@ import (
 
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/env"
 	"github.com/songquanpeng/one-api/common/i18n"
 	"github.com/songquanpeng/one-api/common/message"
 	"github.com/songquanpeng/one-api/model"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
 
 	"github.com/gin-gonic/gin"
 )
@@ -28,6 +30,7 @@ func GetStatus(c *gin.Context) {
 			"lark_client_id":              config.LarkClientId,
 			"system_name":                 config.SystemName,
 			"logo":                        config.Logo,
+			"favicon":                     config.Favicon,
 			"footer_html":                 config.Footer,
 			"wechat_qrcode":               config.WeChatAccountQRCodeImageURL,
 			"wechat_login":                config.WeChatAuthEnabled,
@@ -38,12 +41,46 @@ func GetStatus(c *gin.Context) {
 			"chat_link":                   config.ChatLink,
 			"quota_per_unit":              config.QuotaPerUnit,
 			"display_in_currency":         config.DisplayInCurrencyEnabled,
+			"base_currency":               config.BaseCurrency,
+			"exchange_rates":              billingratio.GetExchangeRates(),
 			"oidc":                        config.OidcEnabled,
+			"saml":                        config.SAMLEnabled,
 			"oidc_client_id":              config.OidcClientId,
 			"oidc_well_known":             config.OidcWellKnown,
 			"oidc_authorization_endpoint": config.OidcAuthorizationEndpoint,
 			"oidc_token_endpoint":         config.OidcTokenEndpoint,
 			"oidc_userinfo_endpoint":      config.OidcUserinfoEndpoint,
+			"bot_chat":                    config.BotChatEnabled,
+			"status_page":                 config.StatusPageEnabled,
+			"quota_transfer":              config.QuotaTransferEnabled,
+			"feature_flags":               model.GetFeatureFlags(),
+			"degraded":                    model.IsDegraded(),
+		},
+	})
+	return
+}
+
+func GetEffectiveConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"config_file": env.ConfigFile(),
+			"entries":     config.Effective(),
+		},
+	})
+	return
+}
+
+// GetStartupCheck returns the problems found by the startup check of the node serving the request, the status only
+// tells whether there is any
+func GetStartupCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"policy":   config.StartupCheckPolicy,
+			"problems": model.GetStartupProblems(),
 		},
 	})
 	return
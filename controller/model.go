@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common/ctxkey"
@@ -8,10 +9,12 @@ import (
 	relay "github.com/songquanpeng/one-api/relay"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
 	"github.com/songquanpeng/one-api/relay/apitype"
+	"github.com/songquanpeng/one-api/relay/capability"
 	"github.com/songquanpeng/one-api/relay/channeltype"
 	"github.com/songquanpeng/one-api/relay/meta"
 	relaymodel "github.com/songquanpeng/one-api/relay/model"
 	"net/http"
+	"slices"
 	"strings"
 )
 
@@ -40,6 +43,16 @@ type OpenAIModels struct {
 	Permission []OpenAIModelPermission `json:"permission"`
 	Root       string                  `json:"root"`
 	Parent     *string                 `json:"parent"`
+	// Capabilities extend the model object of OpenAI with what the model can serve, when it is known
+	Capabilities *capability.Capabilities `json:"capabilities,omitempty"`
+}
+
+// withCapabilities returns the model with its capabilities
+func withCapabilities(model OpenAIModels) OpenAIModels {
+	if capabilities := capability.Get(model.Id); !capabilities.IsEmpty() {
+		model.Capabilities = &capabilities
+	}
+	return model
 }
 
 var models []OpenAIModels
@@ -129,16 +142,45 @@ func ListAllModels(c *gin.Context) {
 	})
 }
 
-func ListModels(c *gin.Context) {
-	ctx := c.Request.Context()
-	var availableModels []string
+// getAvailableModels returns the models the token may use, or those of the groups of the user if the token is not limited
+func getAvailableModels(c *gin.Context) []string {
 	if c.GetString(ctxkey.AvailableModels) != "" {
-		availableModels = strings.Split(c.GetString(ctxkey.AvailableModels), ",")
-	} else {
-		userId := c.GetInt(ctxkey.Id)
-		userGroup, _ := model.CacheGetUserGroup(userId)
-		availableModels, _ = model.CacheGetGroupModels(ctx, userGroup)
+		return strings.Split(c.GetString(ctxkey.AvailableModels), ",")
+	}
+	availableModels, _ := getUserModels(c.Request.Context(), c.GetInt(ctxkey.Id))
+	return availableModels
+}
+
+// getUserModels returns the models of the groups of the user and of the other groups of the plan of the user,
+// with those of the groups they inherit
+func getUserModels(ctx context.Context, userId int) ([]string, error) {
+	userGroups, err := model.CacheGetUserGroups(userId)
+	if err != nil {
+		return nil, err
+	}
+	if plan, _ := model.CacheGetUserPlan(userId); plan != nil {
+		userGroups = append(userGroups, plan.GetGroups()...)
+	}
+	models := make([]string, 0)
+	for i, group := range model.ExpandGroups(userGroups) {
+		groupModels, err := model.CacheGetGroupModels(ctx, group.Group)
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			continue
+		}
+		for _, groupModel := range groupModels {
+			if !slices.Contains(models, groupModel) {
+				models = append(models, groupModel)
+			}
+		}
 	}
+	return models, nil
+}
+
+func ListModels(c *gin.Context) {
+	availableModels := getAvailableModels(c)
 	modelSet := make(map[string]bool)
 	for _, availableModel := range availableModels {
 		modelSet[availableModel] = true
@@ -147,19 +189,19 @@ func ListModels(c *gin.Context) {
 	for _, model := range models {
 		if _, ok := modelSet[model.Id]; ok {
 			modelSet[model.Id] = false
-			availableOpenAIModels = append(availableOpenAIModels, model)
+			availableOpenAIModels = append(availableOpenAIModels, withCapabilities(model))
 		}
 	}
 	for modelName, ok := range modelSet {
 		if ok {
-			availableOpenAIModels = append(availableOpenAIModels, OpenAIModels{
+			availableOpenAIModels = append(availableOpenAIModels, withCapabilities(OpenAIModels{
 				Id:      modelName,
 				Object:  "model",
 				Created: 1626777600,
 				OwnedBy: "custom",
 				Root:    modelName,
 				Parent:  nil,
-			})
+			}))
 		}
 	}
 	c.JSON(200, gin.H{
@@ -171,7 +213,7 @@ func ListModels(c *gin.Context) {
 func RetrieveModel(c *gin.Context) {
 	modelId := c.Param("model")
 	if model, ok := modelsMap[modelId]; ok {
-		c.JSON(200, model)
+		c.JSON(200, withCapabilities(model))
 	} else {
 		Error := relaymodel.Error{
 			Message: fmt.Sprintf("The model '%s' does not exist", modelId),
@@ -188,15 +230,7 @@ func RetrieveModel(c *gin.Context) {
 func GetUserAvailableModels(c *gin.Context) {
 	ctx := c.Request.Context()
 	id := c.GetInt(ctxkey.Id)
-	userGroup, err := model.CacheGetUserGroup(id)
-	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": err.Error(),
-		})
-		return
-	}
-	models, err := model.CacheGetGroupModels(ctx, userGroup)
+	models, err := getUserModels(ctx, id)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -8,6 +8,8 @@ import (
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/i18n"
+	"github.com/songquanpeng/one-api/common/network"
+	"github.com/songquanpeng/one-api/common/saml"
 	"github.com/songquanpeng/one-api/model"
 
 	"github.com/gin-gonic/gin"
@@ -46,7 +48,7 @@ func UpdateOption(c *gin.Context) {
 	}
 	switch option.Key {
 	case "Theme":
-		if !config.ValidThemes[option.Value] {
+		if !config.ThemeExists(option.Value) {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
 				"message": "无效的主题",
@@ -77,6 +79,54 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "SAMLEnabled":
+		if option.Value == "true" && (config.SAMLIdPEntityId == "" || config.SAMLIdPSSOURL == "" || config.SAMLIdPCertificate == "") {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无法启用 SAML 登录，请先填入 IdP 的 Entity ID、SSO 地址以及证书！",
+			})
+			return
+		}
+	case "SAMLIdPCertificate":
+		if option.Value == "" && config.SAMLEnabled {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无法清空 IdP 证书，请先禁用 SAML 登录！",
+			})
+			return
+		}
+		if _, err = saml.ParseCertificate(option.Value); option.Value != "" && err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无效的 IdP 证书：" + err.Error(),
+			})
+			return
+		}
+	case "SAMLGroupMapping":
+		var mapping map[string]string
+		if option.Value != "" && json.Unmarshal([]byte(option.Value), &mapping) != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "分组映射必须是 IdP 分组到分组的 JSON 对象",
+			})
+			return
+		}
+	case "RelayCORSAllowedOrigins", "AdminCORSAllowedOrigins":
+		if err = network.IsValidOrigins(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无效的来源：" + err.Error(),
+			})
+			return
+		}
+		// the console is signed in with cookies, any site could act as the user
+		if option.Key == "AdminCORSAllowedOrigins" && network.IsOriginAllowed("*", option.Value) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "管理接口不能允许任意来源",
+			})
+			return
+		}
 	case "TurnstileCheckEnabled":
 		if option.Value == "true" && config.TurnstileSiteKey == "" {
 			c.JSON(http.StatusOK, gin.H{
@@ -85,6 +135,14 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "BaseCurrency":
+		if len(option.Value) != 3 {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "基准货币应为三位货币代码，例如 USD",
+			})
+			return
+		}
 	}
 	err = model.UpdateOption(option.Key, option.Value)
 	if err != nil {
@@ -100,3 +158,21 @@ func UpdateOption(c *gin.Context) {
 	})
 	return
 }
+
+// SyncExchangeRates fetches the exchange rates from ExchangeRateURL now
+func SyncExchangeRates(c *gin.Context) {
+	rates, err := model.SyncExchangeRates()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    rates,
+	})
+	return
+}
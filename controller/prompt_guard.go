@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/middleware"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/guard"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// promptGuardMaxChars is how much of the screened text is given to the model of a policy, the end is kept
+const promptGuardMaxChars = 8000
+
+// screenedText returns the text of the request written by the client in this turn: the messages after the last
+// answer of the model, so that the history already screened is not scored again, the system messages are trusted
+func screenedText(relayMode int, request *relaymodel.GeneralOpenAIRequest) string {
+	var parts []string
+	switch relayMode {
+	case relaymode.ChatCompletions:
+		start := len(request.Messages)
+		for start > 0 && request.Messages[start-1].Role != "assistant" {
+			start--
+		}
+		for _, message := range request.Messages[start:] {
+			if message.Role == "user" || message.Role == "tool" || message.Role == "function" {
+				parts = append(parts, message.StringContent())
+			}
+		}
+	case relaymode.Completions:
+		switch prompt := request.Prompt.(type) {
+		case string:
+			parts = append(parts, prompt)
+		case []any:
+			for _, item := range prompt {
+				if s, ok := item.(string); ok {
+					parts = append(parts, s)
+				}
+			}
+		}
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n\n"))
+}
+
+// scorePrompt asks the model of the policy for the score of the text, the request is relayed with the key of the token
+func scorePrompt(c *gin.Context, policy *guard.Policy, text string) (float64, error) {
+	token, err := model.GetTokenById(c.GetInt(ctxkey.TokenId))
+	if err != nil {
+		return 0, err
+	}
+	if runes := []rune(text); len(runes) > promptGuardMaxChars {
+		text = string(runes[len(runes)-promptGuardMaxChars:])
+	}
+	temperature := 0.0
+	response := &openai.TextResponse{}
+	ctx := helper.SetScreeningOf(c.Request.Context(), c.GetString(helper.RequestIdKey))
+	err = relayInternalWithContext(ctx, token.Key, "/v1/chat/completions", &relaymodel.GeneralOpenAIRequest{
+		Model:       policy.Model,
+		MaxTokens:   8,
+		Temperature: &temperature,
+		Messages:    []relaymodel.Message{{Role: "user", Content: policy.GetPrompt(text)}},
+	}, response)
+	if err != nil {
+		return 0, err
+	}
+	if len(response.Choices) == 0 {
+		return 0, errors.New("模型没有返回内容")
+	}
+	return guard.ParseModelScore(response.Choices[0].StringContent())
+}
+
+// downgradeRequest sends the request to the downgrade model of the policy, on a channel of the model
+func downgradeRequest(c *gin.Context, modelName string) *relaymodel.ErrorWithStatusCode {
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return openai.ErrorWrapper(err, "read_request_body_failed", http.StatusBadRequest)
+	}
+	var fields map[string]any
+	if err = json.Unmarshal(body, &fields); err != nil {
+		return openai.ErrorWrapper(err, "invalid_request", http.StatusBadRequest)
+	}
+	group := c.GetString(ctxkey.Group)
+	channel, err := model.CacheGetRandomSatisfiedChannel(group, modelName, false)
+	if err != nil {
+		return openai.ErrorWrapper(fmt.Errorf("当前分组 %s 下对于模型 %s 无可用渠道", group, modelName), "no_available_channel", http.StatusServiceUnavailable)
+	}
+	fields["model"] = modelName
+	if body, err = json.Marshal(fields); err != nil {
+		return openai.ErrorWrapper(err, "marshal_request_failed", http.StatusInternalServerError)
+	}
+	c.Set(ctxkey.KeyRequestBody, body)
+	c.Set(ctxkey.RequestModel, modelName)
+	middleware.SetupContextForSelectedChannel(c, channel, modelName)
+	c.Header("X-OneAPI-Downgraded-To", modelName)
+	return nil
+}
+
+// screenPrompt scores the chat and completion requests of the user groups with a prompt guard policy, the requests
+// scoring the threshold of the policy are flagged, blocked or downgraded, and the verdict is recorded in the logs
+func screenPrompt(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatusCode {
+	if relayMode != relaymode.ChatCompletions && relayMode != relaymode.Completions {
+		return nil
+	}
+	policy := guard.GetGroupPromptGuard(c.GetString(ctxkey.Group))
+	ctx := c.Request.Context()
+	// the requests sent by the gateway for a request carry the text already screened with it
+	if policy == nil || helper.GetScreeningOf(ctx) != "" || helper.GetSummaryOf(ctx) != "" {
+		return nil
+	}
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return openai.ErrorWrapper(err, "read_request_body_failed", http.StatusBadRequest)
+	}
+	var request relaymodel.GeneralOpenAIRequest
+	if json.Unmarshal(body, &request) != nil {
+		// the relay reports the invalid request
+		return nil
+	}
+	text := screenedText(relayMode, &request)
+	if text == "" {
+		return nil
+	}
+	verdict := policy.Screen(text)
+	if policy.Model != "" {
+		score, err := scorePrompt(c, policy, text)
+		if err != nil {
+			logger.Warnf(ctx, "failed to score the prompt with %s: %s", policy.Model, err.Error())
+		} else {
+			verdict.SetModelScore(score)
+		}
+	}
+	if verdict.Score < policy.GetThreshold() {
+		return nil
+	}
+	note := policy.Action + " " + verdict.String()
+	logger.Warnf(ctx, "prompt injection suspected: %s", note)
+	switch policy.Action {
+	case guard.ActionBlock:
+		model.RecordLog(ctx, c.GetInt(ctxkey.Id), model.LogTypeSystem,
+			fmt.Sprintf("请求 %s 疑似提示注入，已被拦截（%s）", c.GetString(helper.RequestIdKey), note))
+		return openai.ErrorWrapper(errors.New("请求疑似包含提示注入，已被拦截"), "prompt_injection_detected", http.StatusBadRequest)
+	case guard.ActionDowngrade:
+		if bizErr := downgradeRequest(c, policy.DowngradeModel); bizErr != nil {
+			return bizErr
+		}
+		note += " -> " + policy.DowngradeModel
+	}
+	c.Request = c.Request.WithContext(helper.SetPromptGuard(ctx, note))
+	return nil
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/model"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+type ragQueryRequest struct {
+	CollectionId   string   `json:"collection_id"`
+	Question       string   `json:"question"`
+	Model          string   `json:"model"`
+	MaxNumResults  int      `json:"max_num_results"`
+	ScoreThreshold float64  `json:"score_threshold"`
+	Template       string   `json:"template"`
+	Temperature    *float64 `json:"temperature"`
+	MaxTokens      int      `json:"max_tokens"`
+}
+
+type ragCitation struct {
+	Index int `json:"index"`
+	fileSearchResult
+}
+
+// buildRagPrompt numbers the chunks as the sources, so that the answer can cite them
+func buildRagPrompt(template string, question string, citations []ragCitation) string {
+	var sources strings.Builder
+	for _, citation := range citations {
+		if sources.Len() > 0 {
+			sources.WriteString("\n\n")
+		}
+		sources.WriteString(fmt.Sprintf("[%d] %s\n%s", citation.Index, citation.Filename, citation.Content))
+	}
+	if len(citations) == 0 {
+		sources.WriteString("(none)")
+	}
+	return strings.NewReplacer("{{context}}", sources.String(), "{{question}}", question).Replace(template)
+}
+
+// RagQuery answers the question with the chunks of the vector store retrieved for it, the embedding of the
+// question and the chat completion are relayed with the key of the token, so they are billed and logged as usual
+func RagQuery(c *gin.Context) {
+	if !isVectorStoreEnabled(c) {
+		return
+	}
+	var request ragQueryRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	if strings.TrimSpace(request.Question) == "" || request.Model == "" {
+		abortWithOpenAIError(c, http.StatusBadRequest, "question 与 model 不能为空")
+		return
+	}
+	userId := c.GetInt(ctxkey.Id)
+	store, err := model.GetUserVectorStore(userId, request.CollectionId)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusNotFound, fmt.Sprintf("vector store %s 不存在", request.CollectionId))
+		return
+	}
+	token, err := model.GetTokenById(c.GetInt(ctxkey.TokenId))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusUnauthorized, "令牌不存在")
+		return
+	}
+	results, err := searchVectorStores(token.Key, userId, []string{store.VectorStoreId}, request.Question, request.MaxNumResults)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	citations := make([]ragCitation, 0, len(results))
+	for _, result := range results {
+		if result.Score < request.ScoreThreshold {
+			continue
+		}
+		citations = append(citations, ragCitation{Index: len(citations) + 1, fileSearchResult: result})
+	}
+	template := request.Template
+	if template == "" {
+		template = config.RagPromptTemplate
+	}
+	response, err := relayChatCompletion(token.Key, &relaymodel.GeneralOpenAIRequest{
+		Model:       request.Model,
+		Temperature: request.Temperature,
+		MaxTokens:   request.MaxTokens,
+		Messages: []relaymodel.Message{{
+			Role:    "user",
+			Content: buildRagPrompt(template, request.Question, citations),
+		}},
+	})
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	answer := ""
+	if len(response.Choices) > 0 {
+		answer = response.Choices[0].StringContent()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":            "rag-" + random.GetUUID(),
+		"object":        "rag.answer",
+		"created":       helper.GetTimestamp(),
+		"model":         request.Model,
+		"collection_id": store.VectorStoreId,
+		"answer":        answer,
+		"citations":     citations,
+		"usage":         response.Usage,
+	})
+}
@@ -6,24 +6,46 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/i18n"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/middleware"
 	dbmodel "github.com/songquanpeng/one-api/model"
 	"github.com/songquanpeng/one-api/monitor"
+	"github.com/songquanpeng/one-api/relay/apiversion"
 	"github.com/songquanpeng/one-api/relay/controller"
+	"github.com/songquanpeng/one-api/relay/defaults"
 	"github.com/songquanpeng/one-api/relay/model"
 	"github.com/songquanpeng/one-api/relay/relaymode"
 )
 
 // https://platform.openai.com/docs/api-reference/chat
 
+// relayProfileOf returns the relay profile of the requested model, or of the model it is mapped to by the channel
+func relayProfileOf(c *gin.Context) (defaults.RelayProfile, bool) {
+	originalModel := c.GetString(ctxkey.OriginalModel)
+	return defaults.GetRelayProfile(originalModel, c.GetStringMapString(ctxkey.ModelMapping)[originalModel])
+}
+
 func relayHelper(c *gin.Context, relayMode int) *model.ErrorWithStatusCode {
+	wakeChannel(c)
+	profile, _ := relayProfileOf(c)
+	c.Set(ctxkey.UpstreamTimeout, profile.Timeout > 0)
+	if profile.Timeout > 0 {
+		// every attempt has the whole timeout
+		parent := c.Request.Context()
+		ctx, cancel := context.WithTimeout(parent, time.Duration(profile.Timeout)*time.Second)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		defer func() { c.Request = c.Request.WithContext(parent) }()
+	}
+	finishCheck := checkResponse(c, relayMode)
 	var err *model.ErrorWithStatusCode
 	switch relayMode {
 	case relaymode.ImagesGenerations:
@@ -39,30 +61,60 @@ func relayHelper(c *gin.Context, relayMode int) *model.ErrorWithStatusCode {
 	default:
 		err = controller.RelayTextHelper(c)
 	}
+	finishCheck(err)
 	return err
 }
 
 func Relay(c *gin.Context) {
+	defer trackInFlight(c)()
 	ctx := c.Request.Context()
 	relayMode := relaymode.GetByPath(c.Request.URL.Path)
 	if config.DebugEnabled {
 		requestBody, _ := common.GetRequestBody(c)
 		logger.Debugf(ctx, "request body: %s", string(requestBody))
 	}
+	if config.LogRequestBodyEnabled {
+		recordRequestBody(c)
+	}
+	bizErr := screenPrompt(c, relayMode)
+	if bizErr == nil {
+		bizErr = condenseLongContext(c, relayMode)
+	}
+	if bizErr == nil {
+		describeImages(c, relayMode)
+		bizErr = checkCapabilities(c, relayMode)
+	}
+	if bizErr != nil {
+		bizErr.Error.Message = helper.MessageWithRequestId(bizErr.Error.Message, c.GetString(helper.RequestIdKey))
+		c.JSON(bizErr.StatusCode, gin.H{
+			"error": bizErr.Error,
+		})
+		return
+	}
 	channelId := c.GetInt(ctxkey.ChannelId)
 	userId := c.GetInt(ctxkey.Id)
-	bizErr := relayHelper(c, relayMode)
+	bizErr = relayHelper(c, relayMode)
 	if bizErr == nil {
-		monitor.Emit(channelId, true)
+		if !config.StreamSalvageEnabled || !c.GetBool(ctxkey.StreamInterrupted) {
+			// a dropped stream is reported as a failure of the channel by salvageStream
+			monitor.Emit(channelId, true)
+		}
+		salvageStream(c, relayMode)
 		return
 	}
 	lastFailedChannelId := channelId
 	channelName := c.GetString(ctxkey.ChannelName)
-	group := c.GetString(ctxkey.Group)
+	group := retryGroup(c)
 	originalModel := c.GetString(ctxkey.OriginalModel)
-	go processChannelRelayError(ctx, userId, channelId, channelName, *bizErr)
+	if !isRequestKilled(c) {
+		noteAPIVersionError(c, bizErr)
+		go processChannelRelayError(ctx, userId, channelId, channelName, *bizErr)
+	}
 	requestId := c.GetString(helper.RequestIdKey)
 	retryTimes := config.RetryTimes
+	if profile, ok := relayProfileOf(c); ok && profile.RetryTimes != nil {
+		retryTimes = *profile.RetryTimes
+	}
 	if !shouldRetry(c, bizErr.StatusCode) {
 		logger.Errorf(ctx, "relay error happen, status code is %d, won't retry in this case", bizErr.StatusCode)
 		retryTimes = 0
@@ -78,21 +130,37 @@ func Relay(c *gin.Context) {
 			continue
 		}
 		middleware.SetupContextForSelectedChannel(c, channel, originalModel)
+		describeImages(c, relayMode)
+		if bizErr = checkCapabilities(c, relayMode); bizErr != nil {
+			break
+		}
 		requestBody, err := common.GetRequestBody(c)
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 		bizErr = relayHelper(c, relayMode)
 		if bizErr == nil {
+			salvageStream(c, relayMode)
 			return
 		}
 		channelId := c.GetInt(ctxkey.ChannelId)
 		lastFailedChannelId = channelId
 		channelName := c.GetString(ctxkey.ChannelName)
+		if isRequestKilled(c) {
+			break
+		}
+		noteAPIVersionError(c, bizErr)
 		go processChannelRelayError(ctx, userId, channelId, channelName, *bizErr)
 	}
 	if bizErr != nil {
+		controller.NormalizeError(bizErr)
 		if bizErr.StatusCode == http.StatusTooManyRequests {
 			bizErr.Error.Message = "当前分组上游负载已饱和，请稍后再试"
 		}
+		if message, ok := defaults.GetErrorMessage(bizErr.Error.Code, bizErr.Error.Type, bizErr.StatusCode, i18n.GetLang(c)); ok {
+			bizErr.Error.Message = message
+		}
+		if isRequestKilled(c) {
+			bizErr.Error.Message = "请求已被管理员终止"
+		}
 
 		// BUG: bizErr is in race condition
 		bizErr.Error.Message = helper.MessageWithRequestId(bizErr.Error.Message, requestId)
@@ -102,10 +170,23 @@ func Relay(c *gin.Context) {
 	}
 }
 
+// retryGroup is the group whose channels the request is retried on, the group it was served from when
+// the user inherits it from another group
+func retryGroup(c *gin.Context) string {
+	if group := c.GetString(ctxkey.ChannelGroup); group != "" {
+		return group
+	}
+	return c.GetString(ctxkey.Group)
+}
+
 func shouldRetry(c *gin.Context, statusCode int) bool {
 	if _, ok := c.Get(ctxkey.SpecificChannelId); ok {
 		return false
 	}
+	if c.Request.Context().Err() != nil {
+		// the deadline of the client has passed
+		return false
+	}
 	if statusCode == http.StatusTooManyRequests {
 		return true
 	}
@@ -121,8 +202,22 @@ func shouldRetry(c *gin.Context, statusCode int) bool {
 	return true
 }
 
+// noteAPIVersionError remembers the API version rejected by the upstream of the channel, for the channels upgrading
+// their version to stop sending it
+func noteAPIVersionError(c *gin.Context, err *model.ErrorWithStatusCode) {
+	value, _ := c.Get(ctxkey.Config)
+	cfg, ok := value.(dbmodel.ChannelConfig)
+	if !ok || cfg.APIVersion == "" || !apiversion.IsVersionError(err.StatusCode, err.Message) {
+		return
+	}
+	channelId := c.GetInt(ctxkey.ChannelId)
+	apiversion.MarkUnsupported(channelId, cfg.APIVersion)
+	logger.Warnf(c.Request.Context(), "channel #%d rejected the api version %s", channelId, cfg.APIVersion)
+}
+
 func processChannelRelayError(ctx context.Context, userId int, channelId int, channelName string, err model.ErrorWithStatusCode) {
 	logger.Errorf(ctx, "relay error (channel id %d, user id: %d): %s", channelId, userId, err.Message)
+	monitor.ReportUpstreamError(ctx, channelId, channelName, err.StatusCode, err.Message)
 	// https://platform.openai.com/docs/guides/error-codes/api-errors
 	if monitor.ShouldDisableChannel(&err.Error, err.StatusCode) {
 		monitor.DisableChannel(channelId, channelName, err.Message)
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/middleware"
+	dbmodel "github.com/songquanpeng/one-api/model"
+)
+
+func recordRequestBody(c *gin.Context) {
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil || len(requestBody) > config.LogRequestBodyMaxSize*1024 {
+		return
+	}
+	dbmodel.RecordLogBody(c.Request.Context(), c.GetInt(ctxkey.Id), c.GetString(ctxkey.TokenName), c.Request.URL.Path, requestBody)
+}
+
+func GetLogBody(c *gin.Context) {
+	logBody, err := dbmodel.GetLogBodyByRequestId(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "未找到该请求的请求体",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    logBody,
+	})
+	return
+}
+
+type replayRequest struct {
+	ChannelId int    `json:"channel_id"`
+	TokenId   int    `json:"token_id"` // the replay is billed to this token of the current user
+	Model     string `json:"model"`    // replaces the model of the original request if not empty
+}
+
+// ReplayLog sends a recorded request again through the given channel, the response is relayed as is
+func ReplayLog(c *gin.Context) {
+	requestId := c.Param("request_id")
+	req := replayRequest{}
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	logBody, err := dbmodel.GetLogBodyByRequestId(requestId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "未找到该请求的请求体",
+		})
+		return
+	}
+	userId := c.GetInt(ctxkey.Id)
+	token, err := dbmodel.GetTokenByIds(req.TokenId, userId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的令牌",
+		})
+		return
+	}
+	channel, err := dbmodel.GetChannelById(req.ChannelId, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的渠道 Id",
+		})
+		return
+	}
+	body := []byte(logBody.Body)
+	modelName := ""
+	var fields map[string]any
+	if json.Unmarshal(body, &fields) == nil {
+		if req.Model != "" {
+			fields["model"] = req.Model
+			body, _ = json.Marshal(fields)
+		}
+		modelName, _ = fields["model"].(string)
+	}
+	ctx := helper.SetReplayOf(c.Request.Context(), requestId)
+	replay, err := http.NewRequestWithContext(ctx, http.MethodPost, logBody.Path, io.NopCloser(bytes.NewReader(body)))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	replay.Header.Set("Content-Type", "application/json")
+	c.Request = replay
+	c.Set(ctxkey.KeyRequestBody, body)
+	userGroup, _ := dbmodel.CacheGetUserGroup(userId)
+	c.Set(ctxkey.Group, userGroup)
+	c.Set(ctxkey.TokenId, token.Id)
+	c.Set(ctxkey.TokenName, token.Name)
+	c.Set(ctxkey.RequestModel, modelName)
+	c.Set(ctxkey.SpecificChannelId, strconv.Itoa(channel.Id))
+	middleware.SetupContextForSelectedChannel(c, channel, modelName)
+	dbmodel.RecordLog(ctx, userId, dbmodel.LogTypeManage, fmt.Sprintf("通过渠道 #%d 重放请求 %s", channel.Id, requestId))
+	Relay(c)
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	dbmodel "github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/monitor"
+	"github.com/songquanpeng/one-api/relay/conformance"
+	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// checkWriter checks the response as it is written to the client
+type checkWriter struct {
+	gin.ResponseWriter
+	checker *conformance.Checker
+}
+
+func (w *checkWriter) check(data []byte) {
+	if w.checker == nil {
+		// the headers are set before the body
+		w.checker = conformance.New(strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream"))
+	}
+	w.checker.Write(data)
+}
+
+func (w *checkWriter) Write(data []byte) (int, error) {
+	w.check(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *checkWriter) WriteString(s string) (int, error) {
+	w.check([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// checkResponse checks the chat completion the channel answers with when ResponseValidationEnabled is set, the
+// function returned is called with the result of the relay, the malformed responses are kept as samples and
+// counted for the channel to be quarantined
+func checkResponse(c *gin.Context, relayMode int) func(err *model.ErrorWithStatusCode) {
+	if !config.ResponseValidationEnabled || c.GetBool(ctxkey.DryRun) ||
+		(relayMode != relaymode.ChatCompletions && relayMode != relaymode.Completions) {
+		return func(err *model.ErrorWithStatusCode) {}
+	}
+	writer := &checkWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	return func(err *model.ErrorWithStatusCode) {
+		c.Writer = writer.ResponseWriter
+		// the errors are counted as such, and the responses cut by the clients are not those of the upstream
+		status := c.Writer.Status()
+		if err != nil || c.Request.Context().Err() != nil || status < http.StatusOK || status >= http.StatusMultipleChoices {
+			return
+		}
+		checker := writer.checker
+		if checker == nil {
+			checker = conformance.New(false)
+		}
+		ctx := c.Request.Context()
+		channelId := c.GetInt(ctxkey.ChannelId)
+		reason := checker.Result()
+		if reason != "" {
+			logger.Warnf(ctx, "channel #%d answered with a malformed response: %s", channelId, reason)
+			sample := &dbmodel.ResponseSample{
+				ChannelId:  channelId,
+				ModelName:  c.GetString(ctxkey.OriginalModel),
+				Reason:     reason,
+				StatusCode: status,
+			}
+			body := checker.Sample()
+			go dbmodel.RecordResponseSample(ctx, sample, body)
+		}
+		go monitor.CountResponse(channelId, c.GetString(ctxkey.ChannelName), reason)
+	}
+}
+
+// GetResponseSamples returns the malformed responses kept, of the channel given by channel_id or of all channels
+func GetResponseSamples(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	samples, err := dbmodel.GetResponseSamples(channelId, p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    samples,
+	})
+}
+
+func DeleteResponseSamples(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	if err := dbmodel.DeleteResponseSamples(channelId); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/render"
+	"github.com/songquanpeng/one-api/middleware"
+	dbmodel "github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/monitor"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// continuationRequestBody appends the generated part to the request, so that another channel carries on from it
+func continuationRequestBody(body []byte, relayMode int, partialText string) ([]byte, error) {
+	var request map[string]any
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	if relayMode == relaymode.Completions {
+		prompt, _ := request["prompt"].(string)
+		request["prompt"] = prompt + partialText
+	} else {
+		messages, _ := request["messages"].([]any)
+		messages = append(messages,
+			map[string]any{"role": "assistant", "content": partialText},
+			map[string]any{"role": "user", "content": config.StreamSalvagePrompt},
+		)
+		request["messages"] = messages
+	}
+	return json.Marshal(request)
+}
+
+// salvageStream continues a stream dropped by the upstream on another channel,
+// the client sees the two streams as one, and each channel is billed for its own part.
+// The continuation is billed as a request of its own, its prompt being the original prompt followed by
+// the generated part, so the original prompt is billed twice
+func salvageStream(c *gin.Context, relayMode int) {
+	if !config.StreamSalvageEnabled || !c.GetBool(ctxkey.StreamInterrupted) {
+		return
+	}
+	ctx := c.Request.Context()
+	defer func() {
+		// end the stream held back if the continuation did not
+		if c.GetBool(ctxkey.HoldStreamDone) {
+			c.Set(ctxkey.HoldStreamDone, false)
+			render.Done(c)
+		}
+	}()
+	failedChannelId := c.GetInt(ctxkey.ChannelId)
+	monitor.Emit(failedChannelId, false)
+	if _, ok := c.Get(ctxkey.SpecificChannelId); ok {
+		return
+	}
+	group := retryGroup(c)
+	originalModel := c.GetString(ctxkey.OriginalModel)
+	var channel *dbmodel.Channel
+	for i := 0; i < 3; i++ {
+		candidate, err := dbmodel.CacheGetRandomSatisfiedChannel(group, originalModel, i != 0)
+		if err != nil {
+			break
+		}
+		if candidate.Id != failedChannelId {
+			channel = candidate
+			break
+		}
+	}
+	if channel == nil {
+		logger.Warnf(ctx, "no other channel to continue the stream dropped by channel #%d", failedChannelId)
+		return
+	}
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return
+	}
+	requestBody, err = continuationRequestBody(requestBody, relayMode, c.GetString(ctxkey.StreamPartialText))
+	if err != nil {
+		logger.Errorf(ctx, "failed to build the continuation request: %s", err.Error())
+		return
+	}
+	c.Set(ctxkey.KeyRequestBody, requestBody)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+	c.Request.ContentLength = int64(len(requestBody))
+	c.Request.Header.Set("Content-Length", strconv.Itoa(len(requestBody)))
+	logger.Infof(ctx, "channel #%d dropped the stream, continuing on channel #%d", failedChannelId, channel.Id)
+	middleware.SetupContextForSelectedChannel(c, channel, originalModel)
+	if bizErr := relayHelper(c, relayMode); bizErr != nil {
+		go processChannelRelayError(ctx, c.GetInt(ctxkey.Id), channel.Id, channel.Name, *bizErr)
+		return
+	}
+	monitor.Emit(channel.Id, true)
+}
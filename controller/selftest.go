@@ -0,0 +1,358 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/controller"
+	"github.com/songquanpeng/one-api/relay/meta"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// the features checked by the self test, each one is a chat completion sent to the first model of the channel
+const (
+	selfTestChat   = "chat"
+	selfTestStream = "stream"
+	selfTestTools  = "tools"
+	selfTestVision = "vision"
+	selfTestJSON   = "json"
+)
+
+type selfTestCase struct {
+	feature string
+	build   func(request *relaymodel.GeneralOpenAIRequest)
+	// check tells whether the response written by the adaptor, in the format of OpenAI, shows the feature works
+	check func(body []byte) error
+}
+
+var selfTestCases = []selfTestCase{
+	{
+		feature: selfTestChat,
+		build: func(request *relaymodel.GeneralOpenAIRequest) {
+			request.Messages = []relaymodel.Message{{Role: "user", Content: config.TestPrompt}}
+		},
+		check: checkSelfTestContent(nil),
+	},
+	{
+		feature: selfTestStream,
+		build: func(request *relaymodel.GeneralOpenAIRequest) {
+			request.Stream = true
+			request.Messages = []relaymodel.Message{{Role: "user", Content: config.TestPrompt}}
+		},
+		check: checkSelfTestStream,
+	},
+	{
+		feature: selfTestTools,
+		build: func(request *relaymodel.GeneralOpenAIRequest) {
+			request.Messages = []relaymodel.Message{{Role: "user", Content: "What is the weather in Paris? Use the get_weather tool."}}
+			request.Tools = []relaymodel.Tool{{
+				Type: "function",
+				Function: relaymodel.Function{
+					Name:        "get_weather",
+					Description: "Get the current weather of a city.",
+					Parameters: map[string]any{
+						"type":       "object",
+						"properties": map[string]any{"city": map[string]any{"type": "string"}},
+						"required":   []string{"city"},
+					},
+				},
+			}}
+			request.ToolChoice = "auto"
+		},
+		check: checkSelfTestTools,
+	},
+	{
+		feature: selfTestVision,
+		build: func(request *relaymodel.GeneralOpenAIRequest) {
+			request.Messages = []relaymodel.Message{{Role: "user", Content: []any{
+				map[string]any{"type": relaymodel.ContentTypeText, "text": "What is the color of this image? Answer with one word."},
+				map[string]any{"type": relaymodel.ContentTypeImageURL, "image_url": map[string]any{"url": selfTestImageURL}},
+			}}}
+		},
+		check: checkSelfTestContent(nil),
+	},
+	{
+		feature: selfTestJSON,
+		build: func(request *relaymodel.GeneralOpenAIRequest) {
+			request.Messages = []relaymodel.Message{{Role: "user", Content: `Answer in JSON: an object with the key "ok" set to true.`}}
+			request.ResponseFormat = &relaymodel.ResponseFormat{Type: "json_object"}
+		},
+		check: checkSelfTestContent(func(content string) error {
+			var object map[string]any
+			if err := json.Unmarshal([]byte(strings.TrimSpace(content)), &object); err != nil {
+				return fmt.Errorf("the answer is not a JSON object: %s", content)
+			}
+			return nil
+		}),
+	},
+}
+
+// selfTestImageURL is a red square, small enough for any upstream accepting images
+var selfTestImageURL = func() string {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 32; y++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}()
+
+func parseSelfTestResponse(body []byte) (*openai.TextResponseChoice, error) {
+	var response openai.TextResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Choices) == 0 {
+		return nil, errors.New("response has no choices")
+	}
+	return &response.Choices[0], nil
+}
+
+func checkSelfTestContent(checkContent func(content string) error) func(body []byte) error {
+	return func(body []byte) error {
+		choice, err := parseSelfTestResponse(body)
+		if err != nil {
+			return err
+		}
+		content := choice.StringContent()
+		if strings.TrimSpace(content) == "" {
+			return errors.New("the answer is empty")
+		}
+		if checkContent != nil {
+			return checkContent(content)
+		}
+		return nil
+	}
+}
+
+func checkSelfTestStream(body []byte) error {
+	var content strings.Builder
+	chunks := 0
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok || strings.TrimSpace(data) == "[DONE]" {
+			continue
+		}
+		var chunk openai.ChatCompletionsStreamResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid chunk: %s", data)
+		}
+		chunks++
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.StringContent())
+		}
+	}
+	if chunks == 0 {
+		return errors.New("the response is not a stream")
+	}
+	if strings.TrimSpace(content.String()) == "" {
+		return errors.New("the answer is empty")
+	}
+	return nil
+}
+
+func checkSelfTestTools(body []byte) error {
+	choice, err := parseSelfTestResponse(body)
+	if err != nil {
+		return err
+	}
+	for _, call := range choice.ToolCalls {
+		if call.Function.Name == "get_weather" {
+			return nil
+		}
+	}
+	return errors.New("the model did not call the tool")
+}
+
+func doSelfTest(channel *model.Channel, checkedModelName string, modelName string, testCase selfTestCase) error {
+	c, w := newChannelTestContext(channel)
+	meta := meta.GetByContext(c)
+	apiType := channeltype.ToAPIType(channel.Type)
+	adaptor := relay.GetAdaptor(apiType)
+	if adaptor == nil {
+		return fmt.Errorf("invalid api type: %d, adaptor is nil", apiType)
+	}
+	adaptor.Init(meta)
+	request := &relaymodel.GeneralOpenAIRequest{Model: modelName}
+	testCase.build(request)
+	meta.OriginModelName, meta.ActualModelName = checkedModelName, modelName
+	meta.IsStream = request.Stream
+	convertedRequest, err := adaptor.ConvertRequest(c, relaymode.ChatCompletions, request)
+	if err != nil {
+		return err
+	}
+	jsonData, err := json.Marshal(convertedRequest)
+	if err != nil {
+		return err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(jsonData))
+	resp, err := adaptor.DoRequest(c, meta, bytes.NewReader(jsonData))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		relayErr := controller.RelayErrorHandler(resp)
+		return fmt.Errorf("status code %d: %s", resp.StatusCode, relayErr.Error.Message)
+	}
+	if _, respErr := adaptor.DoResponse(c, resp, meta); respErr != nil {
+		return errors.New(respErr.Error.Message)
+	}
+	return testCase.check(w.Body.Bytes())
+}
+
+func selfTestChannel(channel *model.Channel) []*model.SelfTestResult {
+	checkedModelName, modelName := getTestModelName(channel, "")
+	results := make([]*model.SelfTestResult, 0, len(selfTestCases))
+	for _, testCase := range selfTestCases {
+		startTime := time.Now()
+		err := doSelfTest(channel, checkedModelName, modelName, testCase)
+		result := &model.SelfTestResult{
+			ChannelId:   channel.Id,
+			ChannelName: channel.Name,
+			Model:       checkedModelName,
+			Feature:     testCase.feature,
+			Passed:      err == nil,
+			Latency:     helper.CalcElapsedTime(startTime),
+		}
+		if err != nil {
+			result.Message = err.Error()
+		}
+		results = append(results, result)
+		time.Sleep(config.RequestInterval)
+	}
+	return results
+}
+
+var selfTestLock sync.Mutex
+var selfTestRunning bool
+
+// runSelfTest tests the features of the enabled channels, or of the given channel only,
+// the channels are not disabled for the features they lack
+func runSelfTest(channels []*model.Channel) {
+	defer func() {
+		selfTestLock.Lock()
+		selfTestRunning = false
+		selfTestLock.Unlock()
+	}()
+	for _, channel := range channels {
+		if channel.Status != model.ChannelStatusEnabled || model.IsChannelInMaintenance(channel.Id) {
+			continue
+		}
+		if err := model.RecordSelfTestResults(selfTestChannel(channel)); err != nil {
+			logger.SysError("failed to record self test results: " + err.Error())
+		}
+	}
+	logger.SysLog("self test finished")
+}
+
+// StartSelfTest starts the self test of the enabled channels, or of the channel given by channel_id,
+// the results are listed by GetSelfTest
+func StartSelfTest(c *gin.Context) {
+	var channels []*model.Channel
+	var err error
+	if channelId, _ := strconv.Atoi(c.Query("channel_id")); channelId != 0 {
+		var channel *model.Channel
+		channel, err = model.GetChannelById(channelId, true)
+		channels = []*model.Channel{channel}
+	} else {
+		channels, err = model.GetAllChannels(0, 0, "all")
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	selfTestLock.Lock()
+	if selfTestRunning {
+		selfTestLock.Unlock()
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "自检已在运行中",
+		})
+		return
+	}
+	selfTestRunning = true
+	selfTestLock.Unlock()
+	go runSelfTest(channels)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// GetSelfTest lists the last result of each feature of each channel tested
+func GetSelfTest(c *gin.Context) {
+	results, err := model.GetLatestSelfTestResults()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	selfTestLock.Lock()
+	running := selfTestRunning
+	selfTestLock.Unlock()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"running": running,
+			"results": results,
+		},
+	})
+}
+
+// GetSelfTestMatrix returns the features supported by the models, it is shown on the status page
+func GetSelfTestMatrix(c *gin.Context) {
+	matrix, err := model.GetSelfTestMatrix()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	features := make([]string, 0, len(selfTestCases))
+	for _, testCase := range selfTestCases {
+		features = append(features, testCase.feature)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"features": features,
+			"models":   matrix,
+		},
+	})
+}
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/i18n"
+	"github.com/songquanpeng/one-api/model"
+)
+
+type sessionResponse struct {
+	*model.Session
+	// Current tells the session of the request
+	Current bool `json:"current"`
+}
+
+func replySessions(c *gin.Context, userId int) {
+	sessions, err := model.GetUserSessions(userId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	key := c.GetString(ctxkey.SessionKey)
+	data := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		data = append(data, sessionResponse{
+			Session: session,
+			Current: key != "" && session.Key == key,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
+
+// getManagedUser returns the user of the id in the path if the admin may manage the user
+func getManagedUser(c *gin.Context) (*model.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return nil, false
+	}
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return nil, false
+	}
+	myRole := c.GetInt(ctxkey.Role)
+	if myRole <= user.Role && myRole != model.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权更新同权限等级或更高权限等级的用户信息",
+		})
+		return nil, false
+	}
+	return user, true
+}
+
+func GetSelfSessions(c *gin.Context) {
+	replySessions(c, c.GetInt(ctxkey.Id))
+}
+
+func RevokeSelfSession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	revoked, err := model.RevokeSession(c.GetInt(ctxkey.Id), id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "会话不存在",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// RevokeOtherSelfSessions signs the user out everywhere except the session of the request
+func RevokeOtherSelfSessions(c *gin.Context) {
+	key := c.GetString(ctxkey.SessionKey)
+	if key == "" {
+		// an access token has no session to keep
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "请在登录后的网页上操作",
+		})
+		return
+	}
+	if err := model.RevokeUserSessions(c.GetInt(ctxkey.Id), key); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetUserSessions(c *gin.Context) {
+	user, ok := getManagedUser(c)
+	if !ok {
+		return
+	}
+	replySessions(c, user.Id)
+}
+
+// RevokeUserSessions forces the user to sign in again everywhere
+func RevokeUserSessions(c *gin.Context) {
+	user, ok := getManagedUser(c)
+	if !ok {
+		return
+	}
+	if err := model.RevokeUserSessions(user.Id, ""); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
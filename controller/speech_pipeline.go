@@ -0,0 +1,383 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// the speech to speech pipeline transcribes the audio, chats with the transcript and synthesizes the reply, each
+// stage is relayed with the key of the token on its own channel, so that it is billed and logged as its own request
+
+const (
+	speechPipelineTranscriptHeader = "X-OneAPI-Transcript"
+	speechPipelineReplyTrailer     = "X-OneAPI-Reply"
+	// speechPipelineMinSentenceRunes keeps the short sentences with the next one, so that the speech flows
+	speechPipelineMinSentenceRunes = 12
+	speechPipelineMaxFileSize      = 25 << 20
+)
+
+// speechPipelineStreamFormats are the audio formats whose segments can be concatenated, the reply is synthesized
+// sentence by sentence in them, and at once in the other formats
+var speechPipelineStreamFormats = map[string]bool{
+	"mp3": true,
+	"aac": true,
+	"pcm": true,
+}
+
+type speechPipelineRequest struct {
+	Model              string
+	TranscriptionModel string
+	Instructions       string
+	TTSModel           string
+	Voice              string
+	ResponseFormat     string
+	Speed              float64
+	Language           string
+}
+
+func newSpeechPipelineRequest(c *gin.Context) (*speechPipelineRequest, error) {
+	request := &speechPipelineRequest{
+		Model:              c.PostForm("model"),
+		TranscriptionModel: c.DefaultPostForm("transcription_model", "whisper-1"),
+		Instructions:       c.PostForm("instructions"),
+		TTSModel:           c.DefaultPostForm("tts_model", "tts-1"),
+		Voice:              c.DefaultPostForm("voice", "alloy"),
+		ResponseFormat:     c.DefaultPostForm("response_format", "mp3"),
+		Speed:              1,
+		Language:           c.PostForm("language"),
+	}
+	if request.Model == "" {
+		return nil, errors.New("model 不能为空")
+	}
+	if speed := c.PostForm("speed"); speed != "" {
+		value, err := strconv.ParseFloat(speed, 64)
+		if err != nil || value < 0.25 || value > 4 {
+			return nil, errors.New("speed 必须在 0.25 到 4 之间")
+		}
+		request.Speed = value
+	}
+	switch request.ResponseFormat {
+	case "mp3", "aac", "pcm", "opus", "wav", "flac":
+	default:
+		return nil, fmt.Errorf("不支持的音频格式：%s", request.ResponseFormat)
+	}
+	return request, nil
+}
+
+type speechPipeline struct {
+	ctx       context.Context
+	key       string
+	requestId string
+	request   *speechPipelineRequest
+}
+
+// relayError returns the error of the relay response, or nil if it succeeded
+func relayError(w *httptest.ResponseRecorder) error {
+	if w.Code == http.StatusOK {
+		return nil
+	}
+	var errorResponse struct {
+		Error *relaymodel.Error `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &errorResponse); err == nil && errorResponse.Error != nil && errorResponse.Error.Message != "" {
+		return errors.New(errorResponse.Error.Message)
+	}
+	return fmt.Errorf("状态码 %d", w.Code)
+}
+
+// transcribe relays the audio file to the transcriptions and returns the text
+func (p *speechPipeline) transcribe(file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", file.Filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(part, src); err != nil {
+		return "", err
+	}
+	_ = writer.WriteField("model", p.request.TranscriptionModel)
+	_ = writer.WriteField("response_format", "json")
+	if p.request.Language != "" {
+		_ = writer.WriteField("language", p.request.Language)
+	}
+	if err = writer.Close(); err != nil {
+		return "", err
+	}
+	w := httptest.NewRecorder()
+	ctx := helper.SetPipelineStage(p.ctx, p.requestId, "语音转写")
+	if err = relayInternalRaw(ctx, p.key, "/v1/audio/transcriptions", writer.FormDataContentType(), body.Bytes(), w); err != nil {
+		return "", err
+	}
+	if err = relayError(w); err != nil {
+		return "", err
+	}
+	var transcription struct {
+		Text string `json:"text"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &transcription); err != nil {
+		return "", errors.New("解析转写结果失败：" + err.Error())
+	}
+	return strings.TrimSpace(transcription.Text), nil
+}
+
+// pipeResponseWriter passes the streamed chat completion to the pipeline as it is written
+type pipeResponseWriter struct {
+	header http.Header
+	status int
+	pipe   *io.PipeWriter
+	done   <-chan struct{}
+}
+
+func (w *pipeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *pipeResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *pipeResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.pipe.Write(data)
+}
+
+func (w *pipeResponseWriter) Flush() {}
+
+func (w *pipeResponseWriter) CloseNotify() <-chan bool {
+	gone := make(chan bool, 1)
+	go func() {
+		<-w.done
+		gone <- true
+	}()
+	return gone
+}
+
+// isSentenceEnd tells whether the rune ends a sentence that can be synthesized on its own
+func isSentenceEnd(r rune) bool {
+	return strings.ContainsRune("。！？；.!?;\n", r)
+}
+
+// chat relays the streamed chat completion of the transcript, the sentences of the reply are sent as they end, and
+// the whole reply is returned
+func (p *speechPipeline) chat(transcript string, sentences chan<- string) (string, error) {
+	defer close(sentences)
+	var messages []relaymodel.Message
+	if p.request.Instructions != "" {
+		messages = append(messages, relaymodel.Message{Role: "system", Content: p.request.Instructions})
+	}
+	messages = append(messages, relaymodel.Message{Role: "user", Content: transcript})
+	body, err := json.Marshal(&relaymodel.GeneralOpenAIRequest{Model: p.request.Model, Messages: messages, Stream: true})
+	if err != nil {
+		return "", err
+	}
+	reader, pipe := io.Pipe()
+	w := &pipeResponseWriter{header: http.Header{}, pipe: pipe, done: p.ctx.Done()}
+	ctx := helper.SetPipelineStage(p.ctx, p.requestId, "对话")
+	go func() {
+		err := relayInternalRaw(ctx, p.key, "/v1/chat/completions", "application/json", body, w)
+		_ = pipe.CloseWithError(err)
+	}()
+	defer reader.Close()
+
+	var reply, sentence strings.Builder
+	var other bytes.Buffer
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			if !strings.HasPrefix(line, ":") {
+				other.WriteString(line)
+			}
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			continue
+		}
+		var chunk openai.ChatCompletionsStreamResponse
+		if json.Unmarshal([]byte(data), &chunk) != nil {
+			continue
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			for _, r := range choice.Delta.StringContent() {
+				reply.WriteRune(r)
+				sentence.WriteRune(r)
+				if isSentenceEnd(r) && len([]rune(strings.TrimSpace(sentence.String()))) >= speechPipelineMinSentenceRunes {
+					sentences <- strings.TrimSpace(sentence.String())
+					sentence.Reset()
+				}
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return "", err
+	}
+	if w.status != http.StatusOK || reply.Len() == 0 {
+		recorder := httptest.NewRecorder()
+		recorder.Code = w.status
+		recorder.Body = &other
+		if err = relayError(recorder); err != nil {
+			return "", err
+		}
+		if reply.Len() == 0 {
+			return "", errors.New("模型没有返回内容")
+		}
+	}
+	if rest := strings.TrimSpace(sentence.String()); rest != "" {
+		sentences <- rest
+	}
+	return strings.TrimSpace(reply.String()), nil
+}
+
+// synthesize relays the text to the speech and returns the audio
+func (p *speechPipeline) synthesize(text string) (*httptest.ResponseRecorder, error) {
+	body, err := json.Marshal(&openai.TextToSpeechRequest{
+		Model:          p.request.TTSModel,
+		Input:          text,
+		Voice:          p.request.Voice,
+		Speed:          p.request.Speed,
+		ResponseFormat: p.request.ResponseFormat,
+	})
+	if err != nil {
+		return nil, err
+	}
+	w := httptest.NewRecorder()
+	ctx := helper.SetPipelineStage(p.ctx, p.requestId, "语音合成")
+	if err = relayInternalRaw(ctx, p.key, "/v1/audio/speech", "application/json", body, w); err != nil {
+		return nil, err
+	}
+	if err = relayError(w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// SpeechToSpeech answers the spoken question of the audio file with speech, the audio of the reply is written
+// sentence by sentence as it is synthesized
+func SpeechToSpeech(c *gin.Context) {
+	request, err := newSpeechPipelineRequest(c)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, "file 不能为空")
+		return
+	}
+	if file.Size > speechPipelineMaxFileSize {
+		abortWithOpenAIError(c, http.StatusRequestEntityTooLarge, "音频文件不能超过 25 MB")
+		return
+	}
+	token, err := model.GetTokenById(c.GetInt(ctxkey.TokenId))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	pipeline := &speechPipeline{
+		ctx:       c.Request.Context(),
+		key:       token.Key,
+		requestId: c.GetString(helper.RequestIdKey),
+		request:   request,
+	}
+	transcript, err := pipeline.transcribe(file)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadGateway, "语音转写失败："+err.Error())
+		return
+	}
+	if transcript == "" {
+		abortWithOpenAIError(c, http.StatusBadRequest, "没有识别到语音")
+		return
+	}
+
+	sentences := make(chan string, 64)
+	var reply string
+	var chatErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reply, chatErr = pipeline.chat(transcript, sentences)
+	}()
+	written := false
+	var synthesizeErr error
+	synthesizeText := func(text string) {
+		if synthesizeErr != nil {
+			return
+		}
+		audio, err := pipeline.synthesize(text)
+		if err != nil {
+			synthesizeErr = err
+			return
+		}
+		if !written {
+			written = true
+			c.Header(speechPipelineTranscriptHeader, url.QueryEscape(transcript))
+			c.Header("Trailer", speechPipelineReplyTrailer)
+			c.Header("Content-Type", audio.Header().Get("Content-Type"))
+			c.Status(http.StatusOK)
+		}
+		_, _ = c.Writer.Write(audio.Body.Bytes())
+		c.Writer.Flush()
+	}
+	streamable := speechPipelineStreamFormats[request.ResponseFormat]
+	for sentence := range sentences {
+		if streamable {
+			synthesizeText(sentence)
+		}
+	}
+	wg.Wait()
+	if chatErr == nil && !streamable && reply != "" {
+		synthesizeText(reply)
+	}
+	if !written {
+		switch {
+		case chatErr != nil:
+			abortWithOpenAIError(c, http.StatusBadGateway, "对话失败："+chatErr.Error())
+		case synthesizeErr != nil:
+			abortWithOpenAIError(c, http.StatusBadGateway, "语音合成失败："+synthesizeErr.Error())
+		default:
+			abortWithOpenAIError(c, http.StatusBadGateway, "模型没有返回内容")
+		}
+		return
+	}
+	if chatErr != nil || synthesizeErr != nil {
+		logger.Warnf(c.Request.Context(), "speech to speech pipeline ended early: chat %v, speech %v", chatErr, synthesizeErr)
+		return
+	}
+	c.Writer.Header().Set(speechPipelineReplyTrailer, url.QueryEscape(reply))
+}
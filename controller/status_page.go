@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// GetModelStatus returns the availability, error rate and latency of the models derived from the channel health checks
+func GetModelStatus(c *gin.Context) {
+	statuses, err := model.GetModelStatuses()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"window_hours": config.StatusPageWindowHours,
+			"models":       statuses,
+		},
+	})
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/model"
+)
+
+func GetAllPromptTemplates(c *gin.Context) {
+	templates, err := model.GetAllPromptTemplates()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    templates,
+	})
+	return
+}
+
+func GetPromptTemplateVersions(c *gin.Context) {
+	templates, err := model.GetPromptTemplateVersions(c.Param("name"))
+	if err == nil && len(templates) == 0 {
+		err = errors.New("模板不存在")
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    templates,
+	})
+	return
+}
+
+// SavePromptTemplate adds a new version of the template, the previous versions are kept for the clients pinning them
+func SavePromptTemplate(c *gin.Context) {
+	template := model.PromptTemplate{}
+	err := c.ShouldBindJSON(&template)
+	if err == nil && (template.Name == "" || len(template.Name) > 64) {
+		err = errors.New("模板名称不能为空且不能超过 64 个字符")
+	}
+	if err == nil {
+		err = template.Insert()
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    template,
+	})
+	return
+}
+
+func DeletePromptTemplate(c *gin.Context) {
+	err := model.DeletePromptTemplate(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
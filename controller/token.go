@@ -9,8 +9,11 @@ import (
 	"github.com/songquanpeng/one-api/common/network"
 	"github.com/songquanpeng/one-api/common/random"
 	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/defaults"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 )
 
 func GetAllTokens(c *gin.Context) {
@@ -117,6 +120,24 @@ func validateToken(c *gin.Context, token model.Token) error {
 			return fmt.Errorf("无效的网段：%s", err.Error())
 		}
 	}
+	if _, err := defaults.Parse(token.Defaults); err != nil {
+		return fmt.Errorf("无效的默认参数：%s", err.Error())
+	}
+	if token.MaxConcurrency < 0 {
+		return fmt.Errorf("并发数不能为负数")
+	}
+	if err := network.IsValidOrigins(token.AllowedOrigins); err != nil {
+		return fmt.Errorf("无效的来源：%s", err.Error())
+	}
+	if len(token.OpenAIOrganization) > 64 || len(token.OpenAIProject) > 64 {
+		return fmt.Errorf("OpenAI 组织或项目 ID 过长")
+	}
+	if token.TranscriptWebhook != "" {
+		u, err := url.Parse(token.TranscriptWebhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(token.TranscriptWebhook) > 512 {
+			return fmt.Errorf("无效的对话记录推送地址")
+		}
+	}
 	return nil
 }
 
@@ -140,16 +161,23 @@ func AddToken(c *gin.Context) {
 	}
 
 	cleanToken := model.Token{
-		UserId:         c.GetInt(ctxkey.Id),
-		Name:           token.Name,
-		Key:            random.GenerateKey(),
-		CreatedTime:    helper.GetTimestamp(),
-		AccessedTime:   helper.GetTimestamp(),
-		ExpiredTime:    token.ExpiredTime,
-		RemainQuota:    token.RemainQuota,
-		UnlimitedQuota: token.UnlimitedQuota,
-		Models:         token.Models,
-		Subnet:         token.Subnet,
+		UserId:             c.GetInt(ctxkey.Id),
+		Name:               token.Name,
+		Key:                random.GenerateKey(),
+		CreatedTime:        helper.GetTimestamp(),
+		AccessedTime:       helper.GetTimestamp(),
+		ExpiredTime:        token.ExpiredTime,
+		RemainQuota:        token.RemainQuota,
+		UnlimitedQuota:     token.UnlimitedQuota,
+		Models:             token.Models,
+		Subnet:             token.Subnet,
+		Defaults:           token.Defaults,
+		MaxConcurrency:     token.MaxConcurrency,
+		AllowedOrigins:     token.AllowedOrigins,
+		Sandbox:            token.Sandbox,
+		OpenAIOrganization: strings.TrimSpace(token.OpenAIOrganization),
+		OpenAIProject:      strings.TrimSpace(token.OpenAIProject),
+		TranscriptWebhook:  token.TranscriptWebhook,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -185,6 +213,24 @@ func DeleteToken(c *gin.Context) {
 	return
 }
 
+// RotateToken gives the token a new key, for the clients to replace a key which may have leaked
+func RotateToken(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	token, err := model.RotateTokenKey(id, c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    token,
+	})
+}
+
 func UpdateToken(c *gin.Context) {
 	userId := c.GetInt(ctxkey.Id)
 	statusOnly := c.Query("status_only")
@@ -239,6 +285,13 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.UnlimitedQuota = token.UnlimitedQuota
 		cleanToken.Models = token.Models
 		cleanToken.Subnet = token.Subnet
+		cleanToken.Defaults = token.Defaults
+		cleanToken.MaxConcurrency = token.MaxConcurrency
+		cleanToken.AllowedOrigins = token.AllowedOrigins
+		cleanToken.Sandbox = token.Sandbox
+		cleanToken.OpenAIOrganization = strings.TrimSpace(token.OpenAIOrganization)
+		cleanToken.OpenAIProject = strings.TrimSpace(token.OpenAIProject)
+		cleanToken.TranscriptWebhook = token.TranscriptWebhook
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+)
+
+type tokenAnomalyResponse struct {
+	*model.TokenAnomaly
+	Description string `json:"description"`
+}
+
+func getTokenAnomalies(c *gin.Context, userId int) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	anomalies, err := model.GetTokenAnomalies(userId, p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	data := make([]tokenAnomalyResponse, 0, len(anomalies))
+	for _, anomaly := range anomalies {
+		data = append(data, tokenAnomalyResponse{TokenAnomaly: anomaly, Description: anomaly.Description()})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
+
+func resolveTokenAnomaly(c *gin.Context, userId int) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.ResolveTokenAnomaly(id, userId); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// GetUserTokenAnomalies lists the unresolved anomalies of the tokens of the user
+func GetUserTokenAnomalies(c *gin.Context) {
+	getTokenAnomalies(c, c.GetInt(ctxkey.Id))
+}
+
+func ResolveUserTokenAnomaly(c *gin.Context) {
+	resolveTokenAnomaly(c, c.GetInt(ctxkey.Id))
+}
+
+// GetAllTokenAnomalies lists the unresolved anomalies of the tokens of all users
+func GetAllTokenAnomalies(c *gin.Context) {
+	getTokenAnomalies(c, 0)
+}
+
+func ResolveTokenAnomaly(c *gin.Context) {
+	resolveTokenAnomaly(c, 0)
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// transcriptPollInterval is short, the transcripts are posted soon after the completions
+const transcriptPollInterval = time.Second
+
+// transcriptConcurrency is how many transcripts are posted at the same time, so that a slow webhook does not
+// hold the others back for long
+const transcriptConcurrency = 8
+
+func postTranscript(delivery *model.TranscriptDelivery) error {
+	token, err := model.GetTokenById(delivery.TokenId)
+	if err != nil {
+		return err
+	}
+	body := []byte(delivery.Body)
+	ctx, cancel := context.WithTimeout(context.Background(), asyncCallbackTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(asyncSignatureHeader, common.SignWithTokenKey(token.Key, body))
+	resp, err := client.UserContentRequestHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// deliverTranscript posts the transcript, a transcript not answered with 2xx is retried with the delays
+// of the async callbacks, then given up
+func deliverTranscript(delivery *model.TranscriptDelivery) {
+	delivery.Attempts++
+	err := postTranscript(delivery)
+	if err != nil && delivery.Attempts <= len(asyncRetryDelays) {
+		logger.SysLog(fmt.Sprintf("failed to post transcript #%d of token #%d: %s", delivery.Id, delivery.TokenId, err.Error()))
+		delivery.NextAttemptAt = helper.GetTimestamp() + asyncRetryDelays[delivery.Attempts-1]
+		if err = delivery.UpdateAttempt(); err != nil {
+			logger.SysError(fmt.Sprintf("failed to update transcript #%d: %s", delivery.Id, err.Error()))
+		}
+		return
+	}
+	if err != nil {
+		logger.SysError(fmt.Sprintf("gave up posting transcript #%d of token #%d: %s", delivery.Id, delivery.TokenId, err.Error()))
+	}
+	if err = delivery.Delete(); err != nil {
+		logger.SysError(fmt.Sprintf("failed to delete transcript #%d: %s", delivery.Id, err.Error()))
+	}
+}
+
+// AutomaticallyDeliverTranscripts posts the transcripts queued for the transcript webhooks of the tokens on the leader
+func AutomaticallyDeliverTranscripts() {
+	for {
+		time.Sleep(transcriptPollInterval)
+		if !model.IsLeader() {
+			continue
+		}
+		deliveries, err := model.GetDueTranscriptDeliveries(100)
+		if err != nil {
+			logger.SysError("failed to get transcripts: " + err.Error())
+			continue
+		}
+		slots := make(chan struct{}, transcriptConcurrency)
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func(delivery *model.TranscriptDelivery) {
+				defer func() {
+					<-slots
+					wg.Done()
+				}()
+				deliverTranscript(delivery)
+			}(delivery)
+		}
+		wg.Wait()
+	}
+}
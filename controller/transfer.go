@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+)
+
+type transferQuotaRequest struct {
+	Username string `json:"username"`
+	Quota    int64  `json:"quota"`
+	Remark   string `json:"remark"`
+}
+
+func TransferQuota(c *gin.Context) {
+	req := transferQuotaRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	transfer, err := model.TransferQuota(c.Request.Context(), c.GetInt(ctxkey.Id), req.Username, req.Quota, req.Remark)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    transfer,
+	})
+}
+
+func respondQuotaTransfers(c *gin.Context, userId int) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	transfers, err := model.GetQuotaTransfers(userId, p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    transfers,
+	})
+}
+
+func GetSelfQuotaTransfers(c *gin.Context) {
+	respondQuotaTransfers(c, c.GetInt(ctxkey.Id))
+}
+
+// GetQuotaTransfers lists the whole ledger, or the transfers of the user given by user_id
+func GetQuotaTransfers(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	respondQuotaTransfers(c, userId)
+}
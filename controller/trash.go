@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+)
+
+func getTrashPage(c *gin.Context) int {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	return p * config.ItemsPerPage
+}
+
+func respondTrash(c *gin.Context, data any, err error) {
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
+
+func GetDeletedChannels(c *gin.Context) {
+	channels, err := model.GetDeletedChannels(getTrashPage(c), config.ItemsPerPage)
+	respondTrash(c, channels, err)
+}
+
+func RestoreChannel(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	respondTrash(c, nil, model.RestoreChannelById(id))
+}
+
+func PurgeChannel(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	respondTrash(c, nil, model.PurgeChannelById(id))
+}
+
+func GetDeletedTokens(c *gin.Context) {
+	tokens, err := model.GetDeletedTokens(c.GetInt(ctxkey.Id), getTrashPage(c), config.ItemsPerPage)
+	respondTrash(c, tokens, err)
+}
+
+func RestoreToken(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	respondTrash(c, nil, model.RestoreTokenById(id, c.GetInt(ctxkey.Id)))
+}
+
+func PurgeToken(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	respondTrash(c, nil, model.PurgeTokenById(id, c.GetInt(ctxkey.Id)))
+}
+
+func GetDeletedUsers(c *gin.Context) {
+	users, err := model.GetDeletedUsers(getTrashPage(c), config.ItemsPerPage)
+	respondTrash(c, users, err)
+}
+
+// checkDeletedUser makes sure the admin outranks the user in the trash, as deleting the user requires
+func checkDeletedUser(c *gin.Context) (int, bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	user, err := model.GetDeletedUserById(id)
+	if err != nil {
+		respondTrash(c, nil, err)
+		return 0, false
+	}
+	if c.GetInt(ctxkey.Role) <= user.Role {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权操作同权限等级或更高权限等级的用户",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+func RestoreUser(c *gin.Context) {
+	if id, ok := checkDeletedUser(c); ok {
+		respondTrash(c, nil, model.RestoreUserById(id))
+	}
+}
+
+func PurgeUser(c *gin.Context) {
+	if id, ok := checkDeletedUser(c); ok {
+		respondTrash(c, nil, model.PurgeUserById(id))
+	}
+}
@@ -0,0 +1,269 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/i18n"
+	"github.com/songquanpeng/one-api/common/totp"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// twoFactorLoginTimeout is the seconds the user has to enter the code after the password
+const twoFactorLoginTimeout = 5 * 60
+
+type twoFactorRequest struct {
+	Code string `json:"code"`
+}
+
+func bindTwoFactorRequest(c *gin.Context) (string, bool) {
+	var request twoFactorRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&request); err != nil || request.Code == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return "", false
+	}
+	return request.Code, true
+}
+
+// LoginTwoFactor finishes the login of the user who has entered the password, or been signed in by an IdP,
+// with the code of the authenticator app or a recovery code
+func LoginTwoFactor(c *gin.Context) {
+	code, ok := bindTwoFactorRequest(c)
+	if !ok {
+		return
+	}
+	session := sessions.Default(c)
+	id, _ := session.Get("two_factor_id").(int)
+	expiresAt, _ := session.Get("two_factor_expires_at").(int64)
+	if id == 0 || helper.GetTimestamp() > expiresAt {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "登录已过期，请重新登录",
+		})
+		return
+	}
+	user, err := model.GetUserById(id, true)
+	if err != nil || user.Status != model.UserStatusEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "用户已被封禁",
+		})
+		return
+	}
+	if !user.VerifyTwoFactor(code) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "验证码错误或已被使用",
+		})
+		return
+	}
+	if err = saveLoginSession(user, c); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无法保存会话信息，请重试",
+		})
+		return
+	}
+	replyLogin(user, c)
+}
+
+func GetTwoFactorStatus(c *gin.Context) {
+	user, err := model.GetUserById(c.GetInt(ctxkey.Id), true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"enabled":             user.TwoFactorEnabled,
+			"enforced":            model.IsTwoFactorEnforced(user.Role),
+			"recovery_codes_left": user.RecoveryCodesLeft(),
+		},
+	})
+}
+
+// SetupTwoFactor generates the secret to be added to the authenticator app, it is enabled once a code of it is entered
+func SetupTwoFactor(c *gin.Context) {
+	user, err := model.GetUserById(c.GetInt(ctxkey.Id), true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "两步验证已启用",
+		})
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err == nil {
+		err = model.SetTwoFactorSecret(user.Id, secret)
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"secret": secret,
+			"uri":    totp.URI(config.SystemName, user.Username, secret),
+		},
+	})
+}
+
+// EnableTwoFactor enables the two-factor authentication with the code of the secret, the recovery codes
+// are returned once
+func EnableTwoFactor(c *gin.Context) {
+	code, ok := bindTwoFactorRequest(c)
+	if !ok {
+		return
+	}
+	user, err := model.GetUserById(c.GetInt(ctxkey.Id), true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	codes, enabled, err := user.EnableTwoFactor(code)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if !enabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "验证码错误，请确认设备的时间准确",
+		})
+		return
+	}
+	session := sessions.Default(c)
+	if session.Get("two_factor_setup_required") != nil {
+		session.Delete("two_factor_setup_required")
+		_ = session.Save()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// verifySelfTwoFactor checks the code before the two-factor authentication of the user is changed
+func verifySelfTwoFactor(c *gin.Context) (*model.User, bool) {
+	code, ok := bindTwoFactorRequest(c)
+	if !ok {
+		return nil, false
+	}
+	user, err := model.GetUserById(c.GetInt(ctxkey.Id), true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return nil, false
+	}
+	if !user.VerifyTwoFactor(code) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "验证码错误或已被使用",
+		})
+		return nil, false
+	}
+	return user, true
+}
+
+func DisableTwoFactor(c *gin.Context) {
+	user, ok := verifySelfTwoFactor(c)
+	if !ok {
+		return
+	}
+	if model.IsTwoFactorEnforced(user.Role) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员要求你的角色启用两步验证，无法关闭",
+		})
+		return
+	}
+	if err := model.DisableTwoFactor(user.Id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := verifySelfTwoFactor(c)
+	if !ok {
+		return
+	}
+	codes, err := model.RegenerateRecoveryCodes(user.Id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// ResetUserTwoFactor disables the two-factor authentication of a user who has lost the device and the recovery codes
+func ResetUserTwoFactor(c *gin.Context) {
+	user, ok := getManagedUser(c)
+	if !ok {
+		return
+	}
+	if err := model.DisableTwoFactor(user.Id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
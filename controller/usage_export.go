@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+)
+
+// usageExportLag is how old a consume log must be to be exported, the logs written meanwhile with
+// smaller ids are exported before the cursor passes them
+const usageExportLag = 60
+
+const usageExportBatchSize = 500
+
+type usageRecord struct {
+	Object            string `json:"object"`
+	Id                string `json:"id"`
+	CreatedAt         int64  `json:"created_at"`
+	UserId            int    `json:"user_id"`
+	Username          string `json:"username"`
+	TokenName         string `json:"token_name"`
+	ModelName         string `json:"model_name"`
+	ChannelId         int    `json:"channel_id"`
+	Quota             int    `json:"quota"`
+	PromptTokens      int    `json:"prompt_tokens"`
+	CompletionTokens  int    `json:"completion_tokens"`
+	IsStream          bool   `json:"is_stream"`
+	ElapsedTime       int64  `json:"elapsed_time"`
+	RequestId         string `json:"request_id"`
+	UpstreamRequestId string `json:"upstream_request_id"`
+	UpstreamAccount   string `json:"upstream_account,omitempty"`
+	Project           string `json:"project,omitempty"`
+	// Cost is the quota in Currency, when the export is given a currency
+	Cost     *float64 `json:"cost,omitempty"`
+	Currency string   `json:"currency,omitempty"`
+}
+
+type usageExportCursor struct {
+	Object     string `json:"object"`
+	NextCursor string `json:"next_cursor"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// ExportUsage streams the consume logs after the cursor as NDJSON, one usage record per line, the last line is
+// the cursor of the next export. The id of a record is stable, so the records exported again after a failed
+// export can be deduplicated, the cursor being advanced by the client only once it has stored them
+func ExportUsage(c *gin.Context) {
+	cursor, err := strconv.Atoi(c.DefaultQuery("cursor", "0"))
+	if err != nil || cursor < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的游标",
+		})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100000 {
+		limit = 10000
+	}
+	currency := strings.ToUpper(c.Query("currency"))
+	if currency != "" {
+		if _, ok := billingratio.GetExchangeRate(currency); !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "不支持的货币：" + currency,
+			})
+			return
+		}
+	}
+	until := helper.GetTimestamp() - usageExportLag
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	exported := 0
+	hasMore := true
+	for exported < limit {
+		size := usageExportBatchSize
+		if limit-exported < size {
+			size = limit - exported
+		}
+		logs, err := model.GetUsageRecords(cursor, until, size)
+		if err != nil {
+			// the client sees the stream ending without the cursor line and exports again from its cursor
+			logger.SysError("failed to export usage records: " + err.Error())
+			return
+		}
+		for _, log := range logs {
+			record := usageRecord{
+				Object:            "usage.record",
+				Id:                strconv.Itoa(log.Id),
+				CreatedAt:         log.CreatedAt,
+				UserId:            log.UserId,
+				Username:          log.Username,
+				TokenName:         log.TokenName,
+				ModelName:         log.ModelName,
+				ChannelId:         log.ChannelId,
+				Quota:             log.Quota,
+				PromptTokens:      log.PromptTokens,
+				CompletionTokens:  log.CompletionTokens,
+				IsStream:          log.IsStream,
+				ElapsedTime:       log.ElapsedTime,
+				RequestId:         log.RequestId,
+				UpstreamRequestId: log.UpstreamRequestId,
+				UpstreamAccount:   log.UpstreamAccount,
+				Project:           log.Project,
+			}
+			if currency != "" {
+				cost, _ := billingratio.QuotaToCurrency(int64(log.Quota), currency)
+				record.Cost, record.Currency = &cost, currency
+			}
+			_ = encoder.Encode(record)
+			cursor = log.Id
+		}
+		c.Writer.Flush()
+		exported += len(logs)
+		if len(logs) < size {
+			hasMore = false
+			break
+		}
+	}
+	_ = encoder.Encode(usageExportCursor{
+		Object:     "usage.cursor",
+		NextCursor: strconv.Itoa(cursor),
+		HasMore:    hasMore,
+	})
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,7 +14,9 @@ import (
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/i18n"
+	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/common/random"
 	"github.com/songquanpeng/one-api/model"
 )
@@ -66,12 +69,7 @@ func Login(c *gin.Context) {
 
 // setup session & cookies and then return user info
 func SetupLogin(user *model.User, c *gin.Context) {
-	session := sessions.Default(c)
-	session.Set("id", user.Id)
-	session.Set("username", user.Username)
-	session.Set("role", user.Role)
-	session.Set("status", user.Status)
-	err := session.Save()
+	twoFactorRequired, err := SetupSession(user, c)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "无法保存会话信息，请重试",
@@ -79,15 +77,83 @@ func SetupLogin(user *model.User, c *gin.Context) {
 		})
 		return
 	}
+	if twoFactorRequired {
+		// the web page asks for the code and posts it to /api/user/login/2fa
+		c.JSON(http.StatusOK, gin.H{
+			"message": "two_factor",
+			"success": true,
+		})
+		return
+	}
+	replyLogin(user, c)
+}
+
+// SetupSession signs the user in, or only remembers the user until the code of the two-factor authentication
+// is verified if it is enabled, in which case it returns true
+func SetupSession(user *model.User, c *gin.Context) (bool, error) {
+	session := sessions.Default(c)
+	if user.TwoFactorEnabled {
+		if err := revokeCurrentSession(session); err != nil {
+			return false, err
+		}
+		session.Clear()
+		session.Set("two_factor_id", user.Id)
+		session.Set("two_factor_expires_at", helper.GetTimestamp()+twoFactorLoginTimeout)
+		return true, session.Save()
+	}
+	return false, saveLoginSession(user, c)
+}
+
+// revokeCurrentSession revokes the session signed in before in the browser, if any
+func revokeCurrentSession(session sessions.Session) error {
+	if key, ok := session.Get("session_key").(string); ok {
+		return model.RevokeSessionByKey(key)
+	}
+	return nil
+}
+
+// saveLoginSession records a new session of the user and keeps its key in the cookie
+func saveLoginSession(user *model.User, c *gin.Context) error {
+	session := sessions.Default(c)
+	if err := revokeCurrentSession(session); err != nil {
+		return err
+	}
+	record, err := model.CreateSession(user.Id, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		return err
+	}
+	session.Set("session_key", record.Key)
+	session.Delete("two_factor_id")
+	session.Delete("two_factor_expires_at")
+	session.Set("id", user.Id)
+	session.Set("username", user.Username)
+	session.Set("role", user.Role)
+	session.Set("status", user.Status)
+	// the users of the roles it is enforced for can only enable the two-factor authentication till then
+	if model.IsTwoFactorEnforced(user.Role) && !user.TwoFactorEnabled {
+		session.Set("two_factor_setup_required", true)
+	} else {
+		session.Delete("two_factor_setup_required")
+	}
+	return session.Save()
+}
+
+func replyLogin(user *model.User, c *gin.Context) {
+	message := ""
+	if model.IsTwoFactorEnforced(user.Role) && !user.TwoFactorEnabled {
+		message = "two_factor_setup"
+	}
 	cleanUser := model.User{
-		Id:          user.Id,
-		Username:    user.Username,
-		DisplayName: user.DisplayName,
-		Role:        user.Role,
-		Status:      user.Status,
+		Id:               user.Id,
+		Username:         user.Username,
+		DisplayName:      user.DisplayName,
+		Role:             user.Role,
+		Status:           user.Status,
+		TwoFactorEnabled: user.TwoFactorEnabled,
+		Currency:         user.Currency,
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"message": "",
+		"message": message,
 		"success": true,
 		"data":    cleanUser,
 	})
@@ -95,6 +161,13 @@ func SetupLogin(user *model.User, c *gin.Context) {
 
 func Logout(c *gin.Context) {
 	session := sessions.Default(c)
+	if err := revokeCurrentSession(session); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": err.Error(),
+			"success": false,
+		})
+		return
+	}
 	session.Clear()
 	err := session.Save()
 	if err != nil {
@@ -110,6 +183,13 @@ func Logout(c *gin.Context) {
 	})
 }
 
+func truncateDeviceId(deviceId string) string {
+	if len(deviceId) > 64 {
+		return deviceId[:64]
+	}
+	return deviceId
+}
+
 func Register(c *gin.Context) {
 	ctx := c.Request.Context()
 	if !config.RegisterEnabled {
@@ -126,8 +206,12 @@ func Register(c *gin.Context) {
 		})
 		return
 	}
-	var user model.User
-	err := json.NewDecoder(c.Request.Body).Decode(&user)
+	var req struct {
+		model.User
+		// DeviceId is a random id kept by the browser, it is used to dedupe the invite rewards
+		DeviceId string `json:"device_id"`
+	}
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -135,6 +219,7 @@ func Register(c *gin.Context) {
 		})
 		return
 	}
+	user := req.User
 	if err := common.Validate.Struct(&user); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -161,10 +246,12 @@ func Register(c *gin.Context) {
 	affCode := user.AffCode // this code is the inviter's code, not the user's own code
 	inviterId, _ := model.GetUserIdByAffCode(affCode)
 	cleanUser := model.User{
-		Username:    user.Username,
-		Password:    user.Password,
-		DisplayName: user.Username,
-		InviterId:   inviterId,
+		Username:       user.Username,
+		Password:       user.Password,
+		DisplayName:    user.Username,
+		InviterId:      inviterId,
+		RegisterIp:     c.ClientIP(),
+		RegisterDevice: truncateDeviceId(req.DeviceId),
 	}
 	if config.EmailVerificationEnabled {
 		cleanUser.Email = user.Email
@@ -367,7 +454,17 @@ func GetSelf(c *gin.Context) {
 func UpdateUser(c *gin.Context) {
 	ctx := c.Request.Context()
 	var updatedUser model.User
-	err := json.NewDecoder(c.Request.Body).Decode(&updatedUser)
+	// the extra groups are kept when omitted, and removed when empty
+	var extraGroups struct {
+		ExtraGroups *string `json:"extra_groups"`
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &updatedUser)
+	}
+	if err == nil {
+		err = json.Unmarshal(body, &extraGroups)
+	}
 	if err != nil || updatedUser.Id == 0 {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -419,6 +516,26 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
+	if extraGroups.ExtraGroups != nil && *extraGroups.ExtraGroups != originUser.ExtraGroups {
+		if err := model.UpdateUserExtraGroups(originUser.Id, *extraGroups.ExtraGroups); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	// the role is not updated when it is omitted
+	demoted := updatedUser.Role != 0 && updatedUser.Role < originUser.Role
+	if updatePassword || demoted {
+		if err := model.RevokeUserSessions(originUser.Id, ""); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
 	if originUser.Quota != updatedUser.Quota {
 		model.RecordLog(ctx, originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", common.LogQuota(originUser.Quota), common.LogQuota(updatedUser.Quota)))
 	}
@@ -468,6 +585,16 @@ func UpdateSelf(c *gin.Context) {
 		})
 		return
 	}
+	if updatePassword {
+		// the other sessions are signed out, the one changing the password is kept
+		if err := model.RevokeUserSessions(cleanUser.Id, c.GetString(ctxkey.SessionKey)); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -693,6 +820,15 @@ func ManageUser(c *gin.Context) {
 		})
 		return
 	}
+	if req.Action == "disable" || req.Action == "demote" {
+		if err := model.RevokeUserSessions(user.Id, ""); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
 	clearUser := model.User{
 		Role:   user.Role,
 		Status: user.Status,
@@ -740,6 +876,97 @@ func EmailBind(c *gin.Context) {
 	if user.Role == model.RoleRootUser {
 		config.RootUserEmail = email
 	}
+	if user.TrialPending {
+		if err := model.GrantPendingTrialQuota(c.Request.Context(), id); err != nil {
+			logger.SysError(fmt.Sprintf("failed to grant trial quota to user %d: %s", id, err.Error()))
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+func GetFreeAllowances(c *gin.Context) {
+	id := c.GetInt(ctxkey.Id)
+	userGroup, err := model.CacheGetUserGroup(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.GetFreeAllowanceStatus(id, userGroup),
+	})
+	return
+}
+
+func GetNotificationPreferences(c *gin.Context) {
+	preferences, err := model.GetUserNotificationPreferences(c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    preferences,
+	})
+	return
+}
+
+func UpdateNotificationPreferences(c *gin.Context) {
+	var preferences map[string]bool
+	if err := c.ShouldBindJSON(&preferences); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	if err := model.UpdateUserNotificationPreferences(c.GetInt(ctxkey.Id), preferences); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+type currencyRequest struct {
+	Currency string `json:"currency"`
+}
+
+// UpdateSelfCurrency sets the currency the quota of the user is displayed in
+func UpdateSelfCurrency(c *gin.Context) {
+	var req currencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	if err := model.UpdateUserCurrency(c.GetInt(ctxkey.Id), req.Currency); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -748,7 +975,8 @@ func EmailBind(c *gin.Context) {
 }
 
 type topUpRequest struct {
-	Key string `json:"key"`
+	Key    string `json:"key"`
+	Coupon string `json:"coupon"`
 }
 
 func TopUp(c *gin.Context) {
@@ -763,7 +991,7 @@ func TopUp(c *gin.Context) {
 		return
 	}
 	id := c.GetInt("id")
-	quota, err := model.Redeem(ctx, req.Key, id)
+	quota, err := model.Redeem(ctx, req.Key, req.Coupon, id)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -796,7 +1024,14 @@ func AdminTopUp(c *gin.Context) {
 		})
 		return
 	}
-	err = model.IncreaseUserQuota(req.UserId, int64(req.Quota))
+	if req.Quota < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "quota 不能为负数！",
+		})
+		return
+	}
+	err = model.ChangeUserQuota(req.UserId, int64(req.Quota), model.LedgerTypeTopUp, "通过 API 充值")
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -808,6 +1043,7 @@ func AdminTopUp(c *gin.Context) {
 		req.Remark = fmt.Sprintf("通过 API 充值 %s", common.LogQuota(int64(req.Quota)))
 	}
 	model.RecordTopupLog(ctx, req.UserId, req.Remark, req.Quota)
+	model.RewardInviterForTopUp(ctx, req.UserId, int64(req.Quota))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
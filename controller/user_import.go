@@ -0,0 +1,276 @@
+package controller
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/model"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+)
+
+// the users are provisioned in bulk from the identity system of the organization, keyed by their external id,
+// so that the joiners and the leavers are applied by one call instead of the dashboard
+
+const maxUserImportRecords = 1000
+
+const (
+	UserImportCreated   = "created"
+	UserImportUpdated   = "updated"
+	UserImportDisabled  = "disabled"
+	UserImportUnchanged = "unchanged"
+	UserImportSkipped   = "skipped"
+	UserImportFailed    = "failed"
+)
+
+type userImportRecord struct {
+	ExternalId  string `json:"external_id" validate:"max=64"`
+	Username    string `json:"username" validate:"max=12"`
+	DisplayName string `json:"display_name" validate:"max=20"`
+	Email       string `json:"email" validate:"max=50"`
+	Group       string `json:"group" validate:"max=32"`
+	// Active false disables the user and signs them out, nil keeps the status
+	Active *bool `json:"active"`
+}
+
+type userImportRequest struct {
+	// DryRun reports what would be done, without changing any user
+	DryRun bool                `json:"dry_run"`
+	Users  []*userImportRecord `json:"users"`
+}
+
+type userImportResult struct {
+	ExternalId string `json:"external_id"`
+	Username   string `json:"username"`
+	Status     string `json:"status"`
+	Message    string `json:"message,omitempty"`
+	UserId     int    `json:"user_id,omitempty"`
+}
+
+// parseUserImportCSV reads the records of a CSV file whose first line names the columns
+func parseUserImportCSV(reader io.Reader) ([]*userImportRecord, error) {
+	rows, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("CSV 文件为空")
+	}
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["external_id"]; !ok {
+		return nil, errors.New("CSV 文件缺少 external_id 列")
+	}
+	records := make([]*userImportRecord, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		get := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+		record := &userImportRecord{
+			ExternalId:  get("external_id"),
+			Username:    get("username"),
+			DisplayName: get("display_name"),
+			Email:       get("email"),
+			Group:       get("group"),
+		}
+		if active := get("active"); active != "" {
+			value, err := strconv.ParseBool(active)
+			if err != nil {
+				return nil, fmt.Errorf("用户 %s 的 active 无效：%s", record.ExternalId, active)
+			}
+			record.Active = &value
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func importUser(ctx context.Context, record *userImportRecord, myRole int, dryRun bool) *userImportResult {
+	result := &userImportResult{ExternalId: record.ExternalId, Username: record.Username, Status: UserImportFailed}
+	if record.ExternalId == "" {
+		result.Message = "external_id 不能为空"
+		return result
+	}
+	if err := common.Validate.Struct(record); err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	if record.Group != "" {
+		if _, ok := billingratio.GroupRatio[record.Group]; !ok {
+			result.Message = fmt.Sprintf("分组 %s 不存在", record.Group)
+			return result
+		}
+	}
+	origin, exists, err := findByExternalId(func() (*model.User, error) {
+		return model.GetUserByExternalId(record.ExternalId)
+	})
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	if !exists {
+		return createImportedUser(ctx, record, result, dryRun)
+	}
+	result.Username = origin.Username
+	result.UserId = origin.Id
+	if myRole <= origin.Role && myRole != model.RoleRootUser {
+		result.Message = "无权更新同权限等级或更高权限等级的用户信息"
+		return result
+	}
+	user := model.User{Id: origin.Id}
+	changed := false
+	if record.DisplayName != "" && record.DisplayName != origin.DisplayName {
+		user.DisplayName = record.DisplayName
+		changed = true
+	}
+	if record.Email != "" && record.Email != origin.Email {
+		user.Email = record.Email
+		changed = true
+	}
+	if record.Group != "" && record.Group != origin.Group {
+		user.Group = record.Group
+		changed = true
+	}
+	disabling := false
+	if record.Active != nil {
+		status := model.UserStatusEnabled
+		if !*record.Active {
+			status = model.UserStatusDisabled
+		}
+		if status != origin.Status {
+			if status == model.UserStatusDisabled && origin.Role == model.RoleRootUser {
+				result.Message = "无法禁用超级管理员用户"
+				return result
+			}
+			user.Status = status
+			disabling = status == model.UserStatusDisabled
+			changed = true
+		}
+	}
+	if !changed {
+		result.Status = UserImportUnchanged
+		return result
+	}
+	if !dryRun {
+		if err = user.Update(false); err != nil {
+			result.Message = err.Error()
+			return result
+		}
+		if disabling {
+			if err = model.RevokeUserSessions(origin.Id, ""); err != nil {
+				result.Message = err.Error()
+				return result
+			}
+		}
+	}
+	result.Status = UserImportUpdated
+	if disabling {
+		result.Status = UserImportDisabled
+	}
+	return result
+}
+
+// createImportedUser creates the user with a random password, they sign in with the single sign-on
+// or reset the password by their email
+func createImportedUser(ctx context.Context, record *userImportRecord, result *userImportResult, dryRun bool) *userImportResult {
+	if record.Active != nil && !*record.Active {
+		result.Status = UserImportSkipped
+		result.Message = "用户不存在"
+		return result
+	}
+	if record.Username == "" {
+		result.Message = "创建用户时用户名不能为空"
+		return result
+	}
+	if model.IsUsernameAlreadyTaken(record.Username) {
+		result.Message = "用户名已被占用"
+		return result
+	}
+	if record.Email != "" && model.IsEmailAlreadyTaken(record.Email) {
+		result.Message = "邮箱地址已被占用"
+		return result
+	}
+	if dryRun {
+		result.Status = UserImportCreated
+		return result
+	}
+	user := model.User{
+		Username:    record.Username,
+		Password:    random.GetRandomString(16),
+		DisplayName: record.DisplayName,
+		Email:       record.Email,
+		ExternalId:  record.ExternalId,
+	}
+	if user.DisplayName == "" {
+		user.DisplayName = user.Username
+	}
+	if err := user.Insert(ctx, 0); err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	result.UserId = user.Id
+	if record.Group != "" {
+		if err := model.UpdateUserGroup(user.Id, record.Group); err != nil {
+			result.Message = err.Error()
+			return result
+		}
+	}
+	result.Status = UserImportCreated
+	return result
+}
+
+// ImportUsers creates, updates and disables the users of the records, given as JSON or as a CSV file,
+// each record is applied on its own and has its result
+func ImportUsers(c *gin.Context) {
+	var request userImportRequest
+	var err error
+	if strings.HasPrefix(c.ContentType(), "text/csv") {
+		request.DryRun = c.Query("dry_run") == "true"
+		request.Users, err = parseUserImportCSV(c.Request.Body)
+	} else {
+		err = c.ShouldBindJSON(&request)
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if len(request.Users) > maxUserImportRecords {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("单次最多导入 %d 个用户", maxUserImportRecords),
+		})
+		return
+	}
+	myRole := c.GetInt(ctxkey.Role)
+	results := make([]*userImportResult, 0, len(request.Users))
+	for _, record := range request.Users {
+		if record == nil {
+			continue
+		}
+		results = append(results, importUser(c.Request.Context(), record, myRole, request.DryRun))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    results,
+	})
+}
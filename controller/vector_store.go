@@ -0,0 +1,519 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/storage"
+	"github.com/songquanpeng/one-api/common/vector"
+	"github.com/songquanpeng/one-api/model"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// https://platform.openai.com/docs/api-reference/vector-stores
+// the vector stores are emulated like the assistants, the files are embedded with the token of their owner
+// through the relay routes, so that the embedding model may be served by any channel and is billed as usual
+
+// vectorStoreEmbeddingBatchSize is how many chunks are embedded by one request
+const vectorStoreEmbeddingBatchSize = 64
+
+// fileSearchMaxResults is how many chunks are returned by a search which does not ask for a number
+const fileSearchMaxResults = 10
+
+type vectorStoreRequest struct {
+	Name     *string         `json:"name"`
+	FileIds  []string        `json:"file_ids"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+type vectorStoreFileRequest struct {
+	FileId string `json:"file_id"`
+}
+
+type vectorStoreSearchRequest struct {
+	Query         string `json:"query"`
+	MaxNumResults int    `json:"max_num_results"`
+}
+
+func isVectorStoreEnabled(c *gin.Context) bool {
+	if config.VectorStore == "" {
+		abortWithOpenAIError(c, http.StatusNotImplemented, "未启用向量存储")
+		return false
+	}
+	return true
+}
+
+func vectorStoreObject(store *model.VectorStore) gin.H {
+	counts := store.FileCounts()
+	status := "completed"
+	if counts.InProgress > 0 {
+		status = "in_progress"
+	}
+	return gin.H{
+		"id":          store.VectorStoreId,
+		"object":      "vector_store",
+		"created_at":  store.CreatedAt,
+		"name":        store.Name,
+		"usage_bytes": store.UsageBytes(),
+		"file_counts": gin.H{
+			"in_progress": counts.InProgress,
+			"completed":   counts.Completed,
+			"failed":      counts.Failed,
+			"cancelled":   0,
+			"total":       counts.Total,
+		},
+		"status":         status,
+		"expires_after":  nil,
+		"expires_at":     nil,
+		"last_active_at": nil,
+		"metadata":       rawJSON(store.Metadata, "{}"),
+	}
+}
+
+func vectorStoreFileObject(storeFile *model.VectorStoreFile) gin.H {
+	var lastError any
+	if storeFile.LastError != "" {
+		lastError = gin.H{
+			"code":    "server_error",
+			"message": storeFile.LastError,
+		}
+	}
+	return gin.H{
+		"id":              storeFile.FileId,
+		"object":          "vector_store.file",
+		"usage_bytes":     storeFile.UsageBytes,
+		"created_at":      storeFile.CreatedAt,
+		"vector_store_id": storeFile.VectorStoreId,
+		"status":          storeFile.Status,
+		"last_error":      lastError,
+	}
+}
+
+func getUserVectorStore(c *gin.Context) (*model.VectorStore, bool) {
+	if !isVectorStoreEnabled(c) {
+		return nil, false
+	}
+	store, err := model.GetUserVectorStore(c.GetInt(ctxkey.Id), c.Param("id"))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusNotFound, fmt.Sprintf("vector store %s 不存在", c.Param("id")))
+		return nil, false
+	}
+	return store, true
+}
+
+func CreateVectorStore(c *gin.Context) {
+	if !isVectorStoreEnabled(c) {
+		return
+	}
+	var request vectorStoreRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	userId := c.GetInt(ctxkey.Id)
+	var files []*model.File
+	for _, fileId := range request.FileIds {
+		file, err := model.GetUserFile(userId, fileId)
+		if err != nil {
+			abortWithOpenAIError(c, http.StatusNotFound, fmt.Sprintf("文件 %s 不存在", fileId))
+			return
+		}
+		files = append(files, file)
+	}
+	store := &model.VectorStore{UserId: userId}
+	if request.Name != nil {
+		store.Name = *request.Name
+	}
+	var err error
+	if store.Metadata, err = normalizeMetadata(request.Metadata, ""); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err = store.Insert(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, file := range files {
+		if _, err = addVectorStoreFile(c, store, file); err != nil {
+			abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	c.JSON(http.StatusOK, vectorStoreObject(store))
+}
+
+func ListVectorStores(c *gin.Context) {
+	if !isVectorStoreEnabled(c) {
+		return
+	}
+	params := getListParams(c)
+	stores, err := model.GetUserVectorStores(c.GetInt(ctxkey.Id), params)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	data := make([]gin.H, 0, len(stores))
+	for _, store := range stores {
+		data = append(data, vectorStoreObject(store))
+	}
+	listResponse(c, data, params.Limit)
+}
+
+func RetrieveVectorStore(c *gin.Context) {
+	store, ok := getUserVectorStore(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, vectorStoreObject(store))
+}
+
+func ModifyVectorStore(c *gin.Context) {
+	store, ok := getUserVectorStore(c)
+	if !ok {
+		return
+	}
+	var request vectorStoreRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	if request.Name != nil {
+		store.Name = *request.Name
+	}
+	var err error
+	if store.Metadata, err = normalizeMetadata(request.Metadata, store.Metadata); err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err = store.Update(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, vectorStoreObject(store))
+}
+
+func DeleteVectorStore(c *gin.Context) {
+	store, ok := getUserVectorStore(c)
+	if !ok {
+		return
+	}
+	if err := store.Delete(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      store.VectorStoreId,
+		"object":  "vector_store.deleted",
+		"deleted": true,
+	})
+}
+
+// addVectorStoreFile adds the file to the store and ingests it in the background, a file added before is replaced
+func addVectorStoreFile(c *gin.Context, store *model.VectorStore, file *model.File) (*model.VectorStoreFile, error) {
+	if old, err := model.GetVectorStoreFile(store.VectorStoreId, file.FileId); err == nil {
+		if old.Status == model.VectorStoreFileStatusInProgress {
+			return nil, fmt.Errorf("文件 %s 正在处理中", file.FileId)
+		}
+		if err = old.Delete(); err != nil {
+			return nil, err
+		}
+	}
+	storeFile := &model.VectorStoreFile{
+		VectorStoreId: store.VectorStoreId,
+		FileId:        file.FileId,
+		UserId:        store.UserId,
+	}
+	if err := storeFile.Insert(); err != nil {
+		return nil, err
+	}
+	go ingestVectorStoreFile(c.GetInt(ctxkey.TokenId), *storeFile, *file)
+	return storeFile, nil
+}
+
+func getVectorStoreFile(c *gin.Context) (*model.VectorStoreFile, bool) {
+	store, ok := getUserVectorStore(c)
+	if !ok {
+		return nil, false
+	}
+	storeFile, err := model.GetVectorStoreFile(store.VectorStoreId, c.Param("fileId"))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusNotFound, fmt.Sprintf("文件 %s 不在 vector store %s 中", c.Param("fileId"), store.VectorStoreId))
+		return nil, false
+	}
+	return storeFile, true
+}
+
+func CreateVectorStoreFile(c *gin.Context) {
+	store, ok := getUserVectorStore(c)
+	if !ok {
+		return
+	}
+	var request vectorStoreFileRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	file, err := model.GetUserFile(store.UserId, request.FileId)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusNotFound, fmt.Sprintf("文件 %s 不存在", request.FileId))
+		return
+	}
+	storeFile, err := addVectorStoreFile(c, store, file)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, vectorStoreFileObject(storeFile))
+}
+
+func ListVectorStoreFiles(c *gin.Context) {
+	store, ok := getUserVectorStore(c)
+	if !ok {
+		return
+	}
+	params := getListParams(c)
+	storeFiles, err := model.GetVectorStoreFiles(store.VectorStoreId, c.Query("filter"), params)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	data := make([]gin.H, 0, len(storeFiles))
+	for _, storeFile := range storeFiles {
+		data = append(data, vectorStoreFileObject(storeFile))
+	}
+	listResponse(c, data, params.Limit)
+}
+
+func RetrieveVectorStoreFile(c *gin.Context) {
+	storeFile, ok := getVectorStoreFile(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, vectorStoreFileObject(storeFile))
+}
+
+func DeleteVectorStoreFile(c *gin.Context) {
+	storeFile, ok := getVectorStoreFile(c)
+	if !ok {
+		return
+	}
+	if err := storeFile.Delete(); err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      storeFile.FileId,
+		"object":  "vector_store.file.deleted",
+		"deleted": true,
+	})
+}
+
+func SearchVectorStore(c *gin.Context) {
+	store, ok := getUserVectorStore(c)
+	if !ok {
+		return
+	}
+	var request vectorStoreSearchRequest
+	if !bindAssistantsRequest(c, &request) {
+		return
+	}
+	if strings.TrimSpace(request.Query) == "" {
+		abortWithOpenAIError(c, http.StatusBadRequest, "query 不能为空")
+		return
+	}
+	token, err := model.GetTokenById(c.GetInt(ctxkey.TokenId))
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusUnauthorized, "令牌不存在")
+		return
+	}
+	results, err := searchVectorStores(token.Key, store.UserId, []string{store.VectorStoreId}, request.Query, request.MaxNumResults)
+	if err != nil {
+		abortWithOpenAIError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	data := make([]gin.H, 0, len(results))
+	for _, result := range results {
+		data = append(data, gin.H{
+			"file_id":    result.FileId,
+			"filename":   result.Filename,
+			"score":      result.Score,
+			"attributes": gin.H{},
+			"content": []gin.H{{
+				"type": "text",
+				"text": result.Content,
+			}},
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":       "vector_store.search_results.page",
+		"search_query": request.Query,
+		"data":         data,
+		"has_more":     false,
+		"next_page":    nil,
+	})
+}
+
+type fileSearchResult struct {
+	FileId   string  `json:"file_id"`
+	Filename string  `json:"filename"`
+	Score    float64 `json:"score"`
+	Content  string  `json:"content"`
+}
+
+// searchVectorStores returns the chunks of the stores closest to the query, the query is embedded with the token key
+func searchVectorStores(key string, userId int, vectorStoreIds []string, query string, limit int) ([]fileSearchResult, error) {
+	if limit <= 0 || limit > 50 {
+		limit = fileSearchMaxResults
+	}
+	embeddings, err := embedTexts(key, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := model.SearchVectorChunks(vectorStoreIds, embeddings[0], limit)
+	if err != nil {
+		return nil, err
+	}
+	filenames := make(map[string]string)
+	results := make([]fileSearchResult, 0, len(chunks))
+	for _, chunk := range chunks {
+		filename, ok := filenames[chunk.FileId]
+		if !ok {
+			if file, err := model.GetUserFile(userId, chunk.FileId); err == nil {
+				filename = file.Filename
+			}
+			filenames[chunk.FileId] = filename
+		}
+		results = append(results, fileSearchResult{
+			FileId:   chunk.FileId,
+			Filename: filename,
+			Score:    chunk.Score,
+			Content:  chunk.Content,
+		})
+	}
+	return results, nil
+}
+
+// embedTexts embeds the texts with the embedding model of the vector stores, in batches
+func embedTexts(key string, texts []string) ([][]float64, error) {
+	embeddings := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += vectorStoreEmbeddingBatchSize {
+		end := start + vectorStoreEmbeddingBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		response, err := relayEmbeddings(key, &relaymodel.GeneralOpenAIRequest{
+			Model: config.VectorStoreEmbeddingModel,
+			Input: texts[start:end],
+		})
+		if err != nil {
+			return nil, err
+		}
+		batch := make([][]float64, end-start)
+		for _, item := range response.Data {
+			if item.Index >= 0 && item.Index < len(batch) {
+				batch[item.Index] = item.Embedding
+			}
+		}
+		for _, embedding := range batch {
+			if len(embedding) == 0 {
+				return nil, errors.New("嵌入模型返回的结果不完整")
+			}
+		}
+		embeddings = append(embeddings, batch...)
+	}
+	return embeddings, nil
+}
+
+// readFileContent reads the content of the file from the storage, or from the upstream of its channel
+func readFileContent(ctx context.Context, file *model.File) ([]byte, error) {
+	var content io.ReadCloser
+	if file.Storage != "" {
+		var err error
+		if content, err = storage.GetFileBackend(file.Storage).Get(ctx, file.FileId); err != nil {
+			return nil, err
+		}
+	} else {
+		channel, err := model.GetChannelById(file.ChannelId, true)
+		if err != nil {
+			return nil, fmt.Errorf("文件所在的渠道 #%d 不存在", file.ChannelId)
+		}
+		upstreamFileId := file.UpstreamFileId
+		if upstreamFileId == "" {
+			upstreamFileId = file.FileId
+		}
+		resp, err := doFineTuningRequest(ctx, channel, http.MethodGet, "/v1/files/"+upstreamFileId+"/content", nil, "")
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("读取上游文件失败，状态码 %d", resp.StatusCode)
+		}
+		content = resp.Body
+	}
+	defer content.Close()
+	return io.ReadAll(io.LimitReader(content, config.FileMaxSize*1024*1024))
+}
+
+func embedFile(ctx context.Context, key string, storeFile *model.VectorStoreFile, file *model.File) ([]*model.VectorChunk, error) {
+	data, err := readFileContent(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(data) {
+		return nil, errors.New("仅支持 UTF-8 编码的文本文件")
+	}
+	texts := vector.Chunk(string(data), config.VectorStoreChunkSize, config.VectorStoreChunkOverlap)
+	if len(texts) == 0 {
+		return nil, errors.New("文件内容为空")
+	}
+	embeddings, err := embedTexts(key, texts)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([]*model.VectorChunk, 0, len(texts))
+	for i, text := range texts {
+		chunks = append(chunks, &model.VectorChunk{
+			VectorStoreId: storeFile.VectorStoreId,
+			FileId:        storeFile.FileId,
+			Content:       text,
+			Vector:        embeddings[i],
+		})
+	}
+	storeFile.UsageBytes = int64(len(data))
+	return chunks, nil
+}
+
+// ingestVectorStoreFile chunks and embeds the file with the token which added it, then records the result
+func ingestVectorStoreFile(tokenId int, storeFile model.VectorStoreFile, file model.File) {
+	ctx := context.Background()
+	token, err := model.GetTokenById(tokenId)
+	var chunks []*model.VectorChunk
+	if err == nil {
+		chunks, err = embedFile(ctx, token.Key, &storeFile, &file)
+	}
+	if err == nil {
+		err = model.InsertVectorChunks(chunks)
+	}
+	storeFile.Status = model.VectorStoreFileStatusCompleted
+	storeFile.Chunks = len(chunks)
+	if err != nil {
+		logger.Warnf(ctx, "failed to ingest file %s into vector store %s: %s", file.FileId, storeFile.VectorStoreId, err.Error())
+		storeFile.Status = model.VectorStoreFileStatusFailed
+		storeFile.LastError = err.Error()
+		storeFile.UsageBytes = 0
+		storeFile.Chunks = 0
+		_ = model.GetVectorBackend().DeleteFile(storeFile.VectorStoreId, storeFile.FileId)
+	}
+	if storeFile.Finish() != nil && err == nil {
+		// the file has been removed from the store in the meantime
+		_ = model.GetVectorBackend().DeleteFile(storeFile.VectorStoreId, storeFile.FileId)
+	}
+}
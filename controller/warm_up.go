@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// wakeRetryInterval is how often the wake url is requested again until the backend answers
+const wakeRetryInterval = 2 * time.Second
+
+// lastChannelUses is when this node last sent a request to the channels with warm-up settings
+var lastChannelUsesLock sync.Mutex
+var lastChannelUses = make(map[int]time.Time)
+
+// channelWakeLocks make the requests to a cold channel wait for the same wake call
+var channelWakeLocks sync.Map
+
+// useChannel records the use of the channel and returns how long it had been idle on this node
+func useChannel(id int) time.Duration {
+	lastChannelUsesLock.Lock()
+	defer lastChannelUsesLock.Unlock()
+	now := time.Now()
+	idle := now.Sub(lastChannelUses[id])
+	lastChannelUses[id] = now
+	return idle
+}
+
+func getChannelIdleTime(id int) time.Duration {
+	lastChannelUsesLock.Lock()
+	defer lastChannelUsesLock.Unlock()
+	return time.Since(lastChannelUses[id])
+}
+
+// wakeBackend requests the wake url until it answers 2xx or the wait passes
+func wakeBackend(ctx context.Context, wakeURL string, wait time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, wakeURL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err == nil {
+			_ = resp.Body.Close()
+			if resp.StatusCode/100 == 2 {
+				return nil
+			}
+			err = fmt.Errorf("status code %d", resp.StatusCode)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wakeRetryInterval):
+		}
+	}
+}
+
+// wakeChannel calls the wake url of the channel selected for the request if the channel has been idle, so that the
+// request does not hit the cold start of the backend, a request is sent anyway if the backend does not answer in time
+func wakeChannel(c *gin.Context) {
+	value, _ := c.Get(ctxkey.Config)
+	cfg, ok := value.(model.ChannelConfig)
+	if !ok || cfg.WarmUp == nil {
+		return
+	}
+	id := c.GetInt(ctxkey.ChannelId)
+	lock, _ := channelWakeLocks.LoadOrStore(id, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+	idle := useChannel(id)
+	if cfg.WarmUp.WakeURL == "" || idle < time.Duration(cfg.WarmUp.GetIdleMinutes())*time.Minute {
+		return
+	}
+	ctx := c.Request.Context()
+	start := time.Now()
+	if err := wakeBackend(ctx, cfg.WarmUp.WakeURL, time.Duration(cfg.WarmUp.GetWait())*time.Second); err != nil {
+		logger.Warnf(ctx, "failed to wake channel #%d up: %s", id, err.Error())
+		return
+	}
+	logger.Infof(ctx, "channel #%d woke up in %s", id, time.Since(start).Round(time.Millisecond))
+}
+
+func warmUpChannel(ctx context.Context, channel *model.Channel, warmUp *model.WarmUp) {
+	var err error
+	if warmUp.WakeURL != "" {
+		err = wakeBackend(ctx, warmUp.WakeURL, time.Duration(warmUp.GetWait())*time.Second)
+	} else {
+		_, err, _ = testChannel(ctx, channel, buildTestRequest(""))
+	}
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to warm channel #%d up: %s", channel.Id, err.Error()))
+	}
+}
+
+// warmUpChannels sends the warm-up requests of the channels idle for their interval
+func warmUpChannels(ctx context.Context, lastWarmUps map[int]time.Time) {
+	channels, err := model.GetWarmUpChannels()
+	if err != nil {
+		logger.SysError("failed to load warm-up channels: " + err.Error())
+		return
+	}
+	for _, channel := range channels {
+		cfg, err := channel.LoadConfig()
+		if err != nil || cfg.WarmUp == nil || cfg.WarmUp.Interval <= 0 || model.IsChannelInMaintenance(channel.Id) {
+			continue
+		}
+		interval := time.Duration(cfg.WarmUp.Interval) * time.Minute
+		if time.Since(lastWarmUps[channel.Id]) < interval || getChannelIdleTime(channel.Id) < interval {
+			continue
+		}
+		lastWarmUps[channel.Id] = time.Now()
+		go warmUpChannel(ctx, channel, cfg.WarmUp)
+	}
+}
+
+// AutomaticallyWarmUpChannels checks the warm-up intervals of the channels every minute on the leader node
+func AutomaticallyWarmUpChannels() {
+	ctx := context.Background()
+	lastWarmUps := make(map[int]time.Time)
+	for {
+		time.Sleep(time.Minute)
+		if model.IsLeader() {
+			warmUpChannels(ctx, lastWarmUps)
+		}
+	}
+}
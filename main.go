@@ -1,26 +1,40 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
+	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 	_ "github.com/joho/godotenv/autoload"
 
+	"github.com/songquanpeng/one-api/cmd"
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/client"
 	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/env"
 	"github.com/songquanpeng/one-api/common/i18n"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/network"
 	"github.com/songquanpeng/one-api/controller"
 	"github.com/songquanpeng/one-api/middleware"
 	"github.com/songquanpeng/one-api/model"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/billing"
+	"github.com/songquanpeng/one-api/relay/plugin"
 	"github.com/songquanpeng/one-api/router"
+	"github.com/songquanpeng/one-api/rpc"
 )
 
 //go:embed web/build/*
@@ -29,7 +43,19 @@ var buildFS embed.FS
 func main() {
 	common.Init()
 	logger.SetupLogger()
+	if cmd.Run(flag.Args()) {
+		return
+	}
 	logger.SysLogf("One API %s started", common.Version)
+	if env.ConfigFile() != "" {
+		logger.SysLogf("using config file %s", env.ConfigFile())
+		for _, key := range config.UnknownFileKeys() {
+			logger.SysLogf("unknown key %s in config file, ignored", key)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		logger.FatalLog("invalid configuration: " + err.Error())
+	}
 
 	if os.Getenv("GIN_MODE") != gin.DebugMode {
 		gin.SetMode(gin.ReleaseMode)
@@ -72,10 +98,41 @@ func main() {
 		logger.SysLog(fmt.Sprintf("sync frequency: %d seconds", config.SyncFrequency))
 		model.InitChannelCache()
 	}
+	if config.StartupCheckPolicy != "off" {
+		problems := model.RunStartupCheck()
+		for _, problem := range problems {
+			logger.SysErrorf("startup check failed on %s: %s", problem.Check, problem.Message)
+		}
+		if len(problems) > 0 && config.StartupCheckPolicy == "fail" {
+			logger.FatalLog("refusing to serve as the startup check failed, set STARTUP_CHECK_POLICY=degraded to serve anyway")
+		}
+		if len(problems) > 0 {
+			logger.SysLog("serving degraded as the startup check failed")
+		}
+	}
+	model.InitExperimentCache()
+	go model.SyncExperimentCache(config.SyncFrequency)
 	if config.MemoryCacheEnabled {
 		go model.SyncOptions(config.SyncFrequency)
 		go model.SyncChannelCache(config.SyncFrequency)
 	}
+	if common.RedisEnabled {
+		// reload caches as soon as another node changes channels or options, and share the in-flight requests
+		go model.SubscribeCacheInvalidation()
+		go controller.SubscribeInFlightCancels()
+		go controller.SyncInFlightRequests()
+	}
+	if config.LeaderElectionEnabled {
+		logger.SysLog("leader election enabled, scheduled jobs will only run on the leader node")
+		go model.RunLeaderElection()
+	}
+	if os.Getenv("CHANNEL_UPDATE_FREQUENCY") != "" {
+		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_UPDATE_FREQUENCY"))
+		if err != nil {
+			logger.FatalLog("failed to parse CHANNEL_UPDATE_FREQUENCY: " + err.Error())
+		}
+		go controller.AutomaticallyUpdateChannels(frequency)
+	}
 	if os.Getenv("CHANNEL_TEST_FREQUENCY") != "" {
 		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_TEST_FREQUENCY"))
 		if err != nil {
@@ -83,6 +140,49 @@ func main() {
 		}
 		go controller.AutomaticallyTestChannels(frequency)
 	}
+	if os.Getenv("CHANNEL_SCAN_FREQUENCY") != "" {
+		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_SCAN_FREQUENCY"))
+		if err != nil {
+			logger.FatalLog("failed to parse CHANNEL_SCAN_FREQUENCY: " + err.Error())
+		}
+		go controller.AutomaticallyScanChannelKeys(frequency)
+	}
+	if config.LogRetentionDays > 0 {
+		logger.SysLogf("log retention enabled, logs older than %d days will be deleted", config.LogRetentionDays)
+		go model.AutomaticallyDeleteOldLogs(config.LogRetentionDays)
+	}
+	if config.BackupFrequency > 0 {
+		logger.SysLogf("backups enabled, a backup will be created every %d minutes", config.BackupFrequency)
+		go model.AutomaticallyBackup(config.BackupFrequency)
+	}
+	if config.TrashRetentionDays > 0 {
+		go model.AutomaticallyPurgeTrash(config.TrashRetentionDays)
+	}
+	go model.AutomaticallyDeleteOldFreeUsages()
+	go model.AutomaticallyDeleteOldChannelChecks()
+	go model.SyncChannelMaintenance()
+	go model.SyncChannelSpendCaps()
+	go controller.AutomaticallyWarmUpChannels()
+	go controller.AutomaticallyUpdateFineTuningJobs()
+	go controller.AutomaticallyDeleteExpiredFiles()
+	go model.AutomaticallySendNotifications()
+	go model.AutomaticallyDetectTokenAnomalies()
+	go model.AutomaticallyResetPlanQuotas()
+	go model.SyncUsageCoupons()
+	go model.SyncChannelKeyUsages()
+	if config.ExchangeRateSyncFrequency > 0 {
+		go model.AutomaticallySyncExchangeRates(config.ExchangeRateSyncFrequency)
+	}
+	go controller.AutomaticallyDeliverTranscripts()
+	if config.AsyncTaskConcurrency > 0 {
+		go controller.AutomaticallyRunAsyncTasks()
+		go controller.AutomaticallyDeliverAsyncCallbacks()
+		go model.AutomaticallyDeleteOldAsyncTasks()
+	}
+	if config.ReconcileDir != "" {
+		logger.SysLogf("reconciling channels and tokens from %s every %d seconds", config.ReconcileDir, config.ReconcileFrequency)
+		go model.AutomaticallyReconcile(config.ReconcileDir, config.ReconcilePrune, config.ReconcileFrequency)
+	}
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		config.BatchUpdateEnabled = true
 		logger.SysLog("batch update enabled with interval " + strconv.Itoa(config.BatchUpdateInterval) + "s")
@@ -93,6 +193,7 @@ func main() {
 	}
 	openai.InitTokenEncoders()
 	client.Init()
+	plugin.Init()
 
 	// Initialize i18n
 	if err := i18n.Init(); err != nil {
@@ -100,6 +201,95 @@ func main() {
 	}
 
 	// Initialize HTTP server
+	server := newEngine()
+	router.SetRouter(server, buildFS)
+	controller.BotRelayHandler = server
+	publicServer := server
+	if !config.PublicAdminAPIEnabled {
+		// the routes of the management API and the console do not exist on the public listener at all
+		publicServer = newEngine()
+		router.SetPublicRouter(publicServer)
+	}
+	address := config.ListenAddress
+	if address == "" {
+		var port = os.Getenv("PORT")
+		if port == "" {
+			port = strconv.Itoa(*common.Port)
+		}
+		address = ":" + port
+	}
+	srv := &http.Server{
+		Addr:    address,
+		Handler: router.WithBasePath(publicServer),
+	}
+	servers := []*http.Server{srv}
+	if config.ACMEDomains != "" {
+		manager := network.ACMEManager(config.ACMEDomains, config.ACMEEmail, config.ACMECachePath, config.ACMEDirectoryURL)
+		// TLS-ALPN-01 challenges are answered on the public listener itself, which works when it is on port 443
+		srv.TLSConfig = manager.TLSConfig()
+		if config.ACMEHTTPListen != "" {
+			challengeSrv := &http.Server{
+				Addr:    config.ACMEHTTPListen,
+				Handler: manager.HTTPHandler(nil),
+			}
+			serve("ACME challenge server", challengeSrv, "", "")
+			servers = append(servers, challengeSrv)
+		}
+	}
+	serve("server", srv, config.TLSCertFile, config.TLSKeyFile)
+	if config.AdminListen != "" {
+		// the internal listener serves everything, the management API and the profiles included
+		adminServer := server
+		if config.PublicAdminAPIEnabled {
+			// the public listener shares the engine otherwise, which must not serve the profiles
+			adminServer = newEngine()
+			router.SetRouter(adminServer, buildFS)
+		}
+		router.SetPprofRouter(adminServer)
+		adminSrv := &http.Server{
+			Addr:    config.AdminListen,
+			Handler: router.WithBasePath(adminServer),
+		}
+		if config.AdminTLSClientCAFile != "" {
+			tlsConfig, err := network.ClientCertTLSConfig(config.AdminTLSClientCAFile)
+			if err != nil {
+				logger.FatalLog("failed to load ADMIN_TLS_CLIENT_CA_FILE: " + err.Error())
+			}
+			adminSrv.TLSConfig = tlsConfig
+		}
+		serve("admin server", adminSrv, config.AdminTLSCertFile, config.AdminTLSKeyFile)
+		servers = append(servers, adminSrv)
+	}
+
+	if config.GRPCPort != "" {
+		go rpc.Start()
+	}
+	go env.WatchFile(5*time.Second, func(err error) {
+		if err != nil {
+			logger.SysError("failed to reload config file: " + err.Error())
+			return
+		}
+		logger.SysLog("config file changed, reloading configuration")
+		reload()
+	})
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range quit {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		logger.SysLog("received SIGHUP, reloading configuration")
+		if env.ConfigFile() != "" {
+			if err := env.LoadFile(env.ConfigFile()); err != nil {
+				logger.SysError("failed to reload config file: " + err.Error())
+			}
+		}
+		reload()
+	}
+	shutdown(servers...)
+}
+
+func newEngine() *gin.Engine {
 	server := gin.New()
 	server.Use(gin.Recovery())
 	// This will cause SSE not to work!!!
@@ -107,20 +297,78 @@ func main() {
 	server.Use(middleware.RequestId())
 	server.Use(middleware.Language())
 	middleware.SetUpLogger(server)
-	// gpt-4o/gpt-5 参数清洗
-	server.Use(middleware.ConstrainedModelSanitizer())
 	// Initialize session store
 	store := cookie.NewStore([]byte(config.SessionSecret))
+	if config.BasePath != "" {
+		// the console under the prefix does not share its session with the other sites of the domain
+		store.Options(sessions.Options{Path: config.BasePath, MaxAge: 86400 * 30})
+	}
 	server.Use(sessions.Sessions("session", store))
+	return server
+}
 
-	router.SetRouter(server, buildFS)
-	var port = os.Getenv("PORT")
-	if port == "" {
-		port = strconv.Itoa(*common.Port)
-	}
-	logger.SysLogf("server started on http://localhost:%s", port)
-	err = server.Run(":" + port)
+// serve starts the server in the background, over TLS if the certificate is given, or if the TLS config
+// provides the certificates itself
+func serve(name string, srv *http.Server, certFile string, keyFile string) {
+	mode, _ := strconv.ParseUint(config.UnixSocketMode, 8, 32)
+	listener, err := network.Listen(srv.Addr, os.FileMode(mode))
 	if err != nil {
-		logger.FatalLog("failed to start HTTP server: " + err.Error())
+		logger.FatalLog(fmt.Sprintf("failed to listen on %s for %s: %s", srv.Addr, name, err.Error()))
+	}
+	go func() {
+		var err error
+		if certFile != "" || (srv.TLSConfig != nil && srv.TLSConfig.GetCertificate != nil) {
+			logger.SysLogf("%s started on https://%s", name, srv.Addr)
+			err = srv.ServeTLS(listener, certFile, keyFile)
+		} else {
+			logger.SysLogf("%s started on http://%s", name, srv.Addr)
+			err = srv.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.FatalLog(fmt.Sprintf("failed to start %s: %s", name, err.Error()))
+		}
+	}()
+}
+
+// reload applies the changed configuration without dropping any connection
+func reload() {
+	config.Reload()
+	if err := config.Validate(); err != nil {
+		logger.SysError("invalid configuration: " + err.Error())
+	}
+	client.Init()
+	model.ReloadOptions()
+	if config.MemoryCacheEnabled {
+		model.InitChannelCache()
+	}
+	logger.SysLog("configuration reloaded")
+}
+
+// shutdown stops accepting new requests, waits for the in-flight requests (including streams)
+// until SHUTDOWN_TIMEOUT is reached, then writes out everything still buffered in memory
+func shutdown(servers ...*http.Server) {
+	logger.SysLogf("shutting down, waiting up to %d seconds for in-flight requests", config.ShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ShutdownTimeout)*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				logger.SysError("failed to shutdown HTTP server gracefully: " + err.Error())
+			}
+		}(srv)
+	}
+	wg.Wait()
+	rpc.Stop()
+	if err := billing.Drain(ctx); err != nil {
+		logger.SysError("failed to wait for billing tasks: " + err.Error())
+	}
+	if config.BatchUpdateEnabled {
+		logger.SysLog("flushing pending batch updates before exit")
+		model.FlushBatchUpdates()
 	}
+	model.FlushChannelKeyUsages()
+	logger.SysLog("server exited")
 }
@@ -1,23 +1,34 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common/blacklist"
+	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/network"
 	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/defaults"
 	"net/http"
 	"strings"
 )
 
+var twoFactorSetupPaths = map[string]bool{
+	"/api/user/self":       true,
+	"/api/user/2fa":        true,
+	"/api/user/2fa/setup":  true,
+	"/api/user/2fa/enable": true,
+}
+
 func authHelper(c *gin.Context, minRole int) {
 	session := sessions.Default(c)
 	username := session.Get("username")
 	role := session.Get("role")
 	id := session.Get("id")
 	status := session.Get("status")
+	fromSession := username != nil
 	if username == nil {
 		// Check access token
 		accessToken := c.Request.Header.Get("Authorization")
@@ -45,6 +56,22 @@ func authHelper(c *gin.Context, minRole int) {
 			return
 		}
 	}
+	if fromSession {
+		// the session is revoked on logout, password change or role downgrade, and the cookies issued before
+		// the sessions were recorded carry no key
+		key, _ := session.Get("session_key").(string)
+		if _, err := model.GetActiveSession(key, id.(int)); err != nil {
+			session.Clear()
+			_ = session.Save()
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "登录已失效，请重新登录",
+			})
+			c.Abort()
+			return
+		}
+		c.Set(ctxkey.SessionKey, key)
+	}
 	if status.(int) == model.UserStatusDisabled || blacklist.IsUserBanned(id.(int)) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -56,6 +83,15 @@ func authHelper(c *gin.Context, minRole int) {
 		c.Abort()
 		return
 	}
+	// the users the two-factor authentication is enforced for can only enable it after signing in without it
+	if fromSession && session.Get("two_factor_setup_required") != nil && !twoFactorSetupPaths[c.FullPath()] {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "请先在个人设置中启用两步验证",
+		})
+		c.Abort()
+		return
+	}
 	if role.(int) < minRole {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -76,6 +112,13 @@ func UserAuth() func(c *gin.Context) {
 	}
 }
 
+// ResellerAuth lets the resellers manage their own users
+func ResellerAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		authHelper(c, model.RoleResellerUser)
+	}
+}
+
 func AdminAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		authHelper(c, model.RoleAdminUser)
@@ -88,6 +131,17 @@ func RootAuth() func(c *gin.Context) {
 	}
 }
 
+// StatusPageAuth lets everyone see the status page when it is enabled, otherwise only the admins
+func StatusPageAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if config.StatusPageEnabled {
+			c.Next()
+			return
+		}
+		authHelper(c, model.RoleAdminUser)
+	}
+}
+
 func TokenAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
@@ -97,7 +151,10 @@ func TokenAuth() func(c *gin.Context) {
 		parts := strings.Split(key, "-")
 		key = parts[0]
 		token, err := model.ValidateUserToken(key)
-		if err != nil {
+		quotaFallback := false
+		if errors.Is(err, model.ErrTokenExhausted) && config.QuotaFallbackModel != "" && isQuotaFallbackPath(c) {
+			quotaFallback = true
+		} else if err != nil {
 			abortWithMessage(c, http.StatusUnauthorized, err.Error())
 			return
 		}
@@ -107,6 +164,10 @@ func TokenAuth() func(c *gin.Context) {
 				return
 			}
 		}
+		if !checkTokenOrigin(c, token.AllowedOrigins) {
+			abortWithMessage(c, http.StatusForbidden, fmt.Sprintf("该令牌不允许来自 %s 的请求", c.Request.Header.Get("Origin")))
+			return
+		}
 		userEnabled, err := model.CacheIsUserEnabled(token.UserId)
 		if err != nil {
 			abortWithMessage(c, http.StatusInternalServerError, err.Error())
@@ -121,10 +182,17 @@ func TokenAuth() func(c *gin.Context) {
 			abortWithMessage(c, http.StatusBadRequest, err.Error())
 			return
 		}
+		if quotaFallback {
+			if err = useQuotaFallbackModel(c); err != nil {
+				abortWithMessage(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			requestModel = config.QuotaFallbackModel
+		}
 		c.Set(ctxkey.RequestModel, requestModel)
 		if token.Models != nil && *token.Models != "" {
 			c.Set(ctxkey.AvailableModels, *token.Models)
-			if requestModel != "" && !isModelInList(requestModel, *token.Models) {
+			if requestModel != "" && !quotaFallback && !isModelInList(requestModel, *token.Models) {
 				abortWithMessage(c, http.StatusForbidden, fmt.Sprintf("该令牌无权使用模型：%s", requestModel))
 				return
 			}
@@ -132,15 +200,48 @@ func TokenAuth() func(c *gin.Context) {
 		c.Set(ctxkey.Id, token.UserId)
 		c.Set(ctxkey.TokenId, token.Id)
 		c.Set(ctxkey.TokenName, token.Name)
+		c.Set(ctxkey.TokenMaxConcurrency, token.MaxConcurrency)
+		if !token.UnlimitedQuota {
+			c.Set(ctxkey.TokenQuota, token.RemainQuota)
+		}
+		if token.Sandbox {
+			c.Set(ctxkey.Sandbox, true)
+		}
+		if token.OpenAIOrganization != "" {
+			c.Set(ctxkey.TokenOpenAIOrg, token.OpenAIOrganization)
+		}
+		if token.OpenAIProject != "" {
+			c.Set(ctxkey.TokenOpenAIProject, token.OpenAIProject)
+		}
+		if token.TranscriptWebhook != "" {
+			c.Set(ctxkey.TranscriptWebhook, token.TranscriptWebhook)
+		}
+		if token.Defaults != "" {
+			if d, err := defaults.Parse(token.Defaults); err == nil {
+				c.Set(ctxkey.TokenDefaults, d)
+			}
+		}
+		specificChannelId := c.Request.Header.Get("X-OneAPI-Channel-Id")
 		if len(parts) > 1 {
+			specificChannelId = parts[1]
+		}
+		if specificChannelId != "" {
 			if model.IsAdmin(token.UserId) {
-				c.Set(ctxkey.SpecificChannelId, parts[1])
+				c.Set(ctxkey.SpecificChannelId, specificChannelId)
 			} else {
 				abortWithMessage(c, http.StatusForbidden, "普通用户不支持指定渠道")
 				return
 			}
 		}
 
+		if c.Request.Header.Get("X-OneAPI-Dry-Run") == "true" {
+			if !config.DryRunEnabled && !model.IsAdmin(token.UserId) {
+				abortWithMessage(c, http.StatusForbidden, "普通用户不支持预览请求")
+				return
+			}
+			c.Set(ctxkey.DryRun, true)
+		}
+
 		// set channel id for proxy relay
 		if channelId := c.Param("channelid"); channelId != "" {
 			c.Set(ctxkey.SpecificChannelId, channelId)
@@ -151,12 +252,20 @@ func TokenAuth() func(c *gin.Context) {
 }
 
 func shouldCheckModel(c *gin.Context) bool {
+	// the gets such as the polls of the deferred completions have no body
+	if c.Request.Method == http.MethodGet {
+		return false
+	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/completions") {
 		return true
 	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/chat/completions") {
 		return true
 	}
+	// the templates are rendered after the authentication, so that the names of the templates are not disclosed
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/templates/chat/completions") {
+		return true
+	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/images") {
 		return true
 	}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/relay/chaos"
+)
+
+// Chaos injects the faults configured for the token into its requests: the latency and the errors before
+// the request is relayed, the truncated streams and the malformed events while the response is streamed
+func Chaos() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		fault := chaos.GetFault(c.GetInt(ctxkey.TokenId))
+		if fault == nil {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		if latency := fault.Latency(); latency > 0 {
+			timer := time.NewTimer(latency)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				c.Abort()
+				return
+			}
+		}
+		if chaos.Chance(fault.ErrorRate) {
+			status := fault.Status()
+			if status == http.StatusTooManyRequests {
+				c.Header("Retry-After", "1")
+			}
+			abortWithMessage(c, status, fmt.Sprintf("注入的故障：%d %s", status, http.StatusText(status)))
+			return
+		}
+		if fault.HasStreamFault() {
+			c.Set(ctxkey.StreamFault, chaos.NewStreamFault(fault))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+var gzipWriterPool = sync.Pool{
+	New: func() any {
+		writer, _ := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+		return writer
+	},
+}
+
+const (
+	compressUndecided = iota
+	compressPassthrough
+	compressGzip
+)
+
+// compressWriter holds back the start of the response until it knows whether it is worth compressing, an event
+// stream, a flush, or a response smaller than the min size is written as is
+type compressWriter struct {
+	gin.ResponseWriter
+	minSize int
+	state   int
+	buffer  []byte
+	gzip    *gzip.Writer
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	switch w.state {
+	case compressGzip:
+		return w.gzip.Write(data)
+	case compressPassthrough:
+		return w.ResponseWriter.Write(data)
+	}
+	if !w.compressible() {
+		w.state = compressPassthrough
+		return w.ResponseWriter.Write(data)
+	}
+	w.buffer = append(w.buffer, data...)
+	if len(w.buffer) >= w.minSize {
+		if err := w.startGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if w.state == compressUndecided && len(w.buffer) == 0 {
+		w.state = compressPassthrough
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressWriter) Written() bool {
+	return len(w.buffer) > 0 || w.ResponseWriter.Written()
+}
+
+// Flush means the client is waiting for what has been written, so the response is not compressed any more
+func (w *compressWriter) Flush() {
+	switch w.state {
+	case compressUndecided:
+		w.passthrough()
+	case compressGzip:
+		_ = w.gzip.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) compressible() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	status := w.Status()
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	return strings.Contains(contentType, "json") && !strings.HasPrefix(contentType, "text/event-stream")
+}
+
+func (w *compressWriter) startGzip() error {
+	header := w.Header()
+	header.Set("Content-Encoding", "gzip")
+	header.Add("Vary", "Accept-Encoding")
+	header.Del("Content-Length")
+	w.state = compressGzip
+	w.gzip = gzipWriterPool.Get().(*gzip.Writer)
+	w.gzip.Reset(w.ResponseWriter)
+	buffer := w.buffer
+	w.buffer = nil
+	_, err := w.gzip.Write(buffer)
+	return err
+}
+
+func (w *compressWriter) passthrough() {
+	w.state = compressPassthrough
+	if len(w.buffer) > 0 {
+		buffer := w.buffer
+		w.buffer = nil
+		_, _ = w.ResponseWriter.Write(buffer)
+	}
+}
+
+func (w *compressWriter) close() {
+	switch w.state {
+	case compressUndecided:
+		w.passthrough()
+	case compressGzip:
+		_ = w.gzip.Close()
+		w.gzip.Reset(nil)
+		gzipWriterPool.Put(w.gzip)
+		w.gzip = nil
+	}
+}
+
+// acceptsGzip tells whether the Accept-Encoding header of the request lists gzip without a zero quality
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				quality, err := strconv.ParseFloat(value, 64)
+				return err == nil && quality > 0
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Compress gzips the large non-streaming JSON responses, such as the ones of the embeddings, see
+// RELAY_COMPRESSION_MIN_SIZE
+func Compress() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if config.RelayCompressionMinSize <= 0 || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+		writer := &compressWriter{
+			ResponseWriter: c.Writer,
+			minSize:        config.RelayCompressionMinSize,
+		}
+		c.Writer = writer
+		defer func() {
+			writer.close()
+			c.Writer = writer.ResponseWriter
+		}()
+		c.Next()
+	}
+}
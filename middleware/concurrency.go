@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// tokenConcurrencyTTL is the longest a request holds its slot, so that the slots of the requests of a node
+// stopped before releasing them are freed
+const tokenConcurrencyTTL = time.Hour
+
+// the in-flight requests of a token are the members of a sorted set scored by their start time,
+// the members older than the TTL are removed before counting
+var redisAcquireConcurrencyScript = `
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[1])
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[3], ARGV[4])
+redis.call("PEXPIRE", KEYS[1], ARGV[5])
+return 1
+`
+
+var tokenConcurrencyLock sync.Mutex
+var tokenConcurrency = make(map[int]int)
+
+func acquireTokenConcurrency(ctx context.Context, tokenId int, limit int, requestId string) (bool, error) {
+	if !common.RedisEnabled {
+		tokenConcurrencyLock.Lock()
+		defer tokenConcurrencyLock.Unlock()
+		if tokenConcurrency[tokenId] >= limit {
+			return false, nil
+		}
+		tokenConcurrency[tokenId]++
+		return true, nil
+	}
+	now := time.Now()
+	key := "concurrency:token:" + strconv.Itoa(tokenId)
+	acquired, err := common.RDB.Eval(ctx, redisAcquireConcurrencyScript, []string{key},
+		now.Add(-tokenConcurrencyTTL).UnixMilli(), limit, now.UnixMilli(), requestId, tokenConcurrencyTTL.Milliseconds()).Int()
+	return acquired == 1, err
+}
+
+func releaseTokenConcurrency(ctx context.Context, tokenId int, requestId string) {
+	if !common.RedisEnabled {
+		tokenConcurrencyLock.Lock()
+		defer tokenConcurrencyLock.Unlock()
+		if tokenConcurrency[tokenId]--; tokenConcurrency[tokenId] <= 0 {
+			delete(tokenConcurrency, tokenId)
+		}
+		return
+	}
+	key := "concurrency:token:" + strconv.Itoa(tokenId)
+	if err := common.RDB.ZRem(ctx, key, requestId).Err(); err != nil {
+		logger.Errorf(ctx, "failed to release the concurrency slot of token %d: %s", tokenId, err.Error())
+	}
+}
+
+// TokenConcurrency rejects the requests of a token beyond its maximum of simultaneous requests with 429,
+// a request holds its slot until its response, streamed or not, is finished
+func TokenConcurrency() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		limit := c.GetInt(ctxkey.TokenMaxConcurrency)
+		// the summaries of a long context, the screening of a prompt and the stages of a pipeline are sent by the
+		// request holding the slot
+		requestCtx := c.Request.Context()
+		pipelineOf, _ := helper.GetPipelineStage(requestCtx)
+		if limit <= 0 || helper.GetSummaryOf(requestCtx) != "" || helper.GetScreeningOf(requestCtx) != "" || pipelineOf != "" {
+			c.Next()
+			return
+		}
+		tokenId := c.GetInt(ctxkey.TokenId)
+		requestId := c.GetString(helper.RequestIdKey)
+		// the context of the request is done once the client goes away, the slot must be released anyway
+		ctx := context.Background()
+		acquired, err := acquireTokenConcurrency(ctx, tokenId, limit, requestId)
+		if err != nil {
+			// Redis failing must not stop the relay
+			logger.Errorf(c.Request.Context(), "failed to acquire the concurrency slot of token %d: %s", tokenId, err.Error())
+			c.Next()
+			return
+		}
+		if !acquired {
+			abortWithMessage(c, http.StatusTooManyRequests, fmt.Sprintf("该令牌的并发请求数已达上限 %d，请稍后再试", limit))
+			return
+		}
+		defer releaseTokenConcurrency(ctx, tokenId, requestId)
+		c.Next()
+	}
+}
@@ -1,14 +1,13 @@
 package middleware
 
 import (
-	"bytes"
-	"encoding/json"
-	"io"
 	"net/http"
 	"os"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
 )
 
 // 仅拦截 /v1/chat/completions：
@@ -23,51 +22,30 @@ func ConstrainedModelSanitizer() gin.HandlerFunc {
 			return
 		}
 
-		// 读取原始请求体
-		raw, err := io.ReadAll(c.Request.Body)
-
-		// 定义并统一恢复请求体（无论是否改写/早退）
-		restore := func(b []byte) {
-			c.Request.Body = io.NopCloser(bytes.NewReader(b))
-			c.Request.ContentLength = int64(len(b))
-		}
-		defer restore(raw)
-
-		// 读取失败或空体则放行（保持原样）
-		if err != nil || len(raw) == 0 {
-			c.Next()
-			return
-		}
-
-		// 解析 JSON
-		var body map[string]any
-		if err := json.Unmarshal(raw, &body); err != nil {
+		// 解析共享的请求对象，只解析顶层字段的位置，不解码其余字段（如图片）；
+		// 改写在其上进行，由后续读取请求体时统一序列化，字段顺序不变
+		body, err := common.GetRequestObject(c)
+		if err != nil {
 			c.Next()
 			return
 		}
 
 		// 非受限模型直接放行
-		model, _ := body["model"].(string)
-		if !isConstrainedModel(model) {
+		var model string
+		if body.Unmarshal("model", &model) != nil || !isConstrainedModel(model) {
 			c.Next()
 			return
 		}
 
 		// 1) 移除不支持的采样参数
-		delete(body, "temperature")
-		delete(body, "top_p")
+		body.Delete("temperature", "top_p")
 
 		// 2) max_tokens -> max_completion_tokens（若用户传入了 max_tokens）
-		if mt, ok := body["max_tokens"]; ok && mt != nil {
-			if _, exists := body["max_completion_tokens"]; !exists {
-				body["max_completion_tokens"] = mt
+		if mt, ok := body.Get("max_tokens"); ok && string(mt) != "null" {
+			if !body.Has("max_completion_tokens") {
+				body.SetRaw("max_completion_tokens", mt)
 			}
-			delete(body, "max_tokens")
-		}
-
-		// 写回改写后的请求体
-		if patched, err := json.Marshal(body); err == nil {
-			restore(patched)
+			body.Delete("max_tokens")
 		}
 
 		// 交给后续处理
@@ -0,0 +1,123 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/middleware"
+	"github.com/songquanpeng/one-api/relay/defaults"
+)
+
+func sanitizerBody(model string, imageBytes int) []byte {
+	content := any("What is in the picture?")
+	if imageBytes > 0 {
+		image := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x89, 0x50, 0x4e, 0x47}, imageBytes/4))
+		content = []map[string]any{
+			{"type": "text", "text": "What is in the picture?"},
+			{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64," + image}},
+		}
+	}
+	body, _ := json.Marshal(map[string]any{
+		"model":       model,
+		"temperature": 0.7,
+		"max_tokens":  256,
+		"messages":    []map[string]any{{"role": "user", "content": content}},
+	})
+	return body
+}
+
+// BenchmarkConstrainedModelSanitizer rewrites the requests of a constrained model, with and without a 1 MB image,
+// and passes through those of the other models
+func BenchmarkConstrainedModelSanitizer(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ConstrainedModelSanitizer())
+	router.POST("/v1/chat/completions", func(c *gin.Context) {
+		// read as the relay does, which serializes the rewritten request
+		_, _ = common.GetRequestBody(c)
+	})
+	cases := []struct {
+		name string
+		body []byte
+	}{
+		{"rewritten", sanitizerBody("o3-mini", 0)},
+		{"rewritten-vision", sanitizerBody("o3-mini", 1<<20)},
+		{"passthrough-vision", sanitizerBody("claude-3-5-sonnet", 1<<20)},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			b.SetBytes(int64(len(tc.body)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(tc.body))
+				router.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		})
+	}
+}
+
+// TestConstrainedModelSanitizerAfterDefaults sends a request of a constrained model with a token which has defaults,
+// the sanitizer follows the defaults as in the relay router, so the parameters they add are cleaned as well
+func TestConstrainedModelSanitizerAfterDefaults(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	temperature := 0.2
+	maxTokens := 512
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(ctxkey.TokenDefaults, defaults.Defaults{Temperature: &temperature, MaxTokens: &maxTokens})
+	}, middleware.RequestDefaults(), middleware.ConstrainedModelSanitizer())
+	var relayed map[string]any
+	router.POST("/v1/chat/completions", func(c *gin.Context) {
+		body, _ := common.GetRequestBody(c)
+		_ = json.Unmarshal(body, &relayed)
+	})
+	Convey("the defaults of a constrained model are sanitized", t, func() {
+		body, _ := json.Marshal(map[string]any{
+			"model":    "o3-mini",
+			"messages": []map[string]any{{"role": "user", "content": "hi"}},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		So(relayed, ShouldNotContainKey, "temperature")
+		So(relayed, ShouldNotContainKey, "max_tokens")
+		So(relayed["max_completion_tokens"], ShouldEqual, 512)
+	})
+}
+
+// TestConstrainedModelSanitizerPassthrough checks the requests the sanitizer does not rewrite reach the relay with
+// their body, and that it only matches the relay path, so it has to follow PlaygroundAuth which rewrites the path
+func TestConstrainedModelSanitizerPassthrough(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ConstrainedModelSanitizer())
+	var relayed map[string]any
+	handler := func(c *gin.Context) {
+		relayed = nil
+		body, _ := common.GetRequestBody(c)
+		_ = json.Unmarshal(body, &relayed)
+	}
+	router.POST("/v1/chat/completions", handler)
+	router.POST("/api/playground/chat/completions", handler)
+	Convey("the requests of the other models keep their body", t, func() {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(sanitizerBody("claude-3-5-sonnet", 0)))
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		So(relayed["model"], ShouldEqual, "claude-3-5-sonnet")
+		So(relayed["temperature"], ShouldEqual, 0.7)
+		So(relayed["max_tokens"], ShouldEqual, 256)
+	})
+	Convey("the path of the playground is not matched before it is rewritten", t, func() {
+		req := httptest.NewRequest(http.MethodPost, "/api/playground/chat/completions", bytes.NewReader(sanitizerBody("o3-mini", 0)))
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		So(relayed["temperature"], ShouldEqual, 0.7)
+		So(relayed, ShouldNotContainKey, "max_completion_tokens")
+	})
+}
@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// conversationIdHeader names the conversation whose history is replayed into a chat completion
+const conversationIdHeader = "X-OneAPI-Conversation-Id"
+
+// conversationHistory returns the history of the conversation to replay, the oldest messages are dropped to fit
+// the limits, and the history never starts with the answer or the tool output of a dropped message
+func conversationHistory(conversation *model.Conversation, modelName string) ([]*model.ConversationMessage, error) {
+	limit := config.ConversationMaxMessages
+	if conversation.MaxMessages > 0 && conversation.MaxMessages < limit {
+		limit = conversation.MaxMessages
+	}
+	history, err := model.GetRecentConversationMessages(conversation.ConversationId, limit)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.MaxTokens > 0 {
+		tokens := 0
+		start := len(history)
+		for ; start > 0; start-- {
+			var message relaymodel.Message
+			_ = json.Unmarshal([]byte(history[start-1].Message), &message)
+			tokens += openai.CountTokenMessages([]relaymodel.Message{message}, modelName)
+			if tokens > conversation.MaxTokens {
+				break
+			}
+		}
+		history = history[start:]
+	}
+	for len(history) > 0 && history[0].Role != "user" {
+		history = history[1:]
+	}
+	return history, nil
+}
+
+// conversationAnswer returns the first choice of the chat completion response as a message to save,
+// the deltas of a stream are merged into one message
+func conversationAnswer(body []byte, stream bool) (json.RawMessage, bool) {
+	if !stream {
+		var response struct {
+			Choices []struct {
+				Message json.RawMessage `json:"message"`
+			} `json:"choices"`
+		}
+		if json.Unmarshal(body, &response) != nil || len(response.Choices) == 0 || len(response.Choices[0].Message) == 0 {
+			return nil, false
+		}
+		return response.Choices[0].Message, true
+	}
+	var content strings.Builder
+	var toolCalls []relaymodel.Tool
+	chunks := 0
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok || strings.TrimSpace(data) == "[DONE]" {
+			continue
+		}
+		var chunk openai.ChatCompletionsStreamResponse
+		if json.Unmarshal([]byte(data), &chunk) != nil {
+			continue
+		}
+		chunks++
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			content.WriteString(choice.Delta.StringContent())
+			for _, call := range choice.Delta.ToolCalls {
+				index := len(toolCalls)
+				if call.Index != nil {
+					index = *call.Index
+				}
+				for len(toolCalls) <= index {
+					toolCalls = append(toolCalls, relaymodel.Tool{Type: "function"})
+				}
+				merged := &toolCalls[index]
+				if call.Id != "" {
+					merged.Id = call.Id
+				}
+				if call.Function.Name != "" {
+					merged.Function.Name = call.Function.Name
+				}
+				arguments, _ := merged.Function.Arguments.(string)
+				delta, _ := call.Function.Arguments.(string)
+				merged.Function.Arguments = arguments + delta
+			}
+		}
+	}
+	if chunks == 0 {
+		return nil, false
+	}
+	answer := relaymodel.Message{Role: "assistant", ToolCalls: toolCalls}
+	if content.Len() > 0 || len(toolCalls) == 0 {
+		answer.Content = content.String()
+	}
+	data, err := json.Marshal(answer)
+	return data, err == nil
+}
+
+// Conversation replays the history of the conversation of the X-OneAPI-Conversation-Id header before the new messages
+// of the chat completion request, the system messages of the request are kept first, and saves the new messages
+// with the answer to the conversation when the request succeeds
+func Conversation() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		conversationId := c.Request.Header.Get(conversationIdHeader)
+		if conversationId == "" {
+			c.Next()
+			return
+		}
+		if !strings.HasPrefix(c.Request.URL.Path, "/v1/chat/completions") {
+			abortWithMessage(c, http.StatusBadRequest, conversationIdHeader+" 仅适用于对话补全请求")
+			return
+		}
+		conversation, err := model.GetUserConversation(c.GetInt(ctxkey.Id), conversationId)
+		if err != nil {
+			abortWithMessage(c, http.StatusNotFound, fmt.Sprintf("conversation %s 不存在", conversationId))
+			return
+		}
+		var messagesOfRequest []json.RawMessage
+		var stream bool
+		request, err := common.GetRequestObject(c)
+		if err == nil {
+			err = request.Unmarshal("messages", &messagesOfRequest)
+		}
+		if err == nil {
+			err = request.Unmarshal("stream", &stream)
+		}
+		if err != nil {
+			abortWithMessage(c, http.StatusBadRequest, "无效的请求："+err.Error())
+			return
+		}
+		var system []json.RawMessage
+		var added []*model.ConversationMessage
+		for _, raw := range messagesOfRequest {
+			var message relaymodel.Message
+			if err = json.Unmarshal(raw, &message); err != nil {
+				abortWithMessage(c, http.StatusBadRequest, "无效的消息："+err.Error())
+				return
+			}
+			if message.Role == "system" || message.Role == "developer" {
+				system = append(system, raw)
+				continue
+			}
+			added = append(added, &model.ConversationMessage{Role: message.Role, Message: string(raw)})
+		}
+		if len(added) == 0 {
+			abortWithMessage(c, http.StatusBadRequest, "请求中没有要加入对话的新消息")
+			return
+		}
+		history, err := conversationHistory(conversation, c.GetString(ctxkey.RequestModel))
+		if err != nil {
+			abortWithMessage(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		messages := system
+		for _, message := range history {
+			messages = append(messages, json.RawMessage(message.Message))
+		}
+		for _, message := range added {
+			messages = append(messages, json.RawMessage(message.Message))
+		}
+		if err = request.Set("messages", messages); err != nil {
+			abortWithMessage(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Header("X-OneAPI-Conversation-History", strconv.Itoa(len(history)))
+
+		writer := &teeWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		if writer.Status() != http.StatusOK || c.GetBool(ctxkey.DryRun) {
+			return
+		}
+		answer, ok := conversationAnswer(writer.body.Bytes(), stream)
+		if !ok {
+			logger.Warnf(c.Request.Context(), "no answer to save to conversation %s", conversationId)
+			return
+		}
+		added = append(added, &model.ConversationMessage{Role: "assistant", Message: string(answer)})
+		if err = model.AppendConversationMessages(conversation, added); err != nil {
+			logger.Errorf(c.Request.Context(), "failed to save conversation %s: %s", conversationId, err.Error())
+		}
+	}
+}
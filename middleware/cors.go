@@ -1,15 +1,79 @@
 package middleware
 
 import (
+	"path"
+	"strings"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/network"
 )
 
+// IsManagementPath tells whether the path belongs to the management API, which the console signs in to with cookies,
+// rather than to the relay API called with tokens
+func IsManagementPath(p string) bool {
+	p = path.Clean("/" + p)
+	return p == "/api" || strings.HasPrefix(p, "/api/")
+}
+
+func newCORS(allowCredentials bool, allowOrigin func(origin string) bool) gin.HandlerFunc {
+	corsConfig := cors.DefaultConfig()
+	if allowOrigin == nil {
+		corsConfig.AllowAllOrigins = true
+	} else {
+		corsConfig.AllowOriginFunc = allowOrigin
+	}
+	corsConfig.AllowCredentials = allowCredentials
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"*"}
+	return cors.New(corsConfig)
+}
+
+// isConsoleOrigin tells whether the origin is the one of the server address, where the console is served
+func isConsoleOrigin(origin string) bool {
+	serverOrigin := network.NormalizeOrigin(config.ServerAddress)
+	return serverOrigin != "" && strings.ToLower(origin) == serverOrigin
+}
+
+// CORS applies RelayCORSAllowedOrigins to the relay API, and AdminCORSAllowedOrigins to the management API,
+// the options are read on each request so that they take effect at once
 func CORS() gin.HandlerFunc {
-	config := cors.DefaultConfig()
-	config.AllowAllOrigins = true
-	config.AllowCredentials = true
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"*"}
-	return cors.New(config)
+	relayAnyOrigin := newCORS(true, nil)
+	relay := newCORS(false, func(origin string) bool {
+		return network.IsOriginAllowed(origin, config.RelayCORSAllowedOrigins)
+	})
+	admin := newCORS(true, func(origin string) bool {
+		return isConsoleOrigin(origin) || network.IsOriginAllowed(origin, config.AdminCORSAllowedOrigins)
+	})
+	return func(c *gin.Context) {
+		if IsManagementPath(c.Request.URL.Path) {
+			// no CORS headers are sent unless origins are listed, the browsers only let the console itself call it
+			if config.AdminCORSAllowedOrigins != "" {
+				admin(c)
+			}
+			return
+		}
+		if strings.TrimSpace(config.RelayCORSAllowedOrigins) == "*" {
+			relayAnyOrigin(c)
+			return
+		}
+		relay(c)
+	}
+}
+
+// checkTokenOrigin tells whether a token limited to some origins may be used by the request, the requests
+// sent without a browser carry no origin
+func checkTokenOrigin(c *gin.Context, allowedOrigins string) bool {
+	origin := c.Request.Header.Get("Origin")
+	if allowedOrigins == "" || origin == "" {
+		return true
+	}
+	host := strings.ToLower(c.Request.Host)
+	origin = strings.ToLower(origin)
+	if origin == "http://"+host || origin == "https://"+host || isConsoleOrigin(origin) {
+		return true
+	}
+	return network.IsOriginAllowed(origin, allowedOrigins)
 }
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the header sent by the official openai sdks, and the one for the other clients
+var requestTimeoutHeaders = []string{"X-Stainless-Timeout", "X-Request-Timeout"}
+
+// Deadline applies the timeout given by the client, in seconds, as the deadline of the upstream requests,
+// so that the upstream stops generating once the client has given up
+func Deadline() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		value := ""
+		for _, header := range requestTimeoutHeaders {
+			if value = c.Request.Header.Get(header); value != "" {
+				break
+			}
+		}
+		if value == "" {
+			c.Next()
+			return
+		}
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds <= 0 {
+			abortWithMessage(c, http.StatusBadRequest, "无效的请求超时时间")
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(seconds*float64(time.Second)))
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// dedupHeader returns to the coalesced requests the id of the request whose response they share
+const dedupHeader = "X-OneAPI-Deduplicated"
+
+// dedupCall is the response of the first of the identical requests, shared with those repeating it as it is written
+type dedupCall struct {
+	requestId string
+	expiresAt time.Time
+
+	lock    sync.Mutex
+	changed chan struct{}
+	started bool
+	done    bool
+	status  int
+	header  http.Header
+	body    bytes.Buffer
+}
+
+var dedupCalls = struct {
+	sync.Mutex
+	calls map[string]*dedupCall
+}{calls: make(map[string]*dedupCall)}
+
+func init() {
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+			now := time.Now()
+			dedupCalls.Lock()
+			for key, call := range dedupCalls.calls {
+				if now.After(call.expiresAt) {
+					delete(dedupCalls.calls, key)
+				}
+			}
+			dedupCalls.Unlock()
+		}
+	}()
+}
+
+// start keeps the status and the headers of the response once it is written
+func (call *dedupCall) start(w gin.ResponseWriter) {
+	if call.started {
+		return
+	}
+	call.started = true
+	call.status = w.Status()
+	call.header = w.Header().Clone()
+}
+
+// notify wakes up the requests waiting for more of the response
+func (call *dedupCall) notify() {
+	close(call.changed)
+	call.changed = make(chan struct{})
+}
+
+func (call *dedupCall) write(w gin.ResponseWriter, data []byte) {
+	call.lock.Lock()
+	defer call.lock.Unlock()
+	call.start(w)
+	call.body.Write(data)
+	call.notify()
+}
+
+// finish ends the response shared, the requests repeating a request that panicked before writing fail too
+func (call *dedupCall) finish(w gin.ResponseWriter, panicked bool) {
+	call.lock.Lock()
+	defer call.lock.Unlock()
+	if panicked && !call.started {
+		call.started = true
+		call.status = http.StatusInternalServerError
+	}
+	call.start(w)
+	call.done = true
+	call.notify()
+}
+
+// dedupWriter writes the response through and shares it with the requests repeating it
+type dedupWriter struct {
+	gin.ResponseWriter
+	call *dedupCall
+}
+
+func (w *dedupWriter) Write(data []byte) (int, error) {
+	w.call.write(w.ResponseWriter, data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *dedupWriter) WriteString(s string) (int, error) {
+	w.call.write(w.ResponseWriter, []byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// dedupKey identifies the requests of a token by their path, their body and the headers changing how they are relayed
+func dedupKey(c *gin.Context, body []byte) string {
+	var headers []string
+	for name := range c.Request.Header {
+		if strings.HasPrefix(name, "X-Oneapi-") || name == projectHeader || name == "Content-Type" || name == "Accept" {
+			headers = append(headers, name+": "+strings.Join(c.Request.Header.Values(name), ", "))
+		}
+	}
+	sort.Strings(headers)
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.URL.RequestURI() + "\n" + strings.Join(headers, "\n") + "\n\n"))
+	hash.Write(body)
+	return "dedup:" + strconv.Itoa(c.GetInt(ctxkey.TokenId)) + ":" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// follow writes the response of the call to the request as it is written for the first request, until it is
+// finished or the client goes away
+func follow(c *gin.Context, call *dedupCall) {
+	offset := 0
+	for {
+		call.lock.Lock()
+		started, done, changed := call.started, call.done, call.changed
+		var data []byte
+		if started {
+			if offset == 0 {
+				// the headers of this request, such as its id and its rate limits, are kept
+				header := c.Writer.Header()
+				for name, values := range call.header {
+					if _, ok := header[name]; !ok && name != "Content-Length" && name != "Content-Encoding" && name != "Vary" {
+						header[name] = values
+					}
+				}
+				c.Header(dedupHeader, call.requestId)
+				c.Status(call.status)
+			}
+			data = append(data, call.body.Bytes()[offset:]...)
+			offset += len(data)
+		}
+		call.lock.Unlock()
+		if started && (len(data) > 0 || done) {
+			_, _ = c.Writer.Write(data)
+			c.Writer.Flush()
+		}
+		if done {
+			return
+		}
+		select {
+		case <-changed:
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// RequestDedup coalesces the byte-identical requests of a token within RequestDedupWindow seconds onto the first
+// of them, the requests repeating it share its response instead of being relayed and billed again, such as the
+// double submissions of a client, streamed responses are shared as they are written
+func RequestDedup() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		window := time.Duration(config.RequestDedupWindow) * time.Second
+		// the stages of a pipeline are billed one by one even when they are the same
+		if pipelineOf, _ := helper.GetPipelineStage(c.Request.Context()); window <= 0 || c.Request.Method != http.MethodPost || pipelineOf != "" {
+			c.Next()
+			return
+		}
+		body, err := common.GetRequestBody(c)
+		if err != nil {
+			abortWithMessage(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		key := dedupKey(c, body)
+		now := time.Now()
+		dedupCalls.Lock()
+		call, ok := dedupCalls.calls[key]
+		if ok && now.Before(call.expiresAt) {
+			dedupCalls.Unlock()
+			logger.Infof(c.Request.Context(), "coalesced with the identical request %s", call.requestId)
+			follow(c, call)
+			c.Abort()
+			return
+		}
+		call = &dedupCall{
+			requestId: c.GetString(helper.RequestIdKey),
+			expiresAt: now.Add(window),
+			changed:   make(chan struct{}),
+		}
+		dedupCalls.calls[key] = call
+		dedupCalls.Unlock()
+
+		writer := &dedupWriter{ResponseWriter: c.Writer, call: call}
+		c.Writer = writer
+		completed := false
+		defer func() {
+			c.Writer = writer.ResponseWriter
+			call.finish(c.Writer, !completed)
+			// failed requests are not billed, so that they can be retried at once
+			if status := c.Writer.Status(); !completed || status < 200 || status >= 300 {
+				dedupCalls.Lock()
+				if dedupCalls.calls[key] == call {
+					delete(dedupCalls.calls, key)
+				}
+				dedupCalls.Unlock()
+			}
+		}()
+		c.Next()
+		completed = true
+	}
+}
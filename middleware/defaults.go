@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/relay/defaults"
+)
+
+// RequestDefaults merges the defaults of the token and the user group into the chat and completion requests
+func RequestDefaults() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if !strings.HasPrefix(path, "/v1/chat/completions") && !strings.HasPrefix(path, "/v1/completions") {
+			c.Next()
+			return
+		}
+		tokenDefaults, _ := c.Get(ctxkey.TokenDefaults)
+		groupDefaults := defaults.GetGroupDefaults(c.GetString(ctxkey.Group))
+		d, _ := tokenDefaults.(defaults.Defaults)
+		if d.IsEmpty() && groupDefaults.IsEmpty() {
+			c.Next()
+			return
+		}
+		request, err := common.GetRequestObject(c)
+		if err != nil {
+			// left to the relay to report
+			c.Next()
+			return
+		}
+		if err = defaults.Apply(request, d, groupDefaults); err != nil {
+			abortWithMessage(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/defaults"
+)
+
+// ModelDeprecation sends the requests for a retired model to its replacement, the substitution is returned in
+// the X-OneAPI-Model-Substitution header; the models of the token are checked against the model requested
+func ModelDeprecation() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		requestModel := c.GetString(ctxkey.RequestModel)
+		replacement, ok := defaults.GetModelReplacement(requestModel)
+		if !ok || !strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+			c.Next()
+			return
+		}
+		if err := setRequestBodyModel(c, replacement); err != nil {
+			abortWithMessage(c, http.StatusBadRequest, "无效的请求："+err.Error())
+			return
+		}
+		c.Set(ctxkey.RequestModel, replacement)
+		c.Header("X-OneAPI-Model-Substitution", requestModel+" -> "+replacement)
+		logger.Infof(c.Request.Context(), "model %s is deprecated, replaced by %s", requestModel, replacement)
+		c.Next()
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/apiversion"
 	"github.com/songquanpeng/one-api/relay/channeltype"
 )
 
@@ -21,7 +22,11 @@ func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 		userId := c.GetInt(ctxkey.Id)
-		userGroup, _ := model.CacheGetUserGroup(userId)
+		userGroups, _ := model.CacheGetUserGroups(userId)
+		if len(userGroups) == 0 {
+			userGroups = []string{""}
+		}
+		userGroup := userGroups[0]
 		c.Set(ctxkey.Group, userGroup)
 		var requestModel string
 		var channel *model.Channel
@@ -43,9 +48,33 @@ func Distribute() func(c *gin.Context) {
 			}
 		} else {
 			requestModel = c.GetString(ctxkey.RequestModel)
-			var err error
-			channel, err = model.CacheGetRandomSatisfiedChannel(userGroup, requestModel, false)
-			if err != nil {
+			// the groups of the user, then the other groups of its plan, each followed by the groups it inherits,
+			// the first one having a channel for the model serves the request
+			memberGroups := append(userGroups, c.GetStringSlice(ctxkey.PlanGroups)...)
+			found, denied := false, false
+			for _, group := range model.ExpandGroups(memberGroups) {
+				if model.IsModelDeniedByRouting(group.Member, requestModel) {
+					denied = true
+					continue
+				}
+				groupChannel, err := model.CacheGetRandomSatisfiedChannel(group.Group, requestModel, false)
+				if err != nil {
+					if groupChannel != nil {
+						channel = groupChannel
+					}
+					continue
+				}
+				channel, found = groupChannel, true
+				userGroup = group.Member
+				c.Set(ctxkey.Group, group.Member)
+				c.Set(ctxkey.ChannelGroup, group.Group)
+				break
+			}
+			if !found && denied && channel == nil {
+				abortWithMessage(c, http.StatusForbidden, fmt.Sprintf("当前时段分组 %s 不可使用模型 %s", userGroup, requestModel))
+				return
+			}
+			if !found {
 				message := fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", userGroup, requestModel)
 				if channel != nil {
 					logger.SysError(fmt.Sprintf("渠道不存在：%d", channel.Id))
@@ -98,5 +127,6 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 			}
 		}
 	}
+	cfg.APIVersion = apiversion.Resolve(channel.Id, channel.Type, cfg.APIVersion, cfg.AutoUpgradeAPIVersion)
 	c.Set(ctxkey.Config, cfg)
 }
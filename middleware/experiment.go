@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// Experiment replaces the virtual model of a running experiment with the model of the picked variant,
+// the variant is returned in the X-OneAPI-Experiment header and recorded in the consume log
+func Experiment() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		experiment, variant := model.PickExperimentVariant(c.GetString(ctxkey.RequestModel))
+		if experiment == nil || !strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+			c.Next()
+			return
+		}
+		// the token may use the virtual model but not the model of the variant, the request then goes to the
+		// other variant and is left out of the experiment, so as not to skew the report
+		recorded := true
+		if models := c.GetString(ctxkey.AvailableModels); models != "" && !isModelInList(variant.Model, models) {
+			if variant.Name == "A" {
+				variant = experiment.Variant("B")
+			} else {
+				variant = experiment.Variant("A")
+			}
+			if !isModelInList(variant.Model, models) {
+				abortWithMessage(c, http.StatusForbidden, fmt.Sprintf("该令牌无权使用模型：%s", variant.Model))
+				return
+			}
+			recorded = false
+		}
+		if err := setRequestBodyModel(c, variant.Model); err != nil {
+			abortWithMessage(c, http.StatusBadRequest, "无效的请求："+err.Error())
+			return
+		}
+		c.Set(ctxkey.RequestModel, variant.Model)
+		if _, ok := c.Get(ctxkey.SpecificChannelId); !ok && variant.ChannelId != 0 {
+			c.Set(ctxkey.SpecificChannelId, strconv.Itoa(variant.ChannelId))
+		}
+		if recorded {
+			tag := experiment.Name + "/" + variant.Name
+			c.Header("X-OneAPI-Experiment", tag)
+			c.Request = c.Request.WithContext(helper.SetExperiment(c.Request.Context(), tag))
+		}
+		c.Next()
+	}
+}
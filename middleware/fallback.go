@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+)
+
+// only the text requests can be downgraded to the fallback model
+func isQuotaFallbackPath(c *gin.Context) bool {
+	path := c.Request.URL.Path
+	return strings.HasPrefix(path, "/v1/chat/completions") || strings.HasPrefix(path, "/v1/completions") ||
+		strings.HasPrefix(path, "/v1/templates/chat/completions")
+}
+
+// useQuotaFallbackModel replaces the model of the request of an exhausted token with QUOTA_FALLBACK_MODEL,
+// the downgrade is returned in the X-OneAPI-Downgraded-To header
+func useQuotaFallbackModel(c *gin.Context) error {
+	if err := setRequestBodyModel(c, config.QuotaFallbackModel); err != nil {
+		return err
+	}
+	c.Set(ctxkey.QuotaFallback, true)
+	c.Header("X-OneAPI-Downgraded-To", config.QuotaFallbackModel)
+	return nil
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// FeatureFlag rejects the requests to a beta endpoint from the user groups it is not rolled out to yet
+func FeatureFlag(feature string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		group, err := model.CacheGetUserGroup(c.GetInt(ctxkey.Id))
+		if err != nil {
+			abortWithMessage(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !model.IsFeatureEnabled(feature, group) {
+			abortWithMessage(c, http.StatusForbidden, fmt.Sprintf("功能 %s 尚未对分组 %s 开放", feature, group))
+			return
+		}
+		c.Next()
+	}
+}
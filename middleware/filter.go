@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/filter"
+)
+
+// getResponseFilter returns the filters of the channel currently selected and of the user group
+func getResponseFilter(c *gin.Context) *filter.Filter {
+	var rules []filter.Rule
+	if cfg, ok := c.Get(ctxkey.Config); ok {
+		rules = append(rules, cfg.(model.ChannelConfig).ResponseFilters...)
+	}
+	rules = append(rules, filter.GetGroupFilters(c.GetString(ctxkey.Group))...)
+	f, err := filter.Compile(rules)
+	if err != nil {
+		logger.Errorf(c.Request.Context(), "invalid response filters of channel #%d: %s", c.GetInt(ctxkey.ChannelId), err.Error())
+		return nil
+	}
+	return f
+}
+
+// ResponseFilters post-processes the generated text with the filters of the channel and the user group
+func ResponseFilters() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if getResponseFilter(c).IsEmpty() {
+			c.Next()
+			return
+		}
+		c.Set(ctxkey.StreamFilter, filter.NewChunkFilter(func() *filter.Filter {
+			return getResponseFilter(c)
+		}))
+		writer := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		// the streams are filtered chunk by chunk when rendered
+		if writer.passthrough || c.Writer.Written() {
+			return
+		}
+		body := writer.body.Bytes()
+		if writer.status == http.StatusOK && strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "application/json") {
+			body = filter.FilterResponseBody(getResponseFilter(c), body)
+		}
+		c.Writer.Header().Del("Content-Length")
+		c.Writer.WriteHeader(writer.status)
+		_, _ = c.Writer.Write(body)
+	}
+}
@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// a request holding the key longer than this is considered lost, so that the key can be used again
+const idempotencyPendingTTL = 10 * time.Minute
+
+type idempotentResponse struct {
+	Pending     bool   `json:"pending,omitempty"`
+	RequestHash string `json:"request_hash"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+type idempotencyEntry struct {
+	response  *idempotentResponse
+	expiresAt time.Time
+}
+
+var idempotencyStore = struct {
+	sync.Mutex
+	entries map[string]*idempotencyEntry
+}{entries: make(map[string]*idempotencyEntry)}
+
+// idempotencySweeper removes the expired entries of the memory store, it is started with the first entry
+var idempotencySweeper sync.Once
+
+func sweepIdempotencyStore() {
+	for {
+		time.Sleep(time.Minute)
+		now := time.Now()
+		idempotencyStore.Lock()
+		for key, entry := range idempotencyStore.entries {
+			if now.After(entry.expiresAt) {
+				delete(idempotencyStore.entries, key)
+			}
+		}
+		idempotencyStore.Unlock()
+	}
+}
+
+// acquireIdempotencyKey marks the key as pending and returns nil,
+// or returns the pending or finished response already saved with the key
+func acquireIdempotencyKey(key string, requestHash string) (*idempotentResponse, error) {
+	pending := &idempotentResponse{Pending: true, RequestHash: requestHash}
+	if common.RedisEnabled {
+		data, _ := json.Marshal(pending)
+		ok, err := common.RedisSetNX(key, string(data), idempotencyPendingTTL)
+		if err != nil || ok {
+			return nil, err
+		}
+		value, err := common.RedisGet(key)
+		if err != nil {
+			return nil, err
+		}
+		response := &idempotentResponse{}
+		err = json.Unmarshal([]byte(value), response)
+		return response, err
+	}
+	idempotencySweeper.Do(func() {
+		go sweepIdempotencyStore()
+	})
+	idempotencyStore.Lock()
+	defer idempotencyStore.Unlock()
+	if entry, ok := idempotencyStore.entries[key]; ok && time.Now().Before(entry.expiresAt) {
+		return entry.response, nil
+	}
+	idempotencyStore.entries[key] = &idempotencyEntry{response: pending, expiresAt: time.Now().Add(idempotencyPendingTTL)}
+	return nil, nil
+}
+
+func saveIdempotentResponse(key string, response *idempotentResponse) {
+	ttl := time.Duration(config.IdempotencyKeyTTL) * time.Second
+	if common.RedisEnabled {
+		data, _ := json.Marshal(response)
+		if err := common.RedisSet(key, string(data), ttl); err != nil {
+			logger.SysError("failed to save idempotent response: " + err.Error())
+		}
+		return
+	}
+	idempotencyStore.Lock()
+	idempotencyStore.entries[key] = &idempotencyEntry{response: response, expiresAt: time.Now().Add(ttl)}
+	idempotencyStore.Unlock()
+}
+
+func releaseIdempotencyKey(key string) {
+	if common.RedisEnabled {
+		_ = common.RedisDel(key)
+		return
+	}
+	idempotencyStore.Lock()
+	delete(idempotencyStore.entries, key)
+	idempotencyStore.Unlock()
+}
+
+// teeWriter writes the response through and keeps a copy of it
+type teeWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *teeWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *teeWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency returns the saved response for the requests repeated with the same Idempotency-Key header,
+// so that the retries of the clients are not billed twice, stream requests are not supported
+func Idempotency() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		idempotencyKey := c.Request.Header.Get("Idempotency-Key")
+		if idempotencyKey == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		if len(idempotencyKey) > 255 {
+			abortWithMessage(c, http.StatusBadRequest, "Idempotency-Key 过长")
+			return
+		}
+		body, err := common.GetRequestBody(c)
+		if err != nil {
+			abortWithMessage(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		var request struct {
+			Stream bool `json:"stream"`
+		}
+		if json.Unmarshal(body, &request) == nil && request.Stream {
+			c.Next()
+			return
+		}
+		hash := sha256.Sum256(append([]byte(c.Request.URL.Path+"\n"), body...))
+		requestHash := hex.EncodeToString(hash[:])
+		key := fmt.Sprintf("idempotency:%d:%s", c.GetInt(ctxkey.TokenId), idempotencyKey)
+		saved, err := acquireIdempotencyKey(key, requestHash)
+		if err != nil {
+			logger.Errorf(c.Request.Context(), "failed to acquire idempotency key: %s", err.Error())
+			c.Next()
+			return
+		}
+		if saved != nil {
+			if saved.RequestHash != requestHash {
+				abortWithMessage(c, http.StatusUnprocessableEntity, "该 Idempotency-Key 已用于不同的请求")
+				return
+			}
+			if saved.Pending {
+				abortWithMessage(c, http.StatusConflict, "使用该 Idempotency-Key 的请求正在处理中")
+				return
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(saved.Status, saved.ContentType, saved.Body)
+			c.Abort()
+			return
+		}
+		writer := &teeWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		finished := false
+		defer func() {
+			if !finished {
+				// the request panicked, the key is released rather than left pending until idempotencyPendingTTL
+				c.Writer = writer.ResponseWriter
+				releaseIdempotencyKey(key)
+			}
+		}()
+		c.Next()
+		finished = true
+		c.Writer = writer.ResponseWriter
+		// failed requests are not billed, so they can be retried with the same key
+		if status := writer.Status(); status >= 200 && status < 300 {
+			saveIdempotentResponse(key, &idempotentResponse{
+				RequestHash: requestHash,
+				Status:      status,
+				ContentType: writer.Header().Get("Content-Type"),
+				Body:        writer.body.Bytes(),
+			})
+		} else {
+			releaseIdempotencyKey(key)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+// keepAliveWriter sends a comment line when an event stream has been idle for the interval,
+// so that the proxies in between do not close the streams of the models thinking for a long time
+type keepAliveWriter struct {
+	gin.ResponseWriter
+	interval  time.Duration
+	mutex     sync.Mutex
+	lastWrite time.Time
+	started   bool
+	closed    bool
+	done      chan struct{}
+}
+
+func (w *keepAliveWriter) Write(data []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.wrote()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *keepAliveWriter) WriteString(s string) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.wrote()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *keepAliveWriter) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.ResponseWriter.Flush()
+}
+
+// wrote is called with the mutex held, the heartbeat starts with the first write of an event stream
+func (w *keepAliveWriter) wrote() {
+	w.lastWrite = time.Now()
+	if w.started || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		return
+	}
+	w.started = true
+	go w.heartbeat()
+}
+
+func (w *keepAliveWriter) heartbeat() {
+	timer := time.NewTimer(w.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-timer.C:
+		}
+		w.mutex.Lock()
+		if w.closed {
+			w.mutex.Unlock()
+			return
+		}
+		idle := time.Since(w.lastWrite)
+		if idle >= w.interval {
+			_, _ = w.ResponseWriter.WriteString(": keep-alive\n\n")
+			w.ResponseWriter.Flush()
+			w.lastWrite = time.Now()
+			idle = 0
+		}
+		w.mutex.Unlock()
+		timer.Reset(w.interval - idle)
+	}
+}
+
+func (w *keepAliveWriter) close() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.closed = true
+	close(w.done)
+}
+
+// StreamKeepAlive writes the keep-alive comments of the event streams, see STREAM_KEEP_ALIVE_INTERVAL
+func StreamKeepAlive() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if config.StreamKeepAliveInterval <= 0 {
+			c.Next()
+			return
+		}
+		writer := &keepAliveWriter{
+			ResponseWriter: c.Writer,
+			interval:       time.Duration(config.StreamKeepAliveInterval) * time.Second,
+			done:           make(chan struct{}),
+		}
+		c.Writer = writer
+		defer func() {
+			writer.close()
+			c.Writer = writer.ResponseWriter
+		}()
+		c.Next()
+	}
+}
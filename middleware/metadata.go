@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+)
+
+const metadataHeader = "X-OneAPI-Metadata"
+
+// addResponseMetadata appends the one_api object to the JSON object of the response, the other fields are kept as is
+func addResponseMetadata(body []byte, metadata any) []byte {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) < 2 || trimmed[0] != '{' || trimmed[len(trimmed)-1] != '}' || !json.Valid(trimmed) {
+		return body
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return body
+	}
+	var buffer bytes.Buffer
+	buffer.Write(trimmed[:len(trimmed)-1])
+	if len(bytes.TrimSpace(trimmed[1:len(trimmed)-1])) > 0 {
+		buffer.WriteByte(',')
+	}
+	buffer.WriteString(`"one_api":`)
+	buffer.Write(data)
+	buffer.WriteByte('}')
+	return buffer.Bytes()
+}
+
+// ResponseMetadata adds the channel, the latency, the cost and the remaining quota to the responses of the requests
+// with the X-OneAPI-Metadata header, in the one_api object of the response or of the last chunk of the stream
+func ResponseMetadata() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Header.Get(metadataHeader) != "true" {
+			c.Next()
+			return
+		}
+		c.Set(ctxkey.MetadataRequested, true)
+		writer := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		// the streams carry the metadata in their last chunk
+		if writer.passthrough || c.Writer.Written() {
+			return
+		}
+		body := writer.body.Bytes()
+		metadata, ok := c.Get(ctxkey.ResponseMetadata)
+		if ok && writer.status == http.StatusOK && strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "application/json") {
+			body = addResponseMetadata(body, metadata)
+		}
+		c.Writer.Header().Del("Content-Length")
+		c.Writer.WriteHeader(writer.status)
+		_, _ = c.Writer.Write(body)
+	}
+}
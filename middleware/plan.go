@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// the requests of a user in the last minute are the members of a sorted set scored by their time,
+// the script returns the requests left after this one, -1 when this one is rejected
+var redisPlanRateLimitScript = `
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[1])
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[2]) then
+	return -1
+end
+redis.call("ZADD", KEYS[1], ARGV[3], ARGV[4])
+redis.call("PEXPIRE", KEYS[1], 60000)
+return tonumber(ARGV[2]) - redis.call("ZCARD", KEYS[1])
+`
+
+// allowPlanRequest also returns how many more requests the user may make in the minute
+func allowPlanRequest(ctx context.Context, userId int, rpm int, requestId string) (bool, int, error) {
+	if !common.RedisEnabled {
+		inMemoryRateLimiter.Init(config.RateLimitKeyExpirationDuration)
+		key := "PL" + strconv.Itoa(userId)
+		allowed := inMemoryRateLimiter.Request(key, rpm, 60)
+		return allowed, inMemoryRateLimiter.Remaining(key, rpm, 60), nil
+	}
+	now := time.Now()
+	key := "rateLimit:plan:" + strconv.Itoa(userId)
+	remaining, err := common.RDB.Eval(ctx, redisPlanRateLimitScript, []string{key},
+		now.Add(-time.Minute).UnixMilli(), rpm, now.UnixMilli(), requestId).Int()
+	if err != nil {
+		return false, 0, err
+	}
+	if remaining < 0 {
+		return false, 0, nil
+	}
+	return true, remaining, nil
+}
+
+// PlanLimit applies the plan of the user, its requests beyond the RPM of the plan are rejected with 429
+// and the groups of the plan are kept for the channel selection
+func PlanLimit() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userId := c.GetInt(ctxkey.Id)
+		plan, err := model.CacheGetUserPlan(userId)
+		if err != nil {
+			logger.Errorf(ctx, "failed to get the plan of user %d: %s", userId, err.Error())
+		}
+		if plan == nil {
+			c.Next()
+			return
+		}
+		c.Set(ctxkey.PlanGroups, plan.GetGroups())
+		if plan.RPM > 0 {
+			allowed, remaining, err := allowPlanRequest(ctx, userId, plan.RPM, c.GetString(helper.RequestIdKey))
+			if err != nil {
+				// Redis failing must not stop the relay
+				logger.Errorf(ctx, "failed to check the plan rate limit of user %d: %s", userId, err.Error())
+			} else {
+				c.Set(ctxkey.RemainingRequests, remaining)
+			}
+			if err == nil && !allowed {
+				abortWithMessage(c, http.StatusTooManyRequests, fmt.Sprintf("套餐 %s 的请求数已达上限 %d 次/分钟，请稍后再试", plan.Name, plan.RPM))
+				return
+			}
+		}
+		c.Next()
+	}
+}
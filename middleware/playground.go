@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// PlaygroundAuth turns a request of the logged-in user into a relay request authorized by
+// the token selected with the X-OneAPI-Token-Id header, so that the usage is billed to it
+func PlaygroundAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		tokenId, _ := strconv.Atoi(c.Request.Header.Get("X-OneAPI-Token-Id"))
+		token, err := model.GetTokenByIds(tokenId, c.GetInt(ctxkey.Id))
+		if err != nil {
+			abortWithMessage(c, http.StatusBadRequest, "无效的令牌")
+			return
+		}
+		c.Request.Header.Set("Authorization", "Bearer sk-"+token.Key)
+		c.Request.URL.Path = "/v1" + strings.TrimPrefix(c.Request.URL.Path, "/api/playground")
+		c.Next()
+	}
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/relay/plugin"
+)
+
+// bufferedWriter holds back the response so that plugins can modify it,
+// streaming responses are written through as is
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	decided     bool
+	passthrough bool
+}
+
+func (w *bufferedWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.decide()
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bufferedWriter) Flush() {
+	w.decide()
+	if w.passthrough {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.passthrough {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	return w.body.Len()
+}
+
+// Plugins runs the pre-request and post-response hooks of the registered plugins
+func Plugins() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !plugin.Enabled() {
+			c.Next()
+			return
+		}
+		meta := plugin.GetMeta(c)
+		if strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+			body, err := common.GetRequestBody(c)
+			if err != nil {
+				abortWithMessage(c, http.StatusBadRequest, err.Error())
+				return
+			}
+			body, err = plugin.PreRequest(c, meta, body)
+			if err != nil {
+				var rejectErr *plugin.RejectError
+				if errors.As(err, &rejectErr) {
+					abortWithMessage(c, rejectErr.StatusCode, rejectErr.Message)
+					return
+				}
+				abortWithMessage(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			common.SetRequestBody(c, body)
+		}
+
+		writer := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		// the connection is hijacked (websocket) or already streamed
+		if writer.passthrough || c.Writer.Written() {
+			return
+		}
+		body := writer.body.Bytes()
+		if strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "application/json") {
+			body = plugin.PostResponse(c, meta, writer.status, body)
+		}
+		c.Writer.Header().Del("Content-Length")
+		c.Writer.WriteHeader(writer.status)
+		_, _ = c.Writer.Write(body)
+	}
+}
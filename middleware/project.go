@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+const (
+	projectHeader = "X-Project"
+	// maxProjectLength is the most characters of a project, as stored in the logs
+	maxProjectLength = 64
+)
+
+// Project attributes the usage of the request to the project of the X-Project header, or of the project field of
+// the metadata of a JSON body, the project is recorded in the consume logs and summed in the usage reports
+func Project() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		project := strings.TrimSpace(c.Request.Header.Get(projectHeader))
+		if project == "" && strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+			var metadata struct {
+				Project string `json:"project"`
+			}
+			if request, err := common.GetRequestObject(c); err == nil && request.Unmarshal("metadata", &metadata) == nil {
+				project = strings.TrimSpace(metadata.Project)
+			}
+		}
+		if project == "" {
+			c.Next()
+			return
+		}
+		if utf8.RuneCountInString(project) > maxProjectLength {
+			abortWithMessage(c, http.StatusBadRequest, "项目名称不能超过 64 个字符")
+			return
+		}
+		c.Request = c.Request.WithContext(helper.SetProject(c.Request.Context(), project))
+		c.Next()
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+)
+
+const (
+	remainingRequestsHeader = "X-Ratelimit-Remaining-Requests"
+	remainingTokensHeader   = "X-Ratelimit-Remaining-Tokens"
+	remainingQuotaHeader    = "X-Oneapi-Quota-Remaining"
+)
+
+// rateLimitHeaderWriter sets the headers when the response is written, so that they replace those
+// copied from the upstream, which are about the key of the channel rather than the user
+type rateLimitHeaderWriter struct {
+	gin.ResponseWriter
+	c   *gin.Context
+	set bool
+}
+
+func (w *rateLimitHeaderWriter) setHeaders() {
+	if w.set {
+		return
+	}
+	w.set = true
+	setRateLimitHeaders(w.c, w.ResponseWriter.Header())
+}
+
+func (w *rateLimitHeaderWriter) WriteHeaderNow() {
+	w.setHeaders()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *rateLimitHeaderWriter) Write(data []byte) (int, error) {
+	w.setHeaders()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *rateLimitHeaderWriter) WriteString(s string) (int, error) {
+	w.setHeaders()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *rateLimitHeaderWriter) Flush() {
+	w.setHeaders()
+	w.ResponseWriter.Flush()
+}
+
+// setRateLimitHeaders sets the requests left in the minute of the plan, the quota left to the user
+// and the tokens of the requested model it pays for at the prompt price
+func setRateLimitHeaders(c *gin.Context, header http.Header) {
+	header.Del(remainingRequestsHeader)
+	header.Del(remainingTokensHeader)
+	if remaining, ok := c.Get(ctxkey.RemainingRequests); ok {
+		header.Set(remainingRequestsHeader, strconv.Itoa(remaining.(int)))
+	}
+	quota, err := model.CacheGetUserQuota(c.Request.Context(), c.GetInt(ctxkey.Id))
+	if err != nil {
+		return
+	}
+	if tokenQuota, ok := c.Get(ctxkey.TokenQuota); ok && tokenQuota.(int64) < quota {
+		quota = tokenQuota.(int64)
+	}
+	if quota < 0 {
+		quota = 0
+	}
+	header.Set(remainingQuotaHeader, strconv.FormatInt(quota, 10))
+	modelName := c.GetString(ctxkey.RequestModel)
+	if modelName == "" {
+		return
+	}
+	ratio := billingratio.GetModelRatio(modelName, c.GetInt(ctxkey.Channel)) * billingratio.GetGroupModelRatio(c.GetString(ctxkey.Group), modelName)
+	if ratio <= 0 {
+		return
+	}
+	header.Set(remainingTokensHeader, strconv.FormatInt(int64(float64(quota)/ratio), 10))
+}
+
+// RateLimitHeaders returns the x-ratelimit-remaining-requests, x-ratelimit-remaining-tokens and
+// x-oneapi-quota-remaining headers on the relay responses, so that the clients can throttle themselves
+// as they do with OpenAI
+func RateLimitHeaders() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		c.Writer = &rateLimitHeaderWriter{ResponseWriter: c.Writer, c: c}
+		c.Next()
+	}
+}
@@ -1,31 +1,111 @@
 package middleware
 
 import (
+	"encoding/json"
 	"fmt"
-	"github.com/gin-gonic/gin"
-	"github.com/songquanpeng/one-api/common"
-	"github.com/songquanpeng/one-api/common/logger"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/sentry"
 )
 
+// maxPanicBodyLog bounds the request body logged with a panic, the bodies of vision requests are megabytes
+const maxPanicBodyLog = 4096
+
+// panicReport is the log record of a panic, with what the request had been resolved to when it happened
+type panicReport struct {
+	RequestId   string `json:"request_id"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	UserId      int    `json:"user_id,omitempty"`
+	TokenId     int    `json:"token_id,omitempty"`
+	Model       string `json:"model,omitempty"`
+	ChannelId   int    `json:"channel_id,omitempty"`
+	ChannelName string `json:"channel_name,omitempty"`
+	Panic       string `json:"panic"`
+	Stack       string `json:"stack"`
+	Body        string `json:"body,omitempty"`
+}
+
+func newPanicReport(c *gin.Context, err any) *panicReport {
+	report := &panicReport{
+		RequestId:   c.GetString(helper.RequestIdKey),
+		Method:      c.Request.Method,
+		Path:        c.Request.URL.Path,
+		UserId:      c.GetInt(ctxkey.Id),
+		TokenId:     c.GetInt(ctxkey.TokenId),
+		Model:       c.GetString(ctxkey.RequestModel),
+		ChannelId:   c.GetInt(ctxkey.ChannelId),
+		ChannelName: c.GetString(ctxkey.ChannelName),
+		Panic:       fmt.Sprintf("%v", err),
+		Stack:       string(debug.Stack()),
+	}
+	// only the body already read is logged, the request may have panicked while it was streamed
+	if body, ok := c.Get(ctxkey.KeyRequestBody); ok {
+		requestBody, _ := body.([]byte)
+		if len(requestBody) > maxPanicBodyLog {
+			requestBody = requestBody[:maxPanicBodyLog]
+		}
+		report.Body = string(requestBody)
+	}
+	return report
+}
+
+// reportPanic sends the panic to Sentry, tagged to be searched by model and channel
+func reportPanic(c *gin.Context, report *panicReport) {
+	if !sentry.Enabled() {
+		return
+	}
+	event := sentry.NewEvent(c.Request.Context(), sentry.LevelError, "")
+	// the recovery runs on top of the function which panicked, its frames and those of reportPanic are skipped
+	event.SetException("panic", report.Panic, 2)
+	event.Request = &sentry.Request{Method: report.Method, URL: report.Path}
+	event.SetTrace(c.Request.Header.Get("traceparent"))
+	event.Tags["path"] = report.Path
+	if report.Model != "" {
+		event.Tags["model"] = report.Model
+	}
+	if report.ChannelId != 0 {
+		event.Tags["channel_id"] = strconv.Itoa(report.ChannelId)
+		event.Tags["channel_name"] = report.ChannelName
+	}
+	event.Extra["user_id"] = report.UserId
+	event.Extra["token_id"] = report.TokenId
+	sentry.Capture(event)
+}
+
+// RelayPanicRecover logs the panics of the relay as one JSON record and answers with a generic error, which
+// does not leak the panic to the client. The quota pre-consumed for the request is refunded by the deferred
+// rollback of the relay, which runs as the panic unwinds
 func RelayPanicRecover() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				ctx := c.Request.Context()
-				logger.Errorf(ctx, fmt.Sprintf("panic detected: %v", err))
-				logger.Errorf(ctx, fmt.Sprintf("stacktrace from panic: %s", string(debug.Stack())))
-				logger.Errorf(ctx, fmt.Sprintf("request: %s %s", c.Request.Method, c.Request.URL.Path))
-				body, _ := common.GetRequestBody(c)
-				logger.Errorf(ctx, fmt.Sprintf("request body: %s", string(body)))
+				if err == http.ErrAbortHandler {
+					// the client is gone, net/http suppresses the stack trace of this panic
+					panic(err)
+				}
+				report := newPanicReport(c, err)
+				record, _ := json.Marshal(report)
+				logger.Errorf(c.Request.Context(), "panic detected: %s", record)
+				reportPanic(c, report)
+				c.Abort()
+				if c.Writer.Written() {
+					// the response has started, such as a stream, it can only be cut off
+					return
+				}
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error": gin.H{
-						"message": fmt.Sprintf("Panic detected, error: %v. Please submit an issue with the related log here: https://github.com/songquanpeng/one-api", err),
+						"message": helper.MessageWithRequestId("服务器内部错误，请联系管理员", report.RequestId),
 						"type":    "one_api_panic",
 					},
 				})
-				c.Abort()
 			}
 		}()
 		c.Next()
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/relay/responses"
+)
+
+// responsesWriter converts the chat completion written by the relay into the response of the Responses API, the
+// streams are converted line by line as they are written, the other responses when they are complete
+type responsesWriter struct {
+	gin.ResponseWriter
+	stream    *responses.Stream
+	status    int
+	body      bytes.Buffer
+	line      []byte
+	decided   bool
+	streaming bool
+}
+
+func (w *responsesWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	if w.status == http.StatusOK && strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		w.streaming = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responsesWriter) WriteHeader(code int) {
+	if w.streaming {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *responsesWriter) WriteHeaderNow() {
+	w.decide()
+	if w.streaming {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *responsesWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if !w.streaming {
+		return w.body.Write(data)
+	}
+	w.line = append(w.line, data...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimRight(string(w.line[:i]), "\r")
+		w.line = w.line[i+1:]
+		if strings.HasPrefix(line, ":") {
+			// the keep-alive comments are kept
+			_, _ = w.ResponseWriter.WriteString(line + "\n\n")
+		} else if chunk, ok := strings.CutPrefix(line, "data:"); ok {
+			_, _ = w.ResponseWriter.Write(w.stream.Convert(chunk))
+		}
+	}
+	return len(data), nil
+}
+
+func (w *responsesWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *responsesWriter) Flush() {
+	w.decide()
+	if w.streaming {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *responsesWriter) Status() int {
+	if w.streaming {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *responsesWriter) Size() int {
+	if w.streaming {
+		return w.ResponseWriter.Size()
+	}
+	return w.body.Len()
+}
+
+// Responses relays the requests of the Responses API as chat completions, so that its clients can use the channels
+// which only have the chat completions, the function calls and their outputs are converted both ways
+func Responses() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var request responses.Request
+		body, err := common.GetRequestBody(c)
+		if err == nil {
+			err = json.Unmarshal(body, &request)
+		}
+		if err != nil {
+			abortWithMessage(c, http.StatusBadRequest, "无效的请求："+err.Error())
+			return
+		}
+		chatRequest, err := responses.ToChatRequest(&request)
+		if err != nil {
+			abortWithMessage(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		body, err = json.Marshal(chatRequest)
+		if err != nil {
+			abortWithMessage(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		common.SetRequestBody(c, body)
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.URL.Path = "/v1/chat/completions"
+
+		writer := &responsesWriter{ResponseWriter: c.Writer, stream: responses.NewStream(&request), status: http.StatusOK}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		if writer.streaming {
+			_, _ = c.Writer.Write(writer.stream.Finish())
+			c.Writer.Flush()
+			return
+		}
+		if c.Writer.Written() {
+			return
+		}
+		body = writer.body.Bytes()
+		// the errors have the same format, the dry runs show the chat completion request
+		if writer.status == http.StatusOK && !c.GetBool(ctxkey.DryRun) && strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "application/json") {
+			if converted, err := responses.FromChatResponse(&request, body); err == nil {
+				body = converted
+			}
+		}
+		c.Writer.Header().Del("Content-Length")
+		c.Writer.WriteHeader(writer.status)
+		_, _ = c.Writer.Write(body)
+	}
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/conv"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/render"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+	"github.com/songquanpeng/one-api/relay/sandbox"
+)
+
+const sandboxHeader = "X-OneAPI-Sandbox"
+
+type sandboxCompletionChoice struct {
+	Index        int     `json:"index"`
+	Text         string  `json:"text"`
+	FinishReason *string `json:"finish_reason"`
+}
+
+type sandboxCompletionResponse struct {
+	Id      string                    `json:"id"`
+	Object  string                    `json:"object"`
+	Created int64                     `json:"created"`
+	Model   string                    `json:"model"`
+	Choices []sandboxCompletionChoice `json:"choices"`
+	Usage   *relaymodel.Usage         `json:"usage,omitempty"`
+}
+
+// sandboxPrompt is the text the reply is chosen for, the last user message of the chats
+func sandboxPrompt(request *relaymodel.GeneralOpenAIRequest, mode int) string {
+	if mode == relaymode.Completions {
+		return conv.AsString(request.Prompt)
+	}
+	for i := len(request.Messages) - 1; i >= 0; i-- {
+		if request.Messages[i].Role == "user" {
+			return request.Messages[i].StringContent()
+		}
+	}
+	return ""
+}
+
+func sandboxUsage(request *relaymodel.GeneralOpenAIRequest, mode int, prompt string, reply string) *relaymodel.Usage {
+	usage := &relaymodel.Usage{}
+	if mode == relaymode.ChatCompletions {
+		usage.PromptTokens = openai.CountTokenMessages(request.Messages, request.Model)
+	} else {
+		usage.PromptTokens = openai.CountTokenText(prompt, request.Model)
+	}
+	usage.CompletionTokens = openai.CountTokenText(reply, request.Model)
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+func sandboxEmbeddings(c *gin.Context, request *relaymodel.GeneralOpenAIRequest) {
+	response := openai.EmbeddingResponse{Object: "list", Model: request.Model}
+	for i, input := range request.ParseInput() {
+		embedding, err := sandbox.Embedding(input, request.Dimensions)
+		if err != nil {
+			abortWithMessage(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		response.Data = append(response.Data, openai.EmbeddingResponseItem{Object: "embedding", Index: i, Embedding: embedding})
+		response.PromptTokens += openai.CountTokenText(input, request.Model)
+	}
+	response.TotalTokens = response.PromptTokens
+	c.JSON(http.StatusOK, response)
+}
+
+func sandboxStream(c *gin.Context, request *relaymodel.GeneralOpenAIRequest, mode int, reply string, usage *relaymodel.Usage) {
+	common.SetEventStreamHeaders(c)
+	id := helper.GetResponseID(c)
+	created := helper.GetTimestamp()
+	stop := "stop"
+	chunks := sandbox.Chunks(reply)
+	for i, chunk := range chunks {
+		var finishReason *string
+		if i == len(chunks)-1 {
+			finishReason = &stop
+		}
+		var data any
+		if mode == relaymode.Completions {
+			data = sandboxCompletionResponse{Id: id, Object: "text_completion", Created: created, Model: request.Model,
+				Choices: []sandboxCompletionChoice{{Text: chunk, FinishReason: finishReason}}}
+		} else {
+			delta := relaymodel.Message{Content: chunk}
+			if i == 0 {
+				delta.Role = "assistant"
+			}
+			data = openai.ChatCompletionsStreamResponse{Id: id, Object: "chat.completion.chunk", Created: created, Model: request.Model,
+				Choices: []openai.ChatCompletionsStreamResponseChoice{{Delta: delta, FinishReason: finishReason}}}
+		}
+		_ = render.ObjectData(c, data)
+	}
+	if request.StreamOptions != nil && request.StreamOptions.IncludeUsage {
+		_ = render.ObjectData(c, openai.ChatCompletionsStreamResponse{Id: id, Object: "chat.completion.chunk", Created: created,
+			Model: request.Model, Choices: []openai.ChatCompletionsStreamResponseChoice{}, Usage: usage})
+	}
+	render.Done(c)
+}
+
+// Sandbox answers the requests of the sandbox tokens with the fixtures or the echo of the prompt, without selecting
+// a channel or consuming quota
+func Sandbox() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !c.GetBool(ctxkey.Sandbox) {
+			c.Next()
+			return
+		}
+		mode := relaymode.GetByPath(c.Request.URL.Path)
+		if mode != relaymode.ChatCompletions && mode != relaymode.Completions && mode != relaymode.Embeddings {
+			abortWithMessage(c, http.StatusBadRequest, "沙盒令牌仅支持 /v1/chat/completions、/v1/completions 与 /v1/embeddings 接口")
+			return
+		}
+		var request relaymodel.GeneralOpenAIRequest
+		if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+			abortWithMessage(c, http.StatusBadRequest, "无效的请求体："+err.Error())
+			return
+		}
+		c.Header(sandboxHeader, "true")
+		defer c.Abort()
+		if mode == relaymode.Embeddings {
+			sandboxEmbeddings(c, &request)
+			return
+		}
+		prompt := sandboxPrompt(&request, mode)
+		reply := sandbox.Reply(request.Model, prompt)
+		usage := sandboxUsage(&request, mode, prompt, reply)
+		if request.Stream {
+			sandboxStream(c, &request, mode, reply, usage)
+			return
+		}
+		id := helper.GetResponseID(c)
+		if mode == relaymode.Completions {
+			stop := "stop"
+			c.JSON(http.StatusOK, sandboxCompletionResponse{Id: id, Object: "text_completion", Created: helper.GetTimestamp(),
+				Model: request.Model, Choices: []sandboxCompletionChoice{{Text: reply, FinishReason: &stop}}, Usage: usage})
+			return
+		}
+		c.JSON(http.StatusOK, openai.TextResponse{Id: id, Object: "chat.completion", Created: helper.GetTimestamp(), Model: request.Model,
+			Choices: []openai.TextResponseChoice{{Message: relaymodel.Message{Role: "assistant", Content: reply}, FinishReason: "stop"}},
+			Usage:   *usage})
+	}
+}
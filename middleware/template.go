@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/model"
+)
+
+type templateRequest struct {
+	TemplateId string         `json:"template_id"`
+	Version    int            `json:"version"` // 0 means the latest version
+	Variables  map[string]any `json:"variables"`
+}
+
+// PromptTemplate renders the prompt template of the request into messages, and relays it as a chat completion request,
+// the other fields of the request are kept as is
+func PromptTemplate() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var templateReq templateRequest
+		request, err := common.GetRequestObject(c)
+		if err == nil {
+			err = request.Unmarshal("template_id", &templateReq.TemplateId)
+		}
+		if err == nil {
+			err = request.Unmarshal("version", &templateReq.Version)
+		}
+		if err == nil {
+			err = request.Unmarshal("variables", &templateReq.Variables)
+		}
+		if err != nil {
+			abortWithMessage(c, http.StatusBadRequest, "无效的请求："+err.Error())
+			return
+		}
+		template, err := model.GetPromptTemplate(templateReq.TemplateId, templateReq.Version)
+		if err != nil {
+			abortWithMessage(c, http.StatusNotFound, "模板不存在："+templateReq.TemplateId)
+			return
+		}
+		messages, err := template.Render(templateReq.Variables)
+		if err != nil {
+			abortWithMessage(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		request.Delete("template_id", "version", "variables")
+		if err = request.Set("messages", messages); err != nil {
+			abortWithMessage(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.URL.Path = "/v1/chat/completions"
+		ctx := helper.SetPromptTemplate(c.Request.Context(), fmt.Sprintf("%s v%d", template.Name, template.Version))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// transcript is the chat completion posted to the transcript webhook of the token, the request as relayed
+// and the answer, merged from the deltas for the streams
+type transcript struct {
+	Id        string            `json:"id"` // the request id, the same transcript may be posted again after a failure
+	Object    string            `json:"object"`
+	Created   int64             `json:"created"`
+	TokenName string            `json:"token_name"`
+	Model     string            `json:"model"`
+	Request   json.RawMessage   `json:"request"`
+	Answer    json.RawMessage   `json:"answer"`
+	Usage     *relaymodel.Usage `json:"usage"`
+}
+
+// Transcript queues the chat completions of the tokens with a transcript webhook to be posted to it once they
+// succeed, the completions answered without being relayed, such as the repeated ones, are left out. The playground,
+// the templates and the responses are relayed as chat completions, it goes after the middlewares rewriting the path
+func Transcript() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		webhook := c.GetString(ctxkey.TranscriptWebhook)
+		if webhook == "" || c.Request.URL.Path != "/v1/chat/completions" {
+			c.Next()
+			return
+		}
+		writer := &teeWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		value, ok := c.Get(ctxkey.RelayUsage)
+		if !ok || writer.Status() != http.StatusOK || c.GetBool(ctxkey.DryRun) {
+			return
+		}
+		usage, _ := value.(*relaymodel.Usage)
+		var stream bool
+		request, err := common.GetRequestObject(c)
+		if err == nil {
+			_ = request.Unmarshal("stream", &stream)
+		}
+		body, err := common.GetRequestBody(c)
+		if err != nil {
+			return
+		}
+		answer, ok := conversationAnswer(writer.body.Bytes(), stream)
+		if !ok {
+			return
+		}
+		data, err := json.Marshal(transcript{
+			Id:        c.GetString(helper.RequestIdKey),
+			Object:    "transcript",
+			Created:   helper.GetTimestamp(),
+			TokenName: c.GetString(ctxkey.TokenName),
+			Model:     c.GetString(ctxkey.RequestModel),
+			Request:   body,
+			Answer:    answer,
+			Usage:     usage,
+		})
+		if err != nil {
+			return
+		}
+		delivery := &model.TranscriptDelivery{
+			TokenId: c.GetInt(ctxkey.TokenId),
+			URL:     webhook,
+			Body:    string(data),
+		}
+		if err = delivery.Insert(); err != nil {
+			logger.Errorf(c.Request.Context(), "failed to queue the transcript: %s", err.Error())
+		}
+	}
+}
@@ -49,6 +49,15 @@ func getRequestModel(c *gin.Context) (string, error) {
 	return modelRequest.Model, nil
 }
 
+// setRequestBodyModel replaces the model in the JSON body of the request, for the relay to read it again
+func setRequestBodyModel(c *gin.Context, modelName string) error {
+	request, err := common.GetRequestObject(c)
+	if err != nil {
+		return err
+	}
+	return request.Set("model", modelName)
+}
+
 func isModelInList(modelName string, models string) bool {
 	modelList := strings.Split(models, ",")
 	for _, model := range modelList {
@@ -7,7 +7,6 @@ import (
 
 	"gorm.io/gorm"
 
-	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/utils"
 )
 
@@ -20,27 +19,55 @@ type Ability struct {
 }
 
 func GetRandomSatisfiedChannel(group string, model string, ignoreFirstPriority bool) (*Channel, error) {
-	ability := Ability{}
-	groupCol := "`group`"
-	trueVal := "1"
-	if common.UsingPostgreSQL {
-		groupCol = `"group"`
-		trueVal = "true"
+	r := getRouting(group, model)
+	if r.denied {
+		return nil, gorm.ErrRecordNotFound
+	}
+	// the channels in maintenance, over a spend cap or excluded by the routing rules are always excluded
+	unroutable := append(GetUnroutableChannelIds(), r.excluded...)
+	if len(r.preferred) > 0 {
+		if channel, err := getUnthrottledChannel(group, model, ignoreFirstPriority, r.preferred, unroutable); err == nil {
+			return channel, nil
+		}
+	}
+	return getUnthrottledChannel(group, model, ignoreFirstPriority, nil, unroutable)
+}
+
+// getUnthrottledChannel excludes the throttled channels only when there are others
+func getUnthrottledChannel(group string, model string, ignoreFirstPriority bool, includedIds []int, excludedIds []int) (*Channel, error) {
+	if throttled := GetThrottledChannelIds(); len(throttled) > 0 {
+		if channel, err := getRandomSatisfiedChannel(group, model, ignoreFirstPriority, includedIds, append(throttled, excludedIds...)); err == nil {
+			return channel, nil
+		}
 	}
+	return getRandomSatisfiedChannel(group, model, ignoreFirstPriority, includedIds, excludedIds)
+}
+
+// getRandomSatisfiedChannel chooses among includedIds unless it is empty
+func getRandomSatisfiedChannel(group string, model string, ignoreFirstPriority bool, includedIds []int, excludedIds []int) (*Channel, error) {
+	ability := Ability{}
+	groupCol := quoteCol("group")
+	trueVal := trueValue()
 
+	condition := groupCol + " = ? and model = ? and enabled = " + trueVal
+	args := []any{group, model}
+	if len(includedIds) > 0 {
+		condition += " and channel_id in ?"
+		args = append(args, includedIds)
+	}
+	if len(excludedIds) > 0 {
+		condition += " and channel_id not in ?"
+		args = append(args, excludedIds)
+	}
 	var err error = nil
 	var channelQuery *gorm.DB
 	if ignoreFirstPriority {
-		channelQuery = DB.Where(groupCol+" = ? and model = ? and enabled = "+trueVal, group, model)
+		channelQuery = DB.Where(condition, args...)
 	} else {
-		maxPrioritySubQuery := DB.Model(&Ability{}).Select("MAX(priority)").Where(groupCol+" = ? and model = ? and enabled = "+trueVal, group, model)
-		channelQuery = DB.Where(groupCol+" = ? and model = ? and enabled = "+trueVal+" and priority = (?)", group, model, maxPrioritySubQuery)
-	}
-	if common.UsingSQLite || common.UsingPostgreSQL {
-		err = channelQuery.Order("RANDOM()").First(&ability).Error
-	} else {
-		err = channelQuery.Order("RAND()").First(&ability).Error
+		maxPrioritySubQuery := DB.Model(&Ability{}).Select("MAX(priority)").Where(condition, args...)
+		channelQuery = DB.Where(condition+" and priority = (?)", append(args, maxPrioritySubQuery)...)
 	}
+	err = channelQuery.Order(randomFunc()).First(&ability).Error
 	if err != nil {
 		return nil, err
 	}
@@ -51,6 +78,10 @@ func GetRandomSatisfiedChannel(group string, model string, ignoreFirstPriority b
 }
 
 func (channel *Channel) AddAbilities() error {
+	return DB.Create(channel.abilities()).Error
+}
+
+func (channel *Channel) abilities() []Ability {
 	models_ := strings.Split(channel.Models, ",")
 	models_ = utils.DeDuplication(models_)
 	groups_ := strings.Split(channel.Group, ",")
@@ -67,7 +98,7 @@ func (channel *Channel) AddAbilities() error {
 			abilities = append(abilities, ability)
 		}
 	}
-	return DB.Create(&abilities).Error
+	return abilities
 }
 
 func (channel *Channel) DeleteAbilities() error {
@@ -96,12 +127,8 @@ func UpdateAbilityStatus(channelId int, status bool) error {
 }
 
 func GetGroupModels(ctx context.Context, group string) ([]string, error) {
-	groupCol := "`group`"
-	trueVal := "1"
-	if common.UsingPostgreSQL {
-		groupCol = `"group"`
-		trueVal = "true"
-	}
+	groupCol := quoteCol("group")
+	trueVal := trueValue()
 	var models []string
 	err := DB.Model(&Ability{}).Distinct("model").Where(groupCol+" = ? and enabled = "+trueVal, group).Pluck("model", &models).Error
 	if err != nil {
@@ -110,3 +137,25 @@ func GetGroupModels(ctx context.Context, group string) ([]string, error) {
 	sort.Strings(models)
 	return models, err
 }
+
+// GetGroupModelChannels returns the enabled channels which serve the model for the group, except those in maintenance
+// or over a spend cap, by the routing rules of the group
+func GetGroupModelChannels(group string, model string) ([]*Channel, error) {
+	groupCol := quoteCol("group")
+	condition := groupCol + " = ? and model = ? and enabled = " + trueValue()
+	args := []any{group, model}
+	if unroutable := GetUnroutableChannelIds(); len(unroutable) > 0 {
+		condition += " and channel_id not in ?"
+		args = append(args, unroutable)
+	}
+	var channelIds []int
+	if err := DB.Model(&Ability{}).Where(condition, args...).Pluck("channel_id", &channelIds).Error; err != nil {
+		return nil, err
+	}
+	var channels []*Channel
+	if len(channelIds) == 0 {
+		return channels, nil
+	}
+	err := DB.Omit("key").Where("id in ?", channelIds).Order("priority desc, id").Find(&channels).Error
+	return routeChannels(group, model, channels), err
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	AffRewardTypeSignup = 1
+	AffRewardTypeTopUp  = 2
+)
+
+// AffReward is a quota given to an inviter for an invited user
+type AffReward struct {
+	Id        int   `json:"id"`
+	InviterId int   `json:"inviter_id" gorm:"index"`
+	InviteeId int   `json:"invitee_id" gorm:"index"`
+	Type      int   `json:"type"`
+	Quota     int64 `json:"quota" gorm:"bigint;default:0"`
+	CreatedAt int64 `json:"created_at" gorm:"bigint"`
+}
+
+func recordAffReward(inviterId int, inviteeId int, rewardType int, quota int64) {
+	err := DB.Create(&AffReward{
+		InviterId: inviterId,
+		InviteeId: inviteeId,
+		Type:      rewardType,
+		Quota:     quota,
+		CreatedAt: helper.GetTimestamp(),
+	}).Error
+	if err != nil {
+		logger.SysError("failed to record aff reward: " + err.Error())
+	}
+}
+
+// isDuplicatedInvitee tells if an invited user has been registered from the same ip or device as the inviter
+// or another invited user before, such users get no invite reward when InviteRewardDedupeEnabled is set
+func isDuplicatedInvitee(user *User, inviterId int) bool {
+	if !config.InviteRewardDedupeEnabled || (user.RegisterIp == "" && user.RegisterDevice == "") {
+		return false
+	}
+	query := DB.Model(&User{}).Where("id <> ? and (inviter_id <> 0 or id = ?)", user.Id, inviterId)
+	if user.RegisterIp != "" && user.RegisterDevice != "" {
+		query = query.Where("register_ip = ? or register_device = ?", user.RegisterIp, user.RegisterDevice)
+	} else if user.RegisterIp != "" {
+		query = query.Where("register_ip = ?", user.RegisterIp)
+	} else {
+		query = query.Where("register_device = ?", user.RegisterDevice)
+	}
+	var count int64
+	query.Count(&count)
+	return count > 0
+}
+
+func rewardInvite(ctx context.Context, user *User, inviterId int) {
+	if isDuplicatedInvitee(user, inviterId) {
+		logger.Warnf(ctx, "user %d invited by user %d is registered from a used ip or device, invite reward skipped", user.Id, inviterId)
+		return
+	}
+	if config.QuotaForInvitee > 0 {
+		_ = ChangeUserQuota(user.Id, config.QuotaForInvitee, LedgerTypeReward, "使用邀请码赠送")
+		RecordLog(ctx, user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", common.LogQuota(config.QuotaForInvitee)))
+	}
+	if config.QuotaForInviter > 0 {
+		_ = ChangeUserQuota(inviterId, config.QuotaForInviter, LedgerTypeReward, "邀请用户赠送")
+		RecordLog(ctx, inviterId, LogTypeSystem, fmt.Sprintf("邀请用户赠送 %s", common.LogQuota(config.QuotaForInviter)))
+		recordAffReward(inviterId, user.Id, AffRewardTypeSignup, config.QuotaForInviter)
+	}
+}
+
+// RewardInviterForTopUp gives the inviter of the user a share of the topped up quota
+func RewardInviterForTopUp(ctx context.Context, userId int, quota int64) {
+	if config.InviterTopUpRewardRate <= 0 || quota <= 0 {
+		return
+	}
+	var user User
+	err := DB.Select("id", "inviter_id").Where("id = ?", userId).First(&user).Error
+	if err != nil || user.InviterId == 0 {
+		return
+	}
+	reward := int64(math.Floor(float64(quota) * config.InviterTopUpRewardRate))
+	if reward <= 0 {
+		return
+	}
+	if err = ChangeUserQuota(user.InviterId, reward, LedgerTypeReward, "邀请用户充值返利"); err != nil {
+		logger.Error(ctx, "failed to reward inviter: "+err.Error())
+		return
+	}
+	RecordLog(ctx, user.InviterId, LogTypeSystem, fmt.Sprintf("邀请用户充值返利 %s", common.LogQuota(reward)))
+	recordAffReward(user.InviterId, userId, AffRewardTypeTopUp, reward)
+}
+
+type AffStat struct {
+	InviteeCount      int64 `json:"invitee_count"`
+	SignupRewardQuota int64 `json:"signup_reward_quota"`
+	TopUpRewardQuota  int64 `json:"topup_reward_quota"`
+}
+
+func GetAffStat(inviterId int) (stat AffStat, err error) {
+	err = DB.Model(&User{}).Where("inviter_id = ?", inviterId).Count(&stat.InviteeCount).Error
+	if err != nil {
+		return stat, err
+	}
+	var rows []struct {
+		Type  int
+		Quota int64
+	}
+	err = DB.Model(&AffReward{}).Select("type, sum(quota) as quota").Where("inviter_id = ?", inviterId).Group("type").Scan(&rows).Error
+	for _, row := range rows {
+		switch row.Type {
+		case AffRewardTypeSignup:
+			stat.SignupRewardQuota = row.Quota
+		case AffRewardTypeTopUp:
+			stat.TopUpRewardQuota = row.Quota
+		}
+	}
+	return stat, err
+}
+
+func GetAffRewards(inviterId int, startIdx int, num int) (rewards []*AffReward, err error) {
+	err = DB.Where("inviter_id = ?", inviterId).Order("id desc").Limit(num).Offset(startIdx).Find(&rewards).Error
+	return rewards, err
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+func TestIsDuplicatedInvitee(t *testing.T) {
+	DB = openTestDB(t, "affiliate.db")
+	if err := migrateUp(DB, migrationScopeMain, migrations); err != nil {
+		t.Fatal(err)
+	}
+	config.InviteRewardDedupeEnabled = true
+	defer func() { config.InviteRewardDedupeEnabled = false }()
+	inviter := &User{Username: "inviter", Password: "12345678", AffCode: "a1", AccessToken: "t1", RegisterIp: "10.0.0.1", RegisterDevice: "d1"}
+	if err := DB.Create(inviter).Error; err != nil {
+		t.Fatal(err)
+	}
+	newInvitee := func(name string, ip string, device string) *User {
+		user := &User{Username: name, Password: "12345678", AffCode: name, AccessToken: name, InviterId: inviter.Id, RegisterIp: ip, RegisterDevice: device}
+		So(DB.Create(user).Error, ShouldBeNil)
+		return user
+	}
+
+	Convey("an invitee registered from the ip or the device of the inviter is a duplicate", t, func() {
+		So(isDuplicatedInvitee(newInvitee("self1", "10.0.0.1", "d2"), inviter.Id), ShouldBeTrue)
+		So(isDuplicatedInvitee(newInvitee("self2", "10.0.0.2", "d1"), inviter.Id), ShouldBeTrue)
+	})
+	Convey("an invitee registered from the ip of another invitee is a duplicate", t, func() {
+		So(isDuplicatedInvitee(newInvitee("other", "10.0.0.2", "d3"), inviter.Id), ShouldBeTrue)
+	})
+	Convey("an invitee registered from a new ip and device is not", t, func() {
+		So(isDuplicatedInvitee(newInvitee("fresh", "10.0.0.9", "d9"), inviter.Id), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,302 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/random"
+)
+
+// the assistants, threads and runs are emulated locally, a run is executed as chat completions
+// of the messages in the thread, see controller/assistant.go
+
+const (
+	RunStatusQueued         = "queued"
+	RunStatusInProgress     = "in_progress"
+	RunStatusRequiresAction = "requires_action"
+	RunStatusCancelled      = "cancelled"
+	RunStatusFailed         = "failed"
+	RunStatusCompleted      = "completed"
+	RunStatusExpired        = "expired"
+)
+
+// RunTimeout is how long a run may stay unfinished, the runs interrupted by a restart are expired after it
+const RunTimeout = 10 * 60
+
+type Assistant struct {
+	Id           int    `json:"id"`
+	AssistantId  string `json:"assistant_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Model        string `json:"model"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Instructions string `json:"instructions" gorm:"type:text"`
+	// Tools, ToolResources and Metadata are the JSON of the objects given by the client
+	Tools         string `json:"tools" gorm:"type:text"`
+	ToolResources string `json:"tool_resources" gorm:"type:text"`
+	Metadata      string `json:"metadata" gorm:"type:text"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint"`
+}
+
+type Thread struct {
+	Id            int    `json:"id"`
+	ThreadId      string `json:"thread_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	ToolResources string `json:"tool_resources" gorm:"type:text"`
+	Metadata      string `json:"metadata" gorm:"type:text"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint"`
+}
+
+type ThreadMessage struct {
+	Id          int    `json:"id"`
+	MessageId   string `json:"message_id" gorm:"type:varchar(64);uniqueIndex"`
+	ThreadId    string `json:"thread_id" gorm:"type:varchar(64);index"`
+	Role        string `json:"role" gorm:"type:varchar(32)"`
+	Content     string `json:"content" gorm:"type:text"`
+	AssistantId string `json:"assistant_id"`
+	RunId       string `json:"run_id"`
+	Metadata    string `json:"metadata" gorm:"type:text"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint"`
+}
+
+type Run struct {
+	Id           int    `json:"id"`
+	RunId        string `json:"run_id" gorm:"type:varchar(64);uniqueIndex"`
+	ThreadId     string `json:"thread_id" gorm:"type:varchar(64);index"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	TokenId      int    `json:"token_id"`
+	AssistantId  string `json:"assistant_id"`
+	Model        string `json:"model"`
+	Instructions string `json:"instructions" gorm:"type:text"`
+	Tools        string `json:"tools" gorm:"type:text"`
+	// VectorStoreIds are the comma separated vector stores of the assistant and the thread searched by file_search
+	VectorStoreIds string `json:"vector_store_ids" gorm:"type:text"`
+	Status         string `json:"status" gorm:"type:varchar(32)"`
+	// ToolMessages are the tool calls of the model and their outputs submitted by the client,
+	// they are sent after the messages of the thread until the run completes
+	ToolMessages     string `json:"tool_messages" gorm:"type:text"`
+	RequiredAction   string `json:"required_action" gorm:"type:text"`
+	LastError        string `json:"last_error" gorm:"type:text"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	Metadata         string `json:"metadata" gorm:"type:text"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint"`
+	StartedAt        int64  `json:"started_at" gorm:"bigint"`
+	CompletedAt      int64  `json:"completed_at" gorm:"bigint"`
+	CancelledAt      int64  `json:"cancelled_at" gorm:"bigint"`
+	FailedAt         int64  `json:"failed_at" gorm:"bigint"`
+}
+
+// ListParams is the cursor pagination of the assistants api, After and Before are object ids
+type ListParams struct {
+	Limit  int
+	Order  string
+	After  string
+	Before string
+}
+
+func NewObjectId(prefix string) string {
+	return prefix + random.GetUUID()[:24]
+}
+
+// paginate returns Limit+1 objects at most, so that the caller knows whether there are more
+func paginate(query *gorm.DB, table any, column string, params ListParams) *gorm.DB {
+	newer, older := ">", "<"
+	if params.Order == "asc" {
+		query = query.Order("id asc")
+		newer, older = older, newer
+	} else {
+		query = query.Order("id desc")
+	}
+	cursorId := func(objectId string) int {
+		var id int
+		DB.Model(table).Select("id").Where(column+" = ?", objectId).Scan(&id)
+		return id
+	}
+	if params.After != "" {
+		query = query.Where("id "+older+" ?", cursorId(params.After))
+	}
+	if params.Before != "" {
+		query = query.Where("id "+newer+" ?", cursorId(params.Before))
+	}
+	return query.Limit(params.Limit + 1)
+}
+
+func (assistant *Assistant) Insert() error {
+	assistant.AssistantId = NewObjectId("asst_")
+	assistant.CreatedAt = helper.GetTimestamp()
+	return DB.Create(assistant).Error
+}
+
+func (assistant *Assistant) Update() error {
+	return DB.Model(assistant).Select("model", "name", "description", "instructions", "tools", "tool_resources", "metadata").Updates(assistant).Error
+}
+
+func (assistant *Assistant) Delete() error {
+	return DB.Delete(assistant).Error
+}
+
+func GetUserAssistant(userId int, assistantId string) (*Assistant, error) {
+	assistant := &Assistant{}
+	err := DB.Where("user_id = ? and assistant_id = ?", userId, assistantId).First(assistant).Error
+	return assistant, err
+}
+
+func GetUserAssistants(userId int, params ListParams) (assistants []*Assistant, err error) {
+	query := paginate(DB.Where("user_id = ?", userId), &Assistant{}, "assistant_id", params)
+	err = query.Find(&assistants).Error
+	return assistants, err
+}
+
+// InsertThread creates the thread together with its initial messages
+func InsertThread(thread *Thread, messages []*ThreadMessage) error {
+	thread.ThreadId = NewObjectId("thread_")
+	thread.CreatedAt = helper.GetTimestamp()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(thread).Error; err != nil {
+			return err
+		}
+		for _, message := range messages {
+			if err := insertThreadMessage(tx, thread.ThreadId, message); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (thread *Thread) Update() error {
+	return DB.Model(thread).Select("tool_resources", "metadata").Updates(thread).Error
+}
+
+// Delete removes the thread with its messages and runs
+func (thread *Thread) Delete() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("thread_id = ?", thread.ThreadId).Delete(&ThreadMessage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("thread_id = ?", thread.ThreadId).Delete(&Run{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(thread).Error
+	})
+}
+
+func GetUserThread(userId int, threadId string) (*Thread, error) {
+	thread := &Thread{}
+	err := DB.Where("user_id = ? and thread_id = ?", userId, threadId).First(thread).Error
+	return thread, err
+}
+
+func insertThreadMessage(tx *gorm.DB, threadId string, message *ThreadMessage) error {
+	message.MessageId = NewObjectId("msg_")
+	message.ThreadId = threadId
+	message.CreatedAt = helper.GetTimestamp()
+	return tx.Create(message).Error
+}
+
+func InsertThreadMessage(threadId string, message *ThreadMessage) error {
+	return insertThreadMessage(DB, threadId, message)
+}
+
+func (message *ThreadMessage) Update() error {
+	return DB.Model(message).Select("metadata").Updates(message).Error
+}
+
+func DeleteThreadMessage(id int) error {
+	return DB.Delete(&ThreadMessage{}, id).Error
+}
+
+func GetThreadMessage(threadId string, messageId string) (*ThreadMessage, error) {
+	message := &ThreadMessage{}
+	err := DB.Where("thread_id = ? and message_id = ?", threadId, messageId).First(message).Error
+	return message, err
+}
+
+func GetThreadMessages(threadId string, runId string, params ListParams) (messages []*ThreadMessage, err error) {
+	query := DB.Where("thread_id = ?", threadId)
+	if runId != "" {
+		query = query.Where("run_id = ?", runId)
+	}
+	err = paginate(query, &ThreadMessage{}, "message_id", params).Find(&messages).Error
+	return messages, err
+}
+
+// GetAllThreadMessages returns the messages of the thread in the order they were added
+func GetAllThreadMessages(threadId string) (messages []*ThreadMessage, err error) {
+	err = DB.Where("thread_id = ?", threadId).Order("id asc").Find(&messages).Error
+	return messages, err
+}
+
+func (run *Run) Insert() error {
+	run.RunId = NewObjectId("run_")
+	run.Status = RunStatusQueued
+	run.CreatedAt = helper.GetTimestamp()
+	return DB.Create(run).Error
+}
+
+func (run *Run) UpdateMetadata() error {
+	return DB.Model(run).Select("metadata").Updates(run).Error
+}
+
+// Transit moves the run from the status from to the status of run with the other changed fields,
+// it fails if the run has been moved by someone else in the meantime, e.g. cancelled
+func (run *Run) Transit(from string, columns ...string) error {
+	columns = append(columns, "status")
+	result := DB.Model(&Run{}).Where("id = ? and status = ?", run.Id, from).Select(columns).Updates(run)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("run status has been changed")
+	}
+	return nil
+}
+
+func IsRunFinished(status string) bool {
+	return status == RunStatusCancelled || status == RunStatusFailed || status == RunStatusCompleted || status == RunStatusExpired
+}
+
+// expireIfStale expires the run which has not finished in time
+func (run *Run) expireIfStale() {
+	if IsRunFinished(run.Status) || helper.GetTimestamp()-run.CreatedAt < RunTimeout {
+		return
+	}
+	status := run.Status
+	run.Status = RunStatusExpired
+	if run.Transit(status) != nil {
+		_ = DB.First(run, run.Id).Error
+	}
+}
+
+func GetThreadRun(threadId string, runId string) (*Run, error) {
+	run := &Run{}
+	err := DB.Where("thread_id = ? and run_id = ?", threadId, runId).First(run).Error
+	if err == nil {
+		run.expireIfStale()
+	}
+	return run, err
+}
+
+func GetThreadRuns(threadId string, params ListParams) (runs []*Run, err error) {
+	err = paginate(DB.Where("thread_id = ?", threadId), &Run{}, "run_id", params).Find(&runs).Error
+	for _, run := range runs {
+		run.expireIfStale()
+	}
+	return runs, err
+}
+
+// HasActiveRun reports whether a run of the thread is not finished, only one run is allowed at a time
+func HasActiveRun(threadId string) bool {
+	var runs []*Run
+	DB.Where("thread_id = ? and status in ?", threadId, []string{RunStatusQueued, RunStatusInProgress, RunStatusRequiresAction}).Find(&runs)
+	for _, run := range runs {
+		run.expireIfStale()
+		if !IsRunFinished(run.Status) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	AsyncTaskStatusQueued     = "queued"
+	AsyncTaskStatusInProgress = "in_progress"
+	AsyncTaskStatusCompleted  = "completed"
+	AsyncTaskStatusFailed     = "failed"
+)
+
+const (
+	AsyncCallbackStatusPending   = "pending"
+	AsyncCallbackStatusDelivered = "delivered"
+	AsyncCallbackStatusFailed    = "failed"
+)
+
+// AsyncTaskTimeout is how long a task may stay in progress, the tasks of a node stopped while executing them
+// are queued again after it
+const AsyncTaskTimeout = 30 * 60
+
+// asyncTaskRetentionDays is how long the finished tasks are kept for their results to be queried
+const asyncTaskRetentionDays = 7
+
+// AsyncTask is a relay request queued to be executed in the background, its result is posted to the callback url
+type AsyncTask struct {
+	Id          int    `json:"-"`
+	TaskId      string `json:"id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId      int    `json:"-" gorm:"index"`
+	TokenId     int    `json:"-"`
+	Path        string `json:"path" gorm:"type:varchar(64)"`
+	Model       string `json:"model"`
+	Body        string `json:"-" gorm:"type:text"`
+	CallbackURL string `json:"callback_url" gorm:"type:text"`
+	Status      string `json:"status" gorm:"type:varchar(32);index"`
+	Attempts    int    `json:"attempts"`
+	// StatusCode and Response are the status and body of the response of the relay
+	StatusCode       int    `json:"status_code"`
+	Response         string `json:"-" gorm:"type:text"`
+	CallbackStatus   string `json:"callback_status" gorm:"type:varchar(32);index"`
+	CallbackAttempts int    `json:"callback_attempts"`
+	// NextAttemptAt is when the queued task is executed, or when its callback is posted once it is finished
+	NextAttemptAt int64 `json:"-" gorm:"bigint;index"`
+	CreatedAt     int64 `json:"created_at" gorm:"bigint;index"`
+	StartedAt     int64 `json:"started_at" gorm:"bigint"`
+	FinishedAt    int64 `json:"finished_at" gorm:"bigint"`
+	// TTL is the seconds the result of a deferred completion is kept once it is finished, 0 for the async tasks
+	// kept asyncTaskRetentionDays
+	TTL int64 `json:"-" gorm:"bigint;default:0"`
+}
+
+func (task *AsyncTask) Insert() error {
+	if task.TaskId == "" {
+		task.TaskId = NewObjectId("task_")
+	}
+	task.Status = AsyncTaskStatusQueued
+	task.CreatedAt = helper.GetTimestamp()
+	task.NextAttemptAt = task.CreatedAt
+	return DB.Create(task).Error
+}
+
+// Transit moves the task from the status from to the status of task with the other changed fields,
+// it fails if the status has been changed meanwhile
+func (task *AsyncTask) Transit(from string, columns ...string) error {
+	columns = append(columns, "status")
+	result := DB.Model(&AsyncTask{}).Where("id = ? and status = ?", task.Id, from).Select(columns).Updates(task)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("task status has been changed")
+	}
+	return nil
+}
+
+func IsAsyncTaskFinished(status string) bool {
+	return status == AsyncTaskStatusCompleted || status == AsyncTaskStatusFailed
+}
+
+// IsExpired tells whether the result of the deferred completion is past its TTL, it is deleted soon after
+func (task *AsyncTask) IsExpired() bool {
+	return task.TTL > 0 && IsAsyncTaskFinished(task.Status) && task.FinishedAt+task.TTL < helper.GetTimestamp()
+}
+
+// UpdateCallback records the attempt to post the result of the finished task to its callback url
+func (task *AsyncTask) UpdateCallback() error {
+	return DB.Model(task).Select("callback_status", "callback_attempts", "next_attempt_at").Updates(task).Error
+}
+
+func GetUserAsyncTask(userId int, taskId string) (*AsyncTask, error) {
+	task := &AsyncTask{}
+	err := DB.Where("user_id = ? and task_id = ?", userId, taskId).First(task).Error
+	return task, err
+}
+
+// GetDueAsyncTasks returns the queued tasks whose time to be executed has come, the oldest first
+func GetDueAsyncTasks(limit int) (tasks []*AsyncTask, err error) {
+	err = DB.Where("status = ? and next_attempt_at <= ?", AsyncTaskStatusQueued, helper.GetTimestamp()).
+		Order("id asc").Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
+
+// GetDueAsyncCallbacks returns the finished tasks whose callback is to be posted now
+func GetDueAsyncCallbacks(limit int) (tasks []*AsyncTask, err error) {
+	err = DB.Where("status in ? and callback_status = ? and next_attempt_at <= ?",
+		[]string{AsyncTaskStatusCompleted, AsyncTaskStatusFailed}, AsyncCallbackStatusPending, helper.GetTimestamp()).
+		Order("id asc").Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
+
+// RequeueStaleAsyncTasks queues again the tasks left in progress for longer than AsyncTaskTimeout
+func RequeueStaleAsyncTasks() error {
+	now := helper.GetTimestamp()
+	return DB.Model(&AsyncTask{}).Where("status = ? and started_at < ?", AsyncTaskStatusInProgress, now-AsyncTaskTimeout).
+		Updates(map[string]any{"status": AsyncTaskStatusQueued, "next_attempt_at": now}).Error
+}
+
+func AutomaticallyDeleteOldAsyncTasks() {
+	for {
+		if IsLeader() {
+			now := helper.GetTimestamp()
+			finished := []string{AsyncTaskStatusCompleted, AsyncTaskStatusFailed}
+			err := DB.Where("status in ? and ttl = 0 and finished_at < ?", finished, now-asyncTaskRetentionDays*24*3600).
+				Delete(&AsyncTask{}).Error
+			if err != nil {
+				logger.SysError("failed to delete old async tasks: " + err.Error())
+			}
+			err = DB.Where("status in ? and ttl > 0 and finished_at + ttl < ?", finished, now).Delete(&AsyncTask{}).Error
+			if err != nil {
+				logger.SysError("failed to delete expired deferred completions: " + err.Error())
+			}
+		}
+		time.Sleep(time.Hour)
+	}
+}
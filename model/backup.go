@@ -0,0 +1,292 @@
+package model
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/common/storage"
+)
+
+// a backup holds the options, channels, tokens and users, which is what it takes to bring a deployment back,
+// it is gzipped JSON encrypted with BACKUP_PASSWORD since the keys of the channels are part of it
+
+const backupMagic = "ONEAPI-BACKUP-1\n"
+
+const restoreBatchSize = 500
+
+type Backup struct {
+	Id        int    `json:"id"`
+	Name      string `json:"name" gorm:"type:varchar(64);uniqueIndex"`
+	Storage   string `json:"storage" gorm:"type:varchar(16)"`
+	Size      int64  `json:"size" gorm:"bigint"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+// backupUser and backupToken keep the fields hidden from the JSON of the API
+type backupUser struct {
+	User
+	RegisterIp            string `json:"register_ip"`
+	RegisterDevice        string `json:"register_device"`
+	DisabledNotifications string `json:"disabled_notifications"`
+	EnabledNotifications  string `json:"enabled_notifications"`
+}
+
+type backupToken struct {
+	Token
+	ExpiryReminded bool `json:"expiry_reminded"`
+}
+
+type backupContent struct {
+	Version   string         `json:"version"`
+	CreatedAt int64          `json:"created_at"`
+	Options   []*Option      `json:"options"`
+	Channels  []*Channel     `json:"channels"`
+	Tokens    []*backupToken `json:"tokens"`
+	Users     []*backupUser  `json:"users"`
+}
+
+func GetBackups() ([]*Backup, error) {
+	var backups []*Backup
+	err := DB.Order("id desc").Find(&backups).Error
+	return backups, err
+}
+
+func GetBackupById(id int) (*Backup, error) {
+	backup := Backup{}
+	err := DB.First(&backup, "id = ?", id).Error
+	return &backup, err
+}
+
+// dumpBackup reads the rows in the trash as well, so that they can still be restored after a restore
+func dumpBackup() (*backupContent, error) {
+	content := backupContent{Version: common.Version, CreatedAt: helper.GetTimestamp()}
+	if err := DB.Find(&content.Options).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Unscoped().Find(&content.Channels).Error; err != nil {
+		return nil, err
+	}
+	var tokens []*Token
+	if err := DB.Unscoped().Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		content.Tokens = append(content.Tokens, &backupToken{Token: *token, ExpiryReminded: token.ExpiryReminded})
+	}
+	var users []*User
+	if err := DB.Unscoped().Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		content.Users = append(content.Users, &backupUser{
+			User:                  *user,
+			RegisterIp:            user.RegisterIp,
+			RegisterDevice:        user.RegisterDevice,
+			DisabledNotifications: user.DisabledNotifications,
+			EnabledNotifications:  user.EnabledNotifications,
+		})
+	}
+	return &content, nil
+}
+
+func encodeBackup(content *backupContent, password string) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(writer).Encode(content); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	data, err := common.EncryptWithPassword(buf.Bytes(), password)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(backupMagic), data...), nil
+}
+
+func decodeBackup(data []byte, password string) (*backupContent, error) {
+	if !bytes.HasPrefix(data, []byte(backupMagic)) {
+		return nil, errors.New("not a backup of one api")
+	}
+	data, err := common.DecryptWithPassword(data[len(backupMagic):], password)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var content backupContent
+	if err = json.NewDecoder(reader).Decode(&content); err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+// CreateBackup writes a backup to BACKUP_STORAGE, the oldest ones beyond BACKUP_RETENTION are deleted
+func CreateBackup(ctx context.Context) (*Backup, error) {
+	if config.BackupPassword == "" {
+		return nil, errors.New("未设置 BACKUP_PASSWORD，无法备份")
+	}
+	backend := storage.GetBackupBackend(config.BackupStorage)
+	if backend == nil {
+		return nil, fmt.Errorf("不支持的备份存储：%s", config.BackupStorage)
+	}
+	content, err := dumpBackup()
+	if err != nil {
+		return nil, err
+	}
+	data, err := encodeBackup(content, config.BackupPassword)
+	if err != nil {
+		return nil, err
+	}
+	backup := Backup{
+		Name:      fmt.Sprintf("one-api-%s-%s.backup", time.Now().Format("20060102-150405"), random.GetRandomString(6)),
+		Storage:   config.BackupStorage,
+		Size:      int64(len(data)),
+		CreatedAt: content.CreatedAt,
+	}
+	if err = backend.Put(ctx, backup.Name, bytes.NewReader(data), backup.Size); err != nil {
+		return nil, fmt.Errorf("failed to save backup: %w", err)
+	}
+	if err = DB.Create(&backup).Error; err != nil {
+		return nil, err
+	}
+	if config.BackupRetention > 0 {
+		var expired []*Backup
+		if err = DB.Order("id desc").Offset(config.BackupRetention).Find(&expired).Error; err != nil {
+			return &backup, err
+		}
+		for _, old := range expired {
+			if err = DeleteBackup(ctx, old); err != nil {
+				logger.Errorf(ctx, "failed to delete backup %s: %s", old.Name, err.Error())
+			}
+		}
+	}
+	return &backup, nil
+}
+
+func ReadBackup(ctx context.Context, storageName string, name string) ([]byte, error) {
+	backend := storage.GetBackupBackend(storageName)
+	if backend == nil {
+		return nil, fmt.Errorf("不支持的备份存储：%s", storageName)
+	}
+	reader, err := backend.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func DeleteBackup(ctx context.Context, backup *Backup) error {
+	if backend := storage.GetBackupBackend(backup.Storage); backend != nil {
+		if err := backend.Delete(ctx, backup.Name); err != nil {
+			return err
+		}
+	}
+	return DB.Delete(backup).Error
+}
+
+func createInBatches[T any](tx *gorm.DB, rows []T) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	// select all fields, otherwise zero values would be replaced by the column defaults
+	return tx.Select("*").CreateInBatches(rows, restoreBatchSize).Error
+}
+
+// RestoreBackup replaces the options, channels, tokens and users with the ones of the backup,
+// the abilities are built again from the channels
+func RestoreBackup(data []byte, password string) error {
+	content, err := decodeBackup(data, password)
+	if err != nil {
+		return err
+	}
+	channels := content.Channels
+	tokens := make([]*Token, 0, len(content.Tokens))
+	for _, token := range content.Tokens {
+		token.Token.ExpiryReminded = token.ExpiryReminded
+		tokens = append(tokens, &token.Token)
+	}
+	users := make([]*User, 0, len(content.Users))
+	for _, user := range content.Users {
+		user.User.RegisterIp = user.RegisterIp
+		user.User.RegisterDevice = user.RegisterDevice
+		user.User.DisabledNotifications = user.DisabledNotifications
+		user.User.EnabledNotifications = user.EnabledNotifications
+		users = append(users, &user.User)
+	}
+	var abilities []Ability
+	for _, channel := range channels {
+		if !channel.DeletedAt.Valid {
+			abilities = append(abilities, channel.abilities()...)
+		}
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		for _, table := range []any{&Ability{}, &Option{}, &Channel{}, &Token{}, &User{}, &QuotaLedgerEntry{}} {
+			if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(table).Error; err != nil {
+				return err
+			}
+		}
+		if err := createInBatches(tx, content.Options); err != nil {
+			return err
+		}
+		if err := createInBatches(tx, channels); err != nil {
+			return err
+		}
+		if err := createInBatches(tx, abilities); err != nil {
+			return err
+		}
+		if err := createInBatches(tx, tokens); err != nil {
+			return err
+		}
+		if err := createInBatches(tx, users); err != nil {
+			return err
+		}
+		// the history of the quota is not in the backup, the ledgers start again from the restored quota
+		return openQuotaLedgers(tx)
+	})
+	if err != nil {
+		return err
+	}
+	if common.UsingPostgreSQL {
+		for _, table := range []string{"users", "tokens", "channels"} {
+			if err = resetPostgreSQLSequence(DB, table); err != nil {
+				return fmt.Errorf("failed to reset sequence of table %s: %w", table, err)
+			}
+		}
+	}
+	logger.SysLogf("restored %d options, %d channels, %d tokens and %d users from the backup created at %s",
+		len(content.Options), len(channels), len(tokens), len(users), time.Unix(content.CreatedAt, 0).Format(time.RFC3339))
+	return nil
+}
+
+func AutomaticallyBackup(frequency int) {
+	for {
+		time.Sleep(time.Duration(frequency) * time.Minute)
+		if !IsLeader() {
+			continue
+		}
+		backup, err := CreateBackup(context.Background())
+		if err != nil {
+			logger.SysError("failed to create backup: " + err.Error())
+			continue
+		}
+		logger.SysLogf("backup %s created, %d bytes", backup.Name, backup.Size)
+	}
+}
@@ -0,0 +1,185 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/message"
+	"github.com/songquanpeng/one-api/common/random"
+)
+
+// BotChat is a chat of a bot platform bound to a user, the alerts of the user are pushed to it
+// and the commands sent in it are run as the user
+type BotChat struct {
+	Id        int    `json:"id"`
+	Platform  string `json:"platform" gorm:"type:varchar(16);uniqueIndex:idx_bot_chat"`
+	ChatId    string `json:"chat_id" gorm:"type:varchar(128);uniqueIndex:idx_bot_chat"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	Model     string `json:"model" gorm:"type:varchar(64);default:''"` // the model to chat with, BotChatModel if empty
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+const botBindCodeTTL = 10 * time.Minute
+
+var botBindCodesLock sync.Mutex
+var botBindCodes = map[string]botBindCode{}
+
+type botBindCode struct {
+	userId    int
+	expiresAt time.Time
+}
+
+// GenerateBotBindCode returns a one-time code, sending "/bind <code>" in a chat binds the chat to the user
+func GenerateBotBindCode(userId int) (string, error) {
+	code := random.GetRandomString(8)
+	if common.RedisEnabled {
+		return code, common.RedisSet("bot_bind_code:"+code, strconv.Itoa(userId), botBindCodeTTL)
+	}
+	botBindCodesLock.Lock()
+	defer botBindCodesLock.Unlock()
+	now := time.Now()
+	for k, v := range botBindCodes {
+		if now.After(v.expiresAt) {
+			delete(botBindCodes, k)
+		}
+	}
+	botBindCodes[code] = botBindCode{userId: userId, expiresAt: now.Add(botBindCodeTTL)}
+	return code, nil
+}
+
+func consumeBotBindCode(code string) (int, bool) {
+	if common.RedisEnabled {
+		value, err := common.RedisGet("bot_bind_code:" + code)
+		if err != nil {
+			return 0, false
+		}
+		_ = common.RedisDel("bot_bind_code:" + code)
+		userId, err := strconv.Atoi(value)
+		return userId, err == nil
+	}
+	botBindCodesLock.Lock()
+	defer botBindCodesLock.Unlock()
+	bindCode, ok := botBindCodes[code]
+	delete(botBindCodes, code)
+	if !ok || time.Now().After(bindCode.expiresAt) {
+		return 0, false
+	}
+	return bindCode.userId, true
+}
+
+// BindBotChat binds the chat to the owner of the code, a chat bound before is taken over
+func BindBotChat(platform string, chatId string, code string) (*User, error) {
+	userId, ok := consumeBotBindCode(code)
+	if !ok {
+		return nil, errors.New("绑定码无效或已过期")
+	}
+	user, err := GetUserById(userId, false)
+	if err != nil {
+		return nil, err
+	}
+	if !config.BotChatEnabled && user.Role < RoleAdminUser {
+		return nil, errors.New("管理员未开启机器人对话")
+	}
+	err = DB.Where("platform = ? and chat_id = ?", platform, chatId).Delete(&BotChat{}).Error
+	if err != nil {
+		return nil, err
+	}
+	err = DB.Create(&BotChat{
+		Platform:  platform,
+		ChatId:    chatId,
+		UserId:    userId,
+		CreatedAt: helper.GetTimestamp(),
+	}).Error
+	return user, err
+}
+
+func UnbindBotChat(platform string, chatId string) error {
+	return DB.Where("platform = ? and chat_id = ?", platform, chatId).Delete(&BotChat{}).Error
+}
+
+func GetBotChat(platform string, chatId string) (*BotChat, error) {
+	var chat BotChat
+	err := DB.Where("platform = ? and chat_id = ?", platform, chatId).First(&chat).Error
+	return &chat, err
+}
+
+func UpdateBotChatModel(id int, model string) error {
+	return DB.Model(&BotChat{}).Where("id = ?", id).Update("model", model).Error
+}
+
+// botTokenExternalId marks the token used by the chats of the user, see GetBotToken
+const botTokenExternalId = "bot_chat"
+
+// GetBotToken returns the token billed for the chats of the user, it is created on the first use
+func GetBotToken(userId int) (*Token, error) {
+	token, err := GetTokenByExternalId(userId, botTokenExternalId)
+	if err == nil {
+		return token, nil
+	}
+	token = &Token{
+		UserId:         userId,
+		Name:           "bot",
+		Key:            random.GenerateKey(),
+		CreatedTime:    helper.GetTimestamp(),
+		AccessedTime:   helper.GetTimestamp(),
+		ExpiredTime:    -1,
+		RemainQuota:    -1,
+		UnlimitedQuota: true,
+		ExternalId:     botTokenExternalId,
+	}
+	if err = token.Insert(); err != nil {
+		// a concurrent chat created it first, the unique index rejected this one
+		if existing, findErr := GetTokenByExternalId(userId, botTokenExternalId); findErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+func GetUserBotChats(userId int) (chats []*BotChat, err error) {
+	err = DB.Where("user_id = ?", userId).Order("id desc").Find(&chats).Error
+	return chats, err
+}
+
+func DeleteUserBotChat(id int, userId int) error {
+	return DB.Where("id = ? and user_id = ?", id, userId).Delete(&BotChat{}).Error
+}
+
+func sendToBotChats(chats []*BotChat, text string) {
+	for _, chat := range chats {
+		if err := message.SendBotMessage(chat.Platform, chat.ChatId, text); err != nil {
+			logger.SysError(fmt.Sprintf("failed to send %s bot message to chat %s: %s", chat.Platform, chat.ChatId, err.Error()))
+		}
+	}
+}
+
+// NotifyUserBotChats pushes the text to the chats bound to the user
+func NotifyUserBotChats(userId int, text string) {
+	chats, err := GetUserBotChats(userId)
+	if err != nil {
+		logger.SysError("failed to get bot chats: " + err.Error())
+		return
+	}
+	sendToBotChats(chats, text)
+}
+
+// NotifyAdminBotChats pushes the text to the chats bound to the enabled admins
+func NotifyAdminBotChats(text string) {
+	var chats []*BotChat
+	err := DB.Model(&BotChat{}).Joins("join users on users.id = bot_chats.user_id").
+		Where("users.role >= ? and users.status = ? and users.deleted_at is null", RoleAdminUser, UserStatusEnabled).
+		Find(&chats).Error
+	if err != nil {
+		logger.SysError("failed to get bot chats: " + err.Error())
+		return
+	}
+	sendToBotChats(chats, text)
+}
@@ -26,10 +26,7 @@ var (
 )
 
 func CacheGetTokenByKey(key string) (*Token, error) {
-	keyCol := "`key`"
-	if common.UsingPostgreSQL {
-		keyCol = `"key"`
-	}
+	keyCol := quoteCol("key")
 	var token Token
 	if !common.RedisEnabled {
 		err := DB.Where(keyCol+" = ?", key).First(&token).Error
@@ -73,6 +70,79 @@ func CacheGetUserGroup(id int) (group string, err error) {
 	return group, err
 }
 
+// CacheGetUserGroups returns the group of the user followed by its extra groups
+func CacheGetUserGroups(id int) ([]string, error) {
+	if !common.RedisEnabled {
+		return GetUserGroups(id)
+	}
+	groups, err := common.RedisGet(fmt.Sprintf("user_groups:%d", id))
+	if err == nil {
+		return strings.Split(groups, ","), nil
+	}
+	userGroups, err := GetUserGroups(id)
+	if err != nil {
+		return nil, err
+	}
+	err = common.RedisSet(fmt.Sprintf("user_groups:%d", id), strings.Join(userGroups, ","), time.Duration(UserId2GroupCacheSeconds)*time.Second)
+	if err != nil {
+		logger.SysError("Redis set user groups error: " + err.Error())
+	}
+	return userGroups, nil
+}
+
+func cacheGetUserPlanId(id int) (int, error) {
+	if !common.RedisEnabled {
+		return GetUserPlanId(id)
+	}
+	planId, err := common.RedisGet(fmt.Sprintf("user_plan:%d", id))
+	if err != nil {
+		planId, err := GetUserPlanId(id)
+		if err != nil {
+			return 0, err
+		}
+		err = common.RedisSet(fmt.Sprintf("user_plan:%d", id), strconv.Itoa(planId), time.Duration(UserId2GroupCacheSeconds)*time.Second)
+		if err != nil {
+			logger.SysError("Redis set user plan error: " + err.Error())
+		}
+		return planId, nil
+	}
+	return strconv.Atoi(planId)
+}
+
+func cacheGetPlan(id int) (*Plan, error) {
+	if !common.RedisEnabled {
+		return GetPlanById(id)
+	}
+	planObjectString, err := common.RedisGet(fmt.Sprintf("plan:%d", id))
+	if err != nil {
+		plan, err := GetPlanById(id)
+		if err != nil {
+			return nil, err
+		}
+		jsonBytes, err := json.Marshal(plan)
+		if err != nil {
+			return nil, err
+		}
+		err = common.RedisSet(fmt.Sprintf("plan:%d", id), string(jsonBytes), time.Duration(UserId2GroupCacheSeconds)*time.Second)
+		if err != nil {
+			logger.SysError("Redis set plan error: " + err.Error())
+		}
+		return plan, nil
+	}
+	var plan Plan
+	err = json.Unmarshal([]byte(planObjectString), &plan)
+	return &plan, err
+}
+
+// CacheGetUserPlan returns the plan of the user, nil if the user has no plan
+func CacheGetUserPlan(id int) (*Plan, error) {
+	planId, err := cacheGetUserPlanId(id)
+	if err != nil || planId == 0 {
+		return nil, err
+	}
+	return cacheGetPlan(planId)
+}
+
 func fetchAndUpdateUserQuota(ctx context.Context, id int) (quota int64, err error) {
 	quota, err = GetUserQuota(id)
 	if err != nil {
@@ -234,6 +304,10 @@ func CacheGetRandomSatisfiedChannel(group string, model string, ignoreFirstPrior
 	if len(channels) == 0 {
 		return nil, errors.New("channel not found")
 	}
+	channels = excludeThrottledChannels(routeChannels(group, model, excludeCappedChannels(excludeMaintenanceChannels(channels))))
+	if len(channels) == 0 {
+		return nil, errors.New("channel not found")
+	}
 	endIdx := len(channels)
 	// choose by priority
 	firstChannel := channels[0]
@@ -253,3 +327,81 @@ func CacheGetRandomSatisfiedChannel(group string, model string, ignoreFirstPrior
 	}
 	return channels[idx], nil
 }
+
+const cacheInvalidationChannel = "one-api:cache_invalidation"
+
+const (
+	cacheTargetChannels = "channels"
+	cacheTargetOptions  = "options"
+)
+
+// publishCacheInvalidation tells the other replicas to reload their in-memory cache of target,
+// the message carries the node id so that a node can ignore its own messages
+func publishCacheInvalidation(target string) {
+	if !common.RedisEnabled {
+		return
+	}
+	err := common.RedisPublish(cacheInvalidationChannel, target+":"+config.NodeId)
+	if err != nil {
+		logger.SysError("Redis publish cache invalidation error: " + err.Error())
+	}
+}
+
+func SubscribeCacheInvalidation() {
+	pubsub := common.RedisSubscribe(cacheInvalidationChannel)
+	defer pubsub.Close()
+	for msg := range pubsub.Channel() {
+		target, nodeId, _ := strings.Cut(msg.Payload, ":")
+		if nodeId == config.NodeId {
+			continue
+		}
+		switch target {
+		case cacheTargetChannels:
+			if config.MemoryCacheEnabled {
+				InitChannelCache()
+			}
+		case cacheTargetOptions:
+			logger.SysLog("syncing options from database")
+			loadOptionsFromDatabase()
+		}
+	}
+}
+
+func cacheInvalidateChannels() {
+	if config.MemoryCacheEnabled {
+		InitChannelCache()
+	}
+	publishCacheInvalidation(cacheTargetChannels)
+}
+
+func CacheInvalidateToken(key string) {
+	if !common.RedisEnabled {
+		return
+	}
+	err := common.RedisDel(fmt.Sprintf("token:%s", key))
+	if err != nil {
+		logger.SysError("Redis del token error: " + err.Error())
+	}
+}
+
+func CacheInvalidateUser(id int) {
+	if !common.RedisEnabled {
+		return
+	}
+	for _, key := range []string{"user_group:%d", "user_groups:%d", "user_quota:%d", "user_enabled:%d", "user_plan:%d", "user_reseller:%d", "reseller_markup:%d"} {
+		err := common.RedisDel(fmt.Sprintf(key, id))
+		if err != nil {
+			logger.SysError("Redis del user cache error: " + err.Error())
+		}
+	}
+}
+
+func cacheInvalidatePlan(id int) {
+	if !common.RedisEnabled {
+		return
+	}
+	err := common.RedisDel(fmt.Sprintf("plan:%d", id))
+	if err != nil {
+		logger.SysError("Redis del plan error: " + err.Error())
+	}
+}
@@ -2,11 +2,14 @@ package model
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/filter"
 	"gorm.io/gorm"
 )
 
@@ -38,6 +41,9 @@ type Channel struct {
 	Priority           *int64  `json:"priority" gorm:"bigint;default:0"`
 	Config             string  `json:"config"`
 	SystemPrompt       *string `json:"system_prompt" gorm:"type:text"`
+	ExternalId         string  `json:"external_id" gorm:"type:varchar(64);index;default:''"` // set by declarative management tools
+	// DeletedAt keeps the deleted channels in the trash, to be restored or purged
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
 
 type ChannelConfig struct {
@@ -50,6 +56,39 @@ type ChannelConfig struct {
 	Plugin            string `json:"plugin,omitempty"`
 	VertexAIProjectID string `json:"vertex_ai_project_id,omitempty"`
 	VertexAIADC       string `json:"vertex_ai_adc,omitempty"`
+	// RPM and TPM are the limits published by the provider, the requests are queued to stay within them
+	RPM int `json:"rpm,omitempty"`
+	TPM int `json:"tpm,omitempty"`
+	// ResponseFilters are applied before the filters of the user group
+	ResponseFilters []filter.Rule `json:"response_filters,omitempty"`
+	// Maintenance are the known maintenance windows of the provider
+	Maintenance []TimeWindow `json:"maintenance,omitempty"`
+	// NativeWebSearch passes the web_search tool to the upstream instead of serving it by the gateway
+	NativeWebSearch bool `json:"native_web_search,omitempty"`
+	// EmbeddingDimensions is truncate for the upstreams without the dimensions parameter, the embeddings are
+	// truncated to the dimensions asked and normalized by the gateway, which suits the Matryoshka models
+	EmbeddingDimensions string `json:"embedding_dimensions,omitempty"`
+	// NormalizeEmbeddings scales the embeddings of the upstream to a length of 1
+	NormalizeEmbeddings bool `json:"normalize_embeddings,omitempty"`
+	// MaxTokens caps max_tokens and max_completion_tokens of the requests, it is set if the request has neither
+	MaxTokens int `json:"max_tokens,omitempty"`
+	// DailyQuotaLimit and MonthlyQuotaLimit cap the quota consumed through the channel in a day and a month of
+	// the server time, the channel is not routed once a cap is reached until the period ends
+	DailyQuotaLimit   int64 `json:"daily_quota_limit,omitempty"`
+	MonthlyQuotaLimit int64 `json:"monthly_quota_limit,omitempty"`
+	// OpenAIOrganization and OpenAIProject are sent in the OpenAI-Organization and OpenAI-Project headers, for
+	// the usage to be billed to a project of the account, the tokens may override them
+	OpenAIOrganization string `json:"openai_organization,omitempty"`
+	OpenAIProject      string `json:"openai_project,omitempty"`
+	// AutoUpgradeAPIVersion replaces a deprecated API version, or a version rejected by the upstream, by the
+	// newest stable version
+	AutoUpgradeAPIVersion bool `json:"auto_upgrade_api_version,omitempty"`
+	// WarmUp keeps the serverless backends which scale to zero from a cold start on the requests of the users
+	WarmUp *WarmUp `json:"warm_up,omitempty"`
+	// ImageDescription substitutes the descriptions of the images for them, for the models without vision
+	ImageDescription *ImageDescription `json:"image_description,omitempty"`
+	// Dial chooses the IP family and the addresses the upstream is dialed with
+	Dial *Dial `json:"dial,omitempty"`
 }
 
 func GetAllChannels(startIdx int, num int, scope string) ([]*Channel, error) {
@@ -57,17 +96,17 @@ func GetAllChannels(startIdx int, num int, scope string) ([]*Channel, error) {
 	var err error
 	switch scope {
 	case "all":
-		err = DB.Order("id desc").Find(&channels).Error
+		err = REPLICA_DB.Order("id desc").Find(&channels).Error
 	case "disabled":
-		err = DB.Order("id desc").Where("status = ? or status = ?", ChannelStatusAutoDisabled, ChannelStatusManuallyDisabled).Find(&channels).Error
+		err = REPLICA_DB.Order("id desc").Where("status = ? or status = ?", ChannelStatusAutoDisabled, ChannelStatusManuallyDisabled).Find(&channels).Error
 	default:
-		err = DB.Order("id desc").Limit(num).Offset(startIdx).Omit("key").Find(&channels).Error
+		err = REPLICA_DB.Order("id desc").Limit(num).Offset(startIdx).Omit("key").Find(&channels).Error
 	}
 	return channels, err
 }
 
 func SearchChannels(keyword string) (channels []*Channel, err error) {
-	err = DB.Omit("key").Where("id = ? or name LIKE ?", helper.String2Int(keyword), keyword+"%").Find(&channels).Error
+	err = REPLICA_DB.Omit("key").Where("id = ? or name LIKE ?", helper.String2Int(keyword), keyword+"%").Find(&channels).Error
 	return channels, err
 }
 
@@ -84,6 +123,11 @@ func GetChannelById(id int, selectAll bool) (*Channel, error) {
 
 func BatchInsertChannels(channels []Channel) error {
 	var err error
+	for i := range channels {
+		if err = ValidateChannelConfig(channels[i].Config); err != nil {
+			return err
+		}
+	}
 	err = DB.Create(&channels).Error
 	if err != nil {
 		return err
@@ -94,9 +138,51 @@ func BatchInsertChannels(channels []Channel) error {
 			return err
 		}
 	}
+	cacheInvalidateChannels()
 	return nil
 }
 
+func GetChannelByExternalId(externalId string, selectAll bool) (*Channel, error) {
+	if externalId == "" {
+		return nil, errors.New("external id 为空！")
+	}
+	channel := Channel{}
+	var err error
+	if selectAll {
+		err = DB.First(&channel, "external_id = ?", externalId).Error
+	} else {
+		err = DB.Omit("key").First(&channel, "external_id = ?", externalId).Error
+	}
+	return &channel, err
+}
+
+// channelExternalIdIndex makes the external ids unique, the channels without one are left out of it
+const channelExternalIdIndex = "idx_channels_external_id_unique"
+
+func createChannelExternalIdIndex(tx *gorm.DB) error {
+	var duplicates []string
+	err := tx.Model(&Channel{}).Where("external_id <> ''").Group("external_id").Having("count(*) > 1").Pluck("external_id", &duplicates).Error
+	if err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("the external ids %s are shared by several channels, make them unique first", strings.Join(duplicates, ", "))
+	}
+	if tx.Dialector.Name() == "mysql" {
+		// MySQL has no partial index, the empty ids are indexed as NULL through a generated column, NULL may repeat
+		return tx.Exec("ALTER TABLE channels ADD COLUMN external_id_unique varchar(64) GENERATED ALWAYS AS (NULLIF(external_id, '')) VIRTUAL, " +
+			"ADD UNIQUE INDEX " + channelExternalIdIndex + " (external_id_unique)").Error
+	}
+	return tx.Exec("CREATE UNIQUE INDEX " + channelExternalIdIndex + " ON channels (external_id) WHERE external_id <> ''").Error
+}
+
+func dropChannelExternalIdIndex(tx *gorm.DB) error {
+	if tx.Dialector.Name() == "mysql" {
+		return tx.Exec("ALTER TABLE channels DROP INDEX " + channelExternalIdIndex + ", DROP COLUMN external_id_unique").Error
+	}
+	return tx.Exec("DROP INDEX " + channelExternalIdIndex).Error
+}
+
 func (channel *Channel) GetPriority() int64 {
 	if channel.Priority == nil {
 		return 0
@@ -126,23 +212,37 @@ func (channel *Channel) GetModelMapping() map[string]string {
 
 func (channel *Channel) Insert() error {
 	var err error
+	if err = ValidateChannelConfig(channel.Config); err != nil {
+		return err
+	}
 	err = DB.Create(channel).Error
 	if err != nil {
 		return err
 	}
 	err = channel.AddAbilities()
-	return err
+	if err != nil {
+		return err
+	}
+	cacheInvalidateChannels()
+	return nil
 }
 
 func (channel *Channel) Update() error {
 	var err error
+	if err = ValidateChannelConfig(channel.Config); err != nil {
+		return err
+	}
 	err = DB.Model(channel).Updates(channel).Error
 	if err != nil {
 		return err
 	}
 	DB.Model(channel).First(channel, "id = ?", channel.Id)
 	err = channel.UpdateAbilities()
-	return err
+	if err != nil {
+		return err
+	}
+	cacheInvalidateChannels()
+	return nil
 }
 
 func (channel *Channel) UpdateResponseTime(responseTime int64) {
@@ -172,7 +272,11 @@ func (channel *Channel) Delete() error {
 		return err
 	}
 	err = channel.DeleteAbilities()
-	return err
+	if err != nil {
+		return err
+	}
+	cacheInvalidateChannels()
+	return nil
 }
 
 func (channel *Channel) LoadConfig() (ChannelConfig, error) {
@@ -195,10 +299,13 @@ func UpdateChannelStatusById(id int, status int) {
 	err = DB.Model(&Channel{}).Where("id = ?", id).Update("status", status).Error
 	if err != nil {
 		logger.SysError("failed to update channel status: " + err.Error())
+		return
 	}
+	cacheInvalidateChannels()
 }
 
 func UpdateChannelUsedQuota(id int, quota int64) {
+	recordChannelKeyUsage(id, quota)
 	if config.BatchUpdateEnabled {
 		addNewRecord(BatchUpdateTypeChannelUsedQuota, id, quota)
 		return
@@ -215,10 +322,16 @@ func updateChannelUsedQuota(id int, quota int64) {
 
 func DeleteChannelByStatus(status int64) (int64, error) {
 	result := DB.Where("status = ?", status).Delete(&Channel{})
+	if result.Error == nil {
+		cacheInvalidateChannels()
+	}
 	return result.RowsAffected, result.Error
 }
 
 func DeleteDisabledChannel() (int64, error) {
 	result := DB.Where("status = ? or status = ?", ChannelStatusAutoDisabled, ChannelStatusManuallyDisabled).Delete(&Channel{})
+	if result.Error == nil {
+		cacheInvalidateChannels()
+	}
 	return result.RowsAffected, result.Error
 }
@@ -0,0 +1,204 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	ModelStatusOperational = "operational"
+	ModelStatusDegraded    = "degraded"
+	ModelStatusDown        = "down"
+	ModelStatusUnknown     = "unknown"
+)
+
+// the error rates above which a model is shown as degraded or down
+const (
+	modelDegradedErrorRate = 0.05
+	modelDownErrorRate     = 0.5
+)
+
+var modelStatusCacheDuration = time.Minute
+
+// ChannelCheck is the result of a channel health check, the status page is derived from them
+type ChannelCheck struct {
+	Id        int    `json:"id"`
+	ChannelId int    `json:"channel_id" gorm:"index"`
+	Model     string `json:"model" gorm:"index"`
+	Success   bool   `json:"success"`
+	Latency   int64  `json:"latency"` // in milliseconds
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+type ModelStatus struct {
+	Model  string `json:"model"`
+	Status string `json:"status"`
+	// Channels is the number of the enabled channels serving the model
+	Channels      int     `json:"channels"`
+	Checks        int     `json:"checks"`
+	Availability  float64 `json:"availability"`
+	ErrorRate     float64 `json:"error_rate"`
+	LatencyP50    int64   `json:"latency_p50"`
+	LatencyP95    int64   `json:"latency_p95"`
+	LatencyP99    int64   `json:"latency_p99"`
+	LastCheckedAt int64   `json:"last_checked_at"`
+}
+
+var modelStatusLock sync.Mutex
+var modelStatusCache []ModelStatus
+var modelStatusCachedAt time.Time
+
+func RecordChannelCheck(channelId int, modelName string, success bool, latency int64) {
+	check := &ChannelCheck{
+		ChannelId: channelId,
+		Model:     modelName,
+		Success:   success,
+		Latency:   latency,
+		CreatedAt: helper.GetTimestamp(),
+	}
+	if err := DB.Create(check).Error; err != nil {
+		logger.SysError("failed to record channel check: " + err.Error())
+	}
+}
+
+// percentile returns the nearest-rank percentile of the sorted values
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p*float64(len(sorted))+0.999999) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func getStatusPageModels() ([]string, error) {
+	if config.StatusPageModels != "" {
+		var models []string
+		for _, name := range strings.Split(config.StatusPageModels, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				models = append(models, name)
+			}
+		}
+		return models, nil
+	}
+	var models []string
+	err := DB.Model(&Ability{}).Distinct("model").Where("enabled = "+trueValue()).Pluck("model", &models).Error
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(models)
+	return models, nil
+}
+
+func computeModelStatuses() ([]ModelStatus, error) {
+	models, err := getStatusPageModels()
+	if err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return []ModelStatus{}, nil
+	}
+	var channelCounts []struct {
+		Model    string
+		Channels int
+	}
+	err = DB.Model(&Ability{}).Select("model, count(distinct channel_id) as channels").
+		Where("enabled = "+trueValue()+" and model in ?", models).Group("model").Scan(&channelCounts).Error
+	if err != nil {
+		return nil, err
+	}
+	var checks []ChannelCheck
+	since := helper.GetTimestamp() - int64(config.StatusPageWindowHours)*3600
+	err = DB.Where("created_at >= ? and model in ?", since, models).Order("created_at").Find(&checks).Error
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]ModelStatus, len(models))
+	indexes := make(map[string]int, len(models))
+	for i, name := range models {
+		statuses[i] = ModelStatus{Model: name}
+		indexes[name] = i
+	}
+	for _, count := range channelCounts {
+		statuses[indexes[count.Model]].Channels = count.Channels
+	}
+	successes := make([]int, len(models))
+	latencies := make([][]int64, len(models))
+	for _, check := range checks {
+		i := indexes[check.Model]
+		statuses[i].Checks++
+		statuses[i].LastCheckedAt = check.CreatedAt
+		if check.Success {
+			successes[i]++
+			latencies[i] = append(latencies[i], check.Latency)
+		}
+	}
+	for i := range statuses {
+		status := &statuses[i]
+		if status.Checks > 0 {
+			status.Availability = float64(successes[i]) / float64(status.Checks)
+			status.ErrorRate = float64(status.Checks-successes[i]) / float64(status.Checks)
+		}
+		sort.Slice(latencies[i], func(a, b int) bool {
+			return latencies[i][a] < latencies[i][b]
+		})
+		status.LatencyP50 = percentile(latencies[i], 0.5)
+		status.LatencyP95 = percentile(latencies[i], 0.95)
+		status.LatencyP99 = percentile(latencies[i], 0.99)
+		switch {
+		case status.Channels == 0:
+			status.Status = ModelStatusDown
+		case status.Checks == 0:
+			status.Status = ModelStatusUnknown
+		case status.ErrorRate <= modelDegradedErrorRate:
+			status.Status = ModelStatusOperational
+		case status.ErrorRate < modelDownErrorRate:
+			status.Status = ModelStatusDegraded
+		default:
+			status.Status = ModelStatusDown
+		}
+	}
+	return statuses, nil
+}
+
+// GetModelStatuses returns the status of the models on the status page, it is cached for a minute
+// because the page can be served to everyone
+func GetModelStatuses() ([]ModelStatus, error) {
+	modelStatusLock.Lock()
+	defer modelStatusLock.Unlock()
+	if modelStatusCache != nil && time.Since(modelStatusCachedAt) < modelStatusCacheDuration {
+		return modelStatusCache, nil
+	}
+	statuses, err := computeModelStatuses()
+	if err != nil {
+		return nil, err
+	}
+	modelStatusCache = statuses
+	modelStatusCachedAt = time.Now()
+	return statuses, nil
+}
+
+// AutomaticallyDeleteOldChannelChecks removes the health checks out of the status page window
+func AutomaticallyDeleteOldChannelChecks() {
+	for {
+		if IsLeader() {
+			since := helper.GetTimestamp() - int64(config.StatusPageWindowHours)*3600
+			err := DB.Where("created_at < ?", since).Delete(&ChannelCheck{}).Error
+			if err != nil {
+				logger.SysError("failed to delete old channel checks: " + err.Error())
+			}
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+// channelKeyScanRetentionDays is how long the results of the key scans are kept
+const channelKeyScanRetentionDays = 30
+
+// ChannelKeyScan is the result of checking the key of a channel with a cheap call to the upstream
+type ChannelKeyScan struct {
+	Id          int    `json:"id"`
+	ChannelId   int    `json:"channel_id" gorm:"index"`
+	ChannelName string `json:"channel_name" gorm:"default:''"`
+	Result      string `json:"result" gorm:"type:varchar(16);index"`
+	StatusCode  int    `json:"status_code"`
+	Message     string `json:"message" gorm:"type:text"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+func RecordChannelKeyScans(scans []*ChannelKeyScan) error {
+	if len(scans) == 0 {
+		return nil
+	}
+	now := helper.GetTimestamp()
+	for _, scan := range scans {
+		scan.CreatedAt = now
+	}
+	if err := DB.Create(&scans).Error; err != nil {
+		return err
+	}
+	return DB.Where("created_at < ?", now-channelKeyScanRetentionDays*24*3600).Delete(&ChannelKeyScan{}).Error
+}
+
+// GetLatestChannelKeyScans returns the last result of each channel scanned, the deleted channels are left out
+func GetLatestChannelKeyScans() ([]*ChannelKeyScan, error) {
+	var scans []*ChannelKeyScan
+	latest := REPLICA_DB.Model(&ChannelKeyScan{}).Select("max(id)").Group("channel_id")
+	channels := REPLICA_DB.Model(&Channel{}).Select("id")
+	err := REPLICA_DB.Where("id in (?) and channel_id in (?)", latest, channels).Order("channel_id").Find(&scans).Error
+	return scans, err
+}
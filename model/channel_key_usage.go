@@ -0,0 +1,193 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// channelKeyUsageFlushInterval is how often the usage of the channel keys recorded in memory is written, the keys
+// are looked up when it is written, so the usage of a key replaced meanwhile is counted for the new key
+const channelKeyUsageFlushInterval = 30 * time.Second
+
+// ChannelKeyUsage is the usage of a key of a channel, a key put in the channel in place of another gets a usage
+// of its own, and the usage of a channel purged is kept, for the keys to be audited before being revoked
+type ChannelKeyUsage struct {
+	Id             int    `json:"id"`
+	ChannelId      int    `json:"channel_id" gorm:"uniqueIndex:idx_channel_key_usage,priority:1"`
+	KeyFingerprint string `json:"key_fingerprint" gorm:"type:varchar(16);uniqueIndex:idx_channel_key_usage,priority:2"`
+	KeyHint        string `json:"key_hint" gorm:"type:varchar(32);default:''"`
+	FirstUsedTime  int64  `json:"first_used_time" gorm:"bigint"`
+	LastUsedTime   int64  `json:"last_used_time" gorm:"bigint;index"`
+	RequestCount   int64  `json:"request_count" gorm:"bigint;default:0"`
+	UsedQuota      int64  `json:"used_quota" gorm:"bigint;default:0"`
+}
+
+type channelKeyUsageDelta struct {
+	requests int64
+	quota    int64
+	lastUsed int64
+}
+
+var channelKeyUsageDeltas = make(map[int]*channelKeyUsageDelta)
+var channelKeyUsageLock sync.Mutex
+
+// KeyFingerprint identifies a key without revealing it
+func KeyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// keyHint is the end of the key, for the key to be recognized in the console of the provider
+func keyHint(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
+
+// recordChannelKeyUsage counts a request billed on the current key of the channel
+func recordChannelKeyUsage(channelId int, quota int64) {
+	if channelId == 0 {
+		return
+	}
+	channelKeyUsageLock.Lock()
+	defer channelKeyUsageLock.Unlock()
+	delta, ok := channelKeyUsageDeltas[channelId]
+	if !ok {
+		delta = &channelKeyUsageDelta{}
+		channelKeyUsageDeltas[channelId] = delta
+	}
+	delta.requests++
+	delta.quota += quota
+	delta.lastUsed = helper.GetTimestamp()
+}
+
+func addChannelKeyUsage(channelId int, fingerprint string, hint string, delta *channelKeyUsageDelta) error {
+	updates := map[string]any{
+		"request_count":  gorm.Expr("request_count + ?", delta.requests),
+		"used_quota":     gorm.Expr("used_quota + ?", delta.quota),
+		"last_used_time": delta.lastUsed,
+	}
+	result := DB.Model(&ChannelKeyUsage{}).Where("channel_id = ? and key_fingerprint = ?", channelId, fingerprint).Updates(updates)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	err := DB.Create(&ChannelKeyUsage{
+		ChannelId:      channelId,
+		KeyFingerprint: fingerprint,
+		KeyHint:        hint,
+		FirstUsedTime:  delta.lastUsed,
+		LastUsedTime:   delta.lastUsed,
+		RequestCount:   delta.requests,
+		UsedQuota:      delta.quota,
+	}).Error
+	if err != nil {
+		// another node has written the first usage of the key meanwhile
+		err = DB.Model(&ChannelKeyUsage{}).Where("channel_id = ? and key_fingerprint = ?", channelId, fingerprint).Updates(updates).Error
+	}
+	return err
+}
+
+// FlushChannelKeyUsages writes the usage of the channel keys recorded since the last flush
+func FlushChannelKeyUsages() {
+	channelKeyUsageLock.Lock()
+	deltas := channelKeyUsageDeltas
+	channelKeyUsageDeltas = make(map[int]*channelKeyUsageDelta)
+	channelKeyUsageLock.Unlock()
+	if len(deltas) == 0 {
+		return
+	}
+	ids := make([]int, 0, len(deltas))
+	for id := range deltas {
+		ids = append(ids, id)
+	}
+	var channels []*Channel
+	if err := DB.Unscoped().Select("id", quoteCol("key")).Where("id in ?", ids).Find(&channels).Error; err != nil {
+		logger.SysError("failed to get the keys of the channels used: " + err.Error())
+		return
+	}
+	for _, channel := range channels {
+		err := addChannelKeyUsage(channel.Id, KeyFingerprint(channel.Key), keyHint(channel.Key), deltas[channel.Id])
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to record the key usage of channel %d: %s", channel.Id, err.Error()))
+		}
+	}
+}
+
+// SyncChannelKeyUsages writes the usage of the channel keys periodically
+func SyncChannelKeyUsages() {
+	for {
+		time.Sleep(channelKeyUsageFlushInterval)
+		FlushChannelKeyUsages()
+	}
+}
+
+// ChannelKeyUsageRecord is the usage of a channel key with its channel, Current tells whether the key is still the
+// key of the channel
+type ChannelKeyUsageRecord struct {
+	ChannelKeyUsage
+	ChannelName   string `json:"channel_name"`
+	ChannelType   int    `json:"channel_type"`
+	ChannelStatus int    `json:"channel_status"`
+	Deleted       bool   `json:"deleted"`
+	Current       bool   `json:"current"`
+}
+
+// GetChannelKeyUsages returns the usage of the keys of all the channels, the trashed and purged ones included, the
+// most recently used first. The channels never used since the usage is tracked have a record without usage
+func GetChannelKeyUsages() ([]*ChannelKeyUsageRecord, error) {
+	var usages []*ChannelKeyUsage
+	if err := REPLICA_DB.Order("last_used_time desc").Find(&usages).Error; err != nil {
+		return nil, err
+	}
+	var channels []*Channel
+	if err := REPLICA_DB.Unscoped().Select("id", quoteCol("key"), "name", "type", "status", "deleted_at").Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	channelsById := make(map[int]*Channel, len(channels))
+	for _, channel := range channels {
+		channelsById[channel.Id] = channel
+	}
+	used := make(map[int]bool)
+	records := make([]*ChannelKeyUsageRecord, 0, len(usages)+len(channels))
+	for _, usage := range usages {
+		record := &ChannelKeyUsageRecord{ChannelKeyUsage: *usage, Deleted: true}
+		if channel, ok := channelsById[usage.ChannelId]; ok {
+			record.ChannelName = channel.Name
+			record.ChannelType = channel.Type
+			record.ChannelStatus = channel.Status
+			record.Deleted = channel.DeletedAt.Valid
+			record.Current = KeyFingerprint(channel.Key) == usage.KeyFingerprint
+			if record.Current {
+				used[channel.Id] = true
+			}
+		}
+		records = append(records, record)
+	}
+	for _, channel := range channels {
+		if used[channel.Id] {
+			continue
+		}
+		records = append(records, &ChannelKeyUsageRecord{
+			ChannelKeyUsage: ChannelKeyUsage{
+				ChannelId:      channel.Id,
+				KeyFingerprint: KeyFingerprint(channel.Key),
+				KeyHint:        keyHint(channel.Key),
+			},
+			ChannelName:   channel.Name,
+			ChannelType:   channel.Type,
+			ChannelStatus: channel.Status,
+			Deleted:       channel.DeletedAt.Valid,
+			Current:       true,
+		})
+	}
+	return records, nil
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+// Conversation keeps the history of chat completions on the server, the requests carrying its id only send
+// the new messages, the history is replayed before them, see middleware/conversation.go
+type Conversation struct {
+	Id             int    `json:"id"`
+	ConversationId string `json:"conversation_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId         int    `json:"user_id" gorm:"index"`
+	// MaxMessages and MaxTokens trim the replayed history, 0 means the default of the server and no limit
+	MaxMessages int    `json:"max_messages"`
+	MaxTokens   int    `json:"max_tokens"`
+	Metadata    string `json:"metadata" gorm:"type:text"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt   int64  `json:"updated_at" gorm:"bigint"`
+}
+
+type ConversationMessage struct {
+	Id             int    `json:"id"`
+	MessageId      string `json:"message_id" gorm:"type:varchar(64);uniqueIndex"`
+	ConversationId string `json:"conversation_id" gorm:"type:varchar(64);index"`
+	Role           string `json:"role" gorm:"type:varchar(32)"`
+	// Message is the JSON of the chat message as it was sent or answered
+	Message   string `json:"message" gorm:"type:text"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+// InsertConversation creates the conversation together with its initial messages
+func InsertConversation(conversation *Conversation, messages []*ConversationMessage) error {
+	conversation.ConversationId = NewObjectId("conv_")
+	conversation.CreatedAt = helper.GetTimestamp()
+	conversation.UpdatedAt = conversation.CreatedAt
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(conversation).Error; err != nil {
+			return err
+		}
+		return insertConversationMessages(tx, conversation.ConversationId, messages)
+	})
+}
+
+func (conversation *Conversation) Update() error {
+	return DB.Model(conversation).Select("max_messages", "max_tokens", "metadata").Updates(conversation).Error
+}
+
+// Delete removes the conversation with its messages
+func (conversation *Conversation) Delete() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("conversation_id = ?", conversation.ConversationId).Delete(&ConversationMessage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(conversation).Error
+	})
+}
+
+func GetUserConversation(userId int, conversationId string) (*Conversation, error) {
+	conversation := &Conversation{}
+	err := DB.Where("user_id = ? and conversation_id = ?", userId, conversationId).First(conversation).Error
+	return conversation, err
+}
+
+func GetUserConversations(userId int, params ListParams) (conversations []*Conversation, err error) {
+	query := paginate(DB.Where("user_id = ?", userId), &Conversation{}, "conversation_id", params)
+	err = query.Find(&conversations).Error
+	return conversations, err
+}
+
+func insertConversationMessages(tx *gorm.DB, conversationId string, messages []*ConversationMessage) error {
+	now := helper.GetTimestamp()
+	for _, message := range messages {
+		message.MessageId = NewObjectId("cmsg_")
+		message.ConversationId = conversationId
+		message.CreatedAt = now
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AppendConversationMessages adds the messages of a chat completion and its answer to the conversation
+func AppendConversationMessages(conversation *Conversation, messages []*ConversationMessage) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := insertConversationMessages(tx, conversation.ConversationId, messages); err != nil {
+			return err
+		}
+		return tx.Model(conversation).Update("updated_at", helper.GetTimestamp()).Error
+	})
+}
+
+func GetConversationMessages(conversationId string, params ListParams) (messages []*ConversationMessage, err error) {
+	query := DB.Where("conversation_id = ?", conversationId)
+	err = paginate(query, &ConversationMessage{}, "message_id", params).Find(&messages).Error
+	return messages, err
+}
+
+// GetRecentConversationMessages returns the last limit messages of the conversation in the order they were added
+func GetRecentConversationMessages(conversationId string, limit int) (messages []*ConversationMessage, err error) {
+	err = DB.Where("conversation_id = ?", conversationId).Order("id desc").Limit(limit).Find(&messages).Error
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, err
+}
@@ -0,0 +1,410 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	CouponScopeTopUp = "top_up" // a bonus on the quota of a redemption code redeemed with the coupon
+	CouponScopeUsage = "usage"  // a discount on the requests to the models of the coupon, once claimed by the user
+)
+
+const (
+	CouponDiscountPercentage = "percentage"
+	CouponDiscountFixed      = "fixed"
+)
+
+const (
+	CouponStatusEnabled  = 1
+	CouponStatusDisabled = 2
+)
+
+// Coupon is a promotion code applying a discount to the top-ups or to the requests to some models during its
+// time window
+type Coupon struct {
+	Id           int    `json:"id"`
+	Code         string `json:"code" gorm:"type:varchar(32);uniqueIndex"`
+	Name         string `json:"name" gorm:"type:varchar(64);default:''"`
+	Status       int    `json:"status" gorm:"default:1"`
+	Scope        string `json:"scope" gorm:"type:varchar(16)"`
+	DiscountType string `json:"discount_type" gorm:"type:varchar(16)"`
+	// Value is the percentage of a percentage discount, or the quota of a fixed one: the quota added to the top-up,
+	// or the quota of the requests to the models the user does not pay
+	Value float64 `json:"value"`
+	// Models are the models of a usage coupon separated by commas, a trailing * matches the models by prefix
+	Models string `json:"models" gorm:"type:text"`
+	// Groups are the user groups which may use the coupon separated by commas, empty for all the groups
+	Groups    string `json:"groups" gorm:"default:''"`
+	StartTime int64  `json:"start_time" gorm:"bigint;default:0"`
+	EndTime   int64  `json:"end_time" gorm:"bigint;default:0"` // 0 means no end
+	// MaxRedemptions is the number of times the coupon can be used by all the users, 0 means no limit
+	MaxRedemptions int   `json:"max_redemptions" gorm:"default:0"`
+	PerUserLimit   int   `json:"per_user_limit" gorm:"default:1"`
+	RedeemedCount  int   `json:"redeemed_count" gorm:"default:0"`
+	CreatedTime    int64 `json:"created_time" gorm:"bigint"`
+}
+
+// CouponRedemption is a use of a coupon by a user, a top-up or the claim of a usage coupon
+type CouponRedemption struct {
+	Id       int    `json:"id"`
+	CouponId int    `json:"coupon_id" gorm:"index"`
+	UserId   int    `json:"user_id" gorm:"index"`
+	Username string `json:"username" gorm:"type:varchar(32);default:''"`
+	// Discount is the quota the coupon has given the user, the bonus of the top-up or the sum of the discounts of
+	// the requests
+	Discount int64 `json:"discount" gorm:"bigint;default:0"`
+	// Remaining is the quota left of a fixed usage coupon
+	Remaining    int64 `json:"remaining" gorm:"bigint;default:0"`
+	RedemptionId int   `json:"redemption_id" gorm:"default:0"`
+	CreatedTime  int64 `json:"created_time" gorm:"bigint"`
+}
+
+// CouponReport sums the redemptions of a coupon
+type CouponReport struct {
+	RedeemedCount int   `json:"redeemed_count"`
+	Users         int   `json:"users"`
+	Discount      int64 `json:"discount"`
+	Remaining     int64 `json:"remaining"`
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (coupon *Coupon) GetModels() []string {
+	return splitList(coupon.Models)
+}
+
+func (coupon *Coupon) GetGroups() []string {
+	return splitList(coupon.Groups)
+}
+
+// matchesModel tells whether the usage coupon discounts the model, all models when it has none
+func (coupon *Coupon) matchesModel(model string) bool {
+	models := coupon.GetModels()
+	if len(models) == 0 {
+		return true
+	}
+	for _, pattern := range models {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(model, prefix) {
+				return true
+			}
+		} else if pattern == model {
+			return true
+		}
+	}
+	return false
+}
+
+func (coupon *Coupon) activeAt(now int64) bool {
+	return coupon.Status == CouponStatusEnabled && coupon.StartTime <= now && (coupon.EndTime == 0 || now < coupon.EndTime)
+}
+
+func GetAllCoupons(startIdx int, num int) ([]*Coupon, error) {
+	var coupons []*Coupon
+	err := DB.Order("id desc").Limit(num).Offset(startIdx).Find(&coupons).Error
+	return coupons, err
+}
+
+func GetCouponById(id int) (*Coupon, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	coupon := Coupon{Id: id}
+	err := DB.First(&coupon, "id = ?", id).Error
+	return &coupon, err
+}
+
+func (coupon *Coupon) Insert() error {
+	coupon.CreatedTime = helper.GetTimestamp()
+	if err := DB.Create(coupon).Error; err != nil {
+		return err
+	}
+	refreshUsageCoupons()
+	return nil
+}
+
+// Update changes the coupon but its code and its redemptions
+func (coupon *Coupon) Update() error {
+	err := DB.Model(coupon).Select("name", "status", "scope", "discount_type", "value", "models", "groups",
+		"start_time", "end_time", "max_redemptions", "per_user_limit").Updates(coupon).Error
+	if err != nil {
+		return err
+	}
+	refreshUsageCoupons()
+	return nil
+}
+
+// DeleteCouponById deletes the coupon, the discounts of the usage coupon stop with it and its redemptions are kept
+func DeleteCouponById(id int) error {
+	coupon, err := GetCouponById(id)
+	if err != nil {
+		return err
+	}
+	if err = DB.Delete(coupon).Error; err != nil {
+		return err
+	}
+	refreshUsageCoupons()
+	return nil
+}
+
+func GetCouponRedemptions(couponId int, startIdx int, num int) ([]*CouponRedemption, error) {
+	var redemptions []*CouponRedemption
+	err := DB.Where("coupon_id = ?", couponId).Order("id desc").Limit(num).Offset(startIdx).Find(&redemptions).Error
+	return redemptions, err
+}
+
+func GetCouponReport(couponId int) (*CouponReport, error) {
+	report := &CouponReport{}
+	err := DB.Model(&CouponRedemption{}).Where("coupon_id = ?", couponId).
+		Select("count(1) as redeemed_count, count(distinct user_id) as users, coalesce(sum(discount), 0) as discount, coalesce(sum(remaining), 0) as remaining").
+		Scan(report).Error
+	return report, err
+}
+
+// useCoupon checks the coupon can still be used by the user for the scope, and records its use with the bonus of
+// a top-up of quota. The coupon row is locked, so that the concurrent uses are counted one after the other
+func useCoupon(tx *gorm.DB, code string, userId int, scope string, quota int64, redemptionId int) (*Coupon, *CouponRedemption, error) {
+	coupon := &Coupon{}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", strings.TrimSpace(code)).First(coupon).Error
+	if err != nil {
+		return nil, nil, errors.New("无效的优惠券")
+	}
+	now := helper.GetTimestamp()
+	if !coupon.activeAt(now) {
+		return nil, nil, errors.New("优惠券不在有效期内")
+	}
+	if coupon.Scope != scope {
+		if scope == CouponScopeTopUp {
+			return nil, nil, errors.New("该优惠券用于模型使用折扣，请直接领取")
+		}
+		return nil, nil, errors.New("该优惠券用于充值，请在兑换充值码时填写")
+	}
+	if coupon.MaxRedemptions > 0 && coupon.RedeemedCount >= coupon.MaxRedemptions {
+		return nil, nil, errors.New("优惠券已被领完")
+	}
+	var user User
+	if err = tx.Select("username", quoteCol("group")).Where("id = ?", userId).First(&user).Error; err != nil {
+		return nil, nil, err
+	}
+	if groups := coupon.GetGroups(); len(groups) > 0 && !slices.Contains(groups, user.Group) {
+		return nil, nil, errors.New("您所在的分组不能使用该优惠券")
+	}
+	var count int64
+	if err = tx.Model(&CouponRedemption{}).Where("coupon_id = ? and user_id = ?", coupon.Id, userId).Count(&count).Error; err != nil {
+		return nil, nil, err
+	}
+	if coupon.PerUserLimit > 0 && count >= int64(coupon.PerUserLimit) {
+		return nil, nil, errors.New("已达到该优惠券的使用次数上限")
+	}
+	var discount, remaining int64
+	switch {
+	case coupon.Scope == CouponScopeTopUp && coupon.DiscountType == CouponDiscountPercentage:
+		discount = int64(float64(quota) * coupon.Value / 100)
+	case coupon.Scope == CouponScopeTopUp:
+		discount = int64(coupon.Value)
+	case coupon.DiscountType == CouponDiscountFixed:
+		remaining = int64(coupon.Value)
+	}
+	redemption := &CouponRedemption{
+		CouponId:     coupon.Id,
+		UserId:       userId,
+		Username:     user.Username,
+		Discount:     discount,
+		Remaining:    remaining,
+		RedemptionId: redemptionId,
+		CreatedTime:  now,
+	}
+	// SQLite has no row locks, the count is checked again by the update
+	result := tx.Model(&Coupon{}).Where("id = ? and (max_redemptions = 0 or redeemed_count < max_redemptions)", coupon.Id).
+		Update("redeemed_count", gorm.Expr("redeemed_count + 1"))
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, errors.New("优惠券已被领完")
+	}
+	if err = tx.Create(redemption).Error; err != nil {
+		return nil, nil, err
+	}
+	return coupon, redemption, nil
+}
+
+// ClaimCoupon gives the user the discount of a usage coupon on the requests to its models
+func ClaimCoupon(ctx context.Context, code string, userId int) (*Coupon, error) {
+	if strings.TrimSpace(code) == "" {
+		return nil, errors.New("未提供优惠券")
+	}
+	var coupon *Coupon
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		coupon, _, err = useCoupon(tx, code, userId, CouponScopeUsage, 0, 0)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the coupon may have been created on another node since the last sync
+	refreshUsageCoupons()
+	RecordLog(ctx, userId, LogTypeSystem, fmt.Sprintf("领取优惠券「%s」", coupon.Name))
+	return coupon, nil
+}
+
+// usageCoupons are the usage coupons active or starting within the minute, the requests are not checked for
+// coupons when there are none
+var usageCoupons atomic.Pointer[[]*Coupon]
+
+func refreshUsageCoupons() {
+	now := helper.GetTimestamp()
+	var coupons []*Coupon
+	err := DB.Where("scope = ? and status = ? and start_time <= ? and (end_time = 0 or end_time > ?)",
+		CouponScopeUsage, CouponStatusEnabled, now+60, now).Find(&coupons).Error
+	if err != nil {
+		logger.SysError("failed to check the usage coupons: " + err.Error())
+		return
+	}
+	usageCoupons.Store(&coupons)
+}
+
+// SyncUsageCoupons checks every minute whether a usage coupon is active, as they start and end, and as the coupons
+// are changed on the other nodes
+func SyncUsageCoupons() {
+	for {
+		refreshUsageCoupons()
+		time.Sleep(time.Minute)
+	}
+}
+
+// ApplyUsageCoupons returns the quota of the request after the discounts of the usage coupons the user has claimed
+// for the model: the best percentage discount, then the quota left of the fixed ones. The note tells the
+// discounts for the consume log
+func ApplyUsageCoupons(userId int, model string, quota int64) (int64, string) {
+	coupons := usageCoupons.Load()
+	if quota <= 0 || coupons == nil || len(*coupons) == 0 {
+		return quota, ""
+	}
+	now := helper.GetTimestamp()
+	active := make(map[int]*Coupon)
+	couponIds := make([]int, 0, len(*coupons))
+	for _, coupon := range *coupons {
+		if coupon.activeAt(now) && coupon.matchesModel(model) {
+			active[coupon.Id] = coupon
+			couponIds = append(couponIds, coupon.Id)
+		}
+	}
+	if len(couponIds) == 0 {
+		return quota, ""
+	}
+	var redemptions []*CouponRedemption
+	err := DB.Where("user_id = ? and coupon_id in ?", userId, couponIds).Order("id").Find(&redemptions).Error
+	if err != nil {
+		return quota, ""
+	}
+	var notes []string
+	var best *CouponRedemption
+	for _, redemption := range redemptions {
+		coupon := active[redemption.CouponId]
+		if coupon == nil || coupon.DiscountType != CouponDiscountPercentage {
+			continue
+		}
+		if best == nil || coupon.Value > active[best.CouponId].Value {
+			best = redemption
+		}
+	}
+	if best != nil {
+		coupon := active[best.CouponId]
+		discount := int64(float64(quota) * coupon.Value / 100)
+		if discount > quota {
+			discount = quota
+		}
+		if discount > 0 {
+			err = DB.Model(&CouponRedemption{}).Where("id = ?", best.Id).Update("discount", gorm.Expr("discount + ?", discount)).Error
+			if err == nil {
+				quota -= discount
+				notes = append(notes, fmt.Sprintf("优惠券「%s」减免 %s", coupon.Name, common.LogQuota(discount)))
+			}
+		}
+	}
+	for _, redemption := range redemptions {
+		coupon := active[redemption.CouponId]
+		if quota <= 0 || coupon == nil || coupon.DiscountType != CouponDiscountFixed || redemption.Remaining <= 0 {
+			continue
+		}
+		discount := redemption.Remaining
+		if discount > quota {
+			discount = quota
+		}
+		// the quota left may have been used by a concurrent request meanwhile
+		result := DB.Model(&CouponRedemption{}).Where("id = ? and remaining >= ?", redemption.Id, discount).Updates(map[string]any{
+			"remaining": gorm.Expr("remaining - ?", discount),
+			"discount":  gorm.Expr("discount + ?", discount),
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+		quota -= discount
+		notes = append(notes, fmt.Sprintf("优惠券「%s」抵扣 %s", coupon.Name, common.LogQuota(discount)))
+	}
+	return quota, strings.Join(notes, "，")
+}
+
+// GetUserCoupons returns the coupons the user has used, with the quota left of the fixed usage coupons
+func GetUserCoupons(userId int) ([]map[string]any, error) {
+	var redemptions []*CouponRedemption
+	err := DB.Where("user_id = ?", userId).Order("id desc").Find(&redemptions).Error
+	if err != nil || len(redemptions) == 0 {
+		return []map[string]any{}, err
+	}
+	couponIds := make([]int, 0, len(redemptions))
+	for _, redemption := range redemptions {
+		couponIds = append(couponIds, redemption.CouponId)
+	}
+	var coupons []*Coupon
+	if err = DB.Where("id in ?", couponIds).Find(&coupons).Error; err != nil {
+		return nil, err
+	}
+	couponsById := make(map[int]*Coupon, len(coupons))
+	for _, coupon := range coupons {
+		couponsById[coupon.Id] = coupon
+	}
+	result := make([]map[string]any, 0, len(redemptions))
+	for _, redemption := range redemptions {
+		coupon, ok := couponsById[redemption.CouponId]
+		if !ok {
+			continue
+		}
+		result = append(result, map[string]any{
+			"name":          coupon.Name,
+			"scope":         coupon.Scope,
+			"discount_type": coupon.DiscountType,
+			"value":         coupon.Value,
+			"models":        coupon.Models,
+			"start_time":    coupon.StartTime,
+			"end_time":      coupon.EndTime,
+			"discount":      redemption.Discount,
+			"remaining":     redemption.Remaining,
+			"created_time":  redemption.CreatedTime,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/client"
+)
+
+// Dial chooses how the connections to the upstream of the channel are dialed, such as IPv4 only for the providers
+// whose IPv6 endpoints are not reachable, and the addresses of their hosts instead of those of DNS
+type Dial struct {
+	// Network is dual, ipv4 or ipv6, dual if empty
+	Network string `json:"network,omitempty"`
+	// Hosts map the host names to the addresses dialed for them, which are tried in their order
+	Hosts map[string][]string `json:"hosts,omitempty"`
+	// FallbackDelay is how many milliseconds the first family of the dual network is tried alone, 300 if 0,
+	// negative to try the families one after the other
+	FallbackDelay int `json:"fallback_delay,omitempty"`
+}
+
+func (d *Dial) Options() client.DialOptions {
+	return client.DialOptions{
+		Network:       d.Network,
+		Hosts:         d.Hosts,
+		FallbackDelay: time.Duration(d.FallbackDelay) * time.Millisecond,
+	}
+}
+
+func (d *Dial) Validate() error {
+	return client.ValidateDialOptions(d.Options())
+}
+
+// HTTPClient returns the base client dialing the upstream of the channel by its dial options, for the requests made
+// outside of the relay, such as the balance queries and the key scans
+func (channel *Channel) HTTPClient(base *http.Client) *http.Client {
+	cfg, err := channel.LoadConfig()
+	if err != nil || cfg.Dial == nil {
+		return base
+	}
+	return client.WithDial(base, cfg.Dial.Options())
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/songquanpeng/one-api/common"
+)
+
+// quoteCol quotes reserved words such as `key` and `group` for the database in use
+func quoteCol(name string) string {
+	if common.UsingPostgreSQL {
+		return fmt.Sprintf(`"%s"`, name)
+	}
+	return fmt.Sprintf("`%s`", name)
+}
+
+func trueValue() string {
+	if common.UsingPostgreSQL {
+		return "true"
+	}
+	return "1"
+}
+
+func randomFunc() string {
+	if common.UsingSQLite || common.UsingPostgreSQL {
+		return "RANDOM()"
+	}
+	return "RAND()"
+}
+
+func ifNullFunc() string {
+	if common.UsingPostgreSQL {
+		return "COALESCE"
+	}
+	return "ifnull"
+}
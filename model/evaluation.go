@@ -0,0 +1,71 @@
+package model
+
+import (
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+// Evaluation is the score given by the judge model to an answer, the answers relayed by the gateway
+// are linked to the channel, the model and the experiment variant of their consume log
+type Evaluation struct {
+	Id         int     `json:"id"`
+	UserId     int     `json:"user_id" gorm:"index"`
+	RequestId  string  `json:"request_id" gorm:"type:varchar(64);index"`
+	ChannelId  int     `json:"channel_id" gorm:"index"`
+	ModelName  string  `json:"model_name" gorm:"type:varchar(64);default:''"`
+	Experiment string  `json:"experiment" gorm:"type:varchar(40);index;default:''"`
+	JudgeModel string  `json:"judge_model"`
+	Score      float64 `json:"score"`
+	Reason     string  `json:"reason" gorm:"type:text"`
+	CreatedAt  int64   `json:"created_at" gorm:"bigint;index"`
+}
+
+type ChannelEvaluationReport struct {
+	ChannelId   int     `json:"channel_id"`
+	Evaluations int64   `json:"evaluations"`
+	AvgScore    float64 `json:"avg_score"`
+	MinScore    float64 `json:"min_score"`
+}
+
+func (evaluation *Evaluation) Insert() error {
+	evaluation.CreatedAt = helper.GetTimestamp()
+	return DB.Create(evaluation).Error
+}
+
+// GetUserConsumeLogByRequestId returns the consume log of the request made by the user
+func GetUserConsumeLogByRequestId(userId int, requestId string) (*Log, error) {
+	log := &Log{}
+	err := LOG_DB.Where("user_id = ? and request_id = ? and type = ?", userId, requestId, LogTypeConsume).First(log).Error
+	return log, err
+}
+
+// GetChannelEvaluationReports compares the scores of the answers of the channels since the timestamp
+func GetChannelEvaluationReports(since int64) ([]*ChannelEvaluationReport, error) {
+	var reports []*ChannelEvaluationReport
+	err := DB.Model(&Evaluation{}).
+		Select("channel_id, count(*) as evaluations, avg(score) as avg_score, min(score) as min_score").
+		Where("channel_id <> 0 and created_at >= ?", since).
+		Group("channel_id").Order("avg_score").Scan(&reports).Error
+	return reports, err
+}
+
+type experimentScore struct {
+	Experiment  string
+	Evaluations int64
+	AvgScore    float64
+}
+
+func getExperimentScores(name string) (map[string]*experimentScore, error) {
+	var scores []*experimentScore
+	err := DB.Model(&Evaluation{}).
+		Select("experiment, count(*) as evaluations, avg(score) as avg_score").
+		Where("experiment IN ?", []string{name + "/A", name + "/B"}).
+		Group("experiment").Scan(&scores).Error
+	if err != nil {
+		return nil, err
+	}
+	scoresByVariant := make(map[string]*experimentScore, len(scores))
+	for _, score := range scores {
+		scoresByVariant[score.Experiment] = score
+	}
+	return scoresByVariant, nil
+}
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+)
+
+// exchangeRateResponse is the response of the exchange rate APIs such as open.er-api.com and frankfurter.app,
+// the rates are the units of each currency for one unit of the base
+type exchangeRateResponse struct {
+	Base     string             `json:"base"`
+	BaseCode string             `json:"base_code"`
+	Rates    map[string]float64 `json:"rates"`
+}
+
+// SyncExchangeRates fetches ExchangeRateURL and updates the rates of the currencies already configured, the
+// currencies the users can choose from are not changed by the fetch
+func SyncExchangeRates() (map[string]float64, error) {
+	if config.ExchangeRateURL == "" {
+		return nil, fmt.Errorf("未设置汇率接口")
+	}
+	url := strings.ReplaceAll(config.ExchangeRateURL, "{base}", config.BaseCurrency)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("exchange rate request failed with status code %d: %s", resp.StatusCode, string(data))
+	}
+	var response exchangeRateResponse
+	if err = json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	base := response.Base
+	if base == "" {
+		base = response.BaseCode
+	}
+	if base != "" && !strings.EqualFold(base, config.BaseCurrency) {
+		return nil, fmt.Errorf("汇率接口的基准货币为 %s，而不是 %s", base, config.BaseCurrency)
+	}
+	rates := billingratio.GetExchangeRates()
+	delete(rates, config.BaseCurrency)
+	for currency := range rates {
+		// a currency missing from the response keeps its rate
+		if rate, ok := response.Rates[currency]; ok && rate > 0 {
+			rates[currency] = rate
+		}
+	}
+	jsonBytes, err := json.Marshal(rates)
+	if err != nil {
+		return nil, err
+	}
+	if err = UpdateOption("ExchangeRates", string(jsonBytes)); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// AutomaticallySyncExchangeRates updates the exchange rates every frequency minutes on the leader node, when
+// ExchangeRateURL is set
+func AutomaticallySyncExchangeRates(frequency int) {
+	for {
+		if IsLeader() && config.ExchangeRateURL != "" {
+			if _, err := SyncExchangeRates(); err != nil {
+				logger.SysError("failed to sync exchange rates: " + err.Error())
+			}
+		}
+		time.Sleep(time.Duration(frequency) * time.Minute)
+	}
+}
+
+// UpdateUserCurrency sets the currency the quota is displayed in for the user, empty for the base currency
+func UpdateUserCurrency(userId int, currency string) error {
+	currency = strings.ToUpper(currency)
+	if currency != "" {
+		if _, ok := billingratio.GetExchangeRate(currency); !ok {
+			return fmt.Errorf("不支持的货币：%s", currency)
+		}
+	}
+	return DB.Model(&User{}).Where("id = ?", userId).Update("currency", currency).Error
+}
+
+// userQuotaFormatter writes the quota in the currency chosen by the user, for the statements sent to the user
+func userQuotaFormatter(userId int) func(int64) string {
+	var currency string
+	DB.Model(&User{}).Where("id = ?", userId).Select("currency").Find(&currency)
+	if rate, ok := billingratio.GetExchangeRate(currency); ok && currency != "" {
+		return func(quota int64) string {
+			return common.LogQuotaInCurrency(quota, strings.ToUpper(currency), rate)
+		}
+	}
+	return common.LogQuota
+}
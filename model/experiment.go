@@ -0,0 +1,157 @@
+package model
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	ExperimentStatusRunning = 1
+	ExperimentStatusStopped = 2
+)
+
+// Experiment splits the traffic of a virtual model between two variants
+type Experiment struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name" gorm:"type:varchar(32);uniqueIndex"`
+	Model    string `json:"model" gorm:"type:varchar(64);index"` // the virtual model requested by the clients
+	ModelA   string `json:"model_a"`
+	ChannelA int    `json:"channel_a" gorm:"default:0"` // 0 means routing as usual
+	ModelB   string `json:"model_b"`
+	ChannelB int    `json:"channel_b" gorm:"default:0"`
+	// Percentage of the traffic sent to variant B
+	Percentage  int   `json:"percentage" gorm:"default:50"`
+	Status      int   `json:"status" gorm:"default:1"`
+	CreatedTime int64 `json:"created_time" gorm:"bigint"`
+}
+
+type ExperimentVariant struct {
+	Name      string // A or B
+	Model     string
+	ChannelId int
+}
+
+var experiments map[string]*Experiment
+var experimentsLock sync.RWMutex
+
+func GetAllExperiments() ([]*Experiment, error) {
+	var experiments []*Experiment
+	err := DB.Order("id desc").Find(&experiments).Error
+	return experiments, err
+}
+
+func GetExperimentById(id int) (*Experiment, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	experiment := Experiment{Id: id}
+	err := DB.First(&experiment, "id = ?", id).Error
+	return &experiment, err
+}
+
+func (experiment *Experiment) Insert() error {
+	experiment.CreatedTime = helper.GetTimestamp()
+	err := DB.Create(experiment).Error
+	InitExperimentCache()
+	return err
+}
+
+func (experiment *Experiment) Update() error {
+	err := DB.Model(experiment).Select("name", "model", "model_a", "channel_a", "model_b", "channel_b", "percentage", "status").Updates(experiment).Error
+	InitExperimentCache()
+	return err
+}
+
+func DeleteExperimentById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	err := DB.Delete(&Experiment{Id: id}).Error
+	InitExperimentCache()
+	return err
+}
+
+func InitExperimentCache() {
+	var running []*Experiment
+	if err := DB.Where("status = ?", ExperimentStatusRunning).Find(&running).Error; err != nil {
+		logger.SysError("failed to load experiments: " + err.Error())
+		return
+	}
+	newExperiments := make(map[string]*Experiment, len(running))
+	for _, experiment := range running {
+		newExperiments[experiment.Model] = experiment
+	}
+	experimentsLock.Lock()
+	experiments = newExperiments
+	experimentsLock.Unlock()
+}
+
+func SyncExperimentCache(frequency int) {
+	for {
+		time.Sleep(time.Duration(frequency) * time.Second)
+		InitExperimentCache()
+	}
+}
+
+// PickExperimentVariant returns the running experiment of the model and the variant picked for this request
+func PickExperimentVariant(model string) (*Experiment, *ExperimentVariant) {
+	experimentsLock.RLock()
+	experiment, ok := experiments[model]
+	experimentsLock.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	if rand.Intn(100) < experiment.Percentage {
+		return experiment, experiment.Variant("B")
+	}
+	return experiment, experiment.Variant("A")
+}
+
+// Variant returns the variant A or B of the experiment
+func (experiment *Experiment) Variant(name string) *ExperimentVariant {
+	if name == "B" {
+		return &ExperimentVariant{Name: "B", Model: experiment.ModelB, ChannelId: experiment.ChannelB}
+	}
+	return &ExperimentVariant{Name: "A", Model: experiment.ModelA, ChannelId: experiment.ChannelA}
+}
+
+type ExperimentReport struct {
+	Variant             string  `json:"variant" gorm:"column:experiment"`
+	Requests            int64   `json:"requests"`
+	AvgElapsedTime      float64 `json:"avg_elapsed_time"` // ms
+	TotalQuota          int64   `json:"total_quota"`
+	AvgQuota            float64 `json:"avg_quota"`
+	AvgPromptTokens     float64 `json:"avg_prompt_tokens"`
+	AvgCompletionTokens float64 `json:"avg_completion_tokens"`
+	// the scores of the answers evaluated by the judge model
+	Evaluations int64   `json:"evaluations" gorm:"-"`
+	AvgScore    float64 `json:"avg_score" gorm:"-"`
+}
+
+// GetExperimentReport compares the consume logs of the variants
+func GetExperimentReport(name string) ([]*ExperimentReport, error) {
+	var reports []*ExperimentReport
+	err := LOG_REPLICA_DB.Model(&Log{}).
+		Select("experiment, count(*) as requests, avg(elapsed_time) as avg_elapsed_time, sum(quota) as total_quota, avg(quota) as avg_quota, avg(prompt_tokens) as avg_prompt_tokens, avg(completion_tokens) as avg_completion_tokens").
+		Where("type = ? AND experiment IN ?", LogTypeConsume, []string{name + "/A", name + "/B"}).
+		Group("experiment").Order("experiment").Scan(&reports).Error
+	if err != nil {
+		return nil, err
+	}
+	scores, err := getExperimentScores(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, report := range reports {
+		if score, ok := scores[report.Variant]; ok {
+			report.Evaluations = score.Evaluations
+			report.AvgScore = score.AvgScore
+		}
+	}
+	return reports, nil
+}
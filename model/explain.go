@@ -0,0 +1,170 @@
+package model
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+// the reasons a channel of the model is not chosen from at the moment
+const (
+	ExclusionMaintenance  = "maintenance"   // in a maintenance window
+	ExclusionSpendCap     = "spend_cap"     // over its daily or monthly spend cap
+	ExclusionRouting      = "routing"       // excluded by a routing rule of the group
+	ExclusionNotPreferred = "not_preferred" // a routing rule prefers other channels
+	ExclusionThrottled    = "throttled"     // rate limited by the upstream, while other channels are not
+)
+
+const (
+	TierFirst = "first" // chosen from for the first attempt
+	TierRetry = "retry" // chosen from for the retries only
+)
+
+// ChannelCandidate is a channel of the model in a group, with what the channel selection does with it right now
+type ChannelCandidate struct {
+	Id             int     `json:"id"`
+	Name           string  `json:"name"`
+	Type           int     `json:"type"`
+	Priority       int64   `json:"priority"`
+	Excluded       string  `json:"excluded,omitempty"`
+	ThrottledUntil int64   `json:"throttled_until,omitempty"`
+	Tier           string  `json:"tier,omitempty"`
+	Probability    float64 `json:"probability"` // of being chosen for the first attempt, when the group serves the request
+}
+
+// GroupSelection is the channel selection in a group the user has access to, the groups are tried by precedence
+// and the first one left with a channel serves the request
+type GroupSelection struct {
+	Group      string              `json:"group"`
+	Member     string              `json:"member"` // the group of the user, or of its plan, inheriting the group
+	Denied     bool                `json:"denied"` // a routing rule of the member group denies the model at the moment
+	Selected   bool                `json:"selected"`
+	Candidates []*ChannelCandidate `json:"candidates"`
+}
+
+// getModelChannels returns the enabled channels of the model in the group, the highest priority first
+func getModelChannels(group string, model string) ([]*Channel, error) {
+	if config.MemoryCacheEnabled {
+		channelSyncLock.RLock()
+		defer channelSyncLock.RUnlock()
+		return slices.Clone(group2model2channels[group][model]), nil
+	}
+	var ids []int
+	err := DB.Model(&Ability{}).Where(quoteCol("group")+" = ? and model = ? and enabled = "+trueValue(), group, model).
+		Pluck("channel_id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	var channels []*Channel
+	if err = DB.Omit("key").Where("id in ?", ids).Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	sort.SliceStable(channels, func(i, j int) bool {
+		return channels[i].GetPriority() > channels[j].GetPriority()
+	})
+	return channels, nil
+}
+
+// explainGroup applies the steps of CacheGetRandomSatisfiedChannel to the channels one by one, in the same order,
+// and returns the number of channels left to choose from
+func explainGroup(group string, model string, now time.Time) ([]*ChannelCandidate, int, error) {
+	channels, err := getModelChannels(group, model)
+	if err != nil {
+		return nil, 0, err
+	}
+	r := getRouting(group, model)
+	candidates := make([]*ChannelCandidate, len(channels))
+	var preferred, unthrottled int
+	for i, channel := range channels {
+		candidate := &ChannelCandidate{Id: channel.Id, Name: channel.Name, Type: channel.Type, Priority: channel.GetPriority()}
+		switch {
+		case IsChannelInMaintenance(channel.Id):
+			candidate.Excluded = ExclusionMaintenance
+		case IsChannelCapped(channel.Id):
+			candidate.Excluded = ExclusionSpendCap
+		case r.denied || slices.Contains(r.excluded, channel.Id):
+			candidate.Excluded = ExclusionRouting
+		case slices.Contains(r.preferred, channel.Id):
+			preferred++
+		}
+		candidates[i] = candidate
+	}
+	for i, candidate := range candidates {
+		if candidate.Excluded != "" {
+			continue
+		}
+		if preferred > 0 && !slices.Contains(r.preferred, candidate.Id) {
+			candidate.Excluded = ExclusionNotPreferred
+			continue
+		}
+		if until, ok := channelThrottledUntil(channels[i].Id, now); ok {
+			candidate.ThrottledUntil = until.Unix()
+		} else {
+			unthrottled++
+		}
+	}
+	// the throttled channels are only skipped when there are others
+	var available []*ChannelCandidate
+	for _, candidate := range candidates {
+		if candidate.Excluded != "" {
+			continue
+		}
+		if candidate.ThrottledUntil != 0 && unthrottled > 0 {
+			candidate.Excluded = ExclusionThrottled
+			continue
+		}
+		available = append(available, candidate)
+	}
+	if len(available) == 0 {
+		return candidates, 0, nil
+	}
+	// the first attempt chooses among the highest priority, retries among the lower ones; the in-memory cache
+	// chooses among all the channels when the highest priority is not positive
+	firstTier := len(available)
+	if !config.MemoryCacheEnabled || available[0].Priority > 0 {
+		for i, candidate := range available {
+			if candidate.Priority != available[0].Priority {
+				firstTier = i
+				break
+			}
+		}
+	}
+	for i, candidate := range available {
+		if i < firstTier {
+			candidate.Tier = TierFirst
+			candidate.Probability = 1 / float64(firstTier)
+		} else {
+			candidate.Tier = TierRetry
+		}
+	}
+	return candidates, len(available), nil
+}
+
+// ExplainChannelSelection tells how a request for the model from the member groups is distributed right now,
+// following the precedence of the groups as the distributor does
+func ExplainChannelSelection(memberGroups []string, model string) ([]*GroupSelection, error) {
+	now := time.Now()
+	var selections []*GroupSelection
+	selected := false
+	for _, group := range ExpandGroups(memberGroups) {
+		selection := &GroupSelection{Group: group.Group, Member: group.Member, Denied: IsModelDeniedByRouting(group.Member, model)}
+		candidates, available, err := explainGroup(group.Group, model, now)
+		if err != nil {
+			return nil, err
+		}
+		selection.Candidates = candidates
+		if !selection.Denied && !selected && available > 0 {
+			selection.Selected, selected = true, true
+		}
+		if !selection.Selected {
+			// the group does not serve the request, its channels are not chosen
+			for _, candidate := range candidates {
+				candidate.Probability = 0
+			}
+		}
+		selections = append(selections, selection)
+	}
+	return selections, nil
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	FeatureAsync          = "async"
+	FeatureConversations  = "conversations"
+	FeatureMcp            = "mcp"
+	FeatureSpeechToSpeech = "speech_to_speech"
+)
+
+// FeatureFlagAllGroups enables a feature for all the user groups
+const FeatureFlagAllGroups = "*"
+
+var featureFlagsLock sync.RWMutex
+
+// FeatureFlags are the user groups each beta endpoint is rolled out to, the features not listed are enabled for all
+var FeatureFlags = map[string][]string{}
+
+func FeatureFlags2JSONString() string {
+	featureFlagsLock.RLock()
+	defer featureFlagsLock.RUnlock()
+	jsonBytes, err := json.Marshal(FeatureFlags)
+	if err != nil {
+		logger.SysError("error marshalling feature flags: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateFeatureFlagsByJSONString(jsonStr string) error {
+	featureFlags := make(map[string][]string)
+	if err := json.Unmarshal([]byte(jsonStr), &featureFlags); err != nil {
+		return err
+	}
+	for feature, groups := range featureFlags {
+		if feature == "" {
+			return fmt.Errorf("feature name is empty")
+		}
+		if groups == nil {
+			featureFlags[feature] = []string{}
+		}
+	}
+	featureFlagsLock.Lock()
+	defer featureFlagsLock.Unlock()
+	FeatureFlags = featureFlags
+	return nil
+}
+
+// IsFeatureEnabled tells whether the feature is rolled out to the group, an empty list of groups turns it off
+func IsFeatureEnabled(feature string, group string) bool {
+	featureFlagsLock.RLock()
+	defer featureFlagsLock.RUnlock()
+	groups, ok := FeatureFlags[feature]
+	if !ok {
+		return true
+	}
+	return slices.Contains(groups, FeatureFlagAllGroups) || slices.Contains(groups, group)
+}
+
+// GetFeatureFlags returns a copy of the flags for the status api
+func GetFeatureFlags() map[string][]string {
+	featureFlagsLock.RLock()
+	defer featureFlagsLock.RUnlock()
+	flags := make(map[string][]string, len(FeatureFlags))
+	for feature, groups := range FeatureFlags {
+		flags[feature] = slices.Clone(groups)
+	}
+	return flags
+}
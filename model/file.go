@@ -0,0 +1,78 @@
+package model
+
+import (
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+// File is a file uploaded through /v1/files, it is either kept by the gateway in Storage and uploaded to
+// the channel of its first fine-tuning job, or relayed to the channel at once when no storage is configured
+type File struct {
+	Id        int    `json:"id"`
+	FileId    string `json:"file_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	ChannelId int    `json:"channel_id"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	Bytes     int64  `json:"bytes" gorm:"bigint"`
+	// Storage is the backend keeping the content, it is empty when the content is only on the upstream
+	Storage string `json:"storage" gorm:"type:varchar(16)"`
+	// UpstreamFileId is the id of the file on the upstream of ChannelId
+	UpstreamFileId string `json:"upstream_file_id"`
+	// Data is the file object returned to the client
+	Data      string `json:"data" gorm:"type:text"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+	// ExpiresAt is when the stored file is deleted, 0 means never
+	ExpiresAt int64 `json:"expires_at" gorm:"bigint;index"`
+}
+
+func (f *File) Insert() error {
+	if f.CreatedAt == 0 {
+		f.CreatedAt = helper.GetTimestamp()
+	}
+	return DB.Create(f).Error
+}
+
+// UpdateUpstream records the copy of the stored file uploaded to the channel
+func (f *File) UpdateUpstream(channelId int, upstreamFileId string) error {
+	f.ChannelId = channelId
+	f.UpstreamFileId = upstreamFileId
+	return DB.Model(f).Select("channel_id", "upstream_file_id").Updates(f).Error
+}
+
+func GetUserFile(userId int, fileId string) (*File, error) {
+	file := &File{}
+	err := DB.Where("user_id = ? and file_id = ?", userId, fileId).
+		Where("expires_at = 0 or expires_at > ?", helper.GetTimestamp()).First(file).Error
+	return file, err
+}
+
+func GetUserFiles(userId int, purpose string) (files []*File, err error) {
+	query := DB.Where("user_id = ?", userId).Where("expires_at = 0 or expires_at > ?", helper.GetTimestamp())
+	if purpose != "" {
+		query = query.Where("purpose = ?", purpose)
+	}
+	err = query.Order("id desc").Find(&files).Error
+	return files, err
+}
+
+// GetUserStoredBytes returns the size of the files of the user kept by the gateway, the expired ones are not counted
+func GetUserStoredBytes(userId int) int64 {
+	var bytes int64
+	DB.Model(&File{}).Where("user_id = ? and storage <> ''", userId).
+		Where("expires_at = 0 or expires_at > ?", helper.GetTimestamp()).
+		Select(ifNullFunc() + "(sum(bytes), 0)").Scan(&bytes)
+	return bytes
+}
+
+func GetExpiredFiles() (files []*File, err error) {
+	err = DB.Where("expires_at > 0 and expires_at <= ?", helper.GetTimestamp()).Find(&files).Error
+	return files, err
+}
+
+// Delete removes the file, and its chunks from the vector stores it was added to
+func (f *File) Delete() error {
+	if err := DeleteFileFromVectorStores(f.FileId); err != nil {
+		return err
+	}
+	return DB.Delete(f).Error
+}
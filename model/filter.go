@@ -0,0 +1,73 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+const (
+	FilterStatusEnabled  = 1
+	FilterStatusDisabled = 2
+)
+
+const (
+	FilterScopeChannel = "channel" // target is the channel id
+	FilterScopeGroup   = "group"   // target is the user group
+)
+
+// Filter is a WASM module which transforms the request and response of a channel or a group
+type Filter struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"index"`
+	Scope       string `json:"scope" gorm:"type:varchar(16)"`
+	Target      string `json:"target" gorm:"type:varchar(64);index"`
+	Priority    int    `json:"priority" gorm:"default:0"` // filters with higher priority run first
+	Status      int    `json:"status" gorm:"default:1"`
+	Module      []byte `json:"-"`
+	Size        int    `json:"size"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// GetAllFilters returns the filters without their modules
+func GetAllFilters() ([]*Filter, error) {
+	var filters []*Filter
+	err := DB.Omit("module").Order("priority desc, id").Find(&filters).Error
+	return filters, err
+}
+
+func GetEnabledFilters() ([]*Filter, error) {
+	var filters []*Filter
+	err := DB.Where("status = ?", FilterStatusEnabled).Order("priority desc, id").Find(&filters).Error
+	return filters, err
+}
+
+func GetFilterById(id int) (*Filter, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	filter := Filter{Id: id}
+	err := DB.Omit("module").First(&filter, "id = ?", id).Error
+	return &filter, err
+}
+
+func (filter *Filter) Insert() error {
+	filter.Size = len(filter.Module)
+	filter.CreatedTime = helper.GetTimestamp()
+	filter.UpdatedTime = filter.CreatedTime
+	return DB.Create(filter).Error
+}
+
+// Update updates everything except the module
+func (filter *Filter) Update() error {
+	filter.UpdatedTime = helper.GetTimestamp()
+	return DB.Model(filter).Select("name", "scope", "target", "priority", "status", "updated_time").Updates(filter).Error
+}
+
+func DeleteFilterById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	return DB.Delete(&Filter{Id: id}).Error
+}
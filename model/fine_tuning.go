@@ -0,0 +1,171 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+)
+
+const (
+	FineTuningJobStatusSucceeded = "succeeded"
+	FineTuningJobStatusFailed    = "failed"
+	FineTuningJobStatusCancelled = "cancelled"
+)
+
+type FineTuningJob struct {
+	Id             int    `json:"id"`
+	JobId          string `json:"job_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId         int    `json:"user_id" gorm:"index"`
+	TokenId        int    `json:"token_id"`
+	TokenName      string `json:"token_name"`
+	ChannelId      int    `json:"channel_id"`
+	Group          string `json:"group" gorm:"type:varchar(32)"`
+	Model          string `json:"model"`
+	FineTunedModel string `json:"fine_tuned_model"`
+	Status         string `json:"status" gorm:"type:varchar(32);index"`
+	TrainedTokens  int64  `json:"trained_tokens" gorm:"bigint"`
+	Quota          int64  `json:"quota" gorm:"bigint"`
+	Billed         bool   `json:"billed"`
+	// Data is the latest job object returned by the upstream
+	Data      string `json:"data" gorm:"type:text"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+}
+
+type upstreamFineTuningJob struct {
+	Id             string `json:"id"`
+	Model          string `json:"model"`
+	FineTunedModel string `json:"fine_tuned_model"`
+	Status         string `json:"status"`
+	TrainedTokens  int64  `json:"trained_tokens"`
+}
+
+func IsFineTuningJobFinished(status string) bool {
+	return status == FineTuningJobStatusSucceeded || status == FineTuningJobStatusFailed || status == FineTuningJobStatusCancelled
+}
+
+// NewFineTuningJob tracks the job created by the upstream with data
+func NewFineTuningJob(userId int, tokenId int, tokenName string, channelId int, group string, data []byte) (*FineTuningJob, error) {
+	job := &FineTuningJob{
+		UserId:    userId,
+		TokenId:   tokenId,
+		TokenName: tokenName,
+		ChannelId: channelId,
+		Group:     group,
+		CreatedAt: helper.GetTimestamp(),
+	}
+	if err := job.apply(data); err != nil {
+		return nil, err
+	}
+	return job, DB.Create(job).Error
+}
+
+func (job *FineTuningJob) apply(data []byte) error {
+	var upstreamJob upstreamFineTuningJob
+	if err := json.Unmarshal(data, &upstreamJob); err != nil {
+		return err
+	}
+	if upstreamJob.Id == "" {
+		return fmt.Errorf("invalid fine-tuning job: %s", string(data))
+	}
+	job.JobId = upstreamJob.Id
+	if upstreamJob.Model != "" {
+		job.Model = upstreamJob.Model
+	}
+	job.FineTunedModel = upstreamJob.FineTunedModel
+	job.Status = upstreamJob.Status
+	job.TrainedTokens = upstreamJob.TrainedTokens
+	job.Data = string(data)
+	job.UpdatedAt = helper.GetTimestamp()
+	return nil
+}
+
+// Update saves the job object returned by the upstream, the job is billed once it has finished,
+// the cancelled jobs are billed for the tokens trained before the cancellation
+func (job *FineTuningJob) Update(ctx context.Context, data []byte) error {
+	if err := job.apply(data); err != nil {
+		return err
+	}
+	err := DB.Model(job).Select("fine_tuned_model", "status", "trained_tokens", "data", "updated_at").Updates(job).Error
+	if err != nil {
+		return err
+	}
+	if IsFineTuningJobFinished(job.Status) && job.TrainedTokens > 0 && !job.Billed {
+		job.bill(ctx)
+	}
+	return nil
+}
+
+// GetFineTuningQuota returns the quota of training tokens on the model for the group
+func GetFineTuningQuota(model string, group string, tokens int64) (int64, bool) {
+	ratio, ok := billingratio.GetFineTuningRatio(model)
+	if !ok {
+		return 0, false
+	}
+	ratio *= billingratio.GetGroupModelRatio(group, model)
+	return int64(math.Ceil(float64(tokens) * ratio)), true
+}
+
+func (job *FineTuningJob) bill(ctx context.Context) {
+	// the job may be refreshed by a request and the background job at the same time
+	result := DB.Model(&FineTuningJob{}).Where("id = ? and billed = ?", job.Id, false).Update("billed", true)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	job.Billed = true
+	quota, ok := GetFineTuningQuota(job.Model, job.Group, job.TrainedTokens)
+	if !ok {
+		logger.Errorf(ctx, "fine-tuning ratio of model %s not found, job %s is not billed", job.Model, job.JobId)
+		return
+	}
+	job.Quota = quota
+	DB.Model(job).Update("quota", quota)
+	if err := PostConsumeTokenQuota(job.TokenId, quota); err != nil {
+		// the token has been deleted since the job was created
+		logger.Warnf(ctx, "failed to consume token quota of fine-tuning job %s: %s", job.JobId, err.Error())
+		_ = DecreaseUserQuota(job.UserId, quota)
+	}
+	CacheInvalidateUser(job.UserId)
+	RecordConsumeLog(ctx, &Log{
+		UserId:       job.UserId,
+		ChannelId:    job.ChannelId,
+		PromptTokens: int(job.TrainedTokens),
+		ModelName:    job.Model,
+		TokenName:    job.TokenName,
+		Quota:        int(quota),
+		Content:      fmt.Sprintf("微调任务 %s 训练 %d tokens，微调模型 %s", job.JobId, job.TrainedTokens, job.FineTunedModel),
+	})
+	UpdateUserUsedQuotaAndRequestCount(job.UserId, quota)
+	UpdateChannelUsedQuota(job.ChannelId, quota)
+}
+
+func GetUserFineTuningJob(userId int, jobId string) (*FineTuningJob, error) {
+	job := &FineTuningJob{}
+	err := DB.Where("user_id = ? and job_id = ?", userId, jobId).First(job).Error
+	return job, err
+}
+
+// GetUserFineTuningJobs returns the jobs of the user created before the job after, if any
+func GetUserFineTuningJobs(userId int, after string, limit int) (jobs []*FineTuningJob, err error) {
+	query := DB.Where("user_id = ?", userId)
+	if after != "" {
+		job, err := GetUserFineTuningJob(userId, after)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("id < ?", job.Id)
+	}
+	err = query.Order("id desc").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+func GetUnfinishedFineTuningJobs() (jobs []*FineTuningJob, err error) {
+	err = DB.Where("status not in ?", []string{FineTuningJobStatusSucceeded, FineTuningJobStatusFailed, FineTuningJobStatusCancelled}).
+		Find(&jobs).Error
+	return jobs, err
+}
@@ -0,0 +1,154 @@
+package model
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/logger"
+	"gorm.io/gorm"
+)
+
+// FreeAllowance lets the users send a number of requests to some models for free every day,
+// the requests are counted apart from the quota and the count is reset at midnight
+type FreeAllowance struct {
+	Models []string `json:"models"`
+	// Groups are the user groups having the allowance, empty means all the groups
+	Groups        []string `json:"groups,omitempty"`
+	DailyRequests int      `json:"daily_requests"`
+}
+
+var freeAllowancesLock sync.RWMutex
+var FreeAllowances = map[string]FreeAllowance{}
+
+func FreeAllowances2JSONString() string {
+	freeAllowancesLock.RLock()
+	defer freeAllowancesLock.RUnlock()
+	jsonBytes, err := json.Marshal(FreeAllowances)
+	if err != nil {
+		logger.SysError("error marshalling free allowances: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateFreeAllowancesByJSONString(jsonStr string) error {
+	freeAllowances := make(map[string]FreeAllowance)
+	if err := json.Unmarshal([]byte(jsonStr), &freeAllowances); err != nil {
+		return err
+	}
+	freeAllowancesLock.Lock()
+	defer freeAllowancesLock.Unlock()
+	FreeAllowances = freeAllowances
+	return nil
+}
+
+// getFreeAllowance returns the allowance covering the model for the user group, by the order of the names
+func getFreeAllowance(group string, model string) (string, FreeAllowance, bool) {
+	freeAllowancesLock.RLock()
+	defer freeAllowancesLock.RUnlock()
+	names := make([]string, 0, len(FreeAllowances))
+	for name := range FreeAllowances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		allowance := FreeAllowances[name]
+		if allowance.DailyRequests <= 0 || !slices.Contains(allowance.Models, model) {
+			continue
+		}
+		if len(allowance.Groups) > 0 && !slices.Contains(allowance.Groups, group) {
+			continue
+		}
+		return name, allowance, true
+	}
+	return "", FreeAllowance{}, false
+}
+
+type FreeUsage struct {
+	Id     int    `json:"-"`
+	UserId int    `json:"-" gorm:"uniqueIndex:idx_free_usage"`
+	Day    string `json:"day" gorm:"type:varchar(10);uniqueIndex:idx_free_usage"`
+	Name   string `json:"name" gorm:"type:varchar(64);uniqueIndex:idx_free_usage"`
+	Count  int    `json:"count"`
+}
+
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
+
+func getFreeUsageCount(userId int, name string) int {
+	var count int
+	DB.Model(&FreeUsage{}).Select("count").Where("user_id = ? and day = ? and name = ?", userId, today(), name).Scan(&count)
+	return count
+}
+
+// HasFreeRequest tells if the request of the user is covered by a free allowance today
+func HasFreeRequest(userId int, group string, model string) bool {
+	name, allowance, ok := getFreeAllowance(group, model)
+	if !ok {
+		return false
+	}
+	return getFreeUsageCount(userId, name) < allowance.DailyRequests
+}
+
+// ConsumeFreeRequest counts a free request of the user, the concurrent requests may go slightly over the allowance
+func ConsumeFreeRequest(userId int, group string, model string) {
+	name, _, ok := getFreeAllowance(group, model)
+	if !ok {
+		return
+	}
+	day := today()
+	result := DB.Model(&FreeUsage{}).Where("user_id = ? and day = ? and name = ?", userId, day, name).Update("count", gorm.Expr("count + 1"))
+	if result.Error == nil && result.RowsAffected > 0 {
+		return
+	}
+	err := DB.Create(&FreeUsage{UserId: userId, Day: day, Name: name, Count: 1}).Error
+	if err != nil {
+		logger.SysError("failed to count free request: " + err.Error())
+	}
+}
+
+type FreeAllowanceStatus struct {
+	Name          string   `json:"name"`
+	Models        []string `json:"models"`
+	DailyRequests int      `json:"daily_requests"`
+	Used          int      `json:"used"`
+}
+
+// GetFreeAllowanceStatus returns the allowances of the user group and how much of them is used today
+func GetFreeAllowanceStatus(userId int, group string) []FreeAllowanceStatus {
+	freeAllowancesLock.RLock()
+	var statuses []FreeAllowanceStatus
+	for name, allowance := range FreeAllowances {
+		if allowance.DailyRequests <= 0 {
+			continue
+		}
+		if len(allowance.Groups) > 0 && !slices.Contains(allowance.Groups, group) {
+			continue
+		}
+		statuses = append(statuses, FreeAllowanceStatus{Name: name, Models: allowance.Models, DailyRequests: allowance.DailyRequests})
+	}
+	freeAllowancesLock.RUnlock()
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	for i := range statuses {
+		statuses[i].Used = getFreeUsageCount(userId, statuses[i].Name)
+	}
+	return statuses
+}
+
+// AutomaticallyDeleteOldFreeUsages removes the counts of the past days, they are no longer read
+func AutomaticallyDeleteOldFreeUsages() {
+	for {
+		if IsLeader() {
+			err := DB.Where("day < ?", today()).Delete(&FreeUsage{}).Error
+			if err != nil {
+				logger.SysError("failed to delete old free usages: " + err.Error())
+			}
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+// GlossaryTerm is a term of the glossaries used by the translation endpoint, the term is kept
+// as it is when the translation is empty, such as a brand name
+type GlossaryTerm struct {
+	Id          int    `json:"id"`
+	Term        string `json:"term" gorm:"type:varchar(255);index"`
+	Translation string `json:"translation" gorm:"default:''"`
+	// Language is the target language of the translation, such as zh, all languages if empty
+	Language string `json:"language" gorm:"type:varchar(16);default:''"`
+	// Groups are the user groups using the term separated by commas, all groups if empty
+	Groups      string `json:"groups" gorm:"default:''"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+func GetAllGlossaryTerms() ([]*GlossaryTerm, error) {
+	var terms []*GlossaryTerm
+	err := DB.Order("term, id").Find(&terms).Error
+	return terms, err
+}
+
+// GetGlossaryTerms returns the terms of the group for the target language, which appear in the text
+func GetGlossaryTerms(group string, language string, text string) ([]*GlossaryTerm, error) {
+	var terms []*GlossaryTerm
+	if err := DB.Order("term, id").Find(&terms).Error; err != nil {
+		return nil, err
+	}
+	text = strings.ToLower(text)
+	matched := make([]*GlossaryTerm, 0)
+	for _, term := range terms {
+		if term.AllowGroup(group) && term.AllowLanguage(language) && strings.Contains(text, strings.ToLower(term.Term)) {
+			matched = append(matched, term)
+		}
+	}
+	return matched, nil
+}
+
+func GetGlossaryTermById(id int) (*GlossaryTerm, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	term := GlossaryTerm{Id: id}
+	err := DB.First(&term, "id = ?", id).Error
+	return &term, err
+}
+
+func (term *GlossaryTerm) AllowGroup(group string) bool {
+	if term.Groups == "" {
+		return true
+	}
+	for _, allowed := range strings.Split(term.Groups, ",") {
+		if strings.TrimSpace(allowed) == group {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowLanguage matches the languages by their base, so that zh is the target language of zh-CN
+func (term *GlossaryTerm) AllowLanguage(language string) bool {
+	if term.Language == "" {
+		return true
+	}
+	base := func(code string) string {
+		code, _, _ = strings.Cut(strings.ToLower(strings.ReplaceAll(code, "_", "-")), "-")
+		return code
+	}
+	return strings.EqualFold(term.Language, language) || base(term.Language) == base(language)
+}
+
+func (term *GlossaryTerm) Insert() error {
+	term.CreatedTime = helper.GetTimestamp()
+	term.UpdatedTime = term.CreatedTime
+	return DB.Create(term).Error
+}
+
+func (term *GlossaryTerm) Update() error {
+	term.UpdatedTime = helper.GetTimestamp()
+	return DB.Model(term).Select("term", "translation", "language", "groups", "updated_time").Updates(term).Error
+}
+
+func DeleteGlossaryTermById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	return DB.Delete(&GlossaryTerm{Id: id}).Error
+}
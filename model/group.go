@@ -0,0 +1,110 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// GroupInheritance maps a group to the groups whose channels its users may also use, in order of precedence.
+// The requests served by the channels of an inherited group are billed with the ratio of the group inheriting it
+var groupInheritanceLock sync.RWMutex
+var GroupInheritance = map[string][]string{}
+
+func GroupInheritance2JSONString() string {
+	groupInheritanceLock.RLock()
+	defer groupInheritanceLock.RUnlock()
+	jsonBytes, err := json.Marshal(GroupInheritance)
+	if err != nil {
+		logger.SysError("error marshalling group inheritance: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupInheritanceByJSONString(jsonStr string) error {
+	groupInheritance := make(map[string][]string)
+	if err := json.Unmarshal([]byte(jsonStr), &groupInheritance); err != nil {
+		return err
+	}
+	for group, parents := range groupInheritance {
+		for _, parent := range parents {
+			if parent == "" || parent == group {
+				return fmt.Errorf("group %s: invalid parent group %q", group, parent)
+			}
+		}
+	}
+	groupInheritanceLock.Lock()
+	defer groupInheritanceLock.Unlock()
+	GroupInheritance = groupInheritance
+	return nil
+}
+
+// EffectiveGroup is a group whose channels a user may use, Member is the group of the user it is reached from,
+// whose ratio, filters and defaults apply to the requests
+type EffectiveGroup struct {
+	Group  string
+	Member string
+}
+
+// ExpandGroups lists the groups the member groups give access to by precedence: each member group followed by
+// the groups it inherits, depth first, a group reached again being skipped so that the cycles end
+func ExpandGroups(memberGroups []string) []EffectiveGroup {
+	groupInheritanceLock.RLock()
+	defer groupInheritanceLock.RUnlock()
+	var groups []EffectiveGroup
+	seen := make(map[string]bool)
+	var visit func(group string, member string)
+	visit = func(group string, member string) {
+		if group == "" || seen[group] {
+			return
+		}
+		seen[group] = true
+		groups = append(groups, EffectiveGroup{Group: group, Member: member})
+		for _, parent := range GroupInheritance[group] {
+			visit(parent, member)
+		}
+	}
+	for _, member := range memberGroups {
+		visit(member, member)
+	}
+	return groups
+}
+
+// GetGroups returns the group of the user followed by its extra groups, in order of precedence
+func (user *User) GetGroups() []string {
+	groups := []string{user.Group}
+	for _, group := range strings.Split(user.ExtraGroups, ",") {
+		group = strings.TrimSpace(group)
+		if group == "" || slices.Contains(groups, group) {
+			continue
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+func GetUserGroups(id int) ([]string, error) {
+	user := User{}
+	err := DB.Model(&User{}).Where("id = ?", id).Select(quoteCol("group"), "extra_groups").Find(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return user.GetGroups(), nil
+}
+
+// UpdateUserExtraGroups sets the extra groups of the user, separated by commas, "" to remove them all
+func UpdateUserExtraGroups(id int, extraGroups string) error {
+	user := User{Id: id, ExtraGroups: extraGroups}
+	if err := DB.Model(&User{}).Where("id = ?", id).Select(quoteCol("group")).Find(&user.Group).Error; err != nil {
+		return err
+	}
+	// the group of the user and the duplicates are dropped
+	extraGroups = strings.Join(user.GetGroups()[1:], ",")
+	err := DB.Model(&User{}).Where("id = ?", id).Update("extra_groups", extraGroups).Error
+	CacheInvalidateUser(id)
+	return err
+}
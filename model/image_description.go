@@ -0,0 +1,37 @@
+package model
+
+import "slices"
+
+const (
+	ImageDescriptionCaption = "caption"
+	ImageDescriptionOCR     = "ocr"
+
+	defaultImageCaptionPrompt = "Describe this image in detail for someone who cannot see it, including any text it contains. Answer with the description only."
+	defaultImageOCRPrompt     = "Transcribe all the text in this image exactly as it appears, keeping the layout with line breaks. Answer with the text only."
+)
+
+// ImageDescription replaces the images of the chat completions relayed to the channel by their description, for the
+// channels whose models have no vision, the images are described by Model, which is relayed on its own channel
+type ImageDescription struct {
+	Model string `json:"model,omitempty"`
+	// Mode is caption to describe the images, or ocr to transcribe their text
+	Mode   string `json:"mode,omitempty"`
+	Prompt string `json:"prompt,omitempty"`
+	// Models are the models of the channel without vision, all of them if empty
+	Models []string `json:"models,omitempty"`
+}
+
+// Applies tells whether the images of the requests of the model are described
+func (d *ImageDescription) Applies(modelName string) bool {
+	return d.Model != "" && (len(d.Models) == 0 || slices.Contains(d.Models, modelName))
+}
+
+func (d *ImageDescription) GetPrompt() string {
+	if d.Prompt != "" {
+		return d.Prompt
+	}
+	if d.Mode == ImageDescriptionOCR {
+		return defaultImageOCRPrompt
+	}
+	return defaultImageCaptionPrompt
+}
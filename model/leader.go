@@ -0,0 +1,96 @@
+package model
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// Lease is used for leader election when Redis is not available
+type Lease struct {
+	Name      string `json:"name" gorm:"primaryKey;type:varchar(64)"`
+	Holder    string `json:"holder" gorm:"type:varchar(64)"`
+	ExpiredAt int64  `json:"expired_at" gorm:"bigint"`
+}
+
+const leaderLeaseName = "leader"
+const leaderLeaseTTL = 30 * time.Second
+
+var isLeader atomic.Bool
+
+// IsLeader reports whether this node should run the scheduled background jobs.
+// Without leader election, only the master node runs them.
+func IsLeader() bool {
+	if !config.LeaderElectionEnabled {
+		return config.IsMasterNode
+	}
+	return isLeader.Load()
+}
+
+// renew the lease only if it is still held by this node, then extend its expiration
+var redisRenewLeaseScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`
+
+func acquireLeaseByRedis() (bool, error) {
+	ctx := context.Background()
+	key := "lease:" + leaderLeaseName
+	ttl := leaderLeaseTTL.Milliseconds()
+	renewed, err := common.RDB.Eval(ctx, redisRenewLeaseScript, []string{key}, config.NodeId, ttl).Int()
+	if err != nil {
+		return false, err
+	}
+	if renewed == 1 {
+		return true, nil
+	}
+	return common.RDB.SetNX(ctx, key, config.NodeId, leaderLeaseTTL).Result()
+}
+
+func acquireLeaseByDB() (bool, error) {
+	now := helper.GetTimestamp()
+	expiredAt := now + int64(leaderLeaseTTL.Seconds())
+	result := DB.Model(&Lease{}).
+		Where("name = ? and (holder = ? or expired_at < ?)", leaderLeaseName, config.NodeId, now).
+		Updates(map[string]any{"holder": config.NodeId, "expired_at": expiredAt})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+	// the lease may not exist yet, creating it fails if another node holds it
+	err := DB.Create(&Lease{Name: leaderLeaseName, Holder: config.NodeId, ExpiredAt: expiredAt}).Error
+	return err == nil, nil
+}
+
+func RunLeaderElection() {
+	for {
+		var acquired bool
+		var err error
+		if common.RedisEnabled {
+			acquired, err = acquireLeaseByRedis()
+		} else {
+			acquired, err = acquireLeaseByDB()
+		}
+		if err != nil {
+			logger.SysError("failed to acquire leader lease: " + err.Error())
+			acquired = false
+		}
+		if isLeader.Swap(acquired) != acquired {
+			if acquired {
+				logger.SysLogf("node %s became the leader", config.NodeId)
+			} else {
+				logger.SysLogf("node %s is no longer the leader", config.NodeId)
+			}
+		}
+		time.Sleep(leaderLeaseTTL / 3)
+	}
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+const (
+	LedgerTypeOpening    = "opening"    // the balance of the user when the ledger was started or restored
+	LedgerTypeTopUp      = "top_up"     // redemption codes and the top-ups by the admin api
+	LedgerTypeConsume    = "consume"    // the requests, with the corrections of the pre-consumed quota
+	LedgerTypeRefund     = "refund"     // the quota given back for failed requests
+	LedgerTypeAdjustment = "adjustment" // the edits of the quota by an admin
+	LedgerTypeTransfer   = "transfer"   // the transfers between users, with their fees
+	LedgerTypeReward     = "reward"     // the quota for new users and the invitation rewards
+	LedgerTypePlan       = "plan"       // the monthly quota of the plans
+	LedgerTypeCoupon     = "coupon"     // the bonuses of the top-up coupons
+	LedgerTypeMarkup     = "markup"     // the markups of the requests of the users of a reseller, credited to it
+)
+
+// QuotaLedgerEntry is a change of the quota of a user, the entries are only appended, so that the sum of the
+// entries of a user is its quota, the quota column being the balance kept up to date with them
+type QuotaLedgerEntry struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"user_id" gorm:"index:idx_ledger_user_time,priority:1"`
+	Type      string `json:"type" gorm:"type:varchar(16)"`
+	Amount    int64  `json:"amount" gorm:"bigint"` // positive for a credit, negative for a debit
+	Note      string `json:"note" gorm:"type:varchar(255)"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index:idx_ledger_user_time,priority:2"`
+}
+
+// appendQuotaLedger records a change of the quota of the user made in the transaction
+func appendQuotaLedger(tx *gorm.DB, userId int, amount int64, type_ string, note string) error {
+	if amount == 0 {
+		return nil
+	}
+	return tx.Create(&QuotaLedgerEntry{
+		UserId:    userId,
+		Type:      type_,
+		Amount:    amount,
+		Note:      note,
+		CreatedAt: helper.GetTimestamp(),
+	}).Error
+}
+
+// changeUserQuota adds amount to the quota of the user in the transaction and records it in the ledger
+func changeUserQuota(tx *gorm.DB, userId int, amount int64, type_ string, note string) error {
+	if amount == 0 {
+		return nil
+	}
+	result := tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("用户不存在")
+	}
+	return appendQuotaLedger(tx, userId, amount, type_, note)
+}
+
+// ChangeUserQuota adds amount to the quota of the user, negative to take quota, and records it in the ledger
+func ChangeUserQuota(userId int, amount int64, type_ string, note string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return changeUserQuota(tx, userId, amount, type_, note)
+	})
+}
+
+// openQuotaLedgers starts the ledgers of all the users with their current quota
+func openQuotaLedgers(tx *gorm.DB) error {
+	return tx.Exec("INSERT INTO quota_ledger_entries (user_id, type, amount, note, created_at) "+
+		"SELECT id, ?, quota, '', ? FROM users WHERE quota <> 0", LedgerTypeOpening, helper.GetTimestamp()).Error
+}
+
+func GetUserQuotaLedger(userId int, type_ string, startIdx int, num int) (entries []*QuotaLedgerEntry, err error) {
+	tx := DB.Where("user_id = ?", userId)
+	if type_ != "" {
+		tx = tx.Where("type = ?", type_)
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&entries).Error
+	return entries, err
+}
+
+// QuotaDiscrepancy is a user whose quota is not the sum of its ledger
+type QuotaDiscrepancy struct {
+	UserId      int    `json:"user_id"`
+	Username    string `json:"username"`
+	Quota       int64  `json:"quota"`
+	LedgerQuota int64  `json:"ledger_quota"`
+}
+
+// AuditQuotaLedgers recomputes the quota of the users from their ledgers and returns those which do not match,
+// the changes of the quota batched by BATCH_UPDATE_ENABLED are not counted until they are flushed
+func AuditQuotaLedgers() (discrepancies []*QuotaDiscrepancy, err error) {
+	err = DB.Table("users").
+		Select("users.id as user_id, users.username, users.quota, coalesce(ledger.amount, 0) as ledger_quota").
+		Joins("left join (select user_id, sum(amount) as amount from quota_ledger_entries group by user_id) ledger on ledger.user_id = users.id").
+		Where("users.quota <> coalesce(ledger.amount, 0)").
+		Order("users.id").
+		Scan(&discrepancies).Error
+	return discrepancies, err
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/redact"
 )
 
 type Log struct {
@@ -29,6 +31,12 @@ type Log struct {
 	ElapsedTime       int64  `json:"elapsed_time" gorm:"default:0"` // unit is ms
 	IsStream          bool   `json:"is_stream" gorm:"default:false"`
 	SystemPromptReset bool   `json:"system_prompt_reset" gorm:"default:false"`
+	Experiment        string `json:"experiment" gorm:"type:varchar(40);index;default:''"` // experiment name and variant
+	UpstreamRequestId string `json:"upstream_request_id" gorm:"type:varchar(255);default:''"`
+	// UpstreamAccount is the organization and the project the request was billed to by the upstream
+	UpstreamAccount string `json:"upstream_account" gorm:"type:varchar(160);default:''"`
+	// Project is the internal project of the client the usage is attributed to, set by the X-Project header
+	Project string `json:"project" gorm:"type:varchar(64);index;default:''"`
 }
 
 const (
@@ -43,6 +51,7 @@ const (
 func recordLogHelper(ctx context.Context, log *Log) {
 	requestId := helper.GetRequestID(ctx)
 	log.RequestId = requestId
+	log.Content = redact.Text(log.Content)
 	err := LOG_DB.Create(log).Error
 	if err != nil {
 		logger.Error(ctx, "failed to record log: "+err.Error())
@@ -84,6 +93,26 @@ func RecordConsumeLog(ctx context.Context, log *Log) {
 	log.Username = GetUsernameById(log.UserId)
 	log.CreatedAt = helper.GetTimestamp()
 	log.Type = LogTypeConsume
+	log.Experiment = helper.GetExperiment(ctx)
+	log.Project = helper.GetProject(ctx)
+	if template := helper.GetPromptTemplate(ctx); template != "" {
+		log.Content += fmt.Sprintf("（模板 %s）", template)
+	}
+	if replayOf := helper.GetReplayOf(ctx); replayOf != "" {
+		log.Content += fmt.Sprintf("（重放请求 %s）", replayOf)
+	}
+	if summaryOf := helper.GetSummaryOf(ctx); summaryOf != "" {
+		log.Content += fmt.Sprintf("（请求 %s 的长上下文摘要）", summaryOf)
+	}
+	if screeningOf := helper.GetScreeningOf(ctx); screeningOf != "" {
+		log.Content += fmt.Sprintf("（请求 %s 的提示注入检测）", screeningOf)
+	}
+	if pipelineOf, stage := helper.GetPipelineStage(ctx); pipelineOf != "" {
+		log.Content += fmt.Sprintf("（请求 %s 的%s）", pipelineOf, stage)
+	}
+	if verdict := helper.GetPromptGuard(ctx); verdict != "" {
+		log.Content += fmt.Sprintf("（提示注入检测：%s）", verdict)
+	}
 	recordLogHelper(ctx, log)
 }
 
@@ -93,12 +122,12 @@ func RecordTestLog(ctx context.Context, log *Log) {
 	recordLogHelper(ctx, log)
 }
 
-func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int) (logs []*Log, err error) {
+func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int, project string) (logs []*Log, err error) {
 	var tx *gorm.DB
 	if logType == LogTypeUnknown {
-		tx = LOG_DB
+		tx = LOG_REPLICA_DB
 	} else {
-		tx = LOG_DB.Where("type = ?", logType)
+		tx = LOG_REPLICA_DB.Where("type = ?", logType)
 	}
 	if modelName != "" {
 		tx = tx.Where("model_name = ?", modelName)
@@ -118,16 +147,19 @@ func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName
 	if channel != 0 {
 		tx = tx.Where("channel_id = ?", channel)
 	}
+	if project != "" {
+		tx = tx.Where("project = ?", project)
+	}
 	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&logs).Error
 	return logs, err
 }
 
-func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, tokenName string, startIdx int, num int) (logs []*Log, err error) {
+func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, tokenName string, startIdx int, num int, project string) (logs []*Log, err error) {
 	var tx *gorm.DB
 	if logType == LogTypeUnknown {
-		tx = LOG_DB.Where("user_id = ?", userId)
+		tx = LOG_REPLICA_DB.Where("user_id = ?", userId)
 	} else {
-		tx = LOG_DB.Where("user_id = ? and type = ?", userId, logType)
+		tx = LOG_REPLICA_DB.Where("user_id = ? and type = ?", userId, logType)
 	}
 	if modelName != "" {
 		tx = tx.Where("model_name = ?", modelName)
@@ -141,26 +173,26 @@ func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int
 	if endTimestamp != 0 {
 		tx = tx.Where("created_at <= ?", endTimestamp)
 	}
+	if project != "" {
+		tx = tx.Where("project = ?", project)
+	}
 	err = tx.Order("id desc").Limit(num).Offset(startIdx).Omit("id").Find(&logs).Error
 	return logs, err
 }
 
 func SearchAllLogs(keyword string) (logs []*Log, err error) {
-	err = LOG_DB.Where("type = ? or content LIKE ?", keyword, keyword+"%").Order("id desc").Limit(config.MaxRecentItems).Find(&logs).Error
+	err = LOG_REPLICA_DB.Where("type = ? or content LIKE ?", keyword, keyword+"%").Order("id desc").Limit(config.MaxRecentItems).Find(&logs).Error
 	return logs, err
 }
 
 func SearchUserLogs(userId int, keyword string) (logs []*Log, err error) {
-	err = LOG_DB.Where("user_id = ? and type = ?", userId, keyword).Order("id desc").Limit(config.MaxRecentItems).Omit("id").Find(&logs).Error
+	err = LOG_REPLICA_DB.Where("user_id = ? and type = ?", userId, keyword).Order("id desc").Limit(config.MaxRecentItems).Omit("id").Find(&logs).Error
 	return logs, err
 }
 
-func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int) (quota int64) {
-	ifnull := "ifnull"
-	if common.UsingPostgreSQL {
-		ifnull = "COALESCE"
-	}
-	tx := LOG_DB.Table("logs").Select(fmt.Sprintf("%s(sum(quota),0)", ifnull))
+func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, project string) (quota int64) {
+	ifnull := ifNullFunc()
+	tx := LOG_REPLICA_DB.Table("logs").Select(fmt.Sprintf("%s(sum(quota),0)", ifnull))
 	if username != "" {
 		tx = tx.Where("username = ?", username)
 	}
@@ -179,16 +211,46 @@ func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelNa
 	if channel != 0 {
 		tx = tx.Where("channel_id = ?", channel)
 	}
+	if project != "" {
+		tx = tx.Where("project = ?", project)
+	}
 	tx.Where("type = ?", LogTypeConsume).Scan(&quota)
 	return quota
 }
 
-func SumUsedToken(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string) (token int) {
-	ifnull := "ifnull"
-	if common.UsingPostgreSQL {
-		ifnull = "COALESCE"
+type ProjectUsage struct {
+	Project          string `json:"project"`
+	RequestCount     int64  `json:"request_count"`
+	Quota            int64  `json:"quota"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+}
+
+// SumUsageByProject sums the consume logs by the project they are attributed to, the usage of the requests without
+// a project is summed under an empty project
+func SumUsageByProject(startTimestamp int64, endTimestamp int64, username string, tokenName string) (usages []*ProjectUsage, err error) {
+	tx := LOG_REPLICA_DB.Table("logs").
+		Select("project, count(1) as request_count, coalesce(sum(quota), 0) as quota, coalesce(sum(prompt_tokens), 0) as prompt_tokens, coalesce(sum(completion_tokens), 0) as completion_tokens").
+		Where("type = ?", LogTypeConsume)
+	if username != "" {
+		tx = tx.Where("username = ?", username)
+	}
+	if tokenName != "" {
+		tx = tx.Where("token_name = ?", tokenName)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
 	}
-	tx := LOG_DB.Table("logs").Select(fmt.Sprintf("%s(sum(prompt_tokens),0) + %s(sum(completion_tokens),0)", ifnull, ifnull))
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	err = tx.Group("project").Order("quota desc").Scan(&usages).Error
+	return usages, err
+}
+
+func SumUsedToken(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string) (token int) {
+	ifnull := ifNullFunc()
+	tx := LOG_REPLICA_DB.Table("logs").Select(fmt.Sprintf("%s(sum(prompt_tokens),0) + %s(sum(completion_tokens),0)", ifnull, ifnull))
 	if username != "" {
 		tx = tx.Where("username = ?", username)
 	}
@@ -210,7 +272,98 @@ func SumUsedToken(logType int, startTimestamp int64, endTimestamp int64, modelNa
 
 func DeleteOldLog(targetTimestamp int64) (int64, error) {
 	result := LOG_DB.Where("created_at < ?", targetTimestamp).Delete(&Log{})
-	return result.RowsAffected, result.Error
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	err := LOG_DB.Where("created_at < ?", targetTimestamp).Delete(&LogBody{}).Error
+	return result.RowsAffected, err
+}
+
+func userLogsQuery(userId int, tokenName string) *gorm.DB {
+	tx := LOG_DB.Model(&Log{}).Where("user_id = ?", userId)
+	if tokenName != "" {
+		tx = tx.Where("token_name = ?", tokenName)
+	}
+	return tx
+}
+
+// DeleteUserLogs hard-deletes all logs of the given user, or only the logs of one of its tokens if tokenName is set.
+func DeleteUserLogs(userId int, tokenName string) (int64, error) {
+	result := userLogsQuery(userId, tokenName).Delete(&Log{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, deleteUserLogBodies(userId, tokenName)
+}
+
+func deleteUserLogBodies(userId int, tokenName string) error {
+	tx := LOG_DB.Where("user_id = ?", userId)
+	if tokenName != "" {
+		tx = tx.Where("token_name = ?", tokenName)
+	}
+	return tx.Delete(&LogBody{}).Error
+}
+
+// AnonymizeUserLogs strips every user identifying field and stored content from the logs,
+// while keeping the usage numbers so that statistics remain correct.
+func AnonymizeUserLogs(userId int, tokenName string) (int64, error) {
+	result := userLogsQuery(userId, tokenName).Updates(map[string]any{
+		"user_id":    0,
+		"username":   "",
+		"token_name": "",
+		"content":    "",
+		"request_id": "",
+	})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	// the request bodies can not be anonymized
+	return result.RowsAffected, deleteUserLogBodies(userId, tokenName)
+}
+
+func AutomaticallyDeleteOldLogs(retentionDays int) {
+	for {
+		if !IsLeader() {
+			time.Sleep(time.Minute)
+			continue
+		}
+		targetTimestamp := helper.GetTimestamp() - int64(retentionDays)*24*60*60
+		count, err := DeleteOldLog(targetTimestamp)
+		if err != nil {
+			logger.SysError("failed to delete expired logs: " + err.Error())
+		} else {
+			logger.SysLogf("deleted %d logs older than %d days", count, retentionDays)
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+// GetUsageRecords returns the consume logs after the log cursor in the order of their ids, it stops before
+// the first log created since until, as the logs with smaller ids may still be being written then
+func GetUsageRecords(cursor int, until int64, limit int) (logs []*Log, err error) {
+	err = LOG_DB.Where("id > ? and type = ?", cursor, LogTypeConsume).Order("id asc").Limit(limit).Find(&logs).Error
+	for i, log := range logs {
+		if log.CreatedAt >= until {
+			return logs[:i], err
+		}
+	}
+	return logs, err
+}
+
+type UsageStat struct {
+	RequestCount     int   `json:"request_count"`
+	Quota            int64 `json:"quota"`
+	PromptTokens     int   `json:"prompt_tokens"`
+	CompletionTokens int   `json:"completion_tokens"`
+}
+
+// GetUsageStat sums the consume logs of all the users in the time range
+func GetUsageStat(startTimestamp int64, endTimestamp int64) (stat UsageStat, err error) {
+	err = LOG_REPLICA_DB.Model(&Log{}).
+		Select("count(1) as request_count, coalesce(sum(quota), 0) as quota, coalesce(sum(prompt_tokens), 0) as prompt_tokens, coalesce(sum(completion_tokens), 0) as completion_tokens").
+		Where("type = ? and created_at >= ? and created_at < ?", LogTypeConsume, startTimestamp, endTimestamp).
+		Scan(&stat).Error
+	return stat, err
 }
 
 type LogStatistic struct {
@@ -233,7 +386,7 @@ func SearchLogsByDayAndModel(userId, start, end int) (LogStatistics []*LogStatis
 		groupSelect = "strftime('%Y-%m-%d', datetime(created_at, 'unixepoch')) as day"
 	}
 
-	err = LOG_DB.Raw(`
+	err = LOG_REPLICA_DB.Raw(`
 		SELECT `+groupSelect+`,
 		model_name, count(1) as request_count,
 		sum(quota) as quota,
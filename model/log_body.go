@@ -0,0 +1,41 @@
+package model
+
+import (
+	"context"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/redact"
+)
+
+// LogBody is the full request body of a relayed request, recorded when LOG_REQUEST_BODY_ENABLED is set,
+// it is looked up by the request id shown in the logs and error messages, redacted by the log redaction rules
+type LogBody struct {
+	Id        int    `json:"id"`
+	RequestId string `json:"request_id" gorm:"type:varchar(64);index"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	TokenName string `json:"token_name" gorm:"default:''"`
+	Path      string `json:"path"`
+	Body      string `json:"body" gorm:"type:text"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+func RecordLogBody(ctx context.Context, userId int, tokenName string, path string, body []byte) {
+	logBody := &LogBody{
+		RequestId: helper.GetRequestID(ctx),
+		UserId:    userId,
+		TokenName: tokenName,
+		Path:      path,
+		Body:      string(redact.Body(body)),
+		CreatedAt: helper.GetTimestamp(),
+	}
+	if err := LOG_DB.Create(logBody).Error; err != nil {
+		logger.Error(ctx, "failed to record log body: "+err.Error())
+	}
+}
+
+func GetLogBodyByRequestId(requestId string) (*LogBody, error) {
+	logBody := &LogBody{}
+	err := LOG_DB.First(logBody, "request_id = ?", requestId).Error
+	return logBody, err
+}
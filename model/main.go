@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/config"
-	"github.com/songquanpeng/one-api/common/env"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/common/random"
@@ -15,12 +14,16 @@ import (
 	"gorm.io/gorm"
 	"os"
 	"strings"
-	"time"
 )
 
 var DB *gorm.DB
 var LOG_DB *gorm.DB
 
+// REPLICA_DB and LOG_REPLICA_DB serve the read-heavy dashboard queries,
+// they are the same as DB and LOG_DB if no read replica is configured
+var REPLICA_DB *gorm.DB
+var LOG_REPLICA_DB *gorm.DB
+
 func CreateRootAccountIfNeed() error {
 	var user User
 	//if user.Status != util.UserStatusEnabled {
@@ -44,6 +47,7 @@ func CreateRootAccountIfNeed() error {
 			Quota:       500000000000000,
 		}
 		DB.Create(&rootUser)
+		_ = appendQuotaLedger(DB, rootUser.Id, rootUser.Quota, LedgerTypeOpening, "")
 		if config.InitialRootToken != "" {
 			logger.SysLog("creating initial root token as requested")
 			token := Token{
@@ -65,10 +69,14 @@ func CreateRootAccountIfNeed() error {
 }
 
 func chooseDB(envName string) (*gorm.DB, error) {
-	dsn := os.Getenv(envName)
+	return OpenDB(os.Getenv(envName))
+}
 
+// OpenDB picks the database driver from the DSN: postgres:// or postgresql:// for PostgreSQL,
+// an empty DSN for SQLite, anything else for MySQL
+func OpenDB(dsn string) (*gorm.DB, error) {
 	switch {
-	case strings.HasPrefix(dsn, "postgres://"):
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
 		// Use PostgreSQL
 		return openPostgreSQL(dsn)
 	case dsn != "":
@@ -117,55 +125,36 @@ func InitDB() {
 	}
 
 	sqlDB := setDBConns(DB)
+	REPLICA_DB = openReplicaDB("SQL_REPLICA_DSN", DB)
 
-	if !config.IsMasterNode {
-		return
-	}
-
-	if common.UsingMySQL {
+	if config.IsMasterNode && common.UsingMySQL {
 		_, _ = sqlDB.Exec("DROP INDEX idx_channels_key ON channels;") // TODO: delete this line when most users have upgraded
 	}
 
-	logger.SysLog("database migration started")
-	if err = migrateDB(); err != nil {
-		logger.FatalLog("failed to migrate database: " + err.Error())
+	if err = prepareSchema(DB, migrationScopeMain, migrations); err != nil {
+		logger.FatalLog("failed to prepare database: " + err.Error())
 		return
 	}
-	logger.SysLog("database migrated")
+	if os.Getenv("LOG_SQL_DSN") == "" {
+		// the logs are kept in the main database
+		if err = prepareSchema(DB, migrationScopeLog, logMigrations); err != nil {
+			logger.FatalLog("failed to prepare database: " + err.Error())
+		}
+	}
 }
 
-func migrateDB() error {
-	var err error
-	if err = DB.AutoMigrate(&Channel{}); err != nil {
-		return err
-	}
-	if err = DB.AutoMigrate(&Token{}); err != nil {
-		return err
-	}
-	if err = DB.AutoMigrate(&User{}); err != nil {
-		return err
-	}
-	if err = DB.AutoMigrate(&Option{}); err != nil {
-		return err
-	}
-	if err = DB.AutoMigrate(&Redemption{}); err != nil {
-		return err
-	}
-	if err = DB.AutoMigrate(&Ability{}); err != nil {
-		return err
-	}
-	if err = DB.AutoMigrate(&Log{}); err != nil {
-		return err
-	}
-	if err = DB.AutoMigrate(&Channel{}); err != nil {
+// MigrateDB applies the pending migrations of the main database and of the log database
+func MigrateDB() error {
+	if err := migrateUp(DB, migrationScopeMain, migrations); err != nil {
 		return err
 	}
-	return nil
+	return migrateUp(LOG_DB, migrationScopeLog, logMigrations)
 }
 
 func InitLogDB() {
 	if os.Getenv("LOG_SQL_DSN") == "" {
 		LOG_DB = DB
+		LOG_REPLICA_DB = REPLICA_DB
 		return
 	}
 
@@ -178,26 +167,25 @@ func InitLogDB() {
 	}
 
 	setDBConns(LOG_DB)
+	LOG_REPLICA_DB = openReplicaDB("LOG_SQL_REPLICA_DSN", LOG_DB)
 
-	if !config.IsMasterNode {
-		return
-	}
-
-	logger.SysLog("secondary database migration started")
-	err = migrateLOGDB()
-	if err != nil {
-		logger.FatalLog("failed to migrate secondary database: " + err.Error())
-		return
+	if err = prepareSchema(LOG_DB, migrationScopeLog, logMigrations); err != nil {
+		logger.FatalLog("failed to prepare secondary database: " + err.Error())
 	}
-	logger.SysLog("secondary database migrated")
 }
 
-func migrateLOGDB() error {
-	var err error
-	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
-		return err
+func openReplicaDB(envName string, primary *gorm.DB) *gorm.DB {
+	if os.Getenv(envName) == "" {
+		return primary
 	}
-	return nil
+	logger.SysLog("using read replica database set by " + envName)
+	db, err := chooseDB(envName)
+	if err != nil {
+		logger.FatalLog("failed to initialize read replica database: " + err.Error())
+		return primary
+	}
+	setDBConns(db)
+	return db
 }
 
 func setDBConns(db *gorm.DB) *sql.DB {
@@ -211,9 +199,10 @@ func setDBConns(db *gorm.DB) *sql.DB {
 		return nil
 	}
 
-	sqlDB.SetMaxIdleConns(env.Int("SQL_MAX_IDLE_CONNS", 100))
-	sqlDB.SetMaxOpenConns(env.Int("SQL_MAX_OPEN_CONNS", 1000))
-	sqlDB.SetConnMaxLifetime(time.Second * time.Duration(env.Int("SQL_MAX_LIFETIME", 60)))
+	sqlDB.SetMaxIdleConns(config.SQLMaxIdleConns)
+	sqlDB.SetMaxOpenConns(config.SQLMaxOpenConns)
+	sqlDB.SetConnMaxLifetime(config.SQLConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(config.SQLConnMaxIdleTime)
 	return sqlDB
 }
 
@@ -227,6 +216,16 @@ func closeDB(db *gorm.DB) error {
 }
 
 func CloseDB() error {
+	if LOG_REPLICA_DB != nil && LOG_REPLICA_DB != LOG_DB && LOG_REPLICA_DB != REPLICA_DB {
+		if err := closeDB(LOG_REPLICA_DB); err != nil {
+			return err
+		}
+	}
+	if REPLICA_DB != nil && REPLICA_DB != DB {
+		if err := closeDB(REPLICA_DB); err != nil {
+			return err
+		}
+	}
 	if LOG_DB != DB {
 		err := closeDB(LOG_DB)
 		if err != nil {
@@ -0,0 +1,168 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/cron"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// TimeWindow starts when Cron fires and lasts Duration minutes, such as a known maintenance of the provider,
+// during which the channel is not routed and its failures are not alerted, or the hours of a routing rule
+type TimeWindow struct {
+	Cron     string `json:"cron"`
+	Duration int    `json:"duration"`
+	// Timezone is the IANA name of the zone Cron is evaluated in, e.g. America/Los_Angeles, the local zone by default
+	Timezone string `json:"timezone,omitempty"`
+}
+
+func (w *TimeWindow) Validate() error {
+	if _, err := cron.Parse(w.Cron); err != nil {
+		return err
+	}
+	if w.Duration <= 0 {
+		return fmt.Errorf("duration of %q must be positive", w.Cron)
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Active reports whether the window covers now
+func (w *TimeWindow) Active(now time.Time) (bool, error) {
+	schedule, err := cron.Parse(w.Cron)
+	if err != nil {
+		return false, err
+	}
+	location, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return false, err
+	}
+	minute := now.In(location).Truncate(time.Minute)
+	for i := 0; i < w.Duration; i++ {
+		if schedule.Match(minute.Add(-time.Duration(i) * time.Minute)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ValidateChannelConfig checks the parts of the channel config which are not checked when the channel is used
+func ValidateChannelConfig(config string) error {
+	if config == "" {
+		return nil
+	}
+	var cfg ChannelConfig
+	if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+		return err
+	}
+	for i := range cfg.Maintenance {
+		if err := cfg.Maintenance[i].Validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.DailyQuotaLimit < 0 || cfg.MonthlyQuotaLimit < 0 {
+		return fmt.Errorf("spend caps must not be negative")
+	}
+	if cfg.WarmUp != nil {
+		if err := cfg.WarmUp.Validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Dial != nil {
+		if err := cfg.Dial.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var maintenanceChannelsLock sync.RWMutex
+var maintenanceChannels = make(map[int]bool)
+
+func IsChannelInMaintenance(id int) bool {
+	maintenanceChannelsLock.RLock()
+	defer maintenanceChannelsLock.RUnlock()
+	return maintenanceChannels[id]
+}
+
+func GetMaintenanceChannelIds() []int {
+	maintenanceChannelsLock.RLock()
+	defer maintenanceChannelsLock.RUnlock()
+	ids := make([]int, 0, len(maintenanceChannels))
+	for id := range maintenanceChannels {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// excludeMaintenanceChannels unlike excludeThrottledChannels leaves no channel when all of them are in maintenance
+func excludeMaintenanceChannels(channels []*Channel) []*Channel {
+	maintenanceChannelsLock.RLock()
+	defer maintenanceChannelsLock.RUnlock()
+	if len(maintenanceChannels) == 0 {
+		return channels
+	}
+	available := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if !maintenanceChannels[channel.Id] {
+			available = append(available, channel)
+		}
+	}
+	return available
+}
+
+func refreshMaintenanceChannels() {
+	var channels []*Channel
+	err := DB.Select("id", "config").Where("config like ?", "%maintenance%").Find(&channels).Error
+	if err != nil {
+		logger.SysError("failed to load channel maintenance windows: " + err.Error())
+		return
+	}
+	now := time.Now()
+	active := make(map[int]bool)
+	for _, channel := range channels {
+		cfg, err := channel.LoadConfig()
+		if err != nil {
+			continue
+		}
+		for i := range cfg.Maintenance {
+			ok, err := cfg.Maintenance[i].Active(now)
+			if err != nil {
+				logger.SysError(fmt.Sprintf("invalid maintenance window of channel #%d: %s", channel.Id, err.Error()))
+				continue
+			}
+			if ok {
+				active[channel.Id] = true
+				break
+			}
+		}
+	}
+	maintenanceChannelsLock.Lock()
+	defer maintenanceChannelsLock.Unlock()
+	for id := range active {
+		if !maintenanceChannels[id] {
+			logger.SysLogf("channel #%d enters its maintenance window", id)
+		}
+	}
+	for id := range maintenanceChannels {
+		if !active[id] {
+			logger.SysLogf("channel #%d leaves its maintenance window", id)
+		}
+	}
+	maintenanceChannels = active
+}
+
+// SyncChannelMaintenance runs on every node because the channel selection is local, the windows of the routing
+// rules are checked along with the maintenance windows
+func SyncChannelMaintenance() {
+	for {
+		refreshMaintenanceChannels()
+		refreshRoutingRules()
+		time.Sleep(time.Minute)
+	}
+}
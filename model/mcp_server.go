@@ -0,0 +1,98 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+const (
+	McpServerStatusEnabled  = 1
+	McpServerStatusDisabled = 2
+)
+
+// McpServer is an MCP tool server whose tools are offered by the MCP endpoint of the gateway,
+// its tools are named with the name of the server as prefix
+type McpServer struct {
+	Id   int    `json:"id"`
+	Name string `json:"name" gorm:"type:varchar(64);uniqueIndex"`
+	URL  string `json:"url"`
+	// Headers is a JSON object of the headers sent to the server, such as the credentials
+	Headers string `json:"headers" gorm:"type:text"`
+	// Groups are the user groups allowed to use the server separated by commas, all groups if empty
+	Groups      string `json:"groups" gorm:"default:''"`
+	Quota       int64  `json:"quota" gorm:"bigint;default:0"` // billed for each call of the tools
+	Status      int    `json:"status" gorm:"default:1"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+func GetAllMcpServers() ([]*McpServer, error) {
+	var servers []*McpServer
+	err := DB.Order("id").Find(&servers).Error
+	return servers, err
+}
+
+// GetEnabledMcpServers returns the enabled servers which the group may use
+func GetEnabledMcpServers(group string) ([]*McpServer, error) {
+	var servers []*McpServer
+	if err := DB.Where("status = ?", McpServerStatusEnabled).Order("id").Find(&servers).Error; err != nil {
+		return nil, err
+	}
+	allowed := make([]*McpServer, 0, len(servers))
+	for _, server := range servers {
+		if server.AllowGroup(group) {
+			allowed = append(allowed, server)
+		}
+	}
+	return allowed, nil
+}
+
+func GetMcpServerById(id int) (*McpServer, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	server := McpServer{Id: id}
+	err := DB.First(&server, "id = ?", id).Error
+	return &server, err
+}
+
+func (server *McpServer) AllowGroup(group string) bool {
+	if server.Groups == "" {
+		return true
+	}
+	for _, allowed := range strings.Split(server.Groups, ",") {
+		if strings.TrimSpace(allowed) == group {
+			return true
+		}
+	}
+	return false
+}
+
+func (server *McpServer) GetHeaders() map[string]string {
+	headers := make(map[string]string)
+	if server.Headers != "" {
+		_ = json.Unmarshal([]byte(server.Headers), &headers)
+	}
+	return headers
+}
+
+func (server *McpServer) Insert() error {
+	server.CreatedTime = helper.GetTimestamp()
+	server.UpdatedTime = server.CreatedTime
+	return DB.Create(server).Error
+}
+
+func (server *McpServer) Update() error {
+	server.UpdatedTime = helper.GetTimestamp()
+	return DB.Model(server).Select("name", "url", "headers", "groups", "quota", "status", "updated_time").Updates(server).Error
+}
+
+func DeleteMcpServerById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	return DB.Delete(&McpServer{Id: id}).Error
+}
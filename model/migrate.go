@@ -0,0 +1,110 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const migrateBatchSize = 500
+
+// migratedTable is a table created by the migrations, with the model it is copied through
+type migratedTable struct {
+	model  any
+	schema *schema.Schema
+}
+
+// migratedTables returns the tables of the models of the migrations, each once, in the order they were created,
+// so that the copy never drifts from the schema
+func migratedTables(db *gorm.DB) ([]migratedTable, error) {
+	var tables []migratedTable
+	seen := make(map[string]bool)
+	for _, list := range [][]Migration{migrations, logMigrations} {
+		for _, migration := range list {
+			for _, model := range migration.Models {
+				stmt := &gorm.Statement{DB: db}
+				if err := stmt.Parse(model); err != nil {
+					return nil, err
+				}
+				if seen[stmt.Schema.Table] {
+					continue
+				}
+				seen[stmt.Schema.Table] = true
+				tables = append(tables, migratedTable{model: model, schema: stmt.Schema})
+			}
+		}
+	}
+	return tables, nil
+}
+
+// copyTable copies the rows in batches ordered by the primary key, which may be composite, as the one of the abilities
+func copyTable(src *gorm.DB, dst *gorm.DB, table migratedTable) error {
+	var order clause.OrderBy
+	for _, name := range table.schema.PrimaryFieldDBNames {
+		order.Columns = append(order.Columns, clause.OrderByColumn{Column: clause.Column{Name: name}})
+	}
+	rowsType := reflect.SliceOf(reflect.TypeOf(table.model))
+	total := 0
+	for offset := 0; ; offset += migrateBatchSize {
+		rows := reflect.New(rowsType)
+		// the rows in the trash are copied as well
+		query := src.Unscoped().Model(table.model)
+		if len(order.Columns) > 0 {
+			query = query.Clauses(order)
+		}
+		if err := query.Limit(migrateBatchSize).Offset(offset).Find(rows.Interface()).Error; err != nil {
+			return fmt.Errorf("failed to copy table %s: %w", table.schema.Table, err)
+		}
+		count := rows.Elem().Len()
+		if count == 0 {
+			break
+		}
+		// select all fields, otherwise zero values would be replaced by the column defaults
+		if err := dst.Select("*").Create(rows.Interface()).Error; err != nil {
+			return fmt.Errorf("failed to copy table %s: %w", table.schema.Table, err)
+		}
+		total += count
+		if count < migrateBatchSize {
+			break
+		}
+	}
+	logger.SysLogf("copied %d rows of table %s", total, table.schema.Table)
+	return nil
+}
+
+// resetPostgreSQLSequence makes sure the next inserted id won't conflict with the copied rows
+func resetPostgreSQLSequence(db *gorm.DB, table string) error {
+	return db.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %s", table, table)).Error
+}
+
+// MigrateData copies all data from src to dst, dst must be an empty database.
+// It is used to move an existing deployment from SQLite or MySQL to PostgreSQL.
+func MigrateData(src *gorm.DB, dst *gorm.DB, dstIsPostgreSQL bool) error {
+	if err := migrateUp(dst, migrationScopeMain, migrations); err != nil {
+		return fmt.Errorf("failed to migrate target schema: %w", err)
+	}
+	if err := migrateUp(dst, migrationScopeLog, logMigrations); err != nil {
+		return fmt.Errorf("failed to migrate target schema: %w", err)
+	}
+	tables, err := migratedTables(dst)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if err = copyTable(src, dst, table); err != nil {
+			return err
+		}
+		serial := table.schema.PrioritizedPrimaryField
+		if dstIsPostgreSQL && serial != nil && serial.AutoIncrement && serial.DBName == "id" {
+			if err = resetPostgreSQLSequence(dst, table.schema.Table); err != nil {
+				return fmt.Errorf("failed to reset sequence of table %s: %w", table.schema.Table, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T, name string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), name)), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestMigrateData(t *testing.T) {
+	src := openTestDB(t, "src.db")
+	dst := openTestDB(t, "dst.db")
+	Convey("every migrated table has a copier", t, func() {
+		So(migrateUp(src, migrationScopeMain, migrations), ShouldBeNil)
+		So(migrateUp(src, migrationScopeLog, logMigrations), ShouldBeNil)
+		names, err := src.Migrator().GetTables()
+		So(err, ShouldBeNil)
+		tables, err := migratedTables(src)
+		So(err, ShouldBeNil)
+		copied := map[string]bool{"schema_migrations": true}
+		for _, table := range tables {
+			copied[table.schema.Table] = true
+		}
+		for _, name := range names {
+			So(copied[name], ShouldBeTrue)
+		}
+	})
+	Convey("the abilities are copied in batches by their composite key", t, func() {
+		count := migrateBatchSize*2 + 1
+		abilities := make([]Ability, 0, count)
+		for i := 0; i < count; i++ {
+			abilities = append(abilities, Ability{Group: "default", Model: fmt.Sprintf("model-%d", i), ChannelId: i%3 + 1, Enabled: true})
+		}
+		So(src.CreateInBatches(abilities, 100).Error, ShouldBeNil)
+		So(src.Create(&QuotaLedgerEntry{UserId: 1, Type: "opening", Amount: 100}).Error, ShouldBeNil)
+		So(MigrateData(src, dst, false), ShouldBeNil)
+		var copied int64
+		So(dst.Model(&Ability{}).Count(&copied).Error, ShouldBeNil)
+		So(copied, ShouldEqual, count)
+		So(dst.Model(&QuotaLedgerEntry{}).Count(&copied).Error, ShouldBeNil)
+		So(copied, ShouldEqual, 1)
+	})
+}
@@ -0,0 +1,275 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/message"
+)
+
+// UserNotifications are the notifications a user can opt out of
+var UserNotifications = []string{
+	message.NotificationQuotaWarning,
+	message.NotificationTokenExpiry,
+	message.NotificationMonthlyStatement,
+	message.NotificationTokenAnomaly,
+}
+
+// UserOptInNotifications are the notifications a user has to opt in to
+var UserOptInNotifications = []string{
+	message.NotificationDailyDigest,
+	message.NotificationWeeklyDigest,
+}
+
+func GetUserNotificationPreferences(userId int) (map[string]bool, error) {
+	var user User
+	err := DB.Select("id", "disabled_notifications", "enabled_notifications").Where("id = ?", userId).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	disabled := strings.Split(user.DisabledNotifications, ",")
+	enabled := strings.Split(user.EnabledNotifications, ",")
+	preferences := make(map[string]bool, len(UserNotifications)+len(UserOptInNotifications))
+	for _, kind := range UserNotifications {
+		preferences[kind] = !slices.Contains(disabled, kind)
+	}
+	for _, kind := range UserOptInNotifications {
+		preferences[kind] = slices.Contains(enabled, kind)
+	}
+	return preferences, nil
+}
+
+func UpdateUserNotificationPreferences(userId int, preferences map[string]bool) error {
+	var disabled []string
+	for _, kind := range UserNotifications {
+		if enabled, ok := preferences[kind]; ok && !enabled {
+			disabled = append(disabled, kind)
+		}
+	}
+	var optedIn []string
+	for _, kind := range UserOptInNotifications {
+		if preferences[kind] {
+			optedIn = append(optedIn, kind)
+		}
+	}
+	return DB.Model(&User{}).Where("id = ?", userId).Updates(map[string]any{
+		"disabled_notifications": strings.Join(disabled, ","),
+		"enabled_notifications":  strings.Join(optedIn, ","),
+	}).Error
+}
+
+// NotifyUser emails the notification to the user, it does nothing if the user has no email, has opted out,
+// or has not opted in to a notification sent on request only
+func NotifyUser(userId int, kind string, data map[string]any) error {
+	var user User
+	err := DB.Select("id", "email", "disabled_notifications", "enabled_notifications").Where("id = ?", userId).First(&user).Error
+	if err != nil {
+		return err
+	}
+	if user.Email == "" || slices.Contains(strings.Split(user.DisabledNotifications, ","), kind) {
+		return nil
+	}
+	if slices.Contains(UserOptInNotifications, kind) && !slices.Contains(strings.Split(user.EnabledNotifications, ","), kind) {
+		return nil
+	}
+	subject, content, err := message.RenderNotification(kind, data)
+	if err != nil {
+		return err
+	}
+	return message.SendEmail(subject, user.Email, content)
+}
+
+func remindTokenExpiry() {
+	now := helper.GetTimestamp()
+	deadline := now + int64(config.TokenExpiryRemindDays)*24*60*60
+	var tokens []*Token
+	err := DB.Where("status = ? and expired_time <> -1 and expired_time > ? and expired_time <= ? and expiry_reminded = ?",
+		TokenStatusEnabled, now, deadline, false).Find(&tokens).Error
+	if err != nil {
+		logger.SysError("failed to get expiring tokens: " + err.Error())
+		return
+	}
+	for _, token := range tokens {
+		err = DB.Model(&Token{}).Where("id = ?", token.Id).Update("expiry_reminded", true).Error
+		if err != nil {
+			logger.SysError("failed to update token: " + err.Error())
+			continue
+		}
+		err = NotifyUser(token.UserId, message.NotificationTokenExpiry, map[string]any{
+			"TokenName": token.Name,
+			"ExpiredAt": time.Unix(token.ExpiredTime, 0).Format("2006-01-02 15:04:05"),
+		})
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to remind expiry of token %d: %s", token.Id, err.Error()))
+		}
+	}
+}
+
+type monthlyUsage struct {
+	UserId           int
+	RequestCount     int
+	Quota            int64
+	PromptTokens     int
+	CompletionTokens int
+}
+
+func sendMonthlyStatements(month time.Time) {
+	start := month.Unix()
+	end := month.AddDate(0, 1, 0).Unix()
+	var usages []monthlyUsage
+	err := LOG_DB.Model(&Log{}).
+		Select("user_id, count(1) as request_count, sum(quota) as quota, sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens").
+		Where("type = ? and created_at >= ? and created_at < ?", LogTypeConsume, start, end).
+		Group("user_id").Scan(&usages).Error
+	if err != nil {
+		logger.SysError("failed to sum monthly usages: " + err.Error())
+		return
+	}
+	for _, usage := range usages {
+		remainQuota, err := GetUserQuota(usage.UserId)
+		if err != nil {
+			continue
+		}
+		formatQuota := userQuotaFormatter(usage.UserId)
+		err = NotifyUser(usage.UserId, message.NotificationMonthlyStatement, map[string]any{
+			"Month":            month.Format("2006-01"),
+			"RequestCount":     usage.RequestCount,
+			"PromptTokens":     usage.PromptTokens,
+			"CompletionTokens": usage.CompletionTokens,
+			"Quota":            formatQuota(usage.Quota),
+			"RemainQuota":      formatQuota(remainQuota),
+		})
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to send monthly statement to user %d: %s", usage.UserId, err.Error()))
+		}
+	}
+	logger.SysLogf("monthly statements of %s sent to %d users", month.Format("2006-01"), len(usages))
+}
+
+type digestUsage struct {
+	UserId           int
+	ModelName        string
+	RequestCount     int
+	Quota            int64
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// sendUsageDigests sends the digest of the usage from start to end to the users who opted in to it and have used
+// the service in the period, summed by model from the consume logs
+func sendUsageDigests(kind string, period string, start time.Time, end time.Time) {
+	var userIds []int
+	err := DB.Model(&User{}).Where("enabled_notifications like ? and email <> ''", "%"+kind+"%").Pluck("id", &userIds).Error
+	if err != nil {
+		logger.SysError("failed to get digest subscribers: " + err.Error())
+		return
+	}
+	if len(userIds) == 0 {
+		return
+	}
+	var usages []digestUsage
+	err = LOG_DB.Model(&Log{}).
+		Select("user_id, model_name, count(1) as request_count, sum(quota) as quota, sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens").
+		Where("type = ? and created_at >= ? and created_at < ? and user_id in ?", LogTypeConsume, start.Unix(), end.Unix(), userIds).
+		Group("user_id, model_name").Scan(&usages).Error
+	if err != nil {
+		logger.SysError("failed to sum usages for digests: " + err.Error())
+		return
+	}
+	usagesByUser := make(map[int][]digestUsage)
+	for _, usage := range usages {
+		usagesByUser[usage.UserId] = append(usagesByUser[usage.UserId], usage)
+	}
+	for userId, usages := range usagesByUser {
+		sort.Slice(usages, func(i, j int) bool {
+			return usages[i].Quota > usages[j].Quota
+		})
+		formatQuota := userQuotaFormatter(userId)
+		var requestCount, promptTokens, completionTokens int
+		var quota int64
+		models := make([]map[string]any, 0, len(usages))
+		for _, usage := range usages {
+			requestCount += usage.RequestCount
+			promptTokens += usage.PromptTokens
+			completionTokens += usage.CompletionTokens
+			quota += usage.Quota
+			models = append(models, map[string]any{
+				"Model":        usage.ModelName,
+				"RequestCount": usage.RequestCount,
+				"Tokens":       usage.PromptTokens + usage.CompletionTokens,
+				"Quota":        formatQuota(usage.Quota),
+			})
+		}
+		remainQuota, err := GetUserQuota(userId)
+		if err != nil {
+			continue
+		}
+		err = NotifyUser(userId, kind, map[string]any{
+			"Period":           period,
+			"RequestCount":     requestCount,
+			"PromptTokens":     promptTokens,
+			"CompletionTokens": completionTokens,
+			"Quota":            formatQuota(quota),
+			"RemainQuota":      formatQuota(remainQuota),
+			"Models":           models,
+		})
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to send %s to user %d: %s", kind, userId, err.Error()))
+		}
+	}
+	logger.SysLogf("%s of %s sent to %d users", kind, period, len(usagesByUser))
+}
+
+// sendDueUsageDigests sends the daily digests of yesterday and the weekly digests of the last week, from Monday
+func sendDueUsageDigests(now time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	yesterday := today.AddDate(0, 0, -1)
+	if config.DailyDigestSentDay != yesterday.Format("2006-01-02") {
+		// save the day first, so that the digests are not sent twice if something goes wrong
+		if err := UpdateOption("DailyDigestSentDay", yesterday.Format("2006-01-02")); err != nil {
+			logger.SysError("failed to update option: " + err.Error())
+		} else {
+			sendUsageDigests(message.NotificationDailyDigest, yesterday.Format("2006-01-02"), yesterday, today)
+		}
+	}
+	thisWeek := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	lastWeek := thisWeek.AddDate(0, 0, -7)
+	if config.WeeklyDigestSentWeek != lastWeek.Format("2006-01-02") {
+		if err := UpdateOption("WeeklyDigestSentWeek", lastWeek.Format("2006-01-02")); err != nil {
+			logger.SysError("failed to update option: " + err.Error())
+		} else {
+			period := lastWeek.Format("2006-01-02") + " ~ " + thisWeek.AddDate(0, 0, -1).Format("2006-01-02")
+			sendUsageDigests(message.NotificationWeeklyDigest, period, lastWeek, thisWeek)
+		}
+	}
+}
+
+// AutomaticallySendNotifications reminds the expiring tokens and sends the monthly statements and the usage digests
+// on the leader node
+func AutomaticallySendNotifications() {
+	for {
+		if IsLeader() {
+			if config.TokenExpiryRemindDays > 0 {
+				remindTokenExpiry()
+			}
+			now := time.Now()
+			lastMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -1, 0)
+			if config.MonthlyStatementEnabled && config.MonthlyStatementSentMonth != lastMonth.Format("2006-01") {
+				// save the month first, so that the statements are not sent twice if something goes wrong
+				if err := UpdateOption("MonthlyStatementSentMonth", lastMonth.Format("2006-01")); err != nil {
+					logger.SysError("failed to update option: " + err.Error())
+				} else {
+					sendMonthlyStatements(lastMonth)
+				}
+			}
+			sendDueUsageDigests(now)
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -3,7 +3,15 @@ package model
 import (
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/message"
+	"github.com/songquanpeng/one-api/common/redact"
 	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+	"github.com/songquanpeng/one-api/relay/capability"
+	"github.com/songquanpeng/one-api/relay/chaos"
+	"github.com/songquanpeng/one-api/relay/defaults"
+	"github.com/songquanpeng/one-api/relay/filter"
+	"github.com/songquanpeng/one-api/relay/guard"
+	"github.com/songquanpeng/one-api/relay/sandbox"
 	"strconv"
 	"strings"
 	"time"
@@ -29,9 +37,13 @@ func InitOptionMap() {
 	config.OptionMap["EmailVerificationEnabled"] = strconv.FormatBool(config.EmailVerificationEnabled)
 	config.OptionMap["GitHubOAuthEnabled"] = strconv.FormatBool(config.GitHubOAuthEnabled)
 	config.OptionMap["OidcEnabled"] = strconv.FormatBool(config.OidcEnabled)
+	config.OptionMap["SAMLEnabled"] = strconv.FormatBool(config.SAMLEnabled)
 	config.OptionMap["WeChatAuthEnabled"] = strconv.FormatBool(config.WeChatAuthEnabled)
 	config.OptionMap["TurnstileCheckEnabled"] = strconv.FormatBool(config.TurnstileCheckEnabled)
 	config.OptionMap["RegisterEnabled"] = strconv.FormatBool(config.RegisterEnabled)
+	config.OptionMap["TwoFactorEnforcedRoles"] = config.TwoFactorEnforcedRoles
+	config.OptionMap["RelayCORSAllowedOrigins"] = config.RelayCORSAllowedOrigins
+	config.OptionMap["AdminCORSAllowedOrigins"] = config.AdminCORSAllowedOrigins
 	config.OptionMap["AutomaticDisableChannelEnabled"] = strconv.FormatBool(config.AutomaticDisableChannelEnabled)
 	config.OptionMap["AutomaticEnableChannelEnabled"] = strconv.FormatBool(config.AutomaticEnableChannelEnabled)
 	config.OptionMap["ApproximateTokenEnabled"] = strconv.FormatBool(config.ApproximateTokenEnabled)
@@ -39,6 +51,9 @@ func InitOptionMap() {
 	config.OptionMap["DisplayInCurrencyEnabled"] = strconv.FormatBool(config.DisplayInCurrencyEnabled)
 	config.OptionMap["DisplayTokenStatEnabled"] = strconv.FormatBool(config.DisplayTokenStatEnabled)
 	config.OptionMap["ChannelDisableThreshold"] = strconv.FormatFloat(config.ChannelDisableThreshold, 'f', -1, 64)
+	config.OptionMap["ResponseValidationEnabled"] = strconv.FormatBool(config.ResponseValidationEnabled)
+	config.OptionMap["MalformedResponseThreshold"] = strconv.FormatFloat(config.MalformedResponseThreshold, 'f', -1, 64)
+	config.OptionMap["MalformedResponseWindow"] = strconv.Itoa(config.MalformedResponseWindow)
 	config.OptionMap["EmailDomainRestrictionEnabled"] = strconv.FormatBool(config.EmailDomainRestrictionEnabled)
 	config.OptionMap["EmailDomainWhitelist"] = strings.Join(config.EmailDomainWhitelist, ",")
 	config.OptionMap["SMTPServer"] = ""
@@ -52,28 +67,109 @@ func InitOptionMap() {
 	config.OptionMap["Footer"] = config.Footer
 	config.OptionMap["SystemName"] = config.SystemName
 	config.OptionMap["Logo"] = config.Logo
+	config.OptionMap["Favicon"] = config.Favicon
 	config.OptionMap["ServerAddress"] = ""
 	config.OptionMap["GitHubClientId"] = ""
 	config.OptionMap["GitHubClientSecret"] = ""
+	config.OptionMap["SAMLIdPEntityId"] = ""
+	config.OptionMap["SAMLIdPSSOURL"] = ""
+	config.OptionMap["SAMLIdPCertificate"] = ""
+	config.OptionMap["SAMLUsernameAttribute"] = ""
+	config.OptionMap["SAMLEmailAttribute"] = ""
+	config.OptionMap["SAMLDisplayNameAttribute"] = ""
+	config.OptionMap["SAMLGroupAttribute"] = ""
+	config.OptionMap["SAMLGroupMapping"] = ""
 	config.OptionMap["WeChatServerAddress"] = ""
 	config.OptionMap["WeChatServerToken"] = ""
 	config.OptionMap["WeChatAccountQRCodeImageURL"] = ""
 	config.OptionMap["MessagePusherAddress"] = ""
 	config.OptionMap["MessagePusherToken"] = ""
+	config.OptionMap["TelegramBotToken"] = ""
+	config.OptionMap["TelegramWebhookSecretToken"] = ""
+	config.OptionMap["LarkBotAppId"] = ""
+	config.OptionMap["LarkBotAppSecret"] = ""
+	config.OptionMap["LarkBotVerificationToken"] = ""
+	config.OptionMap["DingTalkBotAppKey"] = ""
+	config.OptionMap["DingTalkBotAppSecret"] = ""
+	config.OptionMap["BotChatEnabled"] = strconv.FormatBool(config.BotChatEnabled)
+	config.OptionMap["BotChatModel"] = config.BotChatModel
 	config.OptionMap["TurnstileSiteKey"] = ""
 	config.OptionMap["TurnstileSecretKey"] = ""
 	config.OptionMap["QuotaForNewUser"] = strconv.FormatInt(config.QuotaForNewUser, 10)
+	config.OptionMap["NewUserQuotaEmailRequiredEnabled"] = strconv.FormatBool(config.NewUserQuotaEmailRequiredEnabled)
 	config.OptionMap["QuotaForInviter"] = strconv.FormatInt(config.QuotaForInviter, 10)
 	config.OptionMap["QuotaForInvitee"] = strconv.FormatInt(config.QuotaForInvitee, 10)
+	config.OptionMap["InviterTopUpRewardRate"] = strconv.FormatFloat(config.InviterTopUpRewardRate, 'f', -1, 64)
+	config.OptionMap["InviteRewardDedupeEnabled"] = strconv.FormatBool(config.InviteRewardDedupeEnabled)
+	config.OptionMap["QuotaTransferEnabled"] = strconv.FormatBool(config.QuotaTransferEnabled)
+	config.OptionMap["QuotaTransferMinQuota"] = strconv.FormatInt(config.QuotaTransferMinQuota, 10)
+	config.OptionMap["QuotaTransferMaxQuota"] = strconv.FormatInt(config.QuotaTransferMaxQuota, 10)
+	config.OptionMap["QuotaTransferDailyQuota"] = strconv.FormatInt(config.QuotaTransferDailyQuota, 10)
+	config.OptionMap["QuotaTransferFeeRate"] = strconv.FormatFloat(config.QuotaTransferFeeRate, 'f', -1, 64)
+	config.OptionMap["StatusPageEnabled"] = strconv.FormatBool(config.StatusPageEnabled)
+	config.OptionMap["StatusPageModels"] = config.StatusPageModels
+	config.OptionMap["StatusPageWindowHours"] = strconv.Itoa(config.StatusPageWindowHours)
 	config.OptionMap["QuotaRemindThreshold"] = strconv.FormatInt(config.QuotaRemindThreshold, 10)
+	config.OptionMap["TokenExpiryRemindDays"] = strconv.Itoa(config.TokenExpiryRemindDays)
+	config.OptionMap["MonthlyStatementEnabled"] = strconv.FormatBool(config.MonthlyStatementEnabled)
+	config.OptionMap["TokenAnomalyDetectionEnabled"] = strconv.FormatBool(config.TokenAnomalyDetectionEnabled)
+	config.OptionMap["TokenAnomalySpikeFactor"] = strconv.Itoa(config.TokenAnomalySpikeFactor)
+	config.OptionMap["TokenAnomalyNightHours"] = config.TokenAnomalyNightHours
+	config.OptionMap["MonthlyStatementSentMonth"] = config.MonthlyStatementSentMonth
+	config.OptionMap["DailyDigestSentDay"] = config.DailyDigestSentDay
+	config.OptionMap["WeeklyDigestSentWeek"] = config.WeeklyDigestSentWeek
+	config.OptionMap["NotificationTemplates"] = message.NotificationTemplates2JSONString()
 	config.OptionMap["PreConsumedQuota"] = strconv.FormatInt(config.PreConsumedQuota, 10)
 	config.OptionMap["ModelRatio"] = billingratio.ModelRatio2JSONString()
 	config.OptionMap["GroupRatio"] = billingratio.GroupRatio2JSONString()
+	config.OptionMap["GroupModelRatio"] = billingratio.GroupModelRatio2JSONString()
+	config.OptionMap["FineTuningRatio"] = billingratio.FineTuningRatio2JSONString()
+	config.OptionMap["FineTuningModel"] = config.FineTuningModel
+	config.OptionMap["FileMaxSize"] = strconv.FormatInt(config.FileMaxSize, 10)
+	config.OptionMap["FileUserStorageLimit"] = strconv.FormatInt(config.FileUserStorageLimit, 10)
+	config.OptionMap["FileRetentionDays"] = strconv.Itoa(config.FileRetentionDays)
+	config.OptionMap["VectorStoreEmbeddingModel"] = config.VectorStoreEmbeddingModel
+	config.OptionMap["VectorStoreChunkSize"] = strconv.Itoa(config.VectorStoreChunkSize)
+	config.OptionMap["VectorStoreChunkOverlap"] = strconv.Itoa(config.VectorStoreChunkOverlap)
+	config.OptionMap["RagPromptTemplate"] = config.RagPromptTemplate
+	config.OptionMap["JudgeModel"] = config.JudgeModel
+	config.OptionMap["JudgePrompt"] = config.JudgePrompt
+	config.OptionMap["CouncilModels"] = config.CouncilModels
+	config.OptionMap["CouncilJudgePrompt"] = config.CouncilJudgePrompt
+	config.OptionMap["LongContextSummaryModel"] = config.LongContextSummaryModel
+	config.OptionMap["LongContextSummaryPrompt"] = config.LongContextSummaryPrompt
+	config.OptionMap["WebSearchProvider"] = config.WebSearchProvider
+	config.OptionMap["WebSearchURL"] = config.WebSearchURL
+	config.OptionMap["WebSearchToken"] = ""
+	config.OptionMap["WebSearchMaxResults"] = strconv.Itoa(config.WebSearchMaxResults)
+	config.OptionMap["SentryDSN"] = config.SentryDSN
+	config.OptionMap["SentryEnvironment"] = config.SentryEnvironment
+	config.OptionMap["SentryErrorSpikeThreshold"] = strconv.Itoa(config.SentryErrorSpikeThreshold)
+	config.OptionMap["GroupRequestDefaults"] = defaults.GroupDefaults2JSONString()
+	config.OptionMap["ModelMaxTokens"] = defaults.ModelMaxTokens2JSONString()
+	config.OptionMap["ModelRelayProfiles"] = defaults.ModelRelayProfiles2JSONString()
+	config.OptionMap["ModelContextWindows"] = defaults.ModelContextWindows2JSONString()
+	config.OptionMap["ModelCapabilities"] = capability.ModelCapabilities2JSONString()
+	config.OptionMap["ModelDeprecations"] = defaults.ModelDeprecations2JSONString()
+	config.OptionMap["ErrorMessages"] = defaults.ErrorMessages2JSONString()
+	config.OptionMap["GroupResponseFilters"] = filter.GroupFilters2JSONString()
+	config.OptionMap["GroupPromptGuards"] = guard.GroupPromptGuards2JSONString()
+	config.OptionMap["LogRedactionRules"] = redact.LogRedactionRules2JSONString()
+	config.OptionMap["FreeRequestAllowances"] = FreeAllowances2JSONString()
+	config.OptionMap["GroupRoutingRules"] = GroupRoutingRules2JSONString()
+	config.OptionMap["GroupInheritance"] = GroupInheritance2JSONString()
+	config.OptionMap["SandboxFixtures"] = sandbox.Fixtures2JSONString()
+	config.OptionMap["FaultInjections"] = chaos.Faults2JSONString()
+	config.OptionMap["FeatureFlags"] = FeatureFlags2JSONString()
 	config.OptionMap["CompletionRatio"] = billingratio.CompletionRatio2JSONString()
 	config.OptionMap["TopUpLink"] = config.TopUpLink
 	config.OptionMap["ChatLink"] = config.ChatLink
 	config.OptionMap["QuotaPerUnit"] = strconv.FormatFloat(config.QuotaPerUnit, 'f', -1, 64)
+	config.OptionMap["BaseCurrency"] = config.BaseCurrency
+	config.OptionMap["ExchangeRates"] = billingratio.ExchangeRates2JSONString()
+	config.OptionMap["ExchangeRateURL"] = config.ExchangeRateURL
 	config.OptionMap["RetryTimes"] = strconv.Itoa(config.RetryTimes)
+	config.OptionMap["RequestDedupWindow"] = strconv.Itoa(config.RequestDedupWindow)
 	config.OptionMap["Theme"] = config.Theme
 	config.OptionMapRWMutex.Unlock()
 	loadOptionsFromDatabase()
@@ -92,6 +188,11 @@ func loadOptionsFromDatabase() {
 	}
 }
 
+// ReloadOptions reads all options from database again, it is used on SIGHUP
+func ReloadOptions() {
+	loadOptionsFromDatabase()
+}
+
 func SyncOptions(frequency int) {
 	for {
 		time.Sleep(time.Duration(frequency) * time.Second)
@@ -113,7 +214,12 @@ func UpdateOption(key string, value string) error {
 	// otherwise it will execute Update (with all fields).
 	DB.Save(&option)
 	// Update OptionMap
-	return updateOptionMap(key, value)
+	err := updateOptionMap(key, value)
+	if err != nil {
+		return err
+	}
+	publishCacheInvalidation(cacheTargetOptions)
+	return nil
 }
 
 func updateOptionMap(key string, value string) (err error) {
@@ -133,6 +239,8 @@ func updateOptionMap(key string, value string) (err error) {
 			config.GitHubOAuthEnabled = boolValue
 		case "OidcEnabled":
 			config.OidcEnabled = boolValue
+		case "SAMLEnabled":
+			config.SAMLEnabled = boolValue
 		case "WeChatAuthEnabled":
 			config.WeChatAuthEnabled = boolValue
 		case "TurnstileCheckEnabled":
@@ -145,6 +253,8 @@ func updateOptionMap(key string, value string) (err error) {
 			config.AutomaticDisableChannelEnabled = boolValue
 		case "AutomaticEnableChannelEnabled":
 			config.AutomaticEnableChannelEnabled = boolValue
+		case "ResponseValidationEnabled":
+			config.ResponseValidationEnabled = boolValue
 		case "ApproximateTokenEnabled":
 			config.ApproximateTokenEnabled = boolValue
 		case "LogConsumeEnabled":
@@ -153,6 +263,20 @@ func updateOptionMap(key string, value string) (err error) {
 			config.DisplayInCurrencyEnabled = boolValue
 		case "DisplayTokenStatEnabled":
 			config.DisplayTokenStatEnabled = boolValue
+		case "NewUserQuotaEmailRequiredEnabled":
+			config.NewUserQuotaEmailRequiredEnabled = boolValue
+		case "QuotaTransferEnabled":
+			config.QuotaTransferEnabled = boolValue
+		case "InviteRewardDedupeEnabled":
+			config.InviteRewardDedupeEnabled = boolValue
+		case "MonthlyStatementEnabled":
+			config.MonthlyStatementEnabled = boolValue
+		case "TokenAnomalyDetectionEnabled":
+			config.TokenAnomalyDetectionEnabled = boolValue
+		case "StatusPageEnabled":
+			config.StatusPageEnabled = boolValue
+		case "BotChatEnabled":
+			config.BotChatEnabled = boolValue
 		}
 	}
 	switch key {
@@ -191,12 +315,36 @@ func updateOptionMap(key string, value string) (err error) {
 		config.OidcTokenEndpoint = value
 	case "OidcUserinfoEndpoint":
 		config.OidcUserinfoEndpoint = value
+	case "TwoFactorEnforcedRoles":
+		config.TwoFactorEnforcedRoles = value
+	case "RelayCORSAllowedOrigins":
+		config.RelayCORSAllowedOrigins = value
+	case "AdminCORSAllowedOrigins":
+		config.AdminCORSAllowedOrigins = value
+	case "SAMLIdPEntityId":
+		config.SAMLIdPEntityId = value
+	case "SAMLIdPSSOURL":
+		config.SAMLIdPSSOURL = value
+	case "SAMLIdPCertificate":
+		config.SAMLIdPCertificate = value
+	case "SAMLUsernameAttribute":
+		config.SAMLUsernameAttribute = value
+	case "SAMLEmailAttribute":
+		config.SAMLEmailAttribute = value
+	case "SAMLDisplayNameAttribute":
+		config.SAMLDisplayNameAttribute = value
+	case "SAMLGroupAttribute":
+		config.SAMLGroupAttribute = value
+	case "SAMLGroupMapping":
+		config.SAMLGroupMapping = value
 	case "Footer":
 		config.Footer = value
 	case "SystemName":
 		config.SystemName = value
 	case "Logo":
 		config.Logo = value
+	case "Favicon":
+		config.Favicon = value
 	case "WeChatServerAddress":
 		config.WeChatServerAddress = value
 	case "WeChatServerToken":
@@ -207,6 +355,22 @@ func updateOptionMap(key string, value string) (err error) {
 		config.MessagePusherAddress = value
 	case "MessagePusherToken":
 		config.MessagePusherToken = value
+	case "TelegramBotToken":
+		config.TelegramBotToken = value
+	case "TelegramWebhookSecretToken":
+		config.TelegramWebhookSecretToken = value
+	case "LarkBotAppId":
+		config.LarkBotAppId = value
+	case "LarkBotAppSecret":
+		config.LarkBotAppSecret = value
+	case "LarkBotVerificationToken":
+		config.LarkBotVerificationToken = value
+	case "DingTalkBotAppKey":
+		config.DingTalkBotAppKey = value
+	case "DingTalkBotAppSecret":
+		config.DingTalkBotAppSecret = value
+	case "BotChatModel":
+		config.BotChatModel = value
 	case "TurnstileSiteKey":
 		config.TurnstileSiteKey = value
 	case "TurnstileSecretKey":
@@ -217,16 +381,124 @@ func updateOptionMap(key string, value string) (err error) {
 		config.QuotaForInviter, _ = strconv.ParseInt(value, 10, 64)
 	case "QuotaForInvitee":
 		config.QuotaForInvitee, _ = strconv.ParseInt(value, 10, 64)
+	case "InviterTopUpRewardRate":
+		config.InviterTopUpRewardRate, _ = strconv.ParseFloat(value, 64)
+	case "QuotaTransferMinQuota":
+		config.QuotaTransferMinQuota, _ = strconv.ParseInt(value, 10, 64)
+	case "QuotaTransferMaxQuota":
+		config.QuotaTransferMaxQuota, _ = strconv.ParseInt(value, 10, 64)
+	case "QuotaTransferDailyQuota":
+		config.QuotaTransferDailyQuota, _ = strconv.ParseInt(value, 10, 64)
+	case "QuotaTransferFeeRate":
+		config.QuotaTransferFeeRate, _ = strconv.ParseFloat(value, 64)
 	case "QuotaRemindThreshold":
 		config.QuotaRemindThreshold, _ = strconv.ParseInt(value, 10, 64)
+	case "StatusPageModels":
+		config.StatusPageModels = value
+	case "StatusPageWindowHours":
+		config.StatusPageWindowHours, _ = strconv.Atoi(value)
+	case "TokenExpiryRemindDays":
+		config.TokenExpiryRemindDays, _ = strconv.Atoi(value)
+	case "MonthlyStatementSentMonth":
+		config.MonthlyStatementSentMonth = value
+	case "DailyDigestSentDay":
+		config.DailyDigestSentDay = value
+	case "WeeklyDigestSentWeek":
+		config.WeeklyDigestSentWeek = value
+	case "TokenAnomalySpikeFactor":
+		config.TokenAnomalySpikeFactor, _ = strconv.Atoi(value)
+	case "TokenAnomalyNightHours":
+		config.TokenAnomalyNightHours = value
+	case "NotificationTemplates":
+		err = message.UpdateNotificationTemplatesByJSONString(value)
 	case "PreConsumedQuota":
 		config.PreConsumedQuota, _ = strconv.ParseInt(value, 10, 64)
 	case "RetryTimes":
 		config.RetryTimes, _ = strconv.Atoi(value)
+	case "RequestDedupWindow":
+		config.RequestDedupWindow, _ = strconv.Atoi(value)
 	case "ModelRatio":
 		err = billingratio.UpdateModelRatioByJSONString(value)
 	case "GroupRatio":
 		err = billingratio.UpdateGroupRatioByJSONString(value)
+	case "GroupModelRatio":
+		err = billingratio.UpdateGroupModelRatioByJSONString(value)
+	case "FineTuningRatio":
+		err = billingratio.UpdateFineTuningRatioByJSONString(value)
+	case "FineTuningModel":
+		config.FineTuningModel = value
+	case "FileMaxSize":
+		config.FileMaxSize, _ = strconv.ParseInt(value, 10, 64)
+	case "FileUserStorageLimit":
+		config.FileUserStorageLimit, _ = strconv.ParseInt(value, 10, 64)
+	case "FileRetentionDays":
+		config.FileRetentionDays, _ = strconv.Atoi(value)
+	case "VectorStoreEmbeddingModel":
+		config.VectorStoreEmbeddingModel = value
+	case "VectorStoreChunkSize":
+		config.VectorStoreChunkSize, _ = strconv.Atoi(value)
+	case "VectorStoreChunkOverlap":
+		config.VectorStoreChunkOverlap, _ = strconv.Atoi(value)
+	case "RagPromptTemplate":
+		config.RagPromptTemplate = value
+	case "JudgeModel":
+		config.JudgeModel = value
+	case "JudgePrompt":
+		config.JudgePrompt = value
+	case "CouncilModels":
+		config.CouncilModels = value
+	case "CouncilJudgePrompt":
+		config.CouncilJudgePrompt = value
+	case "LongContextSummaryModel":
+		config.LongContextSummaryModel = value
+	case "LongContextSummaryPrompt":
+		config.LongContextSummaryPrompt = value
+	case "WebSearchProvider":
+		config.WebSearchProvider = value
+	case "WebSearchURL":
+		config.WebSearchURL = value
+	case "WebSearchToken":
+		config.WebSearchToken = value
+	case "WebSearchMaxResults":
+		config.WebSearchMaxResults, _ = strconv.Atoi(value)
+	case "SentryDSN":
+		config.SentryDSN = value
+	case "SentryEnvironment":
+		config.SentryEnvironment = value
+	case "SentryErrorSpikeThreshold":
+		config.SentryErrorSpikeThreshold, _ = strconv.Atoi(value)
+	case "GroupRequestDefaults":
+		err = defaults.UpdateGroupDefaultsByJSONString(value)
+	case "ModelMaxTokens":
+		err = defaults.UpdateModelMaxTokensByJSONString(value)
+	case "ModelRelayProfiles":
+		err = defaults.UpdateModelRelayProfilesByJSONString(value)
+	case "ModelContextWindows":
+		err = defaults.UpdateModelContextWindowsByJSONString(value)
+	case "ModelCapabilities":
+		err = capability.UpdateModelCapabilitiesByJSONString(value)
+	case "ModelDeprecations":
+		err = defaults.UpdateModelDeprecationsByJSONString(value)
+	case "ErrorMessages":
+		err = defaults.UpdateErrorMessagesByJSONString(value)
+	case "GroupResponseFilters":
+		err = filter.UpdateGroupFiltersByJSONString(value)
+	case "GroupPromptGuards":
+		err = guard.UpdateGroupPromptGuardsByJSONString(value)
+	case "LogRedactionRules":
+		err = redact.UpdateLogRedactionRulesByJSONString(value)
+	case "FreeRequestAllowances":
+		err = UpdateFreeAllowancesByJSONString(value)
+	case "GroupRoutingRules":
+		err = UpdateGroupRoutingRulesByJSONString(value)
+	case "GroupInheritance":
+		err = UpdateGroupInheritanceByJSONString(value)
+	case "SandboxFixtures":
+		err = sandbox.UpdateFixturesByJSONString(value)
+	case "FaultInjections":
+		err = chaos.UpdateFaultsByJSONString(value)
+	case "FeatureFlags":
+		err = UpdateFeatureFlagsByJSONString(value)
 	case "CompletionRatio":
 		err = billingratio.UpdateCompletionRatioByJSONString(value)
 	case "TopUpLink":
@@ -235,8 +507,18 @@ func updateOptionMap(key string, value string) (err error) {
 		config.ChatLink = value
 	case "ChannelDisableThreshold":
 		config.ChannelDisableThreshold, _ = strconv.ParseFloat(value, 64)
+	case "MalformedResponseThreshold":
+		config.MalformedResponseThreshold, _ = strconv.ParseFloat(value, 64)
+	case "MalformedResponseWindow":
+		config.MalformedResponseWindow, _ = strconv.Atoi(value)
 	case "QuotaPerUnit":
 		config.QuotaPerUnit, _ = strconv.ParseFloat(value, 64)
+	case "BaseCurrency":
+		config.BaseCurrency = strings.ToUpper(value)
+	case "ExchangeRates":
+		err = billingratio.UpdateExchangeRatesByJSONString(value)
+	case "ExchangeRateURL":
+		config.ExchangeRateURL = value
 	case "Theme":
 		config.Theme = value
 	}
@@ -0,0 +1,208 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// Plan is a subscription assigned to users, their quota granted by the plan is reset every month
+type Plan struct {
+	Id           int    `json:"id"`
+	Name         string `json:"name" gorm:"type:varchar(64);uniqueIndex"`
+	MonthlyQuota int64  `json:"monthly_quota" gorm:"bigint;default:0"`
+	// Groups are the groups the users of the plan may use separated by commas, the first one is
+	// the group of the users assigned to the plan, the others are used when it has no channel for the model
+	Groups string `json:"groups" gorm:"default:''"`
+	// RPM is the maximum of relay requests of a user per minute, 0 means no limit
+	RPM         int   `json:"rpm" gorm:"default:0"`
+	CreatedTime int64 `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64 `json:"updated_time" gorm:"bigint"`
+}
+
+func GetAllPlans() ([]*Plan, error) {
+	var plans []*Plan
+	err := DB.Order("id").Find(&plans).Error
+	return plans, err
+}
+
+func GetPlanById(id int) (*Plan, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	plan := Plan{Id: id}
+	err := DB.First(&plan, "id = ?", id).Error
+	return &plan, err
+}
+
+// GetGroups returns the groups of the plan in order, without the empty ones
+func (plan *Plan) GetGroups() []string {
+	var groups []string
+	for _, group := range strings.Split(plan.Groups, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+func (plan *Plan) Insert() error {
+	plan.CreatedTime = helper.GetTimestamp()
+	plan.UpdatedTime = plan.CreatedTime
+	return DB.Create(plan).Error
+}
+
+// Update changes the plan, the new monthly quota is granted from the next reset of its users
+func (plan *Plan) Update() error {
+	plan.UpdatedTime = helper.GetTimestamp()
+	err := DB.Model(plan).Select("name", "monthly_quota", "groups", "rpm", "updated_time").Updates(plan).Error
+	cacheInvalidatePlan(plan.Id)
+	return err
+}
+
+func DeletePlanById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	var count int64
+	if err := DB.Model(&User{}).Where("plan_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("仍有 %d 个用户使用该套餐", count)
+	}
+	err := DB.Delete(&Plan{Id: id}).Error
+	cacheInvalidatePlan(id)
+	return err
+}
+
+// unusedPlanQuota is the quota granted by the plan which is left in the period, the quota consumed
+// in the period is counted on the quota of the plan first, then on the quota topped up
+func (user *User) unusedPlanQuota() int64 {
+	unused := user.PlanQuota - (user.UsedQuota - user.PlanUsedQuotaBase)
+	if unused > user.Quota {
+		unused = user.Quota
+	}
+	if unused < 0 {
+		unused = 0
+	}
+	return unused
+}
+
+// grantPlanQuota replaces the quota of the plan left to the user with grant, for the period ending at resetAt
+func grantPlanQuota(user *User, planId int, resetAt int64, grant int64, group string) error {
+	change := grant - user.unusedPlanQuota()
+	updates := map[string]any{
+		"quota":                gorm.Expr("quota + ?", change),
+		"plan_id":              planId,
+		"plan_reset_at":        resetAt,
+		"plan_quota":           grant,
+		"plan_used_quota_base": gorm.Expr("used_quota"),
+	}
+	if group != "" {
+		updates["group"] = group
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// the period must not have been changed meanwhile, by a reset or another change of the plan
+		result := tx.Model(&User{}).Where("id = ? and plan_id = ? and plan_reset_at = ?", user.Id, user.PlanId, user.PlanResetAt).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("用户的套餐已被修改，请重试")
+		}
+		note := fmt.Sprintf("套餐 #%d", planId)
+		if planId == 0 {
+			note = "取消套餐"
+		}
+		return appendQuotaLedger(tx, user.Id, change, LedgerTypePlan, note)
+	})
+	if err != nil {
+		return err
+	}
+	CacheInvalidateUser(user.Id)
+	return nil
+}
+
+// SetUserPlan assigns the plan to the user, 0 removes the plan of the user. A user without plan starts a new
+// period with the whole monthly quota, a user changing of plan keeps the period and gets the monthly quota
+// of the new plan prorated to what is left of it, the quota left from the previous plan is taken back
+func SetUserPlan(userId int, planId int) error {
+	user, err := GetUserById(userId, false)
+	if err != nil {
+		return err
+	}
+	if planId == 0 {
+		if user.PlanId == 0 {
+			return nil
+		}
+		return grantPlanQuota(user, 0, 0, 0, "")
+	}
+	plan, err := GetPlanById(planId)
+	if err != nil {
+		return err
+	}
+	group := ""
+	if groups := plan.GetGroups(); len(groups) > 0 && !slices.Contains(groups, user.Group) {
+		group = groups[0]
+	}
+	now := time.Now()
+	if user.PlanId == 0 {
+		return grantPlanQuota(user, plan.Id, now.AddDate(0, 1, 0).Unix(), plan.MonthlyQuota, group)
+	}
+	resetAt := time.Unix(user.PlanResetAt, 0)
+	period := resetAt.Sub(resetAt.AddDate(0, -1, 0))
+	left := resetAt.Sub(now)
+	if left < 0 {
+		left = 0
+	}
+	grant := int64(float64(plan.MonthlyQuota) * float64(left) / float64(period))
+	return grantPlanQuota(user, plan.Id, user.PlanResetAt, grant, group)
+}
+
+// ResetPlanQuotas starts the new period of the users whose period has ended, the quota left from the plan
+// in the period is not carried over, while the quota topped up is kept
+func ResetPlanQuotas() error {
+	now := time.Now()
+	var users []*User
+	err := DB.Where("plan_id <> 0 and plan_reset_at <= ?", now.Unix()).Find(&users).Error
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		plan, err := GetPlanById(user.PlanId)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to get plan %d of user %d: %s", user.PlanId, user.Id, err.Error()))
+			continue
+		}
+		resetAt := time.Unix(user.PlanResetAt, 0)
+		for !resetAt.After(now) {
+			resetAt = resetAt.AddDate(0, 1, 0)
+		}
+		if err = grantPlanQuota(user, plan.Id, resetAt.Unix(), plan.MonthlyQuota, ""); err != nil {
+			logger.SysError(fmt.Sprintf("failed to reset the plan quota of user %d: %s", user.Id, err.Error()))
+			continue
+		}
+		RecordLog(context.Background(), user.Id, LogTypeSystem, fmt.Sprintf("套餐 %s 的额度已重置", plan.Name))
+	}
+	return nil
+}
+
+func AutomaticallyResetPlanQuotas() {
+	for {
+		if IsLeader() {
+			if err := ResetPlanQuotas(); err != nil {
+				logger.SysError("failed to reset plan quotas: " + err.Error())
+			}
+		}
+		time.Sleep(10 * time.Minute)
+	}
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+// PromptTemplate is a version of a named prompt template, updating a template adds a new version
+type PromptTemplate struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64);uniqueIndex:idx_prompt_template_name_version"`
+	Version     int    `json:"version" gorm:"uniqueIndex:idx_prompt_template_name_version"`
+	Description string `json:"description" gorm:"default:''"`
+	// Messages is a JSON array of chat messages, in which {{variable}} is replaced when rendering
+	Messages    string `json:"messages" gorm:"type:text"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+var templateVariablePattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// GetAllPromptTemplates returns the latest version of every template
+func GetAllPromptTemplates() ([]*PromptTemplate, error) {
+	var templates []*PromptTemplate
+	latest := DB.Model(&PromptTemplate{}).Select("name, max(version)").Group("name")
+	err := DB.Where("(name, version) IN (?)", latest).Order("name").Find(&templates).Error
+	return templates, err
+}
+
+func GetPromptTemplateVersions(name string) ([]*PromptTemplate, error) {
+	var templates []*PromptTemplate
+	err := DB.Where("name = ?", name).Order("version desc").Find(&templates).Error
+	return templates, err
+}
+
+// GetPromptTemplate returns the given version of the template, or the latest version if version is 0
+func GetPromptTemplate(name string, version int) (*PromptTemplate, error) {
+	template := PromptTemplate{}
+	tx := DB.Where("name = ?", name)
+	if version != 0 {
+		tx = tx.Where("version = ?", version)
+	}
+	err := tx.Order("version desc").First(&template).Error
+	return &template, err
+}
+
+func (template *PromptTemplate) parseMessages() ([]map[string]any, error) {
+	var messages []map[string]any
+	if err := json.Unmarshal([]byte(template.Messages), &messages); err != nil {
+		return nil, fmt.Errorf("消息格式错误：%w", err)
+	}
+	if len(messages) == 0 {
+		return nil, errors.New("消息不能为空")
+	}
+	return messages, nil
+}
+
+// Insert saves the template as the next version of its name
+func (template *PromptTemplate) Insert() error {
+	if _, err := template.parseMessages(); err != nil {
+		return err
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var version int
+		err := tx.Model(&PromptTemplate{}).Select("coalesce(max(version), 0)").Where("name = ?", template.Name).Scan(&version).Error
+		if err != nil {
+			return err
+		}
+		template.Id = 0
+		template.Version = version + 1
+		template.CreatedTime = helper.GetTimestamp()
+		return tx.Create(template).Error
+	})
+}
+
+func DeletePromptTemplate(name string) error {
+	return DB.Where("name = ?", name).Delete(&PromptTemplate{}).Error
+}
+
+func renderTemplateText(text string, variables map[string]any) (string, error) {
+	var missing string
+	rendered := templateVariablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := templateVariablePattern.FindStringSubmatch(match)[1]
+		value, ok := variables[name]
+		if !ok {
+			missing = name
+			return match
+		}
+		if s, ok := value.(string); ok {
+			return s
+		}
+		return fmt.Sprint(value)
+	})
+	if missing != "" {
+		return "", fmt.Errorf("缺少模板变量：%s", missing)
+	}
+	return rendered, nil
+}
+
+// Render returns the messages with the variables replaced, both string contents and text parts are rendered
+func (template *PromptTemplate) Render(variables map[string]any) ([]map[string]any, error) {
+	messages, err := template.parseMessages()
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range messages {
+		switch content := message["content"].(type) {
+		case string:
+			if message["content"], err = renderTemplateText(content, variables); err != nil {
+				return nil, err
+			}
+		case []any:
+			for _, part := range content {
+				if part, ok := part.(map[string]any); ok {
+					if text, ok := part["text"].(string); ok {
+						if part["text"], err = renderTemplateText(text, variables); err != nil {
+							return nil, err
+						}
+					}
+				}
+			}
+		}
+	}
+	return messages, nil
+}
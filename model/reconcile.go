@@ -0,0 +1,373 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/random"
+)
+
+// Channels and tokens can be declared in YAML or JSON files, usually a ConfigMap mounted into the pod,
+// with the keys in a mounted Secret referenced by key_file. The files are reconciled into the database:
+// resources are identified by their name, which is saved as external id with the prefix below,
+// so resources created from the web page are never touched. The names of the tokens are given per user,
+// their external id holds the id of the user as well.
+
+const reconcileExternalIdPrefix = "k8s/"
+
+type ChannelSpec struct {
+	Name         string            `json:"name" yaml:"name"`
+	Type         int               `json:"type" yaml:"type"`
+	Key          string            `json:"key" yaml:"key"`
+	KeyFile      string            `json:"key_file" yaml:"key_file"`
+	BaseURL      string            `json:"base_url" yaml:"base_url"`
+	Models       []string          `json:"models" yaml:"models"`
+	Group        string            `json:"group" yaml:"group"`
+	ModelMapping map[string]string `json:"model_mapping" yaml:"model_mapping"`
+	Priority     int64             `json:"priority" yaml:"priority"`
+	Weight       uint              `json:"weight" yaml:"weight"`
+	Config       map[string]string `json:"config" yaml:"config"`
+	Disabled     bool              `json:"disabled" yaml:"disabled"`
+}
+
+type TokenSpec struct {
+	Name           string   `json:"name" yaml:"name"`
+	User           string   `json:"user" yaml:"user"` // username of the owner
+	Key            string   `json:"key" yaml:"key"`   // generated if empty
+	KeyFile        string   `json:"key_file" yaml:"key_file"`
+	Quota          int64    `json:"quota" yaml:"quota"`
+	UnlimitedQuota bool     `json:"unlimited_quota" yaml:"unlimited_quota"`
+	ExpiredTime    int64    `json:"expired_time" yaml:"expired_time"` // unix timestamp, never expires if 0
+	Models         []string `json:"models" yaml:"models"`
+	Subnet         string   `json:"subnet" yaml:"subnet"`
+	Disabled       bool     `json:"disabled" yaml:"disabled"`
+}
+
+type ReconcileSpec struct {
+	Channels []ChannelSpec `json:"channels" yaml:"channels"`
+	Tokens   []TokenSpec   `json:"tokens" yaml:"tokens"`
+}
+
+func readKey(key string, keyFile string) (string, error) {
+	if keyFile == "" {
+		return key, nil
+	}
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// LoadReconcileSpec merges all YAML and JSON files in dir
+func LoadReconcileSpec(dir string) (*ReconcileSpec, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	spec := &ReconcileSpec{}
+	for _, entry := range entries {
+		// skip the ..data symlinks created by kubelet
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if ext != ".yaml" && ext != ".yml" && ext != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var fileSpec ReconcileSpec
+		if ext == ".json" {
+			err = json.Unmarshal(data, &fileSpec)
+		} else {
+			err = yaml.Unmarshal(data, &fileSpec)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		spec.Channels = append(spec.Channels, fileSpec.Channels...)
+		spec.Tokens = append(spec.Tokens, fileSpec.Tokens...)
+	}
+	return spec, nil
+}
+
+func (spec *ChannelSpec) toChannel() (*Channel, error) {
+	if spec.Name == "" {
+		return nil, errors.New("channel name is required")
+	}
+	key, err := readKey(spec.Key, spec.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, fmt.Errorf("channel %s: key is required", spec.Name)
+	}
+	group := spec.Group
+	if group == "" {
+		group = "default"
+	}
+	modelMapping := ""
+	if len(spec.ModelMapping) > 0 {
+		data, _ := json.Marshal(spec.ModelMapping)
+		modelMapping = string(data)
+	}
+	config := ""
+	if len(spec.Config) > 0 {
+		data, _ := json.Marshal(spec.Config)
+		config = string(data)
+	}
+	status := ChannelStatusEnabled
+	if spec.Disabled {
+		status = ChannelStatusManuallyDisabled
+	}
+	weight := spec.Weight
+	priority := spec.Priority
+	return &Channel{
+		Type:         spec.Type,
+		Key:          key,
+		Status:       status,
+		Name:         spec.Name,
+		Weight:       &weight,
+		BaseURL:      &spec.BaseURL,
+		Models:       strings.Join(spec.Models, ","),
+		Group:        group,
+		ModelMapping: &modelMapping,
+		Priority:     &priority,
+		Config:       config,
+		ExternalId:   reconcileExternalIdPrefix + spec.Name,
+	}, nil
+}
+
+func channelChanged(current *Channel, desired *Channel) bool {
+	var weight uint
+	if current.Weight != nil {
+		weight = *current.Weight
+	}
+	return current.Type != desired.Type || current.Key != desired.Key || current.Status != desired.Status ||
+		current.GetBaseURL() != desired.GetBaseURL() || current.Models != desired.Models || current.Group != desired.Group ||
+		current.GetPriority() != desired.GetPriority() || current.Config != desired.Config ||
+		deref(current.ModelMapping) != *desired.ModelMapping || weight != *desired.Weight
+}
+
+func reconcileChannels(specs []ChannelSpec, prune bool) error {
+	desired := make(map[string]bool, len(specs))
+	for i := range specs {
+		channel, err := specs[i].toChannel()
+		if err != nil {
+			return err
+		}
+		desired[channel.ExternalId] = true
+		current, err := GetChannelByExternalId(channel.ExternalId, true)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			channel.CreatedTime = helper.GetTimestamp()
+			if err = channel.Insert(); err != nil {
+				return err
+			}
+			logger.SysLogf("reconcile: channel %s created", channel.Name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !channelChanged(current, channel) {
+			continue
+		}
+		channel.Id = current.Id
+		// Updates skips zero values
+		if err = DB.Model(channel).Select("type", "key", "status", "base_url", "models", "group", "model_mapping", "priority", "weight", "config").Updates(channel).Error; err != nil {
+			return err
+		}
+		if err = channel.UpdateAbilities(); err != nil {
+			return err
+		}
+		cacheInvalidateChannels()
+		logger.SysLogf("reconcile: channel %s updated", channel.Name)
+	}
+	if !prune {
+		return nil
+	}
+	var channels []*Channel
+	if err := DB.Where("external_id LIKE ?", reconcileExternalIdPrefix+"%").Find(&channels).Error; err != nil {
+		return err
+	}
+	for _, channel := range channels {
+		if desired[channel.ExternalId] {
+			continue
+		}
+		if err := channel.Delete(); err != nil {
+			return err
+		}
+		logger.SysLogf("reconcile: channel %s deleted", channel.Name)
+	}
+	return nil
+}
+
+func (spec *TokenSpec) toToken() (*Token, error) {
+	if spec.Name == "" || spec.User == "" {
+		return nil, errors.New("token name and user are required")
+	}
+	key, err := readKey(spec.Key, spec.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key = strings.TrimPrefix(key, "sk-")
+	var userId int
+	if err = DB.Model(&User{}).Select("id").Where("username = ?", spec.User).Take(&userId).Error; err != nil {
+		return nil, fmt.Errorf("token %s: user %s not found", spec.Name, spec.User)
+	}
+	expiredTime := spec.ExpiredTime
+	if expiredTime == 0 {
+		expiredTime = -1
+	}
+	status := TokenStatusEnabled
+	if spec.Disabled {
+		status = TokenStatusDisabled
+	}
+	models := strings.Join(spec.Models, ",")
+	return &Token{
+		UserId:         userId,
+		Key:            key,
+		Status:         status,
+		Name:           spec.Name,
+		ExpiredTime:    expiredTime,
+		RemainQuota:    spec.Quota,
+		DeclaredQuota:  spec.Quota,
+		UnlimitedQuota: spec.UnlimitedQuota,
+		Models:         &models,
+		Subnet:         &spec.Subnet,
+		ExternalId:     tokenExternalId(userId, spec.Name),
+	}, nil
+}
+
+func tokenExternalId(userId int, name string) string {
+	return fmt.Sprintf("%s%d/%s", reconcileExternalIdPrefix, userId, name)
+}
+
+// migrateReconciledTokens moves the reconciled tokens to the external ids holding their user, and takes their
+// quota before any request as the declared one
+func migrateReconciledTokens(tx *gorm.DB) error {
+	var tokens []*Token
+	if err := tx.Unscoped().Where("external_id LIKE ?", reconcileExternalIdPrefix+"%").Find(&tokens).Error; err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if token.ExternalId != reconcileExternalIdPrefix+token.Name {
+			continue
+		}
+		err := tx.Unscoped().Model(&Token{}).Where("id = ?", token.Id).Updates(map[string]any{
+			"external_id":    tokenExternalId(token.UserId, token.Name),
+			"declared_quota": token.RemainQuota + token.UsedQuota,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func reconcileTokens(specs []TokenSpec, prune bool) error {
+	desired := make(map[string]bool, len(specs))
+	for i := range specs {
+		token, err := specs[i].toToken()
+		if err != nil {
+			return err
+		}
+		desired[token.ExternalId] = true
+		var current Token
+		err = DB.First(&current, "external_id = ?", token.ExternalId).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if token.Key == "" {
+				token.Key = random.GenerateKey()
+			}
+			token.CreatedTime = helper.GetTimestamp()
+			token.AccessedTime = token.CreatedTime
+			if err = token.Insert(); err != nil {
+				return err
+			}
+			logger.SysLogf("reconcile: token %s created", token.Name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if token.Key == "" {
+			token.Key = current.Key
+		}
+		// the remain quota is consumed by requests, so it is only reset when the declared quota changes
+		quotaChanged := current.DeclaredQuota != token.DeclaredQuota
+		if !quotaChanged && current.Key == token.Key && current.Status == token.Status &&
+			current.ExpiredTime == token.ExpiredTime && current.UnlimitedQuota == token.UnlimitedQuota &&
+			current.GetModels() == *token.Models && deref(current.Subnet) == *token.Subnet {
+			continue
+		}
+		token.Id = current.Id
+		columns := []any{"status", "expired_time", "unlimited_quota", "models", "subnet"}
+		if quotaChanged {
+			columns = append(columns, "remain_quota", "declared_quota")
+		}
+		if err = DB.Model(token).Select("key", columns...).Updates(token).Error; err != nil {
+			return err
+		}
+		CacheInvalidateToken(current.Key)
+		logger.SysLogf("reconcile: token %s updated", token.Name)
+	}
+	if !prune {
+		return nil
+	}
+	var tokens []*Token
+	if err := DB.Where("external_id LIKE ?", reconcileExternalIdPrefix+"%").Find(&tokens).Error; err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if desired[token.ExternalId] {
+			continue
+		}
+		if err := token.Delete(); err != nil {
+			return err
+		}
+		logger.SysLogf("reconcile: token %s deleted", token.Name)
+	}
+	return nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func Reconcile(dir string, prune bool) error {
+	spec, err := LoadReconcileSpec(dir)
+	if err != nil {
+		return err
+	}
+	if err = reconcileChannels(spec.Channels, prune); err != nil {
+		return err
+	}
+	return reconcileTokens(spec.Tokens, prune)
+}
+
+func AutomaticallyReconcile(dir string, prune bool, frequency int) {
+	for {
+		if IsLeader() {
+			if err := Reconcile(dir, prune); err != nil {
+				logger.SysError("failed to reconcile " + dir + ": " + err.Error())
+			}
+		}
+		time.Sleep(time.Duration(frequency) * time.Second)
+	}
+}
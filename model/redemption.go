@@ -32,12 +32,12 @@ type Redemption struct {
 func GetAllRedemptions(startIdx int, num int) ([]*Redemption, error) {
 	var redemptions []*Redemption
 	var err error
-	err = DB.Order("id desc").Limit(num).Offset(startIdx).Find(&redemptions).Error
+	err = REPLICA_DB.Order("id desc").Limit(num).Offset(startIdx).Find(&redemptions).Error
 	return redemptions, err
 }
 
 func SearchRedemptions(keyword string) (redemptions []*Redemption, err error) {
-	err = DB.Where("id = ? or name LIKE ?", keyword, keyword+"%").Find(&redemptions).Error
+	err = REPLICA_DB.Where("id = ? or name LIKE ?", keyword, keyword+"%").Find(&redemptions).Error
 	return redemptions, err
 }
 
@@ -51,7 +51,8 @@ func GetRedemptionById(id int) (*Redemption, error) {
 	return &redemption, err
 }
 
-func Redeem(ctx context.Context, key string, userId int) (quota int64, err error) {
+// Redeem adds the quota of the redemption code to the user, with the bonus of the top-up coupon if any
+func Redeem(ctx context.Context, key string, couponCode string, userId int) (quota int64, err error) {
 	if key == "" {
 		return 0, errors.New("未提供兑换码")
 	}
@@ -59,11 +60,10 @@ func Redeem(ctx context.Context, key string, userId int) (quota int64, err error
 		return 0, errors.New("无效的 user id")
 	}
 	redemption := &Redemption{}
+	var coupon *Coupon
+	var couponRedemption *CouponRedemption
 
-	keyCol := "`key`"
-	if common.UsingPostgreSQL {
-		keyCol = `"key"`
-	}
+	keyCol := quoteCol("key")
 
 	err = DB.Transaction(func(tx *gorm.DB) error {
 		err := tx.Set("gorm:query_option", "FOR UPDATE").Where(keyCol+" = ?", key).First(redemption).Error
@@ -73,10 +73,20 @@ func Redeem(ctx context.Context, key string, userId int) (quota int64, err error
 		if redemption.Status != RedemptionCodeStatusEnabled {
 			return errors.New("该兑换码已被使用")
 		}
-		err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
+		err = changeUserQuota(tx, userId, redemption.Quota, LedgerTypeTopUp, fmt.Sprintf("兑换码 #%d", redemption.Id))
 		if err != nil {
 			return err
 		}
+		if couponCode != "" {
+			coupon, couponRedemption, err = useCoupon(tx, couponCode, userId, CouponScopeTopUp, redemption.Quota, redemption.Id)
+			if err != nil {
+				return err
+			}
+			err = changeUserQuota(tx, userId, couponRedemption.Discount, LedgerTypeCoupon, fmt.Sprintf("优惠券 #%d", coupon.Id))
+			if err != nil {
+				return err
+			}
+		}
 		redemption.RedeemedTime = helper.GetTimestamp()
 		redemption.Status = RedemptionCodeStatusUsed
 		err = tx.Save(redemption).Error
@@ -85,8 +95,16 @@ func Redeem(ctx context.Context, key string, userId int) (quota int64, err error
 	if err != nil {
 		return 0, errors.New("兑换失败，" + err.Error())
 	}
-	RecordLog(ctx, userId, LogTypeTopup, fmt.Sprintf("通过兑换码充值 %s", common.LogQuota(redemption.Quota)))
-	return redemption.Quota, nil
+	content := fmt.Sprintf("通过兑换码充值 %s", common.LogQuota(redemption.Quota))
+	quota = redemption.Quota
+	if couponRedemption != nil {
+		content += fmt.Sprintf("，优惠券「%s」赠送 %s", coupon.Name, common.LogQuota(couponRedemption.Discount))
+		quota += couponRedemption.Discount
+	}
+	RecordLog(ctx, userId, LogTypeTopup, content)
+	// the invitation rewards are based on the quota paid for
+	RewardInviterForTopUp(ctx, userId, redemption.Quota)
+	return quota, nil
 }
 
 func (redemption *Redemption) Insert() error {
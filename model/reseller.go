@@ -0,0 +1,359 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/blacklist"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/random"
+)
+
+// ResellerSummary is a reseller with the totals of its users, for the platform admin
+type ResellerSummary struct {
+	Id             int     `json:"id"`
+	Username       string  `json:"username"`
+	DisplayName    string  `json:"display_name"`
+	Markup         float64 `json:"markup"`
+	Quota          int64   `json:"quota"`
+	Users          int     `json:"users"`
+	UsersQuota     int64   `json:"users_quota"`
+	UsersUsedQuota int64   `json:"users_used_quota"`
+	MarkupEarned   int64   `json:"markup_earned"` // the sum of the markups credited to the reseller
+}
+
+// SetReseller makes the user a reseller adding markup percent to the price of the requests of its users
+func SetReseller(userId int, markup float64) error {
+	if markup < 0 {
+		return errors.New("加价比例不能为负数")
+	}
+	user, err := GetUserById(userId, false)
+	if err != nil {
+		return err
+	}
+	if user.Role != RoleCommonUser && user.Role != RoleResellerUser {
+		return errors.New("只有普通用户可以成为分销商")
+	}
+	if user.ResellerId != 0 {
+		return errors.New("分销商的下级用户不能成为分销商")
+	}
+	err = DB.Model(&User{}).Where("id = ?", userId).Updates(map[string]any{
+		"role":   RoleResellerUser,
+		"markup": markup,
+	}).Error
+	CacheInvalidateUser(userId)
+	return err
+}
+
+// UnsetReseller makes the reseller a common user again, once it has no users left
+func UnsetReseller(userId int) error {
+	var count int64
+	if err := DB.Model(&User{}).Where("reseller_id = ?", userId).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该分销商还有下级用户，无法取消")
+	}
+	err := DB.Model(&User{}).Where("id = ? and role = ?", userId, RoleResellerUser).Updates(map[string]any{
+		"role":   RoleCommonUser,
+		"markup": 0,
+	}).Error
+	CacheInvalidateUser(userId)
+	if err != nil {
+		return err
+	}
+	// the reseller loses the access to the pages of its users
+	return RevokeUserSessions(userId, "")
+}
+
+func resellerSummaryQuery() *gorm.DB {
+	return DB.Table("users").
+		Select("users.id, users.username, users.display_name, users.markup, users.quota, "+
+			"coalesce(downstream.users, 0) as users, coalesce(downstream.quota, 0) as users_quota, "+
+			"coalesce(downstream.used_quota, 0) as users_used_quota, coalesce(earned.amount, 0) as markup_earned").
+		Joins("left join (select reseller_id, count(1) as users, sum(quota) as quota, sum(used_quota) as used_quota "+
+			"from users where reseller_id <> 0 and deleted_at is null group by reseller_id) downstream on downstream.reseller_id = users.id").
+		Joins("left join (select user_id, sum(amount) as amount from quota_ledger_entries where type = ? group by user_id) earned "+
+			"on earned.user_id = users.id", LedgerTypeMarkup).
+		Where("users.role = ? and users.deleted_at is null", RoleResellerUser)
+}
+
+func GetResellerSummaries(startIdx int, num int) (summaries []*ResellerSummary, err error) {
+	err = resellerSummaryQuery().Order("users.id desc").Limit(num).Offset(startIdx).Scan(&summaries).Error
+	return summaries, err
+}
+
+func GetResellerSummary(resellerId int) (*ResellerSummary, error) {
+	var summaries []*ResellerSummary
+	err := resellerSummaryQuery().Where("users.id = ?", resellerId).Scan(&summaries).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(summaries) == 0 {
+		return nil, errors.New("分销商不存在")
+	}
+	return summaries[0], nil
+}
+
+func GetResellerUsers(resellerId int, startIdx int, num int) (users []*User, err error) {
+	err = DB.Omit("password", "access_token").Where("reseller_id = ?", resellerId).
+		Order("id desc").Limit(num).Offset(startIdx).Find(&users).Error
+	return users, err
+}
+
+// GetResellerUser returns the user if it is managed by the reseller
+func GetResellerUser(resellerId int, userId int) (*User, error) {
+	user := User{}
+	err := DB.Omit("password", "access_token").Where("id = ? and reseller_id = ?", userId, resellerId).First(&user).Error
+	if err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	return &user, nil
+}
+
+// InsertResellerUser creates a user managed by the reseller, in the group of the reseller and without the quota for
+// new users, the reseller gives it quota from its own
+func InsertResellerUser(ctx context.Context, resellerId int, user *User) error {
+	reseller, err := GetUserById(resellerId, false)
+	if err != nil {
+		return err
+	}
+	if user.Password, err = common.Password2Hash(user.Password); err != nil {
+		return err
+	}
+	user.Role = RoleCommonUser
+	user.Status = UserStatusEnabled
+	user.Group = reseller.Group
+	user.ResellerId = resellerId
+	user.Quota = 0
+	user.AccessToken = random.GetUUID()
+	user.AffCode = random.GetRandomString(4)
+	if err = DB.Create(user).Error; err != nil {
+		return err
+	}
+	RecordLog(ctx, resellerId, LogTypeManage, fmt.Sprintf("分销商创建下级用户 %s", user.Username))
+	createDefaultToken(user.Id)
+	return nil
+}
+
+// UpdateResellerUser changes the display name, the password and the status of the user of the reseller
+func UpdateResellerUser(resellerId int, user *User) error {
+	if _, err := GetResellerUser(resellerId, user.Id); err != nil {
+		return err
+	}
+	updates := map[string]any{}
+	if user.DisplayName != "" {
+		updates["display_name"] = user.DisplayName
+	}
+	if user.Password != "" {
+		password, err := common.Password2Hash(user.Password)
+		if err != nil {
+			return err
+		}
+		updates["password"] = password
+	}
+	switch user.Status {
+	case UserStatusEnabled:
+		updates["status"] = UserStatusEnabled
+	case UserStatusDisabled:
+		updates["status"] = UserStatusDisabled
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := DB.Model(&User{}).Where("id = ?", user.Id).Updates(updates).Error; err != nil {
+		return err
+	}
+	CacheInvalidateUser(user.Id)
+	if user.Status == UserStatusDisabled {
+		blacklist.BanUser(user.Id)
+	} else if user.Status == UserStatusEnabled {
+		blacklist.UnbanUser(user.Id)
+	}
+	if user.Password != "" || user.Status == UserStatusDisabled {
+		return RevokeUserSessions(user.Id, "")
+	}
+	return nil
+}
+
+// DeleteResellerUser deletes the user of the reseller, the quota left of the user is given back to the reseller
+func DeleteResellerUser(ctx context.Context, resellerId int, userId int) error {
+	user, err := GetResellerUser(resellerId, userId)
+	if err != nil {
+		return err
+	}
+	if user.Quota > 0 {
+		if err = TransferResellerQuota(ctx, resellerId, userId, -user.Quota); err != nil {
+			return err
+		}
+	}
+	return user.Delete()
+}
+
+// TransferResellerQuota gives quota of the reseller to its user, or takes it back when quota is negative
+func TransferResellerQuota(ctx context.Context, resellerId int, userId int, quota int64) error {
+	if quota == 0 {
+		return errors.New("额度不能为 0")
+	}
+	user, err := GetResellerUser(resellerId, userId)
+	if err != nil {
+		return err
+	}
+	from, to := resellerId, userId
+	if quota < 0 {
+		from, to, quota = userId, resellerId, -quota
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ? and quota >= ?", from, quota).Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("额度不足")
+		}
+		if err := appendQuotaLedger(tx, from, -quota, LedgerTypeTransfer, "分销商下级用户 "+user.Username); err != nil {
+			return err
+		}
+		return changeUserQuota(tx, to, quota, LedgerTypeTransfer, "分销商下级用户 "+user.Username)
+	})
+	if err != nil {
+		return errors.New("额度划转失败，" + err.Error())
+	}
+	CacheInvalidateUser(resellerId)
+	CacheInvalidateUser(userId)
+	if to == userId {
+		RecordLog(ctx, userId, LogTypeTopup, fmt.Sprintf("分销商充值 %s", common.LogQuota(quota)))
+	} else {
+		RecordLog(ctx, userId, LogTypeManage, fmt.Sprintf("分销商收回额度 %s", common.LogQuota(quota)))
+	}
+	return nil
+}
+
+// GetResellerLogs returns the logs of the users of the reseller, without the channels
+func GetResellerLogs(resellerId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, startIdx int, num int) (logs []*Log, err error) {
+	var userIds []int
+	if err = DB.Model(&User{}).Where("reseller_id = ?", resellerId).Pluck("id", &userIds).Error; err != nil || len(userIds) == 0 {
+		return nil, err
+	}
+	tx := LOG_REPLICA_DB.Where("user_id in ?", userIds)
+	if logType != LogTypeUnknown {
+		tx = tx.Where("type = ?", logType)
+	}
+	if modelName != "" {
+		tx = tx.Where("model_name = ?", modelName)
+	}
+	if username != "" {
+		tx = tx.Where("username = ?", username)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Omit("id", "channel_id").Find(&logs).Error
+	return logs, err
+}
+
+// GetResellerQuotaLedger returns the ledger entries of the reseller and of its users, the latest first
+func GetResellerQuotaLedger(resellerId int, type_ string, startIdx int, num int) (entries []*QuotaLedgerEntry, err error) {
+	// the entries of the deleted users are kept in the ledger
+	users := DB.Unscoped().Model(&User{}).Select("id").Where("reseller_id = ?", resellerId)
+	tx := DB.Where("user_id = ? or user_id in (?)", resellerId, users)
+	if type_ != "" {
+		tx = tx.Where("type = ?", type_)
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&entries).Error
+	return entries, err
+}
+
+func getUserResellerId(id int) (resellerId int, err error) {
+	err = DB.Model(&User{}).Where("id = ?", id).Select("reseller_id").Find(&resellerId).Error
+	return resellerId, err
+}
+
+func getResellerMarkup(id int) (markup float64, err error) {
+	err = DB.Model(&User{}).Where("id = ? and role = ?", id, RoleResellerUser).Select("markup").Find(&markup).Error
+	return markup, err
+}
+
+func cacheGetUserResellerId(id int) (int, error) {
+	if !common.RedisEnabled {
+		return getUserResellerId(id)
+	}
+	resellerId, err := common.RedisGet(fmt.Sprintf("user_reseller:%d", id))
+	if err != nil {
+		resellerId, err := getUserResellerId(id)
+		if err != nil {
+			return 0, err
+		}
+		err = common.RedisSet(fmt.Sprintf("user_reseller:%d", id), strconv.Itoa(resellerId), time.Duration(UserId2GroupCacheSeconds)*time.Second)
+		if err != nil {
+			logger.SysError("Redis set user reseller error: " + err.Error())
+		}
+		return resellerId, nil
+	}
+	return strconv.Atoi(resellerId)
+}
+
+func cacheGetResellerMarkup(id int) (float64, error) {
+	if !common.RedisEnabled {
+		return getResellerMarkup(id)
+	}
+	markup, err := common.RedisGet(fmt.Sprintf("reseller_markup:%d", id))
+	if err != nil {
+		markup, err := getResellerMarkup(id)
+		if err != nil {
+			return 0, err
+		}
+		err = common.RedisSet(fmt.Sprintf("reseller_markup:%d", id), strconv.FormatFloat(markup, 'f', -1, 64), time.Duration(UserId2GroupCacheSeconds)*time.Second)
+		if err != nil {
+			logger.SysError("Redis set reseller markup error: " + err.Error())
+		}
+		return markup, nil
+	}
+	return strconv.ParseFloat(markup, 64)
+}
+
+// resellerMarkup returns the reseller of the user and the markup it adds to the quota of the request
+func resellerMarkup(userId int, quota int64) (resellerId int, markup float64, amount int64) {
+	if quota <= 0 {
+		return 0, 0, 0
+	}
+	resellerId, err := cacheGetUserResellerId(userId)
+	if err != nil || resellerId == 0 {
+		return 0, 0, 0
+	}
+	markup, err = cacheGetResellerMarkup(resellerId)
+	if err != nil || markup <= 0 {
+		return 0, 0, 0
+	}
+	return resellerId, markup, int64(float64(quota) * markup / 100)
+}
+
+// ApplyPriceAdjustments returns the quota of the request of the user after the discounts of its coupons, then with
+// the markup of its reseller on the discounted price, with the note telling them for the consume log. The markup is
+// credited to the reseller by the returned function, to be called once the user is charged
+func ApplyPriceAdjustments(userId int, model string, quota int64) (int64, string, func()) {
+	quota, couponNote := ApplyUsageCoupons(userId, model, quota)
+	resellerId, markup, amount := resellerMarkup(userId, quota)
+	if amount <= 0 {
+		return quota, couponNote, func() {}
+	}
+	creditMarkup := func() {
+		if err := ChangeUserQuota(resellerId, amount, LedgerTypeMarkup, fmt.Sprintf("用户 #%d", userId)); err != nil {
+			logger.SysError(fmt.Sprintf("failed to credit the markup of user %d to reseller %d: %s", userId, resellerId, err.Error()))
+		}
+	}
+	markupNote := fmt.Sprintf("分销商加价 %.2f%%（%s）", markup, common.LogQuota(amount))
+	if couponNote != "" {
+		return quota + amount, couponNote + "，" + markupNote, creditMarkup
+	}
+	return quota + amount, markupNote, creditMarkup
+}
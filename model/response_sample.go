@@ -0,0 +1,60 @@
+package model
+
+import (
+	"context"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/redact"
+)
+
+// maxResponseSamples is how many malformed responses are kept for each channel
+const maxResponseSamples = 20
+
+// ResponseSample is a malformed response of a channel, the beginning and the end of it, kept for the admins to see
+// what the upstream returned, redacted by the log redaction rules
+type ResponseSample struct {
+	Id         int    `json:"id"`
+	ChannelId  int    `json:"channel_id" gorm:"index"`
+	ModelName  string `json:"model_name" gorm:"default:''"`
+	RequestId  string `json:"request_id" gorm:"type:varchar(64);default:''"`
+	Reason     string `json:"reason" gorm:"type:varchar(32)"`
+	StatusCode int    `json:"status_code"`
+	Body       string `json:"body" gorm:"type:text"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+func RecordResponseSample(ctx context.Context, sample *ResponseSample, body []byte) {
+	sample.RequestId = helper.GetRequestID(ctx)
+	sample.Body = string(redact.Body(body))
+	sample.CreatedAt = helper.GetTimestamp()
+	if err := DB.Create(sample).Error; err != nil {
+		logger.Error(ctx, "failed to record response sample: "+err.Error())
+		return
+	}
+	kept := DB.Model(&ResponseSample{}).Select("id").Where("channel_id = ?", sample.ChannelId).Order("id desc").Limit(maxResponseSamples)
+	// the subquery is wrapped for mysql, which cannot limit a subquery of in
+	err := DB.Where("channel_id = ? and id not in (?)", sample.ChannelId, DB.Table("(?) as kept", kept).Select("id")).Delete(&ResponseSample{}).Error
+	if err != nil {
+		logger.Error(ctx, "failed to delete old response samples: "+err.Error())
+	}
+}
+
+// GetResponseSamples returns the malformed responses kept, the latest first, of the channel if channelId is not 0
+func GetResponseSamples(channelId int, startIdx int, num int) ([]*ResponseSample, error) {
+	var samples []*ResponseSample
+	tx := REPLICA_DB.Order("id desc")
+	if channelId != 0 {
+		tx = tx.Where("channel_id = ?", channelId)
+	}
+	err := tx.Limit(num).Offset(startIdx).Find(&samples).Error
+	return samples, err
+}
+
+func DeleteResponseSamples(channelId int) error {
+	tx := DB.Where("1 = 1")
+	if channelId != 0 {
+		tx = DB.Where("channel_id = ?", channelId)
+	}
+	return tx.Delete(&ResponseSample{}).Error
+}
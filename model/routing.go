@@ -0,0 +1,167 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	RoutingActionDeny    = "deny"    // the models are not served to the group
+	RoutingActionExclude = "exclude" // the channels are not routed for the group
+	RoutingActionPrefer  = "prefer"  // the channels are routed for the group first, the others when none of them is available
+)
+
+// RoutingRule changes the routing of the requests of a user group during its window, such as sending them to the
+// self-hosted channels off-peak, or not serving the expensive models at night
+type RoutingRule struct {
+	TimeWindow
+	Action string `json:"action"`
+	// Models are the models the rule applies to, empty means all the models
+	Models []string `json:"models,omitempty"`
+	// Channels are the channels excluded or preferred
+	Channels []int `json:"channels,omitempty"`
+}
+
+func (rule *RoutingRule) Validate() error {
+	if err := rule.TimeWindow.Validate(); err != nil {
+		return err
+	}
+	switch rule.Action {
+	case RoutingActionDeny:
+	case RoutingActionExclude, RoutingActionPrefer:
+		if len(rule.Channels) == 0 {
+			return fmt.Errorf("channels of %s rule %q are empty", rule.Action, rule.Cron)
+		}
+	default:
+		return fmt.Errorf("unknown routing action %q", rule.Action)
+	}
+	return nil
+}
+
+var groupRoutingRulesLock sync.RWMutex
+var GroupRoutingRules = map[string][]*RoutingRule{}
+
+// activeRoutingRules are the rules whose window covers the current minute, refreshed every minute
+var activeRoutingRules = map[string][]*RoutingRule{}
+
+func GroupRoutingRules2JSONString() string {
+	groupRoutingRulesLock.RLock()
+	defer groupRoutingRulesLock.RUnlock()
+	jsonBytes, err := json.Marshal(GroupRoutingRules)
+	if err != nil {
+		logger.SysError("error marshalling group routing rules: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupRoutingRulesByJSONString(jsonStr string) error {
+	groupRoutingRules := make(map[string][]*RoutingRule)
+	if err := json.Unmarshal([]byte(jsonStr), &groupRoutingRules); err != nil {
+		return err
+	}
+	for group, rules := range groupRoutingRules {
+		for _, rule := range rules {
+			if rule == nil {
+				return fmt.Errorf("group %s: rule is empty", group)
+			}
+			if err := rule.Validate(); err != nil {
+				return fmt.Errorf("group %s: %w", group, err)
+			}
+		}
+	}
+	active := getActiveRoutingRules(groupRoutingRules, time.Now())
+	groupRoutingRulesLock.Lock()
+	defer groupRoutingRulesLock.Unlock()
+	GroupRoutingRules = groupRoutingRules
+	activeRoutingRules = active
+	return nil
+}
+
+func getActiveRoutingRules(groupRoutingRules map[string][]*RoutingRule, now time.Time) map[string][]*RoutingRule {
+	active := make(map[string][]*RoutingRule)
+	for group, rules := range groupRoutingRules {
+		for _, rule := range rules {
+			ok, err := rule.Active(now)
+			if err != nil {
+				logger.SysError(fmt.Sprintf("invalid routing rule of group %s: %s", group, err.Error()))
+				continue
+			}
+			if ok {
+				active[group] = append(active[group], rule)
+			}
+		}
+	}
+	return active
+}
+
+func refreshRoutingRules() {
+	groupRoutingRulesLock.RLock()
+	active := getActiveRoutingRules(GroupRoutingRules, time.Now())
+	groupRoutingRulesLock.RUnlock()
+	groupRoutingRulesLock.Lock()
+	defer groupRoutingRulesLock.Unlock()
+	activeRoutingRules = active
+}
+
+// routing is what the active rules of a group do to a model
+type routing struct {
+	denied    bool
+	excluded  []int
+	preferred []int
+}
+
+func getRouting(group string, model string) routing {
+	groupRoutingRulesLock.RLock()
+	defer groupRoutingRulesLock.RUnlock()
+	var r routing
+	for _, rule := range activeRoutingRules[group] {
+		if len(rule.Models) > 0 && !slices.Contains(rule.Models, model) {
+			continue
+		}
+		switch rule.Action {
+		case RoutingActionDeny:
+			r.denied = true
+		case RoutingActionExclude:
+			r.excluded = append(r.excluded, rule.Channels...)
+		case RoutingActionPrefer:
+			r.preferred = append(r.preferred, rule.Channels...)
+		}
+	}
+	return r
+}
+
+// IsModelDeniedByRouting tells whether a routing rule of the group denies the model at the moment
+func IsModelDeniedByRouting(group string, model string) bool {
+	return getRouting(group, model).denied
+}
+
+// routeChannels applies the active rules of the group to the channels of the model, the order is kept
+func routeChannels(group string, model string, channels []*Channel) []*Channel {
+	r := getRouting(group, model)
+	if r.denied {
+		return nil
+	}
+	if len(r.excluded) == 0 && len(r.preferred) == 0 {
+		return channels
+	}
+	routed := make([]*Channel, 0, len(channels))
+	var preferred []*Channel
+	for _, channel := range channels {
+		if slices.Contains(r.excluded, channel.Id) {
+			continue
+		}
+		routed = append(routed, channel)
+		if slices.Contains(r.preferred, channel.Id) {
+			preferred = append(preferred, channel)
+		}
+	}
+	if len(preferred) > 0 {
+		return preferred
+	}
+	return routed
+}
@@ -0,0 +1,436 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// the schema is changed by versioned migrations only, each one is applied once in the order of the versions
+// and recorded in schema_migrations, so that a binary can tell whether the database matches it.
+// A change of a table goes into a new migration appended to the list, never into an applied one.
+
+const (
+	migrationScopeMain = "main"
+	migrationScopeLog  = "log"
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	// Models are auto-migrated first, they are the tables the migration creates or alters,
+	// MigrateData copies the tables of all the models of the migrations
+	Models []any
+	// Up runs what the auto-migration can not do, it is nil for most migrations
+	Up func(tx *gorm.DB) error
+	// Down reverts Up, it is nil when the migration can not be rolled back
+	Down func(tx *gorm.DB) error
+}
+
+type SchemaMigration struct {
+	Scope      string `json:"scope" gorm:"type:varchar(16);primaryKey"`
+	Version    int    `json:"version" gorm:"primaryKey;autoIncrement:false"`
+	Name       string `json:"name" gorm:"type:varchar(128)"`
+	AppVersion string `json:"app_version" gorm:"type:varchar(64)"`
+	AppliedAt  int64  `json:"applied_at" gorm:"bigint"`
+}
+
+func dropTables(models ...any) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(models...)
+	}
+}
+
+func dropColumns(model any, columns ...string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, column := range columns {
+			if err := tx.Migrator().DropColumn(model, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// createExternalIdIndex makes the external ids of the table unique, within the scope column if any. The rows
+// without one and those in the trash are left out of it, so that a deleted token or user can be declared again
+func createExternalIdIndex(tx *gorm.DB, table string, scope string) error {
+	columns := []string{"external_id"}
+	if scope != "" {
+		columns = []string{scope, "external_id"}
+	}
+	var duplicates []string
+	err := tx.Table(table).Where("external_id <> '' and deleted_at IS NULL").Group(strings.Join(columns, ", ")).
+		Having("count(*) > 1").Pluck("external_id", &duplicates).Error
+	if err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("the external ids %s are shared by several rows of %s, make them unique first", strings.Join(duplicates, ", "), table)
+	}
+	index := fmt.Sprintf("idx_%s_external_id_unique", table)
+	if tx.Dialector.Name() == "mysql" {
+		// MySQL has no partial index, the rows left out are indexed as NULL through a generated column, NULL may repeat
+		columns[len(columns)-1] = "external_id_unique"
+		return tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN external_id_unique varchar(64) GENERATED ALWAYS AS "+
+			"(IF(external_id <> '' AND deleted_at IS NULL, external_id, NULL)) VIRTUAL, ADD UNIQUE INDEX %s (%s)",
+			table, index, strings.Join(columns, ", "))).Error
+	}
+	return tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s) WHERE external_id <> '' AND deleted_at IS NULL",
+		index, table, strings.Join(columns, ", "))).Error
+}
+
+func dropExternalIdIndex(tx *gorm.DB, table string) error {
+	index := fmt.Sprintf("idx_%s_external_id_unique", table)
+	if tx.Dialector.Name() == "mysql" {
+		return tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP INDEX %s, DROP COLUMN external_id_unique", table, index)).Error
+	}
+	return tx.Exec("DROP INDEX " + index).Error
+}
+
+// migrations of the main database, the baseline creates the tables as they were before the migrations
+// were versioned, it only adds what is missing to the databases created by the earlier releases
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Models: []any{&Channel{}, &Token{}, &User{}, &Option{}, &Redemption{}, &Ability{}, &Lease{}, &Filter{},
+			&PromptTemplate{}, &Experiment{}, &FreeUsage{}, &AffReward{}, &BotChat{}, &ChannelCheck{},
+			&QuotaTransfer{}, &File{}, &FineTuningJob{}, &Assistant{}, &Thread{}, &ThreadMessage{}, &Run{},
+			&VectorStore{}, &VectorStoreFile{}, &VectorChunk{}, &McpServer{}, &Evaluation{}, &GlossaryTerm{},
+			&TokenAnomaly{}, &ChannelKeyScan{}},
+	},
+	{
+		Version: 2,
+		Name:    "create backups",
+		Models:  []any{&Backup{}},
+		Down:    dropTables(&Backup{}),
+	},
+	{
+		Version: 3,
+		Name:    "create self test results",
+		Models:  []any{&SelfTestResult{}},
+		Down:    dropTables(&SelfTestResult{}),
+	},
+	{
+		Version: 4,
+		Name:    "add max concurrency to tokens",
+		Models:  []any{&Token{}},
+		Down:    dropColumns(&Token{}, "max_concurrency"),
+	},
+	{
+		Version: 5,
+		Name:    "create async tasks",
+		Models:  []any{&AsyncTask{}},
+		Down:    dropTables(&AsyncTask{}),
+	},
+	{
+		Version: 6,
+		Name:    "create plans",
+		Models:  []any{&Plan{}, &User{}},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(&User{}, "plan_id", "plan_reset_at", "plan_quota", "plan_used_quota_base")(tx); err != nil {
+				return err
+			}
+			return dropTables(&Plan{})(tx)
+		},
+	},
+	{
+		Version: 7,
+		Name:    "add saml id to users",
+		Models:  []any{&User{}},
+		Down:    dropColumns(&User{}, "saml_id"),
+	},
+	{
+		Version: 8,
+		Name:    "add two factor authentication to users",
+		Models:  []any{&User{}},
+		Down:    dropColumns(&User{}, "two_factor_enabled", "two_factor_secret", "two_factor_recovery_codes", "two_factor_last_step"),
+	},
+	{
+		Version: 9,
+		Name:    "create sessions",
+		Models:  []any{&Session{}},
+		Down:    dropTables(&Session{}),
+	},
+	{
+		Version: 10,
+		Name:    "add allowed origins to tokens",
+		Models:  []any{&Token{}},
+		Down:    dropColumns(&Token{}, "allowed_origins"),
+	},
+	{
+		Version: 11,
+		Name:    "create conversations",
+		Models:  []any{&Conversation{}, &ConversationMessage{}},
+		Down:    dropTables(&ConversationMessage{}, &Conversation{}),
+	},
+	{
+		Version: 12,
+		Name:    "add enabled notifications to users",
+		Models:  []any{&User{}},
+		Down:    dropColumns(&User{}, "enabled_notifications"),
+	},
+	{
+		Version: 13,
+		Name:    "create quota ledger",
+		Models:  []any{&QuotaLedgerEntry{}},
+		Up:      openQuotaLedgers,
+		Down:    dropTables(&QuotaLedgerEntry{}),
+	},
+	{
+		Version: 14,
+		Name:    "add extra groups to users",
+		Models:  []any{&User{}},
+		Down:    dropColumns(&User{}, "extra_groups"),
+	},
+	{
+		Version: 15,
+		Name:    "add sandbox to tokens",
+		Models:  []any{&Token{}},
+		Down:    dropColumns(&Token{}, "sandbox"),
+	},
+	{
+		Version: 16,
+		Name:    "add currency to users",
+		Models:  []any{&User{}},
+		Down:    dropColumns(&User{}, "currency"),
+	},
+	{
+		Version: 17,
+		Name:    "create coupons",
+		Models:  []any{&Coupon{}, &CouponRedemption{}},
+		Down:    dropTables(&Coupon{}, &CouponRedemption{}),
+	},
+	{
+		Version: 18,
+		Name:    "add resellers to users",
+		Models:  []any{&User{}},
+		Down:    dropColumns(&User{}, "reseller_id", "markup"),
+	},
+	{
+		Version: 19,
+		Name:    "create channel key usages",
+		Models:  []any{&ChannelKeyUsage{}},
+		Down:    dropTables(&ChannelKeyUsage{}),
+	},
+	{
+		Version: 20,
+		Name:    "add openai organization and project to tokens",
+		Models:  []any{&Token{}},
+		Down:    dropColumns(&Token{}, "openai_organization", "openai_project"),
+	},
+	{
+		Version: 21,
+		Name:    "create response samples",
+		Models:  []any{&ResponseSample{}},
+		Down:    dropTables(&ResponseSample{}),
+	},
+	{
+		Version: 22,
+		Name:    "add ttl to async tasks",
+		Models:  []any{&AsyncTask{}},
+		Down:    dropColumns(&AsyncTask{}, "ttl"),
+	},
+	{
+		Version: 23,
+		Name:    "add transcript webhook to tokens",
+		Models:  []any{&Token{}},
+		Down:    dropColumns(&Token{}, "transcript_webhook"),
+	},
+	{
+		Version: 24,
+		Name:    "create transcript deliveries",
+		Models:  []any{&TranscriptDelivery{}},
+		Down:    dropTables(&TranscriptDelivery{}),
+	},
+	{
+		Version: 25,
+		Name:    "add unique index to external ids of channels",
+		Up:      createChannelExternalIdIndex,
+		Down:    dropChannelExternalIdIndex,
+	},
+	{
+		Version: 26,
+		Name:    "add declared quota to tokens",
+		Models:  []any{&Token{}},
+		Up:      migrateReconciledTokens,
+		Down:    dropColumns(&Token{}, "declared_quota"),
+	},
+	{
+		Version: 27,
+		Name:    "add unique indexes to external ids of tokens and users",
+		Up: func(tx *gorm.DB) error {
+			if err := createExternalIdIndex(tx, "tokens", "user_id"); err != nil {
+				return err
+			}
+			return createExternalIdIndex(tx, "users", "")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropExternalIdIndex(tx, "tokens"); err != nil {
+				return err
+			}
+			return dropExternalIdIndex(tx, "users")
+		},
+	},
+}
+
+// logMigrations are applied to the log database, which is the main database unless LOG_SQL_DSN is set
+var logMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Models:  []any{&Log{}, &LogBody{}},
+	},
+	{
+		Version: 2,
+		Name:    "add upstream request id to logs",
+		Models:  []any{&Log{}},
+		Down:    dropColumns(&Log{}, "upstream_request_id"),
+	},
+	{
+		Version: 3,
+		Name:    "add upstream account to logs",
+		Models:  []any{&Log{}},
+		Down:    dropColumns(&Log{}, "upstream_account"),
+	},
+	{
+		Version: 4,
+		Name:    "add project to logs",
+		Models:  []any{&Log{}},
+		Down:    dropColumns(&Log{}, "project"),
+	},
+}
+
+func latestVersion(list []Migration) int {
+	if len(list) == 0 {
+		return 0
+	}
+	return list[len(list)-1].Version
+}
+
+func schemaVersion(db *gorm.DB, scope string) (int, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return 0, err
+	}
+	var version int
+	err := db.Model(&SchemaMigration{}).Where("scope = ?", scope).Select("coalesce(max(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// checkSchema refuses a database migrated by a newer binary, or one with pending migrations
+func checkSchema(db *gorm.DB, scope string, list []Migration) error {
+	version, err := schemaVersion(db, scope)
+	if err != nil {
+		return err
+	}
+	latest := latestVersion(list)
+	if version > latest {
+		return fmt.Errorf("the %s database is at schema version %d, newer than version %d of One API %s, roll it back with the newer release or upgrade", scope, version, latest, common.Version)
+	}
+	if version < latest {
+		return fmt.Errorf("the %s database is at schema version %d, One API %s requires version %d, run the migrate command first", scope, version, common.Version, latest)
+	}
+	return nil
+}
+
+// prepareSchema applies the pending migrations on the master node unless AUTO_MIGRATE_ENABLED is false,
+// otherwise it refuses to go on with a database which does not match this binary
+func prepareSchema(db *gorm.DB, scope string, list []Migration) error {
+	if !config.IsMasterNode || !config.AutoMigrateEnabled {
+		return checkSchema(db, scope, list)
+	}
+	return migrateUp(db, scope, list)
+}
+
+func migrateUp(db *gorm.DB, scope string, list []Migration) error {
+	version, err := schemaVersion(db, scope)
+	if err != nil {
+		return err
+	}
+	if latest := latestVersion(list); version > latest {
+		return checkSchema(db, scope, list)
+	}
+	for _, migration := range list {
+		if migration.Version <= version {
+			continue
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(migration.Models...); err != nil {
+				return err
+			}
+			if migration.Up != nil {
+				if err := migration.Up(tx); err != nil {
+					return err
+				}
+			}
+			return tx.Create(&SchemaMigration{
+				Scope:      scope,
+				Version:    migration.Version,
+				Name:       migration.Name,
+				AppVersion: common.Version,
+				AppliedAt:  helper.GetTimestamp(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s) of the %s database: %w", migration.Version, migration.Name, scope, err)
+		}
+		logger.SysLogf("applied migration %d (%s) of the %s database", migration.Version, migration.Name, scope)
+	}
+	return nil
+}
+
+// migrateDown reverts the applied migrations newer than version, the newest first
+func migrateDown(db *gorm.DB, scope string, list []Migration, version int) error {
+	current, err := schemaVersion(db, scope)
+	if err != nil {
+		return err
+	}
+	if current > latestVersion(list) {
+		return checkSchema(db, scope, list)
+	}
+	for i := len(list) - 1; i >= 0; i-- {
+		migration := list[i]
+		if migration.Version <= version || migration.Version > current {
+			continue
+		}
+		if migration.Down == nil {
+			return fmt.Errorf("migration %d (%s) of the %s database can not be rolled back", migration.Version, migration.Name, scope)
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, "scope = ? and version = ?", scope, migration.Version).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to roll back migration %d (%s) of the %s database: %w", migration.Version, migration.Name, scope, err)
+		}
+		logger.SysLogf("rolled back migration %d (%s) of the %s database", migration.Version, migration.Name, scope)
+	}
+	return nil
+}
+
+// RollbackDB reverts the migrations of the main database newer than version
+func RollbackDB(version int) error {
+	return migrateDown(DB, migrationScopeMain, migrations, version)
+}
+
+// GetSchemaMigrations lists the applied migrations of the main database and of the log database
+func GetSchemaMigrations() ([]*SchemaMigration, error) {
+	var applied []*SchemaMigration
+	if err := DB.Where("scope = ?", migrationScopeMain).Order("version").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	var logApplied []*SchemaMigration
+	if err := LOG_DB.Where("scope = ?", migrationScopeLog).Order("version").Find(&logApplied).Error; err != nil {
+		return nil, err
+	}
+	return append(applied, logApplied...), nil
+}
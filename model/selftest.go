@@ -0,0 +1,87 @@
+package model
+
+import (
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+// selfTestRetentionDays is how long the results of the self tests are kept
+const selfTestRetentionDays = 30
+
+// SelfTestResult tells whether a channel passed the self test of a feature, such as the streaming or the tools
+type SelfTestResult struct {
+	Id          int    `json:"id"`
+	ChannelId   int    `json:"channel_id" gorm:"index"`
+	ChannelName string `json:"channel_name" gorm:"default:''"`
+	Model       string `json:"model" gorm:"index"`
+	Feature     string `json:"feature" gorm:"type:varchar(16);index"`
+	Passed      bool   `json:"passed"`
+	Message     string `json:"message" gorm:"type:text"`
+	Latency     int64  `json:"latency"` // in milliseconds
+	CreatedAt   int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+func RecordSelfTestResults(results []*SelfTestResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	now := helper.GetTimestamp()
+	for _, result := range results {
+		result.CreatedAt = now
+	}
+	if err := DB.Create(&results).Error; err != nil {
+		return err
+	}
+	return DB.Where("created_at < ?", now-selfTestRetentionDays*24*3600).Delete(&SelfTestResult{}).Error
+}
+
+// GetLatestSelfTestResults returns the last result of each feature of each channel tested,
+// the deleted channels are left out
+func GetLatestSelfTestResults() ([]*SelfTestResult, error) {
+	var results []*SelfTestResult
+	latest := REPLICA_DB.Model(&SelfTestResult{}).Select("max(id)").Group("channel_id, feature")
+	channels := REPLICA_DB.Model(&Channel{}).Select("id")
+	err := REPLICA_DB.Where("id in (?) and channel_id in (?)", latest, channels).Order("channel_id, id").Find(&results).Error
+	return results, err
+}
+
+// SelfTestModel is a row of the feature matrix, a model supports a feature when one of its channels passed the test
+type SelfTestModel struct {
+	Model     string          `json:"model"`
+	Features  map[string]bool `json:"features"`
+	CheckedAt int64           `json:"checked_at"`
+}
+
+func buildSelfTestMatrix(results []*SelfTestResult, models []string) []SelfTestModel {
+	byModel := make(map[string]*SelfTestModel)
+	for _, result := range results {
+		row, ok := byModel[result.Model]
+		if !ok {
+			row = &SelfTestModel{Model: result.Model, Features: make(map[string]bool)}
+			byModel[result.Model] = row
+		}
+		row.Features[result.Feature] = row.Features[result.Feature] || result.Passed
+		if result.CreatedAt > row.CheckedAt {
+			row.CheckedAt = result.CreatedAt
+		}
+	}
+	matrix := make([]SelfTestModel, 0, len(models))
+	for _, name := range models {
+		if row, ok := byModel[name]; ok {
+			matrix = append(matrix, *row)
+		}
+	}
+	return matrix
+}
+
+// GetSelfTestMatrix returns the features supported by the models on the status page, the models never tested are left out
+func GetSelfTestMatrix() ([]SelfTestModel, error) {
+	results, err := GetLatestSelfTestResults()
+	if err != nil {
+		return nil, err
+	}
+	models, err := getStatusPageModels()
+	if err != nil {
+		return nil, err
+	}
+	return buildSelfTestMatrix(results, models), nil
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/random"
+)
+
+// SessionMaxIdle is the seconds a session is kept without being used, it matches the max age of the cookie
+const SessionMaxIdle = 30 * 24 * 3600
+
+// sessionActiveInterval is the seconds between the updates of the last active time, a session is not written
+// on every request
+const sessionActiveInterval = 60
+
+// Session is a login to the console, the cookie only carries its key, so that it is revoked by deleting the row
+type Session struct {
+	Id           int    `json:"id"`
+	Key          string `json:"-" gorm:"type:char(48);uniqueIndex"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Ip           string `json:"ip" gorm:"type:varchar(64);default:''"`
+	UserAgent    string `json:"user_agent" gorm:"type:varchar(255);default:''"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint"`
+	LastActiveAt int64  `json:"last_active_at" gorm:"bigint;index"`
+}
+
+// CreateSession records a login of the user, the expired sessions of the users are purged meanwhile
+func CreateSession(userId int, ip string, userAgent string) (*Session, error) {
+	now := helper.GetTimestamp()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	session := &Session{
+		Key:          random.GetRandomString(48),
+		UserId:       userId,
+		Ip:           ip,
+		UserAgent:    userAgent,
+		CreatedAt:    now,
+		LastActiveAt: now,
+	}
+	if err := DB.Where("last_active_at < ?", now-SessionMaxIdle).Delete(&Session{}).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Create(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetActiveSession returns the session of the key if it belongs to the user and has not been revoked or expired
+func GetActiveSession(key string, userId int) (*Session, error) {
+	if key == "" {
+		return nil, errors.New("会话不存在")
+	}
+	var session Session
+	err := DB.Where(quoteCol("key")+" = ? and user_id = ?", key, userId).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("会话不存在")
+		}
+		return nil, err
+	}
+	now := helper.GetTimestamp()
+	if session.LastActiveAt < now-SessionMaxIdle {
+		return nil, errors.New("会话已过期")
+	}
+	if now-session.LastActiveAt >= sessionActiveInterval {
+		session.LastActiveAt = now
+		err = DB.Model(&Session{}).Where("id = ?", session.Id).Update("last_active_at", now).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &session, nil
+}
+
+func GetUserSessions(userId int) ([]*Session, error) {
+	var sessions []*Session
+	err := DB.Where("user_id = ? and last_active_at >= ?", userId, helper.GetTimestamp()-SessionMaxIdle).
+		Order("last_active_at desc").Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession signs a session of the user out, it returns false if the user has no such session
+func RevokeSession(userId int, id int) (bool, error) {
+	result := DB.Where("id = ? and user_id = ?", id, userId).Delete(&Session{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func RevokeSessionByKey(key string) error {
+	return DB.Where(quoteCol("key")+" = ?", key).Delete(&Session{}).Error
+}
+
+// RevokeUserSessions signs the user out everywhere except the session of the key exceptKey, which may be empty
+func RevokeUserSessions(userId int, exceptKey string) error {
+	query := DB.Where("user_id = ?", userId)
+	if exceptKey != "" {
+		query = query.Where(quoteCol("key")+" <> ?", exceptKey)
+	}
+	return query.Delete(&Session{}).Error
+}
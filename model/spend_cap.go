@@ -0,0 +1,179 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/message"
+)
+
+// cappedChannel is a channel which has reached a spend cap, it is not routed until the period ends
+type cappedChannel struct {
+	period string // daily or monthly
+	end    time.Time
+}
+
+func (capped *cappedChannel) periodName() string {
+	if capped.period == "monthly" {
+		return "本月"
+	}
+	return "今日"
+}
+
+var cappedChannelsLock sync.RWMutex
+var cappedChannels = make(map[int]cappedChannel)
+
+func IsChannelCapped(id int) bool {
+	cappedChannelsLock.RLock()
+	defer cappedChannelsLock.RUnlock()
+	_, ok := cappedChannels[id]
+	return ok
+}
+
+func GetCappedChannelIds() []int {
+	cappedChannelsLock.RLock()
+	defer cappedChannelsLock.RUnlock()
+	ids := make([]int, 0, len(cappedChannels))
+	for id := range cappedChannels {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// GetUnroutableChannelIds returns the channels in maintenance and those which have reached a spend cap
+func GetUnroutableChannelIds() []int {
+	return append(GetMaintenanceChannelIds(), GetCappedChannelIds()...)
+}
+
+// excludeCappedChannels like excludeMaintenanceChannels leaves no channel when all of them have reached their caps
+func excludeCappedChannels(channels []*Channel) []*Channel {
+	cappedChannelsLock.RLock()
+	defer cappedChannelsLock.RUnlock()
+	if len(cappedChannels) == 0 {
+		return channels
+	}
+	available := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if _, ok := cappedChannels[channel.Id]; !ok {
+			available = append(available, channel)
+		}
+	}
+	return available
+}
+
+// sumChannelQuota returns the quota consumed by the channel since start, from the consume logs
+func sumChannelQuota(channelId int, start time.Time) (int64, error) {
+	var quota int64
+	err := LOG_DB.Model(&Log{}).Select("coalesce(sum(quota), 0)").
+		Where("type = ? and channel_id = ? and created_at >= ?", LogTypeConsume, channelId, start.Unix()).
+		Scan(&quota).Error
+	return quota, err
+}
+
+// spendCapOf checks the caps of the channel, the daily cap first, and returns the period of the cap reached
+func spendCapOf(channel *Channel, cfg *ChannelConfig, now time.Time) (*cappedChannel, int64, int64, error) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	periods := []struct {
+		limit int64
+		start time.Time
+		end   time.Time
+		name  string
+	}{
+		{cfg.DailyQuotaLimit, day, day.AddDate(0, 0, 1), "daily"},
+		{cfg.MonthlyQuotaLimit, month, month.AddDate(0, 1, 0), "monthly"},
+	}
+	for _, period := range periods {
+		if period.limit <= 0 {
+			continue
+		}
+		used, err := sumChannelQuota(channel.Id, period.start)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if used >= period.limit {
+			return &cappedChannel{period: period.name, end: period.end}, used, period.limit, nil
+		}
+	}
+	return nil, 0, 0, nil
+}
+
+func notifyChannelSpendCap(channel *Channel, capped *cappedChannel, used int64, limit int64) {
+	subject, content, err := message.RenderNotification(message.NotificationChannelSpendCap, map[string]any{
+		"ChannelId":   channel.Id,
+		"ChannelName": channel.Name,
+		"Period":      capped.periodName(),
+		"Used":        common.LogQuota(used),
+		"Limit":       common.LogQuota(limit),
+	})
+	if err != nil {
+		logger.SysError("failed to render notification: " + err.Error())
+		return
+	}
+	if config.RootUserEmail == "" {
+		config.RootUserEmail = GetRootUserEmail()
+	}
+	if err = message.SendEmail(subject, config.RootUserEmail, content); err != nil {
+		logger.SysError(fmt.Sprintf("failed to send email: %s", err.Error()))
+	}
+	NotifyAdminBotChats(fmt.Sprintf("%s：渠道「%s」（#%d）%s已消耗额度 %s，达到上限 %s，已暂停使用至 %s",
+		subject, channel.Name, channel.Id, capped.periodName(), common.LogQuota(used), common.LogQuota(limit),
+		capped.end.Format("2006-01-02 15:04")))
+}
+
+// refreshCappedChannels sums the spending of the channels with a spend cap, the caps are checked again every time
+// so that raising a cap takes the channel back at once, the leader alerts the caps reached
+func refreshCappedChannels() {
+	var channels []*Channel
+	err := DB.Select("id", "name", "config").Where("config like ?", "%quota_limit%").Find(&channels).Error
+	if err != nil {
+		logger.SysError("failed to load channel spend caps: " + err.Error())
+		return
+	}
+	now := time.Now()
+	capped := make(map[int]cappedChannel)
+	for _, channel := range channels {
+		cfg, err := channel.LoadConfig()
+		if err != nil {
+			continue
+		}
+		state, used, limit, err := spendCapOf(channel, &cfg, now)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to sum the spending of channel #%d: %s", channel.Id, err.Error()))
+			continue
+		}
+		if state == nil {
+			continue
+		}
+		capped[channel.Id] = *state
+		cappedChannelsLock.RLock()
+		previous, ok := cappedChannels[channel.Id]
+		cappedChannelsLock.RUnlock()
+		if !ok || previous.period != state.period {
+			logger.SysLogf("channel #%d reaches its %s spend cap: %s / %s", channel.Id, state.period, common.LogQuota(used), common.LogQuota(limit))
+			if IsLeader() {
+				go notifyChannelSpendCap(channel, state, used, limit)
+			}
+		}
+	}
+	cappedChannelsLock.Lock()
+	defer cappedChannelsLock.Unlock()
+	for id := range cappedChannels {
+		if _, ok := capped[id]; !ok {
+			logger.SysLogf("channel #%d is under its spend caps again", id)
+		}
+	}
+	cappedChannels = capped
+}
+
+// SyncChannelSpendCaps runs on every node because the channel selection is local, the caps need the consume logs
+func SyncChannelSpendCaps() {
+	for {
+		refreshCappedChannels()
+		time.Sleep(time.Minute)
+	}
+}
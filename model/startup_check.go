@@ -0,0 +1,147 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+)
+
+const startupCheckTimeout = 5 * time.Second
+
+// StartupProblem is something the startup check found broken, the check is one of database, log_database, redis,
+// channels and pricing
+type StartupProblem struct {
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// startupProblems are written once by the startup check before the server starts, then only read
+var startupProblems []StartupProblem
+
+// GetStartupProblems returns the problems found by the startup check of this node, the node serves degraded if any
+func GetStartupProblems() []StartupProblem {
+	return startupProblems
+}
+
+func IsDegraded() bool {
+	return len(startupProblems) > 0
+}
+
+func pingDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), startupCheckTimeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+func splitStartupCheckList(list string) []string {
+	var result []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// checkStartupChannels makes sure each critical model has an enabled channel in each critical group, or that each
+// group has a model at all when no model is critical
+func checkStartupChannels() []StartupProblem {
+	var problems []StartupProblem
+	models := splitStartupCheckList(config.StartupCheckModels)
+	for _, group := range splitStartupCheckList(config.StartupCheckGroups) {
+		if len(models) == 0 {
+			groupModels, err := GetGroupModels(context.Background(), group)
+			if err != nil {
+				problems = append(problems, StartupProblem{Check: "channels", Message: err.Error()})
+			} else if len(groupModels) == 0 {
+				problems = append(problems, StartupProblem{Check: "channels", Message: fmt.Sprintf("group %s has no enabled channel", group)})
+			}
+			continue
+		}
+		for _, name := range models {
+			channels, err := GetGroupModelChannels(group, name)
+			if err != nil {
+				problems = append(problems, StartupProblem{Check: "channels", Message: err.Error()})
+			} else if len(channels) == 0 {
+				problems = append(problems, StartupProblem{Check: "channels", Message: fmt.Sprintf("model %s has no enabled channel in group %s", name, group)})
+			}
+		}
+	}
+	return problems
+}
+
+// checkStartupPricing makes sure the models served by the enabled channels, after their model mapping, are priced,
+// instead of being billed with the fallback ratio
+func checkStartupPricing() []StartupProblem {
+	var channels []*Channel
+	err := DB.Select("id", "type", "models", "model_mapping").Where("status = ?", ChannelStatusEnabled).Find(&channels).Error
+	if err != nil {
+		return []StartupProblem{{Check: "pricing", Message: err.Error()}}
+	}
+	unpriced := make(map[string][]string)
+	for _, channel := range channels {
+		mapping := channel.GetModelMapping()
+		for _, name := range splitStartupCheckList(channel.Models) {
+			if mapped, ok := mapping[name]; ok && mapped != "" {
+				name = mapped
+			}
+			if !billingratio.HasModelRatio(name, channel.Type) {
+				unpriced[name] = append(unpriced[name], fmt.Sprintf("#%d", channel.Id))
+			}
+		}
+	}
+	names := make([]string, 0, len(unpriced))
+	for name := range unpriced {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var problems []StartupProblem
+	for _, name := range names {
+		problems = append(problems, StartupProblem{
+			Check:   "pricing",
+			Message: fmt.Sprintf("model %s of channels %s has no model ratio", name, strings.Join(unpriced[name], ", ")),
+		})
+	}
+	return problems
+}
+
+// RunStartupCheck checks the databases, Redis, the channels of the critical models and the pricing of the models
+// served, the problems found are kept for GetStartupProblems and returned
+func RunStartupCheck() []StartupProblem {
+	var problems []StartupProblem
+	dbErr := pingDB(DB)
+	if dbErr != nil {
+		problems = append(problems, StartupProblem{Check: "database", Message: dbErr.Error()})
+	}
+	if LOG_DB != DB {
+		if err := pingDB(LOG_DB); err != nil {
+			problems = append(problems, StartupProblem{Check: "log_database", Message: err.Error()})
+		}
+	}
+	if common.RedisEnabled {
+		ctx, cancel := context.WithTimeout(context.Background(), startupCheckTimeout)
+		if err := common.RDB.Ping(ctx).Err(); err != nil {
+			problems = append(problems, StartupProblem{Check: "redis", Message: err.Error()})
+		}
+		cancel()
+	}
+	if dbErr == nil {
+		// the channels and the pricing are read from the database
+		problems = append(problems, checkStartupChannels()...)
+		problems = append(problems, checkStartupPricing()...)
+	}
+	startupProblems = problems
+	return problems
+}
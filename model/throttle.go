@@ -0,0 +1,62 @@
+package model
+
+import (
+	"sync"
+	"time"
+)
+
+// the channels rate limited by the upstream are skipped by the channel selection until the given time
+var throttledChannelsLock sync.RWMutex
+var throttledChannels = make(map[int]time.Time)
+
+func ThrottleChannel(id int, until time.Time) {
+	throttledChannelsLock.Lock()
+	defer throttledChannelsLock.Unlock()
+	if until.After(throttledChannels[id]) {
+		throttledChannels[id] = until
+	}
+}
+
+// GetThrottledChannelIds returns the channels still throttled and forgets the others
+func GetThrottledChannelIds() []int {
+	throttledChannelsLock.Lock()
+	defer throttledChannelsLock.Unlock()
+	now := time.Now()
+	var ids []int
+	for id, until := range throttledChannels {
+		if now.Before(until) {
+			ids = append(ids, id)
+		} else {
+			delete(throttledChannels, id)
+		}
+	}
+	return ids
+}
+
+// channelThrottledUntil returns the time until which the channel is skipped, if it is throttled now
+func channelThrottledUntil(id int, now time.Time) (time.Time, bool) {
+	throttledChannelsLock.RLock()
+	defer throttledChannelsLock.RUnlock()
+	until, ok := throttledChannels[id]
+	return until, ok && now.Before(until)
+}
+
+// excludeThrottledChannels falls back to all the channels when all of them are throttled
+func excludeThrottledChannels(channels []*Channel) []*Channel {
+	throttledChannelsLock.RLock()
+	defer throttledChannelsLock.RUnlock()
+	if len(throttledChannels) == 0 {
+		return channels
+	}
+	now := time.Now()
+	available := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if until, ok := throttledChannels[channel.Id]; !ok || !now.Before(until) {
+			available = append(available, channel)
+		}
+	}
+	if len(available) == 0 {
+		return channels
+	}
+	return available
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/common/message"
+	"github.com/songquanpeng/one-api/common/random"
 )
 
 const (
@@ -34,12 +35,27 @@ type Token struct {
 	UsedQuota      int64   `json:"used_quota" gorm:"bigint;default:0"` // used quota
 	Models         *string `json:"models" gorm:"type:text"`            // allowed models
 	Subnet         *string `json:"subnet" gorm:"default:''"`           // allowed subnet
+	Defaults       string  `json:"defaults" gorm:"type:text"`          // default parameters in JSON, see relay/defaults
+	MaxConcurrency int     `json:"max_concurrency" gorm:"default:0"`   // simultaneous requests, 0 is unlimited
+	AllowedOrigins string  `json:"allowed_origins" gorm:"default:''"`  // origins allowed to use it in browsers, any if empty
+	ExternalId     string  `json:"external_id" gorm:"type:varchar(64);index;default:''"`
+	ExpiryReminded bool    `json:"-" gorm:"default:false"`
+	Sandbox        bool    `json:"sandbox" gorm:"default:false"` // answered with mock responses, see relay/sandbox
+	// OpenAIOrganization and OpenAIProject override those of the OpenAI channels for the requests of the token
+	OpenAIOrganization string `json:"openai_organization" gorm:"column:openai_organization;type:varchar(64);default:''"`
+	OpenAIProject      string `json:"openai_project" gorm:"column:openai_project;type:varchar(64);default:''"`
+	// TranscriptWebhook receives the chat completions of the token with their usage, see TranscriptDelivery
+	TranscriptWebhook string `json:"transcript_webhook" gorm:"type:varchar(512);default:''"`
+	// DeclaredQuota is the quota of a token declared in the reconciled files, the remain quota is reset when it changes
+	DeclaredQuota int64 `json:"-" gorm:"bigint;default:0"`
+	// DeletedAt keeps the deleted tokens in the trash, to be restored or purged
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
 
 func GetAllUserTokens(userId int, startIdx int, num int, order string) ([]*Token, error) {
 	var tokens []*Token
 	var err error
-	query := DB.Where("user_id = ?", userId)
+	query := REPLICA_DB.Where("user_id = ?", userId)
 
 	switch order {
 	case "remain_quota":
@@ -55,10 +71,13 @@ func GetAllUserTokens(userId int, startIdx int, num int, order string) ([]*Token
 }
 
 func SearchUserTokens(userId int, keyword string) (tokens []*Token, err error) {
-	err = DB.Where("user_id = ?", userId).Where("name LIKE ?", keyword+"%").Find(&tokens).Error
+	err = REPLICA_DB.Where("user_id = ?", userId).Where("name LIKE ?", keyword+"%").Find(&tokens).Error
 	return tokens, err
 }
 
+// ErrTokenExhausted is returned together with the token when its quota is exhausted
+var ErrTokenExhausted = errors.New("额度已用尽")
+
 func ValidateUserToken(key string) (token *Token, err error) {
 	if key == "" {
 		return nil, errors.New("未提供令牌")
@@ -72,7 +91,7 @@ func ValidateUserToken(key string) (token *Token, err error) {
 		return nil, errors.New("令牌验证失败")
 	}
 	if token.Status == TokenStatusExhausted {
-		return nil, fmt.Errorf("令牌 %s（#%d）额度已用尽", token.Name, token.Id)
+		return token, fmt.Errorf("令牌 %s（#%d）%w", token.Name, token.Id, ErrTokenExhausted)
 	} else if token.Status == TokenStatusExpired {
 		return nil, errors.New("该令牌已过期")
 	}
@@ -89,7 +108,7 @@ func ValidateUserToken(key string) (token *Token, err error) {
 		}
 		return nil, errors.New("该令牌已过期")
 	}
-	if !token.UnlimitedQuota && token.RemainQuota <= 0 {
+	if !token.UnlimitedQuota && !token.Sandbox && token.RemainQuota <= 0 {
 		if !common.RedisEnabled {
 			// in this case, we can make sure the token is exhausted
 			token.Status = TokenStatusExhausted
@@ -98,7 +117,7 @@ func ValidateUserToken(key string) (token *Token, err error) {
 				logger.SysError("failed to update token status" + err.Error())
 			}
 		}
-		return nil, errors.New("该令牌额度已用尽")
+		return token, fmt.Errorf("该令牌%w", ErrTokenExhausted)
 	}
 	return token, nil
 }
@@ -113,6 +132,15 @@ func GetTokenByIds(id int, userId int) (*Token, error) {
 	return &token, err
 }
 
+func GetTokenByExternalId(userId int, externalId string) (*Token, error) {
+	if userId == 0 || externalId == "" {
+		return nil, errors.New("userId 或 external id 为空！")
+	}
+	token := Token{}
+	err := DB.First(&token, "user_id = ? and external_id = ?", userId, externalId).Error
+	return &token, err
+}
+
 func GetTokenById(id int) (*Token, error) {
 	if id == 0 {
 		return nil, errors.New("id 为空！")
@@ -132,18 +160,24 @@ func (t *Token) Insert() error {
 // Update Make sure your token's fields is completed, because this will update non-zero values
 func (t *Token) Update() error {
 	var err error
-	err = DB.Model(t).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "models", "subnet").Updates(t).Error
+	// the expired time may be extended, remind the expiry again
+	t.ExpiryReminded = false
+	err = DB.Model(t).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "models", "subnet", "defaults", "max_concurrency", "allowed_origins", "expiry_reminded", "sandbox", "openai_organization", "openai_project", "transcript_webhook").Updates(t).Error
+	CacheInvalidateToken(t.Key)
 	return err
 }
 
 func (t *Token) SelectUpdate() error {
 	// This can update zero values
-	return DB.Model(t).Select("accessed_time", "status").Updates(t).Error
+	err := DB.Model(t).Select("accessed_time", "status").Updates(t).Error
+	CacheInvalidateToken(t.Key)
+	return err
 }
 
 func (t *Token) Delete() error {
 	var err error
 	err = DB.Delete(t).Error
+	CacheInvalidateToken(t.Key)
 	return err
 }
 
@@ -157,6 +191,23 @@ func (t *Token) GetModels() string {
 	return *t.Models
 }
 
+// RotateTokenKey replaces the key of a token of the user, the old key stops working at once
+// while the quota and the settings of the token are kept
+func RotateTokenKey(id int, userId int) (*Token, error) {
+	token, err := GetTokenByIds(id, userId)
+	if err != nil {
+		return nil, err
+	}
+	oldKey := token.Key
+	token.Key = random.GenerateKey()
+	err = DB.Model(token).Update("key", token.Key).Error
+	if err != nil {
+		return nil, err
+	}
+	CacheInvalidateToken(oldKey)
+	return token, nil
+}
+
 func DeleteTokenById(id int, userId int) (err error) {
 	// Why we need userId here? In case user want to delete other's token.
 	if id == 0 || userId == 0 {
@@ -236,36 +287,18 @@ func PreConsumeTokenQuota(tokenId int, quota int64) (err error) {
 	noMoreQuota := userQuota-quota <= 0
 	if quotaTooLow || noMoreQuota {
 		go func() {
-			email, err := GetUserEmail(token.UserId)
+			err := NotifyUser(token.UserId, message.NotificationQuotaWarning, map[string]any{
+				"Exhausted": noMoreQuota,
+				"Quota":     common.LogQuota(userQuota),
+				"TopUpLink": fmt.Sprintf("%s/topup", config.ServerAddress),
+			})
 			if err != nil {
-				logger.SysError("failed to fetch user email: " + err.Error())
+				logger.SysError("failed to send email: " + err.Error())
 			}
-			prompt := "额度提醒"
-			var contentText string
 			if noMoreQuota {
-				contentText = "您的额度已用尽"
+				NotifyUserBotChats(token.UserId, fmt.Sprintf("额度提醒：您的额度已用尽，当前剩余额度为 %s", common.LogQuota(userQuota)))
 			} else {
-				contentText = "您的额度即将用尽"
-			}
-			if email != "" {
-				topUpLink := fmt.Sprintf("%s/topup", config.ServerAddress)
-				content := message.EmailTemplate(
-					prompt,
-					fmt.Sprintf(`
-						<p>您好！</p>
-						<p>%s，当前剩余额度为 <strong>%d</strong>。</p>
-						<p>为了不影响您的使用，请及时充值。</p>
-						<p style="text-align: center; margin: 30px 0;">
-							<a href="%s" style="background-color: #007bff; color: white; padding: 12px 24px; text-decoration: none; border-radius: 4px; display: inline-block;">立即充值</a>
-						</p>
-						<p style="color: #666;">如果按钮无法点击，请复制以下链接到浏览器中打开：</p>
-						<p style="background-color: #f8f8f8; padding: 10px; border-radius: 4px; word-break: break-all;">%s</p>
-					`, contentText, userQuota, topUpLink, topUpLink),
-				)
-				err = message.SendEmail(prompt, email, content)
-				if err != nil {
-					logger.SysError("failed to send email: " + err.Error())
-				}
+				NotifyUserBotChats(token.UserId, fmt.Sprintf("额度提醒：您的额度即将用尽，当前剩余额度为 %s", common.LogQuota(userQuota)))
 			}
 		}()
 	}
@@ -279,6 +312,24 @@ func PreConsumeTokenQuota(tokenId int, quota int64) (err error) {
 	return err
 }
 
+// RefundTokenQuota gives back the quota taken for a failed request, it is recorded in the ledger as refund
+func RefundTokenQuota(tokenId int, quota int64, note string) (err error) {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	token, err := GetTokenById(tokenId)
+	if err != nil {
+		return err
+	}
+	if err = ChangeUserQuota(token.UserId, quota, LedgerTypeRefund, note); err != nil {
+		return err
+	}
+	if !token.UnlimitedQuota {
+		return IncreaseTokenQuota(tokenId, quota)
+	}
+	return nil
+}
+
 func PostConsumeTokenQuota(tokenId int, quota int64) (err error) {
 	token, err := GetTokenById(tokenId)
 	if err != nil {
@@ -0,0 +1,262 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/message"
+)
+
+const (
+	TokenAnomalySpike      = "spike"       // the usage of the last day is many times the daily usage
+	TokenAnomalyNewModel   = "new_model"   // a model never used by the token before
+	TokenAnomalyNightBurst = "night_burst" // the requests of a night hour are many times the hourly requests
+)
+
+// anomalyBaselineDays is how many days before the last day make the usual usage of the tokens
+const anomalyBaselineDays = 7
+
+// anomalyMinRequests keeps the tokens used a little from being flagged for a few requests
+const anomalyMinRequests = 20
+
+// TokenAnomaly is an unusual usage of a token found by the hourly detection, which may be a leaked key,
+// the logs only know the tokens by the user and the name
+type TokenAnomaly struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	TokenName string `json:"token_name" gorm:"index;default:''"`
+	Kind      string `json:"kind" gorm:"type:varchar(16)"`
+	// ModelName is the model of new_model
+	ModelName string  `json:"model_name" gorm:"default:''"`
+	Value     float64 `json:"value"`    // the quota of spike or the requests of night_burst
+	Baseline  float64 `json:"baseline"` // the daily quota of spike or the hourly requests of night_burst
+	Resolved  bool    `json:"resolved" gorm:"default:false"`
+	CreatedAt int64   `json:"created_at" gorm:"bigint;index"`
+}
+
+func (anomaly *TokenAnomaly) Description() string {
+	switch anomaly.Kind {
+	case TokenAnomalySpike:
+		return fmt.Sprintf("最近 24 小时消耗额度 %s，为平时每日 %s 的 %.1f 倍",
+			common.LogQuota(int64(anomaly.Value)), common.LogQuota(int64(anomaly.Baseline)), anomaly.Value/anomaly.Baseline)
+	case TokenAnomalyNewModel:
+		return fmt.Sprintf("开始使用此前从未使用的模型 %s", anomaly.ModelName)
+	case TokenAnomalyNightBurst:
+		return fmt.Sprintf("夜间一小时内请求 %.0f 次，平时每小时 %.1f 次", anomaly.Value, anomaly.Baseline)
+	}
+	return anomaly.Kind
+}
+
+type tokenUsage struct {
+	UserId       int
+	TokenName    string
+	ModelName    string
+	RequestCount int64
+	Quota        int64
+}
+
+func sumTokenUsages(start int64, end int64, byModel bool) (map[string]*tokenUsage, error) {
+	columns := "user_id, token_name"
+	if byModel {
+		columns += ", model_name"
+	}
+	var usages []*tokenUsage
+	err := LOG_DB.Model(&Log{}).
+		Select(columns+", count(1) as request_count, sum(quota) as quota").
+		Where("type = ? and created_at >= ? and created_at < ?", LogTypeConsume, start, end).
+		Group(columns).Scan(&usages).Error
+	if err != nil {
+		return nil, err
+	}
+	usagesByKey := make(map[string]*tokenUsage, len(usages))
+	for _, usage := range usages {
+		usagesByKey[usage.key(byModel)] = usage
+	}
+	return usagesByKey, nil
+}
+
+func (usage *tokenUsage) key(byModel bool) string {
+	key := strconv.Itoa(usage.UserId) + "/" + usage.TokenName
+	if byModel {
+		key += "/" + usage.ModelName
+	}
+	return key
+}
+
+// isNightHour tells whether the hour is in TokenAnomalyNightHours, such as 0-6, which may wrap midnight
+func isNightHour(hour int) bool {
+	startText, endText, ok := strings.Cut(config.TokenAnomalyNightHours, "-")
+	if !ok {
+		return false
+	}
+	start, err1 := strconv.Atoi(strings.TrimSpace(startText))
+	end, err2 := strconv.Atoi(strings.TrimSpace(endText))
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	if start <= end {
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
+
+// detectTokenAnomalies compares the usage of the last day and the last hour with the days before,
+// the tokens without usage in the days before are new and not checked
+func detectTokenAnomalies(now time.Time) ([]*TokenAnomaly, error) {
+	end := now.Unix()
+	dayStart := now.Add(-24 * time.Hour).Unix()
+	hourStart := now.Add(-time.Hour).Unix()
+	baselineStart := now.AddDate(0, 0, -anomalyBaselineDays-1).Unix()
+	baseline, err := sumTokenUsages(baselineStart, dayStart, false)
+	if err != nil {
+		return nil, err
+	}
+	lastDay, err := sumTokenUsages(dayStart, end, false)
+	if err != nil {
+		return nil, err
+	}
+	var anomalies []*TokenAnomaly
+	factor := float64(config.TokenAnomalySpikeFactor)
+	for key, usage := range lastDay {
+		usual, ok := baseline[key]
+		if !ok || usual.Quota <= 0 || usage.RequestCount < anomalyMinRequests {
+			continue
+		}
+		dailyQuota := float64(usual.Quota) / anomalyBaselineDays
+		if float64(usage.Quota) > factor*dailyQuota {
+			anomalies = append(anomalies, &TokenAnomaly{
+				UserId: usage.UserId, TokenName: usage.TokenName, Kind: TokenAnomalySpike,
+				Value: float64(usage.Quota), Baseline: dailyQuota,
+			})
+		}
+	}
+	if isNightHour(now.Add(-time.Hour).Hour()) {
+		lastHour, err := sumTokenUsages(hourStart, end, false)
+		if err != nil {
+			return nil, err
+		}
+		for key, usage := range lastHour {
+			usual, ok := baseline[key]
+			if !ok || usage.RequestCount < anomalyMinRequests {
+				continue
+			}
+			hourlyRequests := float64(usual.RequestCount) / (anomalyBaselineDays * 24)
+			if float64(usage.RequestCount) > factor*hourlyRequests {
+				anomalies = append(anomalies, &TokenAnomaly{
+					UserId: usage.UserId, TokenName: usage.TokenName, Kind: TokenAnomalyNightBurst,
+					Value: float64(usage.RequestCount), Baseline: hourlyRequests,
+				})
+			}
+		}
+	}
+	lastHourModels, err := sumTokenUsages(hourStart, end, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(lastHourModels) > 0 {
+		usedModels, err := sumTokenUsages(baselineStart, hourStart, true)
+		if err != nil {
+			return nil, err
+		}
+		for key, usage := range lastHourModels {
+			if _, ok := baseline[usage.key(false)]; !ok {
+				continue
+			}
+			if _, ok := usedModels[key]; !ok {
+				anomalies = append(anomalies, &TokenAnomaly{
+					UserId: usage.UserId, TokenName: usage.TokenName, Kind: TokenAnomalyNewModel,
+					ModelName: usage.ModelName, Value: float64(usage.RequestCount),
+				})
+			}
+		}
+	}
+	return anomalies, nil
+}
+
+// isAnomalyReported tells whether the anomaly has been found in the last day, so that it is reported once
+func isAnomalyReported(anomaly *TokenAnomaly, since int64) bool {
+	var count int64
+	DB.Model(&TokenAnomaly{}).
+		Where("user_id = ? and token_name = ? and kind = ? and model_name = ? and created_at >= ?",
+			anomaly.UserId, anomaly.TokenName, anomaly.Kind, anomaly.ModelName, since).
+		Count(&count)
+	return count > 0
+}
+
+func reportTokenAnomalies() {
+	now := time.Now()
+	anomalies, err := detectTokenAnomalies(now)
+	if err != nil {
+		logger.SysError("failed to detect token anomalies: " + err.Error())
+		return
+	}
+	since := now.Add(-24 * time.Hour).Unix()
+	for _, anomaly := range anomalies {
+		if isAnomalyReported(anomaly, since) {
+			continue
+		}
+		anomaly.CreatedAt = now.Unix()
+		if err = DB.Create(anomaly).Error; err != nil {
+			logger.SysError("failed to save token anomaly: " + err.Error())
+			continue
+		}
+		description := anomaly.Description()
+		logger.SysLogf("token anomaly of user %d token %s: %s", anomaly.UserId, anomaly.TokenName, description)
+		err = NotifyUser(anomaly.UserId, message.NotificationTokenAnomaly, map[string]any{
+			"TokenName":   anomaly.TokenName,
+			"Description": description,
+		})
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to notify token anomaly to user %d: %s", anomaly.UserId, err.Error()))
+		}
+		text := fmt.Sprintf("令牌「%s」用量异常：%s", anomaly.TokenName, description)
+		NotifyUserBotChats(anomaly.UserId, text)
+		NotifyAdminBotChats(fmt.Sprintf("用户 #%d 的%s", anomaly.UserId, text))
+	}
+}
+
+// AutomaticallyDetectTokenAnomalies checks the usage of the tokens every hour on the leader node
+func AutomaticallyDetectTokenAnomalies() {
+	for {
+		time.Sleep(time.Hour)
+		if IsLeader() && config.TokenAnomalyDetectionEnabled {
+			reportTokenAnomalies()
+		}
+	}
+}
+
+// GetTokenAnomalies returns the unresolved anomalies, of all users if userId is 0
+func GetTokenAnomalies(userId int, startIdx int, num int) ([]*TokenAnomaly, error) {
+	var anomalies []*TokenAnomaly
+	query := REPLICA_DB.Where("resolved = ?", false)
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&anomalies).Error
+	return anomalies, err
+}
+
+// ResolveTokenAnomaly marks the anomaly as dealt with, userId limits it to the anomalies of the user if not 0
+func ResolveTokenAnomaly(id int, userId int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	query := DB.Model(&TokenAnomaly{}).Where("id = ?", id)
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	result := query.Update("resolved", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("异常记录不存在")
+	}
+	return nil
+}
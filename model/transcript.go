@@ -0,0 +1,38 @@
+package model
+
+import (
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+// TranscriptDelivery is a chat completion of a token with a transcript webhook, queued to be posted to it,
+// it is deleted once delivered or given up
+type TranscriptDelivery struct {
+	Id        int    `json:"id"`
+	TokenId   int    `json:"token_id" gorm:"index"`
+	URL       string `json:"url" gorm:"type:varchar(512)"`
+	Body      string `json:"-" gorm:"type:text"`
+	Attempts  int    `json:"attempts"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+	// NextAttemptAt is when the transcript is posted, later after each failed attempt
+	NextAttemptAt int64 `json:"next_attempt_at" gorm:"bigint;index"`
+}
+
+func (delivery *TranscriptDelivery) Insert() error {
+	delivery.CreatedAt = helper.GetTimestamp()
+	delivery.NextAttemptAt = delivery.CreatedAt
+	return DB.Create(delivery).Error
+}
+
+func (delivery *TranscriptDelivery) UpdateAttempt() error {
+	return DB.Model(delivery).Select("attempts", "next_attempt_at").Updates(delivery).Error
+}
+
+func (delivery *TranscriptDelivery) Delete() error {
+	return DB.Delete(delivery).Error
+}
+
+// GetDueTranscriptDeliveries returns the transcripts whose time to be posted has come, the oldest first
+func GetDueTranscriptDeliveries(limit int) (deliveries []*TranscriptDelivery, err error) {
+	err = DB.Where("next_attempt_at <= ?", helper.GetTimestamp()).Order("id asc").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+const maxQuotaTransferRemarkLength = 100
+
+// QuotaTransfer is an entry of the transfer ledger, it is only inserted in the transaction moving the quota
+// and never updated or deleted, the usernames are kept as they were at the time of the transfer
+type QuotaTransfer struct {
+	Id           int    `json:"id"`
+	FromUserId   int    `json:"from_user_id" gorm:"index"`
+	FromUsername string `json:"from_username"`
+	ToUserId     int    `json:"to_user_id" gorm:"index"`
+	ToUsername   string `json:"to_username"`
+	Quota        int64  `json:"quota" gorm:"bigint"`
+	Fee          int64  `json:"fee" gorm:"bigint"`
+	Remark       string `json:"remark"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+// checkDailyTransferQuota is called with the sender locked, so that the concurrent transfers of the sender are
+// counted one after the other
+func checkDailyTransferQuota(tx *gorm.DB, userId int, quota int64) error {
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Unix()
+	var transferred int64
+	err := tx.Model(&QuotaTransfer{}).Where("from_user_id = ? and created_at >= ?", userId, midnight).
+		Select(ifNullFunc() + "(sum(quota), 0)").Scan(&transferred).Error
+	if err != nil {
+		return err
+	}
+	remaining := config.QuotaTransferDailyQuota - transferred
+	if quota > remaining {
+		if remaining < 0 {
+			remaining = 0
+		}
+		return fmt.Errorf("超出每日转账额度，今日还可转账 %s", common.LogQuota(remaining))
+	}
+	return nil
+}
+
+// TransferQuota moves quota from the user to the user named toUsername, the sender pays the fee on top of it
+func TransferQuota(ctx context.Context, fromUserId int, toUsername string, quota int64, remark string) (*QuotaTransfer, error) {
+	if !config.QuotaTransferEnabled {
+		return nil, errors.New("管理员未开启额度转账")
+	}
+	if quota <= 0 {
+		return nil, errors.New("转账额度必须大于 0")
+	}
+	if quota < config.QuotaTransferMinQuota {
+		return nil, fmt.Errorf("单次转账额度不能低于 %s", common.LogQuota(config.QuotaTransferMinQuota))
+	}
+	if config.QuotaTransferMaxQuota > 0 && quota > config.QuotaTransferMaxQuota {
+		return nil, fmt.Errorf("单次转账额度不能超过 %s", common.LogQuota(config.QuotaTransferMaxQuota))
+	}
+	if len([]rune(remark)) > maxQuotaTransferRemarkLength {
+		return nil, fmt.Errorf("备注不能超过 %d 个字符", maxQuotaTransferRemarkLength)
+	}
+	from, err := GetUserById(fromUserId, false)
+	if err != nil {
+		return nil, err
+	}
+	to := User{}
+	if toUsername == "" || DB.Where("username = ?", toUsername).First(&to).Error != nil {
+		return nil, errors.New("收款用户不存在")
+	}
+	if to.Id == fromUserId {
+		return nil, errors.New("不能转账给自己")
+	}
+	if to.Status != UserStatusEnabled {
+		return nil, errors.New("收款用户已被封禁")
+	}
+	fee := int64(math.Ceil(float64(quota) * config.QuotaTransferFeeRate))
+	transfer := &QuotaTransfer{
+		FromUserId:   from.Id,
+		FromUsername: from.Username,
+		ToUserId:     to.Id,
+		ToUsername:   to.Username,
+		Quota:        quota,
+		Fee:          fee,
+		Remark:       remark,
+		CreatedAt:    helper.GetTimestamp(),
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if config.QuotaTransferDailyQuota > 0 {
+			// SQLite has no row locks, its transactions are serialized by the database lock instead
+			var id int
+			err := tx.Model(&User{}).Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", from.Id).Take(&id).Error
+			if err != nil {
+				return err
+			}
+			if err = checkDailyTransferQuota(tx, from.Id, quota); err != nil {
+				return err
+			}
+		}
+		result := tx.Model(&User{}).Where("id = ? and quota >= ?", from.Id, quota+fee).
+			Update("quota", gorm.Expr("quota - ?", quota+fee))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("额度不足")
+		}
+		err := appendQuotaLedger(tx, from.Id, -(quota + fee), LedgerTypeTransfer, "转账给用户 "+to.Username)
+		if err != nil {
+			return err
+		}
+		err = changeUserQuota(tx, to.Id, quota, LedgerTypeTransfer, "收到用户 "+from.Username+" 的转账")
+		if err != nil {
+			return err
+		}
+		return tx.Create(transfer).Error
+	})
+	if err != nil {
+		return nil, errors.New("转账失败，" + err.Error())
+	}
+	CacheInvalidateUser(from.Id)
+	CacheInvalidateUser(to.Id)
+	content := fmt.Sprintf("转账 %s 给用户 %s", common.LogQuota(quota), to.Username)
+	if fee > 0 {
+		content += fmt.Sprintf("，手续费 %s", common.LogQuota(fee))
+	}
+	RecordLog(ctx, from.Id, LogTypeSystem, content)
+	RecordLog(ctx, to.Id, LogTypeSystem, fmt.Sprintf("收到用户 %s 转账 %s", from.Username, common.LogQuota(quota)))
+	return transfer, nil
+}
+
+// GetQuotaTransfers returns the transfers sent or received by the user, or all of them when userId is 0
+func GetQuotaTransfers(userId int, startIdx int, num int) (transfers []*QuotaTransfer, err error) {
+	query := DB.Model(&QuotaTransfer{})
+	if userId != 0 {
+		query = query.Where("from_user_id = ? or to_user_id = ?", userId, userId)
+	}
+	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&transfers).Error
+	return transfers, err
+}
@@ -0,0 +1,154 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/blacklist"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// the deleted channels, tokens and users stay in the trash, where they can be restored,
+// until they are purged by hand or after TRASH_RETENTION_DAYS
+
+func trashed(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Where("deleted_at is not null")
+}
+
+func GetDeletedChannels(startIdx int, num int) ([]*Channel, error) {
+	var channels []*Channel
+	err := trashed(REPLICA_DB).Omit("key").Order("deleted_at desc").Limit(num).Offset(startIdx).Find(&channels).Error
+	return channels, err
+}
+
+func GetDeletedTokens(userId int, startIdx int, num int) ([]*Token, error) {
+	var tokens []*Token
+	err := trashed(REPLICA_DB).Where("user_id = ?", userId).Order("deleted_at desc").Limit(num).Offset(startIdx).Find(&tokens).Error
+	return tokens, err
+}
+
+func GetDeletedUsers(startIdx int, num int) ([]*User, error) {
+	var users []*User
+	err := trashed(REPLICA_DB).Omit("password").Order("deleted_at desc").Limit(num).Offset(startIdx).Find(&users).Error
+	return users, err
+}
+
+func GetDeletedUserById(id int) (*User, error) {
+	user := User{}
+	err := trashed(DB).Omit("password").First(&user, "id = ?", id).Error
+	return &user, err
+}
+
+// RestoreChannelById takes the channel out of the trash, its abilities are created again
+func RestoreChannelById(id int) error {
+	channel := Channel{}
+	if err := trashed(DB).First(&channel, "id = ?", id).Error; err != nil {
+		return errors.New("回收站中没有该渠道")
+	}
+	if err := DB.Unscoped().Model(&channel).Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	if err := channel.DeleteAbilities(); err != nil {
+		return err
+	}
+	if err := channel.AddAbilities(); err != nil {
+		return err
+	}
+	cacheInvalidateChannels()
+	return nil
+}
+
+func RestoreTokenById(id int, userId int) error {
+	token := Token{}
+	if err := trashed(DB).First(&token, "id = ? and user_id = ?", id, userId).Error; err != nil {
+		return errors.New("回收站中没有该令牌")
+	}
+	if err := DB.Unscoped().Model(&token).Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	CacheInvalidateToken(token.Key)
+	return nil
+}
+
+func RestoreUserById(id int) error {
+	result := trashed(DB).Model(&User{}).Where("id = ?", id).Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("回收站中没有该用户")
+	}
+	blacklist.UnbanUser(id)
+	CacheInvalidateUser(id)
+	return nil
+}
+
+func PurgeChannelById(id int) error {
+	result := trashed(DB).Where("id = ?", id).Delete(&Channel{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("回收站中没有该渠道")
+	}
+	return nil
+}
+
+func PurgeTokenById(id int, userId int) error {
+	result := trashed(DB).Where("id = ? and user_id = ?", id, userId).Delete(&Token{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("回收站中没有该令牌")
+	}
+	return nil
+}
+
+// PurgeUserById removes the user for good, along with its tokens
+func PurgeUserById(id int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := trashed(tx).Where("id = ?", id).Delete(&User{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("回收站中没有该用户")
+		}
+		return tx.Unscoped().Where("user_id = ?", id).Delete(&Token{}).Error
+	})
+}
+
+func purgeTrash(before time.Time) error {
+	if err := trashed(DB).Where("deleted_at < ?", before).Delete(&Channel{}).Error; err != nil {
+		return err
+	}
+	if err := trashed(DB).Where("deleted_at < ?", before).Delete(&Token{}).Error; err != nil {
+		return err
+	}
+	var userIds []int
+	if err := trashed(DB).Model(&User{}).Where("deleted_at < ?", before).Pluck("id", &userIds).Error; err != nil {
+		return err
+	}
+	for _, id := range userIds {
+		if err := PurgeUserById(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AutomaticallyPurgeTrash removes the channels, tokens and users deleted retentionDays ago every hour
+func AutomaticallyPurgeTrash(retentionDays int) {
+	for {
+		if IsLeader() {
+			before := time.Now().AddDate(0, 0, -retentionDays)
+			if err := purgeTrash(before); err != nil {
+				logger.SysError("failed to purge trash: " + err.Error())
+			}
+		}
+		time.Sleep(time.Hour)
+	}
+}
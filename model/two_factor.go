@@ -0,0 +1,122 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/common/totp"
+)
+
+const twoFactorRecoveryCodeCount = 10
+
+// IsTwoFactorEnforced tells whether the users of the role must enable the two-factor authentication
+func IsTwoFactorEnforced(role int) bool {
+	return slices.Contains(strings.Split(config.TwoFactorEnforcedRoles, ","), strconv.Itoa(role))
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateRecoveryCodes returns the recovery codes shown once to the user, and their hashes to be saved
+func generateRecoveryCodes() ([]string, string) {
+	codes := make([]string, twoFactorRecoveryCodeCount)
+	hashes := make([]string, twoFactorRecoveryCodeCount)
+	for i := range codes {
+		code := strings.ToLower(random.GetRandomString(10))
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(strings.ReplaceAll(codes[i], "-", ""))
+	}
+	return codes, strings.Join(hashes, ",")
+}
+
+// SetTwoFactorSecret saves the secret the user is enrolling, it is used for login once enabled
+func SetTwoFactorSecret(userId int, secret string) error {
+	return DB.Model(&User{}).Where("id = ? and two_factor_enabled = ?", userId, false).
+		Update("two_factor_secret", secret).Error
+}
+
+// EnableTwoFactor enables the two-factor authentication of the user once the code of its secret is entered,
+// it returns the recovery codes of the user
+func (user *User) EnableTwoFactor(code string) ([]string, bool, error) {
+	if user.TwoFactorEnabled || user.TwoFactorSecret == "" {
+		return nil, false, nil
+	}
+	step := totp.Validate(user.TwoFactorSecret, code, time.Now())
+	if step == 0 {
+		return nil, false, nil
+	}
+	codes, hashes := generateRecoveryCodes()
+	result := DB.Model(&User{}).Where("id = ? and two_factor_enabled = ?", user.Id, false).Updates(map[string]any{
+		"two_factor_enabled":        true,
+		"two_factor_recovery_codes": hashes,
+		"two_factor_last_step":      step,
+	})
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	return codes, result.RowsAffected == 1, nil
+}
+
+// DisableTwoFactor removes the secret and the recovery codes of the user
+func DisableTwoFactor(userId int) error {
+	return DB.Model(&User{}).Where("id = ?", userId).Updates(map[string]any{
+		"two_factor_enabled":        false,
+		"two_factor_secret":         "",
+		"two_factor_recovery_codes": "",
+		"two_factor_last_step":      0,
+	}).Error
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user, the previous ones can not be used anymore
+func RegenerateRecoveryCodes(userId int) ([]string, error) {
+	codes, hashes := generateRecoveryCodes()
+	err := DB.Model(&User{}).Where("id = ? and two_factor_enabled = ?", userId, true).
+		Update("two_factor_recovery_codes", hashes).Error
+	return codes, err
+}
+
+// RecoveryCodesLeft is the count of the unused recovery codes of the user
+func (user *User) RecoveryCodesLeft() int {
+	if user.TwoFactorRecoveryCodes == "" {
+		return 0
+	}
+	return len(strings.Split(user.TwoFactorRecoveryCodes, ","))
+}
+
+// VerifyTwoFactor checks the code of the authenticator app or a recovery code of the user, each of them
+// is accepted once, so that a code seen by someone else can not be used again
+func (user *User) VerifyTwoFactor(code string) bool {
+	if !user.TwoFactorEnabled {
+		return false
+	}
+	code = strings.ReplaceAll(strings.TrimSpace(code), "-", "")
+	if step := totp.Validate(user.TwoFactorSecret, code, time.Now()); step != 0 {
+		result := DB.Model(&User{}).Where("id = ? and two_factor_last_step < ?", user.Id, step).
+			Update("two_factor_last_step", step)
+		return result.Error == nil && result.RowsAffected == 1
+	}
+	if code == "" || user.TwoFactorRecoveryCodes == "" {
+		return false
+	}
+	hashes := strings.Split(user.TwoFactorRecoveryCodes, ",")
+	index := slices.Index(hashes, hashRecoveryCode(code))
+	if index < 0 {
+		return false
+	}
+	left := strings.Join(slices.Delete(slices.Clone(hashes), index, index+1), ",")
+	result := DB.Model(&User{}).Where("id = ? and two_factor_recovery_codes = ?", user.Id, user.TwoFactorRecoveryCodes).
+		Update("two_factor_recovery_codes", left)
+	if result.Error != nil || result.RowsAffected != 1 {
+		return false
+	}
+	user.TwoFactorRecoveryCodes = left
+	return true
+}
@@ -17,10 +17,11 @@ import (
 )
 
 const (
-	RoleGuestUser  = 0
-	RoleCommonUser = 1
-	RoleAdminUser  = 10
-	RoleRootUser   = 100
+	RoleGuestUser    = 0
+	RoleCommonUser   = 1
+	RoleResellerUser = 5 // manages its own users, whose requests are marked up for it
+	RoleAdminUser    = 10
+	RoleRootUser     = 100
 )
 
 const (
@@ -43,14 +44,45 @@ type User struct {
 	WeChatId         string `json:"wechat_id" gorm:"column:wechat_id;index"`
 	LarkId           string `json:"lark_id" gorm:"column:lark_id;index"`
 	OidcId           string `json:"oidc_id" gorm:"column:oidc_id;index"`
+	SamlId           string `json:"saml_id" gorm:"column:saml_id;index"`
 	VerificationCode string `json:"verification_code" gorm:"-:all"`                                    // this field is only for Email verification, don't save it to database!
 	AccessToken      string `json:"access_token" gorm:"type:char(32);column:access_token;uniqueIndex"` // this token is for system management
 	Quota            int64  `json:"quota" gorm:"bigint;default:0"`
 	UsedQuota        int64  `json:"used_quota" gorm:"bigint;default:0;column:used_quota"` // used quota
 	RequestCount     int    `json:"request_count" gorm:"type:int;default:0;"`             // request number
 	Group            string `json:"group" gorm:"type:varchar(32);default:'default'"`
+	ExtraGroups      string `json:"extra_groups" gorm:"type:varchar(255);default:''"` // the other groups of the user separated by commas, after its group in precedence
 	AffCode          string `json:"aff_code" gorm:"type:varchar(32);column:aff_code;uniqueIndex"`
 	InviterId        int    `json:"inviter_id" gorm:"type:int;column:inviter_id;index"`
+	ExternalId       string `json:"external_id" gorm:"type:varchar(64);index;default:''"`
+	TrialPending     bool   `json:"trial_pending" gorm:"default:false"` // the quota for new user is granted once the email is bound
+	RegisterIp       string `json:"-" gorm:"type:varchar(64);index;default:''"`
+	RegisterDevice   string `json:"-" gorm:"type:varchar(64);index;default:''"`
+	// DisabledNotifications are the comma separated notifications the user has opted out of
+	DisabledNotifications string `json:"-" gorm:"type:varchar(255);default:''"`
+	// EnabledNotifications are the comma separated notifications the user has opted in to, such as the digests
+	EnabledNotifications string `json:"-" gorm:"type:varchar(255);default:''"`
+	// Currency is the currency the quota is displayed in for the user, the base currency if empty
+	Currency string `json:"currency" gorm:"type:varchar(8);default:''"`
+	// PlanId is the plan of the user, 0 if none, its monthly quota is granted again at PlanResetAt. PlanQuota is
+	// the quota granted in the period, and PlanUsedQuotaBase is the used quota at its start
+	PlanId            int   `json:"plan_id" gorm:"index;default:0"`
+	PlanResetAt       int64 `json:"plan_reset_at" gorm:"bigint;default:0"`
+	PlanQuota         int64 `json:"plan_quota" gorm:"bigint;default:0"`
+	PlanUsedQuotaBase int64 `json:"-" gorm:"bigint;default:0"`
+	// TwoFactorSecret is the TOTP secret, it is kept but not used for login until TwoFactorEnabled once the
+	// user has entered a code of it. TwoFactorRecoveryCodes are the hashes of the unused recovery codes
+	// separated by commas, and TwoFactorLastStep is the time step of the last code used, which can not be used again
+	TwoFactorEnabled       bool   `json:"two_factor_enabled" gorm:"default:false"`
+	TwoFactorSecret        string `json:"-" gorm:"type:varchar(64);default:''"`
+	TwoFactorRecoveryCodes string `json:"-" gorm:"type:text"`
+	TwoFactorLastStep      int64  `json:"-" gorm:"bigint;default:0"`
+	// ResellerId is the reseller managing the user, 0 for the users of the platform. Markup is the percentage a
+	// reseller adds to the price of the requests of its users, credited to the reseller
+	ResellerId int     `json:"reseller_id" gorm:"index;default:0"`
+	Markup     float64 `json:"markup" gorm:"default:0"`
+	// DeletedAt keeps the deleted users in the trash, to be restored or purged, they keep their username till then
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
 
 func GetMaxUserId() int {
@@ -60,7 +92,7 @@ func GetMaxUserId() int {
 }
 
 func GetAllUsers(startIdx int, num int, order string) (users []*User, err error) {
-	query := DB.Limit(num).Offset(startIdx).Omit("password").Where("status != ?", UserStatusDeleted)
+	query := REPLICA_DB.Limit(num).Offset(startIdx).Omit("password").Where("status != ?", UserStatusDeleted)
 
 	switch order {
 	case "quota":
@@ -79,9 +111,9 @@ func GetAllUsers(startIdx int, num int, order string) (users []*User, err error)
 
 func SearchUsers(keyword string) (users []*User, err error) {
 	if !common.UsingPostgreSQL {
-		err = DB.Omit("password").Where("id = ? or username LIKE ? or email LIKE ? or display_name LIKE ?", keyword, keyword+"%", keyword+"%", keyword+"%").Find(&users).Error
+		err = REPLICA_DB.Omit("password").Where("id = ? or username LIKE ? or email LIKE ? or display_name LIKE ?", keyword, keyword+"%", keyword+"%", keyword+"%").Find(&users).Error
 	} else {
-		err = DB.Omit("password").Where("username LIKE ? or email LIKE ? or display_name LIKE ?", keyword+"%", keyword+"%", keyword+"%").Find(&users).Error
+		err = REPLICA_DB.Omit("password").Where("username LIKE ? or email LIKE ? or display_name LIKE ?", keyword+"%", keyword+"%", keyword+"%").Find(&users).Error
 	}
 	return users, err
 }
@@ -100,6 +132,15 @@ func GetUserById(id int, selectAll bool) (*User, error) {
 	return &user, err
 }
 
+func GetUserByExternalId(externalId string) (*User, error) {
+	if externalId == "" {
+		return nil, errors.New("external id 为空！")
+	}
+	user := User{}
+	err := DB.Omit("password", "access_token").First(&user, "external_id = ?", externalId).Error
+	return &user, err
+}
+
 func GetUserIdByAffCode(affCode string) (int, error) {
 	if affCode == "" {
 		return 0, errors.New("affCode 为空！")
@@ -126,28 +167,35 @@ func (user *User) Insert(ctx context.Context, inviterId int) error {
 		}
 	}
 	user.Quota = config.QuotaForNewUser
+	if config.NewUserQuotaEmailRequiredEnabled && user.Email == "" {
+		user.Quota = 0
+		user.TrialPending = config.QuotaForNewUser > 0
+	}
 	user.AccessToken = random.GetUUID()
 	user.AffCode = random.GetRandomString(4)
-	result := DB.Create(user)
-	if result.Error != nil {
-		return result.Error
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return appendQuotaLedger(tx, user.Id, user.Quota, LedgerTypeReward, "新用户注册赠送")
+	})
+	if err != nil {
+		return err
 	}
-	if config.QuotaForNewUser > 0 {
+	if user.Quota > 0 {
 		RecordLog(ctx, user.Id, LogTypeSystem, fmt.Sprintf("新用户注册赠送 %s", common.LogQuota(config.QuotaForNewUser)))
 	}
 	if inviterId != 0 {
-		if config.QuotaForInvitee > 0 {
-			_ = IncreaseUserQuota(user.Id, config.QuotaForInvitee)
-			RecordLog(ctx, user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", common.LogQuota(config.QuotaForInvitee)))
-		}
-		if config.QuotaForInviter > 0 {
-			_ = IncreaseUserQuota(inviterId, config.QuotaForInviter)
-			RecordLog(ctx, inviterId, LogTypeSystem, fmt.Sprintf("邀请用户赠送 %s", common.LogQuota(config.QuotaForInviter)))
-		}
+		rewardInvite(ctx, user, inviterId)
 	}
-	// create default token
+	createDefaultToken(user.Id)
+	return nil
+}
+
+// createDefaultToken gives the new user an unlimited token, the user is created even if it fails
+func createDefaultToken(userId int) {
 	cleanToken := Token{
-		UserId:         user.Id,
+		UserId:         userId,
 		Name:           "default",
 		Key:            random.GenerateKey(),
 		CreatedTime:    helper.GetTimestamp(),
@@ -156,12 +204,11 @@ func (user *User) Insert(ctx context.Context, inviterId int) error {
 		RemainQuota:    -1,
 		UnlimitedQuota: true,
 	}
-	result.Error = cleanToken.Insert()
-	if result.Error != nil {
+	err := cleanToken.Insert()
+	if err != nil {
 		// do not block
-		logger.SysError(fmt.Sprintf("create default token for user %d failed: %s", user.Id, result.Error.Error()))
+		logger.SysError(fmt.Sprintf("create default token for user %d failed: %s", userId, err.Error()))
 	}
-	return nil
 }
 
 func (user *User) Update(updatePassword bool) error {
@@ -177,7 +224,22 @@ func (user *User) Update(updatePassword bool) error {
 	} else if user.Status == UserStatusEnabled {
 		blacklist.UnbanUser(user.Id)
 	}
-	err = DB.Model(user).Updates(user).Error
+	// the plan is changed by SetUserPlan only, which grants its quota, the extra groups by UpdateUserExtraGroups, the
+	// reseller by SetReseller and the two-factor authentication by their own functions, the quota is changed by the
+	// difference with the current one so that the ledger records it
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Omit("quota", "extra_groups", "plan_id", "plan_reset_at", "plan_quota", "plan_used_quota_base",
+			"reseller_id", "markup", "two_factor_enabled", "two_factor_secret", "two_factor_recovery_codes", "two_factor_last_step").Updates(user).Error
+		if err != nil || user.Quota == 0 {
+			return err
+		}
+		var quota int64
+		if err = tx.Model(&User{}).Where("id = ?", user.Id).Select("quota").Find(&quota).Error; err != nil {
+			return err
+		}
+		return changeUserQuota(tx, user.Id, user.Quota-quota, LedgerTypeAdjustment, "修改用户额度")
+	})
+	CacheInvalidateUser(user.Id)
 	return err
 }
 
@@ -186,10 +248,12 @@ func (user *User) Delete() error {
 		return errors.New("id 为空！")
 	}
 	blacklist.BanUser(user.Id)
-	user.Username = fmt.Sprintf("deleted_%s", random.GetUUID())
-	user.Status = UserStatusDeleted
-	err := DB.Model(user).Updates(user).Error
-	return err
+	err := DB.Delete(user).Error
+	CacheInvalidateUser(user.Id)
+	if err != nil {
+		return err
+	}
+	return RevokeUserSessions(user.Id, "")
 }
 
 // ValidateAndFill check password & user status
@@ -257,6 +321,14 @@ func (user *User) FillUserByOidcId() error {
 	return nil
 }
 
+func (user *User) FillUserBySamlId() error {
+	if user.SamlId == "" {
+		return errors.New("saml id 为空！")
+	}
+	DB.Where(User{SamlId: user.SamlId}).First(user)
+	return nil
+}
+
 func (user *User) FillUserByWeChatId() error {
 	if user.WeChatId == "" {
 		return errors.New("WeChat id 为空！")
@@ -274,27 +346,38 @@ func (user *User) FillUserByUsername() error {
 }
 
 func IsEmailAlreadyTaken(email string) bool {
-	return DB.Where("email = ?", email).Find(&User{}).RowsAffected == 1
+	return DB.Unscoped().Where("email = ?", email).Find(&User{}).RowsAffected == 1
 }
 
 func IsWeChatIdAlreadyTaken(wechatId string) bool {
-	return DB.Where("wechat_id = ?", wechatId).Find(&User{}).RowsAffected == 1
+	return DB.Unscoped().Where("wechat_id = ?", wechatId).Find(&User{}).RowsAffected == 1
 }
 
 func IsGitHubIdAlreadyTaken(githubId string) bool {
-	return DB.Where("github_id = ?", githubId).Find(&User{}).RowsAffected == 1
+	return DB.Unscoped().Where("github_id = ?", githubId).Find(&User{}).RowsAffected == 1
 }
 
 func IsLarkIdAlreadyTaken(githubId string) bool {
-	return DB.Where("lark_id = ?", githubId).Find(&User{}).RowsAffected == 1
+	return DB.Unscoped().Where("lark_id = ?", githubId).Find(&User{}).RowsAffected == 1
 }
 
 func IsOidcIdAlreadyTaken(oidcId string) bool {
-	return DB.Where("oidc_id = ?", oidcId).Find(&User{}).RowsAffected == 1
+	return DB.Unscoped().Where("oidc_id = ?", oidcId).Find(&User{}).RowsAffected == 1
+}
+
+func IsSamlIdAlreadyTaken(samlId string) bool {
+	return DB.Unscoped().Where("saml_id = ?", samlId).Find(&User{}).RowsAffected == 1
+}
+
+// UpdateUserGroup moves the user to the group, like when the group is given by the IdP
+func UpdateUserGroup(id int, group string) error {
+	err := DB.Model(&User{}).Where("id = ?", id).Update("group", group).Error
+	CacheInvalidateUser(id)
+	return err
 }
 
 func IsUsernameAlreadyTaken(username string) bool {
-	return DB.Where("username = ?", username).Find(&User{}).RowsAffected == 1
+	return DB.Unscoped().Where("username = ?", username).Find(&User{}).RowsAffected == 1
 }
 
 func ResetUserPasswordByEmail(email string, password string) error {
@@ -306,7 +389,11 @@ func ResetUserPasswordByEmail(email string, password string) error {
 		return err
 	}
 	err = DB.Model(&User{}).Where("email = ?", email).Update("password", hashedPassword).Error
-	return err
+	if err != nil {
+		return err
+	}
+	// whoever knew the old password is signed out
+	return DB.Where("user_id in (?)", DB.Model(&User{}).Select("id").Where("email = ?", email)).Delete(&Session{}).Error
 }
 
 func IsAdmin(userId int) bool {
@@ -362,15 +449,17 @@ func GetUserEmail(id int) (email string, err error) {
 }
 
 func GetUserGroup(id int) (group string, err error) {
-	groupCol := "`group`"
-	if common.UsingPostgreSQL {
-		groupCol = `"group"`
-	}
-
+	groupCol := quoteCol("group")
 	err = DB.Model(&User{}).Where("id = ?", id).Select(groupCol).Find(&group).Error
 	return group, err
 }
 
+func GetUserPlanId(id int) (planId int, err error) {
+	err = DB.Model(&User{}).Where("id = ?", id).Select("plan_id").Find(&planId).Error
+	return planId, err
+}
+
+// IncreaseUserQuota gives back the quota of the requests, it is recorded in the ledger as consume
 func IncreaseUserQuota(id int, quota int64) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
@@ -382,11 +471,38 @@ func IncreaseUserQuota(id int, quota int64) (err error) {
 	return increaseUserQuota(id, quota)
 }
 
+// GrantPendingTrialQuota gives the quota for new user held back by NewUserQuotaEmailRequiredEnabled,
+// it does nothing if the user has got it already
+func GrantPendingTrialQuota(ctx context.Context, id int) error {
+	var granted bool
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ? and trial_pending = ?", id, true).Updates(map[string]interface{}{
+			"trial_pending": false,
+			"quota":         gorm.Expr("quota + ?", config.QuotaForNewUser),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		granted = result.RowsAffected > 0
+		if !granted {
+			return nil
+		}
+		return appendQuotaLedger(tx, id, config.QuotaForNewUser, LedgerTypeReward, "绑定邮箱赠送")
+	})
+	if err != nil {
+		return err
+	}
+	if granted && config.QuotaForNewUser > 0 {
+		RecordLog(ctx, id, LogTypeSystem, fmt.Sprintf("绑定邮箱赠送 %s", common.LogQuota(config.QuotaForNewUser)))
+	}
+	return nil
+}
+
 func increaseUserQuota(id int, quota int64) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", quota)).Error
-	return err
+	return ChangeUserQuota(id, quota, LedgerTypeConsume, "")
 }
 
+// DecreaseUserQuota takes the quota of the requests, it is recorded in the ledger as consume
 func DecreaseUserQuota(id int, quota int64) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
@@ -399,8 +515,7 @@ func DecreaseUserQuota(id int, quota int64) (err error) {
 }
 
 func decreaseUserQuota(id int, quota int64) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota - ?", quota)).Error
-	return err
+	return ChangeUserQuota(id, -quota, LedgerTypeConsume, "")
 }
 
 func GetRootUserEmail() (email string) {
@@ -1,10 +1,14 @@
 package model
 
 import (
-	"github.com/songquanpeng/one-api/common/config"
-	"github.com/songquanpeng/one-api/common/logger"
+	"bufio"
+	"fmt"
+	"os"
 	"sync"
 	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
 )
 
 const (
@@ -19,6 +23,13 @@ const (
 var batchUpdateStores []map[int]int64
 var batchUpdateLocks []sync.Mutex
 
+// The journal records every pending update so that they survive a crash.
+// A flush renames it to batchUpdateJournalFlushing and marks each applied update in it,
+// so that replaying after a crash applies every update exactly once.
+var batchUpdateJournal *os.File
+var batchUpdateJournalLock sync.Mutex
+var batchUpdateFlushLock sync.Mutex
+
 func init() {
 	for i := 0; i < BatchUpdateTypeCount; i++ {
 		batchUpdateStores = append(batchUpdateStores, make(map[int]int64))
@@ -26,7 +37,19 @@ func init() {
 	}
 }
 
+func batchUpdateJournalFlushing() string {
+	return config.BatchUpdateJournal + ".flushing"
+}
+
 func InitBatchUpdater() {
+	if config.BatchUpdateJournal != "" {
+		replayBatchUpdateJournal()
+		var err error
+		batchUpdateJournal, err = os.OpenFile(config.BatchUpdateJournal, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			logger.FatalLog("failed to open batch update journal: " + err.Error())
+		}
+	}
 	go func() {
 		for {
 			time.Sleep(time.Duration(config.BatchUpdateInterval) * time.Second)
@@ -35,6 +58,14 @@ func InitBatchUpdater() {
 	}()
 }
 
+// FlushBatchUpdates writes all pending updates to the database, it should be called before exiting
+func FlushBatchUpdates() {
+	if !config.BatchUpdateEnabled {
+		return
+	}
+	batchUpdate()
+}
+
 func addNewRecord(type_ int, id int, value int64) {
 	batchUpdateLocks[type_].Lock()
 	defer batchUpdateLocks[type_].Unlock()
@@ -43,36 +74,143 @@ func addNewRecord(type_ int, id int, value int64) {
 	} else {
 		batchUpdateStores[type_][id] += value
 	}
+	writeBatchUpdateJournal(fmt.Sprintf("%d %d %d\n", type_, id, value))
+}
+
+func writeBatchUpdateJournal(line string) {
+	batchUpdateJournalLock.Lock()
+	defer batchUpdateJournalLock.Unlock()
+	if batchUpdateJournal == nil {
+		return
+	}
+	if _, err := batchUpdateJournal.WriteString(line); err != nil {
+		logger.SysError("failed to write batch update journal: " + err.Error())
+	}
+}
+
+type batchUpdateKey struct {
+	type_ int
+	id    int
+}
+
+func replayBatchUpdateJournal() {
+	pending := make(map[batchUpdateKey]int64)
+	for _, path := range []string{batchUpdateJournalFlushing(), config.BatchUpdateJournal} {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		done := make(map[batchUpdateKey]bool)
+		records := make(map[batchUpdateKey]int64)
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var key batchUpdateKey
+			var value int64
+			if _, err := fmt.Sscanf(scanner.Text(), "done %d %d", &key.type_, &key.id); err == nil {
+				done[key] = true
+				continue
+			}
+			if _, err := fmt.Sscanf(scanner.Text(), "%d %d %d", &key.type_, &key.id, &value); err != nil {
+				// the last line may be incomplete if we crashed while writing it
+				continue
+			}
+			if key.type_ < 0 || key.type_ >= BatchUpdateTypeCount {
+				continue
+			}
+			records[key] += value
+		}
+		_ = file.Close()
+		for key, value := range records {
+			if !done[key] {
+				pending[key] += value
+			}
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+	logger.SysLogf("replaying %d pending batch updates from journal", len(pending))
+	for key, value := range pending {
+		applyBatchUpdate(key.type_, key.id, value)
+	}
+	_ = os.Remove(batchUpdateJournalFlushing())
+	_ = os.Remove(config.BatchUpdateJournal)
+}
+
+// rotateBatchUpdateJournal must be called while holding all the batch update locks
+func rotateBatchUpdateJournal() *os.File {
+	batchUpdateJournalLock.Lock()
+	defer batchUpdateJournalLock.Unlock()
+	if batchUpdateJournal == nil {
+		return nil
+	}
+	_ = batchUpdateJournal.Close()
+	if err := os.Rename(config.BatchUpdateJournal, batchUpdateJournalFlushing()); err != nil {
+		logger.SysError("failed to rotate batch update journal: " + err.Error())
+	}
+	var err error
+	batchUpdateJournal, err = os.OpenFile(config.BatchUpdateJournal, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.SysError("failed to open batch update journal: " + err.Error())
+	}
+	flushing, err := os.OpenFile(batchUpdateJournalFlushing(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.SysError("failed to open flushing batch update journal: " + err.Error())
+		return nil
+	}
+	return flushing
+}
+
+func applyBatchUpdate(type_ int, id int, value int64) {
+	switch type_ {
+	case BatchUpdateTypeUserQuota:
+		err := increaseUserQuota(id, value)
+		if err != nil {
+			logger.SysError("failed to batch update user quota: " + err.Error())
+		}
+	case BatchUpdateTypeTokenQuota:
+		err := increaseTokenQuota(id, value)
+		if err != nil {
+			logger.SysError("failed to batch update token quota: " + err.Error())
+		}
+	case BatchUpdateTypeUsedQuota:
+		updateUserUsedQuota(id, value)
+	case BatchUpdateTypeRequestCount:
+		updateUserRequestCount(id, int(value))
+	case BatchUpdateTypeChannelUsedQuota:
+		updateChannelUsedQuota(id, value)
+	}
 }
 
 func batchUpdate() {
+	// the periodic flush and the flush on exit must not run at the same time
+	batchUpdateFlushLock.Lock()
+	defer batchUpdateFlushLock.Unlock()
 	logger.SysLog("batch update started")
+	stores := make([]map[int]int64, BatchUpdateTypeCount)
 	for i := 0; i < BatchUpdateTypeCount; i++ {
 		batchUpdateLocks[i].Lock()
-		store := batchUpdateStores[i]
+	}
+	for i := 0; i < BatchUpdateTypeCount; i++ {
+		stores[i] = batchUpdateStores[i]
 		batchUpdateStores[i] = make(map[int]int64)
+	}
+	flushing := rotateBatchUpdateJournal()
+	for i := 0; i < BatchUpdateTypeCount; i++ {
 		batchUpdateLocks[i].Unlock()
-		// TODO: maybe we can combine updates with same key?
+	}
+	// TODO: maybe we can combine updates with same key?
+	for i, store := range stores {
 		for key, value := range store {
-			switch i {
-			case BatchUpdateTypeUserQuota:
-				err := increaseUserQuota(key, value)
-				if err != nil {
-					logger.SysError("failed to batch update user quota: " + err.Error())
-				}
-			case BatchUpdateTypeTokenQuota:
-				err := increaseTokenQuota(key, value)
-				if err != nil {
-					logger.SysError("failed to batch update token quota: " + err.Error())
-				}
-			case BatchUpdateTypeUsedQuota:
-				updateUserUsedQuota(key, value)
-			case BatchUpdateTypeRequestCount:
-				updateUserRequestCount(key, int(value))
-			case BatchUpdateTypeChannelUsedQuota:
-				updateChannelUsedQuota(key, value)
+			applyBatchUpdate(i, key, value)
+			if flushing != nil {
+				_, _ = flushing.WriteString(fmt.Sprintf("done %d %d\n", i, key))
 			}
 		}
 	}
+	if flushing != nil {
+		_ = flushing.Close()
+		_ = os.Remove(batchUpdateJournalFlushing())
+	}
 	logger.SysLog("batch update finished")
 }
@@ -0,0 +1,300 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/vector"
+)
+
+const (
+	VectorStoreDatabase = "database"
+	VectorStorePgvector = "pgvector"
+	VectorStoreQdrant   = "qdrant"
+)
+
+// VectorBackend keeps the embeddings of the chunks, the chunks themselves are always rows of vector_chunks
+type VectorBackend interface {
+	Insert(chunks []*VectorChunk) error
+	Search(vectorStoreIds []string, embedding []float64, limit int) ([]*VectorChunk, error)
+	DeleteFile(vectorStoreId string, fileId string) error
+	DeleteStore(vectorStoreId string) error
+}
+
+func GetVectorBackend() VectorBackend {
+	switch config.VectorStore {
+	case VectorStorePgvector:
+		return pgvectorBackend{}
+	case VectorStoreQdrant:
+		return qdrantBackend{}
+	}
+	return databaseVectorBackend{}
+}
+
+func deleteVectorChunks(vectorStoreId string, fileId string) error {
+	query := DB.Where("vector_store_id = ?", vectorStoreId)
+	if fileId != "" {
+		query = query.Where("file_id = ?", fileId)
+	}
+	return query.Delete(&VectorChunk{}).Error
+}
+
+// databaseVectorBackend keeps the embeddings as JSON next to the chunks and compares all of them on search,
+// it needs nothing but the database and is meant for the small stores
+type databaseVectorBackend struct{}
+
+func (b databaseVectorBackend) Insert(chunks []*VectorChunk) error {
+	for _, chunk := range chunks {
+		chunk.Embedding = encodeEmbedding(chunk.Vector)
+	}
+	return DB.CreateInBatches(chunks, 100).Error
+}
+
+func (b databaseVectorBackend) Search(vectorStoreIds []string, embedding []float64, limit int) ([]*VectorChunk, error) {
+	var chunks []*VectorChunk
+	if err := DB.Where("vector_store_id in ?", vectorStoreIds).Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+	for _, chunk := range chunks {
+		var chunkEmbedding []float64
+		_ = json.Unmarshal([]byte(chunk.Embedding), &chunkEmbedding)
+		chunk.Score = vector.Cosine(embedding, chunkEmbedding)
+	}
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i].Score > chunks[j].Score
+	})
+	if len(chunks) > limit {
+		chunks = chunks[:limit]
+	}
+	return chunks, nil
+}
+
+func (b databaseVectorBackend) DeleteFile(vectorStoreId string, fileId string) error {
+	return deleteVectorChunks(vectorStoreId, fileId)
+}
+
+func (b databaseVectorBackend) DeleteStore(vectorStoreId string) error {
+	return deleteVectorChunks(vectorStoreId, "")
+}
+
+// pgvectorBackend keeps the embeddings in the embedding_vector column added to vector_chunks,
+// the column has no dimension, so the files have to be added again after the embedding model is changed
+type pgvectorBackend struct{}
+
+func migratePgvector() error {
+	if !common.UsingPostgreSQL {
+		return errors.New("pgvector vector store requires PostgreSQL")
+	}
+	if err := DB.Exec("CREATE EXTENSION IF NOT EXISTS vector").Error; err != nil {
+		return err
+	}
+	return DB.Exec("ALTER TABLE vector_chunks ADD COLUMN IF NOT EXISTS embedding_vector vector").Error
+}
+
+func (b pgvectorBackend) Insert(chunks []*VectorChunk) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(chunks, 100).Error; err != nil {
+			return err
+		}
+		for _, chunk := range chunks {
+			err := tx.Exec("UPDATE vector_chunks SET embedding_vector = ?::vector WHERE id = ?", encodeEmbedding(chunk.Vector), chunk.Id).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b pgvectorBackend) Search(vectorStoreIds []string, embedding []float64, limit int) ([]*VectorChunk, error) {
+	var rows []struct {
+		VectorChunk
+		Distance float64
+	}
+	value := encodeEmbedding(embedding)
+	err := DB.Raw("SELECT id, vector_store_id, file_id, content, embedding_vector <=> ?::vector AS distance FROM vector_chunks "+
+		"WHERE vector_store_id IN ? ORDER BY distance LIMIT ?", value, vectorStoreIds, limit).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([]*VectorChunk, 0, len(rows))
+	for i := range rows {
+		rows[i].VectorChunk.Score = 1 - rows[i].Distance
+		chunks = append(chunks, &rows[i].VectorChunk)
+	}
+	return chunks, nil
+}
+
+func (b pgvectorBackend) DeleteFile(vectorStoreId string, fileId string) error {
+	return deleteVectorChunks(vectorStoreId, fileId)
+}
+
+func (b pgvectorBackend) DeleteStore(vectorStoreId string) error {
+	return deleteVectorChunks(vectorStoreId, "")
+}
+
+// qdrantBackend keeps the embeddings in a qdrant collection, the points are the ids of the chunks
+type qdrantBackend struct{}
+
+var qdrantCollectionReady bool
+var qdrantCollectionLock sync.Mutex
+
+func (b qdrantBackend) do(method string, path string, request any, response any) error {
+	var body io.Reader
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	url := strings.TrimSuffix(config.QdrantURL, "/") + "/collections/" + config.QdrantCollection + path
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if config.QdrantAPIKey != "" {
+		req.Header.Set("api-key", config.QdrantAPIKey)
+	}
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("qdrant request failed with status code %d: %s", resp.StatusCode, string(data))
+	}
+	if response == nil {
+		return nil
+	}
+	return json.Unmarshal(data, response)
+}
+
+// ensureCollection creates the collection with the dimension of the first embedding inserted
+func (b qdrantBackend) ensureCollection(size int) error {
+	qdrantCollectionLock.Lock()
+	defer qdrantCollectionLock.Unlock()
+	if qdrantCollectionReady {
+		return nil
+	}
+	if b.do(http.MethodGet, "", nil, nil) != nil {
+		err := b.do(http.MethodPut, "", map[string]any{
+			"vectors": map[string]any{"size": size, "distance": "Cosine"},
+		}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	qdrantCollectionReady = true
+	return nil
+}
+
+func qdrantFilter(vectorStoreIds []string, fileId string) map[string]any {
+	must := []map[string]any{{
+		"key":   "vector_store_id",
+		"match": map[string]any{"any": vectorStoreIds},
+	}}
+	if fileId != "" {
+		must = append(must, map[string]any{
+			"key":   "file_id",
+			"match": map[string]any{"value": fileId},
+		})
+	}
+	return map[string]any{"must": must}
+}
+
+func (b qdrantBackend) Insert(chunks []*VectorChunk) error {
+	if err := b.ensureCollection(len(chunks[0].Vector)); err != nil {
+		return err
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(chunks, 100).Error; err != nil {
+			return err
+		}
+		points := make([]map[string]any, 0, len(chunks))
+		for _, chunk := range chunks {
+			points = append(points, map[string]any{
+				"id":     chunk.Id,
+				"vector": chunk.Vector,
+				"payload": map[string]any{
+					"vector_store_id": chunk.VectorStoreId,
+					"file_id":         chunk.FileId,
+				},
+			})
+		}
+		return b.do(http.MethodPut, "/points?wait=true", map[string]any{"points": points}, nil)
+	})
+}
+
+func (b qdrantBackend) Search(vectorStoreIds []string, embedding []float64, limit int) ([]*VectorChunk, error) {
+	var response struct {
+		Result []struct {
+			Id    int     `json:"id"`
+			Score float64 `json:"score"`
+		} `json:"result"`
+	}
+	err := b.do(http.MethodPost, "/points/search", map[string]any{
+		"vector": embedding,
+		"limit":  limit,
+		"filter": qdrantFilter(vectorStoreIds, ""),
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(response.Result))
+	for _, point := range response.Result {
+		ids = append(ids, point.Id)
+	}
+	var found []*VectorChunk
+	if err = DB.Where("id in ?", ids).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	chunksById := make(map[int]*VectorChunk, len(found))
+	for _, chunk := range found {
+		chunksById[chunk.Id] = chunk
+	}
+	chunks := make([]*VectorChunk, 0, len(found))
+	for _, point := range response.Result {
+		if chunk, ok := chunksById[point.Id]; ok {
+			chunk.Score = point.Score
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks, nil
+}
+
+func (b qdrantBackend) delete(vectorStoreId string, fileId string) error {
+	err := b.do(http.MethodPost, "/points/delete?wait=true", map[string]any{
+		"filter": qdrantFilter([]string{vectorStoreId}, fileId),
+	}, nil)
+	// nothing has been inserted yet if there is no collection
+	if err != nil && !strings.Contains(err.Error(), "status code 404") {
+		return err
+	}
+	return deleteVectorChunks(vectorStoreId, fileId)
+}
+
+func (b qdrantBackend) DeleteFile(vectorStoreId string, fileId string) error {
+	return b.delete(vectorStoreId, fileId)
+}
+
+func (b qdrantBackend) DeleteStore(vectorStoreId string) error {
+	return b.delete(vectorStoreId, "")
+}
@@ -0,0 +1,207 @@
+package model
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+// the vector stores serve the file_search tool of the assistants, the files added to them are split into chunks
+// and embedded, the chunks are kept in the database and their embeddings are searched by the VectorBackend
+
+const (
+	VectorStoreFileStatusInProgress = "in_progress"
+	VectorStoreFileStatusCompleted  = "completed"
+	VectorStoreFileStatusFailed     = "failed"
+)
+
+type VectorStore struct {
+	Id            int    `json:"id"`
+	VectorStoreId string `json:"vector_store_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	Name          string `json:"name"`
+	Metadata      string `json:"metadata" gorm:"type:text"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint"`
+}
+
+type VectorStoreFile struct {
+	Id            int    `json:"id"`
+	VectorStoreId string `json:"vector_store_id" gorm:"type:varchar(64);index"`
+	FileId        string `json:"file_id" gorm:"type:varchar(64);index"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	Status        string `json:"status" gorm:"type:varchar(32)"`
+	LastError     string `json:"last_error" gorm:"type:text"`
+	UsageBytes    int64  `json:"usage_bytes" gorm:"bigint"`
+	Chunks        int    `json:"chunks"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint"`
+}
+
+type VectorChunk struct {
+	Id            int    `json:"id"`
+	VectorStoreId string `json:"vector_store_id" gorm:"type:varchar(64);index"`
+	FileId        string `json:"file_id" gorm:"type:varchar(64);index"`
+	Content       string `json:"content" gorm:"type:text"`
+	// Embedding is the JSON of the embedding, it is only kept here by the database backend
+	Embedding string    `json:"-" gorm:"type:text"`
+	Vector    []float64 `json:"-" gorm:"-"`
+	Score     float64   `json:"score" gorm:"-"`
+}
+
+type VectorStoreFileCounts struct {
+	InProgress int64 `json:"in_progress"`
+	Completed  int64 `json:"completed"`
+	Failed     int64 `json:"failed"`
+	Total      int64 `json:"total"`
+}
+
+func (store *VectorStore) Insert() error {
+	store.VectorStoreId = NewObjectId("vs_")
+	store.CreatedAt = helper.GetTimestamp()
+	return DB.Create(store).Error
+}
+
+func (store *VectorStore) Update() error {
+	return DB.Model(store).Select("name", "metadata").Updates(store).Error
+}
+
+// Delete removes the vector store with its files and chunks, the uploaded files are kept
+func (store *VectorStore) Delete() error {
+	if err := GetVectorBackend().DeleteStore(store.VectorStoreId); err != nil {
+		return err
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("vector_store_id = ?", store.VectorStoreId).Delete(&VectorStoreFile{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(store).Error
+	})
+}
+
+func (store *VectorStore) FileCounts() VectorStoreFileCounts {
+	var counts VectorStoreFileCounts
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	DB.Model(&VectorStoreFile{}).Select("status, count(*) as count").
+		Where("vector_store_id = ?", store.VectorStoreId).Group("status").Scan(&rows)
+	for _, row := range rows {
+		switch row.Status {
+		case VectorStoreFileStatusInProgress:
+			counts.InProgress = row.Count
+		case VectorStoreFileStatusCompleted:
+			counts.Completed = row.Count
+		case VectorStoreFileStatusFailed:
+			counts.Failed = row.Count
+		}
+		counts.Total += row.Count
+	}
+	return counts
+}
+
+func (store *VectorStore) UsageBytes() int64 {
+	var bytes int64
+	DB.Model(&VectorStoreFile{}).Where("vector_store_id = ?", store.VectorStoreId).
+		Select(ifNullFunc() + "(sum(usage_bytes), 0)").Scan(&bytes)
+	return bytes
+}
+
+func GetUserVectorStore(userId int, vectorStoreId string) (*VectorStore, error) {
+	store := &VectorStore{}
+	err := DB.Where("user_id = ? and vector_store_id = ?", userId, vectorStoreId).First(store).Error
+	return store, err
+}
+
+func GetUserVectorStores(userId int, params ListParams) (stores []*VectorStore, err error) {
+	query := paginate(DB.Where("user_id = ?", userId), &VectorStore{}, "vector_store_id", params)
+	err = query.Find(&stores).Error
+	return stores, err
+}
+
+func (storeFile *VectorStoreFile) Insert() error {
+	storeFile.Status = VectorStoreFileStatusInProgress
+	storeFile.CreatedAt = helper.GetTimestamp()
+	return DB.Create(storeFile).Error
+}
+
+// Finish records the result of the ingestion of the file, it fails if the file has been removed in the meantime
+func (storeFile *VectorStoreFile) Finish() error {
+	result := DB.Model(&VectorStoreFile{}).Where("id = ?", storeFile.Id).
+		Select("status", "last_error", "usage_bytes", "chunks").Updates(storeFile)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete removes the file and its chunks from the vector store
+func (storeFile *VectorStoreFile) Delete() error {
+	if err := GetVectorBackend().DeleteFile(storeFile.VectorStoreId, storeFile.FileId); err != nil {
+		return err
+	}
+	return DB.Delete(storeFile).Error
+}
+
+func GetVectorStoreFile(vectorStoreId string, fileId string) (*VectorStoreFile, error) {
+	storeFile := &VectorStoreFile{}
+	err := DB.Where("vector_store_id = ? and file_id = ?", vectorStoreId, fileId).First(storeFile).Error
+	return storeFile, err
+}
+
+func GetVectorStoreFiles(vectorStoreId string, status string, params ListParams) (storeFiles []*VectorStoreFile, err error) {
+	query := DB.Where("vector_store_id = ?", vectorStoreId)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err = paginate(query, &VectorStoreFile{}, "file_id", params).Find(&storeFiles).Error
+	return storeFiles, err
+}
+
+// DeleteFileFromVectorStores removes the deleted file from all the vector stores it was added to
+func DeleteFileFromVectorStores(fileId string) error {
+	var storeFiles []*VectorStoreFile
+	if err := DB.Where("file_id = ?", fileId).Find(&storeFiles).Error; err != nil {
+		return err
+	}
+	for _, storeFile := range storeFiles {
+		if err := storeFile.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InsertVectorChunks keeps the chunks of the file with their embeddings in Vector
+func InsertVectorChunks(chunks []*VectorChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	return GetVectorBackend().Insert(chunks)
+}
+
+// SearchVectorChunks returns the limit chunks of the vector stores closest to the embedding, with their scores
+func SearchVectorChunks(vectorStoreIds []string, embedding []float64, limit int) ([]*VectorChunk, error) {
+	if len(vectorStoreIds) == 0 {
+		return nil, nil
+	}
+	return GetVectorBackend().Search(vectorStoreIds, embedding, limit)
+}
+
+func encodeEmbedding(embedding []float64) string {
+	data, _ := json.Marshal(embedding)
+	return string(data)
+}
+
+// migrateVectorStore prepares the backend after the tables are migrated
+func migrateVectorStore() error {
+	if config.VectorStore != VectorStorePgvector {
+		return nil
+	}
+	return migratePgvector()
+}
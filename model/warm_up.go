@@ -0,0 +1,57 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+)
+
+const (
+	defaultWakeWait        = 60 // seconds
+	defaultWakeIdleMinutes = 10
+)
+
+// WarmUp sends a request to the channel every Interval minutes, and calls WakeURL before a request to the channel
+// idle for IdleMinutes, until the backend answers or Wait seconds pass
+type WarmUp struct {
+	Interval    int    `json:"interval,omitempty"`
+	WakeURL     string `json:"wake_url,omitempty"`
+	Wait        int    `json:"wait,omitempty"`
+	IdleMinutes int    `json:"idle_minutes,omitempty"`
+}
+
+func (w *WarmUp) Validate() error {
+	if w.Interval < 0 || w.Wait < 0 || w.IdleMinutes < 0 {
+		return fmt.Errorf("warm-up settings must not be negative")
+	}
+	if w.WakeURL != "" {
+		u, err := url.Parse(w.WakeURL)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("wake url must be http or https")
+		}
+	}
+	return nil
+}
+
+func (w *WarmUp) GetWait() int {
+	if w.Wait == 0 {
+		return defaultWakeWait
+	}
+	return w.Wait
+}
+
+func (w *WarmUp) GetIdleMinutes() int {
+	if w.IdleMinutes == 0 {
+		return defaultWakeIdleMinutes
+	}
+	return w.IdleMinutes
+}
+
+// GetWarmUpChannels returns the enabled channels which may have warm-up settings
+func GetWarmUpChannels() ([]*Channel, error) {
+	var channels []*Channel
+	err := DB.Where("status = ? and config like ?", ChannelStatusEnabled, "%warm_up%").Find(&channels).Error
+	return channels, err
+}
@@ -27,37 +27,44 @@ func notifyRootUser(subject string, content string) {
 	}
 }
 
+func notifyChannelFailure(channelId int, channelName string, reason string) {
+	subject, content, err := message.RenderNotification(message.NotificationChannelFailure, map[string]any{
+		"ChannelId":   channelId,
+		"ChannelName": channelName,
+		"Reason":      reason,
+	})
+	if err != nil {
+		logger.SysError("failed to render notification: " + err.Error())
+		return
+	}
+	notifyRootUser(subject, content)
+	name := ""
+	if channelName != "" {
+		name = fmt.Sprintf("「%s」", channelName)
+	}
+	model.NotifyAdminBotChats(fmt.Sprintf("%s：渠道%s（#%d）已被禁用，原因：%s", subject, name, channelId, reason))
+}
+
 // DisableChannel disable & notify
 func DisableChannel(channelId int, channelName string, reason string) {
+	if model.IsChannelInMaintenance(channelId) {
+		logger.SysLog(fmt.Sprintf("channel #%d is in maintenance, not disabled: %s", channelId, reason))
+		return
+	}
 	model.UpdateChannelStatusById(channelId, model.ChannelStatusAutoDisabled)
 	logger.SysLog(fmt.Sprintf("channel #%d has been disabled: %s", channelId, reason))
-	subject := fmt.Sprintf("渠道状态变更提醒")
-	content := message.EmailTemplate(
-		subject,
-		fmt.Sprintf(`
-			<p>您好！</p>
-			<p>渠道「<strong>%s</strong>」（#%d）已被禁用。</p>
-			<p>禁用原因：</p>
-			<p style="background-color: #f8f8f8; padding: 10px; border-radius: 4px;">%s</p>
-		`, channelName, channelId, reason),
-	)
-	notifyRootUser(subject, content)
+	notifyChannelFailure(channelId, channelName, reason)
 }
 
 func MetricDisableChannel(channelId int, successRate float64) {
+	if model.IsChannelInMaintenance(channelId) {
+		logger.SysLog(fmt.Sprintf("channel #%d is in maintenance, not disabled due to low success rate: %.2f", channelId, successRate*100))
+		return
+	}
 	model.UpdateChannelStatusById(channelId, model.ChannelStatusAutoDisabled)
 	logger.SysLog(fmt.Sprintf("channel #%d has been disabled due to low success rate: %.2f", channelId, successRate*100))
-	subject := fmt.Sprintf("渠道状态变更提醒")
-	content := message.EmailTemplate(
-		subject,
-		fmt.Sprintf(`
-			<p>您好！</p>
-			<p>渠道 #%d 已被系统自动禁用。</p>
-			<p>禁用原因：</p>
-			<p style="background-color: #f8f8f8; padding: 10px; border-radius: 4px;">该渠道在最近 %d 次调用中成功率为 <strong>%.2f%%</strong>，低于系统阈值 <strong>%.2f%%</strong>。</p>
-		`, channelId, config.MetricQueueSize, successRate*100, config.MetricSuccessRateThreshold*100),
-	)
-	notifyRootUser(subject, content)
+	reason := fmt.Sprintf("该渠道在最近 %d 次调用中成功率为 %.2f%%，低于系统阈值 %.2f%%。", config.MetricQueueSize, successRate*100, config.MetricSuccessRateThreshold*100)
+	notifyChannelFailure(channelId, "", reason)
 }
 
 // EnableChannel enable & notify
@@ -0,0 +1,108 @@
+package monitor
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/message"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// the results of the key scan, a channel is disabled for the results other than valid, error and unsupported
+const (
+	KeyValid          = "valid"
+	KeyExpired        = "expired"
+	KeyQuotaExhausted = "quota_exhausted"
+	KeyRevoked        = "revoked"
+	KeyRegionBlocked  = "region_blocked"
+	KeyError          = "error"       // the upstream failed for another reason, which may not last
+	KeyUnsupported    = "unsupported" // the keys of the channel type cannot be checked
+)
+
+var keyResultNames = map[string]string{
+	KeyValid:          "有效",
+	KeyExpired:        "已过期",
+	KeyQuotaExhausted: "额度用尽",
+	KeyRevoked:        "已吊销",
+	KeyRegionBlocked:  "地区受限",
+	KeyError:          "请求失败",
+	KeyUnsupported:    "不支持检查",
+}
+
+func KeyResultName(result string) string {
+	if name, ok := keyResultNames[result]; ok {
+		return name
+	}
+	return result
+}
+
+// IsKeyInvalid tells the results for which the channel is disabled
+func IsKeyInvalid(result string) bool {
+	switch result {
+	case KeyExpired, KeyQuotaExhausted, KeyRevoked, KeyRegionBlocked:
+		return true
+	}
+	return false
+}
+
+func containsAny(s string, parts ...string) bool {
+	for _, part := range parts {
+		if strings.Contains(s, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassifyKeyFailure tells why the upstream refused the key from the status code and the body of its response
+func ClassifyKeyFailure(statusCode int, body string) string {
+	lowerBody := strings.ToLower(body)
+	switch {
+	case containsAny(lowerBody, "unsupported_country", "country, region, or territory", "location is not supported",
+		"not available in your region", "region is not supported", "unsupported_region"):
+		return KeyRegionBlocked
+	case containsAny(lowerBody, "expired"):
+		return KeyExpired
+	case statusCode == http.StatusPaymentRequired ||
+		containsAny(lowerBody, "insufficient_quota", "exceeded your current quota", "credit balance", "balance is too low",
+			"billing", "已欠费", "余额不足"):
+		return KeyQuotaExhausted
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden ||
+		containsAny(lowerBody, "invalid_api_key", "api key not valid", "api_key_invalid", "account_deactivated",
+			"revoked", "deactivated", "organization has been disabled", "your access was terminated"):
+		return KeyRevoked
+	}
+	return KeyError
+}
+
+// ReportChannelKeyScans sends the summary of the scans to the root user and the bot chats of the admins
+func ReportChannelKeyScans(scans []*model.ChannelKeyScan) {
+	counts := make(map[string]int)
+	var failures []string
+	for _, scan := range scans {
+		counts[scan.Result]++
+		if scan.Result != KeyValid && scan.Result != KeyUnsupported {
+			failures = append(failures, fmt.Sprintf("#%d %s：%s %s", scan.ChannelId, scan.ChannelName, KeyResultName(scan.Result), scan.Message))
+		}
+	}
+	subject, content, err := message.RenderNotification(message.NotificationKeyScanReport, map[string]any{
+		"Total":       len(scans),
+		"Valid":       counts[KeyValid],
+		"Invalid":     len(scans) - counts[KeyValid] - counts[KeyError] - counts[KeyUnsupported],
+		"Errors":      counts[KeyError],
+		"Unsupported": counts[KeyUnsupported],
+		"Failures":    failures,
+	})
+	if err != nil {
+		logger.SysError("failed to render notification: " + err.Error())
+		return
+	}
+	notifyRootUser(subject, content)
+	text := fmt.Sprintf("%s：共检查 %d 个渠道，有效 %d 个", subject, len(scans), counts[KeyValid])
+	for _, failure := range failures {
+		text += "\n" + failure
+	}
+	model.NotifyAdminBotChats(text)
+}
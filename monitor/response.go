@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/relay/conformance"
+)
+
+var responseWindowsLock sync.Mutex
+
+// responseWindows are the last checked responses of the channels, true for the malformed ones
+var responseWindows = make(map[int][]bool)
+
+// countResponse adds a checked response of the channel to its window, and returns how many of the window are
+// malformed once a malformed response makes the channel to be disabled, the window starts over then
+func countResponse(channelId int, malformed bool, window int, threshold float64) (int, bool) {
+	responseWindowsLock.Lock()
+	defer responseWindowsLock.Unlock()
+	results := append(responseWindows[channelId], malformed)
+	if len(results) > window {
+		results = results[len(results)-window:]
+	}
+	responseWindows[channelId] = results
+	if !malformed || threshold <= 0 || len(results) < window {
+		return 0, false
+	}
+	count := 0
+	for _, malformed := range results {
+		if malformed {
+			count++
+		}
+	}
+	if float64(count) < threshold*float64(window) {
+		return count, false
+	}
+	delete(responseWindows, channelId)
+	return count, true
+}
+
+// CountResponse counts a response of the channel checked by the relay, reason is why it is malformed, empty if it
+// is well-formed, the channel is quarantined once too many of its responses are malformed
+func CountResponse(channelId int, channelName string, reason string) {
+	window := config.MalformedResponseWindow
+	if window <= 0 {
+		return
+	}
+	count, quarantine := countResponse(channelId, reason != "", window, config.MalformedResponseThreshold)
+	if !quarantine {
+		return
+	}
+	DisableChannel(channelId, channelName, fmt.Sprintf("该渠道最近 %d 个响应中有 %d 个格式错误，最近一个为 %s",
+		window, count, ReasonName(reason)))
+}
+
+var reasonNames = map[string]string{
+	conformance.ReasonEmptyBody:     "响应为空",
+	conformance.ReasonInvalidJSON:   "JSON 无法解析",
+	conformance.ReasonInvalidEvent:  "流式事件无法解析",
+	conformance.ReasonEmptyChoices:  "choices 为空",
+	conformance.ReasonUnknownFinish: "未知的 finish_reason",
+	conformance.ReasonTruncated:     "流式响应被截断",
+}
+
+func ReasonName(reason string) string {
+	if name, ok := reasonNames[reason]; ok {
+		return name
+	}
+	return reason
+}
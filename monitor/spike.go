@@ -0,0 +1,66 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/sentry"
+)
+
+// errorWindow counts the upstream errors of a channel in the minute it started
+type errorWindow struct {
+	start    time.Time
+	count    int
+	reported bool
+}
+
+var errorWindowsLock sync.Mutex
+var errorWindows = make(map[int]*errorWindow)
+
+// countUpstreamError adds an error of the channel to its window, and tells whether it is the one which makes
+// a spike of the window, so that a spike is reported once a minute
+func countUpstreamError(channelId int, now time.Time, threshold int) (int, bool) {
+	errorWindowsLock.Lock()
+	defer errorWindowsLock.Unlock()
+	window, ok := errorWindows[channelId]
+	if !ok || now.Sub(window.start) >= time.Minute {
+		window = &errorWindow{start: now}
+		errorWindows[channelId] = window
+	}
+	window.count++
+	if window.reported || window.count < threshold {
+		return window.count, false
+	}
+	window.reported = true
+	return window.count, true
+}
+
+// ReportUpstreamError reports a spike of the upstream errors of the channel to Sentry, when it reaches
+// SentryErrorSpikeThreshold errors in a minute; only the rate limits and the server errors are counted,
+// the others are those of the requests
+func ReportUpstreamError(ctx context.Context, channelId int, channelName string, statusCode int, message string) {
+	threshold := config.SentryErrorSpikeThreshold
+	if !sentry.Enabled() || threshold <= 0 {
+		return
+	}
+	if statusCode != http.StatusTooManyRequests && statusCode < http.StatusInternalServerError {
+		return
+	}
+	count, spike := countUpstreamError(channelId, time.Now(), threshold)
+	if !spike {
+		return
+	}
+	event := sentry.NewEvent(ctx, sentry.LevelWarning, fmt.Sprintf("渠道「%s」（#%d）一分钟内上游出错 %d 次", channelName, channelId, count))
+	// the spikes of a channel are grouped into one issue, whatever the errors are
+	event.Fingerprint = []string{"upstream-error-spike", strconv.Itoa(channelId)}
+	event.Tags["channel_id"] = strconv.Itoa(channelId)
+	event.Tags["channel_name"] = channelName
+	event.Tags["status_code"] = strconv.Itoa(statusCode)
+	event.Extra["last_error"] = message
+	sentry.Capture(event)
+}
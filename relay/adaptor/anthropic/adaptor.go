@@ -28,6 +28,9 @@ func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Request, meta *me
 	adaptor.SetupCommonRequestHeader(c, req, meta)
 	req.Header.Set("x-api-key", meta.APIKey)
 	anthropicVersion := c.Request.Header.Get("anthropic-version")
+	if anthropicVersion == "" {
+		anthropicVersion = meta.Config.APIVersion
+	}
 	if anthropicVersion == "" {
 		anthropicVersion = "2023-06-01"
 	}
@@ -36,6 +36,28 @@ func stopReasonClaude2OpenAI(reason *string) string {
 	}
 }
 
+// minThinkingBudget is the least thinking budget accepted by Anthropic
+const minThinkingBudget = 1024
+
+// setThinking translates the thinking budget or the reasoning effort of the request, the answer gets max_tokens
+// besides the budget, temperature, top_p and top_k cannot be changed while thinking
+func setThinking(claudeRequest *Request, textRequest *model.GeneralOpenAIRequest) {
+	budget, ok := textRequest.ThinkingBudget()
+	if !ok || budget <= 0 {
+		return
+	}
+	if budget < minThinkingBudget {
+		budget = minThinkingBudget
+	}
+	claudeRequest.Thinking = &Thinking{Type: "enabled", BudgetTokens: budget}
+	if claudeRequest.MaxTokens <= budget {
+		claudeRequest.MaxTokens += budget
+	}
+	claudeRequest.Temperature = nil
+	claudeRequest.TopP = nil
+	claudeRequest.TopK = 0
+}
+
 func ConvertRequest(textRequest model.GeneralOpenAIRequest) *Request {
 	claudeTools := make([]Tool, 0, len(textRequest.Tools))
 
@@ -82,6 +104,7 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *Request {
 	if claudeRequest.MaxTokens == 0 {
 		claudeRequest.MaxTokens = 4096
 	}
+	setThinking(&claudeRequest, &textRequest)
 	// legacy model name mapping
 	if claudeRequest.Model == "claude-instant-1" {
 		claudeRequest.Model = "claude-instant-1.1"
@@ -149,6 +172,7 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *Request {
 func StreamResponseClaude2OpenAI(claudeResponse *StreamResponse) (*openai.ChatCompletionsStreamResponse, *Response) {
 	var response *Response
 	var responseText string
+	var reasoningText string
 	var stopReason string
 	tools := make([]model.Tool, 0)
 
@@ -158,6 +182,7 @@ func StreamResponseClaude2OpenAI(claudeResponse *StreamResponse) (*openai.ChatCo
 	case "content_block_start":
 		if claudeResponse.ContentBlock != nil {
 			responseText = claudeResponse.ContentBlock.Text
+			reasoningText = claudeResponse.ContentBlock.Thinking
 			if claudeResponse.ContentBlock.Type == "tool_use" {
 				tools = append(tools, model.Tool{
 					Id:   claudeResponse.ContentBlock.Id,
@@ -172,6 +197,7 @@ func StreamResponseClaude2OpenAI(claudeResponse *StreamResponse) (*openai.ChatCo
 	case "content_block_delta":
 		if claudeResponse.Delta != nil {
 			responseText = claudeResponse.Delta.Text
+			reasoningText = claudeResponse.Delta.Thinking
 			if claudeResponse.Delta.Type == "input_json_delta" {
 				tools = append(tools, model.Tool{
 					Function: model.Function{
@@ -192,6 +218,9 @@ func StreamResponseClaude2OpenAI(claudeResponse *StreamResponse) (*openai.ChatCo
 	}
 	var choice openai.ChatCompletionsStreamResponseChoice
 	choice.Delta.Content = responseText
+	if reasoningText != "" {
+		choice.Delta.ReasoningContent = reasoningText
+	}
 	if len(tools) > 0 {
 		choice.Delta.Content = nil // compatible with other OpenAI derivative applications, like LobeOpenAICompatibleFactory ...
 		choice.Delta.ToolCalls = tools
@@ -209,12 +238,15 @@ func StreamResponseClaude2OpenAI(claudeResponse *StreamResponse) (*openai.ChatCo
 
 func ResponseClaude2OpenAI(claudeResponse *Response) *openai.TextResponse {
 	var responseText string
-	if len(claudeResponse.Content) > 0 {
-		responseText = claudeResponse.Content[0].Text
-	}
+	var reasoningText string
 	tools := make([]model.Tool, 0)
 	for _, v := range claudeResponse.Content {
-		if v.Type == "tool_use" {
+		switch v.Type {
+		case "text":
+			responseText += v.Text
+		case "thinking":
+			reasoningText += v.Thinking
+		case "tool_use":
 			args, _ := json.Marshal(v.Input)
 			tools = append(tools, model.Tool{
 				Id:   v.Id,
@@ -236,6 +268,9 @@ func ResponseClaude2OpenAI(claudeResponse *Response) *openai.TextResponse {
 		},
 		FinishReason: stopReasonClaude2OpenAI(claudeResponse.StopReason),
 	}
+	if reasoningText != "" {
+		choice.Message.ReasoningContent = reasoningText
+	}
 	fullTextResponse := openai.TextResponse{
 		Id:      fmt.Sprintf("chatcmpl-%s", claudeResponse.Id),
 		Model:   claudeResponse.Model,
@@ -288,6 +323,9 @@ func StreamHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusC
 		if meta != nil {
 			usage.PromptTokens += meta.Usage.InputTokens
 			usage.CompletionTokens += meta.Usage.OutputTokens
+			if meta.Usage.CacheReadInputTokens > 0 {
+				usage.PromptTokensDetails = &model.PromptTokensDetails{CachedTokens: meta.Usage.CacheReadInputTokens}
+			}
 			if len(meta.Id) > 0 { // only message_start has an id, otherwise it's a finish_reason event.
 				modelName = meta.Model
 				id = fmt.Sprintf("chatcmpl-%s", meta.Id)
@@ -367,6 +405,9 @@ func Handler(c *gin.Context, resp *http.Response, promptTokens int, modelName st
 		CompletionTokens: claudeResponse.Usage.OutputTokens,
 		TotalTokens:      claudeResponse.Usage.InputTokens + claudeResponse.Usage.OutputTokens,
 	}
+	if claudeResponse.Usage.CacheReadInputTokens > 0 {
+		usage.PromptTokensDetails = &model.PromptTokensDetails{CachedTokens: claudeResponse.Usage.CacheReadInputTokens}
+	}
 	fullTextResponse.Usage = usage
 	jsonResponse, err := json.Marshal(fullTextResponse)
 	if err != nil {
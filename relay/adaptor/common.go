@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
 	"github.com/songquanpeng/one-api/relay/meta"
 	"io"
 	"net/http"
@@ -23,7 +25,7 @@ func DoRequestHelper(a Adaptor, c *gin.Context, meta *meta.Meta, requestBody io.
 	if err != nil {
 		return nil, fmt.Errorf("get request url failed: %w", err)
 	}
-	req, err := http.NewRequest(c.Request.Method, fullRequestURL, requestBody)
+	req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, fullRequestURL, requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("new request failed: %w", err)
 	}
@@ -39,7 +41,17 @@ func DoRequestHelper(a Adaptor, c *gin.Context, meta *meta.Meta, requestBody io.
 }
 
 func DoRequest(c *gin.Context, req *http.Request) (*http.Response, error) {
-	resp, err := client.HTTPClient.Do(req)
+	httpClient := client.HTTPClient
+	if c.GetBool(ctxkey.UpstreamTimeout) {
+		// the deadline of the relay profile of the model replaces the timeout of the client
+		httpClient = client.UntimedHTTPClient
+	}
+	if cfg, ok := c.Get(ctxkey.Config); ok {
+		if dial := cfg.(model.ChannelConfig).Dial; dial != nil {
+			httpClient = client.WithDial(httpClient, dial.Options())
+		}
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -5,6 +5,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/render"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
 	"github.com/songquanpeng/one-api/relay/constant"
 	"github.com/songquanpeng/one-api/relay/constant/finishreason"
@@ -89,11 +90,11 @@ func StreamHandler(c *gin.Context, resp *http.Response, modelName string) *model
 	common.SetEventStreamHeaders(c)
 	c.Stream(func(w io.Writer) bool {
 		if jsonData != nil {
-			c.Render(-1, common.CustomEvent{Data: "data: " + string(jsonData)})
+			render.StringData(c, string(jsonData))
 			jsonData = nil
 			return true
 		}
-		c.Render(-1, common.CustomEvent{Data: "data: [DONE]"})
+		render.Done(c)
 		return false
 	})
 	_ = resp.Body.Close()
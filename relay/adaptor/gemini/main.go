@@ -66,6 +66,9 @@ func ConvertRequest(textRequest model.GeneralOpenAIRequest) *ChatRequest {
 			MaxOutputTokens: textRequest.MaxTokens,
 		},
 	}
+	if budget, ok := textRequest.ThinkingBudget(); ok {
+		geminiRequest.GenerationConfig.ThinkingConfig = &ThinkingConfig{ThinkingBudget: &budget}
+	}
 	if textRequest.ResponseFormat != nil {
 		if mimeType, ok := mimeTypeMap[textRequest.ResponseFormat.Type]; ok {
 			geminiRequest.GenerationConfig.ResponseMimeType = mimeType
@@ -74,6 +74,12 @@ func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Request, meta *me
 		return nil
 	}
 	req.Header.Set("Authorization", "Bearer "+meta.APIKey)
+	if meta.OpenAIOrganization != "" {
+		req.Header.Set("OpenAI-Organization", meta.OpenAIOrganization)
+	}
+	if meta.OpenAIProject != "" {
+		req.Header.Set("OpenAI-Project", meta.OpenAIProject)
+	}
 	if meta.ChannelType == channeltype.OpenRouter {
 		req.Header.Set("HTTP-Referer", "https://github.com/songquanpeng/one-api")
 		req.Header.Set("X-Title", "One API")
@@ -92,6 +98,14 @@ func (a *Adaptor) ConvertRequest(c *gin.Context, relayMode int, request *model.G
 		}
 		request.StreamOptions.IncludeUsage = true
 	}
+	if request.Thinking != nil && (a.ChannelType == channeltype.OpenAI || a.ChannelType == channeltype.Azure) {
+		// OpenAI takes the effort only, the compatible providers may take the thinking as it is
+		if budget, ok := request.ThinkingBudget(); ok && request.ReasoningEffort == nil {
+			effort := model.BudgetToReasoningEffort(budget)
+			request.ReasoningEffort = &effort
+		}
+		request.Thinking = nil
+	}
 	return request, nil
 }
 
@@ -112,6 +126,8 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Met
 		err, responseText, usage = StreamHandler(c, resp, meta.Mode)
 		if usage == nil || usage.TotalTokens == 0 {
 			usage = ResponseText2Usage(responseText, meta.ActualModelName, meta.PromptTokens)
+		} else {
+			calibrateResponse(responseText, meta.ActualModelName, usage)
 		}
 		if usage.TotalTokens != 0 && usage.PromptTokens == 0 { // some channels don't return prompt tokens & completion tokens
 			usage.PromptTokens = meta.PromptTokens
@@ -0,0 +1,108 @@
+package openai
+
+import (
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/conv"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// the completion tokens of the responses without usage are estimated from the text of the deltas streamed,
+// counted with the tokenizer of the model, then corrected by how the counts of the model compared with the
+// usage reported by the upstreams for the responses which had it
+
+const (
+	// calibrationMinTokens are the reported tokens needed before the counts of a model are corrected
+	calibrationMinTokens = 1000
+	// the sums are halved past calibrationMaxTokens, so that the recent responses weigh the most
+	calibrationMaxTokens = 1000000
+	calibrationMinRatio  = 0.5
+	calibrationMaxRatio  = 2.0
+)
+
+type tokenCalibration struct {
+	counted  int64
+	reported int64
+}
+
+var tokenCalibrationsLock sync.Mutex
+var tokenCalibrations = make(map[string]*tokenCalibration)
+
+// CalibrateTokenCount records the completion tokens reported by the upstream for a response counted locally
+func CalibrateTokenCount(modelName string, counted int, reported int) {
+	if counted <= 0 || reported <= 0 {
+		return
+	}
+	tokenCalibrationsLock.Lock()
+	defer tokenCalibrationsLock.Unlock()
+	calibration, ok := tokenCalibrations[modelName]
+	if !ok {
+		calibration = &tokenCalibration{}
+		tokenCalibrations[modelName] = calibration
+	}
+	calibration.counted += int64(counted)
+	calibration.reported += int64(reported)
+	if calibration.reported > calibrationMaxTokens {
+		calibration.counted /= 2
+		calibration.reported /= 2
+	}
+}
+
+// getTokenCalibration returns the ratio of the reported tokens to the counted ones for the model, 1 until enough
+// responses of the model had their usage
+func getTokenCalibration(modelName string) float64 {
+	tokenCalibrationsLock.Lock()
+	defer tokenCalibrationsLock.Unlock()
+	calibration, ok := tokenCalibrations[modelName]
+	if !ok || calibration.reported < calibrationMinTokens || calibration.counted == 0 {
+		return 1
+	}
+	ratio := float64(calibration.reported) / float64(calibration.counted)
+	if ratio < calibrationMinRatio {
+		return calibrationMinRatio
+	}
+	if ratio > calibrationMaxRatio {
+		return calibrationMaxRatio
+	}
+	return ratio
+}
+
+// EstimateCompletionTokens counts the text generated by the model, corrected by the calibration of the model
+func EstimateCompletionTokens(text string, modelName string) int {
+	tokens := CountTokenText(text, modelName)
+	if tokens == 0 {
+		return 0
+	}
+	tokens = int(math.Round(float64(tokens) * getTokenCalibration(modelName)))
+	if tokens == 0 {
+		tokens = 1
+	}
+	return tokens
+}
+
+// MessageCompletionText is the text of a message or of a stream delta which makes completion tokens: the content,
+// the reasoning and the tool calls
+func MessageCompletionText(message *model.Message) string {
+	var builder strings.Builder
+	builder.WriteString(message.StringContent())
+	builder.WriteString(conv.AsString(message.ReasoningContent))
+	for _, tool := range message.ToolCalls {
+		builder.WriteString(tool.Function.Name)
+		builder.WriteString(conv.AsString(tool.Function.Arguments))
+	}
+	return builder.String()
+}
+
+// calibrateResponse compares the usage reported for a response with the count of its text
+func calibrateResponse(text string, modelName string, usage *model.Usage) {
+	if usage == nil || usage.CompletionTokens == 0 {
+		return
+	}
+	if usage.CompletionTokensDetails != nil && usage.CompletionTokensDetails.ReasoningTokens > 0 {
+		// the reasoning of the model is billed but not sent
+		return
+	}
+	CalibrateTokenCount(modelName, CountTokenText(text, modelName), usage.CompletionTokens)
+}
@@ -2,10 +2,16 @@ package openai
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/storage"
 	"github.com/songquanpeng/one-api/relay/model"
 	"io"
+	"mime"
 	"net/http"
 )
 
@@ -25,6 +31,14 @@ func ImageHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusCo
 		return ErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
 	}
 
+	if storage.Enabled() && !isB64JsonRequested(c) && uploadImages(c.Request.Context(), &imageResponse) {
+		responseBody, err = json.Marshal(imageResponse)
+		if err != nil {
+			return ErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError), nil
+		}
+		resp.Header.Del("Content-Length")
+	}
+
 	resp.Body = io.NopCloser(bytes.NewBuffer(responseBody))
 
 	for k, v := range resp.Header {
@@ -42,3 +56,41 @@ func ImageHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusCo
 	}
 	return nil, nil
 }
+
+func isB64JsonRequested(c *gin.Context) bool {
+	imageRequest := model.ImageRequest{}
+	if err := common.UnmarshalBodyReusable(c, &imageRequest); err != nil {
+		return false
+	}
+	return imageRequest.ResponseFormat == "b64_json"
+}
+
+// uploadImages replaces the base64 images with URLs of the object storage, it reports whether any image is replaced
+func uploadImages(ctx context.Context, imageResponse *ImageResponse) bool {
+	replaced := false
+	for i := range imageResponse.Data {
+		data := &imageResponse.Data[i]
+		if data.B64Json == "" {
+			continue
+		}
+		image, err := base64.StdEncoding.DecodeString(data.B64Json)
+		if err != nil {
+			logger.Errorf(ctx, "failed to decode base64 image: %s", err.Error())
+			continue
+		}
+		contentType := http.DetectContentType(image)
+		ext := ".png"
+		if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+			ext = exts[0]
+		}
+		url, err := storage.Upload(ctx, storage.NewObjectKey(ext), image, contentType)
+		if err != nil {
+			logger.Errorf(ctx, "failed to upload image to object storage: %s", err.Error())
+			continue
+		}
+		data.Url = url
+		data.B64Json = ""
+		replaced = true
+	}
+	return replaced
+}
@@ -3,7 +3,9 @@ package openai
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
@@ -13,6 +15,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/conv"
+	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/relay/model"
 	"github.com/songquanpeng/one-api/relay/relaymode"
@@ -24,8 +27,11 @@ const (
 	dataPrefixLength = len(dataPrefix)
 )
 
+// StreamHandler returns the text of all the deltas streamed, the tool calls and the reasoning included, to count
+// the completion tokens when the upstream does not send the usage
 func StreamHandler(c *gin.Context, resp *http.Response, relayMode int) (*model.ErrorWithStatusCode, string, *model.Usage) {
 	responseText := ""
+	var completionText strings.Builder
 	scanner := bufio.NewScanner(resp.Body)
 	scanner.Split(bufio.ScanLines)
 	var usage *model.Usage
@@ -33,6 +39,7 @@ func StreamHandler(c *gin.Context, resp *http.Response, relayMode int) (*model.E
 	common.SetEventStreamHeaders(c)
 
 	doneRendered := false
+	finished := false
 	for scanner.Scan() {
 		data := scanner.Text()
 		if len(data) < dataPrefixLength { // ignore blank line or wrong format
@@ -62,6 +69,13 @@ func StreamHandler(c *gin.Context, resp *http.Response, relayMode int) (*model.E
 			render.StringData(c, data)
 			for _, choice := range streamResponse.Choices {
 				responseText += conv.AsString(choice.Delta.Content)
+				if text := MessageCompletionText(&choice.Delta); text != "" {
+					completionText.WriteString(text)
+					c.Set(ctxkey.StreamContentSent, true)
+				}
+				if choice.FinishReason != nil && *choice.FinishReason != "" {
+					finished = true
+				}
 			}
 			if streamResponse.Usage != nil {
 				usage = streamResponse.Usage
@@ -76,12 +90,29 @@ func StreamHandler(c *gin.Context, resp *http.Response, relayMode int) (*model.E
 			}
 			for _, choice := range streamResponse.Choices {
 				responseText += choice.Text
+				completionText.WriteString(choice.Text)
+				if choice.Text != "" {
+					c.Set(ctxkey.StreamContentSent, true)
+				}
+				if choice.FinishReason != "" {
+					finished = true
+				}
 			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
 		logger.SysError("error reading stream: " + err.Error())
+		if errors.Is(err, context.DeadlineExceeded) {
+			// the generated part is still billed by the caller, tell the client why the stream ended
+			_ = render.ObjectData(c, gin.H{"error": ErrorWrapper(err, "request_timeout", http.StatusGatewayTimeout).Error})
+		}
+	}
+
+	if !doneRendered && !finished && c.Request.Context().Err() == nil {
+		// the upstream dropped the stream, the relay may continue it on another channel
+		c.Set(ctxkey.StreamInterrupted, true)
+		c.Set(ctxkey.StreamPartialText, responseText)
 	}
 
 	if !doneRendered {
@@ -93,7 +124,7 @@ func StreamHandler(c *gin.Context, resp *http.Response, relayMode int) (*model.E
 		return ErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), "", nil
 	}
 
-	return nil, responseText, usage
+	return nil, completionText.String(), usage
 }
 
 func Handler(c *gin.Context, resp *http.Response, promptTokens int, modelName string) (*model.ErrorWithStatusCode, *model.Usage) {
@@ -136,16 +167,19 @@ func Handler(c *gin.Context, resp *http.Response, promptTokens int, modelName st
 		return ErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
 	}
 
+	var completionText strings.Builder
+	for _, choice := range textResponse.Choices {
+		completionText.WriteString(MessageCompletionText(&choice.Message))
+	}
 	if textResponse.Usage.TotalTokens == 0 || (textResponse.Usage.PromptTokens == 0 && textResponse.Usage.CompletionTokens == 0) {
-		completionTokens := 0
-		for _, choice := range textResponse.Choices {
-			completionTokens += CountTokenText(choice.Message.StringContent(), modelName)
-		}
+		completionTokens := EstimateCompletionTokens(completionText.String(), modelName)
 		textResponse.Usage = model.Usage{
 			PromptTokens:     promptTokens,
 			CompletionTokens: completionTokens,
 			TotalTokens:      promptTokens + completionTokens,
 		}
+	} else {
+		calibrateResponse(completionText.String(), modelName, &textResponse.Usage)
 	}
 	return nil, &textResponse.Usage
 }
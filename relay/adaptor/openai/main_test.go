@@ -0,0 +1,56 @@
+package openai_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/filter"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// streamBody is an upstream stream of the given deltas, ended by the usage and [DONE]
+func streamBody(deltas int) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < deltas; i++ {
+		fmt.Fprintf(&buf, "data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"word%d \"},\"finish_reason\":null}]}\n\n", i)
+	}
+	buf.WriteString("data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+	buf.WriteString("data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"gpt-4o\",\"choices\":[],\"usage\":{\"prompt_tokens\":10,\"completion_tokens\":500,\"total_tokens\":510}}\n\n")
+	buf.WriteString("data: [DONE]\n\n")
+	return buf.Bytes()
+}
+
+// BenchmarkStreamHandler relays a stream of 500 deltas to the client, as is and with a response filter
+func BenchmarkStreamHandler(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+	body := streamBody(500)
+	rules := []filter.Rule{{Type: filter.TypeRemove, Pattern: `word1\d `}}
+	for _, filtered := range []bool{false, true} {
+		b.Run(fmt.Sprintf("filtered=%t", filtered), func(b *testing.B) {
+			b.SetBytes(int64(len(body)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				c, _ := gin.CreateTestContext(httptest.NewRecorder())
+				c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+				if filtered {
+					c.Set(ctxkey.StreamFilter, filter.NewChunkFilter(func() *filter.Filter {
+						f, _ := filter.Compile(rules)
+						return f
+					}))
+				}
+				resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body))}
+				if err, _, usage := openai.StreamHandler(c, resp, relaymode.ChatCompletions); err != nil || usage == nil {
+					b.Fatalf("stream not relayed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -16,4 +16,5 @@ type Request struct {
 	TopK          int                 `json:"top_k,omitempty"`
 	Tools         []anthropic.Tool    `json:"tools,omitempty"`
 	ToolChoice    any                 `json:"tool_choice,omitempty"`
+	Thinking      *anthropic.Thinking `json:"thinking,omitempty"`
 }
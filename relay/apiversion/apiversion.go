@@ -0,0 +1,205 @@
+// Package apiversion knows the versions of the upstream APIs selected by the channels, such as the api-version
+// of Azure or the anthropic-version header, and picks a supported one when the configured version is deprecated
+// or rejected by the upstream
+package apiversion
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/songquanpeng/one-api/relay/channeltype"
+)
+
+type Version struct {
+	Name       string `json:"name"`
+	Deprecated bool   `json:"deprecated,omitempty"`
+	Preview    bool   `json:"preview,omitempty"`
+	Note       string `json:"note,omitempty"`
+}
+
+// Catalog is the versions of the API of a channel type, the oldest first
+type Catalog struct {
+	Parameter string    `json:"parameter"`
+	Default   string    `json:"default,omitempty"`
+	Versions  []Version `json:"versions"`
+}
+
+var catalogs = map[int]*Catalog{
+	channeltype.Azure: {
+		Parameter: "api-version",
+		Default:   "2024-10-21",
+		Versions: []Version{
+			{Name: "2022-12-01", Deprecated: true},
+			{Name: "2023-03-15-preview", Deprecated: true, Preview: true},
+			{Name: "2023-05-15", Deprecated: true},
+			{Name: "2023-06-01-preview", Deprecated: true, Preview: true},
+			{Name: "2023-07-01-preview", Deprecated: true, Preview: true},
+			{Name: "2023-08-01-preview", Deprecated: true, Preview: true},
+			{Name: "2023-09-01-preview", Deprecated: true, Preview: true},
+			{Name: "2023-12-01-preview", Deprecated: true, Preview: true},
+			{Name: "2024-02-01"},
+			{Name: "2024-02-15-preview", Deprecated: true, Preview: true},
+			{Name: "2024-03-01-preview", Deprecated: true, Preview: true},
+			{Name: "2024-04-01-preview", Deprecated: true, Preview: true},
+			{Name: "2024-05-01-preview", Preview: true},
+			{Name: "2024-06-01"},
+			{Name: "2024-07-01-preview", Preview: true},
+			{Name: "2024-08-01-preview", Preview: true, Note: "structured outputs"},
+			{Name: "2024-09-01-preview", Preview: true, Note: "o1 models"},
+			{Name: "2024-10-01-preview", Preview: true},
+			{Name: "2024-10-21", Note: "structured outputs, batch"},
+			{Name: "2024-12-01-preview", Preview: true, Note: "o1 reasoning effort"},
+			{Name: "2025-01-01-preview", Preview: true, Note: "o3-mini, audio"},
+		},
+	},
+	channeltype.Anthropic: {
+		Parameter: "anthropic-version",
+		Default:   "2023-06-01",
+		Versions: []Version{
+			{Name: "2023-01-01", Deprecated: true},
+			{Name: "2023-06-01", Note: "streaming events"},
+		},
+	},
+	channeltype.Gemini: {
+		Parameter: "version",
+		Versions: []Version{
+			{Name: "v1"},
+			{Name: "v1beta", Preview: true, Note: "system instructions, tools"},
+		},
+	},
+}
+
+// rejected is the versions rejected by the upstream of each channel, learned from the errors of the requests
+var rejected = make(map[int]map[string]bool)
+var rejectedLock sync.RWMutex
+
+// GetCatalog returns the versions known for the channel type, nil if the type does not select a version
+func GetCatalog(channelType int) *Catalog {
+	return catalogs[channelType]
+}
+
+// GetCatalogs returns the versions known for every channel type
+func GetCatalogs() map[int]*Catalog {
+	return catalogs
+}
+
+// Default returns the version used when the channel does not configure one, empty if the adaptor picks it itself
+func Default(channelType int) string {
+	if catalog, ok := catalogs[channelType]; ok {
+		return catalog.Default
+	}
+	return ""
+}
+
+func (catalog *Catalog) find(name string) *Version {
+	for i := range catalog.Versions {
+		if catalog.Versions[i].Name == name {
+			return &catalog.Versions[i]
+		}
+	}
+	return nil
+}
+
+// MarkUnsupported remembers that the upstream of the channel rejected the version
+func MarkUnsupported(channelId int, version string) {
+	if version == "" {
+		return
+	}
+	rejectedLock.Lock()
+	defer rejectedLock.Unlock()
+	if rejected[channelId] == nil {
+		rejected[channelId] = make(map[string]bool)
+	}
+	rejected[channelId][version] = true
+}
+
+// ResetUnsupported forgets the versions rejected by the upstream of the channel, when its config is changed
+func ResetUnsupported(channelId int) {
+	rejectedLock.Lock()
+	defer rejectedLock.Unlock()
+	delete(rejected, channelId)
+}
+
+func isRejected(channelId int, version string) bool {
+	rejectedLock.RLock()
+	defer rejectedLock.RUnlock()
+	return rejected[channelId][version]
+}
+
+// GetRejected returns the versions rejected by the upstream of each channel
+func GetRejected() map[int][]string {
+	rejectedLock.RLock()
+	defer rejectedLock.RUnlock()
+	result := make(map[int][]string, len(rejected))
+	for channelId, versions := range rejected {
+		for version := range versions {
+			result[channelId] = append(result[channelId], version)
+		}
+	}
+	return result
+}
+
+// Resolve returns the version to send to the upstream of the channel. An empty version is the default of the
+// channel type, when upgrade is set a deprecated version or a version rejected by the upstream is replaced by the
+// newest stable version not rejected, the version is kept if there is none
+func Resolve(channelId int, channelType int, configured string, upgrade bool) string {
+	catalog, ok := catalogs[channelType]
+	if !ok {
+		return configured
+	}
+	version := configured
+	if version == "" {
+		version = catalog.Default
+	}
+	if !upgrade || version == "" {
+		return version
+	}
+	known := catalog.find(version)
+	if !isRejected(channelId, version) && (known == nil || !known.Deprecated) {
+		return version
+	}
+	for i := len(catalog.Versions) - 1; i >= 0; i-- {
+		candidate := catalog.Versions[i]
+		if candidate.Deprecated || candidate.Preview || isRejected(channelId, candidate.Name) {
+			continue
+		}
+		return candidate.Name
+	}
+	return version
+}
+
+// Check returns the warnings about the version configured for the channel type, none if it is fine
+func Check(channelType int, version string) []string {
+	catalog, ok := catalogs[channelType]
+	if !ok || version == "" {
+		return nil
+	}
+	known := catalog.find(version)
+	if known == nil {
+		return []string{fmt.Sprintf("%s %s 不是已知的版本，请确认上游支持该版本", catalog.Parameter, version)}
+	}
+	if known.Deprecated {
+		return []string{fmt.Sprintf("%s %s 已弃用，建议升级到 %s", catalog.Parameter, version, Resolve(0, channelType, version, true))}
+	}
+	return nil
+}
+
+// IsVersionError tells whether the upstream rejected the request because of its API version
+func IsVersionError(statusCode int, message string) bool {
+	if statusCode != http.StatusBadRequest && statusCode != http.StatusNotFound {
+		return false
+	}
+	message = strings.ToLower(message)
+	if !strings.Contains(message, "api-version") && !strings.Contains(message, "api version") &&
+		!strings.Contains(message, "anthropic-version") {
+		return false
+	}
+	for _, reason := range []string{"unsupported", "not supported", "invalid", "deprecated", "not found"} {
+		if strings.Contains(message, reason) {
+			return true
+		}
+	}
+	return false
+}
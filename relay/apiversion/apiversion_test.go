@@ -0,0 +1,52 @@
+package apiversion
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/relay/channeltype"
+)
+
+func TestResolve(t *testing.T) {
+	Convey("Resolve", t, func() {
+		Convey("the default is used when no version is configured", func() {
+			So(Resolve(1, channeltype.Azure, "", false), ShouldEqual, "2024-10-21")
+			So(Resolve(1, channeltype.Gemini, "", true), ShouldEqual, "")
+		})
+		Convey("a deprecated version is kept unless upgraded", func() {
+			So(Resolve(1, channeltype.Azure, "2023-05-15", false), ShouldEqual, "2023-05-15")
+			So(Resolve(1, channeltype.Azure, "2023-05-15", true), ShouldEqual, "2024-10-21")
+			So(Resolve(1, channeltype.Anthropic, "2023-01-01", true), ShouldEqual, "2023-06-01")
+		})
+		Convey("a version rejected by the upstream is replaced", func() {
+			MarkUnsupported(2, "2024-10-21")
+			So(Resolve(2, channeltype.Azure, "", true), ShouldEqual, "2024-06-01")
+			So(Resolve(3, channeltype.Azure, "", true), ShouldEqual, "2024-10-21")
+			ResetUnsupported(2)
+			So(Resolve(2, channeltype.Azure, "", true), ShouldEqual, "2024-10-21")
+		})
+		Convey("the other channel types are unchanged", func() {
+			So(Resolve(1, channeltype.OpenAI, "v2", true), ShouldEqual, "v2")
+		})
+	})
+}
+
+func TestCheck(t *testing.T) {
+	Convey("Check", t, func() {
+		So(Check(channeltype.Azure, "2024-06-01"), ShouldBeEmpty)
+		So(Check(channeltype.Azure, "2023-05-15")[0], ShouldContainSubstring, "2024-10-21")
+		So(Check(channeltype.Azure, "2030-01-01"), ShouldHaveLength, 1)
+		So(Check(channeltype.OpenAI, "2023-05-15"), ShouldBeEmpty)
+	})
+}
+
+func TestIsVersionError(t *testing.T) {
+	Convey("IsVersionError", t, func() {
+		So(IsVersionError(http.StatusNotFound, "Unsupported api-version. The supported versions are..."), ShouldBeTrue)
+		So(IsVersionError(http.StatusBadRequest, "anthropic-version: invalid version 2022-01-01"), ShouldBeTrue)
+		So(IsVersionError(http.StatusBadRequest, "invalid model"), ShouldBeFalse)
+		So(IsVersionError(http.StatusInternalServerError, "unsupported api-version"), ShouldBeFalse)
+	})
+}
@@ -4,27 +4,35 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/plugin"
 )
 
+// ReturnPreConsumedQuota refunds the quota pre-consumed for a request which failed
 func ReturnPreConsumedQuota(ctx context.Context, preConsumedQuota int64, tokenId int) {
-	if preConsumedQuota != 0 {
-		go func(ctx context.Context) {
-			// return pre-consumed quota
-			err := model.PostConsumeTokenQuota(tokenId, -preConsumedQuota)
+	if preConsumedQuota > 0 {
+		note := "请求 " + helper.GetRequestID(ctx) + " 失败"
+		Go(func() {
+			err := model.RefundTokenQuota(tokenId, preConsumedQuota, note)
 			if err != nil {
 				logger.Error(ctx, "error return pre-consumed quota: "+err.Error())
 			}
-		}(ctx)
+		})
 	}
 }
 
-func PostConsumeQuota(ctx context.Context, tokenId int, quotaDelta int64, totalQuota int64, userId int, channelId int, modelRatio float64, groupRatio float64, modelName string, tokenName string) {
+func PostConsumeQuota(ctx context.Context, tokenId int, quotaDelta int64, totalQuota int64, userId int, channelId int, modelRatio float64, groupRatio float64, modelName string, tokenName string, upstreamRequestId string, upstreamAccount string) {
 	// quotaDelta is remaining quota to be consumed
+	quota, priceNote, creditMarkup := model.ApplyPriceAdjustments(userId, modelName, totalQuota)
+	quotaDelta -= totalQuota - quota
+	totalQuota = quota
 	err := model.PostConsumeTokenQuota(tokenId, quotaDelta)
 	if err != nil {
 		logger.SysError("error consuming token remain quota: " + err.Error())
+	} else {
+		creditMarkup()
 	}
 	err = model.CacheUpdateUserQuota(ctx, userId)
 	if err != nil {
@@ -33,16 +41,23 @@ func PostConsumeQuota(ctx context.Context, tokenId int, quotaDelta int64, totalQ
 	// totalQuota is total quota consumed
 	if totalQuota != 0 {
 		logContent := fmt.Sprintf("倍率：%.2f × %.2f", modelRatio, groupRatio)
-		model.RecordConsumeLog(ctx, &model.Log{
-			UserId:           userId,
-			ChannelId:        channelId,
-			PromptTokens:     int(totalQuota),
-			CompletionTokens: 0,
-			ModelName:        modelName,
-			TokenName:        tokenName,
-			Quota:            int(totalQuota),
-			Content:          logContent,
-		})
+		if priceNote != "" {
+			logContent += "，" + priceNote
+		}
+		consumeLog := &model.Log{
+			UserId:            userId,
+			ChannelId:         channelId,
+			PromptTokens:      int(totalQuota),
+			CompletionTokens:  0,
+			ModelName:         modelName,
+			TokenName:         tokenName,
+			Quota:             int(totalQuota),
+			Content:           logContent,
+			UpstreamRequestId: upstreamRequestId,
+			UpstreamAccount:   upstreamAccount,
+		}
+		model.RecordConsumeLog(ctx, consumeLog)
+		plugin.OnBilling(ctx, consumeLog)
 		model.UpdateUserUsedQuotaAndRequestCount(userId, totalQuota)
 		model.UpdateChannelUsedQuota(channelId, totalQuota)
 	}
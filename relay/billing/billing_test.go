@@ -0,0 +1,88 @@
+package billing_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/billing"
+)
+
+var dbDir string
+var user *model.User
+var token *model.Token
+
+// TestMain removes the database of the benchmarks, which is only created when they run
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if dbDir != "" {
+		_ = model.CloseDB()
+		_ = os.RemoveAll(dbDir)
+	}
+	os.Exit(code)
+}
+
+// setupBillingDB opens a fresh SQLite database with a user and a token of a large quota,
+// the benchmarks never touch the database of SQL_DSN
+func setupBillingDB(b *testing.B) {
+	if dbDir != "" {
+		return
+	}
+	b.Setenv("SQL_DSN", "")
+	b.Setenv("LOG_SQL_DSN", "")
+	var err error
+	if dbDir, err = os.MkdirTemp("", "one-api-bench-"); err != nil {
+		b.Fatal(err)
+	}
+	common.SQLitePath = filepath.Join(dbDir, "one-api.db")
+	common.RedisEnabled = false
+	model.InitDB()
+	model.InitLogDB()
+	const quota = int64(1) << 50
+	user = &model.User{Username: "bench", Password: "12345678", DisplayName: "bench"}
+	if err = user.Insert(context.Background(), 0); err != nil {
+		b.Fatal(err)
+	}
+	if err = model.DB.Model(user).Update("quota", quota).Error; err != nil {
+		b.Fatal(err)
+	}
+	token = &model.Token{UserId: user.Id, Name: "bench", Key: "bench", Status: model.TokenStatusEnabled, ExpiredTime: -1, RemainQuota: quota}
+	if err = token.Insert(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// BenchmarkBilling pre-consumes and settles the quota of concurrent requests of one token, as the relay does,
+// and checks that no quota is lost
+func BenchmarkBilling(b *testing.B) {
+	setupBillingDB(b)
+	initialQuota, err := model.GetUserQuota(user.Id)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var requests int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+		for pb.Next() {
+			if err := model.PreConsumeTokenQuota(token.Id, 100); err != nil {
+				b.Error(err)
+				return
+			}
+			billing.PostConsumeQuota(ctx, token.Id, 50, 150, user.Id, 0, 1, 1, "gpt-4o-mini", token.Name, "", "")
+			atomic.AddInt64(&requests, 1)
+		}
+	})
+	b.StopTimer()
+	quota, err := model.GetUserQuota(user.Id)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if want := initialQuota - 150*requests; quota != want {
+		b.Fatalf("user quota is %d after %d requests, want %d", quota, requests, want)
+	}
+}
@@ -0,0 +1,97 @@
+package billing
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	OverflowPolicyBlock = "block" // wait until there is room in the queue
+	OverflowPolicySpawn = "spawn" // run the task in a new goroutine
+	OverflowPolicyDrop  = "drop"  // discard the task
+)
+
+var tasks = make(chan func(), config.BillingQueueSize)
+var droppedTasks atomic.Int64
+var pendingTasks sync.WaitGroup
+
+func init() {
+	for i := 0; i < config.BillingWorkerNum; i++ {
+		go worker()
+	}
+}
+
+func worker() {
+	for task := range tasks {
+		task()
+	}
+}
+
+// Go hands quota settlement and consume log insertion over to the worker pool,
+// so that the response to the client is not delayed by database writes
+func Go(task func()) {
+	pendingTasks.Add(1)
+	task = track(task)
+	select {
+	case tasks <- task:
+		return
+	default:
+	}
+	switch config.BillingQueueOverflowPolicy {
+	case OverflowPolicyBlock:
+		tasks <- task
+	case OverflowPolicyDrop:
+		droppedTasks.Add(1)
+		pendingTasks.Done()
+		logger.SysError("billing queue is full, task dropped")
+	default:
+		go task()
+	}
+}
+
+// track counts the task as pending until it is finished, a task which panics is logged rather than
+// taking the worker and the server down with it
+func track(task func()) func() {
+	return func() {
+		defer pendingTasks.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				logger.SysErrorf("billing task panicked: %v\n%s", err, debug.Stack())
+			}
+		}()
+		task()
+	}
+}
+
+// Drain waits until all submitted tasks are finished or ctx is done,
+// it should be called after the HTTP server stopped accepting requests
+func Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		pendingTasks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func QueueDepth() int {
+	return len(tasks)
+}
+
+func QueueCapacity() int {
+	return cap(tasks)
+}
+
+func DroppedTasks() int64 {
+	return droppedTasks.Load()
+}
@@ -0,0 +1,30 @@
+package billing_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/relay/billing"
+)
+
+func TestGo(t *testing.T) {
+	Convey("a task which panics does not stop the workers", t, func() {
+		var done atomic.Int64
+		billing.Go(func() {
+			panic("settlement failed")
+		})
+		for i := 0; i < 10; i++ {
+			billing.Go(func() {
+				done.Add(1)
+			})
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		So(billing.Drain(ctx), ShouldBeNil)
+		So(done.Load(), ShouldEqual, 10)
+	})
+}
@@ -0,0 +1,81 @@
+package ratio
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+var exchangeRatesLock sync.RWMutex
+
+// ExchangeRates are the units of a currency for one unit of the base currency, the users can display the quota
+// in the base currency or in one of these
+var ExchangeRates = map[string]float64{
+	"CNY": 7.2,
+	"EUR": 0.92,
+}
+
+func ExchangeRates2JSONString() string {
+	exchangeRatesLock.RLock()
+	defer exchangeRatesLock.RUnlock()
+	jsonBytes, err := json.Marshal(ExchangeRates)
+	if err != nil {
+		logger.SysError("error marshalling exchange rates: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateExchangeRatesByJSONString(jsonStr string) error {
+	rates := make(map[string]float64)
+	if err := json.Unmarshal([]byte(jsonStr), &rates); err != nil {
+		return err
+	}
+	exchangeRates := make(map[string]float64, len(rates))
+	for currency, rate := range rates {
+		if rate <= 0 {
+			return fmt.Errorf("the exchange rate of %s is not positive", currency)
+		}
+		exchangeRates[strings.ToUpper(currency)] = rate
+	}
+	exchangeRatesLock.Lock()
+	defer exchangeRatesLock.Unlock()
+	ExchangeRates = exchangeRates
+	return nil
+}
+
+// GetExchangeRate returns the units of the currency for one unit of the base currency
+func GetExchangeRate(currency string) (float64, bool) {
+	currency = strings.ToUpper(currency)
+	if currency == config.BaseCurrency {
+		return 1, true
+	}
+	exchangeRatesLock.RLock()
+	defer exchangeRatesLock.RUnlock()
+	rate, ok := ExchangeRates[currency]
+	return rate, ok
+}
+
+// GetExchangeRates returns the rates of the currencies the quota can be displayed in, with the base currency
+func GetExchangeRates() map[string]float64 {
+	exchangeRatesLock.RLock()
+	defer exchangeRatesLock.RUnlock()
+	rates := make(map[string]float64, len(ExchangeRates)+1)
+	for currency, rate := range ExchangeRates {
+		rates[currency] = rate
+	}
+	rates[config.BaseCurrency] = 1
+	return rates
+}
+
+// QuotaToCurrency converts the quota to an amount of the currency
+func QuotaToCurrency(quota int64, currency string) (float64, bool) {
+	rate, ok := GetExchangeRate(currency)
+	if !ok {
+		return 0, false
+	}
+	return float64(quota) / config.QuotaPerUnit * rate, true
+}
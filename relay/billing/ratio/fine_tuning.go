@@ -0,0 +1,65 @@
+package ratio
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// FineTuningRatio is the price of the training tokens of the base models, in the same unit as ModelRatio,
+// https://openai.com/api/pricing/
+var fineTuningRatioLock sync.RWMutex
+var FineTuningRatio = map[string]float64{
+	"gpt-4.1-2025-04-14":      12.5, // $25 / 1M tokens
+	"gpt-4.1-mini-2025-04-14": 2.5,  // $5 / 1M tokens
+	"gpt-4.1-nano-2025-04-14": 0.75, // $1.5 / 1M tokens
+	"gpt-4o-2024-08-06":       12.5, // $25 / 1M tokens
+	"gpt-4o-mini-2024-07-18":  1.5,  // $3 / 1M tokens
+	"gpt-3.5-turbo":           4,    // $8 / 1M tokens
+	"davinci-002":             3,    // $6 / 1M tokens
+	"babbage-002":             0.2,  // $0.4 / 1M tokens
+}
+
+func FineTuningRatio2JSONString() string {
+	fineTuningRatioLock.RLock()
+	defer fineTuningRatioLock.RUnlock()
+	jsonBytes, err := json.Marshal(FineTuningRatio)
+	if err != nil {
+		logger.SysError("error marshalling fine-tuning ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateFineTuningRatioByJSONString(jsonStr string) error {
+	fineTuningRatio := make(map[string]float64)
+	if err := json.Unmarshal([]byte(jsonStr), &fineTuningRatio); err != nil {
+		return err
+	}
+	fineTuningRatioLock.Lock()
+	defer fineTuningRatioLock.Unlock()
+	FineTuningRatio = fineTuningRatio
+	return nil
+}
+
+// GetFineTuningRatio matches the model exactly first, then by the longest prefix, e.g. gpt-3.5-turbo-0125,
+// a fine-tuned model can be trained further at the price of its base model, e.g. ft:gpt-4o-mini-2024-07-18:org::id
+func GetFineTuningRatio(name string) (float64, bool) {
+	name = strings.TrimPrefix(name, "ft:")
+	fineTuningRatioLock.RLock()
+	defer fineTuningRatioLock.RUnlock()
+	if ratio, ok := FineTuningRatio[name]; ok {
+		return ratio, true
+	}
+	matched := ""
+	for model := range FineTuningRatio {
+		if strings.HasPrefix(name, model) && len(model) > len(matched) {
+			matched = model
+		}
+	}
+	if matched == "" {
+		return 0, false
+	}
+	return FineTuningRatio[matched], true
+}
@@ -38,3 +38,39 @@ func GetGroupRatio(name string) float64 {
 	}
 	return ratio
 }
+
+// GroupModelRatio overrides the group ratio for some models of a group, group name -> model name -> ratio
+var groupModelRatioLock sync.RWMutex
+var GroupModelRatio = map[string]map[string]float64{}
+
+func GroupModelRatio2JSONString() string {
+	groupModelRatioLock.RLock()
+	defer groupModelRatioLock.RUnlock()
+	jsonBytes, err := json.Marshal(GroupModelRatio)
+	if err != nil {
+		logger.SysError("error marshalling group model ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupModelRatioByJSONString(jsonStr string) error {
+	groupModelRatio := make(map[string]map[string]float64)
+	if err := json.Unmarshal([]byte(jsonStr), &groupModelRatio); err != nil {
+		return err
+	}
+	groupModelRatioLock.Lock()
+	defer groupModelRatioLock.Unlock()
+	GroupModelRatio = groupModelRatio
+	return nil
+}
+
+// GetGroupModelRatio returns the ratio of the group for the model, the override if any, otherwise the group ratio
+func GetGroupModelRatio(group string, model string) float64 {
+	groupModelRatioLock.RLock()
+	ratio, ok := GroupModelRatio[group][model]
+	groupModelRatioLock.RUnlock()
+	if ok {
+		return ratio
+	}
+	return GetGroupRatio(group)
+}
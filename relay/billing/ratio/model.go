@@ -683,7 +683,7 @@ func UpdateModelRatioByJSONString(jsonStr string) error {
 	return json.Unmarshal([]byte(jsonStr), &ModelRatio)
 }
 
-func GetModelRatio(name string, channelType int) float64 {
+func lookupModelRatio(name string, channelType int) (float64, bool) {
 	modelRatioLock.RLock()
 	defer modelRatioLock.RUnlock()
 	if strings.HasPrefix(name, "qwen-") && strings.HasSuffix(name, "-internet") {
@@ -694,19 +694,33 @@ func GetModelRatio(name string, channelType int) float64 {
 	}
 	model := fmt.Sprintf("%s(%d)", name, channelType)
 	if ratio, ok := ModelRatio[model]; ok {
-		return ratio
+		return ratio, true
 	}
 	if ratio, ok := DefaultModelRatio[model]; ok {
-		return ratio
+		return ratio, true
 	}
 	if ratio, ok := ModelRatio[name]; ok {
-		return ratio
+		return ratio, true
 	}
 	if ratio, ok := DefaultModelRatio[name]; ok {
-		return ratio
+		return ratio, true
 	}
-	logger.SysError("model ratio not found: " + name)
-	return 30
+	return 0, false
+}
+
+func GetModelRatio(name string, channelType int) float64 {
+	ratio, ok := lookupModelRatio(name, channelType)
+	if !ok {
+		logger.SysError("model ratio not found: " + name)
+		return 30
+	}
+	return ratio
+}
+
+// HasModelRatio tells whether the model is priced for the channel type, instead of being billed with the fallback ratio
+func HasModelRatio(name string, channelType int) bool {
+	_, ok := lookupModelRatio(name, channelType)
+	return ok
 }
 
 func CompletionRatio2JSONString() string {
@@ -0,0 +1,139 @@
+package capability
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/songquanpeng/one-api/common/jsonedit"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// Error is a request the model cannot serve, it is rejected instead of being relayed to fail upstream
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type responseFormat struct {
+	Type       string `json:"type"`
+	JsonSchema *struct {
+		Schema json.RawMessage `json:"schema"`
+	} `json:"json_schema"`
+}
+
+// chatRequest are the fields of the chat completion the capabilities are checked against
+type chatRequest struct {
+	Stream              bool
+	Tools               json.RawMessage
+	Functions           json.RawMessage
+	MaxTokens           int
+	MaxCompletionTokens *int
+	ResponseFormat      *responseFormat
+	Messages            []model.Message
+}
+
+func isEmptyArray(raw json.RawMessage) bool {
+	var array []json.RawMessage
+	return len(raw) == 0 || string(raw) == "null" || (json.Unmarshal(raw, &array) == nil && len(array) == 0)
+}
+
+func hasImages(messages []model.Message) bool {
+	for _, message := range messages {
+		if message.IsStringContent() {
+			continue
+		}
+		for _, content := range message.ParseContent() {
+			if content.Type == model.ContentTypeImageURL {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonInstruction asks in the prompt for the JSON the model cannot be asked for with response_format
+func jsonInstruction(schema json.RawMessage) string {
+	if len(schema) == 0 {
+		return "Respond with a valid JSON object only, without any other text."
+	}
+	return "Respond with a valid JSON object only, without any other text, matching this JSON schema:\n" + string(schema)
+}
+
+// Adapt checks the chat completion request against the capabilities of its model, the streams, the tools and the
+// images the model cannot serve are rejected, the JSON mode it lacks is asked for in a system message, and the
+// output tokens are lowered to its maximum and to what its context window leaves after the prompt. promptTokens
+// counts the tokens of the messages, it is only called when the prompt may not fit the window
+func Adapt(request *jsonedit.Object, capabilities Capabilities, modelName string, promptTokens func([]model.Message) int) error {
+	if capabilities.IsEmpty() {
+		return nil
+	}
+	var chat chatRequest
+	for key, value := range map[string]any{
+		"stream":                &chat.Stream,
+		"tools":                 &chat.Tools,
+		"functions":             &chat.Functions,
+		"max_tokens":            &chat.MaxTokens,
+		"max_completion_tokens": &chat.MaxCompletionTokens,
+		"response_format":       &chat.ResponseFormat,
+		"messages":              &chat.Messages,
+	} {
+		if err := request.Unmarshal(key, value); err != nil {
+			// the relay reports the invalid request
+			return nil
+		}
+	}
+	if chat.Stream && capabilities.Streaming != nil && !*capabilities.Streaming {
+		return &Error{Code: "stream_not_supported", Message: fmt.Sprintf("模型 %s 不支持流式输出", modelName)}
+	}
+	if (!isEmptyArray(chat.Tools) || !isEmptyArray(chat.Functions)) && capabilities.Tools != nil && !*capabilities.Tools {
+		return &Error{Code: "tools_not_supported", Message: fmt.Sprintf("模型 %s 不支持工具调用", modelName)}
+	}
+	if capabilities.Vision != nil && !*capabilities.Vision && hasImages(chat.Messages) {
+		return &Error{Code: "vision_not_supported", Message: fmt.Sprintf("模型 %s 不支持图片输入", modelName)}
+	}
+	if format := chat.ResponseFormat; format != nil && format.Type != "text" && capabilities.JSONMode != nil && !*capabilities.JSONMode {
+		var schema json.RawMessage
+		if format.JsonSchema != nil {
+			schema = format.JsonSchema.Schema
+		}
+		request.Delete("response_format")
+		if err := request.Prepend("messages", model.Message{Role: "system", Content: jsonInstruction(schema)}); err != nil {
+			return nil
+		}
+	}
+
+	key, maxTokens := "max_tokens", chat.MaxTokens
+	if chat.MaxCompletionTokens != nil {
+		key, maxTokens = "max_completion_tokens", *chat.MaxCompletionTokens
+	}
+	if capabilities.MaxOutputTokens > 0 && maxTokens > capabilities.MaxOutputTokens {
+		maxTokens = capabilities.MaxOutputTokens
+		if err := request.Set(key, maxTokens); err != nil {
+			return err
+		}
+	}
+	if window := capabilities.ContextWindow; window > 0 {
+		// a token takes a byte at least, the prompt is only counted when the body may not fit
+		messages, _ := request.Get("messages")
+		if len(messages)+maxTokens <= window {
+			return nil
+		}
+		tokens := promptTokens(chat.Messages)
+		if tokens >= window {
+			return &Error{
+				Code:    "context_length_exceeded",
+				Message: fmt.Sprintf("提示的 %d 个 token 超出了模型 %s 的上下文长度 %d", tokens, modelName, window),
+			}
+		}
+		if maxTokens > window-tokens {
+			if err := request.Set(key, window-tokens); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package capability
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/defaults"
+)
+
+// Capabilities are what a model can serve, the unknown features are nil and not checked
+type Capabilities struct {
+	ContextWindow   int   `json:"context_window,omitempty"`
+	MaxOutputTokens int   `json:"max_output_tokens,omitempty"`
+	Vision          *bool `json:"vision,omitempty"`
+	Tools           *bool `json:"tools,omitempty"`
+	JSONMode        *bool `json:"json_mode,omitempty"`
+	Streaming       *bool `json:"streaming,omitempty"`
+}
+
+// IsEmpty tells whether nothing is known of the model
+func (c Capabilities) IsEmpty() bool {
+	return c == Capabilities{}
+}
+
+// merge sets the known capabilities of other over those of c
+func (c Capabilities) merge(other Capabilities) Capabilities {
+	if other.ContextWindow > 0 {
+		c.ContextWindow = other.ContextWindow
+	}
+	if other.MaxOutputTokens > 0 {
+		c.MaxOutputTokens = other.MaxOutputTokens
+	}
+	if other.Vision != nil {
+		c.Vision = other.Vision
+	}
+	if other.Tools != nil {
+		c.Tools = other.Tools
+	}
+	if other.JSONMode != nil {
+		c.JSONMode = other.JSONMode
+	}
+	if other.Streaming != nil {
+		c.Streaming = other.Streaming
+	}
+	return c
+}
+
+// caps returns the capabilities of a chat model streaming its answers
+func caps(contextWindow int, maxOutputTokens int, vision bool, tools bool, jsonMode bool) Capabilities {
+	streaming := true
+	return Capabilities{
+		ContextWindow:   contextWindow,
+		MaxOutputTokens: maxOutputTokens,
+		Vision:          &vision,
+		Tools:           &tools,
+		JSONMode:        &jsonMode,
+		Streaming:       &streaming,
+	}
+}
+
+// builtinCapabilities are the published capabilities of the well-known models by the prefix of their names, the
+// longest prefix applies, such as gpt-4o-mini for gpt-4o-mini-2024-07-18
+var builtinCapabilities = map[string]Capabilities{
+	"gpt-3.5-turbo":        caps(16385, 4096, false, true, true),
+	"gpt-4":                caps(8192, 8192, false, true, false),
+	"gpt-4-32k":            caps(32768, 32768, false, true, false),
+	"gpt-4-0125-preview":   caps(128000, 4096, false, true, true),
+	"gpt-4-1106-preview":   caps(128000, 4096, false, true, true),
+	"gpt-4-vision-preview": caps(128000, 4096, true, false, false),
+	"gpt-4-turbo":          caps(128000, 4096, true, true, true),
+	"gpt-4o":               caps(128000, 16384, true, true, true),
+	"gpt-4.1":              caps(1047576, 32768, true, true, true),
+	"gpt-4.5":              caps(128000, 16384, true, true, true),
+	"o1":                   caps(200000, 100000, true, true, true),
+	"o1-mini":              caps(128000, 65536, false, false, false),
+	"o1-preview":           caps(128000, 32768, false, false, false),
+	"o3":                   caps(200000, 100000, true, true, true),
+	"o3-mini":              caps(200000, 100000, false, true, true),
+	"o4-mini":              caps(200000, 100000, true, true, true),
+	"claude-3":             caps(200000, 4096, true, true, false),
+	"claude-3-5":           caps(200000, 8192, true, true, false),
+	"claude-3-7-sonnet":    caps(200000, 64000, true, true, false),
+	"claude-sonnet-4":      caps(200000, 64000, true, true, false),
+	"claude-opus-4":        caps(200000, 32000, true, true, false),
+	"gemini-1.5-flash":     caps(1048576, 8192, true, true, true),
+	"gemini-1.5-pro":       caps(2097152, 8192, true, true, true),
+	"gemini-2.0-flash":     caps(1048576, 8192, true, true, true),
+	"gemini-2.5":           caps(1048576, 65536, true, true, true),
+	"deepseek-chat":        caps(65536, 8192, false, true, true),
+	"deepseek-reasoner":    caps(65536, 8192, false, false, false),
+}
+
+var modelCapabilitiesLock sync.RWMutex
+
+// ModelCapabilities are set by the admin for the models by their names, they override the builtin capabilities
+var ModelCapabilities = map[string]Capabilities{}
+
+func ModelCapabilities2JSONString() string {
+	modelCapabilitiesLock.RLock()
+	defer modelCapabilitiesLock.RUnlock()
+	jsonBytes, err := json.Marshal(ModelCapabilities)
+	if err != nil {
+		logger.SysError("error marshalling model capabilities: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateModelCapabilitiesByJSONString(jsonStr string) error {
+	modelCapabilities := make(map[string]Capabilities)
+	if err := json.Unmarshal([]byte(jsonStr), &modelCapabilities); err != nil {
+		return err
+	}
+	for name, capabilities := range modelCapabilities {
+		if capabilities.ContextWindow < 0 || capabilities.MaxOutputTokens < 0 {
+			return fmt.Errorf("capabilities of %s must not be negative", name)
+		}
+	}
+	modelCapabilitiesLock.Lock()
+	defer modelCapabilitiesLock.Unlock()
+	ModelCapabilities = modelCapabilities
+	return nil
+}
+
+func getBuiltin(name string) Capabilities {
+	name = strings.ToLower(name)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	longest := ""
+	for prefix := range builtinCapabilities {
+		if len(prefix) > len(longest) && (name == prefix || strings.HasPrefix(name, prefix+"-")) {
+			longest = prefix
+		}
+	}
+	return builtinCapabilities[longest]
+}
+
+// Get returns the capabilities of the model, the builtin ones overridden by the context windows and the maximum
+// output tokens set for it and by the capabilities set for it
+func Get(name string) Capabilities {
+	capabilities := getBuiltin(name)
+	if window := defaults.GetContextWindow(name); window > 0 {
+		capabilities.ContextWindow = window
+	}
+	if limit := defaults.GetMaxTokens(0, name); limit > 0 {
+		capabilities.MaxOutputTokens = limit
+	}
+	modelCapabilitiesLock.RLock()
+	defer modelCapabilitiesLock.RUnlock()
+	return capabilities.merge(ModelCapabilities[name])
+}
+
+// GetFirst returns the capabilities of the first of the models known, such as the model the request is mapped to
+// by the channel and the model requested
+func GetFirst(names ...string) Capabilities {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if capabilities := Get(name); !capabilities.IsEmpty() {
+			return capabilities
+		}
+	}
+	return Capabilities{}
+}
@@ -0,0 +1,75 @@
+package capability
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/jsonedit"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+func TestGet(t *testing.T) {
+	Convey("Get", t, func() {
+		Convey("takes the longest prefix of the builtin capabilities", func() {
+			So(Get("gpt-4o-mini-2024-07-18").MaxOutputTokens, ShouldEqual, 16384)
+			So(*Get("o1-mini").Vision, ShouldBeFalse)
+			So(*Get("o1-2024-12-17").Vision, ShouldBeTrue)
+			So(Get("gpt-4o1").IsEmpty(), ShouldBeTrue)
+			So(Get("gpt-4-1106-preview").ContextWindow, ShouldEqual, 128000)
+			So(Get("deepseek/deepseek-chat").ContextWindow, ShouldEqual, 65536)
+		})
+		Convey("is overridden by the capabilities set by the admin", func() {
+			So(UpdateModelCapabilitiesByJSONString(`{"gpt-4o":{"vision":false},"my-model":{"context_window":4096}}`), ShouldBeNil)
+			defer func() { _ = UpdateModelCapabilitiesByJSONString(`{}`) }()
+			So(*Get("gpt-4o").Vision, ShouldBeFalse)
+			So(Get("gpt-4o").ContextWindow, ShouldEqual, 128000)
+			So(GetFirst("", "my-model").ContextWindow, ShouldEqual, 4096)
+			So(UpdateModelCapabilitiesByJSONString(`{"my-model":{"context_window":-1}}`), ShouldNotBeNil)
+		})
+	})
+}
+
+func adapt(body string, capabilities Capabilities) (string, error) {
+	request, err := jsonedit.Parse([]byte(body))
+	So(err, ShouldBeNil)
+	err = Adapt(request, capabilities, "m", func(messages []model.Message) int {
+		return len(messages[len(messages)-1].StringContent())
+	})
+	return string(request.Bytes()), err
+}
+
+func TestAdapt(t *testing.T) {
+	Convey("Adapt", t, func() {
+		Convey("rejects what the model cannot serve", func() {
+			_, err := adapt(`{"model":"m","stream":true,"messages":[]}`, caps(0, 0, true, true, true).merge(Capabilities{Streaming: new(bool)}))
+			So(err.(*Error).Code, ShouldEqual, "stream_not_supported")
+			_, err = adapt(`{"model":"m","tools":[{"type":"function"}],"messages":[]}`, caps(0, 0, true, false, true))
+			So(err.(*Error).Code, ShouldEqual, "tools_not_supported")
+			_, err = adapt(`{"model":"m","messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"a"}}]}]}`, caps(0, 0, false, true, true))
+			So(err.(*Error).Code, ShouldEqual, "vision_not_supported")
+			_, err = adapt(`{"model":"m","tools":[],"messages":[{"role":"user","content":"hi"}]}`, caps(0, 0, false, false, true))
+			So(err, ShouldBeNil)
+		})
+		Convey("asks for the JSON in the prompt", func() {
+			body, err := adapt(`{"model":"m","response_format":{"type":"json_schema","json_schema":{"schema":{"type":"object"}}},"messages":[{"role":"user","content":"hi"}]}`, caps(0, 0, true, true, false))
+			So(err, ShouldBeNil)
+			So(body, ShouldNotContainSubstring, "response_format")
+			So(body, ShouldContainSubstring, `"role":"system"`)
+			So(body, ShouldContainSubstring, `{\"type\":\"object\"}`)
+		})
+		Convey("lowers the output tokens", func() {
+			body, err := adapt(`{"model":"m","max_tokens":100000,"messages":[{"role":"user","content":"hi"}]}`, caps(0, 8192, true, true, true))
+			So(err, ShouldBeNil)
+			So(body, ShouldContainSubstring, `"max_tokens":8192`)
+			body, err = adapt(`{"model":"m","max_completion_tokens":900,"messages":[{"role":"user","content":"`+strings.Repeat("a", 200)+`"}]}`, caps(1000, 0, true, true, true))
+			So(err, ShouldBeNil)
+			So(body, ShouldContainSubstring, `"max_completion_tokens":800`)
+		})
+		Convey("rejects the prompts beyond the context window", func() {
+			_, err := adapt(`{"model":"m","messages":[{"role":"user","content":"`+strings.Repeat("a", 2000)+`"}]}`, caps(1000, 0, true, true, true))
+			So(err.(*Error).Code, ShouldEqual, "context_length_exceeded")
+		})
+	})
+}
@@ -0,0 +1,154 @@
+// Package chaos injects failures into the requests of the test tokens chosen by the administrators, so that the
+// clients can check how they handle the latency, the errors and the broken streams of the gateway
+package chaos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// Fault is what is injected into the requests of a token, the rates are probabilities between 0 and 1
+type Fault struct {
+	// LatencyMs delays the requests before they are relayed
+	LatencyMs int `json:"latency_ms,omitempty"`
+	// ErrorRate is the rate of the requests rejected with ErrorStatus, 429 by default
+	ErrorRate   float64 `json:"error_rate,omitempty"`
+	ErrorStatus int     `json:"error_status,omitempty"`
+	// TruncateRate is the rate of the streams ended without [DONE] after TruncateAfter events, 1 by default
+	TruncateRate  float64 `json:"truncate_rate,omitempty"`
+	TruncateAfter int     `json:"truncate_after,omitempty"`
+	// MalformedRate is the rate of the events whose data is cut in half, so that it is not valid JSON
+	MalformedRate float64 `json:"malformed_rate,omitempty"`
+}
+
+func (f *Fault) Latency() time.Duration {
+	return time.Duration(f.LatencyMs) * time.Millisecond
+}
+
+func (f *Fault) Status() int {
+	if f.ErrorStatus == 0 {
+		return 429
+	}
+	return f.ErrorStatus
+}
+
+// HasStreamFault tells whether the streams of the token are to be broken
+func (f *Fault) HasStreamFault() bool {
+	return f.TruncateRate > 0 || f.MalformedRate > 0
+}
+
+func (f *Fault) validate() error {
+	if f.LatencyMs < 0 || f.LatencyMs > 600000 {
+		return errors.New("latency_ms must be between 0 and 600000")
+	}
+	rates := []struct {
+		name string
+		rate float64
+	}{{"error_rate", f.ErrorRate}, {"truncate_rate", f.TruncateRate}, {"malformed_rate", f.MalformedRate}}
+	for _, r := range rates {
+		if r.rate < 0 || r.rate > 1 {
+			return fmt.Errorf("%s must be between 0 and 1", r.name)
+		}
+	}
+	if f.ErrorStatus != 0 && (f.ErrorStatus < 400 || f.ErrorStatus > 599) {
+		return errors.New("error_status must be between 400 and 599")
+	}
+	if f.TruncateAfter < 0 {
+		return errors.New("truncate_after must not be negative")
+	}
+	return nil
+}
+
+// Faults maps the id of a token to its faults, the tokens not listed are never affected
+var faultsLock sync.RWMutex
+var Faults = map[int]*Fault{}
+
+func Faults2JSONString() string {
+	faultsLock.RLock()
+	defer faultsLock.RUnlock()
+	jsonBytes, err := json.Marshal(Faults)
+	if err != nil {
+		logger.SysError("error marshalling fault injections: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateFaultsByJSONString(jsonStr string) error {
+	faults := make(map[int]*Fault)
+	if err := json.Unmarshal([]byte(jsonStr), &faults); err != nil {
+		return err
+	}
+	for tokenId, fault := range faults {
+		if fault == nil {
+			return fmt.Errorf("token %d: fault is empty", tokenId)
+		}
+		if err := fault.validate(); err != nil {
+			return fmt.Errorf("token %d: %w", tokenId, err)
+		}
+	}
+	faultsLock.Lock()
+	defer faultsLock.Unlock()
+	Faults = faults
+	return nil
+}
+
+func GetFault(tokenId int) *Fault {
+	faultsLock.RLock()
+	defer faultsLock.RUnlock()
+	return Faults[tokenId]
+}
+
+// Chance draws whether an event of the rate happens
+func Chance(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// StreamFault breaks the events of a stream, it is applied after the response filters, as the network would
+type StreamFault struct {
+	fault     *Fault
+	truncate  bool
+	events    int
+	truncated bool
+	chance    func(rate float64) bool
+}
+
+// NewStreamFault draws whether the stream is truncated once, the malformed events are drawn one by one
+func NewStreamFault(fault *Fault) *StreamFault {
+	return newStreamFault(fault, Chance)
+}
+
+func newStreamFault(fault *Fault, chance func(rate float64) bool) *StreamFault {
+	return &StreamFault{fault: fault, truncate: chance(fault.TruncateRate), chance: chance}
+}
+
+// Truncated tells whether the rest of the stream has been dropped
+func (s *StreamFault) Truncated() bool {
+	return s.truncated
+}
+
+func (s *StreamFault) Filter(data string) []string {
+	if s.truncated {
+		return nil
+	}
+	if s.truncate {
+		after := s.fault.TruncateAfter
+		if after == 0 {
+			after = 1
+		}
+		if s.events >= after {
+			s.truncated = true
+			return nil
+		}
+	}
+	s.events++
+	if data != "[DONE]" && len(data) > 1 && s.chance(s.fault.MalformedRate) {
+		data = data[:len(data)/2]
+	}
+	return []string{data}
+}
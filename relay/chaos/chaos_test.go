@@ -0,0 +1,38 @@
+package chaos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestChaos(t *testing.T) {
+	Convey("UpdateFaultsByJSONString", t, func() {
+		So(UpdateFaultsByJSONString(`{"1": null}`), ShouldNotBeNil)
+		So(UpdateFaultsByJSONString(`{"1": {"error_rate": 2}}`), ShouldNotBeNil)
+		So(UpdateFaultsByJSONString(`{"1": {"error_status": 200}}`), ShouldNotBeNil)
+		So(UpdateFaultsByJSONString(`{"1": {"latency_ms": -1}}`), ShouldNotBeNil)
+		So(UpdateFaultsByJSONString(`{"1": {"latency_ms": 500, "error_rate": 0.5}}`), ShouldBeNil)
+		So(Faults2JSONString(), ShouldEqual, `{"1":{"latency_ms":500,"error_rate":0.5}}`)
+		fault := GetFault(1)
+		So(fault, ShouldNotBeNil)
+		So(fault.Status(), ShouldEqual, 429)
+		So(fault.HasStreamFault(), ShouldBeFalse)
+		So(GetFault(2), ShouldBeNil)
+	})
+
+	Convey("StreamFault", t, func() {
+		always := func(rate float64) bool { return rate > 0 }
+		s := newStreamFault(&Fault{TruncateRate: 1, TruncateAfter: 2}, always)
+		So(s.Filter(`{"a":1}`), ShouldResemble, []string{`{"a":1}`})
+		So(s.Filter(`{"a":2}`), ShouldResemble, []string{`{"a":2}`})
+		So(s.Filter(`{"a":3}`), ShouldBeNil)
+		So(s.Filter("[DONE]"), ShouldBeNil)
+		So(s.Truncated(), ShouldBeTrue)
+
+		s = newStreamFault(&Fault{MalformedRate: 1}, always)
+		So(s.Filter(`{"a":1}`), ShouldResemble, []string{`{"a`})
+		So(s.Filter("[DONE]"), ShouldResemble, []string{"[DONE]"})
+		So(s.Truncated(), ShouldBeFalse)
+	})
+}
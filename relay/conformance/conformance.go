@@ -0,0 +1,178 @@
+// Package conformance checks that the chat completions returned to the clients are well-formed, whatever the
+// upstream of the channel sent: the bodies parse, the choices are there and the answers end with a known finish
+// reason, so that the channels returning broken or truncated answers with a 2xx status can be found out
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// the reasons of a malformed response
+const (
+	ReasonEmptyBody     = "empty_body"
+	ReasonInvalidJSON   = "invalid_json"
+	ReasonInvalidEvent  = "invalid_event"
+	ReasonEmptyChoices  = "empty_choices"
+	ReasonUnknownFinish = "unknown_finish_reason"
+	ReasonTruncated     = "truncated"
+)
+
+const (
+	maxBodySize    = 4 << 20 // the larger non-streamed bodies are not checked
+	sampleHeadSize = 8 << 10
+	sampleTailSize = 8 << 10
+	sampleOmission = "\n...\n"
+)
+
+var finishReasons = map[string]bool{
+	"stop":           true,
+	"length":         true,
+	"tool_calls":     true,
+	"function_call":  true,
+	"content_filter": true,
+	// the answer cut by deepseek under load
+	"insufficient_system_resource": true,
+}
+
+type choices struct {
+	Choices []struct {
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// Checker reads a response as it is written and tells whether it is well-formed, a streamed response is checked
+// event by event, without keeping it
+type Checker struct {
+	stream bool
+	size   int
+	body   []byte // the non-streamed body, or the partial line of the stream
+	head   []byte
+	tail   []byte
+
+	reason     string
+	hasChoices bool
+	finished   bool
+}
+
+func New(stream bool) *Checker {
+	return &Checker{stream: stream}
+}
+
+func (c *Checker) Write(data []byte) {
+	c.size += len(data)
+	c.keepSample(data)
+	if !c.stream {
+		if len(c.body)+len(data) <= maxBodySize {
+			c.body = append(c.body, data...)
+		}
+		return
+	}
+	c.body = append(c.body, data...)
+	for {
+		end := bytes.IndexByte(c.body, '\n')
+		if end < 0 {
+			break
+		}
+		c.checkLine(c.body[:end])
+		c.body = c.body[end+1:]
+	}
+}
+
+func (c *Checker) keepSample(data []byte) {
+	if room := sampleHeadSize - len(c.head); room > 0 {
+		if room > len(data) {
+			room = len(data)
+		}
+		c.head = append(c.head, data[:room]...)
+		data = data[room:]
+	}
+	c.tail = append(c.tail, data...)
+	if len(c.tail) > sampleTailSize {
+		c.tail = append(c.tail[:0], c.tail[len(c.tail)-sampleTailSize:]...)
+	}
+}
+
+// checkLine checks an event of the stream, the lines other than the data such as the keep-alive comments are left
+func (c *Checker) checkLine(line []byte) {
+	payload, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r"), []byte("data:"))
+	if !ok {
+		return
+	}
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 || string(payload) == "[DONE]" {
+		return
+	}
+	var chunk choices
+	if err := json.Unmarshal(payload, &chunk); err != nil {
+		c.fail(ReasonInvalidEvent)
+		return
+	}
+	c.checkChoices(chunk)
+}
+
+func (c *Checker) checkChoices(response choices) {
+	if len(response.Choices) > 0 {
+		c.hasChoices = true
+	}
+	for _, choice := range response.Choices {
+		if choice.FinishReason == nil || *choice.FinishReason == "" {
+			continue
+		}
+		c.finished = true
+		if !finishReasons[strings.ToLower(*choice.FinishReason)] {
+			c.fail(ReasonUnknownFinish)
+		}
+	}
+}
+
+// fail keeps the first problem of the response
+func (c *Checker) fail(reason string) {
+	if c.reason == "" {
+		c.reason = reason
+	}
+}
+
+// Result returns why the response is malformed, empty if it is well-formed
+func (c *Checker) Result() string {
+	if c.size == 0 {
+		return ReasonEmptyBody
+	}
+	if c.stream {
+		if len(bytes.TrimSpace(c.body)) > 0 {
+			c.checkLine(c.body)
+			c.body = nil
+		}
+	} else if c.size <= maxBodySize {
+		var response choices
+		if err := json.Unmarshal(c.body, &response); err != nil {
+			return ReasonInvalidJSON
+		}
+		c.checkChoices(response)
+	} else {
+		return ""
+	}
+	switch {
+	case c.reason != "":
+		return c.reason
+	case !c.hasChoices:
+		return ReasonEmptyChoices
+	case c.stream && !c.finished:
+		// the stream ended before the answer
+		return ReasonTruncated
+	}
+	return ""
+}
+
+// Sample returns the beginning and the end of the response, for the malformed responses to be looked at
+func (c *Checker) Sample() []byte {
+	if len(c.tail) == 0 {
+		return c.head
+	}
+	sample := append([]byte{}, c.head...)
+	if c.size > len(c.head)+len(c.tail) {
+		sample = append(sample, sampleOmission...)
+	}
+	return append(sample, c.tail...)
+}
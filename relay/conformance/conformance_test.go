@@ -0,0 +1,47 @@
+package conformance
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func check(stream bool, writes ...string) string {
+	checker := New(stream)
+	for _, data := range writes {
+		checker.Write([]byte(data))
+	}
+	return checker.Result()
+}
+
+func TestChecker(t *testing.T) {
+	Convey("non-streamed responses", t, func() {
+		So(check(false, `{"choices":[{"message":{"content":"hi"},`, `"finish_reason":"stop"}]}`), ShouldBeEmpty)
+		So(check(false, `{"choices":[{"message":{"content":"hi"},"finish_reason":null}]}`), ShouldBeEmpty)
+		So(check(false), ShouldEqual, ReasonEmptyBody)
+		So(check(false, `{"choices":[{"message":`), ShouldEqual, ReasonInvalidJSON)
+		So(check(false, `{"id":"x","choices":[]}`), ShouldEqual, ReasonEmptyChoices)
+		So(check(false, `{"choices":[{"finish_reason":"eos_token"}]}`), ShouldEqual, ReasonUnknownFinish)
+	})
+
+	Convey("streamed responses", t, func() {
+		So(check(true, ": keep-alive\n\n", "data: {\"choices\":[{\"delta\":{\"content\":\"h", "i\"}}]}\r\n\r\n",
+			"data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"Length\"}]}\n\n", "data: {\"choices\":[],\"usage\":{}}\n\ndata: [DONE]\n\n"), ShouldBeEmpty)
+		So(check(true, "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n"), ShouldEqual, ReasonTruncated)
+		So(check(true, "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: {\"choi\n\n"), ShouldEqual, ReasonInvalidEvent)
+		So(check(true, "data: {\"choices\":[]}\n\ndata: [DONE]\n\n"), ShouldEqual, ReasonEmptyChoices)
+		So(check(true, "data: {\"choices\":[{\"finish_reason\":\"stop\"}]}"), ShouldBeEmpty)
+	})
+
+	Convey("Sample", t, func() {
+		checker := New(false)
+		checker.Write([]byte("a" + strings.Repeat("b", sampleHeadSize)))
+		checker.Write([]byte(strings.Repeat("c", sampleTailSize) + "d"))
+		sample := string(checker.Sample())
+		So(sample, ShouldStartWith, "a")
+		So(sample, ShouldContainSubstring, "b"+sampleOmission+"c")
+		So(sample, ShouldEndWith, "cd")
+		So(len(sample), ShouldEqual, sampleHeadSize+len(sampleOmission)+sampleTailSize)
+	})
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/relay/adaptor"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// captureWriter keeps the response of the upstream from the client, so that the relay can change it
+type captureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *captureWriter) WriteHeader(code int) {}
+
+func (w *captureWriter) WriteHeaderNow() {}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// captureResponse sends the request, the response converted by the adaptor is kept by the writer
+func captureResponse(c *gin.Context, meta *meta.Meta, a adaptor.Adaptor, requestBody io.Reader, writer *captureWriter) (*model.Usage, *model.ErrorWithStatusCode) {
+	c.Writer = writer
+	defer func() {
+		c.Writer = writer.ResponseWriter
+	}()
+	resp, bizErr := doTextRequest(c, meta, a, requestBody)
+	if bizErr != nil {
+		return nil, bizErr
+	}
+	return a.DoResponse(c, resp, meta)
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/relay/adaptor"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+type DryRunResponse struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    any               `json:"body"`
+}
+
+var secretHeaders = []string{"Authorization", "Api-Key", "X-Api-Key", "X-Goog-Api-Key", "Proxy-Authorization"}
+
+// dryRun responds with the request that would be sent to the upstream, credentials are redacted
+func dryRun(c *gin.Context, meta *meta.Meta, a adaptor.Adaptor, requestBody io.Reader) *model.ErrorWithStatusCode {
+	body, err := io.ReadAll(requestBody)
+	if err != nil {
+		return openai.ErrorWrapper(err, "read_request_body_failed", http.StatusInternalServerError)
+	}
+	fullRequestURL, err := a.GetRequestURL(meta)
+	if err != nil {
+		return openai.ErrorWrapper(fmt.Errorf("get request url failed: %w", err), "dry_run_failed", http.StatusInternalServerError)
+	}
+	req, err := http.NewRequest(c.Request.Method, fullRequestURL, nil)
+	if err != nil {
+		return openai.ErrorWrapper(fmt.Errorf("new request failed: %w", err), "dry_run_failed", http.StatusInternalServerError)
+	}
+	if err = a.SetupRequestHeader(c, req, meta); err != nil {
+		return openai.ErrorWrapper(fmt.Errorf("setup request header failed: %w", err), "dry_run_failed", http.StatusInternalServerError)
+	}
+	respondDryRun(c, req, body)
+	return nil
+}
+
+// redactURL hides the user info and all the query values, some adaptors send the credential in the query,
+// such as the access_token of Baidu
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.User("REDACTED")
+	}
+	if redacted.RawQuery != "" {
+		query := redacted.Query()
+		keys := make([]string, 0, len(query))
+		for key := range query {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			keys[i] = url.QueryEscape(key) + "=[REDACTED]"
+		}
+		redacted.RawQuery = strings.Join(keys, "&")
+	}
+	return redacted.String()
+}
+
+// respondDryRun writes the request instead of sending it, with the credentials in the headers and the url redacted
+func respondDryRun(c *gin.Context, req *http.Request, body []byte) {
+	for _, key := range secretHeaders {
+		if req.Header.Get(key) != "" {
+			req.Header.Set(key, "[REDACTED]")
+		}
+	}
+	headers := make(map[string]string, len(req.Header))
+	for key := range req.Header {
+		headers[key] = strings.Join(req.Header.Values(key), ", ")
+	}
+	resp := DryRunResponse{
+		Method:  req.Method,
+		URL:     redactURL(req.URL),
+		Headers: headers,
+		Body:    string(body),
+	}
+	var jsonBody any
+	if json.Unmarshal(body, &jsonBody) == nil {
+		resp.Body = jsonBody
+	} else if !utf8.Valid(body) {
+		// such as the audio files of the transcriptions
+		resp.Body = fmt.Sprintf("[%d bytes]", len(body))
+	}
+	c.JSON(http.StatusOK, resp)
+}
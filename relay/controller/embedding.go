@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/vector"
+	"github.com/songquanpeng/one-api/relay/adaptor"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/apitype"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+const EmbeddingDimensionsTruncate = "truncate"
+
+// the encoding formats of the embeddings, float16 and int8 are offered by the gateway to shrink the responses
+const (
+	EmbeddingFormatFloat   = "float"
+	EmbeddingFormatBase64  = "base64"
+	EmbeddingFormatFloat16 = "float16"
+	EmbeddingFormatInt8    = "int8"
+)
+
+// embeddingTransform reshapes the embeddings of the upstream as asked by the client, they are asked
+// as floats and encoded again in the format asked by the client
+type embeddingTransform struct {
+	dimensions int
+	normalize  bool
+	format     string
+}
+
+// newEmbeddingTransform returns nil when the embeddings of the upstream are passed to the client as they are
+func newEmbeddingTransform(meta *meta.Meta, textRequest *model.GeneralOpenAIRequest) *embeddingTransform {
+	if meta.Mode != relaymode.Embeddings {
+		return nil
+	}
+	transform := &embeddingTransform{normalize: meta.Config.NormalizeEmbeddings}
+	if meta.Config.EmbeddingDimensions == EmbeddingDimensionsTruncate && textRequest.Dimensions > 0 {
+		transform.dimensions = textRequest.Dimensions
+		transform.normalize = true
+		textRequest.Dimensions = 0
+	}
+	format := textRequest.EncodingFormat
+	// base64 is passed to OpenAI, the other adaptors convert the embeddings of their own APIs into floats
+	encoded := format == EmbeddingFormatFloat16 || format == EmbeddingFormatInt8 ||
+		(format == EmbeddingFormatBase64 && meta.APIType != apitype.OpenAI)
+	if transform.dimensions == 0 && !transform.normalize && !encoded {
+		return nil
+	}
+	transform.format = format
+	textRequest.EncodingFormat = ""
+	meta.Rewritten = true
+	return transform
+}
+
+func (t *embeddingTransform) apply(embedding []float64) []float64 {
+	if t.dimensions > 0 && t.dimensions < len(embedding) {
+		embedding = embedding[:t.dimensions]
+	}
+	if t.normalize {
+		embedding = vector.Normalize(embedding)
+	}
+	return embedding
+}
+
+// encodeEmbedding encodes the embedding as the little-endian float32 array of OpenAI
+func encodeEmbedding(embedding []float64) string {
+	data := make([]byte, 4*len(embedding))
+	for i, x := range embedding {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(float32(x)))
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// encodeEmbeddingFloat16 encodes the embedding as a little-endian float16 array, half the size of base64
+func encodeEmbeddingFloat16(embedding []float64) string {
+	data := make([]byte, 2*len(embedding))
+	for i, x := range embedding {
+		binary.LittleEndian.PutUint16(data[2*i:], vector.Float16(float32(x)))
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func (t *embeddingTransform) relay(c *gin.Context, meta *meta.Meta, a adaptor.Adaptor, requestBody io.Reader) (*model.Usage, *model.ErrorWithStatusCode) {
+	writer := &captureWriter{ResponseWriter: c.Writer}
+	usage, respErr := captureResponse(c, meta, a, requestBody, writer)
+	if respErr != nil {
+		return nil, respErr
+	}
+	var response openai.EmbeddingResponse
+	if err := json.Unmarshal(writer.body.Bytes(), &response); err != nil {
+		return nil, openai.ErrorWrapper(errors.New("invalid embedding response of the upstream"), "invalid_embedding_response", http.StatusInternalServerError)
+	}
+	data := make([]gin.H, 0, len(response.Data))
+	for _, item := range response.Data {
+		embedding := t.apply(item.Embedding)
+		result := gin.H{
+			"object":    item.Object,
+			"index":     item.Index,
+			"embedding": embedding,
+		}
+		switch t.format {
+		case EmbeddingFormatBase64:
+			result["embedding"] = encodeEmbedding(embedding)
+		case EmbeddingFormatFloat16:
+			result["embedding"] = encodeEmbeddingFloat16(embedding)
+		case EmbeddingFormatInt8:
+			// the client multiplies the integers by the scale to get the embedding back
+			result["embedding"], result["scale"] = vector.QuantizeInt8(embedding)
+		}
+		data = append(data, result)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object": response.Object,
+		"data":   data,
+		"model":  response.Model,
+		"usage":  response.Usage,
+	})
+	return usage, nil
+}
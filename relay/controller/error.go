@@ -1,14 +1,19 @@
 package controller
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
 	"github.com/songquanpeng/one-api/relay/model"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 type GeneralErrorResponse struct {
@@ -27,6 +32,13 @@ type GeneralErrorResponse struct {
 	} `json:"response"`
 }
 
+// googleErrorStatus is the status of the errors of Google, e.g. INVALID_ARGUMENT, which tells the type of the error
+type googleErrorStatus struct {
+	Error struct {
+		Status string `json:"status"`
+	} `json:"error"`
+}
+
 func (e GeneralErrorResponse) ToMessage() string {
 	if e.Error.Message != "" {
 		return e.Error.Message
@@ -83,6 +95,14 @@ func RelayErrorHandler(resp *http.Response) (ErrorWithStatusCode *model.ErrorWit
 	if err != nil {
 		return
 	}
+	responseBody = bytes.TrimSpace(responseBody)
+	if len(responseBody) > 0 && responseBody[0] == '[' {
+		// Gemini returns its errors in an array
+		var errResponses []json.RawMessage
+		if err = json.Unmarshal(responseBody, &errResponses); err == nil && len(errResponses) > 0 {
+			responseBody = errResponses[0]
+		}
+	}
 	var errResponse GeneralErrorResponse
 	err = json.Unmarshal(responseBody, &errResponse)
 	if err != nil {
@@ -91,6 +111,11 @@ func RelayErrorHandler(resp *http.Response) (ErrorWithStatusCode *model.ErrorWit
 	if errResponse.Error.Message != "" {
 		// OpenAI format error, so we override the default one
 		ErrorWithStatusCode.Error = errResponse.Error
+		var status googleErrorStatus
+		if json.Unmarshal(responseBody, &status) == nil && status.Error.Status != "" {
+			ErrorWithStatusCode.Error.Type = googleErrorTypes[status.Error.Status]
+			ErrorWithStatusCode.Error.Code = strings.ToLower(status.Error.Status)
+		}
 	} else {
 		ErrorWithStatusCode.Error.Message = errResponse.ToMessage()
 	}
@@ -99,3 +124,107 @@ func RelayErrorHandler(resp *http.Response) (ErrorWithStatusCode *model.ErrorWit
 	}
 	return
 }
+
+var googleErrorTypes = map[string]string{
+	"INVALID_ARGUMENT":    "invalid_request_error",
+	"FAILED_PRECONDITION": "invalid_request_error",
+	"OUT_OF_RANGE":        "invalid_request_error",
+	"UNAUTHENTICATED":     "authentication_error",
+	"PERMISSION_DENIED":   "permission_error",
+	"NOT_FOUND":           "not_found_error",
+	"RESOURCE_EXHAUSTED":  "rate_limit_error",
+	"INTERNAL":            "server_error",
+	"UNAVAILABLE":         "server_error",
+	"DEADLINE_EXCEEDED":   "server_error",
+}
+
+// errorTypes are the types of the errors the SDKs of OpenAI know, the errors of other types are given one of them
+var errorTypes = map[string]bool{
+	"invalid_request_error": true,
+	"authentication_error":  true,
+	"permission_error":      true,
+	"not_found_error":       true,
+	"rate_limit_error":      true,
+	"insufficient_quota":    true,
+	"requests":              true,
+	"tokens":                true,
+	"server_error":          true,
+	"upstream_error":        true,
+	"one_api_error":         true,
+}
+
+// errorTypeStatusCodes give a status code to the errors returned by the adaptors along with a success status
+var errorTypeStatusCodes = map[string]int{
+	"invalid_request_error": http.StatusBadRequest,
+	"not_found_error":       http.StatusNotFound,
+	"request_too_large":     http.StatusRequestEntityTooLarge,
+	"rate_limit_error":      http.StatusTooManyRequests,
+	"overloaded_error":      http.StatusServiceUnavailable,
+}
+
+func statusErrorType(statusCode int) string {
+	switch {
+	case statusCode == http.StatusBadRequest || statusCode == http.StatusRequestEntityTooLarge:
+		return "invalid_request_error"
+	case statusCode == http.StatusNotFound:
+		return "not_found_error"
+	case statusCode == http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case statusCode == http.StatusBadGateway:
+		return "upstream_error"
+	case statusCode >= 500:
+		return "server_error"
+	}
+	return "upstream_error"
+}
+
+// NormalizeError shapes the error of any upstream as an error of OpenAI before it is returned to the client:
+// the type is one known to the SDKs of OpenAI, the code is a string, and the status code tells the SDKs
+// whether to retry. The credentials of the channels failing upstream are no fault of the client, so they are
+// reported as a bad gateway rather than 401 or 403, and the status codes unknown to HTTP, such as 529 of
+// Anthropic when it is overloaded, are given a standard one
+func NormalizeError(err *model.ErrorWithStatusCode) {
+	if err.Type == "one_api_error" {
+		if err.StatusCode < http.StatusBadRequest {
+			err.StatusCode = http.StatusInternalServerError
+		}
+		return
+	}
+	if err.StatusCode < http.StatusBadRequest {
+		err.StatusCode = http.StatusInternalServerError
+		if statusCode, ok := errorTypeStatusCodes[err.Type]; ok {
+			err.StatusCode = statusCode
+		}
+	}
+	switch {
+	case err.StatusCode == 529:
+		err.StatusCode = http.StatusServiceUnavailable
+	case http.StatusText(err.StatusCode) == "" && err.StatusCode >= 500:
+		err.StatusCode = http.StatusBadGateway
+	case http.StatusText(err.StatusCode) == "":
+		err.StatusCode = http.StatusBadRequest
+	}
+	if err.StatusCode == http.StatusUnauthorized || err.StatusCode == http.StatusForbidden {
+		err.StatusCode = http.StatusBadGateway
+	}
+	if !errorTypes[err.Type] || err.StatusCode == http.StatusBadGateway {
+		if err.Type != "" && (err.Code == nil || fmt.Sprint(err.Code) == "") {
+			err.Code = err.Type
+		}
+		err.Type = statusErrorType(err.StatusCode)
+	}
+	switch code := err.Code.(type) {
+	case float64:
+		err.Code = strconv.FormatFloat(code, 'f', -1, 64)
+	case int:
+		err.Code = strconv.Itoa(code)
+	}
+}
+
+// doRequestError reports the requests stopped by the deadline of the client as timeouts rather than upstream failures
+func doRequestError(err error) *model.ErrorWithStatusCode {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return openai.ErrorWrapper(err, "request_timeout", http.StatusGatewayTimeout)
+	}
+	return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+}
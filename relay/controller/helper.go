@@ -7,6 +7,7 @@ import (
 	"math"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/relay/constant/role"
@@ -23,7 +24,9 @@ import (
 	"github.com/songquanpeng/one-api/relay/controller/validator"
 	"github.com/songquanpeng/one-api/relay/meta"
 	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/plugin"
 	"github.com/songquanpeng/one-api/relay/relaymode"
+	"github.com/songquanpeng/one-api/relay/shaper"
 )
 
 func getAndValidateTextRequest(c *gin.Context, relayMode int) (*relaymodel.GeneralOpenAIRequest, error) {
@@ -94,10 +97,16 @@ func preConsumeQuota(ctx context.Context, textRequest *relaymodel.GeneralOpenAIR
 	return preConsumedQuota, nil
 }
 
-func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.Meta, textRequest *relaymodel.GeneralOpenAIRequest, ratio float64, preConsumedQuota int64, modelRatio float64, groupRatio float64, systemPromptReset bool) {
+// hasFreeRequest tells if the user still has a free request to the origin model today
+func hasFreeRequest(meta *meta.Meta) bool {
+	return model.HasFreeRequest(meta.UserId, meta.Group, meta.OriginModelName)
+}
+
+// postConsumeQuota bills the request and returns the quota charged
+func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.Meta, textRequest *relaymodel.GeneralOpenAIRequest, ratio float64, preConsumedQuota int64, modelRatio float64, groupRatio float64, systemPromptReset bool) int64 {
 	if usage == nil {
 		logger.Error(ctx, "usage is nil, which is unexpected")
-		return
+		return 0
 	}
 	var quota int64
 	completionRatio := billingratio.GetCompletionRatio(textRequest.Model, meta.ChannelType)
@@ -113,17 +122,30 @@ func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.M
 		// we cannot just return, because we may have to return the pre-consumed quota
 		quota = 0
 	}
+	quota, priceNote, creditMarkup := model.ApplyPriceAdjustments(meta.UserId, meta.OriginModelName, quota)
 	quotaDelta := quota - preConsumedQuota
 	err := model.PostConsumeTokenQuota(meta.TokenId, quotaDelta)
 	if err != nil {
 		logger.Error(ctx, "error consuming token remain quota: "+err.Error())
+	} else {
+		creditMarkup()
 	}
 	err = model.CacheUpdateUserQuota(ctx, meta.UserId)
 	if err != nil {
 		logger.Error(ctx, "error update user quota cache: "+err.Error())
 	}
 	logContent := fmt.Sprintf("倍率：%.2f × %.2f × %.2f", modelRatio, groupRatio, completionRatio)
-	model.RecordConsumeLog(ctx, &model.Log{
+	if meta.FreeRequest {
+		model.ConsumeFreeRequest(meta.UserId, meta.Group, meta.OriginModelName)
+		logContent += "，免费额度"
+	}
+	if meta.QuotaFallback {
+		logContent += "，额度用尽降级"
+	}
+	if priceNote != "" {
+		logContent += "，" + priceNote
+	}
+	consumeLog := &model.Log{
 		UserId:            meta.UserId,
 		ChannelId:         meta.ChannelId,
 		PromptTokens:      promptTokens,
@@ -135,9 +157,44 @@ func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.M
 		IsStream:          meta.IsStream,
 		ElapsedTime:       helper.CalcElapsedTime(meta.StartTime),
 		SystemPromptReset: systemPromptReset,
-	})
+		UpstreamRequestId: meta.UpstreamRequestId,
+		UpstreamAccount:   meta.UpstreamAccount(),
+	}
+	model.RecordConsumeLog(ctx, consumeLog)
+	plugin.OnBilling(ctx, consumeLog)
 	model.UpdateUserUsedQuotaAndRequestCount(meta.UserId, quota)
 	model.UpdateChannelUsedQuota(meta.ChannelId, quota)
+	return quota
+}
+
+const upstreamRequestIdHeader = "X-OneAPI-Upstream-Request-Id"
+
+// upstreamRequestIdHeaders are the headers carrying the ids the providers ask for in the support tickets
+var upstreamRequestIdHeaders = []string{"x-request-id", "request-id", "apim-request-id", "x-amzn-requestid", "cf-ray"}
+
+// captureUpstreamRequestId returns the request ids of the upstream as name=value pairs, such as
+// "request-id=req_123; cf-ray=8a1b2c", and returns them to the client in the X-OneAPI-Upstream-Request-Id header
+func captureUpstreamRequestId(c *gin.Context, resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+	var ids []string
+	for _, name := range upstreamRequestIdHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			ids = append(ids, name+"="+value)
+		}
+	}
+	requestId := strings.Join(ids, "; ")
+	if len(requestId) > 255 {
+		requestId = requestId[:255]
+	}
+	if requestId == "" {
+		// the header of a former attempt on another channel is not left
+		c.Writer.Header().Del(upstreamRequestIdHeader)
+	} else {
+		c.Header(upstreamRequestIdHeader, requestId)
+	}
+	return requestId
 }
 
 func getMappedModelName(modelName string, mapping map[string]string) (string, bool) {
@@ -196,3 +253,14 @@ func setSystemPrompt(ctx context.Context, request *relaymodel.GeneralOpenAIReque
 	logger.Infof(ctx, "add system prompt")
 	return true
 }
+
+// waitForChannel queues the request until the channel is within its rpm and tpm,
+// the tokens of a text request are counted as the prompt plus the completion limit
+func waitForChannel(c *gin.Context, meta *meta.Meta, tokens int) *relaymodel.ErrorWithStatusCode {
+	maxWait := time.Duration(config.ChannelRateLimitMaxWait) * time.Second
+	err := shaper.Wait(c.Request.Context(), meta.ChannelId, meta.Config.RPM, meta.Config.TPM, tokens, maxWait)
+	if err != nil {
+		return openai.ErrorWrapper(err, "channel_rate_limited", http.StatusTooManyRequests)
+	}
+	return nil
+}
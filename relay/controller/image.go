@@ -17,10 +17,12 @@ import (
 	"github.com/songquanpeng/one-api/model"
 	"github.com/songquanpeng/one-api/relay"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/billing"
 	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
 	"github.com/songquanpeng/one-api/relay/channeltype"
 	"github.com/songquanpeng/one-api/relay/meta"
 	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/plugin"
 )
 
 func getImageRequest(c *gin.Context, _ int) (*relaymodel.ImageRequest, error) {
@@ -168,8 +170,12 @@ func RelayImageHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 		requestBody = bytes.NewBuffer(jsonStr)
 	}
 
+	if meta.DryRun {
+		return dryRun(c, meta, adaptor, requestBody)
+	}
+
 	modelRatio := billingratio.GetModelRatio(imageModel, meta.ChannelType)
-	groupRatio := billingratio.GetGroupRatio(meta.Group)
+	groupRatio := billingratio.GetGroupModelRatio(meta.Group, imageModel)
 	ratio := modelRatio * groupRatio
 	userQuota, err := model.CacheGetUserQuota(ctx, meta.UserId)
 
@@ -186,49 +192,70 @@ func RelayImageHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 		return openai.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
 	}
 
+	if bizErr := waitForChannel(c, meta, 0); bizErr != nil {
+		return bizErr
+	}
+
 	// do request
 	resp, err := adaptor.DoRequest(c, meta, requestBody)
 	if err != nil {
 		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
-		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+		return doRequestError(err)
 	}
+	throttleOnRateLimit(ctx, meta, resp)
+	meta.UpstreamRequestId = captureUpstreamRequestId(c, resp)
 
+	// the images are billed once they are sent, a failed request is not billed
+	var respErr *relaymodel.ErrorWithStatusCode
 	defer func(ctx context.Context) {
-		if resp != nil &&
+		if respErr != nil || (resp != nil &&
 			resp.StatusCode != http.StatusCreated && // replicate returns 201
-			resp.StatusCode != http.StatusOK {
+			resp.StatusCode != http.StatusOK) {
 			return
 		}
 
-		err := model.PostConsumeTokenQuota(meta.TokenId, quota)
-		if err != nil {
-			logger.SysError("error consuming token remain quota: " + err.Error())
-		}
-		err = model.CacheUpdateUserQuota(ctx, meta.UserId)
-		if err != nil {
-			logger.SysError("error update user quota cache: " + err.Error())
-		}
-		if quota != 0 {
-			tokenName := c.GetString(ctxkey.TokenName)
-			logContent := fmt.Sprintf("倍率：%.2f × %.2f", modelRatio, groupRatio)
-			model.RecordConsumeLog(ctx, &model.Log{
-				UserId:           meta.UserId,
-				ChannelId:        meta.ChannelId,
-				PromptTokens:     0,
-				CompletionTokens: 0,
-				ModelName:        imageRequest.Model,
-				TokenName:        tokenName,
-				Quota:            int(quota),
-				Content:          logContent,
-			})
-			model.UpdateUserUsedQuotaAndRequestCount(meta.UserId, quota)
-			channelId := c.GetInt(ctxkey.ChannelId)
-			model.UpdateChannelUsedQuota(channelId, quota)
-		}
+		// read from the gin context now, it must not be used once the request is finished
+		tokenName := c.GetString(ctxkey.TokenName)
+		channelId := c.GetInt(ctxkey.ChannelId)
+		billing.Go(func() {
+			quota, priceNote, creditMarkup := model.ApplyPriceAdjustments(meta.UserId, meta.OriginModelName, quota)
+			err := model.PostConsumeTokenQuota(meta.TokenId, quota)
+			if err != nil {
+				logger.SysError("error consuming token remain quota: " + err.Error())
+			} else {
+				creditMarkup()
+			}
+			err = model.CacheUpdateUserQuota(ctx, meta.UserId)
+			if err != nil {
+				logger.SysError("error update user quota cache: " + err.Error())
+			}
+			if quota != 0 {
+				logContent := fmt.Sprintf("倍率：%.2f × %.2f", modelRatio, groupRatio)
+				if priceNote != "" {
+					logContent += "，" + priceNote
+				}
+				consumeLog := &model.Log{
+					UserId:            meta.UserId,
+					ChannelId:         meta.ChannelId,
+					PromptTokens:      0,
+					CompletionTokens:  0,
+					ModelName:         imageRequest.Model,
+					TokenName:         tokenName,
+					Quota:             int(quota),
+					Content:           logContent,
+					UpstreamRequestId: meta.UpstreamRequestId,
+					UpstreamAccount:   meta.UpstreamAccount(),
+				}
+				model.RecordConsumeLog(ctx, consumeLog)
+				plugin.OnBilling(ctx, consumeLog)
+				model.UpdateUserUsedQuotaAndRequestCount(meta.UserId, quota)
+				model.UpdateChannelUsedQuota(channelId, quota)
+			}
+		})
 	}(c.Request.Context())
 
 	// do response
-	_, respErr := adaptor.DoResponse(c, resp, meta)
+	_, respErr = adaptor.DoResponse(c, resp, meta)
 	if respErr != nil {
 		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
 		return respErr
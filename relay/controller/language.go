@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/adaptor"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/language"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// languageEnforcement asks the model to answer in the language of the token, the answers without streaming
+// are checked and asked once more with a stronger instruction if they are not in the language
+type languageEnforcement struct {
+	language string
+	check    bool
+}
+
+// newLanguageEnforcement adds the instruction to the request, it returns nil when no language is required
+func newLanguageEnforcement(meta *meta.Meta, textRequest *model.GeneralOpenAIRequest) *languageEnforcement {
+	if meta.Mode != relaymode.ChatCompletions || meta.Language == "" {
+		return nil
+	}
+	textRequest.Messages = append([]model.Message{{
+		Role:    "system",
+		Content: language.Instruction(meta.Language),
+	}}, textRequest.Messages...)
+	meta.Rewritten = true
+	return &languageEnforcement{
+		language: meta.Language,
+		// the streams have been sent to the client when they could be checked
+		check: !textRequest.Stream && language.Checkable(meta.Language),
+	}
+}
+
+// violated tells whether the answer is text which is not in the language, the tool calls are not checked
+func (e *languageEnforcement) violated(body []byte) bool {
+	var response openai.TextResponse
+	if err := json.Unmarshal(body, &response); err != nil || len(response.Choices) == 0 {
+		return false
+	}
+	message := response.Choices[0].Message
+	if len(message.ToolCalls) > 0 {
+		return false
+	}
+	return !language.Matches(e.language, message.StringContent())
+}
+
+func (e *languageEnforcement) relay(c *gin.Context, meta *meta.Meta, textRequest *model.GeneralOpenAIRequest, a adaptor.Adaptor, requestBody io.Reader) (*model.Usage, *model.ErrorWithStatusCode) {
+	ctx := c.Request.Context()
+	writer := &captureWriter{ResponseWriter: c.Writer}
+	usage, respErr := captureResponse(c, meta, a, requestBody, writer)
+	if respErr != nil {
+		return nil, respErr
+	}
+	if !e.violated(writer.body.Bytes()) {
+		c.Data(http.StatusOK, "application/json", writer.body.Bytes())
+		return usage, nil
+	}
+	logger.Infof(ctx, "the answer is not in %s, asking again", e.language)
+	textRequest.Messages = append(textRequest.Messages, model.Message{
+		Role:    "system",
+		Content: language.StrongInstruction(e.language),
+	})
+	requestBody, err := getRequestBody(c, meta, textRequest, a)
+	if err != nil {
+		return nil, openai.ErrorWrapper(err, "convert_request_failed", http.StatusInternalServerError)
+	}
+	retryWriter := &captureWriter{ResponseWriter: c.Writer}
+	retryUsage, respErr := captureResponse(c, meta, a, requestBody, retryWriter)
+	if respErr != nil {
+		// the first answer is better than none, it is billed as well
+		logger.Warnf(ctx, "failed to ask again for the answer in %s: %s", e.language, respErr.Message)
+		c.Data(http.StatusOK, "application/json", writer.body.Bytes())
+		return usage, nil
+	}
+	if e.violated(retryWriter.body.Bytes()) {
+		logger.Warnf(ctx, "the answer asked again is still not in %s", e.language)
+	}
+	var response openai.TextResponse
+	if usage == nil || retryUsage == nil || json.Unmarshal(retryWriter.body.Bytes(), &response) != nil {
+		c.Data(http.StatusOK, "application/json", retryWriter.body.Bytes())
+		return retryUsage, nil
+	}
+	// both answers are billed, so the usage returned is the sum
+	usage.PromptTokens += retryUsage.PromptTokens
+	usage.CompletionTokens += retryUsage.CompletionTokens
+	usage.TotalTokens += retryUsage.TotalTokens
+	response.Usage = *usage
+	c.JSON(http.StatusOK, response)
+	return usage, nil
+}
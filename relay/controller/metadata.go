@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/meta"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// ResponseMetadata is the one_api object added to the responses of the requests with the X-OneAPI-Metadata header,
+// so that the clients can show what each message cost without calling the API again
+type ResponseMetadata struct {
+	Channel        string  `json:"channel"`
+	Latency        int64   `json:"latency"` // unit is millisecond
+	Quota          int64   `json:"quota"`
+	Cost           float64 `json:"cost"`
+	RemainingQuota int64   `json:"remaining_quota"`
+	CacheHit       bool    `json:"cache_hit"`
+	CachedTokens   int     `json:"cached_tokens"`
+}
+
+// metadataChunk carries the metadata of a stream, it is sent before the end of the stream
+type metadataChunk struct {
+	openai.ChatCompletionsStreamResponse
+	OneAPI *ResponseMetadata `json:"one_api"`
+}
+
+// maskChannelName keeps the first character of the name of the channel
+func maskChannelName(name string) string {
+	runes := []rune(name)
+	if len(runes) <= 1 {
+		return "***"
+	}
+	return string(runes[0]) + "***"
+}
+
+// setResponseMetadata records the metadata of the request which has been billed quota
+func setResponseMetadata(c *gin.Context, meta *meta.Meta, usage *relaymodel.Usage, quota int64) {
+	channel := c.GetString(ctxkey.ChannelName)
+	if !config.ResponseMetadataChannelVisible {
+		channel = maskChannelName(channel)
+	}
+	metadata := &ResponseMetadata{
+		Channel: channel,
+		Latency: time.Since(meta.StartTime).Milliseconds(),
+		Quota:   quota,
+		Cost:    float64(quota) / config.QuotaPerUnit,
+	}
+	if usage != nil && usage.PromptTokensDetails != nil {
+		metadata.CachedTokens = usage.PromptTokensDetails.CachedTokens
+		metadata.CacheHit = metadata.CachedTokens > 0
+	}
+	remainingQuota, err := model.CacheGetUserQuota(c.Request.Context(), meta.UserId)
+	if err == nil {
+		metadata.RemainingQuota = remainingQuota
+	}
+	c.Set(ctxkey.ResponseMetadata, metadata)
+}
@@ -27,8 +27,10 @@ func RelayProxyHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 	resp, err := adaptor.DoRequest(c, meta, c.Request.Body)
 	if err != nil {
 		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
-		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+		return doRequestError(err)
 	}
+	throttleOnRateLimit(ctx, meta, resp)
+	captureUpstreamRequestId(c, resp)
 
 	// do response
 	_, respErr := adaptor.DoResponse(c, resp, meta)
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/common/render"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// holdStreamDone keeps the end of the stream back for the clients asking for stream_options.include_usage,
+// so that the usage can still be sent when the upstream omits it, and for the streams which may be salvaged
+func holdStreamDone(c *gin.Context, textRequest *model.GeneralOpenAIRequest, relayMode int) {
+	c.Set(ctxkey.StreamDoneHeld, false)
+	c.Set(ctxkey.StreamUsageSent, false)
+	c.Set(ctxkey.StreamInterrupted, false)
+	c.Set(ctxkey.StreamContentSent, false)
+	if !textRequest.Stream {
+		return
+	}
+	if relayMode != relaymode.ChatCompletions && relayMode != relaymode.Completions {
+		return
+	}
+	// the adaptors may turn include_usage on for the upstream, so it is remembered as asked by the client
+	includeUsage := textRequest.StreamOptions != nil && textRequest.StreamOptions.IncludeUsage
+	if !includeUsage && !config.StreamSalvageEnabled && !c.GetBool(ctxkey.MetadataRequested) {
+		return
+	}
+	c.Set(ctxkey.StreamIncludeUsage, includeUsage)
+	c.Set(ctxkey.HoldStreamDone, true)
+}
+
+// finishStream sends the usage counted locally if the upstream did not send it, then ends the stream held back
+func finishStream(c *gin.Context, meta *meta.Meta, usage *model.Usage) {
+	if !c.GetBool(ctxkey.HoldStreamDone) {
+		return
+	}
+	if config.StreamSalvageEnabled && c.GetBool(ctxkey.StreamInterrupted) {
+		// the stream is continued by the relay on another channel
+		return
+	}
+	c.Set(ctxkey.HoldStreamDone, false)
+	if !c.GetBool(ctxkey.StreamDoneHeld) {
+		return
+	}
+	if c.GetBool(ctxkey.StreamIncludeUsage) && usage != nil && !c.GetBool(ctxkey.StreamUsageSent) {
+		object := "chat.completion.chunk"
+		if meta.Mode == relaymode.Completions {
+			object = "text_completion"
+		}
+		_ = render.ObjectData(c, openai.ChatCompletionsStreamResponse{
+			Id:      fmt.Sprintf("chatcmpl-%s", random.GetUUID()),
+			Object:  object,
+			Created: helper.GetTimestamp(),
+			Model:   meta.ActualModelName,
+			Choices: []openai.ChatCompletionsStreamResponseChoice{},
+			Usage:   usage,
+		})
+	}
+	if metadata, ok := c.Get(ctxkey.ResponseMetadata); ok {
+		_ = render.ObjectData(c, metadataChunk{
+			ChatCompletionsStreamResponse: openai.ChatCompletionsStreamResponse{
+				Id:      fmt.Sprintf("chatcmpl-%s", random.GetUUID()),
+				Object:  "chat.completion.chunk",
+				Created: helper.GetTimestamp(),
+				Model:   meta.ActualModelName,
+				Choices: []openai.ChatCompletionsStreamResponseChoice{},
+			},
+			OneAPI: metadata.(*ResponseMetadata),
+		})
+	}
+	render.Done(c)
+}
+
+// isStreamFailedBeforeContent tells whether the upstream dropped the stream before sending any content,
+// the request is not billed then, and the quota pre-consumed for it is refunded.
+// Only the stream handler of the OpenAI compatible channels tracks the interruption, the other channels are billed
+func isStreamFailedBeforeContent(c *gin.Context, meta *meta.Meta) bool {
+	return meta.IsStream && c.GetBool(ctxkey.StreamInterrupted) && !c.GetBool(ctxkey.StreamContentSent)
+}
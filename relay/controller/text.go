@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/relay"
 	"github.com/songquanpeng/one-api/relay/adaptor"
@@ -18,8 +19,10 @@ import (
 	"github.com/songquanpeng/one-api/relay/billing"
 	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
 	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/defaults"
 	"github.com/songquanpeng/one-api/relay/meta"
 	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
 )
 
 func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
@@ -32,6 +35,7 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 		return openai.ErrorWrapper(err, "invalid_text_request", http.StatusBadRequest)
 	}
 	meta.IsStream = textRequest.Stream
+	holdStreamDone(c, textRequest, meta.Mode)
 
 	// map model name
 	meta.OriginModelName = textRequest.Model
@@ -39,9 +43,24 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 	meta.ActualModelName = textRequest.Model
 	// set system prompt if not empty
 	systemPromptReset := setSystemPrompt(ctx, textRequest, meta.ForcedSystemPrompt)
+	if meta.Mode == relaymode.ChatCompletions || meta.Mode == relaymode.Completions {
+		limit := defaults.GetMaxTokens(meta.Config.MaxTokens, meta.ActualModelName, meta.OriginModelName)
+		if defaults.ClampMaxTokens(textRequest, limit) {
+			meta.Rewritten = true
+		}
+	}
 	// get model ratio & group ratio
 	modelRatio := billingratio.GetModelRatio(textRequest.Model, meta.ChannelType)
-	groupRatio := billingratio.GetGroupRatio(meta.Group)
+	groupRatio := billingratio.GetGroupModelRatio(meta.Group, textRequest.Model)
+	if hasFreeRequest(meta) {
+		meta.FreeRequest = true
+		groupRatio = 0
+	}
+	if meta.QuotaFallback {
+		// the token is exhausted, the downgraded requests are free whatever the fallback model costs,
+		// otherwise the token would keep spending without a bound
+		groupRatio = 0
+	}
 	ratio := modelRatio * groupRatio
 	// pre-consume quota
 	promptTokens := getPromptTokens(textRequest, meta.Mode)
@@ -51,12 +70,22 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 		logger.Warnf(ctx, "preConsumeQuota failed: %+v", *bizErr)
 		return bizErr
 	}
+	// the pre-consumed quota is refunded unless the request is billed
+	billed := false
+	defer func() {
+		if !billed {
+			billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		}
+	}()
 
 	adaptor := relay.GetAdaptor(meta.APIType)
 	if adaptor == nil {
 		return openai.ErrorWrapper(fmt.Errorf("invalid api type: %d", meta.APIType), "invalid_api_type", http.StatusBadRequest)
 	}
 	adaptor.Init(meta)
+	enforcement := newLanguageEnforcement(meta, textRequest)
+	search := newWebSearch(c, meta, textRequest)
+	transform := newEmbeddingTransform(meta, textRequest)
 
 	// get request body
 	requestBody, err := getRequestBody(c, meta, textRequest, adaptor)
@@ -64,35 +93,85 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 		return openai.ErrorWrapper(err, "convert_request_failed", http.StatusInternalServerError)
 	}
 
-	// do request
-	resp, err := adaptor.DoRequest(c, meta, requestBody)
-	if err != nil {
-		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
-		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	if meta.DryRun {
+		return dryRun(c, meta, adaptor, requestBody)
 	}
-	if isErrorHappened(meta, resp) {
-		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
-		return RelayErrorHandler(resp)
+
+	completionTokens := textRequest.MaxTokens
+	if textRequest.MaxCompletionTokens != nil {
+		completionTokens = *textRequest.MaxCompletionTokens
+	}
+	if bizErr = waitForChannel(c, meta, promptTokens+completionTokens); bizErr != nil {
+		return bizErr
 	}
 
-	// do response
-	usage, respErr := adaptor.DoResponse(c, resp, meta)
+	var usage *model.Usage
+	var respErr *model.ErrorWithStatusCode
+	if search != nil {
+		usage, respErr = search.relay(c, meta, textRequest, adaptor, requestBody)
+	} else if transform != nil {
+		usage, respErr = transform.relay(c, meta, adaptor, requestBody)
+	} else if enforcement != nil && enforcement.check {
+		usage, respErr = enforcement.relay(c, meta, textRequest, adaptor, requestBody)
+	} else {
+		// do request
+		resp, bizErr := doTextRequest(c, meta, adaptor, requestBody)
+		if bizErr != nil {
+			return bizErr
+		}
+		// do response
+		usage, respErr = adaptor.DoResponse(c, resp, meta)
+	}
 	if respErr != nil {
 		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
-		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		finishStream(c, meta, nil)
 		return respErr
 	}
+	if isStreamFailedBeforeContent(c, meta) {
+		logger.Warnf(ctx, "the stream of channel #%d failed before any content, the request is not billed", meta.ChannelId)
+		finishStream(c, meta, nil)
+		return nil
+	}
 	// post-consume quota
-	go postConsumeQuota(ctx, usage, meta, textRequest, ratio, preConsumedQuota, modelRatio, groupRatio, systemPromptReset)
+	billed = true
+	c.Set(ctxkey.RelayUsage, usage)
+	if c.GetBool(ctxkey.MetadataRequested) {
+		// the request is billed before the response ends, to tell the client what it has been charged
+		quota := postConsumeQuota(ctx, usage, meta, textRequest, ratio, preConsumedQuota, modelRatio, groupRatio, systemPromptReset)
+		setResponseMetadata(c, meta, usage, quota)
+		finishStream(c, meta, usage)
+		return nil
+	}
+	finishStream(c, meta, usage)
+	billing.Go(func() {
+		postConsumeQuota(ctx, usage, meta, textRequest, ratio, preConsumedQuota, modelRatio, groupRatio, systemPromptReset)
+	})
 	return nil
 }
 
+func doTextRequest(c *gin.Context, meta *meta.Meta, adaptor adaptor.Adaptor, requestBody io.Reader) (*http.Response, *model.ErrorWithStatusCode) {
+	ctx := c.Request.Context()
+	resp, err := adaptor.DoRequest(c, meta, requestBody)
+	if err != nil {
+		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
+		return nil, doRequestError(err)
+	}
+	throttleOnRateLimit(ctx, meta, resp)
+	meta.UpstreamRequestId = captureUpstreamRequestId(c, resp)
+	if isErrorHappened(meta, resp) {
+		return nil, RelayErrorHandler(resp)
+	}
+	return resp, nil
+}
+
 func getRequestBody(c *gin.Context, meta *meta.Meta, textRequest *model.GeneralOpenAIRequest, adaptor adaptor.Adaptor) (io.Reader, error) {
 	if !config.EnforceIncludeUsage &&
 		meta.APIType == apitype.OpenAI &&
 		meta.OriginModelName == meta.ActualModelName &&
 		meta.ChannelType != channeltype.Baichuan &&
-		meta.ForcedSystemPrompt == "" {
+		meta.ForcedSystemPrompt == "" &&
+		textRequest.Thinking == nil &&
+		!meta.Rewritten {
 		// no need to convert request for openai
 		return c.Request.Body, nil
 	}
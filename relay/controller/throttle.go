@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/meta"
+)
+
+// maxThrottle bounds the wait asked by an upstream
+const maxThrottle = time.Hour
+
+// throttleUntil reads when the upstream accepts requests again, from the Retry-After header of a rejected request,
+// or from the rate limit headers of openai and anthropic once a limit is used up, zero means no throttling
+func throttleUntil(resp *http.Response, now time.Time) time.Time {
+	header := resp.Header
+	var wait time.Duration
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if value := header.Get("Retry-After-Ms"); value != "" {
+			if ms, err := strconv.ParseFloat(value, 64); err == nil {
+				wait = time.Duration(ms * float64(time.Millisecond))
+			}
+		} else if value := header.Get("Retry-After"); value != "" {
+			if seconds, err := strconv.Atoi(value); err == nil {
+				wait = time.Duration(seconds) * time.Second
+			} else if at, err := http.ParseTime(value); err == nil {
+				wait = at.Sub(now)
+			}
+		}
+	}
+	for _, kind := range []string{"requests", "tokens"} {
+		if header.Get("X-Ratelimit-Remaining-"+kind) == "0" {
+			if reset, err := time.ParseDuration(header.Get("X-Ratelimit-Reset-" + kind)); err == nil && reset > wait {
+				wait = reset
+			}
+		}
+		if header.Get("Anthropic-Ratelimit-"+kind+"-Remaining") == "0" {
+			if at, err := time.Parse(time.RFC3339, header.Get("Anthropic-Ratelimit-"+kind+"-Reset")); err == nil && at.Sub(now) > wait {
+				wait = at.Sub(now)
+			}
+		}
+	}
+	if wait <= 0 {
+		return time.Time{}
+	}
+	if wait > maxThrottle {
+		wait = maxThrottle
+	}
+	return now.Add(wait)
+}
+
+// throttleOnRateLimit makes the channel selection skip the channel until the upstream accepts requests again
+func throttleOnRateLimit(ctx context.Context, meta *meta.Meta, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	if until := throttleUntil(resp, time.Now()); !until.IsZero() {
+		logger.Warnf(ctx, "channel #%d is rate limited by the upstream until %s", meta.ChannelId, until.Format(time.RFC3339))
+		model.ThrottleChannel(meta.ChannelId, until)
+	}
+}
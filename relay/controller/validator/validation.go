@@ -24,6 +24,11 @@ func ValidateTextRequest(textRequest *model.GeneralOpenAIRequest, relayMode int)
 			return errors.New("field messages is required")
 		}
 	case relaymode.Embeddings:
+		switch textRequest.EncodingFormat {
+		case "", "float", "base64", "float16", "int8":
+		default:
+			return errors.New("field encoding_format must be one of float, base64, float16 and int8")
+		}
 	case relaymode.Moderations:
 		if textRequest.Input == "" {
 			return errors.New("field input is required")
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/render"
+	"github.com/songquanpeng/one-api/relay/adaptor"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+	"github.com/songquanpeng/one-api/relay/websearch"
+)
+
+// webSearch serves the web_search tool for the channels which do not support it, the model is asked without
+// streaming until it stops searching, then the answer is sent to the client as it asked for it
+type webSearch struct {
+	provider     websearch.Provider
+	limit        int
+	stream       bool
+	includeUsage bool
+}
+
+// newWebSearch rewrites the request for the web search served by the gateway, it returns nil
+// when the request does not ask for it, the channel supports it or no provider is configured
+func newWebSearch(c *gin.Context, meta *meta.Meta, textRequest *model.GeneralOpenAIRequest) *webSearch {
+	if meta.Mode != relaymode.ChatCompletions || meta.Config.NativeWebSearch || !websearch.Requested(textRequest) {
+		return nil
+	}
+	provider := websearch.GetProvider()
+	if provider == nil {
+		return nil
+	}
+	search := &webSearch{
+		provider:     provider,
+		limit:        websearch.Rewrite(textRequest),
+		stream:       textRequest.Stream,
+		includeUsage: textRequest.StreamOptions != nil && textRequest.StreamOptions.IncludeUsage,
+	}
+	textRequest.Stream = false
+	textRequest.StreamOptions = nil
+	meta.IsStream = false
+	meta.Rewritten = true
+	c.Set(ctxkey.HoldStreamDone, false)
+	return search
+}
+
+func (s *webSearch) relay(c *gin.Context, meta *meta.Meta, textRequest *model.GeneralOpenAIRequest, a adaptor.Adaptor, requestBody io.Reader) (*model.Usage, *model.ErrorWithStatusCode) {
+	ctx := c.Request.Context()
+	usage := &model.Usage{}
+	for round := 0; ; round++ {
+		if round > 0 {
+			var err error
+			if requestBody, err = getRequestBody(c, meta, textRequest, a); err != nil {
+				return nil, openai.ErrorWrapper(err, "convert_request_failed", http.StatusInternalServerError)
+			}
+		}
+		writer := &captureWriter{ResponseWriter: c.Writer}
+		roundUsage, respErr := captureResponse(c, meta, a, requestBody, writer)
+		if respErr != nil {
+			return nil, respErr
+		}
+		if roundUsage != nil {
+			usage.PromptTokens += roundUsage.PromptTokens
+			usage.CompletionTokens += roundUsage.CompletionTokens
+			usage.TotalTokens += roundUsage.TotalTokens
+		}
+		var response openai.TextResponse
+		if err := json.Unmarshal(writer.body.Bytes(), &response); err != nil || len(response.Choices) == 0 {
+			return nil, openai.ErrorWrapper(errors.New("invalid response of the web search round"), "web_search_failed", http.StatusInternalServerError)
+		}
+		message := response.Choices[0].Message
+		var calls, others []model.Tool
+		for _, toolCall := range message.ToolCalls {
+			if websearch.IsCall(toolCall) {
+				calls = append(calls, toolCall)
+			} else {
+				others = append(others, toolCall)
+			}
+		}
+		// the calls of the tools of the client are returned to it, the searches along them are dropped
+		if len(calls) == 0 || len(others) > 0 || round == websearch.MaxRounds {
+			response.Choices[0].Message.ToolCalls = others
+			if len(others) == 0 && response.Choices[0].FinishReason == "tool_calls" {
+				response.Choices[0].FinishReason = "stop"
+			}
+			response.Usage = *usage
+			meta.IsStream = s.stream
+			s.respond(c, &response)
+			return usage, nil
+		}
+		logger.Infof(ctx, "web search round %d: %d searches", round+1, len(calls))
+		textRequest.Messages = append(textRequest.Messages, message)
+		for _, toolCall := range calls {
+			textRequest.Messages = append(textRequest.Messages, model.Message{
+				Role:       "tool",
+				ToolCallId: toolCall.Id,
+				Content:    websearch.Run(ctx, s.provider, toolCall, s.limit),
+			})
+		}
+		if round+1 == websearch.MaxRounds {
+			websearch.Exhaust(textRequest)
+		}
+	}
+}
+
+func (s *webSearch) respond(c *gin.Context, response *openai.TextResponse) {
+	if !s.stream {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	common.SetEventStreamHeaders(c)
+	choice := response.Choices[0]
+	for i := range choice.ToolCalls {
+		index := i
+		choice.ToolCalls[i].Index = &index
+	}
+	choice.Role = "assistant"
+	_ = render.ObjectData(c, openai.ChatCompletionsStreamResponse{
+		Id:      response.Id,
+		Object:  "chat.completion.chunk",
+		Created: response.Created,
+		Model:   response.Model,
+		Choices: []openai.ChatCompletionsStreamResponseChoice{{
+			Delta:        choice.Message,
+			FinishReason: &choice.FinishReason,
+		}},
+	})
+	if s.includeUsage {
+		_ = render.ObjectData(c, openai.ChatCompletionsStreamResponse{
+			Id:      response.Id,
+			Object:  "chat.completion.chunk",
+			Created: response.Created,
+			Model:   response.Model,
+			Choices: []openai.ChatCompletionsStreamResponseChoice{},
+			Usage:   &response.Usage,
+		})
+	}
+	render.Done(c)
+}
@@ -0,0 +1,45 @@
+package defaults
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+var modelContextWindowsLock sync.RWMutex
+
+// ModelContextWindows are the tokens the models take at most, the prompt and the answer together, the prompts
+// longer than that may be condensed on request, see LongContextSummaryModel
+var ModelContextWindows = map[string]int{}
+
+func ModelContextWindows2JSONString() string {
+	modelContextWindowsLock.RLock()
+	defer modelContextWindowsLock.RUnlock()
+	jsonBytes, err := json.Marshal(ModelContextWindows)
+	if err != nil {
+		logger.SysError("error marshalling model context windows: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateModelContextWindowsByJSONString(jsonStr string) error {
+	modelContextWindows := make(map[string]int)
+	if err := json.Unmarshal([]byte(jsonStr), &modelContextWindows); err != nil {
+		return err
+	}
+	modelContextWindowsLock.Lock()
+	defer modelContextWindowsLock.Unlock()
+	ModelContextWindows = modelContextWindows
+	return nil
+}
+
+// GetContextWindow returns the context window of the model, 0 if it is unknown
+func GetContextWindow(name string) int {
+	modelContextWindowsLock.RLock()
+	defer modelContextWindowsLock.RUnlock()
+	if window := ModelContextWindows[name]; window > 0 {
+		return window
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+// Package defaults fills the parameters that the client did not set with the defaults of the token or the user group
+package defaults
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/jsonedit"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+type Defaults struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+	// SystemPrompt is prepended to the messages, such as a guardrail message
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	// Language is the language the chat completions must be answered in, such as zh, it is enforced by the relay
+	Language string `json:"language,omitempty"`
+}
+
+var groupDefaultsLock sync.RWMutex
+var GroupDefaults = map[string]Defaults{}
+
+func GroupDefaults2JSONString() string {
+	groupDefaultsLock.RLock()
+	defer groupDefaultsLock.RUnlock()
+	jsonBytes, err := json.Marshal(GroupDefaults)
+	if err != nil {
+		logger.SysError("error marshalling group defaults: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupDefaultsByJSONString(jsonStr string) error {
+	groupDefaults := make(map[string]Defaults)
+	if err := json.Unmarshal([]byte(jsonStr), &groupDefaults); err != nil {
+		return err
+	}
+	groupDefaultsLock.Lock()
+	defer groupDefaultsLock.Unlock()
+	GroupDefaults = groupDefaults
+	return nil
+}
+
+func GetGroupDefaults(group string) Defaults {
+	groupDefaultsLock.RLock()
+	defer groupDefaultsLock.RUnlock()
+	return GroupDefaults[group]
+}
+
+// Parse parses the defaults saved in a token, empty string means no defaults
+func Parse(jsonStr string) (Defaults, error) {
+	var d Defaults
+	if jsonStr == "" {
+		return d, nil
+	}
+	err := json.Unmarshal([]byte(jsonStr), &d)
+	return d, err
+}
+
+// IsEmpty tells whether there is nothing to fill in the request, the language is not filled but enforced
+func (d Defaults) IsEmpty() bool {
+	return d.Temperature == nil && d.TopP == nil && d.MaxTokens == nil && d.SystemPrompt == ""
+}
+
+func setIfAbsent(request *jsonedit.Object, key string, value any) error {
+	if request.Has(key) {
+		return nil
+	}
+	return request.Set(key, value)
+}
+
+// Apply fills the request with the defaults, the token defaults take precedence over the group defaults,
+// the system prompts of both are prepended with the group one first
+func Apply(request *jsonedit.Object, token Defaults, group Defaults) error {
+	for _, d := range []Defaults{token, group} {
+		if d.Temperature != nil {
+			if err := setIfAbsent(request, "temperature", *d.Temperature); err != nil {
+				return err
+			}
+		}
+		if d.TopP != nil {
+			if err := setIfAbsent(request, "top_p", *d.TopP); err != nil {
+				return err
+			}
+		}
+		if d.MaxTokens != nil && !request.Has("max_completion_tokens") {
+			if err := setIfAbsent(request, "max_tokens", *d.MaxTokens); err != nil {
+				return err
+			}
+		}
+	}
+	var prepended []any
+	for _, prompt := range []string{group.SystemPrompt, token.SystemPrompt} {
+		if prompt != "" {
+			prepended = append(prepended, map[string]any{"role": "system", "content": prompt})
+		}
+	}
+	if len(prepended) == 0 {
+		return nil
+	}
+	if messages, ok := request.Get("messages"); !ok || messages[0] != '[' {
+		// not a chat request, e.g. a completion one
+		return nil
+	}
+	return request.Prepend("messages", prepended...)
+}
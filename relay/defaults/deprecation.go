@@ -0,0 +1,55 @@
+package defaults
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+var modelDeprecationsLock sync.RWMutex
+
+// ModelDeprecations maps the retired models to the models replacing them, the requests for a retired model
+// are sent to its replacement instead of failing
+var ModelDeprecations = map[string]string{}
+
+func ModelDeprecations2JSONString() string {
+	modelDeprecationsLock.RLock()
+	defer modelDeprecationsLock.RUnlock()
+	jsonBytes, err := json.Marshal(ModelDeprecations)
+	if err != nil {
+		logger.SysError("error marshalling model deprecations: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateModelDeprecationsByJSONString(jsonStr string) error {
+	modelDeprecations := make(map[string]string)
+	if err := json.Unmarshal([]byte(jsonStr), &modelDeprecations); err != nil {
+		return err
+	}
+	modelDeprecationsLock.Lock()
+	defer modelDeprecationsLock.Unlock()
+	ModelDeprecations = modelDeprecations
+	return nil
+}
+
+// GetModelReplacement follows the replacements of the model, so that a replacement retired in turn is replaced
+// as well, it returns false when the model is not retired
+func GetModelReplacement(name string) (string, bool) {
+	modelDeprecationsLock.RLock()
+	defer modelDeprecationsLock.RUnlock()
+	replacement, ok := ModelDeprecations[name]
+	if !ok || replacement == "" || replacement == name {
+		return name, false
+	}
+	// a cycle of replacements stops after every model of the table has been visited
+	for i := 0; i < len(ModelDeprecations); i++ {
+		next, ok := ModelDeprecations[replacement]
+		if !ok || next == "" || next == replacement || next == name {
+			break
+		}
+		replacement = next
+	}
+	return replacement, true
+}
@@ -0,0 +1,85 @@
+package defaults
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+var errorMessagesLock sync.RWMutex
+
+// ErrorMessages replaces the messages of the relay errors returned to the clients, so that they read a message
+// of ours instead of the one of the upstream. The keys match an error by code:<code>, type:<type>,
+// status:<status code>, or * for any error not raised by one api itself, in this order;
+// the values are the messages by language, e.g. {"en": "...", "zh-CN": "..."}
+var ErrorMessages = map[string]map[string]string{}
+
+func ErrorMessages2JSONString() string {
+	errorMessagesLock.RLock()
+	defer errorMessagesLock.RUnlock()
+	jsonBytes, err := json.Marshal(ErrorMessages)
+	if err != nil {
+		logger.SysError("error marshalling error messages: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateErrorMessagesByJSONString(jsonStr string) error {
+	errorMessages := make(map[string]map[string]string)
+	if err := json.Unmarshal([]byte(jsonStr), &errorMessages); err != nil {
+		return err
+	}
+	errorMessagesLock.Lock()
+	defer errorMessagesLock.Unlock()
+	ErrorMessages = errorMessages
+	return nil
+}
+
+// localize picks the message of lang, then the English one, then the first one by language
+func localize(messages map[string]string, lang string) (string, bool) {
+	if message, ok := messages[lang]; ok {
+		return message, true
+	}
+	if message, ok := messages["en"]; ok {
+		return message, true
+	}
+	langs := make([]string, 0, len(messages))
+	for l := range messages {
+		langs = append(langs, l)
+	}
+	if len(langs) == 0 {
+		return "", false
+	}
+	sort.Strings(langs)
+	return messages[langs[0]], true
+}
+
+// GetErrorMessage returns the message replacing the one of the error for a client speaking lang
+func GetErrorMessage(code any, errType string, statusCode int, lang string) (string, bool) {
+	errorMessagesLock.RLock()
+	defer errorMessagesLock.RUnlock()
+	if len(ErrorMessages) == 0 {
+		return "", false
+	}
+	keys := make([]string, 0, 4)
+	if code != nil && fmt.Sprint(code) != "" {
+		keys = append(keys, "code:"+fmt.Sprint(code))
+	}
+	if errType != "" {
+		keys = append(keys, "type:"+errType)
+	}
+	keys = append(keys, "status:"+strconv.Itoa(statusCode))
+	if errType != "one_api_error" {
+		keys = append(keys, "*")
+	}
+	for _, key := range keys {
+		if messages, ok := ErrorMessages[key]; ok {
+			return localize(messages, lang)
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,84 @@
+package defaults
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+var modelMaxTokensLock sync.RWMutex
+
+// ModelMaxTokens caps the tokens of the answers of the models, to contain the worst-case cost and latency
+var ModelMaxTokens = map[string]int{}
+
+func ModelMaxTokens2JSONString() string {
+	modelMaxTokensLock.RLock()
+	defer modelMaxTokensLock.RUnlock()
+	jsonBytes, err := json.Marshal(ModelMaxTokens)
+	if err != nil {
+		logger.SysError("error marshalling model max tokens: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateModelMaxTokensByJSONString(jsonStr string) error {
+	modelMaxTokens := make(map[string]int)
+	if err := json.Unmarshal([]byte(jsonStr), &modelMaxTokens); err != nil {
+		return err
+	}
+	modelMaxTokensLock.Lock()
+	defer modelMaxTokensLock.Unlock()
+	ModelMaxTokens = modelMaxTokens
+	return nil
+}
+
+// GetMaxTokens returns the smallest of the limits of the channel and of the models, 0 means no limit
+func GetMaxTokens(channelLimit int, modelNames ...string) int {
+	limit := channelLimit
+	modelMaxTokensLock.RLock()
+	defer modelMaxTokensLock.RUnlock()
+	for _, name := range modelNames {
+		if modelLimit := ModelMaxTokens[name]; modelLimit > 0 && (limit <= 0 || modelLimit < limit) {
+			limit = modelLimit
+		}
+	}
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// isReasoningModel tells the models of OpenAI such as o1 and o3-mini, which take max_completion_tokens only
+func isReasoningModel(name string) bool {
+	return len(name) > 1 && name[0] == 'o' && unicode.IsDigit(rune(name[1])) && !strings.Contains(name, "-audio")
+}
+
+// ClampMaxTokens lowers max_tokens and max_completion_tokens to the limit, it sets the limit
+// when the request sets neither and reports whether the request is changed
+func ClampMaxTokens(request *model.GeneralOpenAIRequest, limit int) bool {
+	if limit <= 0 {
+		return false
+	}
+	changed := false
+	if request.MaxCompletionTokens != nil && *request.MaxCompletionTokens > limit {
+		request.MaxCompletionTokens = &limit
+		changed = true
+	}
+	if request.MaxTokens > limit {
+		request.MaxTokens = limit
+		changed = true
+	}
+	if request.MaxTokens == 0 && request.MaxCompletionTokens == nil {
+		if isReasoningModel(request.Model) {
+			request.MaxCompletionTokens = &limit
+		} else {
+			request.MaxTokens = limit
+		}
+		changed = true
+	}
+	return changed
+}
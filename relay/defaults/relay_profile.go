@@ -0,0 +1,87 @@
+package defaults
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// ReasoningClass is the key of the profile of the reasoning models which have no profile of their own
+const ReasoningClass = "@reasoning"
+
+// RelayProfile is how long the upstream requests of a model may take and how many times they are retried,
+// the reasoning models think for minutes before answering and a retry pays for the thinking again
+type RelayProfile struct {
+	// Timeout in seconds replaces RELAY_TIMEOUT for the model, 0 to keep it
+	Timeout int `json:"timeout,omitempty"`
+	// RetryTimes replaces the RetryTimes option for the model
+	RetryTimes *int `json:"retry_times,omitempty"`
+}
+
+var modelRelayProfilesLock sync.RWMutex
+
+// ModelRelayProfiles are the profiles by the model name, or by the class of the models such as ReasoningClass
+var ModelRelayProfiles = map[string]RelayProfile{}
+
+func ModelRelayProfiles2JSONString() string {
+	modelRelayProfilesLock.RLock()
+	defer modelRelayProfilesLock.RUnlock()
+	jsonBytes, err := json.Marshal(ModelRelayProfiles)
+	if err != nil {
+		logger.SysError("error marshalling model relay profiles: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateModelRelayProfilesByJSONString(jsonStr string) error {
+	modelRelayProfiles := make(map[string]RelayProfile)
+	if err := json.Unmarshal([]byte(jsonStr), &modelRelayProfiles); err != nil {
+		return err
+	}
+	for name, profile := range modelRelayProfiles {
+		if profile.Timeout < 0 || (profile.RetryTimes != nil && *profile.RetryTimes < 0) {
+			return fmt.Errorf("relay profile of %s must not be negative", name)
+		}
+	}
+	modelRelayProfilesLock.Lock()
+	defer modelRelayProfilesLock.Unlock()
+	ModelRelayProfiles = modelRelayProfiles
+	return nil
+}
+
+// IsReasoningModel tells the models which think before answering, such as o1, o3-mini, deepseek-reasoner,
+// DeepSeek-R1 and QwQ, by their names
+func IsReasoningModel(name string) bool {
+	name = strings.ToLower(name)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return isReasoningModel(name) ||
+		strings.Contains(name, "reasoner") ||
+		strings.Contains(name, "thinking") ||
+		strings.HasPrefix(name, "qwq") ||
+		strings.HasPrefix(name, "r1") ||
+		strings.Contains(name, "-r1")
+}
+
+// GetRelayProfile returns the profile of the first of the models having one, or else the profile of their class
+func GetRelayProfile(modelNames ...string) (RelayProfile, bool) {
+	modelRelayProfilesLock.RLock()
+	defer modelRelayProfilesLock.RUnlock()
+	for _, name := range modelNames {
+		if profile, ok := ModelRelayProfiles[name]; ok {
+			return profile, true
+		}
+	}
+	if profile, ok := ModelRelayProfiles[ReasoningClass]; ok {
+		for _, name := range modelNames {
+			if IsReasoningModel(name) {
+				return profile, true
+			}
+		}
+	}
+	return RelayProfile{}, false
+}
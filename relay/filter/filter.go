@@ -0,0 +1,388 @@
+// Package filter post-processes the text generated by the upstream, such as removing the reasoning of the models,
+// the same rules are applied to the streaming and the non-streaming responses
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+const (
+	TypeStripThink     = "strip_think"      // removes the <think>...</think> blocks
+	TypeStripJSONFence = "strip_json_fence" // removes the markdown fence around a json answer
+	TypeRemove         = "remove"           // removes the text in Pattern, such as a watermark
+	TypeRegex          = "regex"            // replaces the matches of Pattern by Replacement, line by line
+	TypeAppend         = "append"           // appends Text to the answer, such as an attribution line
+	TypeWatermark      = "watermark"        // embeds Text into the answer as invisible characters
+)
+
+type Rule struct {
+	Type        string `json:"type"`
+	Pattern     string `json:"pattern,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	Text        string `json:"text,omitempty"`
+}
+
+var groupFiltersLock sync.RWMutex
+var GroupFilters = map[string][]Rule{}
+
+func GroupFilters2JSONString() string {
+	groupFiltersLock.RLock()
+	defer groupFiltersLock.RUnlock()
+	jsonBytes, err := json.Marshal(GroupFilters)
+	if err != nil {
+		logger.SysError("error marshalling group response filters: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupFiltersByJSONString(jsonStr string) error {
+	groupFilters := make(map[string][]Rule)
+	if err := json.Unmarshal([]byte(jsonStr), &groupFilters); err != nil {
+		return err
+	}
+	for group, rules := range groupFilters {
+		if _, err := Compile(rules); err != nil {
+			return fmt.Errorf("group %s: %w", group, err)
+		}
+	}
+	groupFiltersLock.Lock()
+	defer groupFiltersLock.Unlock()
+	GroupFilters = groupFilters
+	return nil
+}
+
+func GetGroupFilters(group string) []Rule {
+	groupFiltersLock.RLock()
+	defer groupFiltersLock.RUnlock()
+	return GroupFilters[group]
+}
+
+type Filter struct {
+	rules   []Rule
+	regexps []*regexp.Regexp
+}
+
+// Compile checks the rules, the rules are applied in order
+func Compile(rules []Rule) (*Filter, error) {
+	f := &Filter{rules: rules, regexps: make([]*regexp.Regexp, len(rules))}
+	for i, rule := range rules {
+		switch rule.Type {
+		case TypeStripThink, TypeStripJSONFence:
+		case TypeRemove:
+			if rule.Pattern == "" {
+				return nil, fmt.Errorf("rule %d: pattern is empty", i)
+			}
+		case TypeRegex:
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i, err)
+			}
+			f.regexps[i] = re
+		case TypeAppend, TypeWatermark:
+			if rule.Text == "" {
+				return nil, fmt.Errorf("rule %d: text is empty", i)
+			}
+		default:
+			return nil, fmt.Errorf("rule %d: unknown type %q", i, rule.Type)
+		}
+	}
+	return f, nil
+}
+
+func (f *Filter) IsEmpty() bool {
+	return f == nil || len(f.rules) == 0
+}
+
+// Apply filters a complete text
+func (f *Filter) Apply(text string) string {
+	s := f.NewStream()
+	return s.Push(text) + s.Flush()
+}
+
+// NewStream returns the state of the filter for a text received piece by piece
+func (f *Filter) NewStream() *Stream {
+	s := &Stream{}
+	for i, rule := range f.rules {
+		switch rule.Type {
+		case TypeStripThink:
+			s.stages = append(s.stages, &thinkStage{})
+		case TypeStripJSONFence:
+			s.stages = append(s.stages, &fenceStage{})
+		case TypeRemove:
+			pattern := rule.Pattern
+			s.stages = append(s.stages, &lineStage{replace: func(text string) string {
+				return strings.ReplaceAll(text, pattern, "")
+			}})
+		case TypeRegex:
+			re, replacement := f.regexps[i], rule.Replacement
+			s.stages = append(s.stages, &lineStage{replace: func(text string) string {
+				return re.ReplaceAllString(text, replacement)
+			}})
+		case TypeAppend:
+			s.stages = append(s.stages, &appendStage{text: rule.Text})
+		case TypeWatermark:
+			s.stages = append(s.stages, &watermarkStage{mark: Watermark(rule.Text)})
+		}
+	}
+	return s
+}
+
+// stage is a rule applied to a stream, it holds back the text which may still be matched by the coming text
+type stage interface {
+	push(text string) string
+	flush() string
+}
+
+type Stream struct {
+	stages []stage
+}
+
+// Push returns the part of the text which can already be sent
+func (s *Stream) Push(text string) string {
+	for _, st := range s.stages {
+		text = st.push(text)
+	}
+	return text
+}
+
+// Flush returns the text held back, at the end of the stream
+func (s *Stream) Flush() string {
+	text := ""
+	for _, st := range s.stages {
+		text = st.push(text) + st.flush()
+	}
+	return text
+}
+
+// partialSuffix returns the length of the longest suffix of text which is a prefix of tag
+func partialSuffix(text string, tag string) int {
+	for n := len(tag) - 1; n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+type thinkStage struct {
+	pending     string
+	thinking    bool
+	trimLeading bool
+}
+
+func (st *thinkStage) push(text string) string {
+	st.pending += text
+	out := ""
+	for {
+		if st.thinking {
+			if i := strings.Index(st.pending, "</think>"); i >= 0 {
+				st.pending = st.pending[i+len("</think>"):]
+				st.thinking = false
+				st.trimLeading = true
+				continue
+			}
+			st.pending = st.pending[len(st.pending)-partialSuffix(st.pending, "</think>"):]
+			return out
+		}
+		if st.trimLeading {
+			st.pending = strings.TrimLeft(st.pending, " \t\r\n")
+			if st.pending == "" {
+				return out
+			}
+			st.trimLeading = false
+		}
+		if i := strings.Index(st.pending, "<think>"); i >= 0 {
+			out += st.pending[:i]
+			st.pending = st.pending[i+len("<think>"):]
+			st.thinking = true
+			continue
+		}
+		n := len(st.pending) - partialSuffix(st.pending, "<think>")
+		out += st.pending[:n]
+		st.pending = st.pending[n:]
+		return out
+	}
+}
+
+func (st *thinkStage) flush() string {
+	// an unterminated block is dropped as well
+	if st.thinking {
+		st.pending = ""
+	}
+	out := st.pending
+	st.pending = ""
+	return out
+}
+
+const (
+	fenceUndecided = iota
+	fenceInside
+	fenceAbsent
+)
+
+type fenceStage struct {
+	pending string
+	state   int
+}
+
+// mayBeClosingFence tells if the text after the last line break can still turn out to be the closing fence
+func mayBeClosingFence(tail string) bool {
+	return strings.HasPrefix("```", tail)
+}
+
+func (st *fenceStage) push(text string) string {
+	switch st.state {
+	case fenceAbsent:
+		return text
+	case fenceUndecided:
+		st.pending += text
+		trimmed := strings.TrimLeft(st.pending, " \t\r\n")
+		if len(trimmed) < 3 && strings.HasPrefix("```", trimmed) {
+			return ""
+		}
+		if !strings.HasPrefix(trimmed, "```") {
+			st.state = fenceAbsent
+			out := st.pending
+			st.pending = ""
+			return out
+		}
+		i := strings.Index(trimmed, "\n")
+		if i < 0 {
+			return ""
+		}
+		if lang := strings.TrimSpace(trimmed[3:i]); lang != "" && lang != "json" {
+			st.state = fenceAbsent
+			out := st.pending
+			st.pending = ""
+			return out
+		}
+		st.state = fenceInside
+		st.pending = ""
+		text = trimmed[i+1:]
+	}
+	st.pending += text
+	trimmed := strings.TrimRight(st.pending, " \t\r\n")
+	i := strings.LastIndex(trimmed, "\n")
+	if !mayBeClosingFence(trimmed[i+1:]) {
+		out := st.pending
+		st.pending = ""
+		return out
+	}
+	if i < 0 {
+		return ""
+	}
+	out := st.pending[:i]
+	st.pending = st.pending[i:]
+	return out
+}
+
+func (st *fenceStage) flush() string {
+	out := st.pending
+	st.pending = ""
+	if st.state == fenceInside && strings.TrimSpace(out) == "```" {
+		return ""
+	}
+	return out
+}
+
+// lineStage applies a replacement to complete lines, so that a match is never split between two chunks
+type lineStage struct {
+	pending string
+	replace func(text string) string
+}
+
+func (st *lineStage) push(text string) string {
+	st.pending += text
+	i := strings.LastIndex(st.pending, "\n")
+	if i < 0 {
+		return ""
+	}
+	out := st.replace(st.pending[:i+1])
+	st.pending = st.pending[i+1:]
+	return out
+}
+
+func (st *lineStage) flush() string {
+	out := st.replace(st.pending)
+	st.pending = ""
+	return out
+}
+
+// appendStage adds the text at the end of the answer, an answer without text, such as tool calls, is left as is
+type appendStage struct {
+	text string
+	seen bool
+}
+
+func (st *appendStage) push(text string) string {
+	if text != "" {
+		st.seen = true
+	}
+	return text
+}
+
+func (st *appendStage) flush() string {
+	if !st.seen {
+		return ""
+	}
+	st.seen = false
+	return st.text
+}
+
+// the watermark is the bits of its text written with zero width characters between two word joiners
+const (
+	watermarkZero  = "\u200b"
+	watermarkOne   = "\u200c"
+	watermarkBound = "\u2060"
+)
+
+// Watermark returns the invisible form of the text
+func Watermark(text string) string {
+	var b strings.Builder
+	b.WriteString(watermarkBound)
+	for i := 0; i < len(text); i++ {
+		for bit := 7; bit >= 0; bit-- {
+			if text[i]>>bit&1 == 1 {
+				b.WriteString(watermarkOne)
+			} else {
+				b.WriteString(watermarkZero)
+			}
+		}
+	}
+	b.WriteString(watermarkBound)
+	return b.String()
+}
+
+// watermarkStage inserts the watermark after the first space or line break, so that it is kept when a part of
+// the answer is copied, and nothing is held back in a stream; a short answer without one gets it at the end
+type watermarkStage struct {
+	mark     string
+	seen     bool
+	inserted bool
+}
+
+func (st *watermarkStage) push(text string) string {
+	if st.inserted || text == "" {
+		return text
+	}
+	st.seen = true
+	if i := strings.IndexAny(text, " \n"); i >= 0 {
+		st.inserted = true
+		return text[:i+1] + st.mark + text[i+1:]
+	}
+	return text
+}
+
+func (st *watermarkStage) flush() string {
+	if st.inserted || !st.seen {
+		return ""
+	}
+	st.inserted = true
+	return st.mark
+}
@@ -0,0 +1,64 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// pushByChar filters the text as a stream split into single characters
+func pushByChar(f *Filter, text string) string {
+	s := f.NewStream()
+	out := ""
+	for _, r := range text {
+		out += s.Push(string(r))
+	}
+	return out + s.Flush()
+}
+
+func TestFilter(t *testing.T) {
+	Convey("Compile", t, func() {
+		_, err := Compile([]Rule{{Type: "unknown"}})
+		So(err, ShouldNotBeNil)
+		_, err = Compile([]Rule{{Type: TypeRegex, Pattern: "("}})
+		So(err, ShouldNotBeNil)
+		_, err = Compile([]Rule{{Type: TypeWatermark}})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Watermark", t, func() {
+		mark := Watermark("A")
+		So(mark, ShouldEqual, "\u2060\u200b\u200c\u200b\u200b\u200b\u200b\u200b\u200c\u2060")
+		So(strings.Trim(mark, "\u200b\u200c\u2060"), ShouldBeEmpty)
+	})
+
+	cases := []struct {
+		name  string
+		rules []Rule
+		in    string
+		out   string
+	}{
+		{"strip think", []Rule{{Type: TypeStripThink}}, "<think>\nlet me see\n</think>\n\nHello <b>world</b>", "Hello <b>world</b>"},
+		{"unterminated think", []Rule{{Type: TypeStripThink}}, "Hi<think>still thinking", "Hi"},
+		{"strip json fence", []Rule{{Type: TypeStripJSONFence}}, "```json\n{\"a\": 1}\n```\n", "{\"a\": 1}"},
+		{"keep other fences", []Rule{{Type: TypeStripJSONFence}}, "```go\nfunc main() {}\n```", "```go\nfunc main() {}\n```"},
+		{"no fence", []Rule{{Type: TypeStripJSONFence}}, "plain `code`", "plain `code`"},
+		{"remove watermark", []Rule{{Type: TypeRemove, Pattern: " [generated by x]"}}, "answer [generated by x]\nmore", "answer\nmore"},
+		{"regex", []Rule{{Type: TypeRegex, Pattern: `(?m)^Disclaimer:.*\n?`}}, "ok\nDisclaimer: none\nbye", "ok\nbye"},
+		{"chained", []Rule{{Type: TypeStripThink}, {Type: TypeStripJSONFence}}, "<think>hmm</think>\n```json\n[1]\n```", "[1]"},
+		{"append", []Rule{{Type: TypeAppend, Text: "\n\n（AI 生成）"}}, "Hello world", "Hello world\n\n（AI 生成）"},
+		{"append nothing to empty", []Rule{{Type: TypeAppend, Text: "\n\n（AI 生成）"}}, "", ""},
+		{"watermark", []Rule{{Type: TypeWatermark, Text: "ai"}}, "Hello world", "Hello " + Watermark("ai") + "world"},
+		{"watermark short", []Rule{{Type: TypeWatermark, Text: "ai"}}, "Hi", "Hi" + Watermark("ai")},
+		{"think before append", []Rule{{Type: TypeStripThink}, {Type: TypeAppend, Text: "!"}}, "<think>x</think>ok", "ok!"},
+	}
+	for _, tc := range cases {
+		Convey(tc.name, t, func() {
+			f, err := Compile(tc.rules)
+			So(err, ShouldBeNil)
+			So(f.Apply(tc.in), ShouldEqual, tc.out)
+			So(pushByChar(f, tc.in), ShouldEqual, tc.out)
+		})
+	}
+}
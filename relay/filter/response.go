@@ -0,0 +1,161 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// marshal keeps the generated html as is, instead of escaping it as json.Marshal does
+func marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// filterChoices filters the content of the choices of a chat or completion response, key is message or delta
+func filterChoices(response map[string]any, key string, filterText func(index int, text string, finished bool) string) bool {
+	choices, ok := response["choices"].([]any)
+	if !ok {
+		return false
+	}
+	changed := false
+	for _, item := range choices {
+		choice, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		index, _ := choice["index"].(float64)
+		finishReason, _ := choice["finish_reason"].(string)
+		if text, ok := choice["text"].(string); ok {
+			choice["text"] = filterText(int(index), text, finishReason != "")
+			changed = true
+			continue
+		}
+		message, ok := choice[key].(map[string]any)
+		if !ok {
+			continue
+		}
+		if content, ok := message["content"].(string); ok {
+			message["content"] = filterText(int(index), content, finishReason != "")
+			changed = true
+		} else if finishReason != "" {
+			// the text held back is sent with the last chunk
+			message["content"] = filterText(int(index), "", true)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// FilterResponseBody filters a non-streaming chat or completion response, other bodies are returned as is
+func FilterResponseBody(f *Filter, body []byte) []byte {
+	if f.IsEmpty() {
+		return body
+	}
+	var response map[string]any
+	if err := json.Unmarshal(body, &response); err != nil {
+		return body
+	}
+	changed := filterChoices(response, "message", func(index int, text string, finished bool) string {
+		return f.Apply(text)
+	})
+	if !changed {
+		return body
+	}
+	newBody, err := marshal(response)
+	if err != nil {
+		return body
+	}
+	return newBody
+}
+
+// ChunkFilter filters the chunks of a streaming chat or completion response,
+// the filter is resolved with the first chunk, as the channel may change when the request is retried
+type ChunkFilter struct {
+	resolve  func() *Filter
+	filter   *Filter
+	resolved bool
+	streams  map[int]*Stream
+	last     map[string]any
+}
+
+func NewChunkFilter(resolve func() *Filter) *ChunkFilter {
+	return &ChunkFilter{resolve: resolve, streams: make(map[int]*Stream)}
+}
+
+func (cf *ChunkFilter) stream(index int) *Stream {
+	s, ok := cf.streams[index]
+	if !ok {
+		s = cf.filter.NewStream()
+		cf.streams[index] = s
+	}
+	return s
+}
+
+func (cf *ChunkFilter) Filter(data string) []string {
+	if !cf.resolved {
+		cf.filter = cf.resolve()
+		cf.resolved = true
+	}
+	if cf.filter.IsEmpty() {
+		return []string{data}
+	}
+	if data == "[DONE]" {
+		return append(cf.flush(), data)
+	}
+	var chunk map[string]any
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return []string{data}
+	}
+	changed := filterChoices(chunk, "delta", func(index int, text string, finished bool) string {
+		s := cf.stream(index)
+		text = s.Push(text)
+		if finished {
+			text += s.Flush()
+			delete(cf.streams, index)
+		}
+		return text
+	})
+	if !changed {
+		return []string{data}
+	}
+	cf.last = chunk
+	newData, err := marshal(chunk)
+	if err != nil {
+		return []string{data}
+	}
+	return []string{string(newData)}
+}
+
+// flush sends the text still held back when the stream ends without a finish reason
+func (cf *ChunkFilter) flush() []string {
+	var chunks []string
+	for index, s := range cf.streams {
+		text := s.Flush()
+		if text == "" || cf.last == nil {
+			continue
+		}
+		chunk := map[string]any{}
+		for _, key := range []string{"id", "object", "created", "model"} {
+			if value, ok := cf.last[key]; ok {
+				chunk[key] = value
+			}
+		}
+		choice := map[string]any{"index": index}
+		if chunk["object"] == "text_completion" {
+			choice["text"] = text
+		} else {
+			choice["delta"] = map[string]any{"content": text}
+		}
+		chunk["choices"] = []any{choice}
+		if data, err := marshal(chunk); err == nil {
+			chunks = append(chunks, string(data))
+		}
+	}
+	cf.streams = make(map[int]*Stream)
+	return chunks
+}
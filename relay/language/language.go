@@ -0,0 +1,125 @@
+// Package language asks the models to answer in the language required by the token, and checks
+// cheaply by the writing system of the answer whether they did
+package language
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+type language struct {
+	name    string
+	scripts []*unicode.RangeTable
+	// required is a script of which the answer must contain at least a letter, such as the kana of Japanese
+	required *unicode.RangeTable
+}
+
+var latin = []*unicode.RangeTable{unicode.Latin}
+
+var languages = map[string]language{
+	"zh": {name: "Chinese", scripts: []*unicode.RangeTable{unicode.Han}},
+	"ja": {name: "Japanese", scripts: []*unicode.RangeTable{unicode.Han, unicode.Hiragana, unicode.Katakana}, required: kana},
+	"ko": {name: "Korean", scripts: []*unicode.RangeTable{unicode.Hangul, unicode.Han}, required: unicode.Hangul},
+	"ru": {name: "Russian", scripts: []*unicode.RangeTable{unicode.Cyrillic}},
+	"uk": {name: "Ukrainian", scripts: []*unicode.RangeTable{unicode.Cyrillic}},
+	"ar": {name: "Arabic", scripts: []*unicode.RangeTable{unicode.Arabic}},
+	"he": {name: "Hebrew", scripts: []*unicode.RangeTable{unicode.Hebrew}},
+	"th": {name: "Thai", scripts: []*unicode.RangeTable{unicode.Thai}},
+	"hi": {name: "Hindi", scripts: []*unicode.RangeTable{unicode.Devanagari}},
+	"el": {name: "Greek", scripts: []*unicode.RangeTable{unicode.Greek}},
+	"en": {name: "English", scripts: latin},
+	"fr": {name: "French", scripts: latin},
+	"de": {name: "German", scripts: latin},
+	"es": {name: "Spanish", scripts: latin},
+	"pt": {name: "Portuguese", scripts: latin},
+	"it": {name: "Italian", scripts: latin},
+	"nl": {name: "Dutch", scripts: latin},
+	"id": {name: "Indonesian", scripts: latin},
+	"vi": {name: "Vietnamese", scripts: latin},
+	"tr": {name: "Turkish", scripts: latin},
+}
+
+var kana = &unicode.RangeTable{R16: []unicode.Range16{{Lo: 0x3040, Hi: 0x30ff, Stride: 1}}}
+
+// minUnits is the size under which an answer is too short to be checked, such as "OK" or a number
+const minUnits = 4
+
+var codePattern = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+
+func lookup(code string) (language, bool) {
+	code = strings.ToLower(code)
+	if base, _, ok := strings.Cut(strings.ReplaceAll(code, "_", "-"), "-"); ok {
+		code = base
+	}
+	l, ok := languages[code]
+	return l, ok
+}
+
+// Name returns the English name of the language code, such as zh-CN, other values are used as they are
+func Name(code string) string {
+	if l, ok := lookup(code); ok {
+		return l.name
+	}
+	return code
+}
+
+func Instruction(code string) string {
+	return "Always write your answer in " + Name(code) + ", whatever the language of the messages, " +
+		"unless you are asked to translate or to write code."
+}
+
+// StrongInstruction is given when the model did not follow the instruction
+func StrongInstruction(code string) string {
+	name := Name(code)
+	return "IMPORTANT: your answer MUST be written entirely in " + name + ". " +
+		"Do not answer in any other language, even if the question or the sources are in another language. " +
+		"Write the answer again in " + name + "."
+}
+
+// Checkable tells whether the answers in the language can be checked, only the known languages are
+func Checkable(code string) bool {
+	_, ok := lookup(code)
+	return ok
+}
+
+// the letters of these scripts are counted one by one, the other scripts are counted by word
+var unspaced = []*unicode.RangeTable{unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai}
+
+// Matches checks the writing system of the text outside the code, a word counts as much as a Chinese
+// character, so that the names and terms in English do not fail the Chinese answers
+func Matches(code string, text string) bool {
+	l, ok := lookup(code)
+	if !ok {
+		return true
+	}
+	text = codePattern.ReplaceAllString(text, " ")
+	target, other := 0, 0
+	hasRequired := l.required == nil
+	inWord := false
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			inWord = false
+			continue
+		}
+		if !hasRequired && unicode.Is(l.required, r) {
+			hasRequired = true
+		}
+		if unicode.In(r, unspaced...) {
+			inWord = false
+		} else if inWord {
+			continue
+		} else {
+			inWord = true
+		}
+		if unicode.In(r, l.scripts...) {
+			target++
+		} else {
+			other++
+		}
+	}
+	if target+other < minUnits {
+		return true
+	}
+	return hasRequired && target >= other
+}
@@ -0,0 +1,29 @@
+package language
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMatches(t *testing.T) {
+	Convey("Name", t, func() {
+		So(Name("zh-CN"), ShouldEqual, "Chinese")
+		So(Name("pt_BR"), ShouldEqual, "Portuguese")
+		So(Name("Klingon"), ShouldEqual, "Klingon")
+		So(Checkable("Klingon"), ShouldBeFalse)
+	})
+	Convey("Matches", t, func() {
+		So(Matches("zh", "退款会在 3 个工作日内到账，可在 App Store 的订单页面查看。"), ShouldBeTrue)
+		So(Matches("zh", "The refund arrives within 3 working days."), ShouldBeFalse)
+		So(Matches("zh", "可以这样写：\n```go\nfmt.Println(\"hello world, this is some code\")\n```"), ShouldBeTrue)
+		So(Matches("zh", "OK"), ShouldBeTrue)
+		So(Matches("ja", "返金は3営業日以内に行われます。"), ShouldBeTrue)
+		So(Matches("ja", "退款会在三个工作日内到账"), ShouldBeFalse)
+		So(Matches("ko", "환불은 3영업일 이내에 처리됩니다."), ShouldBeTrue)
+		So(Matches("en", "The refund arrives within 3 working days."), ShouldBeTrue)
+		So(Matches("en", "退款会在三个工作日内到账"), ShouldBeFalse)
+		So(Matches("ru", "Возврат поступит в течение трёх рабочих дней."), ShouldBeTrue)
+		So(Matches("Klingon", "anything"), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,179 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/client"
+)
+
+// Client talks to an MCP server over the streamable HTTP transport, a session is opened for each operation
+type Client struct {
+	URL     string
+	Headers map[string]string
+
+	sessionId string
+	nextId    int
+}
+
+func (c *Client) post(ctx context.Context, method string, params any, notification bool) (json.RawMessage, error) {
+	request := map[string]any{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		request["params"] = params
+	}
+	id := ""
+	if !notification {
+		c.nextId++
+		id = strconv.Itoa(c.nextId)
+		request["id"] = c.nextId
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	for key, value := range c.Headers {
+		req.Header.Set(key, value)
+	}
+	if c.sessionId != "" {
+		req.Header.Set(SessionIdHeader, c.sessionId)
+	}
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if sessionId := resp.Header.Get(SessionIdHeader); sessionId != "" {
+		c.sessionId = sessionId
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("MCP server responded with status code %d: %s", resp.StatusCode, string(data))
+	}
+	if notification {
+		return nil, nil
+	}
+	var response struct {
+		Id     json.RawMessage `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *Error          `json:"error"`
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		// the server may send requests and notifications before the response
+		found := false
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for !found && scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			if err = json.Unmarshal([]byte(strings.TrimSpace(line[5:])), &response); err == nil && string(response.Id) == id {
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.New("no response in the event stream of the MCP server")
+		}
+	} else if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	return response.Result, nil
+}
+
+func (c *Client) call(ctx context.Context, method string, params any, result any) error {
+	data, err := c.post(ctx, method, params, false)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	var result InitializeResult
+	err := c.call(ctx, "initialize", map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      Implementation{Name: "one-api", Version: common.Version},
+	}, &result)
+	if err != nil {
+		return err
+	}
+	_, err = c.post(ctx, "notifications/initialized", nil, true)
+	return err
+}
+
+// close ends the session, the servers which do not support it are ignored
+func (c *Client) close() {
+	if c.sessionId == "" {
+		return
+	}
+	req, err := http.NewRequest(http.MethodDelete, c.URL, nil)
+	if err != nil {
+		return
+	}
+	for key, value := range c.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set(SessionIdHeader, c.sessionId)
+	if resp, err := client.HTTPClient.Do(req); err == nil {
+		resp.Body.Close()
+	}
+	c.sessionId = ""
+}
+
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	defer c.close()
+	if err := c.initialize(ctx); err != nil {
+		return nil, err
+	}
+	var tools []Tool
+	var cursor string
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var result struct {
+			ListToolsResult
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &result); err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+func (c *Client) CallTool(ctx context.Context, name string, arguments map[string]any) (*CallToolResult, error) {
+	defer c.close()
+	if err := c.initialize(ctx); err != nil {
+		return nil, err
+	}
+	result := &CallToolResult{}
+	if err := c.call(ctx, "tools/call", CallToolParams{Name: name, Arguments: arguments}, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
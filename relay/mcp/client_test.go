@@ -0,0 +1,51 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/client"
+)
+
+func TestClient(t *testing.T) {
+	client.Init()
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request Request
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		methods = append(methods, r.Method+" "+request.Method+" "+r.Header.Get(SessionIdHeader))
+		w.Header().Set(SessionIdHeader, "session")
+		switch request.Method {
+		case "initialize":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":"%s"}}`, request.Id, ProtocolVersion)
+		case "tools/call":
+			// the response is streamed after a notification
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/message\"}\n\n")
+			fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"id\":%s,\"result\":{\"content\":[{\"type\":\"text\",\"text\":\"ok\"}]}}\n\n", request.Id)
+		case "tools/list":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32603,"message":"broken"}}`, request.Id)
+		default:
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer server.Close()
+	c := &Client{URL: server.URL}
+	Convey("CallTool", t, func() {
+		result, err := c.CallTool(context.Background(), "echo", map[string]any{"a": 1})
+		So(err, ShouldBeNil)
+		So(result.Content, ShouldResemble, []Content{{Type: "text", Text: "ok"}})
+		So(methods, ShouldResemble, []string{"POST initialize ", "POST notifications/initialized session", "POST tools/call session", "DELETE  session"})
+	})
+	Convey("ListTools", t, func() {
+		_, err := c.ListTools(context.Background())
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "broken")
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/model"
 	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/defaults"
 	"github.com/songquanpeng/one-api/relay/relaymode"
 )
 
@@ -35,6 +36,21 @@ type Meta struct {
 	PromptTokens       int // only for DoResponse
 	ForcedSystemPrompt string
 	StartTime          time.Time
+	// DryRun responds with the converted upstream request instead of sending it
+	DryRun bool
+	// QuotaFallback means the token is exhausted and the request is downgraded to the fallback model
+	QuotaFallback bool
+	// FreeRequest means the request is covered by a daily free allowance and not billed
+	FreeRequest bool
+	// Rewritten means the request has been changed by the gateway, such as for the web search, so it is always converted
+	Rewritten bool
+	// Language is the language the answer must be in, set by the defaults of the token or the user group
+	Language string
+	// UpstreamRequestId holds the request ids returned by the upstream, recorded in the consume log
+	UpstreamRequestId string
+	// OpenAIOrganization and OpenAIProject are sent in the OpenAI-Organization and OpenAI-Project headers
+	OpenAIOrganization string
+	OpenAIProject      string
 }
 
 func GetByContext(c *gin.Context) *Meta {
@@ -53,6 +69,8 @@ func GetByContext(c *gin.Context) *Meta {
 		RequestURLPath:     c.Request.URL.String(),
 		ForcedSystemPrompt: c.GetString(ctxkey.SystemPrompt),
 		StartTime:          time.Now(),
+		DryRun:             c.GetBool(ctxkey.DryRun),
+		QuotaFallback:      c.GetBool(ctxkey.QuotaFallback),
 	}
 	cfg, ok := c.Get(ctxkey.Config)
 	if ok {
@@ -62,5 +80,35 @@ func GetByContext(c *gin.Context) *Meta {
 		meta.BaseURL = channeltype.ChannelBaseURLs[meta.ChannelType]
 	}
 	meta.APIType = channeltype.ToAPIType(meta.ChannelType)
+	meta.OpenAIOrganization = meta.Config.OpenAIOrganization
+	meta.OpenAIProject = meta.Config.OpenAIProject
+	if meta.ChannelType == channeltype.OpenAI {
+		// the ids of the token are those of the OpenAI account, they are not sent to the other providers
+		if organization := c.GetString(ctxkey.TokenOpenAIOrg); organization != "" {
+			meta.OpenAIOrganization = organization
+		}
+		if project := c.GetString(ctxkey.TokenOpenAIProject); project != "" {
+			meta.OpenAIProject = project
+		}
+	}
+	tokenDefaults, _ := c.Get(ctxkey.TokenDefaults)
+	if d, ok := tokenDefaults.(defaults.Defaults); ok && d.Language != "" {
+		meta.Language = d.Language
+	} else {
+		meta.Language = defaults.GetGroupDefaults(meta.Group).Language
+	}
 	return &meta
 }
+
+// UpstreamAccount returns the organization and the project sent to the upstream as name=value pairs, such as
+// "organization=org-123; project=proj_abc", recorded in the consume log
+func (m *Meta) UpstreamAccount() string {
+	var pairs []string
+	if m.OpenAIOrganization != "" {
+		pairs = append(pairs, "organization="+m.OpenAIOrganization)
+	}
+	if m.OpenAIProject != "" {
+		pairs = append(pairs, "project="+m.OpenAIProject)
+	}
+	return strings.Join(pairs, "; ")
+}
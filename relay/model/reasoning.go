@@ -0,0 +1,58 @@
+package model
+
+const (
+	ReasoningEffortMinimal = "minimal"
+	ReasoningEffortLow     = "low"
+	ReasoningEffortMedium  = "medium"
+	ReasoningEffortHigh    = "high"
+)
+
+// Thinking is the extended thinking of the Anthropic models, it is accepted on the chat completions too so that the
+// clients can set the thinking budget of any provider
+type Thinking struct {
+	Type         string `json:"type"` // enabled or disabled
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
+}
+
+// reasoningBudgets are the thinking budgets the reasoning efforts are translated to
+var reasoningBudgets = map[string]int{
+	ReasoningEffortMinimal: 0,
+	ReasoningEffortLow:     1024,
+	ReasoningEffortMedium:  8192,
+	ReasoningEffortHigh:    24576,
+}
+
+// ReasoningEffortToBudget returns the thinking budget of the effort, false for an unknown effort
+func ReasoningEffortToBudget(effort string) (int, bool) {
+	budget, ok := reasoningBudgets[effort]
+	return budget, ok
+}
+
+// BudgetToReasoningEffort returns the effort whose budget is the nearest from below, a budget below the low one is low
+func BudgetToReasoningEffort(budget int) string {
+	switch {
+	case budget <= 0:
+		return ReasoningEffortMinimal
+	case budget < reasoningBudgets[ReasoningEffortMedium]:
+		return ReasoningEffortLow
+	case budget < reasoningBudgets[ReasoningEffortHigh]:
+		return ReasoningEffortMedium
+	default:
+		return ReasoningEffortHigh
+	}
+}
+
+// ThinkingBudget returns the thinking budget asked by the request, from thinking or else from reasoning_effort,
+// false if it asks for neither, a budget of 0 turns the thinking off
+func (r GeneralOpenAIRequest) ThinkingBudget() (int, bool) {
+	if r.Thinking != nil {
+		if r.Thinking.Type == "disabled" {
+			return 0, true
+		}
+		return r.Thinking.BudgetTokens, true
+	}
+	if r.ReasoningEffort != nil {
+		return ReasoningEffortToBudget(*r.ReasoningEffort)
+	}
+	return 0, false
+}
@@ -2,7 +2,8 @@ package model
 
 type Tool struct {
 	Id       string   `json:"id,omitempty"`
-	Type     string   `json:"type,omitempty"` // when splicing claude tools stream messages, it is empty
+	Index    *int     `json:"index,omitempty"` // only in the tool calls of the stream deltas
+	Type     string   `json:"type,omitempty"`  // when splicing claude tools stream messages, it is empty
 	Function Function `json:"function"`
 }
 
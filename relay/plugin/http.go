@@ -0,0 +1,166 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+)
+
+const (
+	StagePreRequest   = "pre_request"
+	StagePostResponse = "post_response"
+	StageBilling      = "billing"
+)
+
+// HTTPHook forwards every hook to an external service as a JSON POST request.
+// Only JSON bodies are sent, other bodies (e.g. multipart audio uploads) are passed through untouched.
+// If the service is unavailable, the request goes on as if the hook didn't exist.
+type HTTPHook struct {
+	URL    string
+	client *http.Client
+}
+
+type hookRequest struct {
+	Stage      string          `json:"stage"`
+	Meta       *Meta           `json:"meta,omitempty"`
+	StatusCode int             `json:"status_code,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	Log        *model.Log      `json:"log,omitempty"`
+}
+
+type hookResponse struct {
+	// Body replaces the original body if it is not empty
+	Body json.RawMessage `json:"body"`
+	// Reject rejects the request in pre_request stage
+	Reject     bool   `json:"reject"`
+	StatusCode int    `json:"status_code"`
+	Message    string `json:"message"`
+}
+
+func NewHTTPHook(url string, timeout time.Duration) *HTTPHook {
+	return &HTTPHook{
+		URL:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (h *HTTPHook) Name() string {
+	return "http:" + h.URL
+}
+
+func (h *HTTPHook) call(ctx context.Context, request *hookRequest) (*hookResponse, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return &hookResponse{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("hook returned status code %d", resp.StatusCode)
+	}
+	var response hookResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (h *HTTPHook) PreRequest(c *gin.Context, meta *Meta, body []byte) ([]byte, error) {
+	if !json.Valid(body) {
+		return body, nil
+	}
+	response, err := h.call(c.Request.Context(), &hookRequest{
+		Stage: StagePreRequest,
+		Meta:  meta,
+		Body:  body,
+	})
+	if err != nil {
+		logger.Errorf(c.Request.Context(), "plugin %s failed: %s", h.Name(), err.Error())
+		return body, nil
+	}
+	if response.Reject {
+		statusCode := response.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusForbidden
+		}
+		return nil, &RejectError{StatusCode: statusCode, Message: response.Message}
+	}
+	if len(response.Body) != 0 {
+		return response.Body, nil
+	}
+	return body, nil
+}
+
+func (h *HTTPHook) PostResponse(c *gin.Context, meta *Meta, statusCode int, body []byte) ([]byte, error) {
+	if !json.Valid(body) {
+		return body, nil
+	}
+	response, err := h.call(c.Request.Context(), &hookRequest{
+		Stage:      StagePostResponse,
+		Meta:       meta,
+		StatusCode: statusCode,
+		Body:       body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Body) != 0 {
+		return response.Body, nil
+	}
+	return body, nil
+}
+
+func (h *HTTPHook) OnBilling(ctx context.Context, log *model.Log) {
+	_, err := h.call(ctx, &hookRequest{
+		Stage: StageBilling,
+		Log:   log,
+	})
+	if err != nil {
+		logger.Errorf(ctx, "plugin %s failed: %s", h.Name(), err.Error())
+	}
+}
+
+// Init registers the HTTP hooks set by PLUGIN_HOOK_URLS and the WASM filters
+func Init() {
+	for _, url := range strings.Split(config.PluginHookURLs, ",") {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+		Register(NewHTTPHook(url, time.Duration(config.PluginHookTimeout)*time.Second))
+	}
+	if config.WasmFilterEnabled {
+		w, err := NewWasmFilters(context.Background())
+		if err != nil {
+			logger.FatalLog("failed to initialize wasm runtime: " + err.Error())
+		}
+		if err = w.Reload(context.Background()); err != nil {
+			logger.SysError("failed to load wasm filters: " + err.Error())
+		}
+		Wasm = w
+		Register(w)
+		go w.Sync(config.SyncFrequency)
+	}
+}
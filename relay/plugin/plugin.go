@@ -0,0 +1,123 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// Plugin lets operators customize the relay without changing its code.
+// A plugin can be compiled in by calling Register in an init function,
+// or run as an external service, see HTTPHook.
+type Plugin interface {
+	Name() string
+	// PreRequest is called after the channel is selected and before the request is converted,
+	// it returns the (possibly modified) request body, an error rejects the request
+	PreRequest(c *gin.Context, meta *Meta, body []byte) ([]byte, error)
+	// PostResponse is called with the complete body of a non-streaming response,
+	// it returns the (possibly modified) response body
+	PostResponse(c *gin.Context, meta *Meta, statusCode int, body []byte) ([]byte, error)
+	// OnBilling is called after the quota of a request is settled, it must not block for long
+	OnBilling(ctx context.Context, log *model.Log)
+}
+
+// Base implements Plugin with no-op hooks, embed it to implement only the hooks you need
+type Base struct{}
+
+func (Base) PreRequest(c *gin.Context, meta *Meta, body []byte) ([]byte, error) {
+	return body, nil
+}
+
+func (Base) PostResponse(c *gin.Context, meta *Meta, statusCode int, body []byte) ([]byte, error) {
+	return body, nil
+}
+
+func (Base) OnBilling(ctx context.Context, log *model.Log) {}
+
+// RejectError is returned by PreRequest to reject a request with the given status code
+type RejectError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *RejectError) Error() string {
+	return e.Message
+}
+
+type Meta struct {
+	UserId    int    `json:"user_id"`
+	TokenId   int    `json:"token_id"`
+	TokenName string `json:"token_name"`
+	Group     string `json:"group"`
+	ChannelId int    `json:"channel_id"`
+	Model     string `json:"model"`
+	Path      string `json:"path"`
+}
+
+func GetMeta(c *gin.Context) *Meta {
+	return &Meta{
+		UserId:    c.GetInt(ctxkey.Id),
+		TokenId:   c.GetInt(ctxkey.TokenId),
+		TokenName: c.GetString(ctxkey.TokenName),
+		Group:     c.GetString(ctxkey.Group),
+		ChannelId: c.GetInt(ctxkey.ChannelId),
+		Model:     c.GetString(ctxkey.RequestModel),
+		Path:      c.Request.URL.Path,
+	}
+}
+
+var pluginsLock sync.RWMutex
+var plugins []Plugin
+
+// Register adds a plugin, plugins are called in the order they are registered
+func Register(p Plugin) {
+	pluginsLock.Lock()
+	defer pluginsLock.Unlock()
+	plugins = append(plugins, p)
+	logger.SysLogf("plugin %s registered", p.Name())
+}
+
+func list() []Plugin {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+	return plugins
+}
+
+func Enabled() bool {
+	return len(list()) > 0
+}
+
+func PreRequest(c *gin.Context, meta *Meta, body []byte) ([]byte, error) {
+	var err error
+	for _, p := range list() {
+		body, err = p.PreRequest(c, meta, body)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %w", p.Name(), err)
+		}
+	}
+	return body, nil
+}
+
+func PostResponse(c *gin.Context, meta *Meta, statusCode int, body []byte) []byte {
+	for _, p := range list() {
+		newBody, err := p.PostResponse(c, meta, statusCode, body)
+		if err != nil {
+			logger.Errorf(c.Request.Context(), "plugin %s failed to process response: %s", p.Name(), err.Error())
+			continue
+		}
+		body = newBody
+	}
+	return body
+}
+
+func OnBilling(ctx context.Context, log *model.Log) {
+	for _, p := range list() {
+		p.OnBilling(ctx, log)
+	}
+}
@@ -0,0 +1,237 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// A WASM filter is a module which exports:
+//   - memory
+//   - alloc(size i32) i32: allocates size bytes and returns the pointer, the body is written there
+//   - on_request(ptr i32, len i32) i64 and/or on_response(ptr i32, len i32) i64:
+//     return 0 to keep the body unchanged, or (ptr << 32 | len) of the new body
+//
+// The module may import one_api.reject(ptr i32, len i32) to reject the request with a message,
+// and one_api.log(ptr i32, len i32) to write a log line.
+// WASI is available without any file system, network, clock or environment access.
+
+const (
+	wasmRequestFunc  = "on_request"
+	wasmResponseFunc = "on_response"
+	wasmAllocFunc    = "alloc"
+)
+
+type wasmCallStateKey struct{}
+
+type wasmCallState struct {
+	rejected bool
+	message  string
+}
+
+type compiledFilter struct {
+	filter   *model.Filter
+	compiled wazero.CompiledModule
+}
+
+// Wasm is nil unless WASM_FILTER_ENABLED is set
+var Wasm *WasmFilters
+
+type WasmFilters struct {
+	Base
+	runtime wazero.Runtime
+	lock    sync.RWMutex
+	filters []*compiledFilter
+}
+
+func NewWasmFilters(ctx context.Context) (*WasmFilters, error) {
+	runtimeConfig := wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(uint32(config.WasmFilterMemoryLimit) * 16) // 64 KiB per page
+	r := wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		return nil, err
+	}
+	_, err := r.NewHostModuleBuilder("one_api").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, size uint32) {
+		state, _ := ctx.Value(wasmCallStateKey{}).(*wasmCallState)
+		if state == nil {
+			return
+		}
+		data, _ := m.Memory().Read(ptr, size)
+		state.rejected = true
+		state.message = string(data)
+	}).Export("reject").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, size uint32) {
+		data, _ := m.Memory().Read(ptr, size)
+		logger.SysLogf("wasm filter: %s", string(data))
+	}).Export("log").
+		Instantiate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &WasmFilters{runtime: r}, nil
+}
+
+func (w *WasmFilters) Name() string {
+	return "wasm"
+}
+
+// Validate makes sure that the module can be used as a filter before it is saved
+func (w *WasmFilters) Validate(ctx context.Context, module []byte) error {
+	compiled, err := w.runtime.CompileModule(ctx, module)
+	if err != nil {
+		return err
+	}
+	defer compiled.Close(ctx)
+	exports := compiled.ExportedFunctions()
+	if _, ok := exports[wasmAllocFunc]; !ok {
+		return errors.New("function alloc is not exported")
+	}
+	_, hasRequest := exports[wasmRequestFunc]
+	_, hasResponse := exports[wasmResponseFunc]
+	if !hasRequest && !hasResponse {
+		return errors.New("neither on_request nor on_response is exported")
+	}
+	if len(compiled.ExportedMemories()) == 0 {
+		return errors.New("memory is not exported")
+	}
+	return nil
+}
+
+// Reload loads the enabled filters from database, unchanged modules are not compiled again
+func (w *WasmFilters) Reload(ctx context.Context) error {
+	filters, err := model.GetEnabledFilters()
+	if err != nil {
+		return err
+	}
+	w.lock.RLock()
+	old := make(map[int]*compiledFilter, len(w.filters))
+	for _, f := range w.filters {
+		old[f.filter.Id] = f
+	}
+	w.lock.RUnlock()
+	compiledFilters := make([]*compiledFilter, 0, len(filters))
+	for _, filter := range filters {
+		if f, ok := old[filter.Id]; ok && f.filter.UpdatedTime == filter.UpdatedTime {
+			compiledFilters = append(compiledFilters, f)
+			delete(old, filter.Id)
+			continue
+		}
+		compiled, err := w.runtime.CompileModule(ctx, filter.Module)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to compile wasm filter %s: %s", filter.Name, err.Error()))
+			continue
+		}
+		filter.Module = nil
+		compiledFilters = append(compiledFilters, &compiledFilter{filter: filter, compiled: compiled})
+	}
+	w.lock.Lock()
+	w.filters = compiledFilters
+	w.lock.Unlock()
+	for _, f := range old {
+		_ = f.compiled.Close(ctx)
+	}
+	return nil
+}
+
+func (w *WasmFilters) Sync(frequency int) {
+	for {
+		time.Sleep(time.Duration(frequency) * time.Second)
+		if err := w.Reload(context.Background()); err != nil {
+			logger.SysError("failed to sync wasm filters: " + err.Error())
+		}
+	}
+}
+
+func (w *WasmFilters) match(meta *Meta) []*compiledFilter {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	var matched []*compiledFilter
+	for _, f := range w.filters {
+		switch f.filter.Scope {
+		case model.FilterScopeChannel:
+			if f.filter.Target == strconv.Itoa(meta.ChannelId) {
+				matched = append(matched, f)
+			}
+		case model.FilterScopeGroup:
+			if f.filter.Target == meta.Group {
+				matched = append(matched, f)
+			}
+		}
+	}
+	return matched
+}
+
+// run instantiates a fresh module for every call, so that no state is shared between requests
+func (w *WasmFilters) run(ctx context.Context, f *compiledFilter, funcName string, body []byte) ([]byte, *wasmCallState, error) {
+	state := &wasmCallState{}
+	if _, ok := f.compiled.ExportedFunctions()[funcName]; !ok {
+		return body, state, nil
+	}
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, wasmCallStateKey{}, state), time.Duration(config.WasmFilterTimeout)*time.Millisecond)
+	defer cancel()
+	mod, err := w.runtime.InstantiateModule(ctx, f.compiled, wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize"))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer mod.Close(ctx)
+	results, err := mod.ExportedFunction(wasmAllocFunc).Call(ctx, uint64(len(body)))
+	if err != nil {
+		return nil, nil, err
+	}
+	ptr := uint32(results[0])
+	if !mod.Memory().Write(ptr, body) {
+		return nil, nil, errors.New("alloc returned an invalid pointer")
+	}
+	results, err = mod.ExportedFunction(funcName).Call(ctx, uint64(ptr), uint64(len(body)))
+	if err != nil {
+		return nil, nil, err
+	}
+	if results[0] == 0 {
+		return body, state, nil
+	}
+	out, ok := mod.Memory().Read(uint32(results[0]>>32), uint32(results[0]))
+	if !ok {
+		return nil, nil, errors.New("returned body is out of memory range")
+	}
+	return append([]byte(nil), out...), state, nil
+}
+
+func (w *WasmFilters) PreRequest(c *gin.Context, meta *Meta, body []byte) ([]byte, error) {
+	for _, f := range w.match(meta) {
+		newBody, state, err := w.run(c.Request.Context(), f, wasmRequestFunc, body)
+		if err != nil {
+			return nil, fmt.Errorf("filter %s failed: %w", f.filter.Name, err)
+		}
+		if state.rejected {
+			return nil, &RejectError{StatusCode: http.StatusForbidden, Message: state.message}
+		}
+		body = newBody
+	}
+	return body, nil
+}
+
+func (w *WasmFilters) PostResponse(c *gin.Context, meta *Meta, statusCode int, body []byte) ([]byte, error) {
+	for _, f := range w.match(meta) {
+		newBody, _, err := w.run(c.Request.Context(), f, wasmResponseFunc, body)
+		if err != nil {
+			return nil, fmt.Errorf("filter %s failed: %w", f.filter.Name, err)
+		}
+		body = newBody
+	}
+	return body, nil
+}
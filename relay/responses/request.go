@@ -0,0 +1,345 @@
+// Package responses bridges the clients of the Responses API onto the chat completions, the requests are converted
+// into chat completion requests, and the chat completions, streamed or not, are converted back into responses
+package responses
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// fileSearchToolName names the function standing for the file_search tool in the history of the chat completions
+const fileSearchToolName = "file_search"
+
+// Request is the part of a Responses API request which is relayed, the other fields are ignored
+type Request struct {
+	Model              string            `json:"model"`
+	Input              json.RawMessage   `json:"input"`
+	Instructions       string            `json:"instructions,omitempty"`
+	Tools              []json.RawMessage `json:"tools,omitempty"`
+	ToolChoice         json.RawMessage   `json:"tool_choice,omitempty"`
+	ParallelToolCalls  *bool             `json:"parallel_tool_calls,omitempty"`
+	Stream             bool              `json:"stream,omitempty"`
+	Temperature        *float64          `json:"temperature,omitempty"`
+	TopP               *float64          `json:"top_p,omitempty"`
+	MaxOutputTokens    *int              `json:"max_output_tokens,omitempty"`
+	User               string            `json:"user,omitempty"`
+	Metadata           json.RawMessage   `json:"metadata,omitempty"`
+	Text               *TextConfig       `json:"text,omitempty"`
+	Reasoning          *ReasoningConfig  `json:"reasoning,omitempty"`
+	PreviousResponseId string            `json:"previous_response_id,omitempty"`
+}
+
+type TextConfig struct {
+	Format *TextFormat `json:"format,omitempty"`
+}
+
+type TextFormat struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+type ReasoningConfig struct {
+	Effort string `json:"effort,omitempty"`
+}
+
+// inputItem is any item of the input, the fields used depend on its type
+type inputItem struct {
+	Type      string          `json:"type"`
+	Id        string          `json:"id"`
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content"`
+	CallId    string          `json:"call_id"`
+	Name      string          `json:"name"`
+	Arguments string          `json:"arguments"`
+	Output    json.RawMessage `json:"output"`
+	Queries   []string        `json:"queries"`
+	Results   json.RawMessage `json:"results"`
+}
+
+type contentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Refusal  string `json:"refusal"`
+	ImageURL string `json:"image_url"`
+	FileId   string `json:"file_id"`
+	Detail   string `json:"detail"`
+	FileData string `json:"file_data"`
+	Filename string `json:"filename"`
+	Data     string `json:"data"`
+	Format   string `json:"format"`
+}
+
+type tool struct {
+	Type              string          `json:"type"`
+	Name              string          `json:"name"`
+	Description       string          `json:"description,omitempty"`
+	Parameters        json.RawMessage `json:"parameters,omitempty"`
+	Strict            *bool           `json:"strict,omitempty"`
+	SearchContextSize string          `json:"search_context_size,omitempty"`
+	UserLocation      json.RawMessage `json:"user_location,omitempty"`
+}
+
+type chatFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
+	Arguments   *string         `json:"arguments,omitempty"`
+}
+
+type chatToolCall struct {
+	Id       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function chatFunction `json:"function"`
+}
+
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    any            `json:"content"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallId string         `json:"tool_call_id,omitempty"`
+}
+
+// ChatRequest is the chat completion request a Responses API request is relayed as
+type ChatRequest struct {
+	Model             string           `json:"model"`
+	Messages          []*chatMessage   `json:"messages"`
+	Tools             []map[string]any `json:"tools,omitempty"`
+	ToolChoice        any              `json:"tool_choice,omitempty"`
+	ParallelToolCalls *bool            `json:"parallel_tool_calls,omitempty"`
+	Stream            bool             `json:"stream,omitempty"`
+	StreamOptions     map[string]bool  `json:"stream_options,omitempty"`
+	Temperature       *float64         `json:"temperature,omitempty"`
+	TopP              *float64         `json:"top_p,omitempty"`
+	MaxTokens         *int             `json:"max_tokens,omitempty"`
+	User              string           `json:"user,omitempty"`
+	ResponseFormat    map[string]any   `json:"response_format,omitempty"`
+	ReasoningEffort   string           `json:"reasoning_effort,omitempty"`
+	WebSearchOptions  map[string]any   `json:"web_search_options,omitempty"`
+}
+
+func convertContent(raw json.RawMessage, role string) (any, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var parts []contentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return nil, fmt.Errorf("invalid content of a %s message", role)
+	}
+	if role != "user" {
+		// the chat completions take the text only in the other messages
+		var builder strings.Builder
+		for _, part := range parts {
+			builder.WriteString(part.Text + part.Refusal)
+		}
+		return builder.String(), nil
+	}
+	converted := make([]map[string]any, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case "input_text", "output_text":
+			converted = append(converted, map[string]any{"type": "text", "text": part.Text})
+		case "input_image":
+			if part.ImageURL == "" {
+				return nil, fmt.Errorf("the images given by file_id are not supported, use image_url")
+			}
+			imageURL := map[string]any{"url": part.ImageURL}
+			if part.Detail != "" {
+				imageURL["detail"] = part.Detail
+			}
+			converted = append(converted, map[string]any{"type": "image_url", "image_url": imageURL})
+		case "input_file":
+			file := map[string]any{}
+			if part.FileId != "" {
+				file["file_id"] = part.FileId
+			}
+			if part.FileData != "" {
+				file["file_data"] = part.FileData
+			}
+			if part.Filename != "" {
+				file["filename"] = part.Filename
+			}
+			converted = append(converted, map[string]any{"type": "file", "file": file})
+		case "input_audio":
+			converted = append(converted, map[string]any{"type": "input_audio", "input_audio": map[string]any{"data": part.Data, "format": part.Format}})
+		default:
+			return nil, fmt.Errorf("the content of type %s is not supported", part.Type)
+		}
+	}
+	return converted, nil
+}
+
+// outputText returns the output of a function call as text, the outputs given as content parts are joined
+func outputText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var parts []contentPart
+	if err := json.Unmarshal(raw, &parts); err == nil {
+		var builder strings.Builder
+		for _, part := range parts {
+			builder.WriteString(part.Text)
+		}
+		return builder.String()
+	}
+	return string(raw)
+}
+
+// appendToolCall adds the call to the assistant message before it, or to a new assistant message, so that the
+// parallel calls are in one message as the chat completions expect
+func appendToolCall(messages []*chatMessage, call chatToolCall) []*chatMessage {
+	if n := len(messages); n > 0 && messages[n-1].Role == "assistant" {
+		messages[n-1].ToolCalls = append(messages[n-1].ToolCalls, call)
+		return messages
+	}
+	return append(messages, &chatMessage{Role: "assistant", ToolCalls: []chatToolCall{call}})
+}
+
+func convertInput(raw json.RawMessage) ([]*chatMessage, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []*chatMessage{{Role: "user", Content: text}}, nil
+	}
+	var items []inputItem
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("input must be a string or an array of items")
+	}
+	var messages []*chatMessage
+	for _, item := range items {
+		switch item.Type {
+		case "", "message":
+			role := item.Role
+			if role == "developer" {
+				role = "system"
+			}
+			content, err := convertContent(item.Content, role)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, &chatMessage{Role: role, Content: content})
+		case "function_call":
+			arguments := item.Arguments
+			messages = appendToolCall(messages, chatToolCall{
+				Id:       item.CallId,
+				Type:     "function",
+				Function: chatFunction{Name: item.Name, Arguments: &arguments},
+			})
+		case "function_call_output":
+			messages = append(messages, &chatMessage{Role: "tool", ToolCallId: item.CallId, Content: outputText(item.Output)})
+		case "file_search_call":
+			// the search done before is replayed as a call of a function and its output
+			queries, _ := json.Marshal(map[string]any{"queries": item.Queries})
+			arguments := string(queries)
+			messages = appendToolCall(messages, chatToolCall{
+				Id:       item.Id,
+				Type:     "function",
+				Function: chatFunction{Name: fileSearchToolName, Arguments: &arguments},
+			})
+			results := string(item.Results)
+			if results == "" || results == "null" {
+				results = "[]"
+			}
+			messages = append(messages, &chatMessage{Role: "tool", ToolCallId: item.Id, Content: results})
+		case "reasoning":
+			// the reasoning of the former turns is not sent back to the chat completions
+		default:
+			return nil, fmt.Errorf("the input item of type %s is not supported", item.Type)
+		}
+	}
+	return messages, nil
+}
+
+func convertToolChoice(raw json.RawMessage) (any, error) {
+	var choice string
+	if err := json.Unmarshal(raw, &choice); err == nil {
+		return choice, nil
+	}
+	var named struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(raw, &named); err != nil || named.Type != "function" {
+		return nil, fmt.Errorf("tool_choice must be auto, none, required or a function")
+	}
+	return map[string]any{"type": "function", "function": map[string]any{"name": named.Name}}, nil
+}
+
+// ToChatRequest converts a Responses API request into a chat completion request
+func ToChatRequest(request *Request) (*ChatRequest, error) {
+	if request.PreviousResponseId != "" {
+		return nil, fmt.Errorf("previous_response_id is not supported, send the whole input")
+	}
+	chatRequest := &ChatRequest{
+		Model:             request.Model,
+		ParallelToolCalls: request.ParallelToolCalls,
+		Stream:            request.Stream,
+		Temperature:       request.Temperature,
+		TopP:              request.TopP,
+		MaxTokens:         request.MaxOutputTokens,
+		User:              request.User,
+	}
+	if request.Stream {
+		chatRequest.StreamOptions = map[string]bool{"include_usage": true}
+	}
+	if request.Instructions != "" {
+		chatRequest.Messages = append(chatRequest.Messages, &chatMessage{Role: "system", Content: request.Instructions})
+	}
+	messages, err := convertInput(request.Input)
+	if err != nil {
+		return nil, err
+	}
+	chatRequest.Messages = append(chatRequest.Messages, messages...)
+	for _, raw := range request.Tools {
+		var t tool
+		if err = json.Unmarshal(raw, &t); err != nil {
+			return nil, fmt.Errorf("invalid tool: %s", err.Error())
+		}
+		switch {
+		case t.Type == "function":
+			chatRequest.Tools = append(chatRequest.Tools, map[string]any{
+				"type":     "function",
+				"function": chatFunction{Name: t.Name, Description: t.Description, Parameters: t.Parameters, Strict: t.Strict},
+			})
+		case t.Type == "web_search" || strings.HasPrefix(t.Type, "web_search_preview"):
+			chatRequest.WebSearchOptions = map[string]any{}
+			if t.SearchContextSize != "" {
+				chatRequest.WebSearchOptions["search_context_size"] = t.SearchContextSize
+			}
+			if len(t.UserLocation) > 0 {
+				chatRequest.WebSearchOptions["user_location"] = t.UserLocation
+			}
+		default:
+			return nil, fmt.Errorf("the tool of type %s is not supported by the chat completions", t.Type)
+		}
+	}
+	if len(request.ToolChoice) > 0 {
+		if chatRequest.ToolChoice, err = convertToolChoice(request.ToolChoice); err != nil {
+			return nil, err
+		}
+	}
+	if request.Text != nil && request.Text.Format != nil {
+		switch format := request.Text.Format; format.Type {
+		case "json_schema":
+			schema := map[string]any{"name": format.Name, "schema": format.Schema}
+			if format.Description != "" {
+				schema["description"] = format.Description
+			}
+			if format.Strict != nil {
+				schema["strict"] = *format.Strict
+			}
+			chatRequest.ResponseFormat = map[string]any{"type": "json_schema", "json_schema": schema}
+		case "json_object":
+			chatRequest.ResponseFormat = map[string]any{"type": "json_object"}
+		}
+	}
+	if request.Reasoning != nil {
+		chatRequest.ReasoningEffort = request.Reasoning.Effort
+	}
+	return chatRequest, nil
+}
@@ -0,0 +1,244 @@
+package responses
+
+import (
+	"encoding/json"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/random"
+)
+
+type OutputContent struct {
+	Type        string            `json:"type"`
+	Text        string            `json:"text"`
+	Annotations []json.RawMessage `json:"annotations"`
+}
+
+type SummaryText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// OutputItem is an item of the output, a message, a function call or a reasoning
+type OutputItem struct {
+	Id        string          `json:"id"`
+	Type      string          `json:"type"`
+	Status    string          `json:"status,omitempty"`
+	Role      string          `json:"role,omitempty"`
+	Content   []OutputContent `json:"content,omitempty"`
+	CallId    string          `json:"call_id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Arguments *string         `json:"arguments,omitempty"`
+	Summary   []SummaryText   `json:"summary,omitempty"`
+}
+
+type Usage struct {
+	InputTokens        int `json:"input_tokens"`
+	InputTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+	OutputTokens        int `json:"output_tokens"`
+	OutputTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"output_tokens_details"`
+	TotalTokens int `json:"total_tokens"`
+}
+
+type IncompleteDetails struct {
+	Reason string `json:"reason"`
+}
+
+// Response is the Responses API object, the settings of the request are sent back in it
+type Response struct {
+	Id                string             `json:"id"`
+	Object            string             `json:"object"`
+	CreatedAt         int64              `json:"created_at"`
+	Status            string             `json:"status"`
+	IncompleteDetails *IncompleteDetails `json:"incomplete_details"`
+	Error             json.RawMessage    `json:"error"`
+	Model             string             `json:"model"`
+	Instructions      *string            `json:"instructions"`
+	Output            []*OutputItem      `json:"output"`
+	Tools             []json.RawMessage  `json:"tools"`
+	ToolChoice        json.RawMessage    `json:"tool_choice"`
+	ParallelToolCalls bool               `json:"parallel_tool_calls"`
+	Temperature       *float64           `json:"temperature"`
+	TopP              *float64           `json:"top_p"`
+	MaxOutputTokens   *int               `json:"max_output_tokens"`
+	Metadata          json.RawMessage    `json:"metadata"`
+	Store             bool               `json:"store"`
+	Usage             *Usage             `json:"usage"`
+}
+
+// chatUsage is the usage of the chat completions with the details of the tokens
+type chatUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	CompletionTokensDetails *struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+type chatResponseToolCall struct {
+	Index    *int   `json:"index"`
+	Id       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatResponseMessage struct {
+	Content          *string                `json:"content"`
+	ReasoningContent *string                `json:"reasoning_content"`
+	ToolCalls        []chatResponseToolCall `json:"tool_calls"`
+	Annotations      []json.RawMessage      `json:"annotations"`
+}
+
+type chatResponse struct {
+	Id      string `json:"id"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index        int                 `json:"index"`
+		Message      chatResponseMessage `json:"message"`
+		Delta        chatResponseMessage `json:"delta"`
+		FinishReason *string             `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *chatUsage     `json:"usage"`
+	Error map[string]any `json:"error"`
+}
+
+func newId(prefix string) string {
+	return prefix + random.GetRandomString(24)
+}
+
+func convertUsage(usage *chatUsage) *Usage {
+	if usage == nil {
+		return nil
+	}
+	converted := &Usage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  usage.TotalTokens,
+	}
+	if usage.PromptTokensDetails != nil {
+		converted.InputTokensDetails.CachedTokens = usage.PromptTokensDetails.CachedTokens
+	}
+	if usage.CompletionTokensDetails != nil {
+		converted.OutputTokensDetails.ReasoningTokens = usage.CompletionTokensDetails.ReasoningTokens
+	}
+	return converted
+}
+
+// newResponse returns the response to the request before its output is known
+func newResponse(request *Request) *Response {
+	response := &Response{
+		Id:                newId("resp_"),
+		Object:            "response",
+		CreatedAt:         helper.GetTimestamp(),
+		Status:            "in_progress",
+		Model:             request.Model,
+		Output:            []*OutputItem{},
+		Tools:             request.Tools,
+		ToolChoice:        request.ToolChoice,
+		ParallelToolCalls: request.ParallelToolCalls == nil || *request.ParallelToolCalls,
+		Temperature:       request.Temperature,
+		TopP:              request.TopP,
+		MaxOutputTokens:   request.MaxOutputTokens,
+		Metadata:          request.Metadata,
+	}
+	if request.Instructions != "" {
+		response.Instructions = &request.Instructions
+	}
+	if response.Tools == nil {
+		response.Tools = []json.RawMessage{}
+	}
+	if len(response.ToolChoice) == 0 {
+		response.ToolChoice = json.RawMessage(`"auto"`)
+	}
+	return response
+}
+
+// finish sets the status of the response from the finish reason of the chat completion
+func (r *Response) finish(finishReason string) {
+	switch finishReason {
+	case "length":
+		r.Status = "incomplete"
+		r.IncompleteDetails = &IncompleteDetails{Reason: "max_output_tokens"}
+	case "content_filter":
+		r.Status = "incomplete"
+		r.IncompleteDetails = &IncompleteDetails{Reason: "content_filter"}
+	default:
+		r.Status = "completed"
+	}
+}
+
+func messageItem(text string, annotations []json.RawMessage) *OutputItem {
+	if annotations == nil {
+		annotations = []json.RawMessage{}
+	}
+	return &OutputItem{
+		Id:      newId("msg_"),
+		Type:    "message",
+		Status:  "completed",
+		Role:    "assistant",
+		Content: []OutputContent{{Type: "output_text", Text: text, Annotations: annotations}},
+	}
+}
+
+func reasoningItem(text string) *OutputItem {
+	return &OutputItem{Id: newId("rs_"), Type: "reasoning", Summary: []SummaryText{{Type: "summary_text", Text: text}}}
+}
+
+func functionCallItem(callId string, name string, arguments string) *OutputItem {
+	return &OutputItem{
+		Id:        newId("fc_"),
+		Type:      "function_call",
+		Status:    "completed",
+		CallId:    callId,
+		Name:      name,
+		Arguments: &arguments,
+	}
+}
+
+// FromChatResponse converts a chat completion into the response to the request, the reasoning, the answer and the
+// function calls of the first choice are the items of the output in this order
+func FromChatResponse(request *Request, body []byte) ([]byte, error) {
+	var chat chatResponse
+	if err := json.Unmarshal(body, &chat); err != nil {
+		return nil, err
+	}
+	response := newResponse(request)
+	if chat.Created != 0 {
+		response.CreatedAt = chat.Created
+	}
+	if chat.Model != "" {
+		response.Model = chat.Model
+	}
+	finishReason := ""
+	for _, choice := range chat.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		message := choice.Message
+		if message.ReasoningContent != nil && *message.ReasoningContent != "" {
+			response.Output = append(response.Output, reasoningItem(*message.ReasoningContent))
+		}
+		if message.Content != nil && (*message.Content != "" || len(message.ToolCalls) == 0) {
+			response.Output = append(response.Output, messageItem(*message.Content, message.Annotations))
+		}
+		for _, call := range message.ToolCalls {
+			response.Output = append(response.Output, functionCallItem(call.Id, call.Function.Name, call.Function.Arguments))
+		}
+		if choice.FinishReason != nil {
+			finishReason = *choice.FinishReason
+		}
+	}
+	response.finish(finishReason)
+	response.Usage = convertUsage(chat.Usage)
+	return json.Marshal(response)
+}
@@ -0,0 +1,133 @@
+package responses
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func parseRequest(body string) *Request {
+	var request Request
+	So(json.Unmarshal([]byte(body), &request), ShouldBeNil)
+	return &request
+}
+
+func TestToChatRequest(t *testing.T) {
+	Convey("ToChatRequest", t, func() {
+		Convey("converts the text input and the settings", func() {
+			request := parseRequest(`{"model":"gpt-4o","instructions":"be brief","input":"hi","max_output_tokens":50,"stream":true,
+				"text":{"format":{"type":"json_schema","name":"answer","schema":{"type":"object"}}}}`)
+			chat, err := ToChatRequest(request)
+			So(err, ShouldBeNil)
+			So(chat.Messages, ShouldHaveLength, 2)
+			So(chat.Messages[0].Role, ShouldEqual, "system")
+			So(chat.Messages[1].Content, ShouldEqual, "hi")
+			So(*chat.MaxTokens, ShouldEqual, 50)
+			So(chat.StreamOptions["include_usage"], ShouldBeTrue)
+			So(chat.ResponseFormat["type"], ShouldEqual, "json_schema")
+		})
+		Convey("converts the function calls and their outputs", func() {
+			request := parseRequest(`{"model":"gpt-4o","input":[
+				{"role":"user","content":[{"type":"input_text","text":"weather?"},{"type":"input_image","image_url":"https://a/b.png"}]},
+				{"type":"function_call","call_id":"call_1","name":"weather","arguments":"{\"city\":\"Paris\"}"},
+				{"type":"function_call","call_id":"call_2","name":"weather","arguments":"{\"city\":\"Rome\"}"},
+				{"type":"function_call_output","call_id":"call_1","output":"sunny"},
+				{"type":"function_call_output","call_id":"call_2","output":"rainy"}],
+				"tools":[{"type":"function","name":"weather","parameters":{"type":"object"},"strict":true}],
+				"tool_choice":{"type":"function","name":"weather"}}`)
+			chat, err := ToChatRequest(request)
+			So(err, ShouldBeNil)
+			So(chat.Messages, ShouldHaveLength, 4)
+			So(chat.Messages[0].Content, ShouldHaveLength, 2)
+			So(chat.Messages[1].Role, ShouldEqual, "assistant")
+			So(chat.Messages[1].ToolCalls, ShouldHaveLength, 2)
+			So(chat.Messages[2].Role, ShouldEqual, "tool")
+			So(chat.Messages[3].ToolCallId, ShouldEqual, "call_2")
+			So(chat.Tools, ShouldHaveLength, 1)
+			So(*chat.Tools[0]["function"].(chatFunction).Strict, ShouldBeTrue)
+			So(chat.ToolChoice.(map[string]any)["function"], ShouldResemble, map[string]any{"name": "weather"})
+		})
+		Convey("replays the file search results as a function call", func() {
+			request := parseRequest(`{"model":"gpt-4o","input":[{"role":"user","content":"what is in the docs?"},
+				{"type":"file_search_call","id":"fs_1","status":"completed","queries":["docs"],"results":[{"file_id":"file_1","text":"the docs"}]}]}`)
+			chat, err := ToChatRequest(request)
+			So(err, ShouldBeNil)
+			So(chat.Messages, ShouldHaveLength, 3)
+			So(chat.Messages[1].ToolCalls[0].Function.Name, ShouldEqual, fileSearchToolName)
+			So(*chat.Messages[1].ToolCalls[0].Function.Arguments, ShouldEqual, `{"queries":["docs"]}`)
+			So(chat.Messages[2].Content, ShouldContainSubstring, "the docs")
+		})
+		Convey("rejects what the chat completions cannot do", func() {
+			_, err := ToChatRequest(parseRequest(`{"model":"gpt-4o","input":"hi","tools":[{"type":"code_interpreter"}]}`))
+			So(err, ShouldNotBeNil)
+			_, err = ToChatRequest(parseRequest(`{"model":"gpt-4o","input":"hi","previous_response_id":"resp_1"}`))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestFromChatResponse(t *testing.T) {
+	Convey("FromChatResponse", t, func() {
+		request := parseRequest(`{"model":"gpt-4o","input":"hi"}`)
+		body, err := FromChatResponse(request, []byte(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,
+			"message":{"role":"assistant","content":"Let me check.","reasoning_content":"the user asks",
+			"tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{}"}}]},"finish_reason":"tool_calls"}],
+			"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`))
+		So(err, ShouldBeNil)
+		var response Response
+		So(json.Unmarshal(body, &response), ShouldBeNil)
+		So(response.Status, ShouldEqual, "completed")
+		So(response.Output, ShouldHaveLength, 3)
+		So(response.Output[0].Type, ShouldEqual, "reasoning")
+		So(response.Output[1].Content[0].Text, ShouldEqual, "Let me check.")
+		So(response.Output[2].CallId, ShouldEqual, "call_1")
+		So(response.Usage.TotalTokens, ShouldEqual, 15)
+	})
+}
+
+func TestStream(t *testing.T) {
+	Convey("Stream", t, func() {
+		stream := NewStream(parseRequest(`{"model":"gpt-4o","input":"hi","stream":true}`))
+		var events strings.Builder
+		for _, chunk := range []string{
+			`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"weather","arguments":"{\"ci"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ty\":1}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}}`,
+			"[DONE]",
+		} {
+			events.Write(stream.Convert(chunk))
+		}
+		So(stream.Finish(), ShouldBeNil)
+		var types []string
+		var completed map[string]any
+		for _, line := range strings.Split(events.String(), "\n") {
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var event map[string]any
+				So(json.Unmarshal([]byte(data), &event), ShouldBeNil)
+				types = append(types, event["type"].(string))
+				if event["type"] == "response.completed" {
+					completed = event["response"].(map[string]any)
+				}
+			}
+		}
+		So(types, ShouldResemble, []string{
+			"response.created", "response.in_progress",
+			"response.output_item.added", "response.content_part.added",
+			"response.output_text.delta", "response.output_text.delta",
+			"response.output_text.done", "response.content_part.done", "response.output_item.done",
+			"response.output_item.added", "response.function_call_arguments.delta", "response.function_call_arguments.delta",
+			"response.function_call_arguments.done", "response.output_item.done",
+			"response.completed",
+		})
+		output := completed["output"].([]any)
+		So(output, ShouldHaveLength, 2)
+		So(output[0].(map[string]any)["content"].([]any)[0].(map[string]any)["text"], ShouldEqual, "Hello")
+		So(output[1].(map[string]any)["arguments"], ShouldEqual, `{"city":1}`)
+		So(completed["usage"].(map[string]any)["total_tokens"], ShouldEqual, 7)
+	})
+}
@@ -0,0 +1,223 @@
+package responses
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Stream converts the chunks of a streamed chat completion into the events of the Responses API, an item of the
+// output is opened for the reasoning, the answer and each function call as their deltas arrive
+type Stream struct {
+	response     *Response
+	sequence     int
+	started      bool
+	done         bool
+	current      *OutputItem // the reasoning or the message receiving the deltas
+	text         strings.Builder
+	annotations  []json.RawMessage
+	calls        map[int]*OutputItem
+	callOrder    []int
+	indexes      map[string]int
+	finishReason string
+}
+
+func NewStream(request *Request) *Stream {
+	return &Stream{
+		response: newResponse(request),
+		calls:    make(map[int]*OutputItem),
+		indexes:  make(map[string]int),
+	}
+}
+
+func (s *Stream) event(buffer *bytes.Buffer, eventType string, data map[string]any) {
+	data["type"] = eventType
+	data["sequence_number"] = s.sequence
+	s.sequence++
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Fprintf(buffer, "event: %s\ndata: %s\n\n", eventType, jsonData)
+}
+
+func (s *Stream) start(buffer *bytes.Buffer) {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.event(buffer, "response.created", map[string]any{"response": s.response})
+	s.event(buffer, "response.in_progress", map[string]any{"response": s.response})
+}
+
+func (s *Stream) addItem(buffer *bytes.Buffer, item *OutputItem) {
+	s.indexes[item.Id] = len(s.response.Output)
+	s.response.Output = append(s.response.Output, item)
+	s.event(buffer, "response.output_item.added", map[string]any{"output_index": s.indexes[item.Id], "item": item})
+}
+
+func (s *Stream) itemEvent(buffer *bytes.Buffer, eventType string, item *OutputItem, data map[string]any) {
+	data["item_id"] = item.Id
+	data["output_index"] = s.indexes[item.Id]
+	s.event(buffer, eventType, data)
+}
+
+// open closes the item receiving the deltas if it is of another type, and opens an item of the type
+func (s *Stream) open(buffer *bytes.Buffer, itemType string) *OutputItem {
+	if s.current != nil && s.current.Type == itemType {
+		return s.current
+	}
+	s.closeCurrent(buffer)
+	switch itemType {
+	case "reasoning":
+		s.current = &OutputItem{Id: newId("rs_"), Type: "reasoning", Summary: []SummaryText{}}
+		s.addItem(buffer, s.current)
+		s.itemEvent(buffer, "response.reasoning_summary_part.added", s.current, map[string]any{
+			"summary_index": 0,
+			"part":          SummaryText{Type: "summary_text"},
+		})
+	default:
+		s.current = &OutputItem{Id: newId("msg_"), Type: "message", Status: "in_progress", Role: "assistant", Content: []OutputContent{}}
+		s.addItem(buffer, s.current)
+		s.itemEvent(buffer, "response.content_part.added", s.current, map[string]any{
+			"content_index": 0,
+			"part":          OutputContent{Type: "output_text", Annotations: []json.RawMessage{}},
+		})
+	}
+	return s.current
+}
+
+func (s *Stream) closeCurrent(buffer *bytes.Buffer) {
+	item := s.current
+	if item == nil {
+		return
+	}
+	text := s.text.String()
+	s.current = nil
+	s.text.Reset()
+	if item.Type == "reasoning" {
+		part := SummaryText{Type: "summary_text", Text: text}
+		item.Summary = []SummaryText{part}
+		s.itemEvent(buffer, "response.reasoning_summary_text.done", item, map[string]any{"summary_index": 0, "text": text})
+		s.itemEvent(buffer, "response.reasoning_summary_part.done", item, map[string]any{"summary_index": 0, "part": part})
+	} else {
+		annotations := s.annotations
+		if annotations == nil {
+			annotations = []json.RawMessage{}
+		}
+		s.annotations = nil
+		part := OutputContent{Type: "output_text", Text: text, Annotations: annotations}
+		item.Status = "completed"
+		item.Content = []OutputContent{part}
+		s.itemEvent(buffer, "response.output_text.done", item, map[string]any{"content_index": 0, "text": text})
+		s.itemEvent(buffer, "response.content_part.done", item, map[string]any{"content_index": 0, "part": part})
+	}
+	s.event(buffer, "response.output_item.done", map[string]any{"output_index": s.indexes[item.Id], "item": item})
+}
+
+func (s *Stream) toolCall(buffer *bytes.Buffer, position int, call chatResponseToolCall) {
+	index := position
+	if call.Index != nil {
+		index = *call.Index
+	}
+	item, ok := s.calls[index]
+	if !ok {
+		// the function calls stay open until the end, as some upstreams interleave the deltas of parallel calls
+		s.closeCurrent(buffer)
+		arguments := ""
+		item = &OutputItem{Id: newId("fc_"), Type: "function_call", Status: "in_progress", CallId: call.Id, Name: call.Function.Name, Arguments: &arguments}
+		s.calls[index] = item
+		s.callOrder = append(s.callOrder, index)
+		s.addItem(buffer, item)
+	}
+	if item.CallId == "" {
+		item.CallId = call.Id
+	}
+	if item.Name == "" {
+		item.Name = call.Function.Name
+	}
+	if call.Function.Arguments != "" {
+		arguments := *item.Arguments + call.Function.Arguments
+		item.Arguments = &arguments
+		s.itemEvent(buffer, "response.function_call_arguments.delta", item, map[string]any{"delta": call.Function.Arguments})
+	}
+}
+
+// Convert converts a data line of the chat completion stream into events, [DONE] ends the response
+func (s *Stream) Convert(data string) []byte {
+	data = strings.TrimSpace(data)
+	if data == "[DONE]" {
+		return s.Finish()
+	}
+	var chunk chatResponse
+	if s.done || json.Unmarshal([]byte(data), &chunk) != nil {
+		return nil
+	}
+	var buffer bytes.Buffer
+	s.start(&buffer)
+	if chunk.Error != nil {
+		s.done = true
+		s.response.Status = "failed"
+		s.response.Error, _ = json.Marshal(chunk.Error)
+		s.event(&buffer, "response.failed", map[string]any{"response": s.response})
+		return buffer.Bytes()
+	}
+	if chunk.Model != "" {
+		s.response.Model = chunk.Model
+	}
+	if chunk.Usage != nil {
+		s.response.Usage = convertUsage(chunk.Usage)
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		delta := choice.Delta
+		if delta.ReasoningContent != nil && *delta.ReasoningContent != "" {
+			item := s.open(&buffer, "reasoning")
+			s.text.WriteString(*delta.ReasoningContent)
+			s.itemEvent(&buffer, "response.reasoning_summary_text.delta", item, map[string]any{"summary_index": 0, "delta": *delta.ReasoningContent})
+		}
+		if delta.Content != nil && *delta.Content != "" {
+			item := s.open(&buffer, "message")
+			s.text.WriteString(*delta.Content)
+			s.itemEvent(&buffer, "response.output_text.delta", item, map[string]any{"content_index": 0, "delta": *delta.Content})
+		}
+		if len(delta.Annotations) > 0 {
+			s.open(&buffer, "message")
+			s.annotations = append(s.annotations, delta.Annotations...)
+		}
+		for i, call := range delta.ToolCalls {
+			s.toolCall(&buffer, i, call)
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.finishReason = *choice.FinishReason
+		}
+	}
+	return buffer.Bytes()
+}
+
+// Finish closes the items of the output and sends the whole response, it returns nothing once the response ended
+func (s *Stream) Finish() []byte {
+	if s.done {
+		return nil
+	}
+	s.done = true
+	var buffer bytes.Buffer
+	s.start(&buffer)
+	s.closeCurrent(&buffer)
+	for _, index := range s.callOrder {
+		item := s.calls[index]
+		item.Status = "completed"
+		s.itemEvent(&buffer, "response.function_call_arguments.done", item, map[string]any{"arguments": *item.Arguments})
+		s.event(&buffer, "response.output_item.done", map[string]any{"output_index": s.indexes[item.Id], "item": item})
+	}
+	s.response.finish(s.finishReason)
+	eventType := "response.completed"
+	if s.response.Status == "incomplete" {
+		eventType = "response.incomplete"
+	}
+	s.event(&buffer, eventType, map[string]any{"response": s.response})
+	return buffer.Bytes()
+}
@@ -0,0 +1,103 @@
+// Package sandbox answers the requests of the sandbox tokens with deterministic mock responses, so that the clients
+// can be tested against the gateway without calling the upstreams or consuming quota
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// DefaultDimensions is the length of the mock embeddings of the requests not setting the dimensions
+const DefaultDimensions = 1536
+
+// Fixture is a canned answer, for the requests of its model whose prompt contains its text, empty matching any
+type Fixture struct {
+	Model    string `json:"model,omitempty"`
+	Contains string `json:"contains,omitempty"`
+	Content  string `json:"content"`
+}
+
+var fixturesLock sync.RWMutex
+var Fixtures []*Fixture
+
+func Fixtures2JSONString() string {
+	fixturesLock.RLock()
+	defer fixturesLock.RUnlock()
+	jsonBytes, err := json.Marshal(Fixtures)
+	if err != nil {
+		logger.SysError("error marshalling sandbox fixtures: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateFixturesByJSONString(jsonStr string) error {
+	var fixtures []*Fixture
+	if err := json.Unmarshal([]byte(jsonStr), &fixtures); err != nil {
+		return err
+	}
+	for i, fixture := range fixtures {
+		if fixture == nil || fixture.Content == "" {
+			return fmt.Errorf("fixture %d: content is empty", i)
+		}
+	}
+	fixturesLock.Lock()
+	defer fixturesLock.Unlock()
+	Fixtures = fixtures
+	return nil
+}
+
+// Reply returns the content of the first fixture matching the request, otherwise the prompt is echoed
+func Reply(modelName string, prompt string) string {
+	fixturesLock.RLock()
+	defer fixturesLock.RUnlock()
+	for _, fixture := range Fixtures {
+		if fixture.Model != "" && fixture.Model != modelName {
+			continue
+		}
+		if fixture.Contains != "" && !strings.Contains(prompt, fixture.Contains) {
+			continue
+		}
+		return fixture.Content
+	}
+	if prompt == "" {
+		return "This is a sandbox response."
+	}
+	return prompt
+}
+
+// Chunks splits the reply into the deltas of a stream, word by word
+func Chunks(reply string) []string {
+	return strings.SplitAfter(reply, " ")
+}
+
+// Embedding returns a unit vector derived from the text only, the same text always having the same vector
+func Embedding(text string, dimensions int) ([]float64, error) {
+	if dimensions < 0 || dimensions > 8192 {
+		return nil, errors.New("dimensions must be between 1 and 8192")
+	}
+	if dimensions == 0 {
+		dimensions = DefaultDimensions
+	}
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(text))
+	random := rand.New(rand.NewSource(int64(hash.Sum64())))
+	vector := make([]float64, dimensions)
+	norm := 0.0
+	for i := range vector {
+		vector[i] = random.NormFloat64()
+		norm += vector[i] * vector[i]
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector, nil
+}
@@ -0,0 +1,42 @@
+package sandbox
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSandbox(t *testing.T) {
+	Convey("UpdateFixturesByJSONString", t, func() {
+		So(UpdateFixturesByJSONString(`[{"contains": "weather"}]`), ShouldNotBeNil)
+		So(UpdateFixturesByJSONString(`[null]`), ShouldNotBeNil)
+		So(UpdateFixturesByJSONString(`[{"model": "gpt-4o", "contains": "weather", "content": "sunny"}, {"contains": "weather", "content": "rainy"}]`), ShouldBeNil)
+		So(Fixtures2JSONString(), ShouldEqual, `[{"model":"gpt-4o","contains":"weather","content":"sunny"},{"contains":"weather","content":"rainy"}]`)
+	})
+
+	Convey("Reply", t, func() {
+		So(Reply("gpt-4o", "what is the weather"), ShouldEqual, "sunny")
+		So(Reply("gpt-4o-mini", "what is the weather"), ShouldEqual, "rainy")
+		So(Reply("gpt-4o", "hello there"), ShouldEqual, "hello there")
+		So(Reply("gpt-4o", ""), ShouldEqual, "This is a sandbox response.")
+		So(Chunks("hello there world"), ShouldResemble, []string{"hello ", "there ", "world"})
+	})
+
+	Convey("Embedding", t, func() {
+		vector, err := Embedding("hello", 0)
+		So(err, ShouldBeNil)
+		So(len(vector), ShouldEqual, DefaultDimensions)
+		again, _ := Embedding("hello", 0)
+		So(again, ShouldResemble, vector)
+		other, _ := Embedding("world", 0)
+		So(other, ShouldNotResemble, vector)
+		norm := 0.0
+		for _, v := range vector {
+			norm += v * v
+		}
+		So(math.Sqrt(norm), ShouldAlmostEqual, 1, 1e-9)
+		_, err = Embedding("hello", 10000)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,132 @@
+// Package shaper spreads the requests sent to a channel over time, so that the bursts of the clients
+// stay within the RPM and TPM limits published by the provider instead of being rejected with 429
+package shaper
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var ErrWaitTooLong = errors.New("the channel is saturated, the wait would exceed the limit")
+
+// bucket is a token bucket, a reservation can take it below zero, the later ones wait for the refill
+type bucket struct {
+	capacity float64
+	tokens   float64
+	rate     float64 // per second
+	updated  time.Time
+}
+
+func newBucket(perMinute int, now time.Time) *bucket {
+	return &bucket{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		updated:  now,
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.updated).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.updated = now
+}
+
+// reserve takes n tokens and returns how long to wait until they are available
+func (b *bucket) reserve(n float64, now time.Time) time.Duration {
+	b.refill(now)
+	if n > b.capacity {
+		n = b.capacity
+	}
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *bucket) cancel(n float64) {
+	if n > b.capacity {
+		n = b.capacity
+	}
+	b.tokens += n
+}
+
+type limiter struct {
+	rpm      int
+	tpm      int
+	requests *bucket
+	tokens   *bucket
+}
+
+var lock sync.Mutex
+var limiters = make(map[int]*limiter)
+
+func getLimiter(channelId int, rpm int, tpm int, now time.Time) *limiter {
+	l, ok := limiters[channelId]
+	if !ok || l.rpm != rpm || l.tpm != tpm {
+		// the limits of the channel are changed
+		l = &limiter{rpm: rpm, tpm: tpm}
+		if rpm > 0 {
+			l.requests = newBucket(rpm, now)
+		}
+		if tpm > 0 {
+			l.tokens = newBucket(tpm, now)
+		}
+		limiters[channelId] = l
+	}
+	return l
+}
+
+// reserve returns the wait for a request with the given number of tokens, zero rpm or tpm means no limit
+func reserve(channelId int, rpm int, tpm int, tokens int, maxWait time.Duration) (time.Duration, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	now := time.Now()
+	l := getLimiter(channelId, rpm, tpm, now)
+	var wait time.Duration
+	if l.requests != nil {
+		wait = l.requests.reserve(1, now)
+	}
+	if l.tokens != nil {
+		if tokenWait := l.tokens.reserve(float64(tokens), now); tokenWait > wait {
+			wait = tokenWait
+		}
+	}
+	if wait > maxWait {
+		if l.requests != nil {
+			l.requests.cancel(1)
+		}
+		if l.tokens != nil {
+			l.tokens.cancel(float64(tokens))
+		}
+		return 0, ErrWaitTooLong
+	}
+	return wait, nil
+}
+
+// Wait blocks until the channel can take a request with the given number of tokens,
+// a jitter is added so that the queued requests do not hit the upstream at the same instant
+func Wait(ctx context.Context, channelId int, rpm int, tpm int, tokens int, maxWait time.Duration) error {
+	if rpm <= 0 && tpm <= 0 {
+		return nil
+	}
+	wait, err := reserve(channelId, rpm, tpm, tokens, maxWait)
+	if err != nil || wait == 0 {
+		return err
+	}
+	wait += time.Duration(rand.Int63n(int64(wait/10) + 1))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,39 @@
+package shaper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWait(t *testing.T) {
+	Convey("requests within the rpm are not delayed", t, func() {
+		for i := 0; i < 60; i++ {
+			wait, err := reserve(1, 60, 0, 0, time.Second)
+			So(err, ShouldBeNil)
+			So(wait, ShouldEqual, 0)
+		}
+		wait, err := reserve(1, 60, 0, 0, 10*time.Second)
+		So(err, ShouldBeNil)
+		So(wait, ShouldBeBetween, 900*time.Millisecond, time.Second)
+	})
+
+	Convey("requests waiting too long are rejected and release their reservation", t, func() {
+		_, err := reserve(2, 0, 600, 600, time.Second)
+		So(err, ShouldBeNil)
+		_, err = reserve(2, 0, 600, 100, time.Second)
+		So(err, ShouldEqual, ErrWaitTooLong)
+		wait, err := reserve(2, 0, 600, 5, time.Second)
+		So(err, ShouldBeNil)
+		So(wait, ShouldBeLessThanOrEqualTo, 600*time.Millisecond)
+	})
+
+	Convey("the wait is cancelled with the request", t, func() {
+		_, _ = reserve(3, 1, 0, 0, time.Minute)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		So(Wait(ctx, 3, 1, 0, 0, time.Minute), ShouldEqual, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,144 @@
+package websearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+const (
+	ProviderBing    = "bing"
+	ProviderSearxNG = "searxng"
+	ProviderTavily  = "tavily"
+)
+
+type Result struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Content string `json:"content"`
+}
+
+// Provider searches the web for the web_search tool calls served by the gateway
+type Provider interface {
+	Search(ctx context.Context, query string, limit int) ([]Result, error)
+}
+
+// GetProvider returns the configured provider, or nil when the web_search tool is passed to the upstream
+func GetProvider() Provider {
+	switch config.WebSearchProvider {
+	case ProviderBing:
+		return bing{}
+	case ProviderSearxNG:
+		return searxng{}
+	case ProviderTavily:
+		return tavily{}
+	}
+	return nil
+}
+
+func endpoint(defaultURL string) string {
+	if config.WebSearchURL != "" {
+		return strings.TrimSuffix(config.WebSearchURL, "/")
+	}
+	return defaultURL
+}
+
+func do(req *http.Request, response any) error {
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("search failed with status code %d: %s", resp.StatusCode, string(data))
+	}
+	return json.Unmarshal(data, response)
+}
+
+// https://learn.microsoft.com/en-us/bing/search-apis/bing-web-search/reference/endpoints
+type bing struct{}
+
+func (p bing) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	params := url.Values{"q": {query}, "count": {strconv.Itoa(limit)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint("https://api.bing.microsoft.com/v7.0/search")+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", config.WebSearchToken)
+	var response struct {
+		WebPages struct {
+			Value []struct {
+				Name    string `json:"name"`
+				URL     string `json:"url"`
+				Snippet string `json:"snippet"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}
+	if err = do(req, &response); err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(response.WebPages.Value))
+	for _, page := range response.WebPages.Value {
+		results = append(results, Result{Title: page.Name, URL: page.URL, Content: page.Snippet})
+	}
+	return results, nil
+}
+
+// https://docs.searxng.org/dev/search_api.html, the json format has to be enabled in the settings of the instance
+type searxng struct{}
+
+func (p searxng) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	params := url.Values{"q": {query}, "format": {"json"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint("http://localhost:8080")+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if config.WebSearchToken != "" {
+		req.Header.Set("Authorization", "Bearer "+config.WebSearchToken)
+	}
+	var response struct {
+		Results []Result `json:"results"`
+	}
+	if err = do(req, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Results) > limit {
+		response.Results = response.Results[:limit]
+	}
+	return response.Results, nil
+}
+
+// https://docs.tavily.com/documentation/api-reference/endpoint/search
+type tavily struct{}
+
+func (p tavily) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	body, _ := json.Marshal(map[string]any{
+		"query":       query,
+		"max_results": limit,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint("https://api.tavily.com/search"), strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+config.WebSearchToken)
+	var response struct {
+		Results []Result `json:"results"`
+	}
+	if err = do(req, &response); err != nil {
+		return nil, err
+	}
+	return response.Results, nil
+}
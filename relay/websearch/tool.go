@@ -0,0 +1,121 @@
+package websearch
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// the web_search tool of the request is replaced by a function of the same name, the calls of the model
+// are served by the Provider and the model is asked again with the results, see relay/controller/websearch.go
+
+const ToolName = "web_search"
+
+// MaxRounds is how many times the model may search before it has to answer
+const MaxRounds = 3
+
+var tool = model.Tool{
+	Type: "function",
+	Function: model.Function{
+		Name:        ToolName,
+		Description: "Search the web for up-to-date information, returns the titles, urls and snippets of the top results.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]any{
+					"type":        "string",
+					"description": "The search query.",
+				},
+			},
+			"required": []string{"query"},
+		},
+	},
+}
+
+func isWebSearchTool(toolType string) bool {
+	return toolType == "web_search" || strings.HasPrefix(toolType, "web_search_preview")
+}
+
+// Requested reports whether the request asks for the web search, by a tool or by web_search_options
+func Requested(request *model.GeneralOpenAIRequest) bool {
+	if request.WebSearchOptions != nil {
+		return true
+	}
+	for _, t := range request.Tools {
+		if isWebSearchTool(t.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rewrite replaces the web search asked by the request with the function served by the gateway,
+// it returns how many results a search returns, which follows search_context_size of web_search_options
+func Rewrite(request *model.GeneralOpenAIRequest) int {
+	limit := config.WebSearchMaxResults
+	if options, ok := request.WebSearchOptions.(map[string]any); ok {
+		switch options["search_context_size"] {
+		case "low":
+			limit = (limit + 1) / 2
+		case "high":
+			limit *= 2
+		}
+	}
+	if limit <= 0 {
+		limit = 5
+	}
+	request.WebSearchOptions = nil
+	tools := make([]model.Tool, 0, len(request.Tools)+1)
+	for _, t := range request.Tools {
+		if !isWebSearchTool(t.Type) {
+			tools = append(tools, t)
+		}
+	}
+	// it is the last one, so that it can be dropped when the searches are exhausted
+	request.Tools = append(tools, tool)
+	if choice, ok := request.ToolChoice.(map[string]any); ok {
+		if toolType, _ := choice["type"].(string); isWebSearchTool(toolType) {
+			request.ToolChoice = "auto"
+		}
+	}
+	return limit
+}
+
+// Exhaust drops the function, so that the model answers with what it has found
+func Exhaust(request *model.GeneralOpenAIRequest) {
+	request.Tools = request.Tools[:len(request.Tools)-1]
+	if len(request.Tools) == 0 {
+		request.Tools = nil
+		request.ToolChoice = nil
+	}
+}
+
+func IsCall(toolCall model.Tool) bool {
+	return toolCall.Function.Name == ToolName
+}
+
+// Run serves the call of the model, the failure is told to the model rather than failing the request
+func Run(ctx context.Context, provider Provider, toolCall model.Tool, limit int) string {
+	var arguments struct {
+		Query string `json:"query"`
+	}
+	switch value := toolCall.Function.Arguments.(type) {
+	case string:
+		_ = json.Unmarshal([]byte(value), &arguments)
+	default:
+		data, _ := json.Marshal(value)
+		_ = json.Unmarshal(data, &arguments)
+	}
+	if strings.TrimSpace(arguments.Query) == "" {
+		return "web_search failed: query is empty"
+	}
+	results, err := provider.Search(ctx, arguments.Query, limit)
+	if err != nil {
+		return "web_search failed: " + err.Error()
+	}
+	data, _ := json.Marshal(results)
+	return string(data)
+}
@@ -0,0 +1,40 @@
+package websearch
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+func TestRewrite(t *testing.T) {
+	config.WebSearchMaxResults = 4
+	Convey("Requested", t, func() {
+		So(Requested(&model.GeneralOpenAIRequest{}), ShouldBeFalse)
+		So(Requested(&model.GeneralOpenAIRequest{WebSearchOptions: map[string]any{}}), ShouldBeTrue)
+		So(Requested(&model.GeneralOpenAIRequest{Tools: []model.Tool{{Type: "web_search_preview_2025_03_11"}}}), ShouldBeTrue)
+		So(Requested(&model.GeneralOpenAIRequest{Tools: []model.Tool{{Type: "function"}}}), ShouldBeFalse)
+	})
+	Convey("Rewrite", t, func() {
+		request := &model.GeneralOpenAIRequest{
+			Tools:      []model.Tool{{Type: "web_search"}, {Type: "function", Function: model.Function{Name: "f"}}},
+			ToolChoice: map[string]any{"type": "web_search"},
+		}
+		So(Rewrite(request), ShouldEqual, 4)
+		So(len(request.Tools), ShouldEqual, 2)
+		So(request.Tools[0].Function.Name, ShouldEqual, "f")
+		So(IsCall(request.Tools[1]), ShouldBeTrue)
+		So(request.ToolChoice, ShouldEqual, "auto")
+		Exhaust(request)
+		So(len(request.Tools), ShouldEqual, 1)
+		So(Requested(request), ShouldBeFalse)
+
+		request = &model.GeneralOpenAIRequest{WebSearchOptions: map[string]any{"search_context_size": "low"}}
+		So(Rewrite(request), ShouldEqual, 2)
+		So(request.WebSearchOptions, ShouldBeNil)
+		Exhaust(request)
+		So(request.Tools, ShouldBeNil)
+	})
+}
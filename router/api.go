@@ -15,6 +15,18 @@ func SetApiRouter(router *gin.Engine) {
 	apiRouter.Use(middleware.GlobalAPIRateLimit())
 	{
 		apiRouter.GET("/status", controller.GetStatus)
+		apiRouter.GET("/branding", controller.GetBranding)
+		apiRouter.PUT("/branding", middleware.RootAuth(), controller.UpdateBranding)
+		apiRouter.GET("/branding/themes", middleware.RootAuth(), controller.GetThemes)
+		apiRouter.GET("/status/metrics", middleware.AdminAuth(), controller.GetMetrics)
+		apiRouter.GET("/status/config", middleware.RootAuth(), controller.GetEffectiveConfig)
+		apiRouter.GET("/status/startup_check", middleware.AdminAuth(), controller.GetStartupCheck)
+		apiRouter.GET("/debug/runtime", middleware.RootAuth(), controller.GetRuntimeStats)
+		apiRouter.POST("/debug/gc", middleware.RootAuth(), controller.ForceGC)
+		apiRouter.GET("/status/models", middleware.StatusPageAuth(), controller.GetModelStatus)
+		apiRouter.GET("/selftest", middleware.AdminAuth(), controller.GetSelfTest)
+		apiRouter.POST("/selftest", middleware.AdminAuth(), controller.StartSelfTest)
+		apiRouter.GET("/selftest/matrix", middleware.StatusPageAuth(), controller.GetSelfTestMatrix)
 		apiRouter.GET("/models", middleware.UserAuth(), controller.DashboardListModels)
 		apiRouter.GET("/notice", controller.GetNotice)
 		apiRouter.GET("/about", controller.GetAbout)
@@ -27,14 +39,20 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/oauth/lark", middleware.CriticalRateLimit(), auth.LarkOAuth)
 		apiRouter.GET("/oauth/state", middleware.CriticalRateLimit(), auth.GenerateOAuthCode)
 		apiRouter.GET("/oauth/wechat", middleware.CriticalRateLimit(), auth.WeChatAuth)
+		apiRouter.GET("/saml/metadata", auth.SAMLMetadata)
+		apiRouter.GET("/saml/login", middleware.CriticalRateLimit(), auth.SAMLLogin)
+		apiRouter.POST("/saml/acs", middleware.CriticalRateLimit(), auth.SAMLACS)
 		apiRouter.GET("/oauth/wechat/bind", middleware.CriticalRateLimit(), middleware.UserAuth(), auth.WeChatBind)
 		apiRouter.GET("/oauth/email/bind", middleware.CriticalRateLimit(), middleware.UserAuth(), controller.EmailBind)
 		apiRouter.POST("/topup", middleware.AdminAuth(), controller.AdminTopUp)
+		apiRouter.GET("/transfer", middleware.AdminAuth(), controller.GetQuotaTransfers)
+		apiRouter.GET("/ledger/audit", middleware.AdminAuth(), controller.AuditQuotaLedgers)
 
 		userRoute := apiRouter.Group("/user")
 		{
 			userRoute.POST("/register", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Register)
 			userRoute.POST("/login", middleware.CriticalRateLimit(), controller.Login)
+			userRoute.POST("/login/2fa", middleware.CriticalRateLimit(), controller.LoginTwoFactor)
 			userRoute.GET("/logout", controller.Logout)
 
 			selfRoute := userRoute.Group("/")
@@ -46,8 +64,27 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.DELETE("/self", controller.DeleteSelf)
 				selfRoute.GET("/token", controller.GenerateAccessToken)
 				selfRoute.GET("/aff", controller.GetAffCode)
+				selfRoute.GET("/aff/stat", controller.GetSelfAffStat)
+				selfRoute.GET("/aff/rewards", controller.GetSelfAffRewards)
 				selfRoute.POST("/topup", controller.TopUp)
+				selfRoute.GET("/coupon", controller.GetSelfCoupons)
+				selfRoute.POST("/coupon", middleware.CriticalRateLimit(), controller.ClaimCoupon)
+				selfRoute.POST("/transfer", middleware.CriticalRateLimit(), controller.TransferQuota)
+				selfRoute.GET("/transfer", controller.GetSelfQuotaTransfers)
+				selfRoute.GET("/ledger", controller.GetSelfQuotaLedger)
 				selfRoute.GET("/available_models", controller.GetUserAvailableModels)
+				selfRoute.GET("/free_allowances", controller.GetFreeAllowances)
+				selfRoute.GET("/notification", controller.GetNotificationPreferences)
+				selfRoute.PUT("/notification", controller.UpdateNotificationPreferences)
+				selfRoute.PUT("/currency", controller.UpdateSelfCurrency)
+				selfRoute.GET("/2fa", controller.GetTwoFactorStatus)
+				selfRoute.POST("/2fa/setup", controller.SetupTwoFactor)
+				selfRoute.POST("/2fa/enable", middleware.CriticalRateLimit(), controller.EnableTwoFactor)
+				selfRoute.POST("/2fa/disable", middleware.CriticalRateLimit(), controller.DisableTwoFactor)
+				selfRoute.POST("/2fa/recovery_codes", middleware.CriticalRateLimit(), controller.RegenerateRecoveryCodes)
+				selfRoute.GET("/sessions", controller.GetSelfSessions)
+				selfRoute.DELETE("/sessions", controller.RevokeOtherSelfSessions)
+				selfRoute.DELETE("/sessions/:id", controller.RevokeSelfSession)
 			}
 
 			adminRoute := userRoute.Group("/")
@@ -56,17 +93,66 @@ func SetApiRouter(router *gin.Engine) {
 				adminRoute.GET("/", controller.GetAllUsers)
 				adminRoute.GET("/search", controller.SearchUsers)
 				adminRoute.GET("/:id", controller.GetUser)
+				adminRoute.GET("/:id/aff/stat", controller.GetUserAffStat)
+				adminRoute.GET("/:id/ledger", controller.GetUserQuotaLedger)
 				adminRoute.POST("/", controller.CreateUser)
 				adminRoute.POST("/manage", controller.ManageUser)
+				adminRoute.POST("/import", controller.ImportUsers)
 				adminRoute.PUT("/", controller.UpdateUser)
 				adminRoute.DELETE("/:id", controller.DeleteUser)
+				adminRoute.DELETE("/:id/2fa", controller.ResetUserTwoFactor)
+				adminRoute.GET("/:id/sessions", controller.GetUserSessions)
+				adminRoute.DELETE("/:id/sessions", controller.RevokeUserSessions)
+				adminRoute.GET("/reseller", controller.GetResellers)
+				adminRoute.PUT("/reseller", controller.SetReseller)
+				adminRoute.DELETE("/reseller/:id", controller.UnsetReseller)
+				adminRoute.GET("/reseller/:id/ledger", controller.GetResellerQuotaLedger)
+				adminRoute.GET("/trash", controller.GetDeletedUsers)
+				adminRoute.POST("/trash/:id/restore", controller.RestoreUser)
+				adminRoute.DELETE("/trash/:id", controller.PurgeUser)
+				adminRoute.GET("/external/:external_id", controller.GetUserByExternalId)
+				adminRoute.PUT("/external/:external_id", controller.PutUserByExternalId)
+				adminRoute.DELETE("/external/:external_id", controller.DeleteUserByExternalId)
 			}
 		}
+		resellerRoute := apiRouter.Group("/reseller")
+		resellerRoute.Use(middleware.ResellerAuth())
+		{
+			resellerRoute.GET("/self", controller.GetSelfReseller)
+			resellerRoute.GET("/ledger", controller.GetSelfResellerQuotaLedger)
+			resellerRoute.GET("/log", controller.GetResellerLogs)
+			resellerRoute.GET("/user", controller.GetResellerUsers)
+			resellerRoute.POST("/user", controller.CreateResellerUser)
+			resellerRoute.PUT("/user", controller.UpdateResellerUser)
+			resellerRoute.DELETE("/user/:id", controller.DeleteResellerUser)
+			resellerRoute.POST("/user/:id/quota", controller.TransferResellerQuota)
+			resellerRoute.GET("/user/:id/token", controller.GetResellerUserTokens)
+			resellerRoute.POST("/user/:id/token", controller.AddResellerUserToken)
+			resellerRoute.DELETE("/user/:id/token/:token_id", controller.DeleteResellerUserToken)
+		}
+		botRoute := apiRouter.Group("/bot")
+		{
+			botRoute.POST("/telegram", controller.TelegramBotWebhook)
+			botRoute.POST("/lark", controller.LarkBotWebhook)
+			botRoute.POST("/dingtalk", controller.DingTalkBotWebhook)
+			botRoute.GET("/bind_code", middleware.UserAuth(), controller.GetBotBindCode)
+			botRoute.GET("/chat", middleware.UserAuth(), controller.GetBotChats)
+			botRoute.DELETE("/chat/:id", middleware.UserAuth(), controller.DeleteBotChat)
+		}
 		optionRoute := apiRouter.Group("/option")
 		optionRoute.Use(middleware.RootAuth())
 		{
 			optionRoute.GET("/", controller.GetOptions)
 			optionRoute.PUT("/", controller.UpdateOption)
+			optionRoute.POST("/exchange_rates/sync", controller.SyncExchangeRates)
+		}
+		backupRoute := apiRouter.Group("/backup")
+		backupRoute.Use(middleware.RootAuth())
+		{
+			backupRoute.GET("/", controller.GetBackups)
+			backupRoute.POST("/", controller.CreateBackup)
+			backupRoute.GET("/:id/download", controller.DownloadBackup)
+			backupRoute.DELETE("/:id", controller.DeleteBackup)
 		}
 		channelRoute := apiRouter.Group("/channel")
 		channelRoute.Use(middleware.AdminAuth())
@@ -74,25 +160,55 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/", controller.GetAllChannels)
 			channelRoute.GET("/search", controller.SearchChannels)
 			channelRoute.GET("/models", controller.ListAllModels)
+			channelRoute.GET("/explain", controller.ExplainChannelSelection)
 			channelRoute.GET("/:id", controller.GetChannel)
 			channelRoute.GET("/test", controller.TestChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
 			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
 			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
+			channelRoute.POST("/import", controller.ImportChannels)
+			channelRoute.GET("/key_scan", controller.GetChannelKeyScans)
+			channelRoute.POST("/key_scan", controller.ScanChannelKeys)
+			channelRoute.GET("/key_usage", controller.GetChannelKeyUsages)
+			channelRoute.GET("/api_versions", controller.GetChannelAPIVersions)
+			channelRoute.GET("/response_samples", controller.GetResponseSamples)
+			channelRoute.DELETE("/response_samples", controller.DeleteResponseSamples)
 			channelRoute.POST("/", controller.AddChannel)
 			channelRoute.PUT("/", controller.UpdateChannel)
 			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
 			channelRoute.DELETE("/:id", controller.DeleteChannel)
+			channelRoute.GET("/trash", controller.GetDeletedChannels)
+			channelRoute.POST("/trash/:id/restore", controller.RestoreChannel)
+			channelRoute.DELETE("/trash/:id", controller.PurgeChannel)
+			channelRoute.GET("/external/:external_id", controller.GetChannelByExternalId)
+			channelRoute.PUT("/external/:external_id", controller.PutChannelByExternalId)
+			channelRoute.DELETE("/external/:external_id", controller.DeleteChannelByExternalId)
+		}
+		inFlightRoute := apiRouter.Group("/in_flight")
+		inFlightRoute.Use(middleware.AdminAuth())
+		{
+			inFlightRoute.GET("/", controller.GetInFlightRequests)
+			inFlightRoute.DELETE("/", controller.CancelChannelInFlightRequests)
+			inFlightRoute.DELETE("/:request_id", controller.CancelInFlightRequest)
 		}
 		tokenRoute := apiRouter.Group("/token")
 		tokenRoute.Use(middleware.UserAuth())
 		{
 			tokenRoute.GET("/", controller.GetAllTokens)
 			tokenRoute.GET("/search", controller.SearchTokens)
+			tokenRoute.GET("/anomaly", controller.GetUserTokenAnomalies)
+			tokenRoute.POST("/anomaly/:id/resolve", controller.ResolveUserTokenAnomaly)
 			tokenRoute.GET("/:id", controller.GetToken)
 			tokenRoute.POST("/", controller.AddToken)
 			tokenRoute.PUT("/", controller.UpdateToken)
+			tokenRoute.POST("/:id/rotate", controller.RotateToken)
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
+			tokenRoute.GET("/trash", controller.GetDeletedTokens)
+			tokenRoute.POST("/trash/:id/restore", controller.RestoreToken)
+			tokenRoute.DELETE("/trash/:id", controller.PurgeToken)
+			tokenRoute.GET("/external/:external_id", controller.GetTokenByExternalId)
+			tokenRoute.PUT("/external/:external_id", controller.PutTokenByExternalId)
+			tokenRoute.DELETE("/external/:external_id", controller.DeleteTokenByExternalId)
 		}
 		redemptionRoute := apiRouter.Group("/redemption")
 		redemptionRoute.Use(middleware.AdminAuth())
@@ -104,12 +220,89 @@ func SetApiRouter(router *gin.Engine) {
 			redemptionRoute.PUT("/", controller.UpdateRedemption)
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
+		couponRoute := apiRouter.Group("/coupon")
+		couponRoute.Use(middleware.AdminAuth())
+		{
+			couponRoute.GET("/", controller.GetAllCoupons)
+			couponRoute.POST("/", controller.AddCoupon)
+			couponRoute.PUT("/", controller.UpdateCoupon)
+			couponRoute.DELETE("/:id", controller.DeleteCoupon)
+			couponRoute.GET("/:id/redemptions", controller.GetCouponRedemptions)
+		}
+		filterRoute := apiRouter.Group("/filter")
+		filterRoute.Use(middleware.AdminAuth())
+		{
+			filterRoute.GET("/", controller.GetAllFilters)
+			filterRoute.POST("/", controller.AddFilter)
+			filterRoute.PUT("/", controller.UpdateFilter)
+			filterRoute.DELETE("/:id", controller.DeleteFilter)
+		}
+		mcpServerRoute := apiRouter.Group("/mcp_server")
+		mcpServerRoute.Use(middleware.AdminAuth())
+		{
+			mcpServerRoute.GET("/", controller.GetAllMcpServers)
+			mcpServerRoute.GET("/:id/tools", controller.GetMcpServerTools)
+			mcpServerRoute.POST("/", controller.AddMcpServer)
+			mcpServerRoute.PUT("/", controller.UpdateMcpServer)
+			mcpServerRoute.DELETE("/:id", controller.DeleteMcpServer)
+		}
+		experimentRoute := apiRouter.Group("/experiment")
+		experimentRoute.Use(middleware.AdminAuth())
+		{
+			experimentRoute.GET("/", controller.GetAllExperiments)
+			experimentRoute.GET("/:id/report", controller.GetExperimentReport)
+			experimentRoute.POST("/", controller.AddExperiment)
+			experimentRoute.PUT("/", controller.UpdateExperiment)
+			experimentRoute.DELETE("/:id", controller.DeleteExperiment)
+		}
+		glossaryRoute := apiRouter.Group("/glossary")
+		glossaryRoute.Use(middleware.AdminAuth())
+		{
+			glossaryRoute.GET("/", controller.GetAllGlossaryTerms)
+			glossaryRoute.POST("/", controller.AddGlossaryTerm)
+			glossaryRoute.PUT("/", controller.UpdateGlossaryTerm)
+			glossaryRoute.DELETE("/:id", controller.DeleteGlossaryTerm)
+		}
+		anomalyRoute := apiRouter.Group("/anomaly")
+		anomalyRoute.Use(middleware.AdminAuth())
+		{
+			anomalyRoute.GET("/", controller.GetAllTokenAnomalies)
+			anomalyRoute.POST("/:id/resolve", controller.ResolveTokenAnomaly)
+		}
+		evaluationRoute := apiRouter.Group("/evaluation")
+		evaluationRoute.Use(middleware.AdminAuth())
+		{
+			evaluationRoute.GET("/channels", controller.GetChannelEvaluations)
+		}
+		planRoute := apiRouter.Group("/plan")
+		planRoute.Use(middleware.AdminAuth())
+		{
+			planRoute.GET("/", controller.GetAllPlans)
+			planRoute.POST("/", controller.AddPlan)
+			planRoute.PUT("/", controller.UpdatePlan)
+			planRoute.DELETE("/:id", controller.DeletePlan)
+			planRoute.POST("/user", controller.SetUserPlan)
+		}
+		templateRoute := apiRouter.Group("/template")
+		templateRoute.Use(middleware.AdminAuth())
+		{
+			templateRoute.GET("/", controller.GetAllPromptTemplates)
+			templateRoute.GET("/:name", controller.GetPromptTemplateVersions)
+			templateRoute.POST("/", controller.SavePromptTemplate)
+			templateRoute.DELETE("/:name", controller.DeletePromptTemplate)
+		}
+		apiRouter.GET("/usage/export", middleware.AdminAuth(), controller.ExportUsage)
 		logRoute := apiRouter.Group("/log")
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
 		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
+		logRoute.DELETE("/user/:id", middleware.AdminAuth(), controller.EraseUserLogs)
 		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
+		logRoute.GET("/project_stat", middleware.AdminAuth(), controller.GetLogsProjectStat)
+		logRoute.GET("/self/project_stat", middleware.UserAuth(), controller.GetLogsSelfProjectStat)
 		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
+		logRoute.GET("/body/:request_id", middleware.AdminAuth(), controller.GetLogBody)
+		logRoute.POST("/replay/:request_id", middleware.AdminAuth(), controller.ReplayLog)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
 		groupRoute := apiRouter.Group("/group")
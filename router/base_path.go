@@ -0,0 +1,45 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+// WithBasePath serves the handler under BASE_PATH, the prefix is stripped from the requests under it, and the
+// requests without it are served as they are, for the reverse proxies stripping the prefix themselves
+func WithBasePath(handler http.Handler) http.Handler {
+	base := config.BasePath
+	if base == "" {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == base {
+			location := base + "/"
+			if r.URL.RawQuery != "" {
+				location += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, location, http.StatusMovedPermanently)
+			return
+		}
+		path, ok := strings.CutPrefix(r.URL.Path, base+"/")
+		if !ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		stripped := new(http.Request)
+		*stripped = *r
+		stripped.URL = new(url.URL)
+		*stripped.URL = *r.URL
+		stripped.URL.Path = "/" + path
+		if rawPath, ok := strings.CutPrefix(r.URL.RawPath, base+"/"); ok {
+			stripped.URL.RawPath = "/" + rawPath
+		}
+		if requestURI, ok := strings.CutPrefix(r.RequestURI, base+"/"); ok {
+			stripped.RequestURI = "/" + requestURI
+		}
+		handler.ServeHTTP(w, stripped)
+	})
+}
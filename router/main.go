@@ -6,12 +6,18 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/theme"
+	"github.com/songquanpeng/one-api/controller"
+	"github.com/songquanpeng/one-api/middleware"
 	"net/http"
 	"os"
 	"strings"
 )
 
 func SetRouter(router *gin.Engine, buildFS embed.FS) {
+	theme.Init(buildFS)
+	// ahead of every route, so that the preflight requests are answered for the paths without an OPTIONS route
+	router.Use(middleware.CORS())
 	SetApiRouter(router)
 	SetDashboardRouter(router)
 	SetRelayRouter(router)
@@ -21,7 +27,7 @@ func SetRouter(router *gin.Engine, buildFS embed.FS) {
 		logger.SysLog("FRONTEND_BASE_URL is ignored on master node")
 	}
 	if frontendBaseUrl == "" {
-		SetWebRouter(router, buildFS)
+		SetWebRouter(router)
 	} else {
 		frontendBaseUrl = strings.TrimSuffix(frontendBaseUrl, "/")
 		router.NoRoute(func(c *gin.Context) {
@@ -29,3 +35,33 @@ func SetRouter(router *gin.Engine, buildFS embed.FS) {
 		})
 	}
 }
+
+// SetPublicRouter serves the relay API only, it is used on PORT when PUBLIC_ADMIN_API_ENABLED is false,
+// the management API and the console being served on ADMIN_LISTEN only
+func SetPublicRouter(router *gin.Engine) {
+	router.Use(middleware.CORS())
+	// the playground is among the relay routes, but signed in to the console
+	router.Use(func(c *gin.Context) {
+		if middleware.IsManagementPath(c.Request.URL.Path) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "管理接口未在此端口开放",
+			})
+			c.Abort()
+		}
+	})
+	SetDashboardRouter(router)
+	SetRelayRouter(router)
+	router.NoRoute(controller.RelayNotFound)
+}
+
+// SetPprofRouter serves the profiles of net/http/pprof, it is used on ADMIN_LISTEN only, since the profiles
+// expose the memory and the call stacks of the process, even with PUBLIC_ADMIN_API_ENABLED
+func SetPprofRouter(router *gin.Engine) {
+	pprofRouter := router.Group("/api/debug/pprof")
+	pprofRouter.Use(middleware.GlobalAPIRateLimit(), middleware.RootAuth())
+	{
+		pprofRouter.GET("/*name", controller.GetPprof)
+		pprofRouter.POST("/*name", controller.GetPprof)
+	}
+}
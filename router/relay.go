@@ -3,22 +3,143 @@ package router
 import (
 	"github.com/songquanpeng/one-api/controller"
 	"github.com/songquanpeng/one-api/middleware"
+	"github.com/songquanpeng/one-api/model"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetRelayRouter(router *gin.Engine) {
-	router.Use(middleware.CORS())
 	router.Use(middleware.GzipDecodeMiddleware())
 	// https://platform.openai.com/docs/api-reference/introduction
 	modelsRouter := router.Group("/v1/models")
-	modelsRouter.Use(middleware.TokenAuth())
+	modelsRouter.Use(middleware.Compress(), middleware.TokenAuth())
 	{
 		modelsRouter.GET("", controller.ListModels)
 		modelsRouter.GET("/:model", controller.RetrieveModel)
 	}
+	// the playground is not under the api router, as gzip would block the streaming. The middlewares matching the
+	// relay path, such as ConstrainedModelSanitizer, go after PlaygroundAuth which rewrites the path
+	playgroundRouter := router.Group("/api/playground")
+	playgroundRouter.Use(middleware.RelayPanicRecover(), middleware.Deadline(), middleware.StreamKeepAlive(), middleware.UserAuth(), middleware.PlaygroundAuth(), middleware.TokenAuth(), middleware.Project(), middleware.RateLimitHeaders(), middleware.PlanLimit(), middleware.TokenConcurrency(), middleware.Sandbox(), middleware.Idempotency(), middleware.Transcript(), middleware.ModelDeprecation(), middleware.Experiment(), middleware.Distribute(), middleware.RequestDefaults(), middleware.ConstrainedModelSanitizer(), middleware.ResponseFilters(), middleware.Plugins())
+	{
+		playgroundRouter.POST("/chat/completions", controller.Relay)
+	}
+	templateRouter := router.Group("/v1/templates")
+	templateRouter.Use(middleware.Compress(), middleware.RelayPanicRecover(), middleware.Deadline(), middleware.StreamKeepAlive(), middleware.TokenAuth(), middleware.Project(), middleware.PromptTemplate(), middleware.RateLimitHeaders(), middleware.RequestDedup(), middleware.PlanLimit(), middleware.TokenConcurrency(), middleware.Chaos(), middleware.Sandbox(), middleware.Idempotency(), middleware.Transcript(), middleware.ModelDeprecation(), middleware.Experiment(), middleware.Distribute(), middleware.RequestDefaults(), middleware.ConstrainedModelSanitizer(), middleware.ResponseMetadata(), middleware.ResponseFilters(), middleware.Plugins())
+	{
+		templateRouter.POST("/chat/completions", controller.Relay)
+	}
+	// the files and fine-tuning jobs are sent to the channel they were created on, rather than distributed.
+	// Sandbox rejects the sandbox tokens on the routes it does not answer, which would reach the upstreams otherwise
+	fineTuningRouter := router.Group("/v1")
+	fineTuningRouter.Use(middleware.Compress(), middleware.RelayPanicRecover(), middleware.TokenAuth(), middleware.Project(), middleware.Sandbox())
+	{
+		fineTuningRouter.GET("/files", controller.ListFiles)
+		fineTuningRouter.POST("/files", controller.UploadFile)
+		fineTuningRouter.DELETE("/files/:id", controller.DeleteFile)
+		fineTuningRouter.GET("/files/:id", controller.RetrieveFile)
+		fineTuningRouter.GET("/files/:id/content", controller.RetrieveFileContent)
+		fineTuningRouter.POST("/fine_tuning/jobs", controller.CreateFineTuningJob)
+		fineTuningRouter.GET("/fine_tuning/jobs", controller.ListFineTuningJobs)
+		fineTuningRouter.GET("/fine_tuning/jobs/:id", controller.RetrieveFineTuningJob)
+		fineTuningRouter.POST("/fine_tuning/jobs/:id/cancel", controller.CancelFineTuningJob)
+		fineTuningRouter.GET("/fine_tuning/jobs/:id/events", controller.ListFineTuningEvents)
+	}
+	// the assistants are emulated with chat completions, the runs are distributed when they are executed
+	assistantsRouter := router.Group("/v1")
+	assistantsRouter.Use(middleware.Compress(), middleware.RelayPanicRecover(), middleware.TokenAuth(), middleware.Project(), middleware.Sandbox())
+	{
+		assistantsRouter.POST("/assistants", controller.CreateAssistant)
+		assistantsRouter.GET("/assistants/:id", controller.RetrieveAssistant)
+		assistantsRouter.POST("/assistants/:id", controller.ModifyAssistant)
+		assistantsRouter.DELETE("/assistants/:id", controller.DeleteAssistant)
+		assistantsRouter.GET("/assistants", controller.ListAssistants)
+		assistantsRouter.POST("/assistants/:id/files", controller.RelayNotImplemented)
+		assistantsRouter.GET("/assistants/:id/files/:fileId", controller.RelayNotImplemented)
+		assistantsRouter.DELETE("/assistants/:id/files/:fileId", controller.RelayNotImplemented)
+		assistantsRouter.GET("/assistants/:id/files", controller.RelayNotImplemented)
+		assistantsRouter.POST("/threads", controller.CreateThread)
+		assistantsRouter.POST("/threads/runs", controller.CreateThreadAndRun)
+		assistantsRouter.GET("/threads/:id", controller.RetrieveThread)
+		assistantsRouter.POST("/threads/:id", controller.ModifyThread)
+		assistantsRouter.DELETE("/threads/:id", controller.DeleteThread)
+		assistantsRouter.POST("/threads/:id/messages", controller.CreateMessage)
+		assistantsRouter.GET("/threads/:id/messages", controller.ListMessages)
+		assistantsRouter.GET("/threads/:id/messages/:messageId", controller.RetrieveMessage)
+		assistantsRouter.POST("/threads/:id/messages/:messageId", controller.ModifyMessage)
+		assistantsRouter.GET("/threads/:id/messages/:messageId/files/:filesId", controller.RelayNotImplemented)
+		assistantsRouter.GET("/threads/:id/messages/:messageId/files", controller.RelayNotImplemented)
+		assistantsRouter.POST("/threads/:id/runs", controller.CreateRun)
+		assistantsRouter.GET("/threads/:id/runs/:runsId", controller.RetrieveRun)
+		assistantsRouter.POST("/threads/:id/runs/:runsId", controller.ModifyRun)
+		assistantsRouter.GET("/threads/:id/runs", controller.ListRuns)
+		assistantsRouter.POST("/threads/:id/runs/:runsId/submit_tool_outputs", controller.SubmitToolOutputs)
+		assistantsRouter.POST("/threads/:id/runs/:runsId/cancel", controller.CancelRun)
+		assistantsRouter.GET("/threads/:id/runs/:runsId/steps/:stepId", controller.RelayNotImplemented)
+		assistantsRouter.GET("/threads/:id/runs/:runsId/steps", controller.RelayNotImplemented)
+		assistantsRouter.POST("/vector_stores", controller.CreateVectorStore)
+		assistantsRouter.GET("/vector_stores", controller.ListVectorStores)
+		assistantsRouter.GET("/vector_stores/:id", controller.RetrieveVectorStore)
+		assistantsRouter.POST("/vector_stores/:id", controller.ModifyVectorStore)
+		assistantsRouter.DELETE("/vector_stores/:id", controller.DeleteVectorStore)
+		assistantsRouter.POST("/vector_stores/:id/search", controller.SearchVectorStore)
+		assistantsRouter.POST("/vector_stores/:id/files", controller.CreateVectorStoreFile)
+		assistantsRouter.GET("/vector_stores/:id/files", controller.ListVectorStoreFiles)
+		assistantsRouter.GET("/vector_stores/:id/files/:fileId", controller.RetrieveVectorStoreFile)
+		assistantsRouter.DELETE("/vector_stores/:id/files/:fileId", controller.DeleteVectorStoreFile)
+		assistantsRouter.POST("/rag/query", controller.RagQuery)
+		assistantsRouter.POST("/evaluations", controller.CreateEvaluation)
+		assistantsRouter.POST("/council", controller.Council)
+		assistantsRouter.POST("/translations", controller.Translate)
+		assistantsRouter.POST("/billing/estimate", controller.EstimateBilling)
+	}
+	// the conversations keep the history replayed into the chat completions with the X-OneAPI-Conversation-Id header
+	conversationsRouter := router.Group("/v1/conversations")
+	conversationsRouter.Use(middleware.Compress(), middleware.RelayPanicRecover(), middleware.TokenAuth(), middleware.FeatureFlag(model.FeatureConversations))
+	{
+		conversationsRouter.POST("", controller.CreateConversation)
+		conversationsRouter.GET("", controller.ListConversations)
+		conversationsRouter.GET("/:id", controller.RetrieveConversation)
+		conversationsRouter.POST("/:id", controller.ModifyConversation)
+		conversationsRouter.DELETE("/:id", controller.DeleteConversation)
+		conversationsRouter.GET("/:id/messages", controller.ListConversationMessages)
+	}
+	// the async tasks are relayed in the background, the limits of the token apply when they are executed
+	asyncRouter := router.Group("/v1/async")
+	asyncRouter.Use(middleware.Compress(), middleware.RelayPanicRecover(), middleware.TokenAuth(), middleware.Project(), middleware.Sandbox(), middleware.FeatureFlag(model.FeatureAsync))
+	{
+		asyncRouter.GET("/tasks/:id", controller.RetrieveAsyncTask)
+		asyncRouter.POST("/*path", controller.SubmitAsyncTask)
+	}
+	// the deferred chat completions are submitted to the relay route with the X-OneAPI-Deferred header
+	deferredRouter := router.Group("/v1/chat/completions")
+	deferredRouter.Use(middleware.Compress(), middleware.RelayPanicRecover(), middleware.TokenAuth(), middleware.FeatureFlag(model.FeatureAsync))
+	{
+		deferredRouter.GET("/:id", controller.RetrieveDeferredCompletion)
+	}
+	// the MCP endpoint is offered by the gateway itself, its tools are relayed when they are called
+	mcpRouter := router.Group("/mcp")
+	mcpRouter.Use(middleware.RelayPanicRecover(), middleware.TokenAuth(), middleware.Project(), middleware.Sandbox(), middleware.FeatureFlag(model.FeatureMcp))
+	{
+		mcpRouter.POST("", controller.Mcp)
+		mcpRouter.GET("", controller.McpMethodNotAllowed)
+	}
+	// the stages of the speech to speech pipeline are relayed with the token, each as its own request
+	speechToSpeechRouter := router.Group("/v1/audio/speech-to-speech")
+	speechToSpeechRouter.Use(middleware.RelayPanicRecover(), middleware.TokenAuth(), middleware.Project(), middleware.Sandbox(), middleware.FeatureFlag(model.FeatureSpeechToSpeech), middleware.TokenConcurrency())
+	{
+		speechToSpeechRouter.POST("", controller.SpeechToSpeech)
+	}
+	// the responses are relayed as chat completions, they are not stored to be retrieved later
+	responsesRouter := router.Group("/v1/responses")
+	responsesRouter.Use(middleware.Compress(), middleware.RelayPanicRecover(), middleware.Deadline(), middleware.StreamKeepAlive(), middleware.Responses(), middleware.TokenAuth(), middleware.Project(), middleware.RateLimitHeaders(), middleware.RequestDedup(), middleware.PlanLimit(), middleware.TokenConcurrency(), middleware.Chaos(), middleware.Sandbox(), middleware.Idempotency(), middleware.Conversation(), middleware.Transcript(), middleware.ModelDeprecation(), middleware.Experiment(), middleware.Distribute(), middleware.RequestDefaults(), middleware.ConstrainedModelSanitizer(), middleware.ResponseMetadata(), middleware.ResponseFilters(), middleware.Plugins())
+	{
+		responsesRouter.POST("", controller.Relay)
+	}
+	// ConstrainedModelSanitizer goes after the middlewares which may change the model or add parameters to the
+	// request, such as ModelDeprecation, Experiment and RequestDefaults
 	relayV1Router := router.Group("/v1")
-	relayV1Router.Use(middleware.RelayPanicRecover(), middleware.TokenAuth(), middleware.Distribute())
+	relayV1Router.Use(middleware.Compress(), middleware.RelayPanicRecover(), middleware.Deadline(), middleware.StreamKeepAlive(), middleware.TokenAuth(), middleware.Project(), controller.DeferredCompletion(), middleware.RateLimitHeaders(), middleware.RequestDedup(), middleware.PlanLimit(), middleware.TokenConcurrency(), middleware.Chaos(), middleware.Sandbox(), middleware.Idempotency(), middleware.Conversation(), middleware.Transcript(), middleware.ModelDeprecation(), middleware.Experiment(), middleware.Distribute(), middleware.RequestDefaults(), middleware.ConstrainedModelSanitizer(), middleware.ResponseMetadata(), middleware.ResponseFilters(), middleware.Plugins())
 	{
 		relayV1Router.Any("/oneapi/proxy/:channelid/*target", controller.Relay)
 		relayV1Router.POST("/completions", controller.Relay)
@@ -32,43 +153,10 @@ func SetRelayRouter(router *gin.Engine) {
 		relayV1Router.POST("/audio/transcriptions", controller.Relay)
 		relayV1Router.POST("/audio/translations", controller.Relay)
 		relayV1Router.POST("/audio/speech", controller.Relay)
-		relayV1Router.GET("/files", controller.RelayNotImplemented)
-		relayV1Router.POST("/files", controller.RelayNotImplemented)
-		relayV1Router.DELETE("/files/:id", controller.RelayNotImplemented)
-		relayV1Router.GET("/files/:id", controller.RelayNotImplemented)
-		relayV1Router.GET("/files/:id/content", controller.RelayNotImplemented)
-		relayV1Router.POST("/fine_tuning/jobs", controller.RelayNotImplemented)
-		relayV1Router.GET("/fine_tuning/jobs", controller.RelayNotImplemented)
-		relayV1Router.GET("/fine_tuning/jobs/:id", controller.RelayNotImplemented)
-		relayV1Router.POST("/fine_tuning/jobs/:id/cancel", controller.RelayNotImplemented)
-		relayV1Router.GET("/fine_tuning/jobs/:id/events", controller.RelayNotImplemented)
 		relayV1Router.DELETE("/models/:model", controller.RelayNotImplemented)
 		relayV1Router.POST("/moderations", controller.Relay)
-		relayV1Router.POST("/assistants", controller.RelayNotImplemented)
-		relayV1Router.GET("/assistants/:id", controller.RelayNotImplemented)
-		relayV1Router.POST("/assistants/:id", controller.RelayNotImplemented)
-		relayV1Router.DELETE("/assistants/:id", controller.RelayNotImplemented)
-		relayV1Router.GET("/assistants", controller.RelayNotImplemented)
-		relayV1Router.POST("/assistants/:id/files", controller.RelayNotImplemented)
-		relayV1Router.GET("/assistants/:id/files/:fileId", controller.RelayNotImplemented)
-		relayV1Router.DELETE("/assistants/:id/files/:fileId", controller.RelayNotImplemented)
-		relayV1Router.GET("/assistants/:id/files", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads/:id", controller.RelayNotImplemented)
-		relayV1Router.DELETE("/threads/:id", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads/:id/messages", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id/messages/:messageId", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads/:id/messages/:messageId", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id/messages/:messageId/files/:filesId", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id/messages/:messageId/files", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads/:id/runs", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id/runs/:runsId", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads/:id/runs/:runsId", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id/runs", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads/:id/runs/:runsId/submit_tool_outputs", controller.RelayNotImplemented)
-		relayV1Router.POST("/threads/:id/runs/:runsId/cancel", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id/runs/:runsId/steps/:stepId", controller.RelayNotImplemented)
-		relayV1Router.GET("/threads/:id/runs/:runsId/steps", controller.RelayNotImplemented)
+		relayV1Router.GET("/responses/:id", controller.RelayNotImplemented)
+		relayV1Router.DELETE("/responses/:id", controller.RelayNotImplemented)
+		relayV1Router.GET("/responses/:id/input_items", controller.RelayNotImplemented)
 	}
 }
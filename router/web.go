@@ -1,31 +1,41 @@
 package router
 
 import (
-	"embed"
-	"fmt"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-contrib/static"
 	"github.com/gin-gonic/gin"
-	"github.com/songquanpeng/one-api/common"
-	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/theme"
 	"github.com/songquanpeng/one-api/controller"
 	"github.com/songquanpeng/one-api/middleware"
 	"net/http"
 	"strings"
 )
 
-func SetWebRouter(router *gin.Engine, buildFS embed.FS) {
-	indexPageData, _ := buildFS.ReadFile(fmt.Sprintf("web/build/%s/index.html", config.Theme))
+// SetWebRouter serves the web console of the current theme, it is looked up on each request,
+// so that switching the theme takes effect at once
+func SetWebRouter(router *gin.Engine) {
 	router.Use(gzip.Gzip(gzip.DefaultCompression))
 	router.Use(middleware.GlobalWebRateLimit())
 	router.Use(middleware.Cache())
-	router.Use(static.Serve("/", common.EmbedFolder(buildFS, fmt.Sprintf("web/build/%s", config.Theme))))
+	// the index page itself is rewritten for the branding and BASE_PATH, instead of being served as a file
+	router.Use(func(c *gin.Context) {
+		if c.Request.URL.Path == "/" || c.Request.URL.Path == "/index.html" {
+			serveIndexPage(c)
+			c.Abort()
+		}
+	})
+	router.Use(static.Serve("/", theme.FileSystem{}))
 	router.NoRoute(func(c *gin.Context) {
 		if strings.HasPrefix(c.Request.RequestURI, "/v1") || strings.HasPrefix(c.Request.RequestURI, "/api") {
 			controller.RelayNotFound(c)
 			return
 		}
-		c.Header("Cache-Control", "no-cache")
-		c.Data(http.StatusOK, "text/html; charset=utf-8", indexPageData)
+		serveIndexPage(c)
 	})
 }
+
+func serveIndexPage(c *gin.Context) {
+	indexPageData, _ := theme.IndexPage()
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", indexPageData)
+}
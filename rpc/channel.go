@@ -0,0 +1,136 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/rpc/adminpb"
+)
+
+func deref[T any](p *T) T {
+	var v T
+	if p != nil {
+		v = *p
+	}
+	return v
+}
+
+func channelToPb(channel *model.Channel) *adminpb.Channel {
+	return &adminpb.Channel{
+		Id:           int64(channel.Id),
+		Type:         int32(channel.Type),
+		Key:          channel.Key,
+		Status:       int32(channel.Status),
+		Name:         channel.Name,
+		Weight:       uint32(deref(channel.Weight)),
+		BaseUrl:      channel.GetBaseURL(),
+		Models:       channel.Models,
+		Group:        channel.Group,
+		ModelMapping: deref(channel.ModelMapping),
+		Priority:     channel.GetPriority(),
+		Config:       channel.Config,
+		SystemPrompt: deref(channel.SystemPrompt),
+		Balance:      channel.Balance,
+		UsedQuota:    channel.UsedQuota,
+		CreatedTime:  channel.CreatedTime,
+		TestTime:     channel.TestTime,
+		ResponseTime: int32(channel.ResponseTime),
+	}
+}
+
+func channelFromPb(channel *adminpb.Channel) *model.Channel {
+	weight := uint(channel.Weight)
+	return &model.Channel{
+		Id:           int(channel.Id),
+		Type:         int(channel.Type),
+		Key:          channel.Key,
+		Status:       int(channel.Status),
+		Name:         channel.Name,
+		Weight:       &weight,
+		BaseURL:      &channel.BaseUrl,
+		Models:       channel.Models,
+		Group:        channel.Group,
+		ModelMapping: &channel.ModelMapping,
+		Priority:     &channel.Priority,
+		Config:       channel.Config,
+		SystemPrompt: &channel.SystemPrompt,
+	}
+}
+
+func (s *server) ListChannels(ctx context.Context, req *adminpb.ListRequest) (*adminpb.ListChannelsResponse, error) {
+	startIdx, num := pagination(req.Page, req.PageSize)
+	channels, err := model.GetAllChannels(startIdx, num, "limited")
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &adminpb.ListChannelsResponse{}
+	for _, channel := range channels {
+		resp.Channels = append(resp.Channels, channelToPb(channel))
+	}
+	return resp, nil
+}
+
+func (s *server) GetChannel(ctx context.Context, req *adminpb.IdRequest) (*adminpb.Channel, error) {
+	channel, err := model.GetChannelById(int(req.Id), false)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return channelToPb(channel), nil
+}
+
+func (s *server) CreateChannel(ctx context.Context, req *adminpb.Channel) (*adminpb.CreateChannelResponse, error) {
+	channel := channelFromPb(req)
+	channel.Id = 0
+	channel.CreatedTime = helper.GetTimestamp()
+	if channel.Status == 0 {
+		channel.Status = model.ChannelStatusEnabled
+	}
+	if channel.Group == "" {
+		channel.Group = "default"
+	}
+	keys := strings.Split(channel.Key, "\n")
+	channels := make([]model.Channel, 0, len(keys))
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		localChannel := *channel
+		localChannel.Key = key
+		channels = append(channels, localChannel)
+	}
+	if len(channels) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "key 不能为空")
+	}
+	if err := model.BatchInsertChannels(channels); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &adminpb.CreateChannelResponse{Count: int32(len(channels))}, nil
+}
+
+func (s *server) UpdateChannel(ctx context.Context, req *adminpb.Channel) (*adminpb.Channel, error) {
+	if _, err := model.GetChannelById(int(req.Id), false); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	channel := channelFromPb(req)
+	if err := channel.Update(); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	updated, err := model.GetChannelById(channel.Id, false)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return channelToPb(updated), nil
+}
+
+func (s *server) DeleteChannel(ctx context.Context, req *adminpb.IdRequest) (*adminpb.Empty, error) {
+	channel := model.Channel{Id: int(req.Id)}
+	if err := channel.Delete(); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &adminpb.Empty{}, nil
+}
@@ -0,0 +1,44 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/rpc/adminpb"
+)
+
+func logToPb(log *model.Log) *adminpb.Log {
+	return &adminpb.Log{
+		Id:               int64(log.Id),
+		UserId:           int64(log.UserId),
+		CreatedAt:        log.CreatedAt,
+		Type:             int32(log.Type),
+		Content:          log.Content,
+		Username:         log.Username,
+		TokenName:        log.TokenName,
+		ModelName:        log.ModelName,
+		Quota:            int64(log.Quota),
+		PromptTokens:     int32(log.PromptTokens),
+		CompletionTokens: int32(log.CompletionTokens),
+		ChannelId:        int64(log.ChannelId),
+		RequestId:        log.RequestId,
+		ElapsedTime:      log.ElapsedTime,
+		IsStream:         log.IsStream,
+	}
+}
+
+func (s *server) ListLogs(ctx context.Context, req *adminpb.ListLogsRequest) (*adminpb.ListLogsResponse, error) {
+	startIdx, num := pagination(req.Page, req.PageSize)
+	logs, err := model.GetAllLogs(int(req.Type), req.StartTimestamp, req.EndTimestamp, req.ModelName, req.Username, req.TokenName, startIdx, num, int(req.Channel), "")
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &adminpb.ListLogsResponse{}
+	for _, log := range logs {
+		resp.Logs = append(resp.Logs, logToPb(log))
+	}
+	return resp, nil
+}
@@ -0,0 +1,83 @@
+//go:generate protoc -I proto --go_out=adminpb --go_opt=paths=source_relative --go-grpc_out=adminpb --go-grpc_opt=paths=source_relative proto/admin.proto
+
+// Package rpc serves the management API over gRPC, see proto/admin.proto.
+package rpc
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/songquanpeng/one-api/common/blacklist"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/rpc/adminpb"
+)
+
+type callerKey struct{}
+
+type server struct {
+	adminpb.UnimplementedAdminServiceServer
+}
+
+var grpcServer *grpc.Server
+
+// authenticate accepts the same access token as the admin REST API
+func authenticate(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || values[0] == "" {
+		return nil, status.Error(codes.Unauthenticated, "未提供 access token")
+	}
+	user := model.ValidateAccessToken(values[0])
+	if user == nil || user.Username == "" {
+		return nil, status.Error(codes.Unauthenticated, "access token 无效")
+	}
+	if user.Status == model.UserStatusDisabled || blacklist.IsUserBanned(user.Id) {
+		return nil, status.Error(codes.PermissionDenied, "用户已被封禁")
+	}
+	if user.Role < model.RoleAdminUser {
+		return nil, status.Error(codes.PermissionDenied, "无权进行此操作，权限不足")
+	}
+	return handler(context.WithValue(ctx, callerKey{}, user), req)
+}
+
+func caller(ctx context.Context) *model.User {
+	return ctx.Value(callerKey{}).(*model.User)
+}
+
+func pagination(page int32, pageSize int32) (startIdx int, num int) {
+	if page < 0 {
+		page = 0
+	}
+	num = config.ItemsPerPage
+	if pageSize > 0 {
+		num = int(pageSize)
+	}
+	return int(page) * num, num
+}
+
+// Start serves the management API on GRPC_PORT, it blocks until Stop is called
+func Start() {
+	listener, err := net.Listen("tcp", ":"+config.GRPCPort)
+	if err != nil {
+		logger.FatalLog("failed to listen on gRPC port: " + err.Error())
+	}
+	grpcServer = grpc.NewServer(grpc.UnaryInterceptor(authenticate))
+	adminpb.RegisterAdminServiceServer(grpcServer, &server{})
+	logger.SysLogf("gRPC management API started on port %s", config.GRPCPort)
+	if err = grpcServer.Serve(listener); err != nil {
+		logger.SysError("gRPC server stopped: " + err.Error())
+	}
+}
+
+func Stop() {
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+}
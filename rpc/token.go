@@ -0,0 +1,172 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/network"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/rpc/adminpb"
+)
+
+func tokenToPb(token *model.Token) *adminpb.Token {
+	return &adminpb.Token{
+		Id:             int64(token.Id),
+		UserId:         int64(token.UserId),
+		Key:            token.Key,
+		Status:         int32(token.Status),
+		Name:           token.Name,
+		CreatedTime:    token.CreatedTime,
+		AccessedTime:   token.AccessedTime,
+		ExpiredTime:    token.ExpiredTime,
+		RemainQuota:    token.RemainQuota,
+		UnlimitedQuota: token.UnlimitedQuota,
+		UsedQuota:      token.UsedQuota,
+		Models:         deref(token.Models),
+		Subnet:         deref(token.Subnet),
+	}
+}
+
+func validateToken(token *adminpb.Token) error {
+	if len(token.Name) > 30 {
+		return status.Error(codes.InvalidArgument, "令牌名称过长")
+	}
+	if token.Subnet != "" {
+		if err := network.IsValidSubnets(token.Subnet); err != nil {
+			return status.Errorf(codes.InvalidArgument, "无效的网段：%s", err.Error())
+		}
+	}
+	return nil
+}
+
+// checkTokenOwner lets the caller manage its own tokens and those of the users it outranks, as for the users
+func checkTokenOwner(ctx context.Context, userId int) error {
+	me := caller(ctx)
+	if userId == me.Id {
+		return nil
+	}
+	owner, err := model.GetUserById(userId, false)
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if me.Role <= owner.Role {
+		return status.Error(codes.PermissionDenied, "无权管理同权限等级或更高权限等级用户的令牌")
+	}
+	return nil
+}
+
+func (s *server) ListTokens(ctx context.Context, req *adminpb.ListTokensRequest) (*adminpb.ListTokensResponse, error) {
+	userId := int(req.UserId)
+	if userId == 0 {
+		userId = caller(ctx).Id
+	}
+	if err := checkTokenOwner(ctx, userId); err != nil {
+		return nil, err
+	}
+	startIdx, num := pagination(req.Page, req.PageSize)
+	tokens, err := model.GetAllUserTokens(userId, startIdx, num, "")
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &adminpb.ListTokensResponse{}
+	for _, token := range tokens {
+		resp.Tokens = append(resp.Tokens, tokenToPb(token))
+	}
+	return resp, nil
+}
+
+func (s *server) GetToken(ctx context.Context, req *adminpb.IdRequest) (*adminpb.Token, error) {
+	token, err := model.GetTokenById(int(req.Id))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err = checkTokenOwner(ctx, token.UserId); err != nil {
+		return nil, err
+	}
+	return tokenToPb(token), nil
+}
+
+func (s *server) CreateToken(ctx context.Context, req *adminpb.Token) (*adminpb.Token, error) {
+	if err := validateToken(req); err != nil {
+		return nil, err
+	}
+	userId := int(req.UserId)
+	if userId == 0 {
+		userId = caller(ctx).Id
+	}
+	if err := checkTokenOwner(ctx, userId); err != nil {
+		return nil, err
+	}
+	expiredTime := req.ExpiredTime
+	if expiredTime == 0 {
+		expiredTime = -1
+	}
+	token := model.Token{
+		UserId:         userId,
+		Name:           req.Name,
+		Key:            random.GenerateKey(),
+		CreatedTime:    helper.GetTimestamp(),
+		AccessedTime:   helper.GetTimestamp(),
+		ExpiredTime:    expiredTime,
+		RemainQuota:    req.RemainQuota,
+		UnlimitedQuota: req.UnlimitedQuota,
+		Models:         &req.Models,
+		Subnet:         &req.Subnet,
+	}
+	if err := token.Insert(); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return tokenToPb(&token), nil
+}
+
+func (s *server) UpdateToken(ctx context.Context, req *adminpb.Token) (*adminpb.Token, error) {
+	if err := validateToken(req); err != nil {
+		return nil, err
+	}
+	token, err := model.GetTokenById(int(req.Id))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err = checkTokenOwner(ctx, token.UserId); err != nil {
+		return nil, err
+	}
+	if int(req.Status) == model.TokenStatusEnabled {
+		if token.Status == model.TokenStatusExpired && token.ExpiredTime <= helper.GetTimestamp() && token.ExpiredTime != -1 && req.ExpiredTime == token.ExpiredTime {
+			return nil, status.Error(codes.FailedPrecondition, "令牌已过期，无法启用，请先修改令牌过期时间，或者设置为永不过期")
+		}
+		if token.Status == model.TokenStatusExhausted && req.RemainQuota <= 0 && !req.UnlimitedQuota {
+			return nil, status.Error(codes.FailedPrecondition, "令牌可用额度已用尽，无法启用，请先修改令牌剩余额度，或者设置为无限额度")
+		}
+	}
+	if req.Status != 0 {
+		token.Status = int(req.Status)
+	}
+	token.Name = req.Name
+	token.ExpiredTime = req.ExpiredTime
+	token.RemainQuota = req.RemainQuota
+	token.UnlimitedQuota = req.UnlimitedQuota
+	token.Models = &req.Models
+	token.Subnet = &req.Subnet
+	if err = token.Update(); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return tokenToPb(token), nil
+}
+
+func (s *server) DeleteToken(ctx context.Context, req *adminpb.IdRequest) (*adminpb.Empty, error) {
+	token, err := model.GetTokenById(int(req.Id))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err = checkTokenOwner(ctx, token.UserId); err != nil {
+		return nil, err
+	}
+	if err = model.DeleteTokenById(token.Id, token.UserId); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &adminpb.Empty{}, nil
+}
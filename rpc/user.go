@@ -0,0 +1,140 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/rpc/adminpb"
+)
+
+func userToPb(user *model.User) *adminpb.User {
+	return &adminpb.User{
+		Id:           int64(user.Id),
+		Username:     user.Username,
+		DisplayName:  user.DisplayName,
+		Role:         int32(user.Role),
+		Status:       int32(user.Status),
+		Email:        user.Email,
+		Quota:        user.Quota,
+		UsedQuota:    user.UsedQuota,
+		RequestCount: int32(user.RequestCount),
+		Group:        user.Group,
+	}
+}
+
+func (s *server) ListUsers(ctx context.Context, req *adminpb.ListRequest) (*adminpb.ListUsersResponse, error) {
+	startIdx, num := pagination(req.Page, req.PageSize)
+	users, err := model.GetAllUsers(startIdx, num, "")
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &adminpb.ListUsersResponse{}
+	for _, user := range users {
+		resp.Users = append(resp.Users, userToPb(user))
+	}
+	return resp, nil
+}
+
+func (s *server) GetUser(ctx context.Context, req *adminpb.IdRequest) (*adminpb.User, error) {
+	user, err := model.GetUserById(int(req.Id), false)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return userToPb(user), nil
+}
+
+func (s *server) CreateUser(ctx context.Context, req *adminpb.User) (*adminpb.User, error) {
+	if req.Username == "" || req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "用户名和密码不能为空")
+	}
+	user := model.User{
+		Username:    req.Username,
+		Password:    req.Password,
+		DisplayName: req.DisplayName,
+	}
+	if user.DisplayName == "" {
+		user.DisplayName = user.Username
+	}
+	if err := common.Validate.Struct(&user); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if int(req.Role) >= caller(ctx).Role {
+		return nil, status.Error(codes.PermissionDenied, "无法创建权限大于等于自己的用户")
+	}
+	user.Role = int(req.Role)
+	if user.Role == model.RoleGuestUser {
+		user.Role = model.RoleCommonUser
+	}
+	if err := user.Insert(ctx, 0); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	created, err := model.GetUserById(user.Id, false)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return userToPb(created), nil
+}
+
+func (s *server) UpdateUser(ctx context.Context, req *adminpb.User) (*adminpb.User, error) {
+	originUser, err := model.GetUserById(int(req.Id), false)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	myRole := caller(ctx).Role
+	if myRole <= originUser.Role && myRole != model.RoleRootUser {
+		return nil, status.Error(codes.PermissionDenied, "无权更新同权限等级或更高权限等级的用户信息")
+	}
+	if myRole <= int(req.Role) && myRole != model.RoleRootUser {
+		return nil, status.Error(codes.PermissionDenied, "无权将其他用户权限等级提升到大于等于自己的权限等级")
+	}
+	user := model.User{
+		Id:          originUser.Id,
+		Username:    req.Username,
+		Password:    req.Password,
+		DisplayName: req.DisplayName,
+		Role:        int(req.Role),
+		Status:      int(req.Status),
+		Email:       req.Email,
+		Quota:       req.Quota,
+		Group:       req.Group,
+	}
+	if user.Password == "" {
+		user.Password = "$I_LOVE_U" // make Validator happy :)
+	}
+	if err = common.Validate.Struct(&user); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if user.Password == "$I_LOVE_U" {
+		user.Password = ""
+	}
+	if err = user.Update(user.Password != ""); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if originUser.Quota != user.Quota {
+		model.RecordLog(ctx, originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", common.LogQuota(originUser.Quota), common.LogQuota(user.Quota)))
+	}
+	updated, err := model.GetUserById(user.Id, false)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return userToPb(updated), nil
+}
+
+func (s *server) DeleteUser(ctx context.Context, req *adminpb.IdRequest) (*adminpb.Empty, error) {
+	originUser, err := model.GetUserById(int(req.Id), false)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if caller(ctx).Role <= originUser.Role {
+		return nil, status.Error(codes.PermissionDenied, "无权删除同权限等级或更高权限等级的用户")
+	}
+	if err = model.DeleteUserById(originUser.Id); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &adminpb.Empty{}, nil
+}
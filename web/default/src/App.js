@@ -19,13 +19,20 @@ import Channel from './pages/Channel';
 import Token from './pages/Token';
 import EditToken from './pages/Token/EditToken';
 import EditChannel from './pages/Channel/EditChannel';
+import ImportChannel from './pages/Channel/ImportChannel';
 import Redemption from './pages/Redemption';
 import EditRedemption from './pages/Redemption/EditRedemption';
 import TopUp from './pages/TopUp';
 import Log from './pages/Log';
 import Chat from './pages/Chat';
 import LarkOAuth from './components/LarkOAuth';
+import SAMLOAuth from './components/SAMLOAuth';
+import TwoFactorLogin from './components/TwoFactorLogin';
 import Dashboard from './pages/Dashboard';
+import Playground from './pages/Playground';
+import Status from './pages/Status';
+import Trash from './pages/Trash';
+import InFlight from './pages/InFlight';
 
 const Home = lazy(() => import('./pages/Home'));
 const About = lazy(() => import('./pages/About'));
@@ -54,6 +61,12 @@ function App() {
         localStorage.setItem('footer_html', data.footer_html);
         localStorage.setItem('quota_per_unit', data.quota_per_unit);
         localStorage.setItem('display_in_currency', data.display_in_currency);
+        localStorage.setItem('base_currency', data.base_currency);
+        localStorage.setItem(
+          'exchange_rates',
+          JSON.stringify(data.exchange_rates || {})
+        );
+        localStorage.setItem('status_page', data.status_page);
         if (data.chat_link) {
           localStorage.setItem('chat_link', data.chat_link);
         } else {
@@ -126,6 +139,14 @@ function App() {
           </Suspense>
         }
       />
+      <Route
+        path='/channel/import'
+        element={
+          <PrivateRoute>
+            <ImportChannel />
+          </PrivateRoute>
+        }
+      />
       <Route
         path='/token'
         element={
@@ -222,6 +243,14 @@ function App() {
           </Suspense>
         }
       />
+      <Route
+        path='/login/2fa'
+        element={
+          <Suspense fallback={<Loading></Loading>}>
+            <TwoFactorLogin />
+          </Suspense>
+        }
+      />
       <Route
         path='/register'
         element={
@@ -254,6 +283,14 @@ function App() {
           </Suspense>
         }
       />
+      <Route
+        path='/oauth/saml'
+        element={
+          <Suspense fallback={<Loading></Loading>}>
+            <SAMLOAuth />
+          </Suspense>
+        }
+      />
       <Route
         path='/setting'
         element={
@@ -290,6 +327,23 @@ function App() {
           </Suspense>
         }
       />
+      <Route path='/status' element={<Status />} />
+      <Route
+        path='/trash'
+        element={
+          <PrivateRoute>
+            <Trash />
+          </PrivateRoute>
+        }
+      />
+      <Route
+        path='/in_flight'
+        element={
+          <PrivateRoute>
+            <InFlight />
+          </PrivateRoute>
+        }
+      />
       <Route
         path='/chat'
         element={
@@ -298,6 +352,14 @@ function App() {
           </Suspense>
         }
       />
+      <Route
+        path='/playground'
+        element={
+          <PrivateRoute>
+            <Playground />
+          </PrivateRoute>
+        }
+      />
       <Route
         path='/dashboard'
         element={
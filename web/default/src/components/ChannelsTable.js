@@ -346,6 +346,16 @@ const ChannelsTable = () => {
     }
   };
 
+  const scanChannelKeys = async () => {
+    const res = await API.post(`/api/channel/key_scan`);
+    const { success, message } = res.data;
+    if (success) {
+      showInfo(t('channel.messages.key_scan_started'));
+    } else {
+      showError(message);
+    }
+  };
+
   const deleteAllDisabledChannels = async () => {
     const res = await API.delete(`/api/channel/disabled`);
     const { success, message, data } = res.data;
@@ -668,6 +678,20 @@ const ChannelsTable = () => {
               <Button size='tiny' as={Link} to='/channel/add' loading={loading}>
                 {t('channel.buttons.add')}
               </Button>
+              <Button
+                size='tiny'
+                as={Link}
+                to='/channel/import'
+                loading={loading}
+              >
+                {t('channel.buttons.import')}
+              </Button>
+              <Button size='tiny' as={Link} to='/trash' loading={loading}>
+                {t('channel.buttons.trash')}
+              </Button>
+              <Button size='tiny' as={Link} to='/in_flight' loading={loading}>
+                {t('channel.buttons.in_flight')}
+              </Button>
               <Button
                 size='tiny'
                 loading={loading}
@@ -686,6 +710,9 @@ const ChannelsTable = () => {
               >
                 {t('channel.buttons.test_disabled')}
               </Button>
+              <Button size='tiny' loading={loading} onClick={scanChannelKeys}>
+                {t('channel.buttons.key_scan')}
+              </Button>
               <Popup
                 trigger={
                   <Button size='tiny' loading={loading}>
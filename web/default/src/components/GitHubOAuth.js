@@ -3,6 +3,7 @@ import { Dimmer, Loader, Segment } from 'semantic-ui-react';
 import { useNavigate, useSearchParams } from 'react-router-dom';
 import { API, showError, showSuccess } from '../helpers';
 import { UserContext } from '../context/User';
+import { finishLogin } from './utils';
 
 const GitHubOAuth = () => {
   const [searchParams, setSearchParams] = useSearchParams();
@@ -20,11 +21,8 @@ const GitHubOAuth = () => {
       if (message === 'bind') {
         showSuccess('绑定成功！');
         navigate('/setting');
-      } else {
-        userDispatch({ type: 'login', payload: data });
-        localStorage.setItem('user', JSON.stringify(data));
+      } else if (finishLogin(message, data, userDispatch, navigate)) {
         showSuccess('登录成功！');
-        navigate('/');
       }
     } else {
       showError(message);
@@ -34,6 +34,11 @@ let headerButtons = [
     to: '/token',
     icon: 'key',
   },
+  {
+    name: 'header.playground',
+    to: '/playground',
+    icon: 'terminal',
+  },
   {
     name: 'header.redemption',
     to: '/redemption',
@@ -66,6 +71,12 @@ let headerButtons = [
     to: '/setting',
     icon: 'setting',
   },
+  {
+    name: 'header.status',
+    to: '/status',
+    icon: 'heartbeat',
+    statusPage: true,
+  },
   {
     name: 'header.about',
     to: '/about',
@@ -111,6 +122,12 @@ const Header = () => {
   const renderButtons = (isMobile) => {
     return headerButtons.map((button) => {
       if (button.admin && !isAdmin()) return <></>;
+      if (
+        button.statusPage &&
+        !isAdmin() &&
+        localStorage.getItem('status_page') !== 'true'
+      )
+        return <></>;
       if (isMobile) {
         return (
           <Menu.Item
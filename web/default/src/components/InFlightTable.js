@@ -0,0 +1,134 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { Button, Form, Popup, Table } from 'semantic-ui-react';
+import { API, showError, showSuccess } from '../helpers';
+
+// the requests are reloaded every few seconds as their elapsed time goes on
+const REFRESH_INTERVAL = 5000;
+
+const InFlightTable = () => {
+  const { t } = useTranslation();
+  const [requests, setRequests] = useState([]);
+  const [channelId, setChannelId] = useState('');
+  const [loading, setLoading] = useState(true);
+
+  const loadRequests = async () => {
+    const res = await API.get(`/api/in_flight/?channel_id=${channelId}`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setRequests(data || []);
+    } else {
+      showError(message);
+    }
+    setLoading(false);
+  };
+
+  useEffect(() => {
+    loadRequests().then();
+    const timer = setInterval(loadRequests, REFRESH_INTERVAL);
+    return () => clearInterval(timer);
+  }, [channelId]);
+
+  const cancelRequest = async (requestId) => {
+    const res = await API.delete(`/api/in_flight/${requestId}`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('in_flight.messages.cancel_success'));
+      await loadRequests();
+    } else {
+      showError(message);
+    }
+  };
+
+  const cancelChannel = async () => {
+    const res = await API.delete(`/api/in_flight/?channel_id=${channelId}`);
+    const { success, message, data } = res.data;
+    if (success) {
+      showSuccess(
+        t('in_flight.messages.cancel_channel_success', { count: data })
+      );
+      await loadRequests();
+    } else {
+      showError(message);
+    }
+  };
+
+  return (
+    <>
+      <Form>
+        <Form.Group inline>
+          <Form.Input
+            placeholder={t('in_flight.channel_placeholder')}
+            value={channelId}
+            onChange={(e, { value }) => setChannelId(value.trim())}
+          />
+          <Popup
+            trigger={
+              <Button size='small' negative disabled={channelId === ''}>
+                {t('in_flight.buttons.cancel_channel')}
+              </Button>
+            }
+            on='click'
+            flowing
+            hoverable
+          >
+            <Button negative onClick={cancelChannel}>
+              {t('in_flight.buttons.confirm_cancel_channel')}
+            </Button>
+          </Popup>
+        </Form.Group>
+      </Form>
+      <Table basic='very' compact size='small'>
+        <Table.Header>
+          <Table.Row>
+            <Table.HeaderCell>
+              {t('in_flight.table.request_id')}
+            </Table.HeaderCell>
+            <Table.HeaderCell>{t('in_flight.table.token')}</Table.HeaderCell>
+            <Table.HeaderCell>{t('in_flight.table.model')}</Table.HeaderCell>
+            <Table.HeaderCell>{t('in_flight.table.channel')}</Table.HeaderCell>
+            <Table.HeaderCell>{t('in_flight.table.elapsed')}</Table.HeaderCell>
+            <Table.HeaderCell>{t('in_flight.table.bytes')}</Table.HeaderCell>
+            <Table.HeaderCell>{t('in_flight.table.actions')}</Table.HeaderCell>
+          </Table.Row>
+        </Table.Header>
+        <Table.Body>
+          {requests.map((request) => (
+            <Table.Row key={request.request_id}>
+              <Table.Cell>{request.request_id}</Table.Cell>
+              <Table.Cell>
+                {request.token_name} (#{request.token_id})
+              </Table.Cell>
+              <Table.Cell>{request.model}</Table.Cell>
+              <Table.Cell>
+                {request.channel_id ? `#${request.channel_id}` : '-'}
+              </Table.Cell>
+              <Table.Cell>{request.elapsed.toFixed(1)}s</Table.Cell>
+              <Table.Cell>{request.bytes_streamed}</Table.Cell>
+              <Table.Cell>
+                <Button
+                  size='tiny'
+                  negative
+                  onClick={() => cancelRequest(request.request_id)}
+                >
+                  {t('in_flight.buttons.cancel')}
+                </Button>
+              </Table.Cell>
+            </Table.Row>
+          ))}
+        </Table.Body>
+        <Table.Footer>
+          <Table.Row>
+            <Table.HeaderCell colSpan='7'>
+              <Button size='small' loading={loading} onClick={loadRequests}>
+                {t('in_flight.buttons.refresh')}
+              </Button>
+            </Table.HeaderCell>
+          </Table.Row>
+        </Table.Footer>
+      </Table>
+    </>
+  );
+};
+
+export default InFlightTable;
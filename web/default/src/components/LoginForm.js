@@ -14,8 +14,19 @@ import {
 import { Link, useNavigate, useSearchParams } from 'react-router-dom';
 import { useTranslation } from 'react-i18next';
 import { UserContext } from '../context/User';
-import { API, getLogo, showError, showSuccess, showWarning } from '../helpers';
-import { onGitHubOAuthClicked, onLarkOAuthClicked } from './utils';
+import {
+  API,
+  BASE_PATH,
+  getLogo,
+  showError,
+  showSuccess,
+  showWarning,
+} from '../helpers';
+import {
+  finishLogin,
+  onGitHubOAuthClicked,
+  onLarkOAuthClicked,
+} from './utils';
 import larkIcon from '../images/lark.svg';
 
 const LoginForm = () => {
@@ -56,10 +67,9 @@ const LoginForm = () => {
     );
     const { success, message, data } = res.data;
     if (success) {
-      userDispatch({ type: 'login', payload: data });
-      localStorage.setItem('user', JSON.stringify(data));
-      navigate('/');
-      showSuccess(t('messages.success.login'));
+      if (finishLogin(message, data, userDispatch, navigate)) {
+        showSuccess(t('messages.success.login'));
+      }
       setShowWeChatLoginModal(false);
     } else {
       showError(message);
@@ -80,6 +90,10 @@ const LoginForm = () => {
       });
       const { success, message, data } = res.data;
       if (success) {
+        if (message === 'two_factor') {
+          navigate('/login/2fa');
+          return;
+        }
         userDispatch({ type: 'login', payload: data });
         localStorage.setItem('user', JSON.stringify(data));
         if (username === 'root' && password === '123456') {
@@ -87,7 +101,7 @@ const LoginForm = () => {
           showSuccess(t('messages.success.login'));
           showWarning(t('messages.error.root_password'));
         } else {
-          navigate('/token');
+          navigate(message === 'two_factor_setup' ? '/setting' : '/token');
           showSuccess(t('messages.success.login'));
         }
       } else {
@@ -184,7 +198,8 @@ const LoginForm = () => {
 
             {(status.github_oauth ||
               status.wechat_login ||
-              status.lark_client_id) && (
+              status.lark_client_id ||
+              status.saml) && (
               <>
                 <Divider
                   horizontal
@@ -218,6 +233,17 @@ const LoginForm = () => {
                       onClick={onWeChatLoginClicked}
                     />
                   )}
+                  {status.saml && (
+                    <Button
+                      circular
+                      color='blue'
+                      icon='building'
+                      title={t('auth.login.saml')}
+                      onClick={() =>
+                        (window.location.href = `${BASE_PATH}/api/saml/login`)
+                      }
+                    />
+                  )}
                   {status.lark_client_id && (
                     <div
                       style={{
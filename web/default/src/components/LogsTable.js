@@ -125,6 +125,33 @@ function renderDetail(log) {
           </Label>
         </>
       )}
+      {log.upstream_request_id && (
+        <Label
+          basic
+          size={'mini'}
+          title={log.upstream_request_id}
+          style={{ cursor: 'pointer' }}
+          onClick={async () => {
+            if (await copy(log.upstream_request_id)) {
+              showSuccess(`已复制上游请求 ID：${log.upstream_request_id}`);
+            } else {
+              showWarning(`上游请求 ID 复制失败：${log.upstream_request_id}`);
+            }
+          }}
+        >
+          Upstream ID
+        </Label>
+      )}
+      {log.upstream_account && (
+        <Label basic size={'mini'} title={log.upstream_account}>
+          Upstream Account
+        </Label>
+      )}
+      {log.project && (
+        <Label basic size={'mini'} color='teal' title={log.project}>
+          {log.project}
+        </Label>
+      )}
     </>
   );
 }
@@ -147,6 +174,7 @@ const LogsTable = () => {
     start_timestamp: timestamp2string(0),
     end_timestamp: timestamp2string(now.getTime() / 1000 + 3600),
     channel: '',
+    project: '',
   });
   const {
     username,
@@ -155,6 +183,7 @@ const LogsTable = () => {
     start_timestamp,
     end_timestamp,
     channel,
+    project,
   } = inputs;
 
   const [stat, setStat] = useState({
@@ -179,7 +208,7 @@ const LogsTable = () => {
     let localStartTimestamp = Date.parse(start_timestamp) / 1000;
     let localEndTimestamp = Date.parse(end_timestamp) / 1000;
     let res = await API.get(
-      `/api/log/self/stat?type=${logType}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}`
+      `/api/log/self/stat?type=${logType}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&project=${project}`
     );
     const { success, message, data } = res.data;
     if (success) {
@@ -193,7 +222,7 @@ const LogsTable = () => {
     let localStartTimestamp = Date.parse(start_timestamp) / 1000;
     let localEndTimestamp = Date.parse(end_timestamp) / 1000;
     let res = await API.get(
-      `/api/log/stat?type=${logType}&username=${username}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&channel=${channel}`
+      `/api/log/stat?type=${logType}&username=${username}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&channel=${channel}&project=${project}`
     );
     const { success, message, data } = res.data;
     if (success) {
@@ -223,9 +252,9 @@ const LogsTable = () => {
     let localStartTimestamp = Date.parse(start_timestamp) / 1000;
     let localEndTimestamp = Date.parse(end_timestamp) / 1000;
     if (isAdminUser) {
-      url = `/api/log/?p=${startIdx}&type=${logType}&username=${username}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&channel=${channel}`;
+      url = `/api/log/?p=${startIdx}&type=${logType}&username=${username}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&channel=${channel}&project=${project}`;
     } else {
-      url = `/api/log/self/?p=${startIdx}&type=${logType}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}`;
+      url = `/api/log/self/?p=${startIdx}&type=${logType}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&project=${project}`;
     }
     const res = await API.get(url);
     const { success, message, data } = res.data;
@@ -375,9 +404,19 @@ const LogsTable = () => {
             {t('log.buttons.submit')}
           </Form.Button>
         </Form.Group>
-        {isAdminUser && (
-          <>
-            <Form.Group>
+        <Form.Group>
+          <Form.Input
+            fluid
+            label={t('log.table.project')}
+            size={'small'}
+            width={3}
+            value={project}
+            placeholder={t('log.table.project_placeholder')}
+            name='project'
+            onChange={handleInputChange}
+          />
+          {isAdminUser && (
+            <>
               <Form.Input
                 fluid
                 label={t('log.table.channel_id')}
@@ -398,9 +437,9 @@ const LogsTable = () => {
                 name='username'
                 onChange={handleInputChange}
               />
-            </Form.Group>
-          </>
-        )}
+            </>
+          )}
+        </Form.Group>
         <Form.Input
           icon='search'
           placeholder={t('log.search')}
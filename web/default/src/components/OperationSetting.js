@@ -18,20 +18,71 @@ const OperationSetting = () => {
     QuotaForInvitee: 0,
     QuotaRemindThreshold: 0,
     PreConsumedQuota: 0,
+    NewUserQuotaEmailRequiredEnabled: '',
+    InviterTopUpRewardRate: 0,
+    InviteRewardDedupeEnabled: '',
+    QuotaTransferEnabled: '',
+    QuotaTransferMinQuota: 0,
+    QuotaTransferMaxQuota: 0,
+    QuotaTransferDailyQuota: 0,
+    QuotaTransferFeeRate: 0,
+    FreeRequestAllowances: '',
     ModelRatio: '',
     CompletionRatio: '',
+    ModelMaxTokens: '',
+    ModelContextWindows: '',
+    ModelCapabilities: '',
+    ModelRelayProfiles: '',
+    ModelDeprecations: '',
+    ErrorMessages: '',
+    SandboxFixtures: '',
+    FaultInjections: '',
     GroupRatio: '',
+    GroupModelRatio: '',
+    GroupInheritance: '',
+    FineTuningRatio: '',
+    FineTuningModel: '',
+    FileMaxSize: 0,
+    FileUserStorageLimit: 0,
+    FileRetentionDays: 0,
+    VectorStoreEmbeddingModel: '',
+    VectorStoreChunkSize: 0,
+    VectorStoreChunkOverlap: 0,
+    RagPromptTemplate: '',
+    JudgeModel: '',
+    JudgePrompt: '',
+    CouncilModels: '',
+    CouncilJudgePrompt: '',
+    LongContextSummaryModel: '',
+    LongContextSummaryPrompt: '',
     TopUpLink: '',
     ChatLink: '',
     QuotaPerUnit: 0,
+    BaseCurrency: '',
+    ExchangeRates: '',
+    ExchangeRateURL: '',
     AutomaticDisableChannelEnabled: '',
     AutomaticEnableChannelEnabled: '',
     ChannelDisableThreshold: 0,
+    ResponseValidationEnabled: '',
+    MalformedResponseThreshold: 0,
+    MalformedResponseWindow: 0,
+    StatusPageEnabled: '',
+    StatusPageModels: '',
+    StatusPageWindowHours: 0,
     LogConsumeEnabled: '',
     DisplayInCurrencyEnabled: '',
     DisplayTokenStatEnabled: '',
     ApproximateTokenEnabled: '',
     RetryTimes: 0,
+    RequestDedupWindow: 0,
+    TokenExpiryRemindDays: 0,
+    MonthlyStatementEnabled: '',
+    TokenAnomalyDetectionEnabled: '',
+    TokenAnomalySpikeFactor: 0,
+    TokenAnomalyNightHours: '',
+    NotificationTemplates: '',
+    LogRedactionRules: '',
   });
   const [originInputs, setOriginInputs] = useState({});
   let [loading, setLoading] = useState(false);
@@ -48,11 +99,26 @@ const OperationSetting = () => {
         if (
           item.key === 'ModelRatio' ||
           item.key === 'GroupRatio' ||
-          item.key === 'CompletionRatio'
+          item.key === 'GroupModelRatio' ||
+          item.key === 'GroupInheritance' ||
+          item.key === 'CompletionRatio' ||
+          item.key === 'ModelMaxTokens' ||
+          item.key === 'ModelContextWindows' ||
+          item.key === 'ModelCapabilities' ||
+          item.key === 'ModelRelayProfiles' ||
+          item.key === 'ModelDeprecations' ||
+          item.key === 'ErrorMessages' ||
+          item.key === 'SandboxFixtures' ||
+          item.key === 'FaultInjections' ||
+          item.key === 'FineTuningRatio' ||
+          item.key === 'FreeRequestAllowances' ||
+          item.key === 'ExchangeRates' ||
+          item.key === 'NotificationTemplates' ||
+          item.key === 'LogRedactionRules'
         ) {
           item.value = JSON.stringify(JSON.parse(item.value), null, 2);
         }
-        if (item.value === '{}') {
+        if (item.value === '{}' || item.value === '[]') {
           item.value = '';
         }
         newInputs[item.key] = item.value;
@@ -94,6 +160,17 @@ const OperationSetting = () => {
     }
   };
 
+  const syncExchangeRates = async () => {
+    const res = await API.post('/api/option/exchange_rates/sync');
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('setting.operation.general.exchange_rates_synced'));
+      await getOptions();
+    } else {
+      showError(message);
+    }
+  };
+
   const submitConfig = async (group) => {
     switch (group) {
       case 'monitor':
@@ -114,6 +191,35 @@ const OperationSetting = () => {
             inputs.QuotaRemindThreshold
           );
         }
+        if (
+          originInputs['MalformedResponseThreshold'] !==
+          inputs.MalformedResponseThreshold
+        ) {
+          await updateOption(
+            'MalformedResponseThreshold',
+            inputs.MalformedResponseThreshold
+          );
+        }
+        if (
+          originInputs['MalformedResponseWindow'] !==
+          inputs.MalformedResponseWindow
+        ) {
+          await updateOption(
+            'MalformedResponseWindow',
+            inputs.MalformedResponseWindow
+          );
+        }
+        if (originInputs['StatusPageModels'] !== inputs.StatusPageModels) {
+          await updateOption('StatusPageModels', inputs.StatusPageModels);
+        }
+        if (
+          originInputs['StatusPageWindowHours'] !== inputs.StatusPageWindowHours
+        ) {
+          await updateOption(
+            'StatusPageWindowHours',
+            inputs.StatusPageWindowHours
+          );
+        }
         break;
       case 'ratio':
         if (originInputs['ModelRatio'] !== inputs.ModelRatio) {
@@ -130,6 +236,20 @@ const OperationSetting = () => {
           }
           await updateOption('GroupRatio', inputs.GroupRatio);
         }
+        if (originInputs['GroupModelRatio'] !== inputs.GroupModelRatio) {
+          if (!verifyJSON(inputs.GroupModelRatio)) {
+            showError('分组模型倍率不是合法的 JSON 字符串');
+            return;
+          }
+          await updateOption('GroupModelRatio', inputs.GroupModelRatio);
+        }
+        if (originInputs['GroupInheritance'] !== inputs.GroupInheritance) {
+          if (!verifyJSON(inputs.GroupInheritance)) {
+            showError('分组继承不是合法的 JSON 字符串');
+            return;
+          }
+          await updateOption('GroupInheritance', inputs.GroupInheritance);
+        }
         if (originInputs['CompletionRatio'] !== inputs.CompletionRatio) {
           if (!verifyJSON(inputs.CompletionRatio)) {
             showError('补全倍率不是合法的 JSON 字符串');
@@ -137,6 +257,77 @@ const OperationSetting = () => {
           }
           await updateOption('CompletionRatio', inputs.CompletionRatio);
         }
+        if (originInputs['ModelMaxTokens'] !== inputs.ModelMaxTokens) {
+          if (!verifyJSON(inputs.ModelMaxTokens)) {
+            showError('模型最大输出 token 数不是合法的 JSON 字符串');
+            return;
+          }
+          await updateOption('ModelMaxTokens', inputs.ModelMaxTokens);
+        }
+        if (
+          originInputs['ModelContextWindows'] !== inputs.ModelContextWindows
+        ) {
+          if (!verifyJSON(inputs.ModelContextWindows)) {
+            showError('模型上下文长度不是合法的 JSON 字符串');
+            return;
+          }
+          await updateOption(
+            'ModelContextWindows',
+            inputs.ModelContextWindows
+          );
+        }
+        if (originInputs['ModelCapabilities'] !== inputs.ModelCapabilities) {
+          if (!verifyJSON(inputs.ModelCapabilities)) {
+            showError('模型能力表不是合法的 JSON 字符串');
+            return;
+          }
+          await updateOption('ModelCapabilities', inputs.ModelCapabilities);
+        }
+        if (originInputs['ModelRelayProfiles'] !== inputs.ModelRelayProfiles) {
+          if (!verifyJSON(inputs.ModelRelayProfiles)) {
+            showError('模型超时与重试不是合法的 JSON 字符串');
+            return;
+          }
+          await updateOption('ModelRelayProfiles', inputs.ModelRelayProfiles);
+        }
+        if (originInputs['ModelDeprecations'] !== inputs.ModelDeprecations) {
+          if (!verifyJSON(inputs.ModelDeprecations)) {
+            showError('模型替换表不是合法的 JSON 字符串');
+            return;
+          }
+          await updateOption('ModelDeprecations', inputs.ModelDeprecations);
+        }
+        if (originInputs['ErrorMessages'] !== inputs.ErrorMessages) {
+          if (!verifyJSON(inputs.ErrorMessages)) {
+            showError('错误信息映射不是合法的 JSON 字符串');
+            return;
+          }
+          await updateOption('ErrorMessages', inputs.ErrorMessages);
+        }
+        if (originInputs['SandboxFixtures'] !== inputs.SandboxFixtures) {
+          if (!verifyJSON(inputs.SandboxFixtures)) {
+            showError('沙盒响应不是合法的 JSON 字符串');
+            return;
+          }
+          await updateOption('SandboxFixtures', inputs.SandboxFixtures);
+        }
+        if (originInputs['FaultInjections'] !== inputs.FaultInjections) {
+          if (!verifyJSON(inputs.FaultInjections)) {
+            showError('故障注入不是合法的 JSON 字符串');
+            return;
+          }
+          await updateOption('FaultInjections', inputs.FaultInjections);
+        }
+        if (originInputs['FineTuningRatio'] !== inputs.FineTuningRatio) {
+          if (!verifyJSON(inputs.FineTuningRatio)) {
+            showError('微调倍率不是合法的 JSON 字符串');
+            return;
+          }
+          await updateOption('FineTuningRatio', inputs.FineTuningRatio);
+        }
+        if (originInputs['FineTuningModel'] !== inputs.FineTuningModel) {
+          await updateOption('FineTuningModel', inputs.FineTuningModel);
+        }
         break;
       case 'quota':
         if (originInputs['QuotaForNewUser'] !== inputs.QuotaForNewUser) {
@@ -151,6 +342,92 @@ const OperationSetting = () => {
         if (originInputs['PreConsumedQuota'] !== inputs.PreConsumedQuota) {
           await updateOption('PreConsumedQuota', inputs.PreConsumedQuota);
         }
+        if (
+          originInputs['InviterTopUpRewardRate'] !==
+          inputs.InviterTopUpRewardRate
+        ) {
+          await updateOption(
+            'InviterTopUpRewardRate',
+            inputs.InviterTopUpRewardRate
+          );
+        }
+        for (const key of [
+          'QuotaTransferMinQuota',
+          'QuotaTransferMaxQuota',
+          'QuotaTransferDailyQuota',
+          'QuotaTransferFeeRate',
+        ]) {
+          if (originInputs[key] !== inputs[key]) {
+            await updateOption(key, inputs[key]);
+          }
+        }
+        if (
+          originInputs['FreeRequestAllowances'] !== inputs.FreeRequestAllowances
+        ) {
+          if (!verifyJSON(inputs.FreeRequestAllowances)) {
+            showError('每日免费额度不是合法的 JSON 字符串');
+            return;
+          }
+          await updateOption(
+            'FreeRequestAllowances',
+            inputs.FreeRequestAllowances
+          );
+        }
+        break;
+      case 'notification':
+        if (
+          originInputs['TokenExpiryRemindDays'] !== inputs.TokenExpiryRemindDays
+        ) {
+          await updateOption(
+            'TokenExpiryRemindDays',
+            inputs.TokenExpiryRemindDays
+          );
+        }
+        if (
+          originInputs['TokenAnomalySpikeFactor'] !==
+          inputs.TokenAnomalySpikeFactor
+        ) {
+          await updateOption(
+            'TokenAnomalySpikeFactor',
+            inputs.TokenAnomalySpikeFactor
+          );
+        }
+        if (
+          originInputs['TokenAnomalyNightHours'] !==
+          inputs.TokenAnomalyNightHours
+        ) {
+          await updateOption(
+            'TokenAnomalyNightHours',
+            inputs.TokenAnomalyNightHours
+          );
+        }
+        if (
+          originInputs['NotificationTemplates'] !== inputs.NotificationTemplates
+        ) {
+          if (!verifyJSON(inputs.NotificationTemplates)) {
+            showError('通知模板不是合法的 JSON 字符串');
+            return;
+          }
+          await updateOption(
+            'NotificationTemplates',
+            inputs.NotificationTemplates
+          );
+        }
+        break;
+      case 'log':
+        if (originInputs['LogRedactionRules'] !== inputs.LogRedactionRules) {
+          if (
+            inputs.LogRedactionRules &&
+            !verifyJSON(inputs.LogRedactionRules)
+          ) {
+            showError(t('setting.operation.log.redaction_rules.invalid'));
+            return;
+          }
+          await updateOption(
+            'LogRedactionRules',
+            inputs.LogRedactionRules || '[]'
+          );
+        }
         break;
       case 'general':
         if (originInputs['TopUpLink'] !== inputs.TopUpLink) {
@@ -162,9 +439,98 @@ const OperationSetting = () => {
         if (originInputs['QuotaPerUnit'] !== inputs.QuotaPerUnit) {
           await updateOption('QuotaPerUnit', inputs.QuotaPerUnit);
         }
+        if (originInputs['BaseCurrency'] !== inputs.BaseCurrency) {
+          await updateOption('BaseCurrency', inputs.BaseCurrency);
+        }
+        if (originInputs['ExchangeRates'] !== inputs.ExchangeRates) {
+          if (inputs.ExchangeRates && !verifyJSON(inputs.ExchangeRates)) {
+            showError(t('setting.operation.general.exchange_rates_invalid'));
+            return;
+          }
+          await updateOption('ExchangeRates', inputs.ExchangeRates || '{}');
+        }
+        if (originInputs['ExchangeRateURL'] !== inputs.ExchangeRateURL) {
+          await updateOption('ExchangeRateURL', inputs.ExchangeRateURL);
+        }
         if (originInputs['RetryTimes'] !== inputs.RetryTimes) {
           await updateOption('RetryTimes', inputs.RetryTimes);
         }
+        if (originInputs['RequestDedupWindow'] !== inputs.RequestDedupWindow) {
+          await updateOption('RequestDedupWindow', inputs.RequestDedupWindow);
+        }
+        if (originInputs['FileMaxSize'] !== inputs.FileMaxSize) {
+          await updateOption('FileMaxSize', inputs.FileMaxSize);
+        }
+        if (
+          originInputs['FileUserStorageLimit'] !== inputs.FileUserStorageLimit
+        ) {
+          await updateOption(
+            'FileUserStorageLimit',
+            inputs.FileUserStorageLimit
+          );
+        }
+        if (originInputs['FileRetentionDays'] !== inputs.FileRetentionDays) {
+          await updateOption('FileRetentionDays', inputs.FileRetentionDays);
+        }
+        if (
+          originInputs['VectorStoreEmbeddingModel'] !==
+          inputs.VectorStoreEmbeddingModel
+        ) {
+          await updateOption(
+            'VectorStoreEmbeddingModel',
+            inputs.VectorStoreEmbeddingModel
+          );
+        }
+        if (
+          originInputs['VectorStoreChunkSize'] !== inputs.VectorStoreChunkSize
+        ) {
+          await updateOption(
+            'VectorStoreChunkSize',
+            inputs.VectorStoreChunkSize
+          );
+        }
+        if (
+          originInputs['VectorStoreChunkOverlap'] !==
+          inputs.VectorStoreChunkOverlap
+        ) {
+          await updateOption(
+            'VectorStoreChunkOverlap',
+            inputs.VectorStoreChunkOverlap
+          );
+        }
+        if (originInputs['RagPromptTemplate'] !== inputs.RagPromptTemplate) {
+          await updateOption('RagPromptTemplate', inputs.RagPromptTemplate);
+        }
+        if (originInputs['JudgeModel'] !== inputs.JudgeModel) {
+          await updateOption('JudgeModel', inputs.JudgeModel);
+        }
+        if (originInputs['JudgePrompt'] !== inputs.JudgePrompt) {
+          await updateOption('JudgePrompt', inputs.JudgePrompt);
+        }
+        if (originInputs['CouncilModels'] !== inputs.CouncilModels) {
+          await updateOption('CouncilModels', inputs.CouncilModels);
+        }
+        if (originInputs['CouncilJudgePrompt'] !== inputs.CouncilJudgePrompt) {
+          await updateOption('CouncilJudgePrompt', inputs.CouncilJudgePrompt);
+        }
+        if (
+          originInputs['LongContextSummaryModel'] !==
+          inputs.LongContextSummaryModel
+        ) {
+          await updateOption(
+            'LongContextSummaryModel',
+            inputs.LongContextSummaryModel
+          );
+        }
+        if (
+          originInputs['LongContextSummaryPrompt'] !==
+          inputs.LongContextSummaryPrompt
+        ) {
+          await updateOption(
+            'LongContextSummaryPrompt',
+            inputs.LongContextSummaryPrompt
+          );
+        }
         break;
     }
   };
@@ -233,6 +599,105 @@ const OperationSetting = () => {
               )}
             />
           </Form.Group>
+          <Form.Group widths={4}>
+            <Form.Input
+              label={t('setting.operation.quota.inviter_topup_reward')}
+              name='InviterTopUpRewardRate'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.InviterTopUpRewardRate}
+              type='number'
+              step='0.01'
+              min='0'
+              placeholder={t(
+                'setting.operation.quota.inviter_topup_reward_placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths={4}>
+            <Form.Input
+              label={t('setting.operation.quota.transfer_min')}
+              name='QuotaTransferMinQuota'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.QuotaTransferMinQuota}
+              type='number'
+              min='0'
+              placeholder={t(
+                'setting.operation.quota.transfer_min_placeholder'
+              )}
+            />
+            <Form.Input
+              label={t('setting.operation.quota.transfer_max')}
+              name='QuotaTransferMaxQuota'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.QuotaTransferMaxQuota}
+              type='number'
+              min='0'
+              placeholder={t(
+                'setting.operation.quota.transfer_max_placeholder'
+              )}
+            />
+            <Form.Input
+              label={t('setting.operation.quota.transfer_daily')}
+              name='QuotaTransferDailyQuota'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.QuotaTransferDailyQuota}
+              type='number'
+              min='0'
+              placeholder={t(
+                'setting.operation.quota.transfer_daily_placeholder'
+              )}
+            />
+            <Form.Input
+              label={t('setting.operation.quota.transfer_fee_rate')}
+              name='QuotaTransferFeeRate'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.QuotaTransferFeeRate}
+              type='number'
+              step='0.01'
+              min='0'
+              placeholder={t(
+                'setting.operation.quota.transfer_fee_rate_placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group inline>
+            <Form.Checkbox
+              checked={inputs.NewUserQuotaEmailRequiredEnabled === 'true'}
+              label={t('setting.operation.quota.new_user_email_required')}
+              name='NewUserQuotaEmailRequiredEnabled'
+              onChange={handleInputChange}
+            />
+            <Form.Checkbox
+              checked={inputs.InviteRewardDedupeEnabled === 'true'}
+              label={t('setting.operation.quota.invite_reward_dedupe')}
+              name='InviteRewardDedupeEnabled'
+              onChange={handleInputChange}
+            />
+            <Form.Checkbox
+              checked={inputs.QuotaTransferEnabled === 'true'}
+              label={t('setting.operation.quota.transfer_enabled')}
+              name='QuotaTransferEnabled'
+              onChange={handleInputChange}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.quota.free_allowances.title')}
+              name='FreeRequestAllowances'
+              onChange={handleInputChange}
+              style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.FreeRequestAllowances}
+              placeholder={t(
+                'setting.operation.quota.free_allowances.placeholder'
+              )}
+            />
+          </Form.Group>
           <Form.Button
             onClick={() => {
               submitConfig('quota').then();
@@ -266,50 +731,285 @@ const OperationSetting = () => {
           </Form.Group>
           <Form.Group widths='equal'>
             <Form.TextArea
-              label={t('setting.operation.ratio.group.title')}
-              name='GroupRatio'
+              label={t('setting.operation.ratio.max_tokens.title')}
+              name='ModelMaxTokens'
               onChange={handleInputChange}
-              style={{ minHeight: 250, fontFamily: 'JetBrains Mono, Consolas' }}
+              style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
               autoComplete='new-password'
-              value={inputs.GroupRatio}
-              placeholder={t('setting.operation.ratio.group.placeholder')}
+              value={inputs.ModelMaxTokens}
+              placeholder={t('setting.operation.ratio.max_tokens.placeholder')}
             />
           </Form.Group>
-          <Form.Button
-            onClick={() => {
-              submitConfig('ratio').then();
-            }}
-          >
-            {t('setting.operation.ratio.buttons.save')}
-          </Form.Button>
-          <Divider />
-          <Header as='h3'>{t('setting.operation.log.title')}</Header>
-          <Form.Group inline>
-            <Form.Checkbox
-              checked={inputs.LogConsumeEnabled === 'true'}
-              label={t('setting.operation.log.enable_consume')}
-              name='LogConsumeEnabled'
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.ratio.context_windows.title')}
+              name='ModelContextWindows'
               onChange={handleInputChange}
+              style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.ModelContextWindows}
+              placeholder={t(
+                'setting.operation.ratio.context_windows.placeholder'
+              )}
             />
           </Form.Group>
-          <Form.Group widths={4}>
-            <Form.Input
-              label={t('setting.operation.log.target_time')}
-              value={historyTimestamp}
-              type='datetime-local'
-              name='history_timestamp'
-              onChange={(e, { name, value }) => {
-                setHistoryTimestamp(value);
-              }}
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.ratio.capabilities.title')}
+              name='ModelCapabilities'
+              onChange={handleInputChange}
+              style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.ModelCapabilities}
+              placeholder={t('setting.operation.ratio.capabilities.placeholder')}
             />
           </Form.Group>
-          <Form.Button
-            onClick={() => {
-              deleteHistoryLogs().then();
-            }}
-          >
-            {t('setting.operation.log.buttons.clean')}
-          </Form.Button>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.ratio.relay_profiles.title')}
+              name='ModelRelayProfiles'
+              onChange={handleInputChange}
+              style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.ModelRelayProfiles}
+              placeholder={t(
+                'setting.operation.ratio.relay_profiles.placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.ratio.deprecations.title')}
+              name='ModelDeprecations'
+              onChange={handleInputChange}
+              style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.ModelDeprecations}
+              placeholder={t('setting.operation.ratio.deprecations.placeholder')}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.ratio.error_messages.title')}
+              name='ErrorMessages'
+              onChange={handleInputChange}
+              style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.ErrorMessages}
+              placeholder={t(
+                'setting.operation.ratio.error_messages.placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.ratio.sandbox_fixtures.title')}
+              name='SandboxFixtures'
+              onChange={handleInputChange}
+              style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.SandboxFixtures}
+              placeholder={t(
+                'setting.operation.ratio.sandbox_fixtures.placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.ratio.fault_injections.title')}
+              name='FaultInjections'
+              onChange={handleInputChange}
+              style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.FaultInjections}
+              placeholder={t(
+                'setting.operation.ratio.fault_injections.placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.ratio.group.title')}
+              name='GroupRatio'
+              onChange={handleInputChange}
+              style={{ minHeight: 250, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.GroupRatio}
+              placeholder={t('setting.operation.ratio.group.placeholder')}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.ratio.group_model.title')}
+              name='GroupModelRatio'
+              onChange={handleInputChange}
+              style={{ minHeight: 250, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.GroupModelRatio}
+              placeholder={t('setting.operation.ratio.group_model.placeholder')}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.ratio.group_inheritance.title')}
+              name='GroupInheritance'
+              onChange={handleInputChange}
+              style={{ minHeight: 250, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.GroupInheritance}
+              placeholder={t(
+                'setting.operation.ratio.group_inheritance.placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.ratio.fine_tuning.title')}
+              name='FineTuningRatio'
+              onChange={handleInputChange}
+              style={{ minHeight: 250, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.FineTuningRatio}
+              placeholder={t('setting.operation.ratio.fine_tuning.placeholder')}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.Input
+              label={t('setting.operation.ratio.fine_tuning_model.title')}
+              name='FineTuningModel'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.FineTuningModel}
+              placeholder={t(
+                'setting.operation.ratio.fine_tuning_model.placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Button
+            onClick={() => {
+              submitConfig('ratio').then();
+            }}
+          >
+            {t('setting.operation.ratio.buttons.save')}
+          </Form.Button>
+          <Divider />
+          <Header as='h3'>{t('setting.operation.notification.title')}</Header>
+          <Form.Group inline>
+            <Form.Checkbox
+              checked={inputs.MonthlyStatementEnabled === 'true'}
+              label={t('setting.operation.notification.monthly_statement')}
+              name='MonthlyStatementEnabled'
+              onChange={handleInputChange}
+            />
+            <Form.Checkbox
+              checked={inputs.TokenAnomalyDetectionEnabled === 'true'}
+              label={t('setting.operation.notification.token_anomaly')}
+              name='TokenAnomalyDetectionEnabled'
+              onChange={handleInputChange}
+            />
+          </Form.Group>
+          <Form.Group widths={4}>
+            <Form.Input
+              label={t('setting.operation.notification.token_expiry_days')}
+              name='TokenExpiryRemindDays'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.TokenExpiryRemindDays}
+              type='number'
+              min='0'
+              placeholder={t(
+                'setting.operation.notification.token_expiry_days_placeholder'
+              )}
+            />
+            <Form.Input
+              label={t('setting.operation.notification.token_anomaly_factor')}
+              name='TokenAnomalySpikeFactor'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.TokenAnomalySpikeFactor}
+              type='number'
+              min='1'
+              placeholder={t(
+                'setting.operation.notification.token_anomaly_factor_placeholder'
+              )}
+            />
+            <Form.Input
+              label={t('setting.operation.notification.token_anomaly_night')}
+              name='TokenAnomalyNightHours'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.TokenAnomalyNightHours}
+              placeholder={t(
+                'setting.operation.notification.token_anomaly_night_placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.notification.templates.title')}
+              name='NotificationTemplates'
+              onChange={handleInputChange}
+              style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.NotificationTemplates}
+              placeholder={t(
+                'setting.operation.notification.templates.placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Button
+            onClick={() => {
+              submitConfig('notification').then();
+            }}
+          >
+            {t('setting.operation.notification.buttons.save')}
+          </Form.Button>
+          <Divider />
+          <Header as='h3'>{t('setting.operation.log.title')}</Header>
+          <Form.Group inline>
+            <Form.Checkbox
+              checked={inputs.LogConsumeEnabled === 'true'}
+              label={t('setting.operation.log.enable_consume')}
+              name='LogConsumeEnabled'
+              onChange={handleInputChange}
+            />
+          </Form.Group>
+          <Form.Group widths={4}>
+            <Form.Input
+              label={t('setting.operation.log.target_time')}
+              value={historyTimestamp}
+              type='datetime-local'
+              name='history_timestamp'
+              onChange={(e, { name, value }) => {
+                setHistoryTimestamp(value);
+              }}
+            />
+          </Form.Group>
+          <Form.Button
+            onClick={() => {
+              deleteHistoryLogs().then();
+            }}
+          >
+            {t('setting.operation.log.buttons.clean')}
+          </Form.Button>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.log.redaction_rules.title')}
+              name='LogRedactionRules'
+              onChange={handleInputChange}
+              style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.LogRedactionRules}
+              placeholder={t('setting.operation.log.redaction_rules.placeholder')}
+            />
+          </Form.Group>
+          <Form.Button
+            onClick={() => {
+              submitConfig('log').then();
+            }}
+          >
+            {t('setting.operation.log.buttons.save')}
+          </Form.Button>
 
           <Divider />
           <Header as='h3'>{t('setting.operation.monitor.title')}</Header>
@@ -339,6 +1039,58 @@ const OperationSetting = () => {
               )}
             />
           </Form.Group>
+          <Form.Group widths={3}>
+            <Form.Input
+              label={t('setting.operation.monitor.malformed_threshold')}
+              name='MalformedResponseThreshold'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.MalformedResponseThreshold}
+              type='number'
+              min='0'
+              max='1'
+              step='0.05'
+              placeholder={t(
+                'setting.operation.monitor.malformed_threshold_placeholder'
+              )}
+            />
+            <Form.Input
+              label={t('setting.operation.monitor.malformed_window')}
+              name='MalformedResponseWindow'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.MalformedResponseWindow}
+              type='number'
+              min='1'
+              placeholder={t(
+                'setting.operation.monitor.malformed_window_placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths={3}>
+            <Form.Input
+              label={t('setting.operation.monitor.status_page_models')}
+              name='StatusPageModels'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.StatusPageModels}
+              placeholder={t(
+                'setting.operation.monitor.status_page_models_placeholder'
+              )}
+            />
+            <Form.Input
+              label={t('setting.operation.monitor.status_page_window')}
+              name='StatusPageWindowHours'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.StatusPageWindowHours}
+              type='number'
+              min='1'
+              placeholder={t(
+                'setting.operation.monitor.status_page_window_placeholder'
+              )}
+            />
+          </Form.Group>
           <Form.Group inline>
             <Form.Checkbox
               checked={inputs.AutomaticDisableChannelEnabled === 'true'}
@@ -352,6 +1104,18 @@ const OperationSetting = () => {
               name='AutomaticEnableChannelEnabled'
               onChange={handleInputChange}
             />
+            <Form.Checkbox
+              checked={inputs.ResponseValidationEnabled === 'true'}
+              label={t('setting.operation.monitor.response_validation')}
+              name='ResponseValidationEnabled'
+              onChange={handleInputChange}
+            />
+            <Form.Checkbox
+              checked={inputs.StatusPageEnabled === 'true'}
+              label={t('setting.operation.monitor.status_page')}
+              name='StatusPageEnabled'
+              onChange={handleInputChange}
+            />
           </Form.Group>
           <Form.Button
             onClick={() => {
@@ -410,6 +1174,235 @@ const OperationSetting = () => {
               )}
             />
           </Form.Group>
+          <Form.Group widths={4}>
+            <Form.Input
+              label={t('setting.operation.general.request_dedup_window')}
+              name='RequestDedupWindow'
+              type={'number'}
+              step='1'
+              min='0'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.RequestDedupWindow}
+              placeholder={t(
+                'setting.operation.general.request_dedup_window_placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths={4}>
+            <Form.Input
+              label={t('setting.operation.general.base_currency')}
+              name='BaseCurrency'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.BaseCurrency}
+              placeholder='USD'
+            />
+            <Form.Input
+              width={8}
+              label={t('setting.operation.general.exchange_rate_url')}
+              name='ExchangeRateURL'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.ExchangeRateURL}
+              placeholder='https://open.er-api.com/v6/latest/{base}'
+            />
+            <Form.Button
+              label='&nbsp;'
+              disabled={!originInputs.ExchangeRateURL}
+              onClick={syncExchangeRates}
+            >
+              {t('setting.operation.general.buttons.sync_exchange_rates')}
+            </Form.Button>
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.general.exchange_rates')}
+              name='ExchangeRates'
+              onChange={handleInputChange}
+              style={{ minHeight: 100, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.ExchangeRates}
+              placeholder={t(
+                'setting.operation.general.exchange_rates_placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths={4}>
+            <Form.Input
+              label={t('setting.operation.general.file_max_size')}
+              name='FileMaxSize'
+              type='number'
+              min='0'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.FileMaxSize}
+              placeholder={t(
+                'setting.operation.general.file_max_size_placeholder'
+              )}
+            />
+            <Form.Input
+              label={t('setting.operation.general.file_user_storage_limit')}
+              name='FileUserStorageLimit'
+              type='number'
+              min='0'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.FileUserStorageLimit}
+              placeholder={t(
+                'setting.operation.general.file_user_storage_limit_placeholder'
+              )}
+            />
+            <Form.Input
+              label={t('setting.operation.general.file_retention_days')}
+              name='FileRetentionDays'
+              type='number'
+              min='0'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.FileRetentionDays}
+              placeholder={t(
+                'setting.operation.general.file_retention_days_placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths={4}>
+            <Form.Input
+              label={t(
+                'setting.operation.general.vector_store_embedding_model'
+              )}
+              name='VectorStoreEmbeddingModel'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.VectorStoreEmbeddingModel}
+              placeholder={t(
+                'setting.operation.general.vector_store_embedding_model_placeholder'
+              )}
+            />
+            <Form.Input
+              label={t('setting.operation.general.vector_store_chunk_size')}
+              name='VectorStoreChunkSize'
+              type='number'
+              min='1'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.VectorStoreChunkSize}
+              placeholder={t(
+                'setting.operation.general.vector_store_chunk_size_placeholder'
+              )}
+            />
+            <Form.Input
+              label={t('setting.operation.general.vector_store_chunk_overlap')}
+              name='VectorStoreChunkOverlap'
+              type='number'
+              min='0'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.VectorStoreChunkOverlap}
+              placeholder={t(
+                'setting.operation.general.vector_store_chunk_overlap_placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.general.rag_prompt_template')}
+              name='RagPromptTemplate'
+              onChange={handleInputChange}
+              style={{ minHeight: 100, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.RagPromptTemplate}
+              placeholder={t(
+                'setting.operation.general.rag_prompt_template_placeholder',
+                { context: '{{context}}', question: '{{question}}' }
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.Input
+              label={t('setting.operation.general.judge_model')}
+              name='JudgeModel'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.JudgeModel}
+              placeholder={t(
+                'setting.operation.general.judge_model_placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.general.judge_prompt')}
+              name='JudgePrompt'
+              onChange={handleInputChange}
+              style={{ minHeight: 100, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.JudgePrompt}
+              placeholder={t(
+                'setting.operation.general.judge_prompt_placeholder',
+                {
+                  question: '{{question}}',
+                  answer: '{{answer}}',
+                  reference: '{{reference}}',
+                }
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.Input
+              label={t('setting.operation.general.council_models')}
+              name='CouncilModels'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.CouncilModels}
+              placeholder={t(
+                'setting.operation.general.council_models_placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.general.council_judge_prompt')}
+              name='CouncilJudgePrompt'
+              onChange={handleInputChange}
+              style={{ minHeight: 100, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.CouncilJudgePrompt}
+              placeholder={t(
+                'setting.operation.general.council_judge_prompt_placeholder',
+                {
+                  question: '{{question}}',
+                  answers: '{{answers}}',
+                }
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.Input
+              label={t('setting.operation.general.long_context_summary_model')}
+              name='LongContextSummaryModel'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.LongContextSummaryModel}
+              placeholder={t(
+                'setting.operation.general.long_context_summary_model_placeholder'
+              )}
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.operation.general.long_context_summary_prompt')}
+              name='LongContextSummaryPrompt'
+              onChange={handleInputChange}
+              style={{ minHeight: 100, fontFamily: 'JetBrains Mono, Consolas' }}
+              autoComplete='new-password'
+              value={inputs.LongContextSummaryPrompt}
+              placeholder={t(
+                'setting.operation.general.long_context_summary_prompt_placeholder',
+                { content: '{{content}}' }
+              )}
+            />
+          </Form.Group>
           <Form.Group inline>
             <Form.Checkbox
               checked={inputs.DisplayInCurrencyEnabled === 'true'}
@@ -21,10 +21,12 @@ const OtherSetting = () => {
     About: '',
     SystemName: '',
     Logo: '',
+    Favicon: '',
     HomePageContent: '',
     Theme: '',
   });
   let [loading, setLoading] = useState(false);
+  const [themeOptions, setThemeOptions] = useState([]);
   const [showUpdateModal, setShowUpdateModal] = useState(false);
   const [updateData, setUpdateData] = useState({
     tag_name: '',
@@ -47,8 +49,25 @@ const OtherSetting = () => {
     }
   };
 
+  const getThemes = async () => {
+    const res = await API.get('/api/branding/themes');
+    const { success, data } = res.data;
+    if (success) {
+      setThemeOptions(
+        data.map((theme) => ({
+          key: theme.name,
+          text: theme.description
+            ? `${theme.name}（${theme.description}）`
+            : theme.name,
+          value: theme.name,
+        }))
+      );
+    }
+  };
+
   useEffect(() => {
     getOptions().then();
+    getThemes().then();
   }, []);
 
   const updateOption = async (key, value) => {
@@ -148,7 +167,7 @@ const OtherSetting = () => {
             {t('setting.other.system.buttons.save_name')}
           </Form.Button>
           <Form.Group widths='equal'>
-            <Form.Input
+            <Form.Dropdown
               label={
                 <label>
                   {t('setting.other.system.theme.title')}（
@@ -159,6 +178,8 @@ const OtherSetting = () => {
                 </label>
               }
               placeholder={t('setting.other.system.theme.placeholder')}
+              selection
+              options={themeOptions}
               value={inputs.Theme}
               name='Theme'
               onChange={handleInputChange}
@@ -180,6 +201,19 @@ const OtherSetting = () => {
           <Form.Button onClick={submitLogo}>
             {t('setting.other.system.buttons.save_logo')}
           </Form.Button>
+          <Form.Group widths='equal'>
+            <Form.Input
+              label={t('setting.other.system.favicon')}
+              placeholder={t('setting.other.system.favicon_placeholder')}
+              value={inputs.Favicon}
+              name='Favicon'
+              type='url'
+              onChange={handleInputChange}
+            />
+          </Form.Group>
+          <Form.Button onClick={() => submitOption('Favicon')}>
+            {t('setting.other.system.buttons.save_favicon')}
+          </Form.Button>
 
           <Divider />
           <Header as='h3'>{t('setting.other.content.title')}</Header>
@@ -12,7 +12,9 @@ import {
 import { Link, useNavigate } from 'react-router-dom';
 import {
   API,
+  BASE_PATH,
   copy,
+  isAdmin,
   showError,
   showInfo,
   showNotice,
@@ -21,6 +23,8 @@ import {
 import Turnstile from 'react-turnstile';
 import { UserContext } from '../context/User';
 import { onGitHubOAuthClicked, onLarkOAuthClicked } from './utils';
+import TwoFactorSetting from './TwoFactorSetting';
+import SessionSetting from './SessionSetting';
 
 const PersonalSetting = () => {
   const { t } = useTranslation();
@@ -45,6 +49,9 @@ const PersonalSetting = () => {
   const [countdown, setCountdown] = useState(30);
   const [affLink, setAffLink] = useState('');
   const [systemToken, setSystemToken] = useState('');
+  const [notifications, setNotifications] = useState({});
+  const [botBindCode, setBotBindCode] = useState('');
+  const [currency, setCurrency] = useState(userState?.user?.currency || '');
 
   useEffect(() => {
     let status = localStorage.getItem('status');
@@ -58,6 +65,56 @@ const PersonalSetting = () => {
     }
   }, []);
 
+  useEffect(() => {
+    const getNotifications = async () => {
+      const res = await API.get('/api/user/notification');
+      const { success, data } = res.data;
+      if (success) {
+        setNotifications(data);
+      }
+    };
+    getNotifications().then();
+  }, []);
+
+  const getBotBindCode = async () => {
+    const res = await API.get('/api/bot/bind_code');
+    const { success, message, data } = res.data;
+    if (success) {
+      setBotBindCode(`/bind ${data}`);
+      await copy(`/bind ${data}`);
+      showSuccess(t('setting.personal.notification.bot_bind_copied'));
+    } else {
+      showError(message);
+    }
+  };
+
+  const toggleNotification = async (kind) => {
+    const newNotifications = {
+      ...notifications,
+      [kind]: !notifications[kind],
+    };
+    const res = await API.put('/api/user/notification', newNotifications);
+    const { success, message } = res.data;
+    if (success) {
+      setNotifications(newNotifications);
+    } else {
+      showError(message);
+    }
+  };
+
+  const updateCurrency = async (value) => {
+    const res = await API.put('/api/user/currency', { currency: value });
+    const { success, message } = res.data;
+    if (success) {
+      setCurrency(value);
+      const user = { ...userState.user, currency: value };
+      localStorage.setItem('user', JSON.stringify(user));
+      userDispatch({ type: 'login', payload: user });
+    } else {
+      showError(message);
+    }
+  };
+
   useEffect(() => {
     let countdownInterval = null;
     if (disableButton && countdown > 0) {
@@ -92,7 +149,7 @@ const PersonalSetting = () => {
     const res = await API.get('/api/user/aff');
     const { success, message, data } = res.data;
     if (success) {
-      let link = `${window.location.origin}/register?aff=${data}`;
+      let link = `${window.location.origin}${BASE_PATH}/register?aff=${data}`;
       setAffLink(link);
       setSystemToken('');
       await copy(link);
@@ -224,6 +281,10 @@ const PersonalSetting = () => {
         />
       )}
       <Divider />
+      <TwoFactorSetting />
+      <Divider />
+      <SessionSetting />
+      <Divider />
       <Header as='h3'>{t('setting.personal.binding.title')}</Header>
       {status.wechat_login && (
         <Button onClick={() => setShowWeChatBindModal(true)}>
@@ -413,6 +474,55 @@ const PersonalSetting = () => {
           </Modal.Description>
         </Modal.Content>
       </Modal>
+      {status.display_in_currency &&
+        Object.keys(status.exchange_rates || {}).length > 1 && (
+          <>
+            <Divider />
+            <Header as='h3'>{t('setting.personal.currency.title')}</Header>
+            <Form>
+              <Form.Select
+                label={t('setting.personal.currency.label')}
+                value={currency || status.base_currency}
+                options={Object.keys(status.exchange_rates)
+                  .sort()
+                  .map((code) => ({ key: code, text: code, value: code }))}
+                onChange={(e, { value }) =>
+                  updateCurrency(value === status.base_currency ? '' : value)
+                }
+                style={{ maxWidth: '200px' }}
+              />
+            </Form>
+          </>
+        )}
+      <Divider />
+      <Header as='h3'>{t('setting.personal.notification.title')}</Header>
+      <Form>
+        <Form.Group inline>
+          {Object.keys(notifications).map((kind) => (
+            <Form.Checkbox
+              key={kind}
+              checked={notifications[kind]}
+              label={t(`setting.personal.notification.${kind}`)}
+              onChange={() => toggleNotification(kind)}
+            />
+          ))}
+        </Form.Group>
+      </Form>
+      {(isAdmin() || status.bot_chat) && (
+        <>
+          <Button onClick={getBotBindCode}>
+            {t('setting.personal.notification.bind_bot')}
+          </Button>
+          {botBindCode && (
+            <Form.Input
+              fluid
+              readOnly
+              value={botBindCode}
+              style={{ marginTop: '10px' }}
+            />
+          )}
+        </>
+      )}
     </div>
   );
 };
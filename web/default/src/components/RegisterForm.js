@@ -89,6 +89,13 @@ const RegisterForm = () => {
         affCode = localStorage.getItem('aff');
       }
       inputs.aff_code = affCode;
+      let deviceId = localStorage.getItem('device_id');
+      if (!deviceId) {
+        deviceId =
+          Date.now().toString(36) + Math.random().toString(36).slice(2);
+        localStorage.setItem('device_id', deviceId);
+      }
+      inputs.device_id = deviceId;
       const res = await API.post(
         `/api/user/register?turnstile=${turnstileToken}`,
         inputs
@@ -0,0 +1,59 @@
+import React, { useContext, useEffect, useState } from 'react';
+import { Dimmer, Loader, Segment } from 'semantic-ui-react';
+import { useNavigate, useSearchParams } from 'react-router-dom';
+import { useTranslation } from 'react-i18next';
+import { API, showError, showSuccess } from '../helpers';
+import { UserContext } from '../context/User';
+import { finishLogin } from './utils';
+
+// the IdP posts to /api/saml/acs which signs the user in and redirects here
+const SAMLOAuth = () => {
+  const { t } = useTranslation();
+  const [searchParams] = useSearchParams();
+  const [userState, userDispatch] = useContext(UserContext);
+  const [prompt] = useState(t('auth.saml.processing'));
+
+  let navigate = useNavigate();
+
+  const loadUser = async () => {
+    const error = searchParams.get('error');
+    if (error) {
+      showError(error);
+      navigate('/login');
+      return;
+    }
+    const res = await API.get('/api/user/self');
+    const { success, message, data } = res.data;
+    if (success) {
+      const user = {
+        id: data.id,
+        username: data.username,
+        display_name: data.display_name,
+        role: data.role,
+        status: data.status,
+      };
+      const message = searchParams.get('two_factor_setup')
+        ? 'two_factor_setup'
+        : '';
+      finishLogin(message, user, userDispatch, navigate);
+      showSuccess(t('auth.saml.success'));
+    } else {
+      showError(message);
+      navigate('/login');
+    }
+  };
+
+  useEffect(() => {
+    loadUser().then();
+  }, []);
+
+  return (
+    <Segment style={{ minHeight: '300px' }}>
+      <Dimmer active inverted>
+        <Loader size='large'>{prompt}</Loader>
+      </Dimmer>
+    </Segment>
+  );
+};
+
+export default SAMLOAuth;
@@ -0,0 +1,94 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { Button, Header, Label, Table } from 'semantic-ui-react';
+import { API, showError, showSuccess, timestamp2string } from '../helpers';
+
+const SessionSetting = () => {
+  const { t } = useTranslation();
+  const [sessions, setSessions] = useState([]);
+
+  const loadSessions = async () => {
+    const res = await API.get('/api/user/sessions');
+    const { success, message, data } = res.data;
+    if (success) {
+      setSessions(data);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadSessions().then();
+  }, []);
+
+  const revoke = async (path) => {
+    const res = await API.delete(path);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('setting.personal.sessions.messages.success'));
+      await loadSessions();
+    } else {
+      showError(message);
+    }
+  };
+
+  return (
+    <>
+      <Header as='h3'>{t('setting.personal.sessions.title')}</Header>
+      <Table basic={'very'} compact size='small'>
+        <Table.Header>
+          <Table.Row>
+            <Table.HeaderCell>
+              {t('setting.personal.sessions.device')}
+            </Table.HeaderCell>
+            <Table.HeaderCell>
+              {t('setting.personal.sessions.ip')}
+            </Table.HeaderCell>
+            <Table.HeaderCell>
+              {t('setting.personal.sessions.created_at')}
+            </Table.HeaderCell>
+            <Table.HeaderCell>
+              {t('setting.personal.sessions.last_active_at')}
+            </Table.HeaderCell>
+            <Table.HeaderCell></Table.HeaderCell>
+          </Table.Row>
+        </Table.Header>
+        <Table.Body>
+          {sessions.map((session) => (
+            <Table.Row key={session.id}>
+              <Table.Cell style={{ wordBreak: 'break-all' }}>
+                {session.user_agent}{' '}
+                {session.current && (
+                  <Label basic color='green'>
+                    {t('setting.personal.sessions.current')}
+                  </Label>
+                )}
+              </Table.Cell>
+              <Table.Cell>{session.ip}</Table.Cell>
+              <Table.Cell>{timestamp2string(session.created_at)}</Table.Cell>
+              <Table.Cell>
+                {timestamp2string(session.last_active_at)}
+              </Table.Cell>
+              <Table.Cell>
+                {!session.current && (
+                  <Button
+                    size='tiny'
+                    negative
+                    onClick={() => revoke(`/api/user/sessions/${session.id}`)}
+                  >
+                    {t('setting.personal.sessions.buttons.revoke')}
+                  </Button>
+                )}
+              </Table.Cell>
+            </Table.Row>
+          ))}
+        </Table.Body>
+      </Table>
+      <Button onClick={() => revoke('/api/user/sessions')}>
+        {t('setting.personal.sessions.buttons.revoke_others')}
+      </Button>
+    </>
+  );
+};
+
+export default SessionSetting;
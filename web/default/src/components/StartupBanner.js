@@ -0,0 +1,46 @@
+import React, { useContext, useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { Message } from 'semantic-ui-react';
+import { StatusContext } from '../context/Status';
+import { API, isAdmin } from '../helpers';
+
+// StartupBanner warns that the server is serving degraded, the admins also see the problems the startup check found
+const StartupBanner = () => {
+  const { t } = useTranslation();
+  const [statusState] = useContext(StatusContext);
+  const [problems, setProblems] = useState([]);
+  const degraded = statusState?.status?.degraded;
+
+  useEffect(() => {
+    if (!degraded || !isAdmin()) {
+      return;
+    }
+    API.get('/api/status/startup_check').then((res) => {
+      const { success, data } = res.data;
+      if (success) {
+        setProblems(data.problems || []);
+      }
+    });
+  }, [degraded]);
+
+  if (!degraded) {
+    return <></>;
+  }
+  return (
+    <Message warning>
+      <Message.Header>{t('startup_check.title')}</Message.Header>
+      <p>{t('startup_check.description')}</p>
+      {problems.length > 0 && (
+        <Message.List>
+          {problems.map((problem, index) => (
+            <Message.Item key={index}>
+              {problem.check}: {problem.message}
+            </Message.Item>
+          ))}
+        </Message.List>
+      )}
+    </Message>
+  );
+};
+
+export default StartupBanner;
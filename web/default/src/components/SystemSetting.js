@@ -22,6 +22,15 @@ const SystemSetting = () => {
     GitHubClientSecret: '',
     LarkClientId: '',
     LarkClientSecret: '',
+    SAMLEnabled: '',
+    SAMLIdPEntityId: '',
+    SAMLIdPSSOURL: '',
+    SAMLIdPCertificate: '',
+    SAMLUsernameAttribute: '',
+    SAMLEmailAttribute: '',
+    SAMLDisplayNameAttribute: '',
+    SAMLGroupAttribute: '',
+    SAMLGroupMapping: '',
     Notice: '',
     SMTPServer: '',
     SMTPPort: '',
@@ -29,6 +38,8 @@ const SystemSetting = () => {
     SMTPFrom: '',
     SMTPToken: '',
     ServerAddress: '',
+    RelayCORSAllowedOrigins: '',
+    AdminCORSAllowedOrigins: '',
     Footer: '',
     WeChatAuthEnabled: '',
     WeChatServerAddress: '',
@@ -36,10 +47,27 @@ const SystemSetting = () => {
     WeChatAccountQRCodeImageURL: '',
     MessagePusherAddress: '',
     MessagePusherToken: '',
+    TelegramBotToken: '',
+    TelegramWebhookSecretToken: '',
+    LarkBotAppId: '',
+    LarkBotAppSecret: '',
+    LarkBotVerificationToken: '',
+    DingTalkBotAppKey: '',
+    DingTalkBotAppSecret: '',
+    BotChatEnabled: '',
+    BotChatModel: '',
+    WebSearchProvider: '',
+    WebSearchURL: '',
+    WebSearchToken: '',
+    WebSearchMaxResults: '',
+    SentryDSN: '',
+    SentryEnvironment: '',
+    SentryErrorSpikeThreshold: '',
     TurnstileCheckEnabled: '',
     TurnstileSiteKey: '',
     TurnstileSecretKey: '',
     RegisterEnabled: '',
+    TwoFactorEnforcedRoles: '',
     EmailDomainRestrictionEnabled: '',
     EmailDomainWhitelist: '',
   });
@@ -85,6 +113,7 @@ const SystemSetting = () => {
       case 'PasswordRegisterEnabled':
       case 'EmailVerificationEnabled':
       case 'GitHubOAuthEnabled':
+      case 'SAMLEnabled':
       case 'WeChatAuthEnabled':
       case 'TurnstileCheckEnabled':
       case 'EmailDomainRestrictionEnabled':
@@ -123,15 +152,22 @@ const SystemSetting = () => {
       name === 'Notice' ||
       name.startsWith('SMTP') ||
       name === 'ServerAddress' ||
+      name.endsWith('CORSAllowedOrigins') ||
       name === 'GitHubClientId' ||
       name === 'GitHubClientSecret' ||
       name === 'LarkClientId' ||
       name === 'LarkClientSecret' ||
+      (name.startsWith('SAML') && name !== 'SAMLEnabled') ||
       name === 'WeChatServerAddress' ||
       name === 'WeChatServerToken' ||
       name === 'WeChatAccountQRCodeImageURL' ||
       name === 'TurnstileSiteKey' ||
       name === 'TurnstileSecretKey' ||
+      name === 'TelegramBotToken' ||
+      name === 'TelegramWebhookSecretToken' ||
+      name.startsWith('LarkBot') ||
+      name.startsWith('DingTalkBot') ||
+      name === 'BotChatModel' ||
       name === 'EmailDomainWhitelist'
     ) {
       setInputs((inputs) => ({ ...inputs, [name]: value }));
@@ -145,6 +181,14 @@ const SystemSetting = () => {
     await updateOption('ServerAddress', ServerAddress);
   };
 
+  const submitCORS = async () => {
+    for (const key of ['RelayCORSAllowedOrigins', 'AdminCORSAllowedOrigins']) {
+      if (originInputs[key] !== inputs[key]) {
+        await updateOption(key, inputs[key]);
+      }
+    }
+  };
+
   const submitSMTP = async () => {
     if (originInputs['SMTPServer'] !== inputs.SMTPServer) {
       await updateOption('SMTPServer', inputs.SMTPServer);
@@ -221,6 +265,56 @@ const SystemSetting = () => {
     }
   };
 
+  const submitBot = async () => {
+    for (const key of ['LarkBotAppId', 'DingTalkBotAppKey', 'BotChatModel']) {
+      if (originInputs[key] !== inputs[key]) {
+        await updateOption(key, inputs[key]);
+      }
+    }
+    // the secrets are not sent to the frontend, only update the filled ones
+    for (const key of [
+      'TelegramBotToken',
+      'TelegramWebhookSecretToken',
+      'LarkBotAppSecret',
+      'LarkBotVerificationToken',
+      'DingTalkBotAppSecret',
+    ]) {
+      if (originInputs[key] !== inputs[key] && inputs[key] !== '') {
+        await updateOption(key, inputs[key]);
+      }
+    }
+  };
+
+  const submitWebSearch = async () => {
+    for (const key of [
+      'WebSearchProvider',
+      'WebSearchURL',
+      'WebSearchMaxResults',
+    ]) {
+      if (originInputs[key] !== inputs[key]) {
+        await updateOption(key, inputs[key]);
+      }
+    }
+    if (
+      originInputs['WebSearchToken'] !== inputs.WebSearchToken &&
+      inputs.WebSearchToken !== ''
+    ) {
+      await updateOption('WebSearchToken', inputs.WebSearchToken);
+    }
+  };
+
+  const submitSentry = async () => {
+    for (const key of [
+      'SentryDSN',
+      'SentryEnvironment',
+      'SentryErrorSpikeThreshold',
+    ]) {
+      if (originInputs[key] !== inputs[key]) {
+        await updateOption(key, inputs[key]);
+      }
+    }
+  };
+
   const submitGitHubOAuth = async () => {
     if (originInputs['GitHubClientId'] !== inputs.GitHubClientId) {
       await updateOption('GitHubClientId', inputs.GitHubClientId);
@@ -245,6 +339,23 @@ const SystemSetting = () => {
     }
   };
 
+  const submitSAML = async () => {
+    for (const key of [
+      'SAMLIdPEntityId',
+      'SAMLIdPSSOURL',
+      'SAMLIdPCertificate',
+      'SAMLUsernameAttribute',
+      'SAMLEmailAttribute',
+      'SAMLDisplayNameAttribute',
+      'SAMLGroupAttribute',
+      'SAMLGroupMapping',
+    ]) {
+      if (originInputs[key] !== inputs[key]) {
+        await updateOption(key, inputs[key]);
+      }
+    }
+  };
+
   const submitTurnstile = async () => {
     if (originInputs['TurnstileSiteKey'] !== inputs.TurnstileSiteKey) {
       await updateOption('TurnstileSiteKey', inputs.TurnstileSiteKey);
@@ -299,6 +410,28 @@ const SystemSetting = () => {
             {t('setting.system.general.buttons.update')}
           </Form.Button>
           <Divider />
+          <Header as='h3'>{t('setting.system.cors.title')}</Header>
+          <Message>{t('setting.system.cors.subtitle')}</Message>
+          <Form.Group widths='equal'>
+            <Form.Input
+              label={t('setting.system.cors.relay')}
+              placeholder={t('setting.system.cors.relay_placeholder')}
+              value={inputs.RelayCORSAllowedOrigins}
+              name='RelayCORSAllowedOrigins'
+              onChange={handleInputChange}
+            />
+            <Form.Input
+              label={t('setting.system.cors.admin')}
+              placeholder={t('setting.system.cors.admin_placeholder')}
+              value={inputs.AdminCORSAllowedOrigins}
+              name='AdminCORSAllowedOrigins'
+              onChange={handleInputChange}
+            />
+          </Form.Group>
+          <Form.Button onClick={submitCORS}>
+            {t('setting.system.cors.buttons.save')}
+          </Form.Button>
+          <Divider />
           <Header as='h3'>{t('setting.system.login.title')}</Header>
           <Form.Group inline>
             <Form.Checkbox
@@ -360,6 +493,12 @@ const SystemSetting = () => {
               name='WeChatAuthEnabled'
               onChange={handleInputChange}
             />
+            <Form.Checkbox
+              checked={inputs.SAMLEnabled === 'true'}
+              label={t('setting.system.login.saml')}
+              name='SAMLEnabled'
+              onChange={handleInputChange}
+            />
           </Form.Group>
           <Form.Group inline>
             <Form.Checkbox
@@ -375,6 +514,29 @@ const SystemSetting = () => {
               onChange={handleInputChange}
             />
           </Form.Group>
+          <Form.Dropdown
+            label={t('setting.system.login.two_factor_enforced_roles')}
+            placeholder={t('setting.system.login.two_factor_enforced_roles')}
+            multiple
+            selection
+            options={[
+              { key: 1, text: t('user.table.role_types.normal'), value: '1' },
+              { key: 10, text: t('user.table.role_types.admin'), value: '10' },
+              {
+                key: 100,
+                text: t('user.table.role_types.super_admin'),
+                value: '100',
+              },
+            ]}
+            value={
+              inputs.TwoFactorEnforcedRoles
+                ? inputs.TwoFactorEnforcedRoles.split(',')
+                : []
+            }
+            onChange={(e, { value }) =>
+              updateOption('TwoFactorEnforcedRoles', value.join(','))
+            }
+          />
           <Divider />
           <Header as='h3'>{t('setting.system.email_restriction.title')}</Header>
           <Message>{t('setting.system.email_restriction.subtitle')}</Message>
@@ -575,6 +737,89 @@ const SystemSetting = () => {
             {t('setting.system.lark.buttons.save')}
           </Form.Button>
 
+          <Divider />
+          <Header as='h3'>
+            {t('setting.system.saml.title')}
+            <Header.Subheader>
+              {t('setting.system.saml.subtitle')}
+            </Header.Subheader>
+          </Header>
+          <Message>
+            {t('setting.system.saml.url_notice', {
+              metadata_url: `${inputs.ServerAddress}/api/saml/metadata`,
+              acs_url: `${inputs.ServerAddress}/api/saml/acs`,
+            })}
+          </Message>
+          <Form.Group widths={2}>
+            <Form.Input
+              label={t('setting.system.saml.idp_entity_id')}
+              name='SAMLIdPEntityId'
+              onChange={handleInputChange}
+              value={inputs.SAMLIdPEntityId}
+              placeholder='https://idp.example.com/metadata'
+            />
+            <Form.Input
+              label={t('setting.system.saml.idp_sso_url')}
+              name='SAMLIdPSSOURL'
+              onChange={handleInputChange}
+              value={inputs.SAMLIdPSSOURL}
+              placeholder='https://idp.example.com/sso'
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.system.saml.idp_certificate')}
+              name='SAMLIdPCertificate'
+              onChange={handleInputChange}
+              style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
+              value={inputs.SAMLIdPCertificate}
+              placeholder='-----BEGIN CERTIFICATE-----'
+            />
+          </Form.Group>
+          <Form.Group widths={4}>
+            <Form.Input
+              label={t('setting.system.saml.username_attribute')}
+              name='SAMLUsernameAttribute'
+              onChange={handleInputChange}
+              value={inputs.SAMLUsernameAttribute}
+              placeholder='uid'
+            />
+            <Form.Input
+              label={t('setting.system.saml.email_attribute')}
+              name='SAMLEmailAttribute'
+              onChange={handleInputChange}
+              value={inputs.SAMLEmailAttribute}
+              placeholder='mail'
+            />
+            <Form.Input
+              label={t('setting.system.saml.display_name_attribute')}
+              name='SAMLDisplayNameAttribute'
+              onChange={handleInputChange}
+              value={inputs.SAMLDisplayNameAttribute}
+              placeholder='displayName'
+            />
+            <Form.Input
+              label={t('setting.system.saml.group_attribute')}
+              name='SAMLGroupAttribute'
+              onChange={handleInputChange}
+              value={inputs.SAMLGroupAttribute}
+              placeholder='groups'
+            />
+          </Form.Group>
+          <Form.Group widths='equal'>
+            <Form.TextArea
+              label={t('setting.system.saml.group_mapping')}
+              name='SAMLGroupMapping'
+              onChange={handleInputChange}
+              style={{ minHeight: 100, fontFamily: 'JetBrains Mono, Consolas' }}
+              value={inputs.SAMLGroupMapping}
+              placeholder='{"engineering": "vip"}'
+            />
+          </Form.Group>
+          <Form.Button onClick={submitSAML}>
+            {t('setting.system.saml.buttons.save')}
+          </Form.Button>
+
           <Divider />
           <Header as='h3'>
             {t('setting.system.wechat.title')}
@@ -654,6 +899,195 @@ const SystemSetting = () => {
           <Form.Button onClick={submitTurnstile}>
             {t('setting.system.turnstile.buttons.save')}
           </Form.Button>
+
+          <Divider />
+          <Header as='h3'>
+            {t('setting.system.bot.title')}
+            <Header.Subheader>
+              {t('setting.system.bot.subtitle')}
+            </Header.Subheader>
+          </Header>
+          <Form.Group widths={3}>
+            <Form.Input
+              label={t('setting.system.bot.telegram_token')}
+              name='TelegramBotToken'
+              onChange={handleInputChange}
+              type='password'
+              autoComplete='new-password'
+              value={inputs.TelegramBotToken}
+              placeholder={t('setting.system.bot.secret_placeholder')}
+            />
+            <Form.Input
+              label={t('setting.system.bot.telegram_webhook_secret')}
+              name='TelegramWebhookSecretToken'
+              onChange={handleInputChange}
+              type='password'
+              autoComplete='new-password'
+              value={inputs.TelegramWebhookSecretToken}
+              placeholder={t('setting.system.bot.secret_placeholder')}
+            />
+          </Form.Group>
+          <Form.Group widths={3}>
+            <Form.Input
+              label={t('setting.system.bot.lark_app_id')}
+              name='LarkBotAppId'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.LarkBotAppId}
+              placeholder={t('setting.system.bot.lark_app_id_placeholder')}
+            />
+            <Form.Input
+              label={t('setting.system.bot.lark_app_secret')}
+              name='LarkBotAppSecret'
+              onChange={handleInputChange}
+              type='password'
+              autoComplete='new-password'
+              value={inputs.LarkBotAppSecret}
+              placeholder={t('setting.system.bot.secret_placeholder')}
+            />
+            <Form.Input
+              label={t('setting.system.bot.lark_verification_token')}
+              name='LarkBotVerificationToken'
+              onChange={handleInputChange}
+              type='password'
+              autoComplete='new-password'
+              value={inputs.LarkBotVerificationToken}
+              placeholder={t('setting.system.bot.secret_placeholder')}
+            />
+          </Form.Group>
+          <Form.Group widths={3}>
+            <Form.Input
+              label={t('setting.system.bot.dingtalk_app_key')}
+              name='DingTalkBotAppKey'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.DingTalkBotAppKey}
+              placeholder={t('setting.system.bot.dingtalk_app_key_placeholder')}
+            />
+            <Form.Input
+              label={t('setting.system.bot.dingtalk_app_secret')}
+              name='DingTalkBotAppSecret'
+              onChange={handleInputChange}
+              type='password'
+              autoComplete='new-password'
+              value={inputs.DingTalkBotAppSecret}
+              placeholder={t('setting.system.bot.secret_placeholder')}
+            />
+          </Form.Group>
+          <Form.Group inline>
+            <Form.Checkbox
+              checked={inputs.BotChatEnabled === 'true'}
+              label={t('setting.system.bot.chat_enabled')}
+              name='BotChatEnabled'
+              onChange={handleInputChange}
+            />
+          </Form.Group>
+          <Form.Group widths={3}>
+            <Form.Input
+              label={t('setting.system.bot.chat_model')}
+              name='BotChatModel'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.BotChatModel}
+              placeholder={t('setting.system.bot.chat_model_placeholder')}
+            />
+          </Form.Group>
+          <Form.Button onClick={submitBot}>
+            {t('setting.system.bot.buttons.save')}
+          </Form.Button>
+
+          <Divider />
+          <Header as='h3'>
+            {t('setting.system.web_search.title')}
+            <Header.Subheader>
+              {t('setting.system.web_search.subtitle')}
+            </Header.Subheader>
+          </Header>
+          <Form.Group widths={4}>
+            <Form.Select
+              label={t('setting.system.web_search.provider')}
+              name='WebSearchProvider'
+              options={[
+                {
+                  key: '',
+                  text: t('setting.system.web_search.none'),
+                  value: '',
+                },
+                { key: 'bing', text: 'Bing', value: 'bing' },
+                { key: 'searxng', text: 'SearxNG', value: 'searxng' },
+                { key: 'tavily', text: 'Tavily', value: 'tavily' },
+              ]}
+              onChange={handleInputChange}
+              value={inputs.WebSearchProvider}
+            />
+            <Form.Input
+              label={t('setting.system.web_search.url')}
+              name='WebSearchURL'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.WebSearchURL}
+              placeholder={t('setting.system.web_search.url_placeholder')}
+            />
+            <Form.Input
+              label={t('setting.system.web_search.token')}
+              name='WebSearchToken'
+              onChange={handleInputChange}
+              type='password'
+              autoComplete='new-password'
+              value={inputs.WebSearchToken}
+              placeholder={t('setting.system.bot.secret_placeholder')}
+            />
+            <Form.Input
+              label={t('setting.system.web_search.max_results')}
+              name='WebSearchMaxResults'
+              type='number'
+              min='1'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.WebSearchMaxResults}
+            />
+          </Form.Group>
+          <Form.Button onClick={submitWebSearch}>
+            {t('setting.system.web_search.buttons.save')}
+          </Form.Button>
+
+          <Divider />
+          <Header as='h3'>
+            {t('setting.system.sentry.title')}
+            <Header.Subheader>
+              {t('setting.system.sentry.subtitle')}
+            </Header.Subheader>
+          </Header>
+          <Form.Group widths={3}>
+            <Form.Input
+              label={t('setting.system.sentry.dsn')}
+              name='SentryDSN'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.SentryDSN}
+              placeholder={t('setting.system.sentry.dsn_placeholder')}
+            />
+            <Form.Input
+              label={t('setting.system.sentry.environment')}
+              name='SentryEnvironment'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.SentryEnvironment}
+              placeholder='production'
+            />
+            <Form.Input
+              label={t('setting.system.sentry.spike_threshold')}
+              name='SentryErrorSpikeThreshold'
+              type='number'
+              min='0'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.SentryErrorSpikeThreshold}
+            />
+          </Form.Group>
+          <Form.Button onClick={submitSentry}>
+            {t('setting.system.sentry.buttons.save')}
+          </Form.Button>
         </Form>
       </Grid.Column>
     </Grid>
@@ -12,6 +12,7 @@ import {
 import { Link } from 'react-router-dom';
 import {
   API,
+  BASE_PATH,
   copy,
   showError,
   showSuccess,
@@ -128,7 +129,7 @@ const TokensTable = () => {
       serverAddress = status.server_address;
     }
     if (serverAddress === '') {
-      serverAddress = window.location.origin;
+      serverAddress = window.location.origin + BASE_PATH;
     }
     let encodedServerAddress = encodeURIComponent(serverAddress);
     const nextLink = localStorage.getItem('chat_link');
@@ -176,7 +177,7 @@ const TokensTable = () => {
       serverAddress = status.server_address;
     }
     if (serverAddress === '') {
-      serverAddress = window.location.origin;
+      serverAddress = window.location.origin + BASE_PATH;
     }
     let encodedServerAddress = encodeURIComponent(serverAddress);
     const chatLink = localStorage.getItem('chat_link');
@@ -502,6 +503,9 @@ const TokensTable = () => {
               <Button size='small' onClick={refresh} loading={loading}>
                 {t('token.buttons.refresh')}
               </Button>
+              <Button size='small' as={Link} to='/trash' loading={loading}>
+                {t('token.buttons.trash')}
+              </Button>
               <Dropdown
                 placeholder={t('token.sort.placeholder')}
                 selection
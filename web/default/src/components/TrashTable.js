@@ -0,0 +1,122 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { Button, Popup, Table } from 'semantic-ui-react';
+import { API, showError, showSuccess } from '../helpers';
+import { ITEMS_PER_PAGE } from '../constants';
+
+// the name of the item of each kind, the users are known by the username
+const NAME_FIELDS = {
+  channel: 'name',
+  token: 'name',
+  user: 'username',
+};
+
+const TrashTable = ({ kind }) => {
+  const { t } = useTranslation();
+  const [items, setItems] = useState([]);
+  const [page, setPage] = useState(0);
+  const [loading, setLoading] = useState(true);
+
+  const loadItems = async (page) => {
+    setLoading(true);
+    const res = await API.get(`/api/${kind}/trash?p=${page}`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setItems(data || []);
+    } else {
+      showError(message);
+    }
+    setLoading(false);
+  };
+
+  useEffect(() => {
+    loadItems(page).then();
+  }, [kind, page]);
+
+  const manageItem = async (id, action) => {
+    let res;
+    if (action === 'restore') {
+      res = await API.post(`/api/${kind}/trash/${id}/restore`);
+    } else {
+      res = await API.delete(`/api/${kind}/trash/${id}`);
+    }
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t(`trash.messages.${action}_success`));
+      await loadItems(page);
+    } else {
+      showError(message);
+    }
+  };
+
+  return (
+    <Table basic='very' compact size='small'>
+      <Table.Header>
+        <Table.Row>
+          <Table.HeaderCell>ID</Table.HeaderCell>
+          <Table.HeaderCell>{t('trash.table.name')}</Table.HeaderCell>
+          <Table.HeaderCell>{t('trash.table.deleted_at')}</Table.HeaderCell>
+          <Table.HeaderCell>{t('trash.table.actions')}</Table.HeaderCell>
+        </Table.Row>
+      </Table.Header>
+      <Table.Body>
+        {items.map((item) => (
+          <Table.Row key={item.id}>
+            <Table.Cell>{item.id}</Table.Cell>
+            <Table.Cell>{item[NAME_FIELDS[kind]]}</Table.Cell>
+            <Table.Cell>
+              {new Date(item.deleted_at).toLocaleString()}
+            </Table.Cell>
+            <Table.Cell>
+              <Button
+                size='tiny'
+                positive
+                onClick={() => manageItem(item.id, 'restore')}
+              >
+                {t('trash.buttons.restore')}
+              </Button>
+              <Popup
+                trigger={
+                  <Button size='tiny' negative>
+                    {t('trash.buttons.purge')}
+                  </Button>
+                }
+                on='click'
+                flowing
+                hoverable
+              >
+                <Button negative onClick={() => manageItem(item.id, 'purge')}>
+                  {t('trash.buttons.confirm_purge')}
+                </Button>
+              </Popup>
+            </Table.Cell>
+          </Table.Row>
+        ))}
+      </Table.Body>
+      <Table.Footer>
+        <Table.Row>
+          <Table.HeaderCell colSpan='4'>
+            <Button
+              size='small'
+              disabled={page === 0}
+              loading={loading}
+              onClick={() => setPage(page - 1)}
+            >
+              {t('trash.buttons.previous')}
+            </Button>
+            <Button
+              size='small'
+              disabled={items.length < ITEMS_PER_PAGE}
+              loading={loading}
+              onClick={() => setPage(page + 1)}
+            >
+              {t('trash.buttons.next')}
+            </Button>
+          </Table.HeaderCell>
+        </Table.Row>
+      </Table.Footer>
+    </Table>
+  );
+};
+
+export default TrashTable;
@@ -0,0 +1,59 @@
+import React, { useContext, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { Button, Form, Grid, Header, Segment } from 'semantic-ui-react';
+import { useNavigate } from 'react-router-dom';
+import { API, showError, showSuccess } from '../helpers';
+import { UserContext } from '../context/User';
+import { finishLogin } from './utils';
+
+// asked once the password is checked, or the IdP has signed the user in
+const TwoFactorLogin = () => {
+  const { t } = useTranslation();
+  const [code, setCode] = useState('');
+  const [userState, userDispatch] = useContext(UserContext);
+  let navigate = useNavigate();
+
+  const submit = async () => {
+    if (!code) return;
+    const res = await API.post('/api/user/login/2fa', { code });
+    const { success, message, data } = res.data;
+    if (success) {
+      finishLogin(message, data, userDispatch, navigate, '/token');
+      showSuccess(t('messages.success.login'));
+    } else {
+      showError(message);
+      if (message === '登录已过期，请重新登录') {
+        navigate('/login');
+      }
+    }
+  };
+
+  return (
+    <Grid textAlign='center' style={{ marginTop: '48px' }}>
+      <Grid.Column style={{ maxWidth: 450 }}>
+        <Header as='h2' textAlign='center'>
+          {t('auth.two_factor.title')}
+        </Header>
+        <Form size='large'>
+          <Segment>
+            <p>{t('auth.two_factor.description')}</p>
+            <Form.Input
+              fluid
+              icon='lock'
+              iconPosition='left'
+              placeholder={t('auth.two_factor.code_placeholder')}
+              value={code}
+              autoComplete='one-time-code'
+              onChange={(e, { value }) => setCode(value)}
+            />
+            <Button color='green' fluid size='large' onClick={submit}>
+              {t('auth.two_factor.button')}
+            </Button>
+          </Segment>
+        </Form>
+      </Grid.Column>
+    </Grid>
+  );
+};
+
+export default TwoFactorLogin;
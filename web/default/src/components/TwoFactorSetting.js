@@ -0,0 +1,143 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { Button, Form, Header, Message } from 'semantic-ui-react';
+import { API, copy, showError, showSuccess } from '../helpers';
+
+const TwoFactorSetting = () => {
+  const { t } = useTranslation();
+  const [status, setStatus] = useState({});
+  const [secret, setSecret] = useState(null);
+  const [recoveryCodes, setRecoveryCodes] = useState([]);
+  const [code, setCode] = useState('');
+
+  const loadStatus = async () => {
+    const res = await API.get('/api/user/2fa');
+    const { success, message, data } = res.data;
+    if (success) {
+      setStatus(data);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadStatus().then();
+  }, []);
+
+  const setup = async () => {
+    const res = await API.post('/api/user/2fa/setup');
+    const { success, message, data } = res.data;
+    if (success) {
+      setSecret(data);
+      setRecoveryCodes([]);
+    } else {
+      showError(message);
+    }
+  };
+
+  const post = async (path) => {
+    const res = await API.post(path, { code });
+    const { success, message, data } = res.data;
+    if (!success) {
+      showError(message);
+      return;
+    }
+    setCode('');
+    setSecret(null);
+    setRecoveryCodes(data?.recovery_codes || []);
+    showSuccess(t('setting.personal.two_factor.messages.success'));
+    await loadStatus();
+  };
+
+  const copyRecoveryCodes = async () => {
+    if (await copy(recoveryCodes.join('\n'))) {
+      showSuccess(t('setting.personal.two_factor.messages.copied'));
+    }
+  };
+
+  return (
+    <>
+      <Header as='h3'>{t('setting.personal.two_factor.title')}</Header>
+      {status.enforced && !status.enabled && (
+        <Message warning>{t('setting.personal.two_factor.enforced')}</Message>
+      )}
+      {recoveryCodes.length > 0 && (
+        <Message positive>
+          <Message.Header>
+            {t('setting.personal.two_factor.recovery_codes')}
+          </Message.Header>
+          <p>{t('setting.personal.two_factor.recovery_codes_notice')}</p>
+          <pre>{recoveryCodes.join('\n')}</pre>
+          <Button size='small' onClick={copyRecoveryCodes}>
+            {t('setting.personal.two_factor.buttons.copy')}
+          </Button>
+        </Message>
+      )}
+      {status.enabled ? (
+        <>
+          <Message>
+            {t('setting.personal.two_factor.enabled', {
+              count: status.recovery_codes_left,
+            })}
+          </Message>
+          <Form>
+            <Form.Group inline>
+              <Form.Input
+                placeholder={t('setting.personal.two_factor.code_or_recovery')}
+                value={code}
+                onChange={(e, { value }) => setCode(value)}
+              />
+              <Form.Button
+                onClick={() => post('/api/user/2fa/recovery_codes')}
+              >
+                {t('setting.personal.two_factor.buttons.regenerate')}
+              </Form.Button>
+              {!status.enforced && (
+                <Form.Button
+                  negative
+                  onClick={() => post('/api/user/2fa/disable')}
+                >
+                  {t('setting.personal.two_factor.buttons.disable')}
+                </Form.Button>
+              )}
+            </Form.Group>
+          </Form>
+        </>
+      ) : secret ? (
+        <>
+          <Message>
+            <p>{t('setting.personal.two_factor.setup_notice')}</p>
+            <p>
+              {t('setting.personal.two_factor.secret')}
+              <code>{secret.secret}</code>
+            </p>
+            <p style={{ wordBreak: 'break-all' }}>
+              <code>{secret.uri}</code>
+            </p>
+          </Message>
+          <Form>
+            <Form.Group inline>
+              <Form.Input
+                placeholder={t('setting.personal.two_factor.code')}
+                value={code}
+                onChange={(e, { value }) => setCode(value)}
+              />
+              <Form.Button
+                primary
+                onClick={() => post('/api/user/2fa/enable')}
+              >
+                {t('setting.personal.two_factor.buttons.enable')}
+              </Form.Button>
+            </Form.Group>
+          </Form>
+        </>
+      ) : (
+        <Button onClick={setup}>
+          {t('setting.personal.two_factor.buttons.setup')}
+        </Button>
+      )}
+    </>
+  );
+};
+
+export default TwoFactorSetting;
@@ -24,6 +24,8 @@ function renderRole(role, t) {
   switch (role) {
     case 1:
       return <Label>{t('user.table.role_types.normal')}</Label>;
+    case 5:
+      return <Label color='teal'>{t('user.table.role_types.reseller')}</Label>;
     case 10:
       return <Label color='yellow'>{t('user.table.role_types.admin')}</Label>;
     case 100:
@@ -79,6 +81,30 @@ const UsersTable = () => {
       });
   }, [orderBy]);
 
+  const resetTwoFactor = async (user, idx) => {
+    const res = await API.delete(`/api/user/${user.id}/2fa`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('user.messages.operation_success'));
+      let newUsers = [...users];
+      let realIdx = (activePage - 1) * ITEMS_PER_PAGE + idx;
+      newUsers[realIdx].two_factor_enabled = false;
+      setUsers(newUsers);
+    } else {
+      showError(message);
+    }
+  };
+
+  const revokeSessions = async (user) => {
+    const res = await API.delete(`/api/user/${user.id}/sessions`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('user.messages.operation_success'));
+    } else {
+      showError(message);
+    }
+  };
+
   const manageUser = (username, action, idx) => {
     (async () => {
       const res = await API.post('/api/user/manage', {
@@ -350,6 +376,24 @@ const UsersTable = () => {
                           ? t('user.buttons.disable')
                           : t('user.buttons.enable')}
                       </Button>
+                      <Button
+                        size={'tiny'}
+                        onClick={() => {
+                          resetTwoFactor(user, idx).then();
+                        }}
+                        disabled={!user.two_factor_enabled}
+                      >
+                        {t('user.buttons.reset_two_factor')}
+                      </Button>
+                      <Button
+                        size={'tiny'}
+                        onClick={() => {
+                          revokeSessions(user).then();
+                        }}
+                        disabled={user.role === 100}
+                      >
+                        {t('user.buttons.revoke_sessions')}
+                      </Button>
                       <Button
                         size={'tiny'}
                         as={Link}
@@ -370,6 +414,9 @@ const UsersTable = () => {
               <Button size='small' as={Link} to='/user/add' loading={loading}>
                 {t('user.buttons.add')}
               </Button>
+              <Button size='small' as={Link} to='/trash' loading={loading}>
+                {t('user.buttons.trash')}
+              </Button>
               <Dropdown
                 placeholder={t('user.table.sort_by')}
                 selection
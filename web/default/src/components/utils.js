@@ -1,4 +1,4 @@
-import { API, showError } from '../helpers';
+import { API, BASE_PATH, showError } from '../helpers';
 
 export async function getOAuthState() {
   const res = await API.get('/api/oauth/state');
@@ -22,8 +22,20 @@ export async function onGitHubOAuthClicked(github_client_id) {
 export async function onLarkOAuthClicked(lark_client_id) {
   const state = await getOAuthState();
   if (!state) return;
-  let redirect_uri = `${window.location.origin}/oauth/lark`;
+  let redirect_uri = `${window.location.origin}${BASE_PATH}/oauth/lark`;
   window.open(
     `https://open.feishu.cn/open-apis/authen/v1/index?redirect_uri=${redirect_uri}&app_id=${lark_client_id}&state=${state}`
   );
-}
\ No newline at end of file
+}
+// finishLogin saves the signed in user, or asks for the two-factor code first;
+// users whose role requires two-factor authentication are sent to the settings
+export function finishLogin(message, data, userDispatch, navigate, path = '/') {
+  if (message === 'two_factor') {
+    navigate('/login/2fa');
+    return false;
+  }
+  userDispatch({ type: 'login', payload: data });
+  localStorage.setItem('user', JSON.stringify(data));
+  navigate(message === 'two_factor_setup' ? '/setting' : path);
+  return true;
+}
@@ -1,8 +1,11 @@
 import { showError } from './utils';
 import axios from 'axios';
 
+// the path prefix the console is served under, injected into the index page for BASE_PATH
+export const BASE_PATH = window.__BASE_PATH__ || '';
+
 export const API = axios.create({
-  baseURL: process.env.REACT_APP_SERVER ? process.env.REACT_APP_SERVER : '',
+  baseURL: process.env.REACT_APP_SERVER ? process.env.REACT_APP_SERVER : BASE_PATH,
 });
 
 API.interceptors.response.use(
@@ -49,6 +49,35 @@ export function renderNumber(num) {
   }
 }
 
+// getDisplayCurrency returns the currency chosen by the user and its rate, or the base currency when the user has
+// not chosen one or its rate is no longer configured
+export function getDisplayCurrency() {
+  const baseCurrency = localStorage.getItem('base_currency') || 'USD';
+  let rates = {};
+  let user = {};
+  try {
+    rates = JSON.parse(localStorage.getItem('exchange_rates') || '{}');
+    user = JSON.parse(localStorage.getItem('user') || '{}') || {};
+  } catch (e) {}
+  if (user.currency && rates[user.currency]) {
+    return { currency: user.currency, rate: rates[user.currency] };
+  }
+  return { currency: baseCurrency, rate: 1 };
+}
+
+function formatCurrency(amount, currency, precision) {
+  try {
+    return new Intl.NumberFormat(undefined, {
+      style: 'currency',
+      currency,
+      minimumFractionDigits: precision,
+      maximumFractionDigits: precision,
+    }).format(amount);
+  } catch (e) {
+    return `${amount.toFixed(precision)} ${currency}`;
+  }
+}
+
 export function renderQuota(quota, t, precision = 2) {
   const displayInCurrency =
     localStorage.getItem('display_in_currency') === 'true';
@@ -57,7 +86,12 @@ export function renderQuota(quota, t, precision = 2) {
   );
 
   if (displayInCurrency) {
-    const amount = (quota / quotaPerUnit).toFixed(precision);
+    const { currency, rate } = getDisplayCurrency();
+    const amount = formatCurrency(
+      (quota / quotaPerUnit) * rate,
+      currency,
+      precision
+    );
     return t('common.quota.display_short', { amount });
   }
 
@@ -72,7 +106,8 @@ export function renderQuotaWithPrompt(quota, t) {
   );
 
   if (displayInCurrency) {
-    const amount = (quota / quotaPerUnit).toFixed(2);
+    const { currency, rate } = getDisplayCurrency();
+    const amount = formatCurrency((quota / quotaPerUnit) * rate, currency, 2);
     return ` (${t('common.quota.display', { amount })})`;
   }
 
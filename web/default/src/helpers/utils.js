@@ -1,7 +1,7 @@
 import {toast} from 'react-toastify';
 import {toastConstants} from '../constants';
 import React from 'react';
-import {API} from './api';
+import {API, BASE_PATH} from './api';
 
 const HTMLToastContent = ({ htmlContent }) => {
   return <div dangerouslySetInnerHTML={{ __html: htmlContent }} />;
@@ -30,7 +30,7 @@ export function getSystemName() {
 
 export function getLogo() {
   let logo = localStorage.getItem('logo');
-  if (!logo) return '/logo.png';
+  if (!logo) return `${BASE_PATH}/logo.png`;
   return logo;
 }
 
@@ -81,7 +81,7 @@ export function showError(error) {
       switch (error.response.status) {
         case 401:
           // toast.error('错误：未登录或登录已过期，请重新登录！', showErrorOptions);
-          window.location.href = '/login?expired=true';
+          window.location.href = `${BASE_PATH}/login?expired=true`;
           break;
         case 429:
           toast.error('错误：请求次数过多，请稍后再试！', showErrorOptions);
@@ -1,10 +1,12 @@
 import React from 'react';
 import ReactDOM from 'react-dom/client';
 import { BrowserRouter } from 'react-router-dom';
+import { BASE_PATH } from './helpers';
 import { Container } from 'semantic-ui-react';
 import App from './App';
 import Header from './components/Header';
 import Footer from './components/Footer';
+import StartupBanner from './components/StartupBanner';
 import 'semantic-ui-css/semantic.min.css';
 import './index.css';
 import { UserProvider } from './context/User';
@@ -18,9 +20,10 @@ root.render(
   <React.StrictMode>
     <StatusProvider>
       <UserProvider>
-        <BrowserRouter>
+        <BrowserRouter basename={BASE_PATH}>
           <Header />
           <Container className={'main-content'}>
+            <StartupBanner />
             <App />
           </Container>
           <ToastContainer />
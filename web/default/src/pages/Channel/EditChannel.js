@@ -2,7 +2,7 @@ import React, {useEffect, useState} from 'react';
 import {useTranslation} from 'react-i18next';
 import {Button, Card, Form, Input, Message} from 'semantic-ui-react';
 import {useNavigate, useParams} from 'react-router-dom';
-import {API, copy, getChannelModels, showError, showInfo, showSuccess, verifyJSON,} from '../../helpers';
+import {API, copy, getChannelModels, showError, showInfo, showSuccess, showWarning, verifyJSON,} from '../../helpers';
 import {CHANNEL_OPTIONS} from '../../constants';
 import {renderChannelTip} from '../../helpers/render';
 
@@ -12,6 +12,35 @@ const MODEL_MAPPING_EXAMPLE = {
   'gpt-4-32k-0314': 'gpt-4-32k',
 };
 
+const MAINTENANCE_EXAMPLE = [
+  { cron: '0 2 * * *', duration: 60, timezone: 'America/Los_Angeles' },
+];
+
+// formatHosts writes the addresses of the hosts as the lines of /etc/hosts
+function formatHosts(hosts) {
+  return Object.entries(hosts)
+    .flatMap(([host, addresses]) =>
+      addresses.map((address) => `${address} ${host}`)
+    )
+    .join('\n');
+}
+
+// parseHosts reads the lines of /etc/hosts, an address followed by its host
+// names, it returns null if a line has no host name
+function parseHosts(text) {
+  const hosts = {};
+  for (let line of text.split('\n')) {
+    line = line.split('#')[0].trim();
+    if (line === '') continue;
+    const [address, ...names] = line.split(/\s+/);
+    if (names.length === 0) return null;
+    for (const name of names) {
+      hosts[name] = [...(hosts[name] || []), address];
+    }
+  }
+  return hosts;
+}
+
 function type2secretPrompt(type, t) {
   switch (type) {
     case 15:
@@ -65,6 +94,8 @@ const EditChannel = () => {
     vertex_ai_project_id: '',
     vertex_ai_adc: '',
   });
+  const [maintenance, setMaintenance] = useState('');
+  const [dialHosts, setDialHosts] = useState('');
   const handleInputChange = (e, { name, value }) => {
     setInputs((inputs) => ({ ...inputs, [name]: value }));
     if (name === 'type') {
@@ -103,7 +134,14 @@ const EditChannel = () => {
       }
       setInputs(data);
       if (data.config !== '') {
-        setConfig(JSON.parse(data.config));
+        let localConfig = JSON.parse(data.config);
+        setConfig(localConfig);
+        if (localConfig.maintenance) {
+          setMaintenance(JSON.stringify(localConfig.maintenance, null, 2));
+        }
+        if (localConfig.dial && localConfig.dial.hosts) {
+          setDialHosts(formatHosts(localConfig.dial.hosts));
+        }
       }
       setBasicModels(getChannelModels(data.type));
     } else {
@@ -191,6 +229,15 @@ const EditChannel = () => {
       showInfo(t('channel.edit.messages.model_mapping_invalid'));
       return;
     }
+    if (maintenance !== '' && !verifyJSON(maintenance)) {
+      showInfo(t('channel.edit.messages.maintenance_invalid'));
+      return;
+    }
+    const hosts = parseHosts(dialHosts);
+    if (hosts === null) {
+      showInfo(t('channel.edit.messages.dial_hosts_invalid'));
+      return;
+    }
     let localInputs = { ...inputs };
     if (localInputs.key === 'undefined|undefined|undefined') {
       localInputs.key = ''; // prevent potential bug
@@ -202,12 +249,29 @@ const EditChannel = () => {
       );
     }
     if (localInputs.type === 3 && localInputs.other === '') {
-      localInputs.other = '2024-03-01-preview';
+      localInputs.other = '2024-10-21';
     }
     let res;
     localInputs.models = localInputs.models.join(',');
     localInputs.group = localInputs.groups.join(',');
-    localInputs.config = JSON.stringify(config);
+    let localConfig = { ...config };
+    if (maintenance !== '') {
+      localConfig.maintenance = JSON.parse(maintenance);
+    } else {
+      delete localConfig.maintenance;
+    }
+    localConfig.dial = { ...localConfig.dial, hosts };
+    if (Object.keys(hosts).length === 0) {
+      delete localConfig.dial.hosts;
+    }
+    if (
+      !localConfig.dial.network &&
+      !localConfig.dial.hosts &&
+      !localConfig.dial.fallback_delay
+    ) {
+      delete localConfig.dial;
+    }
+    localInputs.config = JSON.stringify(localConfig);
     if (isEdit) {
       res = await API.put(`/api/channel/`, {
         ...localInputs,
@@ -216,7 +280,7 @@ const EditChannel = () => {
     } else {
       res = await API.post(`/api/channel/`, localInputs);
     }
-    const { success, message } = res.data;
+    const { success, message, warnings } = res.data;
     if (success) {
       if (isEdit) {
         showSuccess(t('channel.edit.messages.update_success'));
@@ -224,6 +288,9 @@ const EditChannel = () => {
         showSuccess(t('channel.edit.messages.create_success'));
         setInputs(originInputs);
       }
+      if (warnings && warnings.length > 0) {
+        showWarning(warnings.join('\n'));
+      }
     } else {
       showError(message);
     }
@@ -326,7 +393,7 @@ const EditChannel = () => {
                   <Form.Input
                     label='默认 API 版本'
                     name='other'
-                    placeholder='请输入默认 API 版本，例如：2024-03-01-preview，该配置可以被实际的请求查询参数所覆盖'
+                    placeholder='请输入默认 API 版本，例如：2024-10-21，该配置可以被实际的请求查询参数所覆盖'
                     onChange={handleInputChange}
                     value={inputs.other}
                     autoComplete='new-password'
@@ -363,6 +430,36 @@ const EditChannel = () => {
               </Form.Field>
             )}
 
+            {inputs.type === 1 && (
+              <Form.Group widths='equal'>
+                <Form.Input
+                  label={t('channel.edit.openai_organization')}
+                  name='openai_organization'
+                  placeholder={t('channel.edit.openai_organization_placeholder')}
+                  onChange={(e, { value }) =>
+                    setConfig((config) => ({
+                      ...config,
+                      openai_organization: value.trim(),
+                    }))
+                  }
+                  value={config.openai_organization || ''}
+                  autoComplete='new-password'
+                />
+                <Form.Input
+                  label={t('channel.edit.openai_project')}
+                  name='openai_project'
+                  placeholder={t('channel.edit.openai_project_placeholder')}
+                  onChange={(e, { value }) =>
+                    setConfig((config) => ({
+                      ...config,
+                      openai_project: value.trim(),
+                    }))
+                  }
+                  value={config.openai_project || ''}
+                  autoComplete='new-password'
+                />
+              </Form.Group>
+            )}
             {inputs.type === 18 && (
               <Form.Field>
                 <Form.Input
@@ -521,6 +618,332 @@ const EditChannel = () => {
                 </Form.Field>
               </>
             )}
+            <Form.Field>
+              <Form.TextArea
+                label={t('channel.edit.maintenance')}
+                placeholder={`${t(
+                  'channel.edit.maintenance_placeholder'
+                )}\n${JSON.stringify(MAINTENANCE_EXAMPLE, null, 2)}`}
+                name='maintenance'
+                onChange={(e, { value }) => setMaintenance(value)}
+                value={maintenance}
+                style={{
+                  minHeight: 150,
+                  fontFamily: 'JetBrains Mono, Consolas',
+                }}
+                autoComplete='new-password'
+              />
+            </Form.Field>
+            <Form.Field>
+              <Form.Input
+                label={t('channel.edit.max_tokens')}
+                name='max_tokens'
+                type='number'
+                min='0'
+                placeholder={t('channel.edit.max_tokens_placeholder')}
+                onChange={(e, { value }) =>
+                  setConfig((config) => ({
+                    ...config,
+                    max_tokens: parseInt(value) || 0,
+                  }))
+                }
+                value={config.max_tokens || ''}
+                autoComplete='new-password'
+              />
+            </Form.Field>
+            <Form.Group widths='equal'>
+              <Form.Input
+                label={t('channel.edit.daily_quota_limit')}
+                name='daily_quota_limit'
+                type='number'
+                min='0'
+                placeholder={t('channel.edit.quota_limit_placeholder')}
+                onChange={(e, { value }) =>
+                  setConfig((config) => ({
+                    ...config,
+                    daily_quota_limit: parseInt(value) || 0,
+                  }))
+                }
+                value={config.daily_quota_limit || ''}
+                autoComplete='new-password'
+              />
+              <Form.Input
+                label={t('channel.edit.monthly_quota_limit')}
+                name='monthly_quota_limit'
+                type='number'
+                min='0'
+                placeholder={t('channel.edit.quota_limit_placeholder')}
+                onChange={(e, { value }) =>
+                  setConfig((config) => ({
+                    ...config,
+                    monthly_quota_limit: parseInt(value) || 0,
+                  }))
+                }
+                value={config.monthly_quota_limit || ''}
+                autoComplete='new-password'
+              />
+            </Form.Group>
+            <Form.Group widths='equal'>
+              <Form.Input
+                label={t('channel.edit.warm_up_interval')}
+                name='warm_up_interval'
+                type='number'
+                min='0'
+                placeholder={t('channel.edit.warm_up_interval_placeholder')}
+                onChange={(e, { value }) =>
+                  setConfig((config) => ({
+                    ...config,
+                    warm_up: {
+                      ...config.warm_up,
+                      interval: parseInt(value) || 0,
+                    },
+                  }))
+                }
+                value={(config.warm_up && config.warm_up.interval) || ''}
+                autoComplete='new-password'
+              />
+              <Form.Input
+                label={t('channel.edit.wake_url')}
+                name='wake_url'
+                placeholder={t('channel.edit.wake_url_placeholder')}
+                onChange={(e, { value }) =>
+                  setConfig((config) => ({
+                    ...config,
+                    warm_up: { ...config.warm_up, wake_url: value },
+                  }))
+                }
+                value={(config.warm_up && config.warm_up.wake_url) || ''}
+                autoComplete='new-password'
+              />
+            </Form.Group>
+            <Form.Group widths='equal'>
+              <Form.Input
+                label={t('channel.edit.wake_wait')}
+                name='wake_wait'
+                type='number'
+                min='0'
+                placeholder='60'
+                onChange={(e, { value }) =>
+                  setConfig((config) => ({
+                    ...config,
+                    warm_up: { ...config.warm_up, wait: parseInt(value) || 0 },
+                  }))
+                }
+                value={(config.warm_up && config.warm_up.wait) || ''}
+                autoComplete='new-password'
+              />
+              <Form.Input
+                label={t('channel.edit.wake_idle_minutes')}
+                name='wake_idle_minutes'
+                type='number'
+                min='0'
+                placeholder='10'
+                onChange={(e, { value }) =>
+                  setConfig((config) => ({
+                    ...config,
+                    warm_up: {
+                      ...config.warm_up,
+                      idle_minutes: parseInt(value) || 0,
+                    },
+                  }))
+                }
+                value={(config.warm_up && config.warm_up.idle_minutes) || ''}
+                autoComplete='new-password'
+              />
+            </Form.Group>
+            <Form.Group widths='equal'>
+              <Form.Input
+                label={t('channel.edit.image_description_model')}
+                name='image_description_model'
+                placeholder={t(
+                  'channel.edit.image_description_model_placeholder'
+                )}
+                onChange={(e, { value }) =>
+                  setConfig((config) => ({
+                    ...config,
+                    image_description: {
+                      ...config.image_description,
+                      model: value,
+                    },
+                  }))
+                }
+                value={
+                  (config.image_description &&
+                    config.image_description.model) ||
+                  ''
+                }
+                autoComplete='new-password'
+              />
+              <Form.Select
+                label={t('channel.edit.image_description_mode')}
+                name='image_description_mode'
+                options={[
+                  {
+                    key: 'caption',
+                    text: t('channel.edit.image_description_caption'),
+                    value: 'caption',
+                  },
+                  {
+                    key: 'ocr',
+                    text: t('channel.edit.image_description_ocr'),
+                    value: 'ocr',
+                  },
+                ]}
+                onChange={(e, { value }) =>
+                  setConfig((config) => ({
+                    ...config,
+                    image_description: {
+                      ...config.image_description,
+                      mode: value,
+                    },
+                  }))
+                }
+                value={
+                  (config.image_description &&
+                    config.image_description.mode) ||
+                  'caption'
+                }
+              />
+              <Form.Dropdown
+                label={t('channel.edit.image_description_models')}
+                placeholder={t(
+                  'channel.edit.image_description_models_placeholder'
+                )}
+                name='image_description_models'
+                fluid
+                multiple
+                selection
+                options={inputs.models.map((model) => ({
+                  key: model,
+                  text: model,
+                  value: model,
+                }))}
+                onChange={(e, { value }) =>
+                  setConfig((config) => ({
+                    ...config,
+                    image_description: {
+                      ...config.image_description,
+                      models: value,
+                    },
+                  }))
+                }
+                value={
+                  (config.image_description &&
+                    config.image_description.models) ||
+                  []
+                }
+              />
+            </Form.Group>
+            <Form.Group widths='equal'>
+              <Form.Select
+                label={t('channel.edit.dial_network')}
+                name='dial_network'
+                options={[
+                  {
+                    key: 'dual',
+                    text: t('channel.edit.dial_network_dual'),
+                    value: '',
+                  },
+                  {
+                    key: 'ipv4',
+                    text: t('channel.edit.dial_network_ipv4'),
+                    value: 'ipv4',
+                  },
+                  {
+                    key: 'ipv6',
+                    text: t('channel.edit.dial_network_ipv6'),
+                    value: 'ipv6',
+                  },
+                ]}
+                onChange={(e, { value }) =>
+                  setConfig((config) => ({
+                    ...config,
+                    dial: { ...config.dial, network: value },
+                  }))
+                }
+                value={(config.dial && config.dial.network) || ''}
+              />
+              <Form.Input
+                label={t('channel.edit.dial_fallback_delay')}
+                name='dial_fallback_delay'
+                type='number'
+                placeholder={t('channel.edit.dial_fallback_delay_placeholder')}
+                onChange={(e, { value }) =>
+                  setConfig((config) => ({
+                    ...config,
+                    dial: {
+                      ...config.dial,
+                      fallback_delay: parseInt(value) || 0,
+                    },
+                  }))
+                }
+                value={(config.dial && config.dial.fallback_delay) || ''}
+                autoComplete='new-password'
+              />
+            </Form.Group>
+            <Form.Field>
+              <Form.TextArea
+                label={t('channel.edit.dial_hosts')}
+                placeholder={t('channel.edit.dial_hosts_placeholder')}
+                name='dial_hosts'
+                onChange={(e, { value }) => setDialHosts(value)}
+                value={dialHosts}
+                style={{
+                  minHeight: 80,
+                  fontFamily: 'JetBrains Mono, Consolas',
+                }}
+                autoComplete='new-password'
+              />
+            </Form.Field>
+            <Form.Checkbox
+              checked={config.auto_upgrade_api_version === true}
+              label={t('channel.edit.auto_upgrade_api_version')}
+              name='auto_upgrade_api_version'
+              onChange={() =>
+                setConfig((config) => ({
+                  ...config,
+                  auto_upgrade_api_version: !config.auto_upgrade_api_version,
+                }))
+              }
+            />
+            <Form.Checkbox
+              checked={config.native_web_search === true}
+              label={t('channel.edit.native_web_search')}
+              name='native_web_search'
+              onChange={() =>
+                setConfig((config) => ({
+                  ...config,
+                  native_web_search: !config.native_web_search,
+                }))
+              }
+            />
+            <Form.Group inline>
+              <Form.Checkbox
+                checked={config.embedding_dimensions === 'truncate'}
+                label={t('channel.edit.embedding_dimensions_truncate')}
+                name='embedding_dimensions'
+                onChange={() =>
+                  setConfig((config) => ({
+                    ...config,
+                    embedding_dimensions:
+                      config.embedding_dimensions === 'truncate'
+                        ? ''
+                        : 'truncate',
+                  }))
+                }
+              />
+              <Form.Checkbox
+                checked={config.normalize_embeddings === true}
+                label={t('channel.edit.normalize_embeddings')}
+                name='normalize_embeddings'
+                onChange={() =>
+                  setConfig((config) => ({
+                    ...config,
+                    normalize_embeddings: !config.normalize_embeddings,
+                  }))
+                }
+              />
+            </Form.Group>
             {inputs.type === 33 && (
               <Form.Field>
                 <Form.Input
@@ -0,0 +1,227 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { Button, Card, Form, Label, Table } from 'semantic-ui-react';
+import { useNavigate } from 'react-router-dom';
+import { API, showError, showSuccess } from '../../helpers';
+
+const PROVIDER_OPTIONS = [
+  { key: 'azure', text: 'Azure OpenAI', value: 'azure' },
+  { key: 'openai', text: 'OpenAI', value: 'openai' },
+];
+
+function renderStatus(status, t) {
+  switch (status) {
+    case 'created':
+      return (
+        <Label basic color='green'>
+          {t('channel.import.status.created')}
+        </Label>
+      );
+    case 'exists':
+      return <Label basic>{t('channel.import.status.exists')}</Label>;
+    case 'preview':
+      return (
+        <Label basic color='blue'>
+          {t('channel.import.status.preview')}
+        </Label>
+      );
+    default:
+      return (
+        <Label basic color='red'>
+          {t('channel.import.status.failed')}
+        </Label>
+      );
+  }
+}
+
+const ImportChannel = () => {
+  const { t } = useTranslation();
+  const navigate = useNavigate();
+  const [loading, setLoading] = useState(false);
+  const [groupOptions, setGroupOptions] = useState([]);
+  const [results, setResults] = useState([]);
+  const [inputs, setInputs] = useState({
+    provider: 'azure',
+    group: 'default',
+    subscription_id: '',
+    access_token: '',
+    tenant_id: '',
+    client_id: '',
+    client_secret: '',
+    api_version: '',
+    admin_key: '',
+  });
+
+  const handleInputChange = (e, { name, value }) => {
+    setInputs((inputs) => ({ ...inputs, [name]: value }));
+  };
+
+  const fetchGroups = async () => {
+    try {
+      let res = await API.get(`/api/group/`);
+      setGroupOptions(
+        res.data.data.map((group) => ({
+          key: group,
+          text: group,
+          value: group,
+        }))
+      );
+    } catch (error) {
+      showError(error.message);
+    }
+  };
+
+  useEffect(() => {
+    fetchGroups().then();
+  }, []);
+
+  const submit = async (dryRun) => {
+    setLoading(true);
+    const res = await API.post(`/api/channel/import`, {
+      ...inputs,
+      dry_run: dryRun,
+    });
+    const { success, message, data } = res.data;
+    if (success) {
+      setResults(data);
+      if (!dryRun) {
+        showSuccess(t('channel.import.messages.success'));
+      }
+    } else {
+      showError(message);
+    }
+    setLoading(false);
+  };
+
+  return (
+    <div className='dashboard-container'>
+      <Card fluid className='chart-card'>
+        <Card.Content>
+          <Card.Header className='header'>
+            {t('channel.import.title')}
+          </Card.Header>
+          <Form loading={loading} autoComplete='new-password'>
+            <Form.Group widths='equal'>
+              <Form.Select
+                label={t('channel.import.provider')}
+                name='provider'
+                options={PROVIDER_OPTIONS}
+                value={inputs.provider}
+                onChange={handleInputChange}
+              />
+              <Form.Dropdown
+                label={t('channel.edit.group')}
+                name='group'
+                selection
+                options={groupOptions}
+                value={inputs.group}
+                onChange={handleInputChange}
+              />
+            </Form.Group>
+            {inputs.provider === 'azure' ? (
+              <>
+                <Form.Group widths='equal'>
+                  <Form.Input
+                    label={t('channel.import.subscription_id')}
+                    name='subscription_id'
+                    value={inputs.subscription_id}
+                    onChange={handleInputChange}
+                    required
+                  />
+                  <Form.Input
+                    label={t('channel.import.api_version')}
+                    name='api_version'
+                    placeholder='2024-02-01'
+                    value={inputs.api_version}
+                    onChange={handleInputChange}
+                  />
+                </Form.Group>
+                <Form.Input
+                  label={t('channel.import.access_token')}
+                  name='access_token'
+                  type='password'
+                  placeholder={t('channel.import.access_token_placeholder')}
+                  value={inputs.access_token}
+                  onChange={handleInputChange}
+                  autoComplete='new-password'
+                />
+                <Form.Group widths='equal'>
+                  <Form.Input
+                    label={t('channel.import.tenant_id')}
+                    name='tenant_id'
+                    value={inputs.tenant_id}
+                    onChange={handleInputChange}
+                  />
+                  <Form.Input
+                    label={t('channel.import.client_id')}
+                    name='client_id'
+                    value={inputs.client_id}
+                    onChange={handleInputChange}
+                  />
+                  <Form.Input
+                    label={t('channel.import.client_secret')}
+                    name='client_secret'
+                    type='password'
+                    value={inputs.client_secret}
+                    onChange={handleInputChange}
+                    autoComplete='new-password'
+                  />
+                </Form.Group>
+              </>
+            ) : (
+              <Form.Input
+                label={t('channel.import.admin_key')}
+                name='admin_key'
+                type='password'
+                placeholder={t('channel.import.admin_key_placeholder')}
+                value={inputs.admin_key}
+                onChange={handleInputChange}
+                autoComplete='new-password'
+                required
+              />
+            )}
+            <Button type='button' onClick={() => navigate('/channel')}>
+              {t('channel.edit.buttons.cancel')}
+            </Button>
+            <Button type='button' onClick={() => submit(true)}>
+              {t('channel.import.buttons.preview')}
+            </Button>
+            <Button type='button' positive onClick={() => submit(false)}>
+              {t('channel.import.buttons.import')}
+            </Button>
+          </Form>
+          {results.length > 0 && (
+            <Table basic='very' compact size='small'>
+              <Table.Header>
+                <Table.Row>
+                  <Table.HeaderCell>
+                    {t('channel.import.name')}
+                  </Table.HeaderCell>
+                  <Table.HeaderCell>
+                    {t('channel.import.models')}
+                  </Table.HeaderCell>
+                  <Table.HeaderCell>
+                    {t('channel.import.status.title')}
+                  </Table.HeaderCell>
+                </Table.Row>
+              </Table.Header>
+              <Table.Body>
+                {results.map((result) => (
+                  <Table.Row key={result.external_id}>
+                    <Table.Cell>{result.name}</Table.Cell>
+                    <Table.Cell>{(result.models || []).join(', ')}</Table.Cell>
+                    <Table.Cell>
+                      {renderStatus(result.status, t)} {result.message}
+                    </Table.Cell>
+                  </Table.Row>
+                ))}
+              </Table.Body>
+            </Table>
+          )}
+        </Card.Content>
+      </Card>
+    </div>
+  );
+};
+
+export default ImportChannel;
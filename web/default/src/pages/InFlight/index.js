@@ -0,0 +1,21 @@
+import React from 'react';
+import { useTranslation } from 'react-i18next';
+import { Card } from 'semantic-ui-react';
+import InFlightTable from '../../components/InFlightTable';
+
+const InFlight = () => {
+  const { t } = useTranslation();
+
+  return (
+    <div className='dashboard-container'>
+      <Card fluid className='chart-card'>
+        <Card.Content>
+          <Card.Header className='header'>{t('in_flight.title')}</Card.Header>
+          <InFlightTable />
+        </Card.Content>
+      </Card>
+    </div>
+  );
+};
+
+export default InFlight;
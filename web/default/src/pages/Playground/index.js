@@ -0,0 +1,297 @@
+import React, { useEffect, useRef, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { Button, Card, Comment, Form, Grid, Segment } from 'semantic-ui-react';
+import { API, BASE_PATH, showError } from '../../helpers';
+
+const Playground = () => {
+  const { t } = useTranslation();
+  const [tokenOptions, setTokenOptions] = useState([]);
+  const [modelOptions, setModelOptions] = useState([]);
+  const [inputs, setInputs] = useState({
+    token_id: '',
+    model: '',
+    system: '',
+    temperature: 1,
+    top_p: 1,
+    max_tokens: '',
+    stream: true,
+  });
+  const [messages, setMessages] = useState([]);
+  const [prompt, setPrompt] = useState('');
+  const [usage, setUsage] = useState(null);
+  const [loading, setLoading] = useState(false);
+  const abortController = useRef(null);
+
+  const handleInputChange = (e, { name, value, checked }) => {
+    setInputs((inputs) => ({
+      ...inputs,
+      [name]: checked !== undefined ? checked : value,
+    }));
+  };
+
+  const loadTokens = async () => {
+    const res = await API.get(`/api/token/?p=0`);
+    const { success, message, data } = res.data || {};
+    if (success && data) {
+      setTokenOptions(
+        data
+          .filter((token) => token.status === 1)
+          .map((token) => ({
+            key: token.id,
+            text: token.name,
+            value: token.id,
+          }))
+      );
+      if (data.length > 0) {
+        setInputs((inputs) => ({ ...inputs, token_id: data[0].id }));
+      }
+    } else {
+      showError(message || 'Failed to load tokens');
+    }
+  };
+
+  const loadModels = async () => {
+    const res = await API.get(`/api/user/available_models`);
+    const { success, message, data } = res.data || {};
+    if (success && data) {
+      setModelOptions(
+        data.map((model) => ({ key: model, text: model, value: model }))
+      );
+      if (data.length > 0) {
+        setInputs((inputs) => ({ ...inputs, model: data[0] }));
+      }
+    } else {
+      showError(message || 'Failed to load models');
+    }
+  };
+
+  useEffect(() => {
+    loadTokens().catch((error) => showError(error.message));
+    loadModels().catch((error) => showError(error.message));
+  }, []);
+
+  // appendDelta appends the streamed content to the last message
+  const appendDelta = (content) => {
+    setMessages((messages) => {
+      const last = messages[messages.length - 1];
+      return [
+        ...messages.slice(0, -1),
+        { ...last, content: last.content + content },
+      ];
+    });
+  };
+
+  const readStream = async (response) => {
+    const reader = response.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = '';
+    for (;;) {
+      const { done, value } = await reader.read();
+      if (done) break;
+      buffer += decoder.decode(value, { stream: true });
+      const lines = buffer.split('\n');
+      buffer = lines.pop();
+      for (const line of lines) {
+        if (!line.startsWith('data:')) continue;
+        const data = line.slice(5).trim();
+        if (data === '[DONE]') return;
+        const chunk = JSON.parse(data);
+        if (chunk.usage) setUsage(chunk.usage);
+        const delta = chunk.choices?.[0]?.delta?.content;
+        if (delta) appendDelta(delta);
+      }
+    }
+  };
+
+  const send = async () => {
+    if (prompt === '' || loading) return;
+    if (inputs.token_id === '') {
+      showError(t('playground.messages.token_required'));
+      return;
+    }
+    const history = [...messages, { role: 'user', content: prompt }];
+    const request = {
+      model: inputs.model,
+      messages:
+        inputs.system !== ''
+          ? [{ role: 'system', content: inputs.system }, ...history]
+          : history,
+      temperature: parseFloat(inputs.temperature),
+      top_p: parseFloat(inputs.top_p),
+      stream: inputs.stream,
+    };
+    if (inputs.max_tokens !== '') {
+      request.max_tokens = parseInt(inputs.max_tokens);
+    }
+    if (inputs.stream) {
+      request.stream_options = { include_usage: true };
+    }
+    setMessages([...history, { role: 'assistant', content: '' }]);
+    setPrompt('');
+    setUsage(null);
+    setLoading(true);
+    abortController.current = new AbortController();
+    try {
+      const response = await fetch(
+        `${process.env.REACT_APP_SERVER || BASE_PATH}/api/playground/chat/completions`,
+        {
+          method: 'POST',
+          credentials: 'include',
+          headers: {
+            'Content-Type': 'application/json',
+            'X-OneAPI-Token-Id': inputs.token_id,
+          },
+          body: JSON.stringify(request),
+          signal: abortController.current.signal,
+        }
+      );
+      if (!response.ok) {
+        const data = await response.json();
+        throw new Error(data.error?.message || data.message);
+      }
+      if (inputs.stream) {
+        await readStream(response);
+      } else {
+        const data = await response.json();
+        setUsage(data.usage);
+        appendDelta(data.choices?.[0]?.message?.content || '');
+      }
+    } catch (error) {
+      if (error.name !== 'AbortError') {
+        showError(error.message);
+      }
+    }
+    setLoading(false);
+  };
+
+  const stop = () => {
+    abortController.current?.abort();
+  };
+
+  return (
+    <div className='dashboard-container'>
+      <Card fluid className='chart-card'>
+        <Card.Content>
+          <Card.Header className='header'>{t('playground.title')}</Card.Header>
+          <Grid stackable>
+            <Grid.Column width={5}>
+              <Form>
+                <Form.Dropdown
+                  label={t('playground.token')}
+                  name='token_id'
+                  selection
+                  search
+                  options={tokenOptions}
+                  value={inputs.token_id}
+                  onChange={handleInputChange}
+                />
+                <Form.Dropdown
+                  label={t('playground.model')}
+                  name='model'
+                  selection
+                  search
+                  options={modelOptions}
+                  value={inputs.model}
+                  onChange={handleInputChange}
+                />
+                <Form.TextArea
+                  label={t('playground.system')}
+                  name='system'
+                  value={inputs.system}
+                  onChange={handleInputChange}
+                />
+                <Form.Input
+                  label={`${t('playground.temperature')}: ${inputs.temperature}`}
+                  name='temperature'
+                  type='range'
+                  min={0}
+                  max={2}
+                  step={0.1}
+                  value={inputs.temperature}
+                  onChange={handleInputChange}
+                />
+                <Form.Input
+                  label={`${t('playground.top_p')}: ${inputs.top_p}`}
+                  name='top_p'
+                  type='range'
+                  min={0}
+                  max={1}
+                  step={0.05}
+                  value={inputs.top_p}
+                  onChange={handleInputChange}
+                />
+                <Form.Input
+                  label={t('playground.max_tokens')}
+                  name='max_tokens'
+                  type='number'
+                  value={inputs.max_tokens}
+                  onChange={handleInputChange}
+                />
+                <Form.Checkbox
+                  label={t('playground.stream')}
+                  name='stream'
+                  checked={inputs.stream}
+                  onChange={handleInputChange}
+                />
+              </Form>
+            </Grid.Column>
+            <Grid.Column width={11}>
+              <Segment style={{ minHeight: '50vh', overflowY: 'auto' }}>
+                <Comment.Group style={{ maxWidth: 'none' }}>
+                  {messages.map((message, index) => (
+                    <Comment key={index}>
+                      <Comment.Content>
+                        <Comment.Author>{message.role}</Comment.Author>
+                        <Comment.Text style={{ whiteSpace: 'pre-wrap' }}>
+                          {message.content}
+                        </Comment.Text>
+                      </Comment.Content>
+                    </Comment>
+                  ))}
+                </Comment.Group>
+              </Segment>
+              {usage && (
+                <p>
+                  {t('playground.usage', {
+                    prompt: usage.prompt_tokens,
+                    completion: usage.completion_tokens,
+                  })}
+                </p>
+              )}
+              <Form>
+                <Form.TextArea
+                  placeholder={t('playground.placeholder')}
+                  value={prompt}
+                  onChange={(e, { value }) => setPrompt(value)}
+                  onKeyDown={(e) => {
+                    if (e.key === 'Enter' && !e.shiftKey) {
+                      e.preventDefault();
+                      send();
+                    }
+                  }}
+                />
+                <Button primary onClick={send} loading={loading}>
+                  {t('playground.send')}
+                </Button>
+                {loading && (
+                  <Button onClick={stop}>{t('playground.stop')}</Button>
+                )}
+                <Button
+                  onClick={() => {
+                    setMessages([]);
+                    setUsage(null);
+                  }}
+                  disabled={loading}
+                >
+                  {t('playground.clear')}
+                </Button>
+              </Form>
+            </Grid.Column>
+          </Grid>
+        </Card.Content>
+      </Card>
+    </div>
+  );
+};
+
+export default Playground;
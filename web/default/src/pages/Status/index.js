@@ -0,0 +1,272 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { Button, Card, Icon, Label, Table } from 'semantic-ui-react';
+import {
+  API,
+  isAdmin,
+  showError,
+  showInfo,
+  timestamp2string,
+} from '../../helpers';
+
+const statusColors = {
+  operational: 'green',
+  degraded: 'yellow',
+  down: 'red',
+  unknown: 'grey',
+};
+
+const renderPercent = (value, checks) => {
+  if (checks === 0) return '-';
+  return (value * 100).toFixed(2) + '%';
+};
+
+const renderLatency = (value) => {
+  if (!value) return '-';
+  return (value / 1000).toFixed(2) + ' s';
+};
+
+const renderFeature = (passed) => {
+  if (passed === undefined) return '-';
+  return passed ? (
+    <Icon name='check' color='green' />
+  ) : (
+    <Icon name='close' color='red' />
+  );
+};
+
+const Status = () => {
+  const { t } = useTranslation();
+  const [models, setModels] = useState([]);
+  const [windowHours, setWindowHours] = useState(0);
+  const [loading, setLoading] = useState(true);
+  const [features, setFeatures] = useState([]);
+  const [matrix, setMatrix] = useState([]);
+  const [selfTestResults, setSelfTestResults] = useState([]);
+  const [selfTestRunning, setSelfTestRunning] = useState(false);
+  const isAdminUser = isAdmin();
+
+  const loadStatus = async () => {
+    const res = await API.get('/api/status/models');
+    const { success, message, data } = res.data;
+    if (success) {
+      setModels(data.models || []);
+      setWindowHours(data.window_hours);
+    } else {
+      showError(message);
+    }
+    setLoading(false);
+  };
+
+  const loadMatrix = async () => {
+    const res = await API.get('/api/selftest/matrix');
+    const { success, data } = res.data;
+    if (success) {
+      setFeatures(data.features || []);
+      setMatrix(data.models || []);
+    }
+  };
+
+  const loadSelfTest = async () => {
+    const res = await API.get('/api/selftest');
+    const { success, message, data } = res.data;
+    if (success) {
+      setSelfTestResults(data.results || []);
+      setSelfTestRunning(data.running);
+    } else {
+      showError(message);
+    }
+  };
+
+  const startSelfTest = async () => {
+    const res = await API.post('/api/selftest');
+    const { success, message } = res.data;
+    if (success) {
+      showInfo(t('status_page.self_test.started'));
+      setSelfTestRunning(true);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadStatus().then();
+    loadMatrix().then();
+    if (isAdminUser) {
+      loadSelfTest().then();
+    }
+  }, []);
+
+  return (
+    <div className='dashboard-container'>
+      <Card fluid className='chart-card'>
+        <Card.Content>
+          <Card.Header className='header'>{t('status_page.title')}</Card.Header>
+          <Card.Meta>
+            {t('status_page.description', { hours: windowHours })}
+          </Card.Meta>
+          <Table basic='very' compact>
+            <Table.Header>
+              <Table.Row>
+                <Table.HeaderCell>{t('status_page.model')}</Table.HeaderCell>
+                <Table.HeaderCell>{t('status_page.status')}</Table.HeaderCell>
+                <Table.HeaderCell>
+                  {t('status_page.availability')}
+                </Table.HeaderCell>
+                <Table.HeaderCell>
+                  {t('status_page.error_rate')}
+                </Table.HeaderCell>
+                <Table.HeaderCell>P50</Table.HeaderCell>
+                <Table.HeaderCell>P95</Table.HeaderCell>
+                <Table.HeaderCell>P99</Table.HeaderCell>
+                <Table.HeaderCell>
+                  {t('status_page.last_checked')}
+                </Table.HeaderCell>
+              </Table.Row>
+            </Table.Header>
+            <Table.Body>
+              {models.map((model) => (
+                <Table.Row key={model.model}>
+                  <Table.Cell>{model.model}</Table.Cell>
+                  <Table.Cell>
+                    <Label basic color={statusColors[model.status]}>
+                      {t('status_page.statuses.' + model.status)}
+                    </Label>
+                  </Table.Cell>
+                  <Table.Cell>
+                    {renderPercent(model.availability, model.checks)}
+                  </Table.Cell>
+                  <Table.Cell>
+                    {renderPercent(model.error_rate, model.checks)}
+                  </Table.Cell>
+                  <Table.Cell>{renderLatency(model.latency_p50)}</Table.Cell>
+                  <Table.Cell>{renderLatency(model.latency_p95)}</Table.Cell>
+                  <Table.Cell>{renderLatency(model.latency_p99)}</Table.Cell>
+                  <Table.Cell>
+                    {model.last_checked_at
+                      ? timestamp2string(model.last_checked_at)
+                      : '-'}
+                  </Table.Cell>
+                </Table.Row>
+              ))}
+              {!loading && models.length === 0 && (
+                <Table.Row>
+                  <Table.Cell colSpan='8'>{t('status_page.empty')}</Table.Cell>
+                </Table.Row>
+              )}
+            </Table.Body>
+          </Table>
+        </Card.Content>
+      </Card>
+      {(matrix.length > 0 || isAdminUser) && (
+        <Card fluid className='chart-card'>
+          <Card.Content>
+            <Card.Header className='header'>
+              {t('status_page.self_test.title')}
+            </Card.Header>
+            <Card.Meta>{t('status_page.self_test.description')}</Card.Meta>
+            <Table basic='very' compact>
+              <Table.Header>
+                <Table.Row>
+                  <Table.HeaderCell>{t('status_page.model')}</Table.HeaderCell>
+                  {features.map((feature) => (
+                    <Table.HeaderCell key={feature}>
+                      {t('status_page.self_test.features.' + feature)}
+                    </Table.HeaderCell>
+                  ))}
+                  <Table.HeaderCell>
+                    {t('status_page.last_checked')}
+                  </Table.HeaderCell>
+                </Table.Row>
+              </Table.Header>
+              <Table.Body>
+                {matrix.map((row) => (
+                  <Table.Row key={row.model}>
+                    <Table.Cell>{row.model}</Table.Cell>
+                    {features.map((feature) => (
+                      <Table.Cell key={feature}>
+                        {renderFeature(row.features[feature])}
+                      </Table.Cell>
+                    ))}
+                    <Table.Cell>{timestamp2string(row.checked_at)}</Table.Cell>
+                  </Table.Row>
+                ))}
+                {matrix.length === 0 && (
+                  <Table.Row>
+                    <Table.Cell colSpan={features.length + 2}>
+                      {t('status_page.empty')}
+                    </Table.Cell>
+                  </Table.Row>
+                )}
+              </Table.Body>
+            </Table>
+          </Card.Content>
+        </Card>
+      )}
+      {isAdminUser && (
+        <Card fluid className='chart-card'>
+          <Card.Content>
+            <Card.Header className='header'>
+              {t('status_page.self_test.channels')}
+            </Card.Header>
+            <Table basic='very' compact size='small'>
+              <Table.Header>
+                <Table.Row>
+                  <Table.HeaderCell>
+                    {t('status_page.self_test.channel')}
+                  </Table.HeaderCell>
+                  <Table.HeaderCell>{t('status_page.model')}</Table.HeaderCell>
+                  <Table.HeaderCell>
+                    {t('status_page.self_test.feature')}
+                  </Table.HeaderCell>
+                  <Table.HeaderCell>
+                    {t('status_page.self_test.result')}
+                  </Table.HeaderCell>
+                  <Table.HeaderCell>
+                    {t('status_page.last_checked')}
+                  </Table.HeaderCell>
+                </Table.Row>
+              </Table.Header>
+              <Table.Body>
+                {selfTestResults.map((result) => (
+                  <Table.Row key={result.id}>
+                    <Table.Cell>{result.channel_name}</Table.Cell>
+                    <Table.Cell>{result.model}</Table.Cell>
+                    <Table.Cell>
+                      {t('status_page.self_test.features.' + result.feature)}
+                    </Table.Cell>
+                    <Table.Cell>
+                      {renderFeature(result.passed)} {result.message}
+                    </Table.Cell>
+                    <Table.Cell>
+                      {timestamp2string(result.created_at)}
+                    </Table.Cell>
+                  </Table.Row>
+                ))}
+              </Table.Body>
+              <Table.Footer>
+                <Table.Row>
+                  <Table.HeaderCell colSpan='5'>
+                    <Button
+                      size='small'
+                      loading={selfTestRunning}
+                      disabled={selfTestRunning}
+                      onClick={startSelfTest}
+                    >
+                      {t('status_page.self_test.run')}
+                    </Button>
+                    <Button size='small' onClick={loadSelfTest}>
+                      {t('status_page.self_test.refresh')}
+                    </Button>
+                  </Table.HeaderCell>
+                </Table.Row>
+              </Table.Footer>
+            </Table>
+          </Card.Content>
+        </Card>
+      )}
+    </div>
+  );
+};
+
+export default Status;
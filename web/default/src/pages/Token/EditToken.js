@@ -32,6 +32,12 @@ const EditToken = () => {
     unlimited_quota: false,
     models: [],
     subnet: '',
+    max_concurrency: 0,
+    allowed_origins: '',
+    sandbox: false,
+    openai_organization: '',
+    openai_project: '',
+    transcript_webhook: '',
   };
   const [inputs, setInputs] = useState(originInputs);
   const { name, remain_quota, expired_time, unlimited_quota } = inputs;
@@ -121,6 +127,7 @@ const EditToken = () => {
     if (!isEdit && inputs.name === '') return;
     let localInputs = inputs;
     localInputs.remain_quota = parseInt(localInputs.remain_quota);
+    localInputs.max_concurrency = parseInt(localInputs.max_concurrency) || 0;
     if (localInputs.expired_time !== -1) {
       let time = Date.parse(localInputs.expired_time);
       if (isNaN(time)) {
@@ -199,6 +206,64 @@ const EditToken = () => {
                 autoComplete='new-password'
               />
             </Form.Field>
+            <Form.Field>
+              <Form.Input
+                label={t('token.edit.max_concurrency')}
+                name='max_concurrency'
+                placeholder={t('token.edit.max_concurrency_placeholder')}
+                onChange={handleInputChange}
+                value={inputs.max_concurrency}
+                autoComplete='new-password'
+                type='number'
+                min='0'
+              />
+            </Form.Field>
+            <Form.Field>
+              <Form.Input
+                label={t('token.edit.allowed_origins')}
+                name='allowed_origins'
+                placeholder={t('token.edit.allowed_origins_placeholder')}
+                onChange={handleInputChange}
+                value={inputs.allowed_origins}
+                autoComplete='new-password'
+              />
+            </Form.Field>
+            <Form.Group widths='equal'>
+              <Form.Input
+                label={t('token.edit.openai_organization')}
+                name='openai_organization'
+                placeholder={t('token.edit.openai_override_placeholder')}
+                onChange={handleInputChange}
+                value={inputs.openai_organization}
+                autoComplete='new-password'
+              />
+              <Form.Input
+                label={t('token.edit.openai_project')}
+                name='openai_project'
+                placeholder={t('token.edit.openai_override_placeholder')}
+                onChange={handleInputChange}
+                value={inputs.openai_project}
+                autoComplete='new-password'
+              />
+            </Form.Group>
+            <Form.Field>
+              <Form.Input
+                label={t('token.edit.transcript_webhook')}
+                name='transcript_webhook'
+                placeholder={t('token.edit.transcript_webhook_placeholder')}
+                onChange={handleInputChange}
+                value={inputs.transcript_webhook}
+                autoComplete='new-password'
+              />
+            </Form.Field>
+            <Form.Checkbox
+              checked={inputs.sandbox === true}
+              label={t('token.edit.sandbox')}
+              name='sandbox'
+              onChange={() =>
+                setInputs((inputs) => ({ ...inputs, sandbox: !inputs.sandbox }))
+              }
+            />
             <Form.Field>
               <Form.Input
                 label={t('token.edit.expire_time')}
@@ -7,18 +7,33 @@ import {
   Card,
   Statistic,
   Divider,
+  Table,
 } from 'semantic-ui-react';
-import { API, showError, showInfo, showSuccess } from '../../helpers';
-import { renderQuota } from '../../helpers/render';
+import {
+  API,
+  showError,
+  showInfo,
+  showSuccess,
+  timestamp2string,
+} from '../../helpers';
+import { renderQuota, renderQuotaWithPrompt } from '../../helpers/render';
 import { useTranslation } from 'react-i18next';
 
 const TopUp = () => {
   const { t } = useTranslation();
   const [redemptionCode, setRedemptionCode] = useState('');
+  const [couponCode, setCouponCode] = useState('');
   const [topUpLink, setTopUpLink] = useState('');
   const [userQuota, setUserQuota] = useState(0);
   const [isSubmitting, setIsSubmitting] = useState(false);
   const [user, setUser] = useState({});
+  const [transferEnabled, setTransferEnabled] = useState(false);
+  const [transferInputs, setTransferInputs] = useState({
+    username: '',
+    quota: '',
+    remark: '',
+  });
+  const [transfers, setTransfers] = useState([]);
 
   const topUp = async () => {
     if (redemptionCode === '') {
@@ -29,6 +44,7 @@ const TopUp = () => {
     try {
       const res = await API.post('/api/user/topup', {
         key: redemptionCode,
+        coupon: couponCode.trim(),
       });
       const { success, message, data } = res.data;
       if (success) {
@@ -37,6 +53,7 @@ const TopUp = () => {
           return quota + data;
         });
         setRedemptionCode('');
+        setCouponCode('');
       } else {
         showError(message);
       }
@@ -47,6 +64,22 @@ const TopUp = () => {
     }
   };
 
+  // the usage coupons are claimed on their own, the top-up coupons are given with a redemption code
+  const claimCoupon = async () => {
+    if (couponCode.trim() === '') {
+      showInfo(t('topup.coupon.empty'));
+      return;
+    }
+    const res = await API.post('/api/user/coupon', { code: couponCode.trim() });
+    const { success, message, data } = res.data;
+    if (success) {
+      showSuccess(t('topup.coupon.claimed', { name: data.name }));
+      setCouponCode('');
+    } else {
+      showError(message);
+    }
+  };
+
   const openTopUpLink = () => {
     if (!topUpLink) {
       showError(t('topup.redeem_code.no_link'));
@@ -61,6 +94,48 @@ const TopUp = () => {
     window.open(url.toString(), '_blank');
   };
 
+  const loadTransfers = async () => {
+    const res = await API.get('/api/user/transfer');
+    const { success, message, data } = res.data;
+    if (success) {
+      setTransfers(data || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  const handleTransferInputChange = (e, { name, value }) => {
+    setTransferInputs((inputs) => ({ ...inputs, [name]: value }));
+  };
+
+  const transfer = async () => {
+    const quota = parseInt(transferInputs.quota);
+    if (transferInputs.username === '' || !(quota > 0)) {
+      showInfo(t('topup.transfer.empty'));
+      return;
+    }
+    setIsSubmitting(true);
+    try {
+      const res = await API.post('/api/user/transfer', {
+        ...transferInputs,
+        quota,
+      });
+      const { success, message, data } = res.data;
+      if (success) {
+        showSuccess(t('topup.transfer.success'));
+        setUserQuota((userQuota) => userQuota - data.quota - data.fee);
+        setTransferInputs({ username: '', quota: '', remark: '' });
+        loadTransfers().then();
+      } else {
+        showError(message);
+      }
+    } catch (err) {
+      showError(t('topup.redeem_code.request_failed'));
+    } finally {
+      setIsSubmitting(false);
+    }
+  };
+
   const getUserQuota = async () => {
     let res = await API.get(`/api/user/self`);
     const { success, message, data } = res.data;
@@ -79,6 +154,10 @@ const TopUp = () => {
       if (status.top_up_link) {
         setTopUpLink(status.top_up_link);
       }
+      if (status.quota_transfer) {
+        setTransferEnabled(true);
+        loadTransfers().then();
+      }
     }
     getUserQuota().then();
   }, []);
@@ -223,6 +302,23 @@ const TopUp = () => {
                           />
                         }
                       />
+                      <Form.Input
+                        fluid
+                        icon='tag'
+                        iconPosition='left'
+                        placeholder={t('topup.coupon.placeholder')}
+                        value={couponCode}
+                        onChange={(e) => {
+                          setCouponCode(e.target.value);
+                        }}
+                        action={
+                          <Button
+                            content={t('topup.coupon.claim')}
+                            onClick={claimCoupon}
+                          />
+                        }
+                        style={{ marginTop: '1em' }}
+                      />
 
                       <div style={{ paddingBottom: '1em' }}>
                         <Button
@@ -244,6 +340,97 @@ const TopUp = () => {
               </Card>
             </Grid.Column>
           </Grid>
+
+          {transferEnabled && (
+            <>
+              <Divider />
+              <Header as='h3'>
+                <i className='exchange icon'></i>
+                {t('topup.transfer.title')}
+              </Header>
+              <Form>
+                <Form.Group widths='equal'>
+                  <Form.Input
+                    label={t('topup.transfer.username')}
+                    name='username'
+                    value={transferInputs.username}
+                    onChange={handleTransferInputChange}
+                  />
+                  <Form.Input
+                    label={`${t('topup.transfer.quota')}${renderQuotaWithPrompt(
+                      parseInt(transferInputs.quota) || 0,
+                      t
+                    )}`}
+                    name='quota'
+                    type='number'
+                    min='1'
+                    value={transferInputs.quota}
+                    onChange={handleTransferInputChange}
+                  />
+                  <Form.Input
+                    label={t('topup.transfer.remark')}
+                    name='remark'
+                    value={transferInputs.remark}
+                    onChange={handleTransferInputChange}
+                  />
+                </Form.Group>
+                <Button
+                  primary
+                  onClick={transfer}
+                  loading={isSubmitting}
+                  disabled={isSubmitting}
+                >
+                  {t('topup.transfer.submit')}
+                </Button>
+              </Form>
+              {transfers.length > 0 && (
+                <Table basic='very' compact>
+                  <Table.Header>
+                    <Table.Row>
+                      <Table.HeaderCell>
+                        {t('topup.transfer.time')}
+                      </Table.HeaderCell>
+                      <Table.HeaderCell>
+                        {t('topup.transfer.direction')}
+                      </Table.HeaderCell>
+                      <Table.HeaderCell>
+                        {t('topup.transfer.quota')}
+                      </Table.HeaderCell>
+                      <Table.HeaderCell>
+                        {t('topup.transfer.fee')}
+                      </Table.HeaderCell>
+                      <Table.HeaderCell>
+                        {t('topup.transfer.remark')}
+                      </Table.HeaderCell>
+                    </Table.Row>
+                  </Table.Header>
+                  <Table.Body>
+                    {transfers.map((transfer) => (
+                      <Table.Row key={transfer.id}>
+                        <Table.Cell>
+                          {timestamp2string(transfer.created_at)}
+                        </Table.Cell>
+                        <Table.Cell>
+                          {transfer.from_user_id === user.id
+                            ? t('topup.transfer.sent_to', {
+                                username: transfer.to_username,
+                              })
+                            : t('topup.transfer.received_from', {
+                                username: transfer.from_username,
+                              })}
+                        </Table.Cell>
+                        <Table.Cell>
+                          {renderQuota(transfer.quota, t)}
+                        </Table.Cell>
+                        <Table.Cell>{renderQuota(transfer.fee, t)}</Table.Cell>
+                        <Table.Cell>{transfer.remark}</Table.Cell>
+                      </Table.Row>
+                    ))}
+                  </Table.Body>
+                </Table>
+              )}
+            </>
+          )}
         </Card.Content>
       </Card>
     </div>
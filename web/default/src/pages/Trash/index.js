@@ -0,0 +1,42 @@
+import React from 'react';
+import { useTranslation } from 'react-i18next';
+import { Card, Tab } from 'semantic-ui-react';
+import TrashTable from '../../components/TrashTable';
+import { isAdmin } from '../../helpers';
+
+const Trash = () => {
+  const { t } = useTranslation();
+
+  let kinds = ['token'];
+  if (isAdmin()) {
+    kinds.push('channel', 'user');
+  }
+  const panes = kinds.map((kind) => ({
+    menuItem: t(`trash.tabs.${kind}`),
+    render: () => (
+      <Tab.Pane attached={false}>
+        <TrashTable kind={kind} />
+      </Tab.Pane>
+    ),
+  }));
+
+  return (
+    <div className='dashboard-container'>
+      <Card fluid className='chart-card'>
+        <Card.Content>
+          <Card.Header className='header'>{t('trash.title')}</Card.Header>
+          <Tab
+            menu={{
+              secondary: true,
+              pointing: true,
+              className: 'settings-tab',
+            }}
+            panes={panes}
+          />
+        </Card.Content>
+      </Card>
+    </div>
+  );
+};
+
+export default Trash;
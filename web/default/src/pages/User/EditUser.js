@@ -19,8 +19,12 @@ const EditUser = () => {
     email: '',
     quota: 0,
     group: 'default',
+    extra_groups: '',
+    plan_id: 0,
   });
   const [groupOptions, setGroupOptions] = useState([]);
+  const [planOptions, setPlanOptions] = useState([]);
+  const [originPlanId, setOriginPlanId] = useState(0);
   const {
     username,
     display_name,
@@ -48,6 +52,21 @@ const EditUser = () => {
       showError(error.message);
     }
   };
+  const fetchPlans = async () => {
+    try {
+      let res = await API.get(`/api/plan/`);
+      setPlanOptions([
+        { key: 0, text: t('user.edit.no_plan'), value: 0 },
+        ...res.data.data.map((plan) => ({
+          key: plan.id,
+          text: plan.name,
+          value: plan.id,
+        })),
+      ]);
+    } catch (error) {
+      showError(error.message);
+    }
+  };
   const navigate = useNavigate();
   const handleCancel = () => {
     navigate('/setting');
@@ -63,6 +82,7 @@ const EditUser = () => {
     if (success) {
       data.password = '';
       setInputs(data);
+      setOriginPlanId(data.plan_id || 0);
     } else {
       showError(message);
     }
@@ -72,6 +92,7 @@ const EditUser = () => {
     loadUser().then();
     if (userId) {
       fetchGroups().then();
+      fetchPlans().then();
     }
   }, []);
 
@@ -86,7 +107,20 @@ const EditUser = () => {
     } else {
       res = await API.put(`/api/user/self`, inputs);
     }
-    const { success, message } = res.data;
+    let { success, message } = res.data;
+    if (success && userId && inputs.plan_id !== originPlanId) {
+      // the plan is changed after the user, as it grants the quota of the plan
+      res = await API.post(`/api/plan/user`, {
+        user_id: parseInt(userId),
+        plan_id: inputs.plan_id,
+      });
+      ({ success, message } = res.data);
+      if (success) {
+        res.data.data.password = '';
+        setInputs(res.data.data);
+        setOriginPlanId(res.data.data.plan_id);
+      }
+    }
     if (success) {
       showSuccess(t('user.messages.update_success'));
     } else {
@@ -149,6 +183,41 @@ const EditUser = () => {
                     options={groupOptions}
                   />
                 </Form.Field>
+                <Form.Field>
+                  <Form.Dropdown
+                    label={t('user.edit.extra_groups')}
+                    placeholder={t('user.edit.extra_groups_placeholder')}
+                    fluid
+                    multiple
+                    search
+                    selection
+                    allowAdditions
+                    additionLabel={t('user.edit.group_addition')}
+                    onChange={(e, { value }) =>
+                      handleInputChange(e, {
+                        name: 'extra_groups',
+                        value: value.join(','),
+                      })
+                    }
+                    value={
+                      inputs.extra_groups
+                        ? inputs.extra_groups.split(',')
+                        : []
+                    }
+                    options={groupOptions}
+                  />
+                </Form.Field>
+                <Form.Field>
+                  <Form.Dropdown
+                    label={t('user.edit.plan')}
+                    name='plan_id'
+                    fluid
+                    selection
+                    onChange={handleInputChange}
+                    value={inputs.plan_id}
+                    options={planOptions}
+                  />
+                </Form.Field>
                 <Form.Field>
                   <Form.Input
                     label={`${t('user.edit.quota')}${renderQuotaWithPrompt(